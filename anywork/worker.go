@@ -6,6 +6,7 @@ import (
 	"os"
 	"runtime"
 	"sync"
+	"sync/atomic"
 )
 
 var (
@@ -14,6 +15,7 @@ var (
 	failpipe    Failures
 	errcount    Counters
 	headcount   uint64
+	stopped     int32
 	WorkerCount int
 )
 
@@ -32,9 +34,24 @@ func catcher(title string, identity uint64) {
 func process(fun Work, identity uint64) {
 	defer group.Done()
 	defer catcher("process", identity)
+	if Stopped() {
+		failpipe <- ""
+		return
+	}
 	fun()
 }
 
+// Stop makes workers skip all work that has not yet started. Skipped work
+// counts as failure in Sync, but is not reported one by one.
+func Stop() {
+	atomic.StoreInt32(&stopped, 1)
+}
+
+// Stopped tells if Stop has been called.
+func Stopped() bool {
+	return atomic.LoadInt32(&stopped) != 0
+}
+
 func member(identity uint64) {
 	defer catcher("member", identity)
 	for {
@@ -52,7 +69,9 @@ func watcher(failures Failures, counters Counters) {
 		select {
 		case fail := <-failures:
 			counter += 1
-			fmt.Fprintln(os.Stderr, fail)
+			if len(fail) > 0 {
+				fmt.Fprintln(os.Stderr, fail)
+			}
 		case counters <- counter:
 			counter = 0
 		}
@@ -100,6 +119,9 @@ func Backlog(todo Work) {
 func Sync() error {
 	group.Wait()
 	count := <-errcount
+	if count > 0 && Stopped() {
+		return fmt.Errorf("Background work was stopped, %d work item(s) failed or were skipped.", count)
+	}
 	if count > 0 {
 		return fmt.Errorf("There has been %d failures. See messages above.", count)
 	}
@@ -0,0 +1,682 @@
+package blake3
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+	"runtime"
+	"sync"
+)
+
+// Plain (unkeyed, 32 byte output) BLAKE3 hash, following reference
+// implementation. Large inputs are hashed in parallel, since chunks are
+// independent until they are merged in tree.
+
+const (
+	Size      = 32
+	BlockSize = 64
+
+	chunkLength = 1024
+
+	chunkStart = 1 << 0
+	chunkEnd   = 1 << 1
+	parent     = 1 << 2
+	root       = 1 << 3
+
+	// pending input is hashed, when it has grown this big (in chunks)
+	batchChunks = 256
+	// parallel hashing is not worth it for less chunks than this
+	parallelChunks = 32
+)
+
+var (
+	iv = [8]uint32{0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19}
+)
+
+// compress is unrolled (with message permutation done at generation time),
+// since it is where all the time goes
+func compress(cv *[8]uint32, block *[16]uint32, counter uint64, length, flags uint32) [16]uint32 {
+	s0, s1, s2, s3, s4, s5, s6, s7 := cv[0], cv[1], cv[2], cv[3], cv[4], cv[5], cv[6], cv[7]
+	s8, s9, s10, s11 := iv[0], iv[1], iv[2], iv[3]
+	s12, s13, s14, s15 := uint32(counter), uint32(counter>>32), length, flags
+	m0, m1, m2, m3, m4, m5, m6, m7 := block[0], block[1], block[2], block[3], block[4], block[5], block[6], block[7]
+	m8, m9, m10, m11, m12, m13, m14, m15 := block[8], block[9], block[10], block[11], block[12], block[13], block[14], block[15]
+	s0 += s4 + m0
+	s12 = bits.RotateLeft32(s12^s0, -16)
+	s8 += s12
+	s4 = bits.RotateLeft32(s4^s8, -12)
+	s0 += s4 + m1
+	s12 = bits.RotateLeft32(s12^s0, -8)
+	s8 += s12
+	s4 = bits.RotateLeft32(s4^s8, -7)
+	s1 += s5 + m2
+	s13 = bits.RotateLeft32(s13^s1, -16)
+	s9 += s13
+	s5 = bits.RotateLeft32(s5^s9, -12)
+	s1 += s5 + m3
+	s13 = bits.RotateLeft32(s13^s1, -8)
+	s9 += s13
+	s5 = bits.RotateLeft32(s5^s9, -7)
+	s2 += s6 + m4
+	s14 = bits.RotateLeft32(s14^s2, -16)
+	s10 += s14
+	s6 = bits.RotateLeft32(s6^s10, -12)
+	s2 += s6 + m5
+	s14 = bits.RotateLeft32(s14^s2, -8)
+	s10 += s14
+	s6 = bits.RotateLeft32(s6^s10, -7)
+	s3 += s7 + m6
+	s15 = bits.RotateLeft32(s15^s3, -16)
+	s11 += s15
+	s7 = bits.RotateLeft32(s7^s11, -12)
+	s3 += s7 + m7
+	s15 = bits.RotateLeft32(s15^s3, -8)
+	s11 += s15
+	s7 = bits.RotateLeft32(s7^s11, -7)
+	s0 += s5 + m8
+	s15 = bits.RotateLeft32(s15^s0, -16)
+	s10 += s15
+	s5 = bits.RotateLeft32(s5^s10, -12)
+	s0 += s5 + m9
+	s15 = bits.RotateLeft32(s15^s0, -8)
+	s10 += s15
+	s5 = bits.RotateLeft32(s5^s10, -7)
+	s1 += s6 + m10
+	s12 = bits.RotateLeft32(s12^s1, -16)
+	s11 += s12
+	s6 = bits.RotateLeft32(s6^s11, -12)
+	s1 += s6 + m11
+	s12 = bits.RotateLeft32(s12^s1, -8)
+	s11 += s12
+	s6 = bits.RotateLeft32(s6^s11, -7)
+	s2 += s7 + m12
+	s13 = bits.RotateLeft32(s13^s2, -16)
+	s8 += s13
+	s7 = bits.RotateLeft32(s7^s8, -12)
+	s2 += s7 + m13
+	s13 = bits.RotateLeft32(s13^s2, -8)
+	s8 += s13
+	s7 = bits.RotateLeft32(s7^s8, -7)
+	s3 += s4 + m14
+	s14 = bits.RotateLeft32(s14^s3, -16)
+	s9 += s14
+	s4 = bits.RotateLeft32(s4^s9, -12)
+	s3 += s4 + m15
+	s14 = bits.RotateLeft32(s14^s3, -8)
+	s9 += s14
+	s4 = bits.RotateLeft32(s4^s9, -7)
+	s0 += s4 + m2
+	s12 = bits.RotateLeft32(s12^s0, -16)
+	s8 += s12
+	s4 = bits.RotateLeft32(s4^s8, -12)
+	s0 += s4 + m6
+	s12 = bits.RotateLeft32(s12^s0, -8)
+	s8 += s12
+	s4 = bits.RotateLeft32(s4^s8, -7)
+	s1 += s5 + m3
+	s13 = bits.RotateLeft32(s13^s1, -16)
+	s9 += s13
+	s5 = bits.RotateLeft32(s5^s9, -12)
+	s1 += s5 + m10
+	s13 = bits.RotateLeft32(s13^s1, -8)
+	s9 += s13
+	s5 = bits.RotateLeft32(s5^s9, -7)
+	s2 += s6 + m7
+	s14 = bits.RotateLeft32(s14^s2, -16)
+	s10 += s14
+	s6 = bits.RotateLeft32(s6^s10, -12)
+	s2 += s6 + m0
+	s14 = bits.RotateLeft32(s14^s2, -8)
+	s10 += s14
+	s6 = bits.RotateLeft32(s6^s10, -7)
+	s3 += s7 + m4
+	s15 = bits.RotateLeft32(s15^s3, -16)
+	s11 += s15
+	s7 = bits.RotateLeft32(s7^s11, -12)
+	s3 += s7 + m13
+	s15 = bits.RotateLeft32(s15^s3, -8)
+	s11 += s15
+	s7 = bits.RotateLeft32(s7^s11, -7)
+	s0 += s5 + m1
+	s15 = bits.RotateLeft32(s15^s0, -16)
+	s10 += s15
+	s5 = bits.RotateLeft32(s5^s10, -12)
+	s0 += s5 + m11
+	s15 = bits.RotateLeft32(s15^s0, -8)
+	s10 += s15
+	s5 = bits.RotateLeft32(s5^s10, -7)
+	s1 += s6 + m12
+	s12 = bits.RotateLeft32(s12^s1, -16)
+	s11 += s12
+	s6 = bits.RotateLeft32(s6^s11, -12)
+	s1 += s6 + m5
+	s12 = bits.RotateLeft32(s12^s1, -8)
+	s11 += s12
+	s6 = bits.RotateLeft32(s6^s11, -7)
+	s2 += s7 + m9
+	s13 = bits.RotateLeft32(s13^s2, -16)
+	s8 += s13
+	s7 = bits.RotateLeft32(s7^s8, -12)
+	s2 += s7 + m14
+	s13 = bits.RotateLeft32(s13^s2, -8)
+	s8 += s13
+	s7 = bits.RotateLeft32(s7^s8, -7)
+	s3 += s4 + m15
+	s14 = bits.RotateLeft32(s14^s3, -16)
+	s9 += s14
+	s4 = bits.RotateLeft32(s4^s9, -12)
+	s3 += s4 + m8
+	s14 = bits.RotateLeft32(s14^s3, -8)
+	s9 += s14
+	s4 = bits.RotateLeft32(s4^s9, -7)
+	s0 += s4 + m3
+	s12 = bits.RotateLeft32(s12^s0, -16)
+	s8 += s12
+	s4 = bits.RotateLeft32(s4^s8, -12)
+	s0 += s4 + m4
+	s12 = bits.RotateLeft32(s12^s0, -8)
+	s8 += s12
+	s4 = bits.RotateLeft32(s4^s8, -7)
+	s1 += s5 + m10
+	s13 = bits.RotateLeft32(s13^s1, -16)
+	s9 += s13
+	s5 = bits.RotateLeft32(s5^s9, -12)
+	s1 += s5 + m12
+	s13 = bits.RotateLeft32(s13^s1, -8)
+	s9 += s13
+	s5 = bits.RotateLeft32(s5^s9, -7)
+	s2 += s6 + m13
+	s14 = bits.RotateLeft32(s14^s2, -16)
+	s10 += s14
+	s6 = bits.RotateLeft32(s6^s10, -12)
+	s2 += s6 + m2
+	s14 = bits.RotateLeft32(s14^s2, -8)
+	s10 += s14
+	s6 = bits.RotateLeft32(s6^s10, -7)
+	s3 += s7 + m7
+	s15 = bits.RotateLeft32(s15^s3, -16)
+	s11 += s15
+	s7 = bits.RotateLeft32(s7^s11, -12)
+	s3 += s7 + m14
+	s15 = bits.RotateLeft32(s15^s3, -8)
+	s11 += s15
+	s7 = bits.RotateLeft32(s7^s11, -7)
+	s0 += s5 + m6
+	s15 = bits.RotateLeft32(s15^s0, -16)
+	s10 += s15
+	s5 = bits.RotateLeft32(s5^s10, -12)
+	s0 += s5 + m5
+	s15 = bits.RotateLeft32(s15^s0, -8)
+	s10 += s15
+	s5 = bits.RotateLeft32(s5^s10, -7)
+	s1 += s6 + m9
+	s12 = bits.RotateLeft32(s12^s1, -16)
+	s11 += s12
+	s6 = bits.RotateLeft32(s6^s11, -12)
+	s1 += s6 + m0
+	s12 = bits.RotateLeft32(s12^s1, -8)
+	s11 += s12
+	s6 = bits.RotateLeft32(s6^s11, -7)
+	s2 += s7 + m11
+	s13 = bits.RotateLeft32(s13^s2, -16)
+	s8 += s13
+	s7 = bits.RotateLeft32(s7^s8, -12)
+	s2 += s7 + m15
+	s13 = bits.RotateLeft32(s13^s2, -8)
+	s8 += s13
+	s7 = bits.RotateLeft32(s7^s8, -7)
+	s3 += s4 + m8
+	s14 = bits.RotateLeft32(s14^s3, -16)
+	s9 += s14
+	s4 = bits.RotateLeft32(s4^s9, -12)
+	s3 += s4 + m1
+	s14 = bits.RotateLeft32(s14^s3, -8)
+	s9 += s14
+	s4 = bits.RotateLeft32(s4^s9, -7)
+	s0 += s4 + m10
+	s12 = bits.RotateLeft32(s12^s0, -16)
+	s8 += s12
+	s4 = bits.RotateLeft32(s4^s8, -12)
+	s0 += s4 + m7
+	s12 = bits.RotateLeft32(s12^s0, -8)
+	s8 += s12
+	s4 = bits.RotateLeft32(s4^s8, -7)
+	s1 += s5 + m12
+	s13 = bits.RotateLeft32(s13^s1, -16)
+	s9 += s13
+	s5 = bits.RotateLeft32(s5^s9, -12)
+	s1 += s5 + m9
+	s13 = bits.RotateLeft32(s13^s1, -8)
+	s9 += s13
+	s5 = bits.RotateLeft32(s5^s9, -7)
+	s2 += s6 + m14
+	s14 = bits.RotateLeft32(s14^s2, -16)
+	s10 += s14
+	s6 = bits.RotateLeft32(s6^s10, -12)
+	s2 += s6 + m3
+	s14 = bits.RotateLeft32(s14^s2, -8)
+	s10 += s14
+	s6 = bits.RotateLeft32(s6^s10, -7)
+	s3 += s7 + m13
+	s15 = bits.RotateLeft32(s15^s3, -16)
+	s11 += s15
+	s7 = bits.RotateLeft32(s7^s11, -12)
+	s3 += s7 + m15
+	s15 = bits.RotateLeft32(s15^s3, -8)
+	s11 += s15
+	s7 = bits.RotateLeft32(s7^s11, -7)
+	s0 += s5 + m4
+	s15 = bits.RotateLeft32(s15^s0, -16)
+	s10 += s15
+	s5 = bits.RotateLeft32(s5^s10, -12)
+	s0 += s5 + m0
+	s15 = bits.RotateLeft32(s15^s0, -8)
+	s10 += s15
+	s5 = bits.RotateLeft32(s5^s10, -7)
+	s1 += s6 + m11
+	s12 = bits.RotateLeft32(s12^s1, -16)
+	s11 += s12
+	s6 = bits.RotateLeft32(s6^s11, -12)
+	s1 += s6 + m2
+	s12 = bits.RotateLeft32(s12^s1, -8)
+	s11 += s12
+	s6 = bits.RotateLeft32(s6^s11, -7)
+	s2 += s7 + m5
+	s13 = bits.RotateLeft32(s13^s2, -16)
+	s8 += s13
+	s7 = bits.RotateLeft32(s7^s8, -12)
+	s2 += s7 + m8
+	s13 = bits.RotateLeft32(s13^s2, -8)
+	s8 += s13
+	s7 = bits.RotateLeft32(s7^s8, -7)
+	s3 += s4 + m1
+	s14 = bits.RotateLeft32(s14^s3, -16)
+	s9 += s14
+	s4 = bits.RotateLeft32(s4^s9, -12)
+	s3 += s4 + m6
+	s14 = bits.RotateLeft32(s14^s3, -8)
+	s9 += s14
+	s4 = bits.RotateLeft32(s4^s9, -7)
+	s0 += s4 + m12
+	s12 = bits.RotateLeft32(s12^s0, -16)
+	s8 += s12
+	s4 = bits.RotateLeft32(s4^s8, -12)
+	s0 += s4 + m13
+	s12 = bits.RotateLeft32(s12^s0, -8)
+	s8 += s12
+	s4 = bits.RotateLeft32(s4^s8, -7)
+	s1 += s5 + m9
+	s13 = bits.RotateLeft32(s13^s1, -16)
+	s9 += s13
+	s5 = bits.RotateLeft32(s5^s9, -12)
+	s1 += s5 + m11
+	s13 = bits.RotateLeft32(s13^s1, -8)
+	s9 += s13
+	s5 = bits.RotateLeft32(s5^s9, -7)
+	s2 += s6 + m15
+	s14 = bits.RotateLeft32(s14^s2, -16)
+	s10 += s14
+	s6 = bits.RotateLeft32(s6^s10, -12)
+	s2 += s6 + m10
+	s14 = bits.RotateLeft32(s14^s2, -8)
+	s10 += s14
+	s6 = bits.RotateLeft32(s6^s10, -7)
+	s3 += s7 + m14
+	s15 = bits.RotateLeft32(s15^s3, -16)
+	s11 += s15
+	s7 = bits.RotateLeft32(s7^s11, -12)
+	s3 += s7 + m8
+	s15 = bits.RotateLeft32(s15^s3, -8)
+	s11 += s15
+	s7 = bits.RotateLeft32(s7^s11, -7)
+	s0 += s5 + m7
+	s15 = bits.RotateLeft32(s15^s0, -16)
+	s10 += s15
+	s5 = bits.RotateLeft32(s5^s10, -12)
+	s0 += s5 + m2
+	s15 = bits.RotateLeft32(s15^s0, -8)
+	s10 += s15
+	s5 = bits.RotateLeft32(s5^s10, -7)
+	s1 += s6 + m5
+	s12 = bits.RotateLeft32(s12^s1, -16)
+	s11 += s12
+	s6 = bits.RotateLeft32(s6^s11, -12)
+	s1 += s6 + m3
+	s12 = bits.RotateLeft32(s12^s1, -8)
+	s11 += s12
+	s6 = bits.RotateLeft32(s6^s11, -7)
+	s2 += s7 + m0
+	s13 = bits.RotateLeft32(s13^s2, -16)
+	s8 += s13
+	s7 = bits.RotateLeft32(s7^s8, -12)
+	s2 += s7 + m1
+	s13 = bits.RotateLeft32(s13^s2, -8)
+	s8 += s13
+	s7 = bits.RotateLeft32(s7^s8, -7)
+	s3 += s4 + m6
+	s14 = bits.RotateLeft32(s14^s3, -16)
+	s9 += s14
+	s4 = bits.RotateLeft32(s4^s9, -12)
+	s3 += s4 + m4
+	s14 = bits.RotateLeft32(s14^s3, -8)
+	s9 += s14
+	s4 = bits.RotateLeft32(s4^s9, -7)
+	s0 += s4 + m9
+	s12 = bits.RotateLeft32(s12^s0, -16)
+	s8 += s12
+	s4 = bits.RotateLeft32(s4^s8, -12)
+	s0 += s4 + m14
+	s12 = bits.RotateLeft32(s12^s0, -8)
+	s8 += s12
+	s4 = bits.RotateLeft32(s4^s8, -7)
+	s1 += s5 + m11
+	s13 = bits.RotateLeft32(s13^s1, -16)
+	s9 += s13
+	s5 = bits.RotateLeft32(s5^s9, -12)
+	s1 += s5 + m5
+	s13 = bits.RotateLeft32(s13^s1, -8)
+	s9 += s13
+	s5 = bits.RotateLeft32(s5^s9, -7)
+	s2 += s6 + m8
+	s14 = bits.RotateLeft32(s14^s2, -16)
+	s10 += s14
+	s6 = bits.RotateLeft32(s6^s10, -12)
+	s2 += s6 + m12
+	s14 = bits.RotateLeft32(s14^s2, -8)
+	s10 += s14
+	s6 = bits.RotateLeft32(s6^s10, -7)
+	s3 += s7 + m15
+	s15 = bits.RotateLeft32(s15^s3, -16)
+	s11 += s15
+	s7 = bits.RotateLeft32(s7^s11, -12)
+	s3 += s7 + m1
+	s15 = bits.RotateLeft32(s15^s3, -8)
+	s11 += s15
+	s7 = bits.RotateLeft32(s7^s11, -7)
+	s0 += s5 + m13
+	s15 = bits.RotateLeft32(s15^s0, -16)
+	s10 += s15
+	s5 = bits.RotateLeft32(s5^s10, -12)
+	s0 += s5 + m3
+	s15 = bits.RotateLeft32(s15^s0, -8)
+	s10 += s15
+	s5 = bits.RotateLeft32(s5^s10, -7)
+	s1 += s6 + m0
+	s12 = bits.RotateLeft32(s12^s1, -16)
+	s11 += s12
+	s6 = bits.RotateLeft32(s6^s11, -12)
+	s1 += s6 + m10
+	s12 = bits.RotateLeft32(s12^s1, -8)
+	s11 += s12
+	s6 = bits.RotateLeft32(s6^s11, -7)
+	s2 += s7 + m2
+	s13 = bits.RotateLeft32(s13^s2, -16)
+	s8 += s13
+	s7 = bits.RotateLeft32(s7^s8, -12)
+	s2 += s7 + m6
+	s13 = bits.RotateLeft32(s13^s2, -8)
+	s8 += s13
+	s7 = bits.RotateLeft32(s7^s8, -7)
+	s3 += s4 + m4
+	s14 = bits.RotateLeft32(s14^s3, -16)
+	s9 += s14
+	s4 = bits.RotateLeft32(s4^s9, -12)
+	s3 += s4 + m7
+	s14 = bits.RotateLeft32(s14^s3, -8)
+	s9 += s14
+	s4 = bits.RotateLeft32(s4^s9, -7)
+	s0 += s4 + m11
+	s12 = bits.RotateLeft32(s12^s0, -16)
+	s8 += s12
+	s4 = bits.RotateLeft32(s4^s8, -12)
+	s0 += s4 + m15
+	s12 = bits.RotateLeft32(s12^s0, -8)
+	s8 += s12
+	s4 = bits.RotateLeft32(s4^s8, -7)
+	s1 += s5 + m5
+	s13 = bits.RotateLeft32(s13^s1, -16)
+	s9 += s13
+	s5 = bits.RotateLeft32(s5^s9, -12)
+	s1 += s5 + m0
+	s13 = bits.RotateLeft32(s13^s1, -8)
+	s9 += s13
+	s5 = bits.RotateLeft32(s5^s9, -7)
+	s2 += s6 + m1
+	s14 = bits.RotateLeft32(s14^s2, -16)
+	s10 += s14
+	s6 = bits.RotateLeft32(s6^s10, -12)
+	s2 += s6 + m9
+	s14 = bits.RotateLeft32(s14^s2, -8)
+	s10 += s14
+	s6 = bits.RotateLeft32(s6^s10, -7)
+	s3 += s7 + m8
+	s15 = bits.RotateLeft32(s15^s3, -16)
+	s11 += s15
+	s7 = bits.RotateLeft32(s7^s11, -12)
+	s3 += s7 + m6
+	s15 = bits.RotateLeft32(s15^s3, -8)
+	s11 += s15
+	s7 = bits.RotateLeft32(s7^s11, -7)
+	s0 += s5 + m14
+	s15 = bits.RotateLeft32(s15^s0, -16)
+	s10 += s15
+	s5 = bits.RotateLeft32(s5^s10, -12)
+	s0 += s5 + m10
+	s15 = bits.RotateLeft32(s15^s0, -8)
+	s10 += s15
+	s5 = bits.RotateLeft32(s5^s10, -7)
+	s1 += s6 + m2
+	s12 = bits.RotateLeft32(s12^s1, -16)
+	s11 += s12
+	s6 = bits.RotateLeft32(s6^s11, -12)
+	s1 += s6 + m12
+	s12 = bits.RotateLeft32(s12^s1, -8)
+	s11 += s12
+	s6 = bits.RotateLeft32(s6^s11, -7)
+	s2 += s7 + m3
+	s13 = bits.RotateLeft32(s13^s2, -16)
+	s8 += s13
+	s7 = bits.RotateLeft32(s7^s8, -12)
+	s2 += s7 + m4
+	s13 = bits.RotateLeft32(s13^s2, -8)
+	s8 += s13
+	s7 = bits.RotateLeft32(s7^s8, -7)
+	s3 += s4 + m7
+	s14 = bits.RotateLeft32(s14^s3, -16)
+	s9 += s14
+	s4 = bits.RotateLeft32(s4^s9, -12)
+	s3 += s4 + m13
+	s14 = bits.RotateLeft32(s14^s3, -8)
+	s9 += s14
+	s4 = bits.RotateLeft32(s4^s9, -7)
+	return [16]uint32{
+		s0 ^ s8, s1 ^ s9, s2 ^ s10, s3 ^ s11, s4 ^ s12, s5 ^ s13, s6 ^ s14, s7 ^ s15,
+		s8 ^ cv[0], s9 ^ cv[1], s10 ^ cv[2], s11 ^ cv[3], s12 ^ cv[4], s13 ^ cv[5], s14 ^ cv[6], s15 ^ cv[7],
+	}
+}
+
+func chainingValue(state [16]uint32) (cv [8]uint32) {
+	copy(cv[:], state[:8])
+	return cv
+}
+
+func blockWords(block []byte) (words [16]uint32) {
+	if len(block) < BlockSize {
+		var padded [BlockSize]byte
+		copy(padded[:], block)
+		block = padded[:]
+	}
+	for at := range words {
+		words[at] = binary.LittleEndian.Uint32(block[at*4:])
+	}
+	return words
+}
+
+// output is last compression of chunk or parent, not yet done, since it is
+// different for root node
+type output struct {
+	cv      [8]uint32
+	block   [16]uint32
+	counter uint64
+	length  uint32
+	flags   uint32
+}
+
+func (it *output) chainingValue() [8]uint32 {
+	return chainingValue(compress(&it.cv, &it.block, it.counter, it.length, it.flags))
+}
+
+func (it *output) rootBytes() []byte {
+	state := compress(&it.cv, &it.block, 0, it.length, it.flags|root)
+	result := make([]byte, Size)
+	for at := 0; at < Size/4; at++ {
+		binary.LittleEndian.PutUint32(result[at*4:], state[at])
+	}
+	return result
+}
+
+// chunkOutput hashes chunk (up to 1024 bytes) except for its last block
+func chunkOutput(chunk []byte, counter uint64) *output {
+	cv := iv
+	flags := uint32(chunkStart)
+	for len(chunk) > BlockSize {
+		block := blockWords(chunk[:BlockSize])
+		cv = chainingValue(compress(&cv, &block, counter, BlockSize, flags))
+		flags = 0
+		chunk = chunk[BlockSize:]
+	}
+	return &output{
+		cv:      cv,
+		block:   blockWords(chunk),
+		counter: counter,
+		length:  uint32(len(chunk)),
+		flags:   flags | chunkEnd,
+	}
+}
+
+func parentOutput(left, right [8]uint32) *output {
+	var block [16]uint32
+	copy(block[:8], left[:])
+	copy(block[8:], right[:])
+	return &output{
+		cv:     iv,
+		block:  block,
+		length: BlockSize,
+		flags:  parent,
+	}
+}
+
+type digest struct {
+	stack   [][8]uint32
+	chunks  uint64
+	pending []byte
+}
+
+// New returns BLAKE3 hasher with 32 byte output.
+func New() hash.Hash {
+	return &digest{
+		stack:   make([][8]uint32, 0, 54),
+		pending: make([]byte, 0, batchChunks*chunkLength),
+	}
+}
+
+// Sum256 returns BLAKE3 hash of content.
+func Sum256(content []byte) (result [Size]byte) {
+	hasher := New()
+	hasher.Write(content)
+	copy(result[:], hasher.Sum(nil))
+	return result
+}
+
+func (it *digest) Size() int {
+	return Size
+}
+
+func (it *digest) BlockSize() int {
+	return BlockSize
+}
+
+func (it *digest) Reset() {
+	it.stack = it.stack[:0]
+	it.chunks = 0
+	it.pending = it.pending[:0]
+}
+
+func (it *digest) Write(content []byte) (int, error) {
+	size := len(content)
+	for len(content) > 0 {
+		room := cap(it.pending) - len(it.pending)
+		if room == 0 {
+			it.flush()
+			continue
+		}
+		if room > len(content) {
+			room = len(content)
+		}
+		it.pending = append(it.pending, content[:room]...)
+		content = content[room:]
+	}
+	return size, nil
+}
+
+// flush hashes all full chunks of pending input, except last one (which
+// might be root, if there is no more input)
+func (it *digest) flush() {
+	count := (len(it.pending) - 1) / chunkLength
+	if count < 1 {
+		return
+	}
+	for _, cv := range chunkValues(it.pending[:count*chunkLength], it.chunks) {
+		it.chunks += 1
+		it.push(cv, it.chunks)
+	}
+	it.pending = append(it.pending[:0], it.pending[count*chunkLength:]...)
+}
+
+// push merges completed subtrees, which is possible for each trailing zero
+// bit in total count of chunks
+func (it *digest) push(cv [8]uint32, total uint64) {
+	for total&1 == 0 {
+		last := len(it.stack) - 1
+		cv = parentOutput(it.stack[last], cv).chainingValue()
+		it.stack = it.stack[:last]
+		total >>= 1
+	}
+	it.stack = append(it.stack, cv)
+}
+
+func chunkValues(content []byte, first uint64) [][8]uint32 {
+	count := len(content) / chunkLength
+	result := make([][8]uint32, count)
+	workers := runtime.NumCPU()
+	if count < parallelChunks || workers < 2 {
+		workers = 1
+	}
+	var group sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		group.Add(1)
+		go func(worker int) {
+			defer group.Done()
+			for at := worker; at < count; at += workers {
+				chunk := content[at*chunkLength : (at+1)*chunkLength]
+				result[at] = chunkOutput(chunk, first+uint64(at)).chainingValue()
+			}
+		}(worker)
+	}
+	group.Wait()
+	return result
+}
+
+func (it *digest) Sum(prefix []byte) []byte {
+	clone := &digest{
+		stack:   append(make([][8]uint32, 0, cap(it.stack)), it.stack...),
+		chunks:  it.chunks,
+		pending: append(make([]byte, 0, cap(it.pending)), it.pending...),
+	}
+	clone.flush()
+	final := chunkOutput(clone.pending, clone.chunks)
+	for at := len(clone.stack) - 1; at >= 0; at-- {
+		final = parentOutput(clone.stack[at], final.chainingValue())
+	}
+	return append(prefix, final.rootBytes()...)
+}
@@ -104,7 +104,7 @@ func (it *internalClient) does(method string, request *Request) *Response {
 		response.Elapsed = stopwatch.Elapsed()
 		common.Trace("%s %s took %s", method, url, response.Elapsed)
 	}()
-	httpRequest, err := http.NewRequest(method, url, request.Body)
+	httpRequest, err := http.NewRequestWithContext(common.Context(), method, url, request.Body)
 	if err != nil {
 		response.Status = 9001
 		response.Err = err
@@ -184,7 +184,7 @@ func Download(url, filename string) error {
 	}
 
 	client := &http.Client{Transport: settings.Global.ConfiguredHttpTransport()}
-	request, err := http.NewRequest("GET", url, nil)
+	request, err := http.NewRequestWithContext(common.Context(), "GET", url, nil)
 	if err != nil {
 		return err
 	}
@@ -43,7 +43,7 @@ func sendMetric(metricsHost, kind, name, value string) {
 
 func BackgroundMetric(kind, name, value string) {
 	metricsHost := settings.Global.TelemetryURL()
-	if len(metricsHost) == 0 {
+	if len(metricsHost) == 0 || common.OfflineFlag {
 		return
 	}
 	common.Debug("BackgroundMetric kind:%v name:%v value:%v send:%v", kind, name, value, xviper.CanTrack())
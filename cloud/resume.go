@@ -0,0 +1,162 @@
+package cloud
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/settings"
+)
+
+const (
+	resumeAttempts = 5
+)
+
+// IsUrl tells if location is http(s) URL instead of local file.
+func IsUrl(location string) bool {
+	lower := strings.ToLower(location)
+	return strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "http://")
+}
+
+// SplitChecksum separates "#sha256=<hex>" fragment from URL, if there is one.
+func SplitChecksum(location string) (string, string) {
+	parsed, err := url.Parse(location)
+	if err != nil || !strings.HasPrefix(parsed.Fragment, "sha256=") {
+		return location, ""
+	}
+	checksum := strings.TrimPrefix(parsed.Fragment, "sha256=")
+	parsed.Fragment = ""
+	return parsed.String(), strings.ToLower(checksum)
+}
+
+// validatorOf is strong ETag, or Last-Modified, of response, which is usable
+// in If-Range header. Weak ETags cannot be used there.
+func validatorOf(response *http.Response) string {
+	etag := response.Header.Get("ETag")
+	if len(etag) > 0 && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return response.Header.Get("Last-Modified")
+}
+
+// resumeFrom continues partial download only when server confirms (with
+// If-Range) that resource is still same one, whose start is already in
+// partial file. Otherwise download starts again from zero.
+func resumeFrom(client *http.Client, link, partname string) (done bool, err error) {
+	validatorname := partname + ".validator"
+	offset := int64(0)
+	validator := ""
+	if stat, err := os.Stat(partname); err == nil {
+		offset = stat.Size()
+	}
+	if content, err := os.ReadFile(validatorname); err == nil {
+		validator = strings.TrimSpace(string(content))
+	}
+	if offset > 0 && len(validator) == 0 {
+		common.Debug("Partial download of %q has no ETag or Last-Modified, restarting it from zero.", link)
+		offset = 0
+	}
+	request, err := http.NewRequestWithContext(common.Context(), "GET", link, nil)
+	if err != nil {
+		return false, err
+	}
+	request.Header.Add("Accept", "application/octet-stream")
+	if offset > 0 {
+		request.Header.Add("Range", fmt.Sprintf("bytes=%d-", offset))
+		request.Header.Add("If-Range", validator)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	switch {
+	case response.StatusCode == http.StatusPartialContent && offset > 0 && strings.HasPrefix(response.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)):
+		common.Debug("Resuming download of %q from byte %d.", link, offset)
+	case response.StatusCode == http.StatusPartialContent:
+		os.Remove(partname)
+		os.Remove(validatorname)
+		return false, fmt.Errorf("Server sent unexpected range %q for %q.", response.Header.Get("Content-Range"), link)
+	case response.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		return true, nil
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		if offset > 0 {
+			common.Debug("Resource %q has changed since partial download, restarting it from zero.", link)
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		err = os.WriteFile(validatorname, []byte(validatorOf(response)), 0o644)
+		if err != nil {
+			return true, err
+		}
+	default:
+		return true, fmt.Errorf("Downloading %q failed, reason: %q!", link, response.Status)
+	}
+	sink, err := os.OpenFile(partname, flags, 0o644)
+	if err != nil {
+		return true, err
+	}
+	defer sink.Close()
+	_, err = io.Copy(sink, response.Body)
+	if err != nil {
+		return false, err
+	}
+	return true, sink.Sync()
+}
+
+// ResumableDownload downloads link into filename through partial file,
+// which is kept between attempts (and rcc runs), so that interrupted
+// downloads continue where they left off. When checksum is given, result
+// must match it, or it is removed.
+func ResumableDownload(link, filename, checksum string) (err error) {
+	common.Timeline("start %s resumable download", filename)
+	defer common.Timeline("done %s resumable download", filename)
+
+	pathlib.EnsureDirectory(filepath.Dir(filename))
+	partname := fmt.Sprintf("%s.part", filename)
+	client := &http.Client{Transport: settings.Global.ConfiguredHttpTransport()}
+	for attempt := 1; attempt <= resumeAttempts; attempt++ {
+		done, err := resumeFrom(client, link, partname)
+		if done && err != nil {
+			return err
+		}
+		if done {
+			break
+		}
+		common.Debug("Download attempt %d of %q interrupted, reason: %v", attempt, link, err)
+		if attempt == resumeAttempts || common.Context().Err() != nil {
+			return fmt.Errorf("Downloading %q failed after %d attempts, reason: %v", link, attempt, err)
+		}
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+
+	if len(checksum) > 0 {
+		actual, err := pathlib.Sha256(partname)
+		if err != nil {
+			return err
+		}
+		if actual != checksum {
+			os.Remove(partname)
+			os.Remove(partname + ".validator")
+			return fmt.Errorf("Checksum mismatch for %q, expected %q, got %q.", link, checksum, actual)
+		}
+	}
+	os.Remove(partname + ".validator")
+	return os.Rename(partname, filename)
+}
+
+// DownloadLocation is stable place for downloading link, so that partial
+// download can be found again by later rcc runs.
+func DownloadLocation(link, extension string) string {
+	name := fmt.Sprintf("%02x", sha256.Sum256([]byte(link)))[:16]
+	return filepath.Join(common.RobocorpTempRoot(), "downloads", name+extension)
+}
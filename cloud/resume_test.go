@@ -0,0 +1,87 @@
+package cloud_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/cloud"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestCanSplitChecksumFromUrl(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	must_be.True(cloud.IsUrl("HTTPS://example.com/hololib.zip"))
+	wont_be.True(cloud.IsUrl("hololib.zip"))
+
+	link, checksum := cloud.SplitChecksum("https://example.com/hololib.zip?x=1#sha256=ABC123")
+	must_be.Equal("https://example.com/hololib.zip?x=1", link)
+	must_be.Equal("abc123", checksum)
+
+	link, checksum = cloud.SplitChecksum("https://example.com/hololib.zip#other")
+	must_be.Equal("https://example.com/hololib.zip#other", link)
+	must_be.Equal("", checksum)
+}
+
+func TestCanResumeDownloads(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	content := bytes.Repeat([]byte("hololib bundle content "), 1000)
+	checksum := fmt.Sprintf("%02x", sha256.Sum256(content))
+	ranges := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		ranges = append(ranges, request.Header.Get("Range")+" "+request.Header.Get("If-Range"))
+		response.Header().Set("ETag", `"v2"`)
+		http.ServeContent(response, request, "hololib.zip", time.Now(), bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	folder, err := ioutil.TempDir("", "resume")
+	must_be.Nil(err)
+	defer os.RemoveAll(folder)
+
+	target := filepath.Join(folder, "hololib.zip")
+	must_be.Nil(ioutil.WriteFile(target+".part", content[:1000], 0o644))
+	must_be.Nil(ioutil.WriteFile(target+".part.validator", []byte(`"v2"`), 0o644))
+	must_be.Nil(cloud.ResumableDownload(server.URL, target, checksum))
+	must_be.Equal([]string{`bytes=1000- "v2"`}, ranges)
+	downloaded, err := ioutil.ReadFile(target)
+	must_be.Nil(err)
+	must_be.Equal(content, downloaded)
+	_, err = os.Stat(target + ".part.validator")
+	wont_be.Nil(err)
+
+	ranges = []string{}
+	changed := filepath.Join(folder, "changed.zip")
+	must_be.Nil(ioutil.WriteFile(changed+".part", []byte("old bundle"), 0o644))
+	must_be.Nil(ioutil.WriteFile(changed+".part.validator", []byte(`"v1"`), 0o644))
+	must_be.Nil(cloud.ResumableDownload(server.URL, changed, checksum))
+	must_be.Equal([]string{`bytes=10- "v1"`}, ranges)
+	downloaded, err = ioutil.ReadFile(changed)
+	must_be.Nil(err)
+	must_be.Equal(content, downloaded)
+
+	ranges = []string{}
+	unknown := filepath.Join(folder, "unknown.zip")
+	must_be.Nil(ioutil.WriteFile(unknown+".part", []byte("old bundle"), 0o644))
+	must_be.Nil(cloud.ResumableDownload(server.URL, unknown, checksum))
+	must_be.Equal([]string{" "}, ranges)
+
+	other := filepath.Join(folder, "other.zip")
+	wont_be.Nil(cloud.ResumableDownload(server.URL, other, "bad"))
+	_, err = os.Stat(other)
+	wont_be.Nil(err)
+	_, err = os.Stat(other + ".part")
+	wont_be.Nil(err)
+
+	must_be.Nil(cloud.ResumableDownload(server.URL, other, ""))
+	wont_be.Equal(cloud.DownloadLocation(server.URL, ".zip"), cloud.DownloadLocation(server.URL+"/x", ".zip"))
+}
@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/journal"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/xviper"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	redacted = `[redacted]`
+)
+
+var (
+	auditStarted  = time.Now()
+	auditEnable   bool
+	auditDisable  bool
+	auditPath     string
+	auditLast     int
+	sensitiveArgs = []string{"secret", "token", "password", "credential", "key"}
+)
+
+func sensitiveFlag(arg string) bool {
+	if !strings.HasPrefix(arg, "-") {
+		return false
+	}
+	name := strings.ToLower(strings.SplitN(arg, "=", 2)[0])
+	for _, marker := range sensitiveArgs {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditArguments redacts values of secret looking options, and all
+// positional arguments of credentials command, from audited arguments.
+func auditArguments(target *cobra.Command, args []string) []string {
+	words := make(map[string]bool)
+	for at := target; at != nil; at = at.Parent() {
+		words[at.Name()] = true
+		for _, alias := range at.Aliases {
+			words[alias] = true
+		}
+	}
+	positional := target == credentialsCmd
+	result := make([]string, 0, len(args))
+	hidden := false
+	for _, arg := range args {
+		switch {
+		case hidden:
+			result, hidden = append(result, redacted), false
+		case sensitiveFlag(arg) && strings.Contains(arg, "="):
+			result = append(result, strings.SplitN(arg, "=", 2)[0]+"="+redacted)
+		case sensitiveFlag(arg):
+			result, hidden = append(result, arg), true
+		case positional && !strings.HasPrefix(arg, "-") && !words[arg]:
+			result = append(result, redacted)
+		default:
+			result = append(result, arg)
+		}
+	}
+	return result
+}
+
+func auditOutcome(exit int, panicked bool) (string, string) {
+	if panicked {
+		return journal.AuditPanic, ""
+	}
+	if exit == 0 {
+		return journal.AuditOk, ""
+	}
+	for _, code := range common.ErrorCodes() {
+		if code.Exit != exit {
+			continue
+		}
+		switch code {
+		case common.CodeCancelled, common.CodeInterrupted, common.CodeTimedOut:
+			return journal.AuditCancelled, code.Code
+		}
+		return journal.AuditFailed, code.Code
+	}
+	return journal.AuditFailed, ""
+}
+
+// Audit records this rcc invocation into audit log, when audit log is
+// enabled. It is called once, when rcc is about to exit.
+func Audit(exit int, panicked bool) {
+	if len(xviper.ConfigFileUsed()) == 0 {
+		useConfigFile()
+	}
+	if !xviper.AuditLogEnabled() {
+		return
+	}
+	target, _, err := rootCmd.Find(os.Args[1:])
+	if err != nil || target == nil {
+		target = rootCmd
+	}
+	invocation := &journal.Invocation{
+		Started:    auditStarted.Format(time.RFC3339),
+		Seconds:    time.Since(auditStarted).Seconds(),
+		Pid:        os.Getpid(),
+		Controller: common.ControllerIdentity(),
+		Version:    common.Version,
+		Command:    target.CommandPath(),
+		Args:       auditArguments(target, os.Args[1:]),
+		Space:      common.HolotreeSpace,
+		Exit:       exit,
+	}
+	invocation.Outcome, invocation.Code = auditOutcome(exit, panicked)
+	if who, err := user.Current(); err == nil {
+		invocation.User = who.Username
+	}
+	invocation.Host, _ = os.Hostname()
+	invocation.Workdir, _ = os.Getwd()
+	err = journal.Audit(xviper.AuditLogPath(), invocation)
+	if err != nil {
+		common.Log("Warning: could not write audit log, reason: %v", err)
+	}
+}
+
+func auditTable(trail []*journal.Invocation) {
+	tabbed := tabwriter.NewWriter(os.Stderr, 2, 4, 2, ' ', 0)
+	tabbed.Write([]byte("Started\tUser\tCommand\tSpace\tTime\tOutcome\n"))
+	tabbed.Write([]byte("-------\t----\t-------\t-----\t----\t-------\n"))
+	for _, entry := range trail {
+		outcome := entry.Outcome
+		if entry.Exit != 0 {
+			outcome = fmt.Sprintf("%s [%d]", outcome, entry.Exit)
+		}
+		tabbed.Write([]byte(fmt.Sprintf("%s\t%s\t%s\t%s\t%.1fs\t%s\n", entry.Started, entry.User, entry.Command, entry.Space, entry.Seconds, outcome)))
+	}
+	tabbed.Flush()
+}
+
+var auditlogCmd = &cobra.Command{
+	Use:   "auditlog",
+	Short: "Manage local audit log of rcc invocations.",
+	Long: `Manage local audit log of rcc invocations.
+
+When enabled, every rcc invocation appends one JSON line into audit log,
+with start time, duration, user, host, controller, command and its arguments
+(secrets redacted), working directory, holotree space, exit code and
+outcome. Default location is ROBOCORP_HOME/audit.log, and it can be changed
+with --path. Entries are only ever appended, never rewritten.
+
+Without options, shows audit log state; with --last, also shows latest
+entries.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		pretty.Guard(!(auditEnable && auditDisable), 1, "Options --enable and --disable cannot be used together.")
+		if len(auditPath) > 0 {
+			fullpath, err := filepath.Abs(auditPath)
+			pretty.Guard(err == nil, 1, "Invalid audit log path %q, reason: %v", auditPath, err)
+			xviper.SetAuditLogPath(fullpath)
+		}
+		if auditEnable {
+			xviper.EnableAuditLog(true)
+		}
+		if auditDisable {
+			xviper.EnableAuditLog(false)
+		}
+		trail := []*journal.Invocation{}
+		if auditLast > 0 && pathlib.Exists(xviper.AuditLogPath()) {
+			found, err := journal.AuditTrail(xviper.AuditLogPath())
+			pretty.Guard(err == nil, 2, "Could not read audit log, reason: %v", err)
+			trail = found
+			if len(trail) > auditLast {
+				trail = trail[len(trail)-auditLast:]
+			}
+		}
+		if jsonFlag {
+			printJson(3, map[string]interface{}{
+				"enabled": xviper.AuditLogEnabled(),
+				"path":    xviper.AuditLogPath(),
+				"entries": trail,
+			})
+			return
+		}
+		state := "disabled"
+		if xviper.AuditLogEnabled() {
+			state = "enabled"
+		}
+		common.Log("Audit log %q is %s.", xviper.AuditLogPath(), state)
+		if len(trail) > 0 {
+			auditTable(trail)
+		}
+	},
+}
+
+func init() {
+	configureCmd.AddCommand(auditlogCmd)
+	auditlogCmd.Flags().BoolVarP(&auditEnable, "enable", "e", false, "Start recording rcc invocations into audit log.")
+	auditlogCmd.Flags().BoolVarP(&auditDisable, "disable", "", false, "Stop recording rcc invocations into audit log.")
+	auditlogCmd.Flags().StringVarP(&auditPath, "path", "p", "", "Location of audit log file (default is ROBOCORP_HOME/audit.log).")
+	auditlogCmd.Flags().IntVarP(&auditLast, "last", "l", 0, "Also show this many latest audit log entries.")
+	auditlogCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output audit log state (and entries) in JSON format.")
+}
@@ -0,0 +1,17 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestAuditArgumentsRedactSecrets(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	must.Equal([]string{"holotree", "serve", "--key", redacted, "--listen", ":4653"}, auditArguments(holotreeServeCmd, []string{"holotree", "serve", "--key", "server.pem", "--listen", ":4653"}))
+	must.Equal([]string{"holotree", "sign", "--keygen=" + redacted}, auditArguments(holotreeSignCmd, []string{"holotree", "sign", "--keygen=signing.pem"}))
+	must.Equal([]string{"cloud", "push", "--wskey", redacted, "--token=" + redacted, "-r", "robot"}, auditArguments(rootCmd, []string{"cloud", "push", "--wskey", "secret", "--token=abc", "-r", "robot"}))
+	must.Equal([]string{"configure", "credentials", redacted, "--account", redacted}, auditArguments(credentialsCmd, []string{"configure", "credentials", "abcd:efgh", "--account", "work"}))
+	must.Equal([]string{"run", "--task", "Main"}, auditArguments(runCmd, []string{"run", "--task", "Main"}))
+}
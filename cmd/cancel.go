@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/shell"
+)
+
+// forcedExit ends rcc right away, without waiting running operation to
+// clean up after itself.
+func forcedExit(code *common.ErrorCode, form string, details ...interface{}) {
+	common.Log("%s[%s] %s%s", pretty.Red, code.Code, fmt.Sprintf(form, details...), pretty.Reset)
+	Audit(code.Exit, false)
+	common.WaitLogs()
+	os.Exit(code.Exit)
+}
+
+func cancelCommand(code *common.ErrorCode, reason string) {
+	if !common.Cancel(code, reason) {
+		forcedExit(code, "Cancelled again, exiting without cleanup.")
+	}
+	pretty.Warning("%s, stopping and cleaning up (interrupt again to exit right away).", reason)
+	time.AfterFunc(common.ExitGrace, func() {
+		forcedExit(code, "Cleanup did not finish within %s, exiting anyway.", common.ExitGrace)
+	})
+}
+
+// watchSignals turns SIGINT and SIGTERM into cancellation of running
+// command. Ctrl-C is left for interactive child process to handle.
+func watchSignals() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for received := range signals {
+			if received == os.Interrupt && shell.Foreground() {
+				common.Debug("Interrupt left for interactive child process.")
+				continue
+			}
+			cancelCommand(common.CodeInterrupted, fmt.Sprintf("Interrupted by signal (%v)", received))
+		}
+	}()
+}
+
+func startTimeout() {
+	if common.CommandTimeout <= 0 {
+		return
+	}
+	timeout := common.CommandTimeout
+	time.AfterFunc(timeout, func() {
+		cancelCommand(common.CodeTimedOut, fmt.Sprintf("Command did not finish within %s", timeout))
+	})
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"github.com/robocorp/rcc/common"
 	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/htfs"
 	"github.com/robocorp/rcc/pretty"
 
 	"github.com/spf13/cobra"
@@ -13,30 +14,58 @@ var (
 	quickFlag      bool
 	micromambaFlag bool
 	daysOption     int
+	orphansOption  int
 )
 
 var cleanupCmd = &cobra.Command{
 	Use:   "cleanup",
 	Short: "Cleanup old managed virtual environments.",
 	Long: `Cleanup removes old virtual environments from existence.
-After cleanup, they will not be available anymore.`,
+After cleanup, they will not be available anymore.
+
+Holotree spaces not used for --days, and with --orphans, spaces of controllers
+not seen for that many days, are removed. Spaces pinned with "rcc holotree pin"
+are always kept.
+
+With --json, removed paths and spaces (or with --dryrun, ones that would be
+removed) are written as JSON into stdout.
+
+In interactive terminal, what would be removed is shown first, and cleanup
+continues only after confirmation (or with --yes).`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if common.DebugFlag {
 			defer common.Stopwatch("Env cleanup lasted").Report()
 		}
-		err := conda.Cleanup(daysOption, dryFlag, quickFlag, allFlag, micromambaFlag)
+		if previewing() {
+			preview, err := conda.Cleanup(daysOption, orphansOption, true, quickFlag, allFlag, micromambaFlag, htfs.SpaceCleaner)
+			pretty.Guard(err == nil, 1, "Error: %v", err)
+			if len(preview.Removed) == 0 && len(preview.Spaces) == 0 {
+				common.Log("Nothing to clean up.")
+				pretty.Ok()
+				return
+			}
+			confirmDestructive("Remove %d path(s) and %d space(s) listed above?", len(preview.Removed), len(preview.Spaces))
+		}
+		report, err := conda.Cleanup(daysOption, orphansOption, dryFlag, quickFlag, allFlag, micromambaFlag, htfs.SpaceCleaner)
 		if err != nil {
 			pretty.Exit(1, "Error: %v", err)
 		}
+		if jsonFlag {
+			printJson(2, report)
+			return
+		}
+		dryrunDone()
 		pretty.Ok()
 	},
 }
 
 func init() {
 	configureCmd.AddCommand(cleanupCmd)
+	cleanupCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output removed paths and spaces in JSON format.")
 	cleanupCmd.Flags().BoolVarP(&dryFlag, "dryrun", "d", false, "Don't delete environments, just show what would happen.")
 	cleanupCmd.Flags().BoolVarP(&micromambaFlag, "micromamba", "", false, "Remove micromamba installation.")
 	cleanupCmd.Flags().BoolVarP(&allFlag, "all", "", false, "Cleanup all enviroments.")
 	cleanupCmd.Flags().BoolVarP(&quickFlag, "quick", "q", false, "Cleanup most of enviroments, but leave hololib and pkgs cache intact.")
-	cleanupCmd.Flags().IntVarP(&daysOption, "days", "", 30, "What is the limit in days to keep environments for (deletes environments older than this).")
+	cleanupCmd.Flags().IntVarP(&daysOption, "days", "", 30, "What is the limit in days to keep environments for (deletes environments older than this, except pinned ones).")
+	cleanupCmd.Flags().IntVarP(&orphansOption, "orphans", "", 0, "Also delete environments of controllers not seen for this many days (0 disables, pinned ones are kept).")
 }
@@ -53,8 +53,8 @@ var prepareCloudCmd = &cobra.Command{
 
 		var label string
 		condafile := config.CondaConfigFile()
-		label, _, err = htfs.NewEnvironment(condafile, config.Holozip(), true, false)
-		pretty.Guard(err == nil, 8, "Error: %v", err)
+		label, _, err = htfs.NewEnvironment(condafile, config.Holozip(), true, false, robot.SettingsOf(config))
+		pretty.Guard(err == nil, common.ExitCodeOf(err, 8), "Error: %v", err)
 
 		common.Log("Prepared %q.", label)
 		pretty.Ok()
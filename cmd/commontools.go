@@ -1,7 +1,13 @@
 package cmd
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/wizard"
 )
 
 const (
@@ -18,3 +24,59 @@ func AccountName() string {
 	}
 	return os.Getenv(environmentAccount)
 }
+
+// printJson writes item as indented JSON into stdout, or exits with code,
+// if that is not possible.
+func printJson(code int, item interface{}) {
+	body, err := json.MarshalIndent(item, "", "  ")
+	pretty.Guard(err == nil, code, "Could not create json, reason: %v", err)
+	fmt.Println(string(body))
+}
+
+// confirmed asks confirmation for destructive operation from user in
+// interactive terminal. With --yes, or without terminal (in scripts),
+// operation is confirmed without asking.
+func confirmed(form string, details ...interface{}) bool {
+	if !confirming() {
+		return true
+	}
+	yes, err := wizard.Confirm(fmt.Sprintf(form, details...), false)
+	return err == nil && yes
+}
+
+// confirming tells if confirmed would really ask user. Terminal detection
+// decides this, so there is no separate --interactive flag.
+func confirming() bool {
+	return !common.AssumeYes && pretty.Interactive
+}
+
+// confirmDestructive asks user to confirm destructive operation, unless it
+// is dry run. Declined operation ends command with cancel error code.
+func confirmDestructive(form string, details ...interface{}) {
+	if dryFlag {
+		return
+	}
+	pretty.Guard(confirmed(form, details...), common.CodeCancelled.Exit, "[%s] Cancelled, nothing was changed.", common.CodeCancelled.Code)
+}
+
+// previewing tells if destructive command should first do dry run, to show
+// what user is asked to confirm.
+func previewing() bool {
+	return !dryFlag && confirming()
+}
+
+// dryrunVerb picks verb for reporting changes, so that dry runs tell what
+// would happen instead.
+func dryrunVerb(done, would string) string {
+	if dryFlag {
+		return would
+	}
+	return done
+}
+
+// dryrunDone reminds that dry run did not change anything.
+func dryrunDone() {
+	if dryFlag {
+		common.Log("%sDry run, nothing was changed.%s", pretty.Yellow, pretty.Reset)
+	}
+}
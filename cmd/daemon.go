@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/operations"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/remote"
+	"github.com/robocorp/rcc/service"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	daemonListen      string
+	daemonInsecure    bool
+	daemonMaintenance bool
+	daemonInterval    int
+	daemonVerify      bool
+	daemonLogfile     string
+	daemonServiceName string
+)
+
+// daemonServes tells if daemon (with given flags) serves remote execution.
+func daemonServes(cmd *cobra.Command) bool {
+	return !daemonMaintenance || cmd.Flags().Changed("listen")
+}
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run rcc as long running daemon, serving remote execution requests.",
+	Long: `Run rcc as long running daemon, serving remote execution requests.
+
+Clients can then use "rcc run --remote host:port" to ship their robots here,
+and have environments built and tasks run on this machine. Set same
+RCC_REMOTE_TOKEN environment variable on both sides for authorization.
+Without it, daemon refuses to serve, unless --insecure is given. Jobs never
+see daemon's own RCC_REMOTE_TOKEN or RCC_HOLOTREE_TOKEN.
+Robot files of a job are removed when it finishes, and its artifacts once
+client fetches them (or after one hour, if nobody does).
+
+With --maintenance, daemon periodically cleans up old environments and
+temporary files (and verifies hololib with --verify), writing reports into
+journal. Then remote execution is only served, if --listen is also given.
+
+Use "install-service" subcommand to register daemon (with same flags) as
+Windows service or systemd unit, so that it starts automatically and is
+restarted on failures.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Daemon lasted").Report()
+		}
+		common.StopHeartbeat()
+		if daemonMaintenance {
+			pretty.Guard(daemonInterval > 0, 1, "Maintenance interval must be positive, not %d.", daemonInterval)
+		}
+		serving := daemonServes(cmd)
+		err := service.Run(daemonServiceName, daemonLogfile, func() error {
+			if daemonMaintenance {
+				interval := time.Duration(daemonInterval) * time.Minute
+				if !serving {
+					operations.MaintenanceLoop(interval, daysOption, daemonVerify)
+					return nil
+				}
+				go operations.MaintenanceLoop(interval, daysOption, daemonVerify)
+			}
+			return remote.Serve(daemonListen, daemonInsecure)
+		})
+		pretty.Guard(err == nil, 1, "Error: %v", err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+
+	daemonCmd.PersistentFlags().StringVarP(&daemonServiceName, "name", "", service.DefaultName, "Name of the daemon, when it is (or is registered as) operating system service.")
+	daemonCmd.PersistentFlags().StringVarP(&daemonLogfile, "logfile", "", "", "Route all daemon output into this (appended) log file.")
+	daemonCmd.PersistentFlags().StringVarP(&daemonListen, "listen", "l", "127.0.0.1:4653", "Address (host:port) where daemon listens for remote execution requests.")
+	daemonCmd.PersistentFlags().BoolVarP(&daemonMaintenance, "maintenance", "", false, "Periodically run cleanup (and verification) of environments and caches.")
+	daemonCmd.PersistentFlags().IntVarP(&daemonInterval, "interval", "", 360, "Minutes between maintenance cycles.")
+	daemonCmd.PersistentFlags().IntVarP(&daysOption, "days", "", 30, "Retention limit in days for environments and temporary files in maintenance.")
+	daemonCmd.PersistentFlags().BoolVarP(&daemonVerify, "verify", "", false, "Also verify hololib integrity in maintenance cycles.")
+	daemonCmd.Flags().BoolVarP(&daemonInsecure, "insecure", "", false, "Serve remote execution without RCC_REMOTE_TOKEN, to anybody who can connect.")
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/remote"
+	"github.com/robocorp/rcc/service"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	serviceDaemonFlags = []string{"listen", "maintenance", "interval", "days", "verify", "name"}
+	unitOnlyFlag       bool
+	serviceUser        string
+	serviceGroup       string
+)
+
+func daemonServiceArguments(cmd *cobra.Command) []string {
+	arguments := []string{"daemon"}
+	for _, name := range serviceDaemonFlags {
+		flag := cmd.Flags().Lookup(name)
+		if flag != nil && flag.Changed {
+			arguments = append(arguments, fmt.Sprintf("--%s=%s", name, flag.Value.String()))
+		}
+	}
+	return append(arguments, "--controller", common.ControllerType)
+}
+
+var daemonInstallServiceCmd = &cobra.Command{
+	Use:   "install-service",
+	Short: "Register daemon as Windows service or systemd unit (Linux).",
+	Long: `Register daemon (with same flags as given here) as Windows service, or
+generate systemd unit for it on Linux. Service starts automatically, and is
+restarted on failures. Current ROBOCORP_HOME is passed into service
+environment. Remote/holotree tokens are not put into service definition, but
+into separate secrets file that only administrators can read (on Linux
+/etc/rcc/<name>.env, referred as EnvironmentFile from unit, and on Windows
+under ProgramData, read by service when it starts).
+
+Daemon serving remote execution (not just --maintenance) is only installed
+when RCC_REMOTE_TOKEN is set, since service cannot be --insecure.
+
+Use --user (and on Linux --group) to run service as dedicated non-privileged
+account instead of root or LocalSystem. That account must be able to write
+ROBOCORP_HOME. On Windows, account can be virtual (like "NT SERVICE\rcc-daemon")
+or managed one, or normal account with password given in RCC_SERVICE_PASSWORD.
+
+With --print, nothing is installed, but generated systemd unit (or on
+Windows, equivalent sc.exe and reg.exe commands) is printed to stdout.
+
+On Windows, output goes into --logfile (default is under ROBOCORP_HOME/logs),
+and on Linux into journal, unless --logfile is given.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if daemonServes(cmd) {
+			pretty.Guard(len(remote.Token()) > 0, 1, "Refusing to install service serving remote execution without %s.", remote.REMOTE_TOKEN_VARIABLE)
+		}
+		config, err := service.NewConfig(daemonServiceName, daemonLogfile, daemonServiceArguments(cmd))
+		pretty.Guard(err == nil, 1, "Error: %v", err)
+		config.User = serviceUser
+		config.Group = serviceGroup
+		if unitOnlyFlag {
+			common.Stdout("%s", service.Definition(config))
+			if config.HasSecrets() {
+				common.Log("Note: tokens are not part of service definition, put them into %q (readable only by administrators).", config.SecretsFile)
+			}
+			return
+		}
+		message, err := service.Install(config)
+		pretty.Guard(err == nil, 2, "Error: %v", err)
+		common.Log("%s", message)
+		pretty.Ok()
+	},
+}
+
+var daemonRemoveServiceCmd = &cobra.Command{
+	Use:   "remove-service",
+	Short: "Unregister daemon Windows service or systemd unit (Linux).",
+	Long:  "Unregister daemon Windows service or systemd unit (Linux).",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		message, err := service.Remove(daemonServiceName)
+		pretty.Guard(err == nil, 2, "Error: %v", err)
+		common.Log("%s", message)
+		pretty.Ok()
+	},
+}
+
+func init() {
+	daemonCmd.AddCommand(daemonInstallServiceCmd)
+	daemonCmd.AddCommand(daemonRemoveServiceCmd)
+
+	daemonInstallServiceCmd.Flags().BoolVarP(&unitOnlyFlag, "print", "", false, "Just print generated service definition to stdout (systemd unit, or sc.exe commands on Windows).")
+	daemonInstallServiceCmd.Flags().StringVarP(&serviceUser, "user", "", "", "Run service as this (non-privileged) account.")
+	daemonInstallServiceCmd.Flags().StringVarP(&serviceGroup, "group", "", "", "Run service with this group (Linux only).")
+}
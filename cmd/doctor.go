@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/operations"
+	"github.com/robocorp/rcc/pretty"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	doctorDeep bool
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check health of rcc setup, and suggest fixes for problems found.",
+	Long: `Check health of rcc setup, and suggest fixes for problems found.
+
+Checks free disk space of ROBOCORP_HOME and temp directory, long path
+support, reachability of configured endpoints (through proxy and TLS, like
+rcc itself connects), micromamba health, hololib catalogs (and with --deep,
+integrity of all blobs), and whether rcc and holotree locks are held by other
+processes. Every problem comes with concrete fix action. With --json, report
+is machine readable, for attaching into support tickets. Exit code is
+non-zero, when there are failures.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Doctor run lasted").Report()
+		}
+		report := operations.RunDoctor(doctorDeep)
+		if jsonFlag {
+			printJson(2, report)
+		} else {
+			operations.HumaneDoctor(os.Stdout, report)
+		}
+		fatal, fail, _, _ := report.Counts()
+		pretty.Guard(fatal+fail == 0, 1, "Doctor found %d problem(s), see fixes above.", fatal+fail)
+		if !jsonFlag {
+			pretty.Ok()
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output in JSON format")
+	doctorCmd.Flags().BoolVarP(&doctorDeep, "deep", "", false, "Also verify all hololib blobs (takes time).")
+}
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Group of commands related to environments.",
+	Long:  "Group of commands related to environments.",
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/spf13/cobra"
+)
+
+func isEnvironmentConfig(source string) bool {
+	if !pathlib.IsFile(source) {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(source)) {
+	case ".yaml", ".yml", ".lock", ".txt":
+		return true
+	default:
+		return false
+	}
+}
+
+func environmentDependencies(source string) []byte {
+	var library htfs.Library
+	var catalog string
+	var err error
+	if isEnvironmentConfig(source) {
+		library, catalog, err = htfs.EnvironmentCatalog(source)
+		pretty.Guard(err == nil, 2, "Could not build environment from %q, reason: %v", source, err)
+	} else {
+		catalog = catalogLocation(source)
+		library, err = htfs.New()
+		pretty.Guard(err == nil, 2, "Could not get holotree library, reason: %v", err)
+	}
+	content, err := htfs.CatalogFile(library, catalog, conda.GoldenMasterFilename(""))
+	pretty.Guard(err == nil, 3, "Could not get dependencies of %q, reason: %v", source, err)
+	return content
+}
+
+func humaneChannel(channel string) string {
+	if len(channel) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" [%s]", channel)
+}
+
+var envDiffCmd = &cobra.Command{
+	Use:   "diff <old> <new>",
+	Short: "Show package level differences between two environments.",
+	Long: `Show package level differences between two environments.
+
+Both sides can be environment configuration (conda.yaml or lockfile), which
+is then built into hololib (if it is not there already), or a catalog given
+as substring of its name, or as path to catalog file. Result lists packages
+added, removed, and changed (version or channel) from old to new.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Environment diff lasted").Report()
+		}
+		left, err := conda.DependenciesFrom(environmentDependencies(args[0]))
+		pretty.Guard(err == nil, 4, "Could not parse dependencies of %q, reason: %v", args[0], err)
+		right, err := conda.DependenciesFrom(environmentDependencies(args[1]))
+		pretty.Guard(err == nil, 4, "Could not parse dependencies of %q, reason: %v", args[1], err)
+		diff := conda.DiffDependencies(left, right)
+		if jsonFlag {
+			body, err := json.MarshalIndent(diff, "", "  ")
+			pretty.Guard(err == nil, 5, "Could not create json, reason: %v", err)
+			fmt.Println(string(body))
+			return
+		}
+		for _, change := range diff.Added {
+			common.Stdout("added    %-6s %s %s%s\n", change.Kind, change.Name, change.To, humaneChannel(change.ToChannel))
+		}
+		for _, change := range diff.Removed {
+			common.Stdout("removed  %-6s %s %s%s\n", change.Kind, change.Name, change.From, humaneChannel(change.FromChannel))
+		}
+		for _, change := range diff.Changed {
+			common.Stdout("changed  %-6s %s %s%s -> %s%s\n", change.Kind, change.Name, change.From, humaneChannel(change.FromChannel), change.To, humaneChannel(change.ToChannel))
+		}
+		common.Log("%d added, %d removed, and %d changed package(s) from %q to %q.", len(diff.Added), len(diff.Removed), len(diff.Changed), args[0], args[1])
+		pretty.Ok()
+	},
+}
+
+func init() {
+	envCmd.AddCommand(envDiffCmd)
+	envDiffCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output in JSON format")
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/spf13/cobra"
+)
+
+var (
+	freezeOutput string
+	freezeNoPip  bool
+)
+
+func freezeTarget(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	name := htfs.ControllerSpaceName([]byte(common.ControllerIdentity()), []byte(htfs.ActiveSpace(common.HolotreeSpace)))
+	return filepath.Join(common.HolotreeLocation(), name)
+}
+
+var envFreezeCmd = &cobra.Command{
+	Use:   "freeze [environment directory]",
+	Short: "Write fully pinned lockfile of an existing environment.",
+	Long: `Write fully pinned lockfile (conda-lock format) of an existing environment.
+Conda packages and pip packages are listed with their urls and hashes, and
+result can be used with "--lockfile" option to recreate same environment.
+Without directory argument, space given with --space is frozen.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Environment freeze lasted").Report()
+		}
+		target := freezeTarget(args)
+		pretty.Guard(pathlib.IsDir(target), 1, "Environment %q does not exist.", target)
+		content, err := conda.FreezeEnvironment(target, !freezeNoPip)
+		pretty.Guard(err == nil, 2, "%s", err)
+		if len(freezeOutput) == 0 {
+			os.Stdout.Write(content)
+			return
+		}
+		err = ioutil.WriteFile(freezeOutput, content, 0o644)
+		pretty.Guard(err == nil, 3, "Could not write %q, reason: %v", freezeOutput, err)
+		common.Log("Lockfile of %q written to %q.", target, freezeOutput)
+		pretty.Ok()
+	},
+}
+
+func init() {
+	envCmd.AddCommand(envFreezeCmd)
+	envFreezeCmd.Flags().StringVarP(&common.HolotreeSpace, "space", "s", "user", "Client specific name of space to freeze.")
+	envFreezeCmd.Flags().StringVarP(&freezeOutput, "output", "o", "", "Write lockfile to this file instead of stdout. <optional>")
+	envFreezeCmd.Flags().BoolVarP(&freezeNoPip, "no-pip", "", false, "Only freeze conda packages, and leave pip packages out.")
+}
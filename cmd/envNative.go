@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/pretty"
+
+	"github.com/spf13/cobra"
+)
+
+var envNativeCmd = &cobra.Command{
+	Use:   "native <environment folder>",
+	Short: "Check native libraries of environment for compatibility issues.",
+	Long: `Check native libraries of environment for compatibility issues.
+
+Native libraries in site-packages are checked for glibc requirements newer
+than system has and for missing shared objects (on Linux), and for quarantine
+attributes (on macOS). Exit code is non-zero, when there are issues. Same
+probe can be run after every environment build with "native-probe" setting.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Native probe lasted").Report()
+		}
+		pretty.Guard(pathlib.IsDir(args[0]), 1, "Environment folder %q does not exist.", args[0])
+		report := conda.ProbeNative(args[0])
+		if jsonFlag {
+			body, err := json.MarshalIndent(report, "", "  ")
+			pretty.Guard(err == nil, 2, "Could not create json, reason: %v", err)
+			fmt.Println(string(body))
+		} else {
+			for _, issue := range report.Issues {
+				common.Stdout("%-15s %s  %s\n", issue.Kind, issue.File, issue.Detail)
+			}
+			for _, note := range report.Notes {
+				common.Log("Note: %s", note)
+			}
+			common.Log("Scanned %d native libraries on %s, found %d issue(s).", report.Scanned, report.Platform, len(report.Issues))
+		}
+		pretty.Guard(len(report.Issues) == 0, 3, "Environment %q has native library issues.", args[0])
+		if !jsonFlag {
+			pretty.Ok()
+		}
+	},
+}
+
+func init() {
+	envCmd.AddCommand(envNativeCmd)
+	envNativeCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output in JSON format")
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/pretty"
+
+	"github.com/spf13/cobra"
+)
+
+var envPlanCmd = &cobra.Command{
+	Use:   "plan <conda.yaml+>",
+	Short: "Show resolved packages of environment, and check them against package policy.",
+	Long: `Show resolved packages of environment, and check them against package policy.
+
+Conda packages are resolved (using solver dry run), but nothing is installed.
+Pip requirements are shown as given, and only pinned ones are checked against
+version ranges of package policy. Exit code is non-zero, when there are
+policy violations.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Environment plan lasted").Report()
+		}
+		plan, err := conda.PlanEnvironment(forceFlag, args...)
+		pretty.Guard(err == nil, 1, "Planning environment failed, reason: %v", err)
+		if jsonFlag {
+			body, err := json.MarshalIndent(plan, "", "  ")
+			pretty.Guard(err == nil, 2, "Could not create json, reason: %v", err)
+			fmt.Println(string(body))
+		} else {
+			for _, dependency := range plan.Conda {
+				common.Stdout("conda  %s %s%s\n", dependency.Name, dependency.Version, humaneChannel(dependency.Origin))
+			}
+			for _, requirement := range plan.Pip {
+				common.Stdout("pypi   %s\n", requirement)
+			}
+			for _, violation := range plan.Violations {
+				common.Log("%sPackage policy violation: %s%s", pretty.Red, violation, pretty.Reset)
+			}
+			common.Log("Blueprint %s has %d conda package(s) and %d pip requirement(s), with %d policy violation(s).", plan.Blueprint, len(plan.Conda), len(plan.Pip), len(plan.Violations))
+		}
+		pretty.Guard(len(plan.Violations) == 0, 3, "Environment is not allowed by package policy %q.", plan.Policy)
+		if !jsonFlag {
+			pretty.Ok()
+		}
+	},
+}
+
+func init() {
+	envCmd.AddCommand(envPlanCmd)
+	envPlanCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output in JSON format")
+	envPlanCmd.Flags().BoolVarP(&forceFlag, "force", "f", false, "Force fresh repodata, instead of using cached one.")
+}
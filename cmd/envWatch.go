@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"strings"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/robot"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchInterval int
+)
+
+// watchedFiles are given conda.yaml files, robot.yaml, and environment
+// configuration robot.yaml refers to. They are collected again after every
+// build, since robot.yaml may change its environment configuration.
+func watchedFiles(userFiles []string, packfile string) []string {
+	result := append([]string{}, userFiles...)
+	if !pathlib.IsFile(packfile) {
+		return result
+	}
+	result = append(result, packfile)
+	config, err := robot.LoadRobotYaml(packfile, false)
+	if err == nil && pathlib.IsFile(config.CondaConfigFile()) {
+		result = append(result, config.CondaConfigFile())
+	}
+	return result
+}
+
+func watchBuild(userFiles []string, packfile string) {
+	stopwatch := common.Stopwatch("Environment build took")
+	env, code, err := holotreeEnvironment(userFiles, packfile, environmentFile, "", 0, false)
+	if err != nil {
+		pretty.Warning("Environment build failed [%d], reason: %v", code, err)
+		common.Log("Fix configuration, and environment is built again when it changes.")
+		return
+	}
+	switch {
+	case jsonFlag:
+		asJson(env)
+	case len(variablesExport) > 0:
+		asExportFormat(variablesExport, env)
+	default:
+		asExportedText(env)
+	}
+	common.Log("%sEnvironment is ready (%s). Watching for changes ...%s", pretty.Green, stopwatch, pretty.Reset)
+}
+
+var envWatchCmd = &cobra.Command{
+	Use:   "watch [conda.yaml*]",
+	Short: "Rebuild environment every time robot.yaml or conda.yaml changes.",
+	Long: `Rebuild environment every time robot.yaml or conda.yaml changes.
+
+Builds (or restores) environment into holotree space like "rcc holotree
+variables" does, prints its variables, and then watches given conda.yaml
+files, robot.yaml, and environment configuration robot.yaml refers to. When
+any of them changes, environment is built again into same space (reusing
+hololib, so only changed parts take time), and new variables are printed.
+Failed builds do not stop watching. Stop watching with ctrl-c.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		pretty.Guard(watchInterval > 0, 1, "Interval must be at least one second, not %d.", watchInterval)
+		checkExportFlag(jsonFlag)
+		files := watchedFiles(args, robotFile)
+		pretty.Guard(len(files) > 0, 2, "Nothing to watch, give conda.yaml file(s) or --robot file.")
+		watchBuild(args, robotFile)
+		watched := pathlib.Watch(files...)
+		common.Debug("Watching files: %s", strings.Join(files, ", "))
+		interval := time.Duration(watchInterval) * time.Second
+		for {
+			time.Sleep(interval)
+			changed := watched.Changed()
+			if len(changed) == 0 {
+				continue
+			}
+			for settled := false; !settled; {
+				time.Sleep(interval)
+				settled = len(watched.Changed()) == 0
+			}
+			common.Log("%sChanged: %s%s", pretty.Cyan, strings.Join(changed, ", "), pretty.Reset)
+			watchBuild(args, robotFile)
+			watched = pathlib.Watch(watchedFiles(args, robotFile)...)
+		}
+	},
+}
+
+func init() {
+	envCmd.AddCommand(envWatchCmd)
+	envWatchCmd.Flags().StringVarP(&robotFile, "robot", "r", "robot.yaml", "Full path to 'robot.yaml' configuration file. <optional>")
+	envWatchCmd.Flags().StringVarP(&environmentFile, "environment", "e", "", "Full path to 'env.json' development environment data file. <optional>")
+	envWatchCmd.Flags().StringVarP(&common.HolotreeSpace, "space", "s", "user", "Client specific name to identify this environment.")
+	envWatchCmd.Flags().IntVarP(&watchInterval, "interval", "i", 2, "Seconds between checks for changes.")
+	envWatchCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Show environment variables as JSON.")
+	envWatchCmd.Flags().StringVarP(&variablesExport, "export", "", "", "Show environment variables in format: bash, powershell, cmd, dotenv, or github-actions. <optional>")
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pretty"
+
+	"github.com/spf13/cobra"
+)
+
+func exitCodesTable() []byte {
+	buffer := &bytes.Buffer{}
+	tabbed := tabwriter.NewWriter(buffer, 2, 4, 2, ' ', 0)
+	tabbed.Write([]byte("Exit\tCode\tLayer\tMeaning\n"))
+	tabbed.Write([]byte("----\t----\t-----\t-------\n"))
+	for _, code := range common.ErrorCodes() {
+		tabbed.Write([]byte(fmt.Sprintf("%d\t%s\t%s\t%s\n", code.Exit, code.Code, code.Layer, code.Summary)))
+	}
+	tabbed.Flush()
+	fmt.Fprintln(buffer)
+	fmt.Fprintln(buffer, "Exit code 0 means success. Other small exit codes (1-20) are command")
+	fmt.Fprintln(buffer, "specific usage and setup failures, and robot runs exit with exit code")
+	fmt.Fprintln(buffer, "of failed task. Failures with error code show it in their message, like")
+	fmt.Fprintln(buffer, "\"[RCC-ENV-SOLVE-001] Could not create environment.\"")
+	return buffer.Bytes()
+}
+
+var exitcodesCmd = &cobra.Command{
+	Use:     "exitcodes",
+	Aliases: []string{"exitcode", "errorcodes", "errors"},
+	Short:   "Show structured error codes and exit codes of rcc.",
+	Long: `Show structured error codes and exit codes of rcc.
+
+Failures that have structured error code (like RCC-ENV-SOLVE-001) show that
+code in their message, and make rcc exit with distinct exit code listed here.
+Use --json to get listing in machine readable form.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if jsonFlag {
+			printJson(1, common.ErrorCodes())
+			return
+		}
+		pretty.Page(exitCodesTable())
+	},
+}
+
+func init() {
+	manCmd.AddCommand(exitcodesCmd)
+	exitcodesCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Show error codes as JSON.")
+}
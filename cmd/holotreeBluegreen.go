@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/operations"
+	"github.com/robocorp/rcc/pretty"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	bluegreenSwitch bool
+)
+
+var holotreeBluegreenCmd = &cobra.Command{
+	Use:   "bluegreen",
+	Short: "Blue-green switching of holotree spaces for long running agents.",
+	Long: `Blue-green switching of holotree spaces for long running agents.
+
+Space given with --space becomes an alias to one of two slot spaces. Next
+environment version is prepared into inactive slot while agents keep using
+active one, and then alias is switched atomically. Previous slot is kept, so
+that switch can be rolled back.`,
+}
+
+var holotreeBluegreenPrepareCmd = &cobra.Command{
+	Use:   "prepare conda.yaml*",
+	Short: "Build next environment version into inactive slot space.",
+	Long:  "Build next environment version into inactive slot space.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Holotree bluegreen prepare lasted").Report()
+		}
+		space := common.HolotreeSpace
+		alias, _ := htfs.LoadSpaceAlias(space)
+		slot := alias.Inactive()
+		common.Log("Preparing slot %q for space %q (active is %q).", slot, space, alias.Active)
+		common.HolotreeSpace = slot
+		holotreeExpandEnvironment(args, robotFile, "", "", 0, holotreeForce)
+		common.HolotreeSpace = space
+		err := alias.Staging(slot)
+		pretty.Guard(err == nil, 1, "Error: %v", err)
+		if bluegreenSwitch {
+			err = alias.Switch()
+			pretty.Guard(err == nil, 2, "Error: %v", err)
+			common.Log("Space %q now uses slot %q.", space, alias.Active)
+		}
+		pretty.Ok()
+	},
+}
+
+var holotreeBluegreenSwitchCmd = &cobra.Command{
+	Use:   "switch",
+	Short: "Atomically switch space alias to prepared slot.",
+	Long:  "Atomically switch space alias to prepared slot.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		alias, _ := htfs.LoadSpaceAlias(common.HolotreeSpace)
+		err := alias.Switch()
+		pretty.Guard(err == nil, 1, "Error: %v", err)
+		common.Log("Space %q now uses slot %q.", alias.Space, alias.Active)
+		pretty.Ok()
+	},
+}
+
+var holotreeBluegreenRollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Switch space alias back to previously active slot.",
+	Long:  "Switch space alias back to previously active slot.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		alias, _ := htfs.LoadSpaceAlias(common.HolotreeSpace)
+		err := alias.Rollback()
+		pretty.Guard(err == nil, 1, "Error: %v", err)
+		common.Log("Space %q now uses slot %q.", alias.Space, alias.Active)
+		pretty.Ok()
+	},
+}
+
+var holotreeBluegreenStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show active, previous and staged slots of space alias.",
+	Long:  "Show active, previous and staged slots of space alias.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		alias, ok := htfs.LoadSpaceAlias(common.HolotreeSpace)
+		pretty.Guard(ok, 1, "Space %q is not a blue-green alias.", common.HolotreeSpace)
+		content, err := operations.NiceJsonOutput(alias)
+		pretty.Guard(err == nil, 2, "Error: %v", err)
+		common.Stdout("%s\n", content)
+	},
+}
+
+func init() {
+	holotreeCmd.AddCommand(holotreeBluegreenCmd)
+	holotreeBluegreenCmd.AddCommand(holotreeBluegreenPrepareCmd)
+	holotreeBluegreenCmd.AddCommand(holotreeBluegreenSwitchCmd)
+	holotreeBluegreenCmd.AddCommand(holotreeBluegreenRollbackCmd)
+	holotreeBluegreenCmd.AddCommand(holotreeBluegreenStatusCmd)
+
+	holotreeBluegreenCmd.PersistentFlags().StringVarP(&common.HolotreeSpace, "space", "s", "user", "Client specific name of space alias.")
+	holotreeBluegreenPrepareCmd.Flags().StringVarP(&robotFile, "robot", "r", "robot.yaml", "Full path to 'robot.yaml' configuration file. <optional>")
+	holotreeBluegreenPrepareCmd.Flags().BoolVarP(&holotreeForce, "force", "f", false, "Force environment creation with refresh.")
+	holotreeBluegreenPrepareCmd.Flags().BoolVarP(&bluegreenSwitch, "switch", "", false, "Switch alias to prepared slot right after successful build.")
+}
@@ -36,8 +36,8 @@ func updateEnvironments(robots []string) {
 		if !config.UsesConda() {
 			continue
 		}
-		_, _, err = htfs.NewEnvironment(config.CondaConfigFile(), "", false, false)
-		pretty.Guard(err == nil, 2, "Holotree recording error: %v", err)
+		_, _, err = htfs.NewEnvironment(config.CondaConfigFile(), "", false, false, robot.SettingsOf(config))
+		pretty.Guard(err == nil, common.ExitCodeOf(err, 2), "Holotree recording error: %v", err)
 	}
 }
 
@@ -0,0 +1,339 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+const (
+	browserHelp = "tab: switch view  up/down (j/k): move  d: delete  p: pin/unpin  e: export  c: check  r: refresh  q: quit"
+)
+
+type holotreeBrowser struct {
+	reader   *bufio.Reader
+	restore  func()
+	catalogs bool
+	cursor   [2]int
+	spaces   []*htfs.SpaceEntry
+	entries  []*htfs.CatalogEntry
+	status   string
+}
+
+func browserTime(when time.Time) string {
+	if when.IsZero() {
+		return "-"
+	}
+	return when.Format("2006-01-02 15:04")
+}
+
+func browserPin(pinned bool) string {
+	if pinned {
+		return "pinned"
+	}
+	return ""
+}
+
+func browserPinned(names []string, name string) bool {
+	for _, candidate := range names {
+		if candidate == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (it *holotreeBrowser) refresh() {
+	it.spaces = htfs.BrowseSpaces()
+	entries, err := htfs.BrowseCatalogs()
+	if err != nil {
+		it.status = fmt.Sprintf("Could not list catalogs, reason: %v", err)
+		entries = []*htfs.CatalogEntry{}
+	}
+	it.entries = entries
+	it.move(0)
+}
+
+func (it *holotreeBrowser) view() int {
+	if it.catalogs {
+		return 1
+	}
+	return 0
+}
+
+func (it *holotreeBrowser) size() int {
+	if it.catalogs {
+		return len(it.entries)
+	}
+	return len(it.spaces)
+}
+
+func (it *holotreeBrowser) move(delta int) {
+	at := it.cursor[it.view()] + delta
+	if at >= it.size() {
+		at = it.size() - 1
+	}
+	if at < 0 {
+		at = 0
+	}
+	it.cursor[it.view()] = at
+}
+
+func (it *holotreeBrowser) table() []string {
+	buffer := &bytes.Buffer{}
+	tabbed := tabwriter.NewWriter(buffer, 2, 4, 2, ' ', 0)
+	if it.catalogs {
+		tabbed.Write([]byte("  Catalog\tFiles\tSize\tSigned\tLast used\tPin\n"))
+		for _, entry := range it.entries {
+			tabbed.Write([]byte(fmt.Sprintf("  %s\t%d\t%s\t%v\t%s\t%s\n", entry.Name, entry.Files, megabytes(entry.Bytes), entry.Signed, browserTime(entry.Used), browserPin(entry.Pinned))))
+		}
+	} else {
+		tabbed.Write([]byte("  Space\tController\tName\tFiles\tSize\tLast used\tPin\n"))
+		for _, entry := range it.spaces {
+			tabbed.Write([]byte(fmt.Sprintf("  %s\t%s\t%s\t%d\t%s\t%s\t%s\n", entry.Label, entry.Controller, entry.Space, entry.Files, megabytes(entry.Size), browserTime(entry.Used), browserPin(entry.Pinned))))
+		}
+	}
+	tabbed.Flush()
+	return strings.Split(strings.TrimRight(buffer.String(), "\n"), "\n")
+}
+
+func (it *holotreeBrowser) draw() {
+	_, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || height < 10 {
+		height = 24
+	}
+	spaces, catalogs := pretty.Bold+"[Spaces]"+pretty.Reset, " Catalogs "
+	if it.catalogs {
+		spaces, catalogs = " Spaces ", pretty.Bold+"[Catalogs]"+pretty.Reset
+	}
+	lines := []string{fmt.Sprintf("rcc holotree browse  %s %s  (%d spaces, %d catalogs)", spaces, catalogs, len(it.spaces), len(it.entries)), ""}
+	rows := it.table()
+	lines = append(lines, pretty.Grey+rows[0]+pretty.Reset)
+	limit := height - 7
+	cursor := it.cursor[it.view()]
+	first := 0
+	if cursor >= limit {
+		first = cursor - limit + 1
+	}
+	for at, row := range rows[1:] {
+		if at < first || at >= first+limit {
+			continue
+		}
+		if at == cursor {
+			row = pretty.Bold + ">" + row[1:] + pretty.Reset
+		}
+		lines = append(lines, row)
+	}
+	if it.size() == 0 {
+		lines = append(lines, "  (nothing here)")
+	}
+	lines = append(lines, "", it.status, pretty.Grey+browserHelp+pretty.Reset)
+	fmt.Printf("%s%s%s\r\n", pretty.Home, pretty.Clear, strings.Join(lines, "\r\n"))
+}
+
+func (it *holotreeBrowser) confirm(question string) bool {
+	it.status = fmt.Sprintf("%s%s [y/N]%s", pretty.Yellow, question, pretty.Reset)
+	it.draw()
+	key, err := pretty.ReadKey(it.reader)
+	return err == nil && (key == 'y' || key == 'Y')
+}
+
+// suspend runs work with terminal back in normal mode, since actions may log.
+func (it *holotreeBrowser) suspend(work func() string) {
+	it.restore()
+	it.status = work()
+	restore, err := pretty.RawTerminal()
+	if err == nil {
+		it.restore = restore
+	}
+	it.refresh()
+}
+
+func (it *holotreeBrowser) export(catalog string) string {
+	if len(catalog) == 0 {
+		return "Space has no catalog to export."
+	}
+	archive := fmt.Sprintf("%s.zip", catalog)
+	tree, err := htfs.New()
+	if err == nil {
+		err = tree.Export([]string{catalog}, []string{}, archive)
+	}
+	if err != nil {
+		return fmt.Sprintf("Export of %s failed, reason: %v", catalog, err)
+	}
+	return fmt.Sprintf("Exported %s into %s.", catalog, archive)
+}
+
+func (it *holotreeBrowser) pin(name string, pinned bool) string {
+	_, err := htfs.Pin([]string{name}, pinned)
+	if err != nil {
+		return fmt.Sprintf("Pinning %s failed, reason: %v", name, err)
+	}
+	if pinned {
+		return fmt.Sprintf("Unpinned %s.", name)
+	}
+	return fmt.Sprintf("Pinned %s.", name)
+}
+
+func (it *holotreeBrowser) spaceAction(key rune, entry *htfs.SpaceEntry) {
+	switch key {
+	case 'd':
+		if entry.Pinned {
+			it.status = fmt.Sprintf("Space %s is pinned, unpin it first.", entry.Label)
+			return
+		}
+		if !it.confirm(fmt.Sprintf("Delete space %s (%s)?", entry.Label, entry.Space)) {
+			it.status = "Delete cancelled."
+			return
+		}
+		it.suspend(func() string {
+			err := htfs.RemoveHolotreeSpace(entry.Label)
+			if err != nil {
+				return fmt.Sprintf("Delete failed, reason: %v", err)
+			}
+			return fmt.Sprintf("Deleted space %s.", entry.Label)
+		})
+	case 'p':
+		it.suspend(func() string {
+			return it.pin(entry.Label, entry.Pinned)
+		})
+	case 'e':
+		it.suspend(func() string {
+			return it.export(entry.Catalog)
+		})
+	case 'c':
+		it.suspend(func() string {
+			drift, err := htfs.CheckLabelDrift(entry.Label)
+			if err != nil {
+				return fmt.Sprintf("Check failed, reason: %v", err)
+			}
+			if !drift.Dirty() {
+				return fmt.Sprintf("Space %s matches its catalog.", entry.Label)
+			}
+			return fmt.Sprintf("Space %s has drifted: restore would add %d, replace %d and delete %d file(s).", entry.Label, len(drift.Added), len(drift.Replaced), len(drift.Deleted))
+		})
+	}
+}
+
+func (it *holotreeBrowser) catalogAction(key rune, entry *htfs.CatalogEntry) {
+	switch key {
+	case 'd':
+		if entry.Pinned {
+			it.status = fmt.Sprintf("Catalog %s is pinned (directly or through space), unpin it first.", entry.Name)
+			return
+		}
+		if !it.confirm(fmt.Sprintf("Delete catalog %s?", entry.Name)) {
+			it.status = "Delete cancelled."
+			return
+		}
+		it.suspend(func() string {
+			err := htfs.RemoveCatalog(entry.Name)
+			if err != nil {
+				return fmt.Sprintf("Delete failed, reason: %v", err)
+			}
+			return fmt.Sprintf("Deleted catalog %s, run \"rcc holotree gc\" to remove its blobs.", entry.Name)
+		})
+	case 'p':
+		if entry.Pinned && !browserPinned(htfs.LoadPins().Catalogs, entry.Name) {
+			it.status = fmt.Sprintf("Catalog %s is pinned through pinned space, unpin that space instead.", entry.Name)
+			return
+		}
+		it.suspend(func() string {
+			return it.pin(entry.Name, entry.Pinned)
+		})
+	case 'e':
+		it.suspend(func() string {
+			return it.export(entry.Name)
+		})
+	case 'c':
+		it.suspend(func() string {
+			report, err := htfs.InspectIntegrity()
+			if err != nil {
+				return fmt.Sprintf("Check failed, reason: %v", err)
+			}
+			return fmt.Sprintf("Catalog %s is %s.", entry.Name, htfs.CatalogHealth(report, entry.Name))
+		})
+	}
+}
+
+// handle reacts to one key, and tells if browsing should continue.
+func (it *holotreeBrowser) handle(key rune) bool {
+	it.status = ""
+	switch key {
+	case 'q', pretty.KeyEscape, pretty.KeyInterrupt:
+		return false
+	case '\t', pretty.KeyLeft, pretty.KeyRight:
+		it.catalogs = !it.catalogs
+	case 'j', pretty.KeyDown:
+		it.move(1)
+	case 'k', pretty.KeyUp:
+		it.move(-1)
+	case 'r':
+		it.refresh()
+	default:
+		at := it.cursor[it.view()]
+		if at >= it.size() {
+			return true
+		}
+		if it.catalogs {
+			it.catalogAction(key, it.entries[at])
+		} else {
+			it.spaceAction(key, it.spaces[at])
+		}
+	}
+	return true
+}
+
+var holotreeBrowseCmd = &cobra.Command{
+	Use:   "browse",
+	Short: "Browse holotree spaces and catalogs interactively. For human users.",
+	Long: `Browse holotree spaces and catalogs interactively in terminal.
+
+Lists spaces and catalogs with their sizes, last-used times and pins, most
+recently used first. Tab switches between spaces and catalogs, arrow keys
+(or j and k) move selection, and keys act on selected entry:
+
+  d  delete space or catalog (after confirmation, pinned ones are refused)
+  p  pin or unpin space or catalog
+  e  export catalog (of space) into <catalog>.zip in current directory
+  c  check space drift against its catalog, or catalog integrity
+  r  refresh listings
+  q  quit
+
+This is for human users, do not use it in automation.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		pretty.Guard(pretty.Interactive, 1, "Holotree browser needs interactive terminal. Use other holotree commands in automation.")
+		restore, err := pretty.RawTerminal()
+		pretty.Guard(err == nil, 2, "Could not set up terminal, reason: %v", err)
+		browser := &holotreeBrowser{
+			reader:  bufio.NewReader(os.Stdin),
+			restore: restore,
+		}
+		defer func() {
+			browser.restore()
+		}()
+		browser.refresh()
+		for {
+			browser.draw()
+			key, err := pretty.ReadKey(browser.reader)
+			if err != nil || !browser.handle(key) {
+				break
+			}
+		}
+		fmt.Printf("%s%s", pretty.Home, pretty.Clear)
+	},
+}
+
+func init() {
+	holotreeCmd.AddCommand(holotreeBrowseCmd)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/spf13/cobra"
+)
+
+func humaneCatalogInfos(infos []*htfs.CatalogInfo) {
+	tabbed := tabwriter.NewWriter(os.Stderr, 2, 4, 2, ' ', 0)
+	tabbed.Write([]byte("Catalog\tFiles\tSize\tSigned\tCreated\trcc\tConda hash\n"))
+	tabbed.Write([]byte("-------\t-----\t----\t------\t-------\t---\t----------\n"))
+	for _, info := range infos {
+		created, version, conda := "-", "-", "-"
+		if info.Provenance != nil {
+			created, version = info.Provenance.Created, info.Provenance.RccVersion
+			if len(info.Provenance.CondaHash) > 0 {
+				conda = info.Provenance.CondaHash
+			}
+		}
+		data := fmt.Sprintf("%s\t%d\t%s\t%v\t%s\t%s\t%s\n", info.Name, info.Files, megabytes(info.Bytes), info.Signed, created, version, conda)
+		tabbed.Write([]byte(data))
+	}
+	tabbed.Flush()
+}
+
+var holotreeCatalogsCmd = &cobra.Command{
+	Use:   "catalogs",
+	Short: "List hololib catalogs with their provenance.",
+	Long: `List hololib catalogs with their provenance.
+
+For catalogs recorded with this or later rcc version, shows when and with
+which rcc version and platform catalog was created, and digest of source
+conda.yaml file(s) it was built from.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Holotree catalogs lasted").Report()
+		}
+		infos, err := htfs.CatalogInfos()
+		pretty.Guard(err == nil, 1, "Could not list catalogs, reason: %v", err)
+		if jsonFlag {
+			body, err := json.MarshalIndent(infos, "", "  ")
+			pretty.Guard(err == nil, 2, "Could not create json, reason: %v", err)
+			fmt.Println(string(body))
+			return
+		}
+		humaneCatalogInfos(infos)
+	},
+}
+
+func init() {
+	holotreeCmd.AddCommand(holotreeCatalogsCmd)
+	holotreeCatalogsCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output in JSON format")
+}
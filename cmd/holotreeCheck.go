@@ -1,10 +1,9 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
-	"path/filepath"
 
-	"github.com/robocorp/rcc/anywork"
 	"github.com/robocorp/rcc/common"
 	"github.com/robocorp/rcc/htfs"
 	"github.com/robocorp/rcc/pretty"
@@ -12,52 +11,115 @@ import (
 )
 
 func checkHolotreeIntegrity() {
-	common.Timeline("holotree integrity check start")
-	defer common.Timeline("holotree integrity check done")
-	fs, err := htfs.NewRoot(common.HololibLibraryLocation())
+	var report *htfs.IntegrityReport
+	var err error
+	switch {
+	case checkRepairFlag:
+		report, err = htfs.RepairIntegrity(checkSourceOption)
+	case dryFlag:
+		report, err = htfs.InspectIntegrity()
+	default:
+		report, err = htfs.CheckIntegrity()
+	}
 	pretty.Guard(err == nil, 1, "%s", err)
-	common.Timeline("holotree integrity lift")
-	err = fs.Lift()
-	pretty.Guard(err == nil, 2, "%s", err)
-	common.Timeline("holotree integrity hasher")
-	known := htfs.LoadHololibHashes()
-	err = fs.AllFiles(htfs.Hasher(known))
-	pretty.Guard(err == nil, 3, "%s", err)
-	collector := make(map[string]string)
-	common.Timeline("holotree integrity collector")
-	err = fs.Treetop(htfs.IntegrityCheck(collector))
-	common.Timeline("holotree integrity report")
-	pretty.Guard(err == nil, 4, "%s", err)
-	purge := make(map[string]bool)
-	for k, v := range collector {
+	if jsonFlag {
+		content, err := json.MarshalIndent(report, "", "  ")
+		pretty.Guard(err == nil, 3, "%s", err)
+		common.Stdout("%s\n", content)
+	} else {
+		humaneIntegrityReport(report)
+	}
+	if checkRepairFlag {
+		pretty.Guard(len(report.Purged) == 0, 6, "Unrepairable catalogs: %d", len(report.Purged))
+		return
+	}
+	pretty.Guard(len(report.Damaged) == 0, 6, "Size: %d", len(report.Damaged))
+}
+
+func humaneIntegrityReport(report *htfs.IntegrityReport) {
+	for k, v := range report.Damaged {
 		fmt.Println(k, v)
-		found, ok := known[filepath.Base(k)]
-		if !ok {
-			continue
+	}
+	for _, k := range report.Missing {
+		fmt.Println("Missing blob:", k)
+	}
+	for k, v := range report.Repaired {
+		fmt.Println("Repaired blob:", k, "from", v)
+	}
+	for _, k := range report.Unreadable {
+		fmt.Println("Unreadable catalog:", k)
+	}
+	if report.Dryrun {
+		for _, k := range report.Orphans {
+			fmt.Println("Orphan blob:", k)
 		}
-		for catalog, _ := range found {
-			purge[catalog] = true
+		for _, k := range report.Affected {
+			fmt.Println("Affected catalog:", k)
 		}
 	}
-	redo := false
-	for k, _ := range purge {
+	for _, k := range report.Purged {
 		fmt.Println("Purge catalog:", k)
-		redo = true
-		anywork.Backlog(htfs.RemoveFile(k))
 	}
-	if redo {
+	if len(report.Purged) > 0 {
 		pretty.Warning("Some catalogs were purged. Run this check command again, please!")
 	}
-	err = anywork.Sync()
-	pretty.Guard(err == nil, 5, "%s", err)
-	pretty.Guard(len(collector) == 0, 6, "Size: %d", len(collector))
+}
+
+var (
+	checkDiffFlag     bool
+	checkRepairFlag   bool
+	checkSourceOption string
+)
+
+func showSpaceDrift(space string) {
+	drift, err := htfs.CheckSpaceDrift(space)
+	pretty.Guard(err == nil, 2, "%s", err)
+	if jsonFlag {
+		content, err := json.MarshalIndent(drift, "", "  ")
+		pretty.Guard(err == nil, 3, "%s", err)
+		common.Stdout("%s\n", content)
+		return
+	}
+	common.Log("Space %q at %q, blueprint %q:", drift.Space, drift.Path, drift.Blueprint)
+	for _, name := range drift.Added {
+		common.Log("%s  + add     %s%s", pretty.Green, name, pretty.Reset)
+	}
+	for _, name := range drift.Replaced {
+		common.Log("%s  ~ replace %s%s", pretty.Yellow, name, pretty.Reset)
+	}
+	for _, name := range drift.Deleted {
+		common.Log("%s  - delete  %s%s", pretty.Red, name, pretty.Reset)
+	}
+	if drift.Dirty() {
+		common.Log("Restore would add %d, replace %d and delete %d file(s).", len(drift.Added), len(drift.Replaced), len(drift.Deleted))
+	} else {
+		common.Log("Space matches its catalog, restore would change nothing.")
+	}
 }
 
 var holotreeCheckCmd = &cobra.Command{
 	Use:   "check",
 	Short: "Check holotree library integrity.",
-	Long:  "Check holotree library integrity.",
+	Long: `Check holotree library integrity.
+
+With --repair, missing and damaged blobs are re-lifted from intact files in
+live spaces, or from hololib given with --source, and only catalogs that
+could not be repaired are purged.
+
+With --diff, library is not checked, but instead given space is compared
+against its catalog, and files that restore would add, replace or delete
+are listed (without touching the space).
+
+With --dryrun, library is only inspected, and nothing gets removed or purged.
+With --json, report (damaged, missing and orphan blobs, unreadable and
+affected catalogs) is given in machine readable form, for monitoring.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if checkDiffFlag {
+			showSpaceDrift(common.HolotreeSpace)
+			pretty.Ok()
+			return
+		}
+		pretty.Guard(!(dryFlag && checkRepairFlag), 1, "Options --dryrun and --repair cannot be used together.")
 		checkHolotreeIntegrity()
 		pretty.Ok()
 	},
@@ -65,4 +127,10 @@ var holotreeCheckCmd = &cobra.Command{
 
 func init() {
 	holotreeCmd.AddCommand(holotreeCheckCmd)
+	holotreeCheckCmd.Flags().BoolVarP(&checkRepairFlag, "repair", "", false, "Repair missing and damaged blobs from live spaces (or --source hololib) instead of just reporting them.")
+	holotreeCheckCmd.Flags().StringVarP(&checkSourceOption, "source", "", "", "Intact hololib directory to repair blobs from (with --repair). <optional>")
+	holotreeCheckCmd.Flags().BoolVarP(&checkDiffFlag, "diff", "", false, "Show what restore would change in space, without restoring it.")
+	holotreeCheckCmd.Flags().StringVarP(&common.HolotreeSpace, "space", "s", "user", "Client specific name of space to compare (with --diff).")
+	holotreeCheckCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output in JSON format.")
+	holotreeCheckCmd.Flags().BoolVarP(&dryFlag, "dryrun", "d", false, "Only report problems, do not remove orphan blobs or purge catalogs.")
 }
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/spf13/cobra"
+)
+
+var holotreeCloneCmd = &cobra.Command{
+	Use:   "clone space new-space",
+	Short: "Create new holotree space as copy of existing one.",
+	Long: `Create new holotree space as copy of existing one.
+
+Both spaces belong to current controller (see --controller). Files of new
+space are reflinked (copy-on-write) from existing space, where filesystem
+supports it, and otherwise copied, or hardlinked with hardlink restore mode.
+Embedded space paths are rewritten for new space, files changed in existing
+space are taken from hololib instead, and metadata of new space is copied
+from existing one. New space must not exist yet.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Holotree clone lasted").Report()
+		}
+		library, err := htfs.New()
+		pretty.Guard(err == nil, 2, "Could not get holotree library, reason: %v", err)
+		target, err := htfs.CloneSpace(library, args[0], args[1])
+		pretty.Guard(err == nil, 3, "Could not clone space %q, reason: %v", args[0], err)
+		common.Log("Cloned space %q as %q into %q.", args[0], args[1], target)
+		pretty.Ok()
+	},
+}
+
+func init() {
+	holotreeCmd.AddCommand(holotreeCloneCmd)
+}
@@ -9,16 +9,25 @@ import (
 )
 
 func deleteByPartialIdentity(partials []string) {
+	labels := []string{}
 	for _, prefix := range partials {
-		for _, label := range htfs.FindEnvironment(prefix) {
-			common.Log("Removing %v", label)
-			if dryFlag {
-				continue
-			}
-			err := htfs.RemoveHolotreeSpace(label)
-			pretty.Guard(err == nil, 1, "Error: %v", err)
+		labels = append(labels, htfs.FindEnvironment(prefix)...)
+	}
+	if previewing() && len(labels) > 0 {
+		for _, label := range labels {
+			common.Log("Would remove %v", label)
+		}
+		confirmDestructive("Delete %d space(s) listed above?", len(labels))
+	}
+	for _, label := range labels {
+		common.Log("%s %v", dryrunVerb("Removing", "Would remove"), label)
+		if dryFlag {
+			continue
 		}
+		err := htfs.RemoveHolotreeSpace(label)
+		pretty.Guard(err == nil, 1, "Error: %v", err)
 	}
+	dryrunDone()
 }
 
 var holotreeDeleteCmd = &cobra.Command{
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pretty"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	deltaManifest string
+	deltaZipfile  string
+)
+
+var holotreeDeltaCmd = &cobra.Command{
+	Use:   "delta",
+	Short: "Ship only blobs that other machine does not have yet.",
+	Long: `Ship only blobs that other machine does not have yet.
+
+Receiving machine writes manifest of its hololib ("rcc holotree delta
+manifest"), sending machine exports catalogs with only blobs missing from
+that manifest ("rcc holotree delta export"), and receiving machine imports
+resulting bundle with normal "rcc holotree import", which takes rest of
+blobs from its own hololib.`,
+}
+
+var holotreeDeltaManifestCmd = &cobra.Command{
+	Use:   "manifest",
+	Short: "Write manifest of blobs in local hololib.",
+	Long:  "Write manifest of blobs in local hololib, one digest per line.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Holotree delta manifest lasted").Report()
+		}
+		count, err := htfs.WriteDeltaManifest(deltaManifest)
+		pretty.Guard(err == nil, 2, "Could not write manifest %q, reason: %v", deltaManifest, err)
+		common.Log("Wrote %d digest(s) into manifest %q.", count, deltaManifest)
+		pretty.Ok()
+	},
+}
+
+var holotreeDeltaExportCmd = &cobra.Command{
+	Use:   "export catalog+",
+	Short: "Export catalogs with only blobs missing from manifest.",
+	Long: `Export catalogs with only blobs missing from manifest.
+
+Catalogs are given as substrings of their names (like in "rcc holotree
+export"), and manifest (--manifest) is written on receiving machine.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Holotree delta export lasted").Report()
+		}
+		known, err := htfs.LoadDeltaManifest(deltaManifest)
+		pretty.Guard(err == nil, 1, "%v", err)
+		catalogs := selectCatalogs(args)
+		pretty.Guard(len(catalogs) > 0, 1, "No catalogs matching %q.", args)
+		library, err := htfs.New()
+		pretty.Guard(err == nil, 2, "Could not get holotree library, reason: %v", err)
+		report, err := htfs.ExportDelta(library, catalogs, known, deltaZipfile)
+		pretty.Guard(err == nil, 3, "Could not export delta %q, reason: %v", deltaZipfile, err)
+		if jsonFlag {
+			body, err := json.MarshalIndent(report, "", "  ")
+			pretty.Guard(err == nil, 4, "Could not create json, reason: %v", err)
+			fmt.Println(string(body))
+			return
+		}
+		for _, catalog := range report.Catalogs {
+			common.Log("- %s", catalog)
+		}
+		common.Log("Exported %d catalog(s) into %q with %d blob(s), left out %d blob(s) known by manifest.", len(report.Catalogs), deltaZipfile, report.Blobs, report.Skipped)
+		pretty.Ok()
+	},
+}
+
+func init() {
+	holotreeCmd.AddCommand(holotreeDeltaCmd)
+	holotreeDeltaCmd.AddCommand(holotreeDeltaManifestCmd)
+	holotreeDeltaCmd.AddCommand(holotreeDeltaExportCmd)
+
+	holotreeDeltaCmd.PersistentFlags().StringVarP(&deltaManifest, "manifest", "m", "hololib.manifest", "Manifest file of receiving hololib.")
+	holotreeDeltaExportCmd.Flags().StringVarP(&deltaZipfile, "zipfile", "z", "delta.zip", "Name of delta zipfile to export.")
+	holotreeDeltaExportCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output in JSON format")
+}
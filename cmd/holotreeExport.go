@@ -11,10 +11,13 @@ import (
 )
 
 var (
-	holozip string
+	holozip       string
+	exportBundles []string
+	exportFormat  string
+	exportPush    string
 )
 
-func holotreeExport(catalogs []string, archive string) {
+func holotreeExport(catalogs, bundles []string, archive string) {
 	common.Debug("Exporting catalogs:")
 	for _, catalog := range catalogs {
 		common.Debug("- %s", catalog)
@@ -23,7 +26,23 @@ func holotreeExport(catalogs []string, archive string) {
 	tree, err := htfs.New()
 	pretty.Guard(err == nil, 2, "%s", err)
 
-	err = tree.Export(catalogs, archive)
+	err = tree.Export(catalogs, bundles, archive)
+	pretty.Guard(err == nil, 3, "%s", err)
+}
+
+func holotreeExportOci(catalogs []string, archive, reference string) {
+	tree, err := htfs.New()
+	pretty.Guard(err == nil, 2, "%s", err)
+
+	export, err := htfs.ExportOci(tree, catalogs)
+	pretty.Guard(err == nil, 3, "%s", err)
+	if len(reference) > 0 {
+		err = export.Push(reference)
+		pretty.Guard(err == nil, 4, "Could not push to %q, reason: %v", reference, err)
+		common.Log("Pushed %d layer(s) to %s.", len(export.Layers), reference)
+		return
+	}
+	err = export.WriteArchive(archive)
 	pretty.Guard(err == nil, 3, "%s", err)
 }
 
@@ -56,15 +75,32 @@ func selectCatalogs(filters []string) []string {
 var holotreeExportCmd = &cobra.Command{
 	Use:   "export catalog+",
 	Short: "Export existing holotree catalog and library parts.",
-	Long:  "Export existing holotree catalog and library parts.",
+	Long: `Export existing holotree catalog and library parts.
+
+Multiple catalogs can be given (as substrings of catalog names), and they
+all go into one archive, where blobs shared by catalogs are stored only once.
+
+With --merge, content of other exported bundles (for example same blueprint
+exported on other platforms) is combined into resulting bundle. Import and
+robot holozip usage then pick catalogs matching local platform.
+
+With --format=oci, catalogs and blobs are packaged as OCI artifact (each as
+its own layer), written as OCI layout tar archive, or with --push pushed to
+container registry (credentials from RCC_REGISTRY_USERNAME and
+RCC_REGISTRY_PASSWORD environment variables, when needed).`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if common.DebugFlag {
 			defer common.Stopwatch("Holotree export command lasted").Report()
 		}
-		if len(args) == 0 {
+		pretty.Guard(exportFormat == "zip" || exportFormat == "oci", 1, "Unknown export format %q, use zip or oci.", exportFormat)
+		pretty.Guard(exportFormat == "oci" || len(exportPush) == 0, 1, "Option --push requires --format=oci.")
+		if len(args) == 0 && len(exportBundles) == 0 {
 			listCatalogs(jsonFlag)
+		} else if exportFormat == "oci" {
+			pretty.Guard(len(exportBundles) == 0, 1, "Option --merge is not supported with --format=oci.")
+			holotreeExportOci(selectCatalogs(args), holozip, exportPush)
 		} else {
-			holotreeExport(selectCatalogs(args), holozip)
+			holotreeExport(selectCatalogs(args), exportBundles, holozip)
 		}
 		pretty.Ok()
 	},
@@ -73,5 +109,8 @@ var holotreeExportCmd = &cobra.Command{
 func init() {
 	holotreeCmd.AddCommand(holotreeExportCmd)
 	holotreeExportCmd.Flags().StringVarP(&holozip, "zipfile", "z", "hololib.zip", "Name of zipfile to export.")
+	holotreeExportCmd.Flags().StringArrayVarP(&exportBundles, "merge", "m", []string{}, "Other exported bundle (zipfile) to merge into this one. Can be given multiple times.")
 	holotreeExportCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output in JSON format")
+	holotreeExportCmd.Flags().StringVarP(&exportFormat, "format", "", "zip", "Export format, either zip or oci.")
+	holotreeExportCmd.Flags().StringVarP(&exportPush, "push", "", "", "With --format=oci, push artifact to registry reference (like registry.example.com/team/env:tag) instead of writing archive.")
 }
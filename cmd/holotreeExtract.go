@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/spf13/cobra"
+)
+
+var (
+	extractSubpath string
+	extractTarget  string
+)
+
+var holotreeExtractCmd = &cobra.Command{
+	Use:   "extract catalog",
+	Short: "Restore only part of holotree catalog into target directory.",
+	Long: `Restore only part of holotree catalog into target directory.
+
+Catalog can be given as substring of its name, or as path to catalog file.
+Only files under --subpath (directory or single file, relative to space root,
+like lib/python3.9/site-packages/foo) are restored into --target directory,
+which must not exist yet, or be empty. Files with embedded space paths keep
+paths of their original space, so this is meant for extracting
+self-contained parts (like single tools) from large environments.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Holotree extract lasted").Report()
+		}
+		if len(args) == 0 {
+			listCatalogs(jsonFlag)
+			return
+		}
+		pretty.Guard(len(extractTarget) > 0, 1, "Target directory is required (--target).")
+		catalog := catalogLocation(args[0])
+		library, err := htfs.New()
+		pretty.Guard(err == nil, 2, "Could not get holotree library, reason: %v", err)
+		err = htfs.RestoreSubtree(library, catalog, extractSubpath, extractTarget)
+		pretty.Guard(err == nil, 3, "Could not extract %q, reason: %v", extractSubpath, err)
+		common.Log("Extracted %q from %q into %q.", extractSubpath, args[0], extractTarget)
+		pretty.Ok()
+	},
+}
+
+func init() {
+	holotreeCmd.AddCommand(holotreeExtractCmd)
+	holotreeExtractCmd.Flags().StringVarP(&extractSubpath, "subpath", "s", ".", "Relative path of directory or file inside catalog to restore.")
+	holotreeExtractCmd.Flags().StringVarP(&extractTarget, "target", "t", "", "Directory where subpath content is restored.")
+	holotreeExtractCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output catalog list in JSON format")
+}
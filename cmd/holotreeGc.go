@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/spf13/cobra"
+)
+
+var holotreeGcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove hololib blobs that no catalog references anymore.",
+	Long: `Remove hololib blobs that no catalog references anymore.
+
+All catalogs are read, and blobs in own hololib library that none of them
+refers to are removed. Recently written blobs are kept, since they might
+belong to catalog still being recorded.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Holotree gc lasted").Report()
+		}
+		if previewing() {
+			preview, err := htfs.CollectGarbage(true)
+			pretty.Guard(err == nil, 1, "Could not collect garbage, reason: %v", err)
+			confirmDestructive("Remove %d unreferenced blob(s), freeing %s?", len(preview.Removed), megabytes(preview.Freed))
+		}
+		report, err := htfs.CollectGarbage(dryFlag)
+		pretty.Guard(err == nil, 1, "Could not collect garbage, reason: %v", err)
+		if jsonFlag {
+			body, err := json.MarshalIndent(report, "", "  ")
+			pretty.Guard(err == nil, 2, "Could not create json, reason: %v", err)
+			fmt.Println(string(body))
+			return
+		}
+		if report.Dryrun {
+			for _, digest := range report.Removed {
+				common.Log("- %s", digest)
+			}
+		}
+		common.Log("%s %d blob(s), freeing %s. Kept %d blob(s), %s, used by %d catalog(s).", dryrunVerb("Removed", "Would remove"), len(report.Removed), megabytes(report.Freed), report.Kept, megabytes(report.Remaining), report.Catalogs)
+		if report.Repack && len(report.Removed) > 0 && !report.Dryrun {
+			common.Log("Space of removed packed blobs is reclaimed by `rcc holotree repack`.")
+		}
+		dryrunDone()
+		pretty.Ok()
+	},
+}
+
+func init() {
+	holotreeCmd.AddCommand(holotreeGcCmd)
+	holotreeGcCmd.Flags().BoolVarP(&dryFlag, "dryrun", "d", false, "Don't remove anything, just show what would happen.")
+	holotreeGcCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output in JSON format")
+}
@@ -1,29 +1,114 @@
 package cmd
 
 import (
+	"os"
+
+	"github.com/robocorp/rcc/cloud"
 	"github.com/robocorp/rcc/common"
-	"github.com/robocorp/rcc/operations"
+	"github.com/robocorp/rcc/htfs"
 	"github.com/robocorp/rcc/pretty"
 	"github.com/spf13/cobra"
 )
 
+var (
+	importChecksum string
+)
+
+// importLocation returns local file for given bundle, downloading it first
+// when it is URL. Downloaded files are removed by returned cleanup.
+func importLocation(location string) (string, func()) {
+	if !cloud.IsUrl(location) {
+		return location, func() {}
+	}
+	link, checksum := cloud.SplitChecksum(location)
+	if len(checksum) == 0 {
+		checksum = importChecksum
+	}
+	filename := cloud.DownloadLocation(link, ".zip")
+	common.Log("Downloading %q ...", link)
+	err := cloud.ResumableDownload(link, filename, checksum)
+	pretty.Guard(err == nil, 2, "Could not download %q, reason: %v", link, err)
+	return filename, func() {
+		os.Remove(filename)
+	}
+}
+
 var holotreeImportCmd = &cobra.Command{
 	Use:   "import hololib.zip+",
 	Short: "Import one or more hololib.zip files into local hololib.",
-	Long:  "Import one or more hololib.zip files into local hololib.",
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Import one or more hololib.zip files into local hololib.
+
+Only catalogs for local platform (and blobs they need) are imported from
+multi-platform bundles, others are skipped.
+
+Bundles can also be http(s) URLs. Those are downloaded first, and if
+download gets interrupted, next attempt (even by later rcc run) continues
+from where it was. Expected SHA256 checksum can be given either with
+--sha256 option, or as "#sha256=<hex>" fragment in URL.
+
+Bundle content is first extracted into staging area (hololib/staging) and
+verified against digests, and only then moved into hololib, so failing
+import does not leave partial catalogs behind. Importing same bundle again
+continues from already staged and verified blobs.`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		if common.DebugFlag {
 			defer common.Stopwatch("Holotree import command lasted").Report()
 		}
-		for _, filename := range args {
-			err := operations.Unzip(common.HololibLocation(), filename, true, false)
-			pretty.Guard(err == nil, 1, "Could not import %q, reason: %v", filename, err)
+		pretty.Guard(len(importChecksum) == 0 || len(args) == 1, 1, "Option --sha256 can only be used with single bundle (use #sha256= fragments instead).")
+		for _, location := range args {
+			filename, cleanup := importLocation(location)
+			if dryFlag || previewing() {
+				preview, err := htfs.PreviewBundle(filename)
+				if err != nil {
+					cleanup()
+				}
+				pretty.Guard(err == nil, common.ExitCodeOf(err, 1), "Could not import %q, reason: %v", location, err)
+				if dryFlag {
+					cleanup()
+					for _, catalog := range preview.Imported {
+						common.Log("Would import catalog %s from %q.", catalog, location)
+					}
+					for _, catalog := range preview.Replaced {
+						common.Log("Would replace existing catalog %s.", catalog)
+					}
+					common.Log("Would import %d blob(s), skip %d catalog(s) of other platforms.", preview.Blobs, len(preview.Skipped))
+					continue
+				}
+				if len(preview.Replaced) > 0 {
+					for _, catalog := range preview.Replaced {
+						common.Log("Would replace existing catalog %s.", catalog)
+					}
+					if !confirmed("Replace %d existing catalog(s) in hololib?", len(preview.Replaced)) {
+						cleanup()
+						pretty.Exit(common.CodeCancelled.Exit, "[%s] Cancelled, nothing was changed.", common.CodeCancelled.Code)
+					}
+				}
+			}
+			report, err := htfs.ImportBundle(filename)
+			cleanup()
+			pretty.Guard(err == nil, common.ExitCodeOf(err, 1), "Could not import %q, reason: %v", location, err)
+			for _, catalog := range report.Imported {
+				common.Log("Imported catalog %s from %q.", catalog, location)
+			}
+			for _, catalog := range report.Replaced {
+				common.Log("Replaced existing catalog %s.", catalog)
+			}
+			for _, catalog := range report.Skipped {
+				common.Debug("Skipped catalog %s (other platform) from %q.", catalog, location)
+			}
+			if report.Resumed > 0 {
+				common.Log("Resumed %d blob(s) staged by earlier, interrupted import.", report.Resumed)
+			}
+			common.Log("Imported %d new blob(s), skipped %d catalog(s) of other platforms.", report.Blobs, len(report.Skipped))
 		}
+		dryrunDone()
 		pretty.Ok()
 	},
 }
 
 func init() {
 	holotreeCmd.AddCommand(holotreeImportCmd)
+	holotreeImportCmd.Flags().StringVarP(&importChecksum, "sha256", "", "", "Expected SHA256 checksum of downloaded bundle (for single URL). <optional>")
+	holotreeImportCmd.Flags().BoolVarP(&dryFlag, "dryrun", "d", false, "Only show what would be imported or replaced, do not change hololib.")
 }
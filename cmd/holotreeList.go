@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"text/tabwriter"
+	"time"
 
 	"github.com/robocorp/rcc/common"
 	"github.com/robocorp/rcc/htfs"
@@ -13,33 +14,115 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func humaneHolotreeSpaceListing() {
+var (
+	listSizesFlag  bool
+	listController string
+	listOlderThan  int
+	listSortOrder  string
+)
+
+func listFilter(patterns []string) *htfs.SpaceFilter {
+	pretty.Guard(listOlderThan >= 0, 1, "Option --older-than must not be negative, was %d.", listOlderThan)
+	filter := &htfs.SpaceFilter{
+		Controller: listController,
+		OlderThan:  listOlderThan,
+		Patterns:   patterns,
+	}
+	err := filter.ValidPatterns()
+	pretty.Guard(err == nil, 1, "%v", err)
+	return filter
+}
+
+func listedSpaces(filter *htfs.SpaceFilter) []*htfs.SpaceEntry {
+	spaces, err := htfs.ListSpaces(filter, listSortOrder)
+	pretty.Guard(err == nil, 1, "%v", err)
+	return spaces
+}
+
+func shortTime(when time.Time) string {
+	if when.IsZero() {
+		return "-"
+	}
+	return when.Local().Format("2006-01-02 15:04")
+}
+
+func createdTime(created string) string {
+	when, err := time.Parse(time.RFC3339, created)
+	if err != nil {
+		return "-"
+	}
+	return shortTime(when)
+}
+
+func humaneHolotreeSpaceListing(filter *htfs.SpaceFilter) {
 	tabbed := tabwriter.NewWriter(os.Stderr, 2, 4, 2, ' ', 0)
-	tabbed.Write([]byte("Identity\tController\tSpace\tBlueprint\tFull path\n"))
-	tabbed.Write([]byte("--------\t----------\t-----\t--------\t---------\n"))
-	for _, space := range htfs.Spaces() {
-		data := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\n", space.Identity, space.Controller, space.Space, space.Blueprint, space.Path)
+	tabbed.Write([]byte("Identity\tController\tSpace\tBlueprint\tSize\tUsed\tCreated\tFull path\n"))
+	tabbed.Write([]byte("--------\t----------\t-----\t--------\t----\t----\t-------\t---------\n"))
+	for _, space := range listedSpaces(filter) {
+		data := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", space.Label, space.Controller, space.Space, space.Blueprint, megabytes(space.Size), shortTime(space.Used), createdTime(space.Created), space.Path)
 		tabbed.Write([]byte(data))
 	}
 	tabbed.Flush()
 }
 
-func jsonicHolotreeSpaceListing() {
-	details := make(map[string]map[string]string)
-	for _, space := range htfs.Spaces() {
-		hold, ok := details[space.Identity]
-		if !ok {
-			hold = make(map[string]string)
-			details[space.Identity] = hold
-			hold["id"] = space.Identity
-			hold["controller"] = space.Controller
-			hold["space"] = space.Space
-			hold["blueprint"] = space.Blueprint
-			hold["path"] = space.Path
-			hold["meta"] = space.Path + ".meta"
-			hold["spec"] = filepath.Join(space.Path, "identity.yaml")
-			hold["plan"] = filepath.Join(space.Path, "rcc_plan.log")
+func humaneHolotreeSpaceSizes(filter *htfs.SpaceFilter) {
+	report := htfs.FilteredSpaceSizes(filter)
+	tabbed := tabwriter.NewWriter(os.Stderr, 2, 4, 2, ' ', 0)
+	tabbed.Write([]byte("Identity\tController\tSpace\tFiles\tSize\tFull path\n"))
+	tabbed.Write([]byte("--------\t----------\t-----\t-----\t----\t---------\n"))
+	for _, space := range report.Spaces {
+		data := fmt.Sprintf("%s\t%s\t%s\t%d\t%s\t%s\n", space.Identity, space.Controller, space.Space, space.Files, megabytes(space.Size), space.Path)
+		tabbed.Write([]byte(data))
+	}
+	tabbed.Write([]byte("\nController\tSpaces\tFiles\tSize\n"))
+	tabbed.Write([]byte("----------\t------\t-----\t----\n"))
+	for _, controller := range report.Controllers {
+		data := fmt.Sprintf("%s\t%d\t%d\t%s\n", controller.Controller, controller.Spaces, controller.Files, megabytes(controller.Size))
+		tabbed.Write([]byte(data))
+	}
+	tabbed.Flush()
+	common.Log("Holotree spaces total: %s", megabytes(report.Total))
+}
+
+func jsonicHolotreeSpaceSizes(filter *htfs.SpaceFilter) {
+	body, err := json.MarshalIndent(htfs.FilteredSpaceSizes(filter), "", "  ")
+	pretty.Guard(err == nil, 1, "Could not create json, reason: %v", err)
+	fmt.Println(string(body))
+}
+
+func jsonicSpaceDetails(space *htfs.SpaceEntry) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         space.Label,
+		"controller": space.Controller,
+		"space":      space.Space,
+		"blueprint":  space.Blueprint,
+		"path":       space.Path,
+		"meta":       space.Path + ".meta",
+		"spec":       filepath.Join(space.Path, "identity.yaml"),
+		"plan":       filepath.Join(space.Path, "rcc_plan.log"),
+		"size":       space.Size,
+		"files":      space.Files,
+		"used":       space.Used,
+		"created":    space.Created,
+		"pinned":     space.Pinned,
+	}
+}
+
+func jsonicHolotreeSpaceListing(filter *htfs.SpaceFilter) {
+	var details interface{}
+	spaces := listedSpaces(filter)
+	if len(listSortOrder) > 0 {
+		ordered := make([]map[string]interface{}, 0, len(spaces))
+		for _, space := range spaces {
+			ordered = append(ordered, jsonicSpaceDetails(space))
+		}
+		details = ordered
+	} else {
+		keyed := make(map[string]map[string]interface{})
+		for _, space := range spaces {
+			keyed[space.Label] = jsonicSpaceDetails(space)
 		}
+		details = keyed
 	}
 	body, err := json.MarshalIndent(details, "", "  ")
 	pretty.Guard(err == nil, 1, "Could not create json, reason: %w", err)
@@ -47,19 +130,40 @@ func jsonicHolotreeSpaceListing() {
 }
 
 var holotreeListCmd = &cobra.Command{
-	Use:     "list",
+	Use:     "list [pattern+]",
 	Aliases: []string{"ls"},
 	Short:   "List holotree spaces.",
-	Long:    "List holotree spaces.",
+	Long: `List holotree spaces.
+
+Listing can be limited to spaces whose space name (or identity) matches any
+of given glob patterns (like "prod-*"), to spaces of one controller with
+--controller, and to spaces not used in given number of days with
+--older-than. With --sort, spaces are ordered by size (largest first), or by
+last use or creation time (most recent first); sorted JSON output is list,
+while unsorted JSON output is object keyed by space identity.
+
+With --sizes, size of each space and total size of spaces per controller
+are shown. Sizes are computed from space metadata (without walking spaces on
+disk), and they are logical sizes, so hardlinked files are counted for every
+space that has them.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if common.DebugFlag {
 			defer common.Stopwatch("Holotree list lasted").Report()
 		}
 
-		if jsonFlag {
-			jsonicHolotreeSpaceListing()
-		} else {
-			humaneHolotreeSpaceListing()
+		if cmd.Flags().Changed("controller") {
+			common.ControllerType = listController
+		}
+		filter := listFilter(args)
+		switch {
+		case listSizesFlag && jsonFlag:
+			jsonicHolotreeSpaceSizes(filter)
+		case listSizesFlag:
+			humaneHolotreeSpaceSizes(filter)
+		case jsonFlag:
+			jsonicHolotreeSpaceListing(filter)
+		default:
+			humaneHolotreeSpaceListing(filter)
 		}
 
 	},
@@ -68,4 +172,8 @@ var holotreeListCmd = &cobra.Command{
 func init() {
 	holotreeCmd.AddCommand(holotreeListCmd)
 	holotreeListCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output in JSON format")
+	holotreeListCmd.Flags().BoolVarP(&listSizesFlag, "sizes", "", false, "Show sizes of spaces, and totals per controller.")
+	holotreeListCmd.Flags().StringVarP(&listController, "controller", "", "", "Only list spaces of this controller (also identifies caller, like global --controller).")
+	holotreeListCmd.Flags().IntVarP(&listOlderThan, "older-than", "", 0, "Only list spaces not used in this many days.")
+	holotreeListCmd.Flags().StringVarP(&listSortOrder, "sort", "", "", "Order spaces by: size, used, or created.")
 }
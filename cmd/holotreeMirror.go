@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mirrorTarget string
+)
+
+var holotreeMirrorCmd = &cobra.Command{
+	Use:   "mirror [catalog...]",
+	Short: "Copy catalogs and their missing blobs into other hololib.",
+	Long: `Copy catalogs and their missing blobs into other hololib.
+
+Target (--to) is hololib directory (on local disk, or network share), or
+URL of shared holotree server (see "rcc holotree serve"). Only blobs that
+target does not have yet are copied, so running this again keeps mirror up
+to date cheaply. Catalogs can be given as substrings of their names, and
+without them all catalogs are mirrored.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Holotree mirror lasted").Report()
+		}
+		pretty.Guard(len(mirrorTarget) > 0, 1, "Target hololib is required (--to).")
+		catalogs := htfs.Catalogs()
+		if len(args) > 0 {
+			catalogs = selectCatalogs(args)
+		}
+		library, err := htfs.New()
+		pretty.Guard(err == nil, 2, "Could not get holotree library, reason: %v", err)
+		report, err := htfs.Mirror(library, catalogs, mirrorTarget)
+		pretty.Guard(err == nil, 3, "Could not mirror to %q, reason: %v", mirrorTarget, err)
+		if jsonFlag {
+			body, err := json.MarshalIndent(report, "", "  ")
+			pretty.Guard(err == nil, 4, "Could not create json, reason: %v", err)
+			fmt.Println(string(body))
+			return
+		}
+		for _, catalog := range report.Catalogs {
+			common.Log("- %s", catalog)
+		}
+		common.Log("Mirrored %d catalog(s) to %q, copied %d blob(s), %d were already there.", len(report.Catalogs), report.Target, report.Blobs, report.Skipped)
+		pretty.Ok()
+	},
+}
+
+func init() {
+	holotreeCmd.AddCommand(holotreeMirrorCmd)
+	holotreeMirrorCmd.Flags().StringVarP(&mirrorTarget, "to", "", "", "Target hololib directory, or shared holotree server URL.")
+	holotreeMirrorCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output in JSON format")
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/spf13/cobra"
+)
+
+var (
+	unpinFlag bool
+	pinSpace  string
+)
+
+var holotreePinCmd = &cobra.Command{
+	Use:   "pin <partial space identity or catalog>*",
+	Short: "Pin spaces and catalogs, so that cleanup and prune keep them.",
+	Long: `Pin spaces and catalogs, so that cleanup and prune keep them.
+
+Spaces are matched by partial identity (like in "rcc holotree delete"), and
+catalogs by their full name (like in "rcc holotree catalogs"). Pinned space
+also keeps catalog it was restored from. Without arguments, lists current
+pins.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		partials := make([]string, 0, len(args)+1)
+		partials = append(partials, args...)
+		if len(pinSpace) > 0 {
+			partials = append(partials, htfs.ControllerSpaceName([]byte(common.ControllerIdentity()), []byte(pinSpace)))
+		}
+		if len(partials) > 0 {
+			changed, err := htfs.Pin(partials, unpinFlag)
+			pretty.Guard(err == nil, 1, "Error: %v", err)
+			verb := "Pinned"
+			if unpinFlag {
+				verb = "Unpinned"
+			}
+			for _, name := range changed {
+				common.Log("%s %s", verb, name)
+			}
+		}
+		pins := htfs.LoadPins()
+		if jsonFlag {
+			body, err := json.MarshalIndent(pins, "", "  ")
+			pretty.Guard(err == nil, 2, "Could not create json, reason: %v", err)
+			fmt.Println(string(body))
+			return
+		}
+		for _, name := range pins.Spaces {
+			common.Stdout("space    %s\n", name)
+		}
+		for _, name := range pins.Catalogs {
+			common.Stdout("catalog  %s\n", name)
+		}
+		pretty.Ok()
+	},
+}
+
+func init() {
+	holotreeCmd.AddCommand(holotreePinCmd)
+	holotreePinCmd.Flags().BoolVarP(&unpinFlag, "unpin", "u", false, "Remove pins instead of adding them.")
+	holotreePinCmd.Flags().StringVarP(&pinSpace, "space", "s", "", "Client specific name to identify space to pin.")
+	holotreePinCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output in JSON format")
+}
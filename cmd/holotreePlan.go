@@ -1,23 +1,84 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
 	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pathlib"
 	"github.com/robocorp/rcc/pretty"
 
 	"github.com/spf13/cobra"
 )
 
+func allFiles(args []string) bool {
+	for _, arg := range args {
+		if !pathlib.IsFile(arg) {
+			return false
+		}
+	}
+	return true
+}
+
+func humaneBuildPlan(plan *conda.BuildPlan) {
+	if plan.Hololib {
+		common.Log("Blueprint %s is already in hololib, so build is not needed, only restore.", plan.Blueprint)
+		return
+	}
+	for _, entry := range plan.Download {
+		common.Stdout("download  %s %s  %s\n", entry.Name, entry.Version, megabytes(entry.Size))
+	}
+	for _, entry := range plan.Cached {
+		common.Stdout("cached    %s %s\n", entry.Name, entry.Version)
+	}
+	for _, requirement := range plan.Pip {
+		common.Stdout("pip       %s\n", requirement)
+	}
+	if plan.CondaLayer {
+		common.Log("Conda layer is in hololib, so %s is not needed, only pip install.", plan.Solver)
+	} else {
+		common.Log("Solver %s would link %d package(s), and download %d of them (%s).", plan.Solver, plan.Linked, len(plan.Download), megabytes(plan.DownloadBytes))
+	}
+	common.Log("Blueprint %s would be built in about %.0f seconds, based on %s.", plan.Blueprint, plan.Estimate, plan.Basis)
+}
+
+func buildPlans(args []string) {
+	for _, condafile := range args {
+		plan, err := htfs.PlanBuild(condafile, forceFlag)
+		pretty.Guard(err == nil, 4, "Could not plan build of %q, reason: %v", condafile, err)
+		if jsonFlag {
+			body, err := json.MarshalIndent(plan, "", "  ")
+			pretty.Guard(err == nil, 5, "Could not create json, reason: %v", err)
+			fmt.Println(string(body))
+		} else {
+			humaneBuildPlan(plan)
+		}
+	}
+	if !jsonFlag {
+		pretty.Ok()
+	}
+}
+
 var holotreePlanCmd = &cobra.Command{
 	Use:   "plan <plan+>",
-	Short: "Show installation plans for given holotree spaces (or substrings)",
-	Long:  "Show installation plans for given holotree spaces (or substrings)",
-	Args:  cobra.MinimumNArgs(1),
+	Short: "Show installation plans for given holotree spaces (or substrings), or build plans for conda.yaml files",
+	Long: `Show installation plans for given holotree spaces (or substrings).
+
+When all arguments are conda.yaml files, shows instead what environment build
+would do, without doing it: is environment already in hololib, which conda
+packages would be downloaded and which are in package cache, what pip would
+install, and how long build would probably take.`,
+	Args: cobra.MinimumNArgs(1),
 
 	Run: func(cmd *cobra.Command, args []string) {
+		if allFiles(args) {
+			buildPlans(args)
+			return
+		}
 		found := false
 		for _, prefix := range args {
 			for _, label := range htfs.FindEnvironment(prefix) {
@@ -36,4 +97,6 @@ var holotreePlanCmd = &cobra.Command{
 
 func init() {
 	holotreeCmd.AddCommand(holotreePlanCmd)
+	holotreePlanCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output build plans in JSON format")
+	holotreePlanCmd.Flags().BoolVarP(&forceFlag, "force", "f", false, "Plan fresh build, ignoring hololib and using fresh repodata")
 }
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/operations"
+	"github.com/robocorp/rcc/pretty"
+
+	"github.com/spf13/cobra"
+)
+
+func prebuildTable(report *operations.PrebuildReport) {
+	tabbed := tabwriter.NewWriter(os.Stderr, 2, 4, 2, ' ', 0)
+	tabbed.Write([]byte("Status\tTime\tBlueprint\tSource\tNotes\n"))
+	tabbed.Write([]byte("------\t----\t---------\t------\t-----\n"))
+	for _, target := range report.Targets {
+		tabbed.Write([]byte(fmt.Sprintf("%s\t%.1fs\t%s\t%s\t%s\n", target.Status, target.Seconds, target.Blueprint, target.Source, target.Problem)))
+	}
+	tabbed.Flush()
+	common.Log("%d configuration(s) under %q handled in %.1fs, %d failed.", len(report.Targets), report.Root, report.Seconds, report.Failures())
+}
+
+var holotreePrebuildCmd = &cobra.Command{
+	Use:   "prebuild [directory]",
+	Short: "Build environments of all robots under directory into hololib.",
+	Long: `Build environments of all robots under directory into hololib.
+
+Finds all robot.yaml files, and conda.yaml files that are not part of those
+robots, under given directory (default is current directory, hidden
+directories are skipped), and makes sure their environments are in hololib,
+without restoring them into any space. Useful for warming CI images.
+
+Same environments are built only once, and environments already in hololib
+are not built again. Missing environments are built concurrently (bounded by
+workers), each in separate rcc process with its own holotree stage slot, so
+that settings from robot.yaml of one robot never affect build of another.
+Failed builds do not stop others; summary table shows outcome of every
+configuration, and exit code is non-zero when any build failed.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Holotree prebuild lasted").Report()
+		}
+		root := "."
+		if len(args) > 0 {
+			root = args[0]
+		}
+		report, err := operations.Prebuild(root, forceFlag)
+		pretty.Guard(err == nil, 1, "Prebuild failed, reason: %v", err)
+		if jsonFlag {
+			printJson(2, report)
+		} else {
+			prebuildTable(report)
+		}
+		failures := report.Failures()
+		pretty.Guard(failures == 0, 3, "%d environment(s) failed to build.", failures)
+		pretty.Ok()
+	},
+}
+
+func init() {
+	holotreeCmd.AddCommand(holotreePrebuildCmd)
+	holotreePrebuildCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output summary in JSON format.")
+	holotreePrebuildCmd.Flags().BoolVarP(&forceFlag, "force", "f", false, "Force environment creation with refresh.")
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/pretty"
+
+	"github.com/spf13/cobra"
+)
+
+var holotreePrefetchCmd = &cobra.Command{
+	Use:   "prefetch <conda.yaml+>",
+	Short: "Download conda packages and pip wheels of environment into caches, without building it.",
+	Long: `Download conda packages and pip wheels of environment into caches, without
+building it. Useful when baking images, or before maintenance windows, when
+later environment builds must work offline or very fast.
+
+Pip wheels are downloaded with "python -m pip download" of python and pip
+from conda.yaml (installed into temporary prefix from just prefetched
+packages), so they match python version and ABI of environment.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Holotree prefetch lasted").Report()
+		}
+		report, err := conda.Prefetch(forceFlag, args...)
+		pretty.Guard(err == nil, 1, "Prefetch failed, reason: %v", err)
+		if jsonFlag {
+			body, err := json.MarshalIndent(report, "", "  ")
+			pretty.Guard(err == nil, 2, "Could not create json, reason: %v", err)
+			fmt.Println(string(body))
+			return
+		}
+		common.Log("Prefetched blueprint %s: %d conda packages downloaded, %d already in cache, pip wheels: %v.", report.Blueprint, len(report.Downloaded), len(report.Cached), report.Pip)
+		pretty.Ok()
+	},
+}
+
+func init() {
+	holotreeCmd.AddCommand(holotreePrefetchCmd)
+	holotreePrefetchCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output in JSON format")
+	holotreePrefetchCmd.Flags().BoolVarP(&forceFlag, "force", "f", false, "Force fresh repodata, instead of using cached one.")
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneDays     int
+	pruneKeepLast int
+)
+
+var holotreePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove catalogs not used for given days, and then their unused blobs.",
+	Long: `Remove catalogs not used for given days, and then their unused blobs.
+
+Catalog is used when holotree space is restored from it, so catalog file
+modification time is its last-used timestamp. Given number of most recently
+used catalogs are always kept. After catalogs are removed, blobs no longer
+referred by remaining catalogs are garbage collected.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Holotree prune lasted").Report()
+		}
+		pretty.Guard(pruneDays > 0, 1, "Days must be positive, was %d.", pruneDays)
+		if previewing() {
+			preview, err := htfs.PruneCatalogs(pruneDays, pruneKeepLast, true)
+			pretty.Guard(err == nil, 2, "Could not prune catalogs, reason: %v", err)
+			for _, catalog := range preview.Removed {
+				common.Log("Would remove %s", catalog)
+			}
+			confirmDestructive("Remove %d catalog(s) and %d blob(s), freeing %s?", len(preview.Removed), len(preview.Garbage.Removed), megabytes(preview.Garbage.Freed))
+		}
+		report, err := htfs.PruneCatalogs(pruneDays, pruneKeepLast, dryFlag)
+		pretty.Guard(err == nil, 2, "Could not prune catalogs, reason: %v", err)
+		if jsonFlag {
+			body, err := json.MarshalIndent(report, "", "  ")
+			pretty.Guard(err == nil, 3, "Could not create json, reason: %v", err)
+			fmt.Println(string(body))
+			return
+		}
+		for _, catalog := range report.Removed {
+			common.Log("- %s", catalog)
+		}
+		garbage := report.Garbage
+		common.Log("%s %d catalog(s) and %d blob(s), freeing %s. Kept %d catalog(s).", dryrunVerb("Removed", "Would remove"), len(report.Removed), len(garbage.Removed), megabytes(garbage.Freed), len(report.Kept))
+		dryrunDone()
+		pretty.Ok()
+	},
+}
+
+func init() {
+	holotreeCmd.AddCommand(holotreePruneCmd)
+	holotreePruneCmd.Flags().IntVarP(&pruneDays, "days", "", 30, "Remove catalogs not used for this many days.")
+	holotreePruneCmd.Flags().IntVarP(&pruneKeepLast, "keep-last", "", 0, "Always keep this many most recently used catalogs.")
+	holotreePruneCmd.Flags().BoolVarP(&dryFlag, "dryrun", "d", false, "Don't remove anything, just show what would happen.")
+	holotreePruneCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output in JSON format")
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/spf13/cobra"
+)
+
+var holotreeRepackCmd = &cobra.Command{
+	Use:   "repack",
+	Short: "Move hololib blobs into pack files, and compact those packs.",
+	Long: `Move hololib blobs into pack files, and compact those packs.
+
+Requires "blob-store: pack" under holotree in settings. Loose blobs of
+hololib library directory tree are moved into packs (few large files, with
+index), and then packs are rewritten without removed blobs and leftovers of
+interrupted writes. Run this once after switching to pack store, and then
+occasionally to reclaim space.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Holotree repack lasted").Report()
+		}
+		report, err := htfs.Repack()
+		pretty.Guard(err == nil, 2, "Could not repack hololib, reason: %v", err)
+		if jsonFlag {
+			body, err := json.MarshalIndent(report, "", "  ")
+			pretty.Guard(err == nil, 3, "Could not create json, reason: %v", err)
+			fmt.Println(string(body))
+			return
+		}
+		common.Log("Migrated %d loose blob(s). Now %d blob(s) in %d pack(s), reclaimed %s.", report.Migrated, report.Blobs, report.Packs, megabytes(report.Reclaimed))
+		pretty.Ok()
+	},
+}
+
+func init() {
+	holotreeCmd.AddCommand(holotreeRepackCmd)
+	holotreeRepackCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output in JSON format")
+}
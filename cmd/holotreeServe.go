@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pretty"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveListen   string
+	serveAccess   string
+	serveCert     string
+	serveKey      string
+	serveClientCA string
+	servePort     int
+	serveReadonly bool
+)
+
+var holotreeServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve local hololib catalogs and blobs as shared holotree for other machines.",
+	Long: `Serve local hololib catalogs and blobs as shared holotree for other machines.
+
+Access file (YAML) maps bearer tokens to identities, and identities to catalog
+read/write patterns. Identity can also come from client certificate common name,
+when --client-ca is given (mTLS). Example:
+
+  tokens:
+    secret-token-of-team-a: team-a
+  permissions:
+    team-a:
+      read: ["*"]
+      write: ["*.linux_amd64"]
+
+Clients configure "holotree/shared-server" in settings.yaml and give their
+token in RCC_HOLOTREE_TOKEN environment variable.
+
+Permissions are per catalog. Blob can be fetched only by identity that can
+read some catalog referring to that blob, and identity that can write any
+catalog can upload blobs (which are verified against their digest).
+
+With --readonly, no access file is needed, and anyone who can connect can
+fetch catalogs and blobs (GET /catalog/ lists catalogs, GET /catalog/<name>
+and GET /blob/<digest> fetch them), but nothing can be uploaded. Blobs are
+verified against their digest before serving. This turns build host into
+lightweight environment cache for other machines. With --port, server
+listens on all interfaces on that port.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Shared holotree server lasted").Report()
+		}
+		common.StopHeartbeat()
+		if servePort > 0 {
+			serveListen = fmt.Sprintf(":%d", servePort)
+		}
+		if serveReadonly {
+			common.Log("Read-only holotree server listening at %q.", serveListen)
+			err := htfs.ServeReadonly(serveListen)
+			pretty.Guard(err == nil, 1, "Error: %v", err)
+			return
+		}
+		pretty.Guard(len(serveAccess) > 0, 2, "Access control file (--access) is required, unless server is --readonly.")
+		common.Log("Shared holotree server listening at %q.", serveListen)
+		err := htfs.ServeShared(serveListen, serveAccess, serveCert, serveKey, serveClientCA)
+		pretty.Guard(err == nil, 1, "Error: %v", err)
+	},
+}
+
+func init() {
+	holotreeCmd.AddCommand(holotreeServeCmd)
+
+	holotreeServeCmd.Flags().StringVarP(&serveListen, "listen", "l", "127.0.0.1:4654", "Address (host:port) where shared holotree server listens.")
+	holotreeServeCmd.Flags().StringVarP(&serveAccess, "access", "a", "", "Access control file with tokens and permissions.")
+	holotreeServeCmd.Flags().StringVarP(&serveCert, "cert", "", "", "Server certificate (PEM) for TLS.")
+	holotreeServeCmd.Flags().StringVarP(&serveKey, "key", "", "", "Server private key (PEM) for TLS.")
+	holotreeServeCmd.Flags().StringVarP(&serveClientCA, "client-ca", "", "", "Certificate authority (PEM) for verifying client certificates (mTLS).")
+	holotreeServeCmd.Flags().IntVarP(&servePort, "port", "p", 0, "Port to listen on all interfaces (overrides --listen).")
+	holotreeServeCmd.Flags().BoolVarP(&serveReadonly, "readonly", "", false, "Serve catalogs and verified blobs to anyone, without access file, and refuse uploads.")
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"path/filepath"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/spf13/cobra"
+)
+
+var (
+	signKeygen string
+	signVerify bool
+)
+
+var holotreeSignCmd = &cobra.Command{
+	Use:   "sign [catalog...]",
+	Short: "Sign hololib catalogs with ed25519 key, or verify their signatures.",
+	Long: `Sign hololib catalogs with ed25519 key, or verify their signatures.
+
+Signing key is file given by "signing-key" under holotree in settings (or
+RCC_SIGNING_KEY environment variable), and new key can be created with
+--keygen. Signature is kept next to catalog, and goes with it in exported
+bundles. When "trusted-keys" are set in settings, import accepts only
+catalogs signed by one of those keys. Catalogs can be given as substrings of
+their names, and without them all catalogs are signed (or verified).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Holotree sign lasted").Report()
+		}
+		if len(signKeygen) > 0 {
+			public, err := htfs.GenerateSigningKey(signKeygen)
+			pretty.Guard(err == nil, 2, "Could not generate signing key, reason: %v", err)
+			common.Log("Signing key written to %q. Public key to add into trusted-keys is:", signKeygen)
+			common.Stdout("%s\n", public)
+			return
+		}
+		catalogs := htfs.Catalogs()
+		if len(args) > 0 {
+			catalogs = selectCatalogs(args)
+		}
+		pretty.Guard(len(catalogs) > 0, 3, "No catalogs selected.")
+		if signVerify {
+			pretty.Guard(htfs.SigningRequired(), 4, "There are no trusted-keys in settings to verify against.")
+			failures := 0
+			for _, catalog := range catalogs {
+				signer, err := htfs.VerifyCatalog(filepath.Join(common.HololibCatalogLocation(), catalog))
+				if err != nil {
+					failures += 1
+					common.Log("%s- %s: %v%s", pretty.Red, catalog, err, pretty.Reset)
+					continue
+				}
+				common.Log("- %s: signed by %s", catalog, signer)
+			}
+			pretty.Guard(failures == 0, 5, "%d catalog(s) failed signature verification.", failures)
+			pretty.Ok()
+			return
+		}
+		public, err := htfs.SignCatalogs(catalogs)
+		pretty.Guard(err == nil, 6, "Could not sign catalogs, reason: %v", err)
+		for _, catalog := range catalogs {
+			common.Log("- %s", catalog)
+		}
+		common.Log("Signed %d catalog(s) with key %s.", len(catalogs), public)
+		pretty.Ok()
+	},
+}
+
+func init() {
+	holotreeCmd.AddCommand(holotreeSignCmd)
+	holotreeSignCmd.Flags().StringVarP(&signKeygen, "keygen", "", "", "Generate new signing key into given file, and print its public key.")
+	holotreeSignCmd.Flags().BoolVarP(&signVerify, "verify", "", false, "Verify signatures of catalogs against trusted keys, instead of signing.")
+}
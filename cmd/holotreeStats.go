@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/spf13/cobra"
+)
+
+func catalogLocation(catalog string) string {
+	if pathlib.IsFile(catalog) {
+		return catalog
+	}
+	selected := selectCatalogs([]string{catalog})
+	pretty.Guard(len(selected) == 1, 1, "Catalog %q should match exactly one catalog, but matched %d.", catalog, len(selected))
+	return filepath.Join(common.HololibCatalogLocation(), selected[0])
+}
+
+func humaneCatalogStats(catalog string, stats *htfs.CatalogStats) {
+	common.Log("Catalog %q, blueprint %q, platform %q:", filepath.Base(catalog), stats.Blueprint, stats.Platform)
+	common.Log("- %d file(s), %d executable, %d link(s), %d dir(s)", stats.Files, stats.Executables, stats.Links, stats.Dirs)
+	common.Log("- total size %s, compressed in hololib %s (%d blobs, %d missing)", megabytes(stats.Total), megabytes(stats.Compressed), stats.Blobs, stats.Missing)
+	tabbed := tabwriter.NewWriter(os.Stderr, 2, 4, 2, ' ', 0)
+	tabbed.Write([]byte("Size\tLargest file\n"))
+	tabbed.Write([]byte("----\t------------\n"))
+	for _, file := range stats.Largest {
+		tabbed.Write([]byte(fmt.Sprintf("%s\t%s\n", megabytes(file.Size), file.Path)))
+	}
+	tabbed.Flush()
+}
+
+var holotreeStatsCmd = &cobra.Command{
+	Use:   "stats catalog",
+	Short: "Show size and file statistics of one holotree catalog.",
+	Long: `Show size and file statistics of one holotree catalog.
+
+Catalog can be given as substring of its name, or as path to catalog file.
+Without catalog, selectable catalogs are listed. Reports total size of files when restored, size of their blobs
+in hololib library, file counts, and largest files.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Holotree stats lasted").Report()
+		}
+		if len(args) == 0 {
+			listCatalogs(jsonFlag)
+			return
+		}
+		catalog := catalogLocation(args[0])
+		root, err := htfs.NewRoot(common.HolotreeLocation())
+		pretty.Guard(err == nil, 1, "Could not create root, reason: %v", err)
+		err = root.LoadFrom(catalog)
+		pretty.Guard(err == nil, 2, "Could not load catalog %q, reason: %v", catalog, err)
+		stats := root.Stats()
+		if jsonFlag {
+			body, err := json.MarshalIndent(stats, "", "  ")
+			pretty.Guard(err == nil, 3, "Could not create json, reason: %v", err)
+			fmt.Println(string(body))
+			return
+		}
+		humaneCatalogStats(catalog, stats)
+	},
+}
+
+func init() {
+	holotreeCmd.AddCommand(holotreeStatsCmd)
+	holotreeStatsCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output in JSON format")
+}
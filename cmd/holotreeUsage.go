@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/spf13/cobra"
+)
+
+func megabytes(size int64) string {
+	return fmt.Sprintf("%.1fM", float64(size)/(1024*1024))
+}
+
+func humaneUsageEntries(tabbed *tabwriter.Writer, title string, entries []*htfs.UsageEntry) {
+	tabbed.Write([]byte(fmt.Sprintf("%s\tFiles\tTotal\tUnique\tShared\tBlueprint\n", title)))
+	tabbed.Write([]byte(fmt.Sprintf("%s\t-----\t-----\t------\t------\t---------\n", strings.Repeat("-", len(title)))))
+	for _, entry := range entries {
+		data := fmt.Sprintf("%s\t%d\t%s\t%s\t%s\t%s\n", entry.Name, entry.Files, megabytes(entry.Total), megabytes(entry.Unique), megabytes(entry.Shared), entry.Blueprint)
+		tabbed.Write([]byte(data))
+	}
+	tabbed.Write([]byte("\n"))
+}
+
+func humaneHolotreeUsage(report *htfs.UsageReport) {
+	tabbed := tabwriter.NewWriter(os.Stderr, 2, 4, 2, ' ', 0)
+	humaneUsageEntries(tabbed, "Catalog", report.Catalogs)
+	humaneUsageEntries(tabbed, "Space", report.Spaces)
+	tabbed.Flush()
+	common.Log("Hololib library: %s (of which %s is not used by any catalog)", megabytes(report.Library), megabytes(report.Orphaned))
+	common.Log("Holotree spaces: %s", megabytes(report.Holotree))
+}
+
+var holotreeUsageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show disk usage attributed to holotree catalogs and spaces.",
+	Long: `Show disk usage attributed to holotree catalogs and spaces.
+
+Hololib blobs are shared between catalogs, so each catalog shows both
+unique bytes (freed if catalog was removed) and shared bytes (also used by
+other catalogs). Each blob is counted only once in library total.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Holotree usage lasted").Report()
+		}
+		report, err := htfs.DiskUsage()
+		pretty.Guard(err == nil, 1, "Could not measure holotree usage, reason: %v", err)
+		if jsonFlag {
+			body, err := json.MarshalIndent(report, "", "  ")
+			pretty.Guard(err == nil, 2, "Could not create json, reason: %v", err)
+			fmt.Println(string(body))
+		} else {
+			humaneHolotreeUsage(report)
+		}
+	},
+}
+
+func init() {
+	holotreeCmd.AddCommand(holotreeUsageCmd)
+	holotreeUsageCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output in JSON format")
+}
@@ -19,6 +19,9 @@ var (
 	holotreeBlueprint []byte
 	holotreeForce     bool
 	holotreeJson      bool
+	holotreeDryrun    bool
+	holotreeLockfile  string
+	variablesExport   string
 )
 
 func asSimpleMap(line string) map[string]string {
@@ -61,25 +64,90 @@ func asExportedText(items []string) {
 	}
 }
 
+func asExportFormat(format string, items []string) {
+	lines, skipped, err := operations.ExportVariables(format, items)
+	pretty.Guard(err == nil, 1, "%v", err)
+	for _, name := range skipped {
+		pretty.Warning("Variable %q cannot be expressed in %s format, left out.", name, format)
+	}
+	for _, line := range lines {
+		common.Stdout("%s\n", line)
+	}
+}
+
+// checkExportFlag fails early on unknown --export format, or when it is
+// combined with JSON output.
+func checkExportFlag(json bool) {
+	if len(variablesExport) == 0 {
+		return
+	}
+	pretty.Guard(!json, 1, "Options --export and --json cannot be used together.")
+	err := operations.ValidExportFormat(variablesExport)
+	pretty.Guard(err == nil, 1, "%v", err)
+}
+
+func holotreeRestorePlan(userFiles []string, packfile string) {
+	_, blueprint, err := htfs.ComposeFinalBlueprint(userFiles, packfile)
+	pretty.Guard(err == nil, 5, "%s", err)
+	condafile := filepath.Join(common.RobocorpTemp(), htfs.BlueprintHash(blueprint))
+	err = os.WriteFile(condafile, blueprint, 0o644)
+	pretty.Guard(err == nil, 6, "%s", err)
+	plan, err := htfs.PlanEnvironment(condafile)
+	pretty.Guard(err == nil, 7, "%s", err)
+	if holotreeJson {
+		content, err := operations.NiceJsonOutput(plan)
+		pretty.Guard(err == nil, 8, "%s", err)
+		common.Stdout("%s\n", content)
+		return
+	}
+	for _, fullpath := range plan.Added {
+		common.Stdout("add     %s\n", fullpath)
+	}
+	for _, fullpath := range plan.Replaced {
+		common.Stdout("replace %s\n", fullpath)
+	}
+	for _, fullpath := range plan.Removed {
+		common.Stdout("remove  %s\n", fullpath)
+	}
+	common.Log("Dry run of %q: %d to add, %d to replace, and %d to remove in %s.", plan.Blueprint, len(plan.Added), len(plan.Replaced), len(plan.Removed), plan.Path)
+}
+
 func holotreeExpandEnvironment(userFiles []string, packfile, environment, workspace string, validity int, force bool) []string {
+	env, code, err := holotreeEnvironment(userFiles, packfile, environment, workspace, validity, force)
+	pretty.Guard(err == nil, code, "%s", err)
+	return env
+}
+
+// holotreeEnvironment is like holotreeExpandEnvironment, but returns errors
+// (with exit codes) instead of exiting, so that callers can recover.
+func holotreeEnvironment(userFiles []string, packfile, environment, workspace string, validity int, force bool) ([]string, int, error) {
 	var extra []string
 	var data operations.Token
 	common.TimelineBegin("environment expansion start")
 	defer common.TimelineEnd()
 
 	config, holotreeBlueprint, err := htfs.ComposeFinalBlueprint(userFiles, packfile)
-	pretty.Guard(err == nil, 5, "%s", err)
+	if err != nil {
+		return nil, 5, err
+	}
 
 	condafile := filepath.Join(common.RobocorpTemp(), htfs.BlueprintHash(holotreeBlueprint))
 	err = os.WriteFile(condafile, holotreeBlueprint, 0o644)
-	pretty.Guard(err == nil, 6, "%s", err)
+	if err != nil {
+		return nil, 6, err
+	}
 
 	holozip := ""
 	if config != nil {
 		holozip = config.Holozip()
 	}
-	path, _, err := htfs.NewEnvironment(condafile, holozip, true, force)
-	pretty.Guard(err == nil, 6, "%s", err)
+	robotSettings := robot.SettingsOf(config)
+	// composed blueprint already has activation hooks from robot.yaml
+	robotSettings.PreActivate, robotSettings.PostActivate = nil, nil
+	path, _, err := htfs.NewEnvironment(condafile, holozip, true, force, robotSettings)
+	if err != nil {
+		return nil, common.ExitCodeOf(err, 6), err
+	}
 
 	if Has(environment) {
 		common.Timeline("load robot environment")
@@ -101,7 +169,9 @@ func holotreeExpandEnvironment(userFiles []string, packfile, environment, worksp
 		common.Timeline("get run robot claims")
 		claims := operations.RunRobotClaims(validity*60, workspace)
 		data, err = operations.AuthorizeClaims(AccountName(), claims)
-		pretty.Guard(err == nil, 9, "Failed to get cloud data, reason: %v", err)
+		if err != nil {
+			return nil, 9, fmt.Errorf("Failed to get cloud data, reason: %v", err)
+		}
 	}
 
 	if len(data) > 0 {
@@ -116,23 +186,49 @@ func holotreeExpandEnvironment(userFiles []string, packfile, environment, worksp
 		env = append(env, fmt.Sprintf("RC_WORKSPACE_ID=%s", workspaceId))
 	}
 
-	return env
+	return env, 0, nil
 }
 
 var holotreeVariablesCmd = &cobra.Command{
 	Use:     "variables conda.yaml+",
 	Aliases: []string{"vars"},
 	Short:   "Do holotree operations.",
-	Long:    "Do holotree operations.",
+	Long: `Do holotree operations.
+
+Builds (or restores) environment into holotree space, and prints its
+environment variables. With --export, variables are printed quoted for
+direct consumption: "bash" (for eval), "powershell" (for Invoke-Expression),
+"cmd" (for batch file to be called), "dotenv", or "github-actions" (for
+appending into $GITHUB_ENV). Variables that cannot be expressed in selected
+format are left out with warning.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if common.DebugFlag {
 			defer common.Stopwatch("Holotree variables command lasted").Report()
 		}
 
+		err := htfs.ValidCompression()
+		pretty.Guard(err == nil, 1, "%v", err)
+		err = htfs.ValidDigestAlgorithm()
+		pretty.Guard(err == nil, 1, "%v", err)
+
+		checkExportFlag(holotreeJson)
+
+		if len(holotreeLockfile) > 0 {
+			pretty.Guard(len(args) == 0, 1, "Give either conda.yaml file(s) or --lockfile, not both.")
+			args = []string{holotreeLockfile}
+		}
+		if holotreeDryrun {
+			holotreeRestorePlan(args, robotFile)
+			return
+		}
+
 		env := holotreeExpandEnvironment(args, robotFile, environmentFile, workspaceId, validityTime, holotreeForce)
-		if holotreeJson {
+		switch {
+		case holotreeJson:
 			asJson(env)
-		} else {
+		case len(variablesExport) > 0:
+			asExportFormat(variablesExport, env)
+		default:
 			asExportedText(env)
 		}
 	},
@@ -149,4 +245,10 @@ func init() {
 	holotreeVariablesCmd.Flags().StringVarP(&common.HolotreeSpace, "space", "s", "user", "Client specific name to identify this environment.")
 	holotreeVariablesCmd.Flags().BoolVarP(&holotreeForce, "force", "f", false, "Force environment creation with refresh.")
 	holotreeVariablesCmd.Flags().BoolVarP(&holotreeJson, "json", "j", false, "Show environment as JSON.")
+	holotreeVariablesCmd.Flags().StringVarP(&variablesExport, "export", "", "", "Show environment for direct consumption, in format: bash, powershell, cmd, dotenv, or github-actions. <optional>")
+	holotreeVariablesCmd.Flags().StringVarP(&holotreeLockfile, "lockfile", "", "", "Create environment from conda-lock or @EXPLICIT lockfile, without solving dependencies. <optional>")
+	holotreeVariablesCmd.Flags().BoolVarP(&holotreeDryrun, "dryrun", "", false, "Only show what restoring space would add, replace, or remove, without changing it. <optional>")
+	holotreeVariablesCmd.Flags().StringVarP(&common.BlobCompression, "compression", "", "", "Compression for new hololib blobs (gzip, zstd, or none). Default comes from settings. <optional>")
+	holotreeVariablesCmd.Flags().StringVarP(&common.BlobDigest, "digest", "", "", "Digest algorithm for new catalogs (sha256 or blake3). Default comes from settings. <optional>")
+	holotreeVariablesCmd.Flags().StringArrayVarP(&common.RecordExcludes, "exclude", "", []string{}, "Glob pattern of files or directories left out of recorded catalog. Can be given multiple times. <optional>")
 }
@@ -18,13 +18,20 @@ var identityCmd = &cobra.Command{
 	Short:   "Manage rcc instance identity related things.",
 	Long:    "Manage rcc instance identity related things.",
 	Run: func(cmd *cobra.Command, args []string) {
-		common.Stdout("rcc instance identity is: %v\n", xviper.TrackingIdentity())
 		if enableTracking {
 			xviper.ConsentTracking(true)
 		}
 		if doNotTrack {
 			xviper.ConsentTracking(false)
 		}
+		if jsonFlag {
+			printJson(1, map[string]interface{}{
+				"identity": xviper.TrackingIdentity(),
+				"tracking": xviper.CanTrack(),
+			})
+			return
+		}
+		common.Stdout("rcc instance identity is: %v\n", xviper.TrackingIdentity())
 		if xviper.CanTrack() {
 			common.Stdout("and anonymous health tracking is: enabled\n")
 		} else {
@@ -35,6 +42,7 @@ var identityCmd = &cobra.Command{
 
 func init() {
 	configureCmd.AddCommand(identityCmd)
+	identityCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output identity and tracking state in JSON format.")
 	identityCmd.Flags().BoolVarP(&doNotTrack, "do-not-track", "t", false, "Do not send application metrics. (opt-in)")
 	identityCmd.Flags().BoolVarP(&enableTracking, "enable", "e", false, "Enable sending application metrics. (opt-in)")
 }
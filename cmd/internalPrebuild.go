@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/operations"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/spf13/cobra"
+)
+
+var internalPrebuildCmd = &cobra.Command{
+	Use:   "prebuild <robot.yaml|conda.yaml>",
+	Short: "Build one prebuild target in its own stage slot.",
+	Long: `Build one prebuild target in its own stage slot, and output outcome as JSON.
+Used by "rcc holotree prebuild" to build environments concurrently.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		target := operations.PrebuildSlot(args[0], forceFlag)
+		printJson(2, target)
+		pretty.Guard(target.Status != "failed", 3, "%s", target.Problem)
+	},
+}
+
+func init() {
+	internalCmd.AddCommand(internalPrebuildCmd)
+	internalPrebuildCmd.Flags().IntVarP(&common.StageSlot, "slot", "", 0, "Stage slot to build in.")
+	internalPrebuildCmd.MarkFlagRequired("slot")
+	internalPrebuildCmd.Flags().BoolVarP(&forceFlag, "force", "f", false, "Force environment creation with refresh.")
+}
@@ -1,24 +1,80 @@
 package cmd
 
 import (
+	"encoding/json"
+	"fmt"
+
 	"github.com/robocorp/rcc/common"
 	"github.com/robocorp/rcc/conda"
 	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/wizard"
 	"github.com/spf13/cobra"
 )
 
 var (
 	enableLongpaths bool
+	fixLongpaths    bool
+	assumeYes       bool
 )
 
+func reportLongpaths(status *conda.LongpathStatus) {
+	if jsonFlag {
+		body, err := json.MarshalIndent(status, "", "  ")
+		pretty.Guard(err == nil, 5, "Could not create json, reason: %v", err)
+		fmt.Println(string(body))
+		return
+	}
+	common.Log("Platform: %s", status.Platform)
+	common.Log("Registry enabled: %v", status.Registry)
+	common.Log("Test path works: %v", status.Working)
+	common.Log("Elevated: %v", status.Elevated)
+	if fixLongpaths {
+		common.Log("Fixed: %v", status.Fixed)
+	}
+	common.Log("%s", status.Message)
+}
+
+func confirmLongpathsFix() bool {
+	if assumeYes || jsonFlag || !pretty.Interactive {
+		return true
+	}
+	yes, err := wizard.Confirm("Enable long path support in registry (may need administrator rights)", true)
+	return err == nil && yes
+}
+
 var longpathsCmd = &cobra.Command{
 	Use:   "longpaths",
 	Short: "Check and enable Windows longpath support",
-	Long:  "Check and enable Windows longpath support",
+	Long: `Check and enable Windows longpath support.
+
+With --fix, registry state is detected, fix is applied (with elevation request
+when not running as administrator), and result is verified using test path.
+With --json, status is reported in machine readable form.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if common.OverrideSystemRequirements() {
 			pretty.Exit(100, "This operation is prevented, because ROBOCORP_OVERRIDE_SYSTEM_REQUIREMENTS is effective!")
 		}
+		if fixLongpaths {
+			status := conda.LongpathDiagnosis()
+			var err error
+			if !status.Working {
+				if !confirmLongpathsFix() {
+					pretty.Exit(3, "Long path fix was cancelled.")
+				}
+				status, err = conda.FixLongpaths()
+			}
+			reportLongpaths(status)
+			if err != nil {
+				pretty.Exit(1, "Failure to modify registry: %v", err)
+			}
+			if !status.Working {
+				pretty.Exit(2, "Long paths do not work!")
+			}
+			if !jsonFlag {
+				pretty.Ok()
+			}
+			return
+		}
 		var err error
 		if enableLongpaths {
 			err = conda.EnforceLongpathSupport()
@@ -26,6 +82,14 @@ var longpathsCmd = &cobra.Command{
 		if err != nil {
 			pretty.Exit(1, "Failure to modify registry: %v", err)
 		}
+		if jsonFlag {
+			status := conda.LongpathDiagnosis()
+			reportLongpaths(status)
+			if !status.Working {
+				pretty.Exit(2, "Long paths do not work!")
+			}
+			return
+		}
 		if !conda.HasLongPathSupport() {
 			pretty.Exit(2, "Long paths do not work!")
 		}
@@ -36,4 +100,7 @@ var longpathsCmd = &cobra.Command{
 func init() {
 	configureCmd.AddCommand(longpathsCmd)
 	longpathsCmd.Flags().BoolVarP(&enableLongpaths, "enable", "e", false, "Change registry settings and enable longpath support")
+	longpathsCmd.Flags().BoolVarP(&fixLongpaths, "fix", "f", false, "Detect, fix (with elevation if needed), and verify longpath support")
+	longpathsCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask confirmation before fixing")
+	longpathsCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output in JSON format")
 }
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/operations"
+	"github.com/robocorp/rcc/pretty"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	profileUnsigned bool
+	profileSecrets  bool
+	profileInsecure bool
+	profileTrusted  []string
+)
+
+var machineExportCmd = &cobra.Command{
+	Use:   "export <profile.zip>",
+	Short: "Export machine profile (settings, certificates, micromamba) into signed archive.",
+	Long: `Export machine profile into single signed archive, to be imported on other
+machines with "rcc configure import".
+
+Archive contains settings.yaml (with its environment profiles), certificate
+bundle files those profiles refer to, and pinned micromamba version and
+binary. Archive is signed with configured signing key (RCC_SIGNING_KEY or
+signing-key in settings, see "rcc holotree sign --keygen"), unless
+--unsigned is given.
+
+Private keys in settings.yaml (signing-key, encryption-key, and client-key)
+are left out of archive, unless --include-secrets is given. Then anybody
+who can read archive can also read those keys.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		profile, err := operations.ExportMachineProfile(args[0], profileUnsigned, profileSecrets)
+		pretty.Guard(err == nil, 1, "Export failed, reason: %v", err)
+		if jsonFlag {
+			printJson(2, profile)
+			return
+		}
+		for _, file := range profile.Files {
+			common.Log("- %s", file.Name)
+		}
+		if len(profile.Micromamba) > 0 {
+			common.Log("- micromamba pin %s", profile.Micromamba)
+		}
+		if len(profile.Stripped) > 0 {
+			common.Log("Private keys (%s) were left out of settings, use --include-secrets to export them.", strings.Join(profile.Stripped, ", "))
+		}
+		if len(profile.Signer) > 0 {
+			common.Log("Machine profile %q is signed by %s.", args[0], profile.Signer)
+		} else {
+			pretty.Warning("Machine profile %q is not signed.", args[0])
+		}
+		pretty.Ok()
+	},
+}
+
+var machineImportCmd = &cobra.Command{
+	Use:   "import <profile.zip>",
+	Short: "Import machine profile made with \"rcc configure export\".",
+	Long: `Import machine profile made with "rcc configure export".
+
+Signature of archive is verified against trusted keys in current settings
+and keys given with --trust, and every file is verified against archive
+manifest before anything is changed. Then certificate bundles are written
+into ROBOCORP_HOME/certificates (and settings.yaml is updated to refer to
+them), settings.yaml is replaced (old one is kept as backup), micromamba
+version is pinned, and micromamba binary is installed, when archive was
+made on same platform. Binary must match micromamba sha256 digest pinned on
+this machine, or (only for archives with trusted signature) one recorded in
+archive. With --insecure, unverifiable binary is not installed at all.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result, err := operations.ImportMachineProfile(args[0], profileTrusted, profileInsecure)
+		pretty.Guard(err == nil, common.ExitCodeOf(err, 1), "Import failed, reason: %v", err)
+		if jsonFlag {
+			printJson(2, result)
+			return
+		}
+		if len(result.Signer) > 0 {
+			common.Log("Machine profile is signed by trusted key %s.", result.Signer)
+		}
+		if len(result.Settings) > 0 {
+			common.Log("Settings imported into %q.", result.Settings)
+		}
+		if len(result.Backup) > 0 {
+			common.Log("Previous settings saved as %q.", result.Backup)
+		}
+		for _, certificate := range result.Certificates {
+			common.Log("Certificate bundle imported into %q.", certificate)
+		}
+		if len(result.Micromamba) > 0 {
+			common.Log("Micromamba pinned to %s.", result.Micromamba)
+		}
+		if len(result.Binary) > 0 {
+			common.Log("Micromamba installed into %q.", result.Binary)
+		}
+		pretty.Ok()
+	},
+}
+
+func init() {
+	configureCmd.AddCommand(machineExportCmd)
+	configureCmd.AddCommand(machineImportCmd)
+	machineExportCmd.Flags().BoolVarP(&profileUnsigned, "unsigned", "", false, "Do not sign archive (not recommended).")
+	machineExportCmd.Flags().BoolVarP(&profileSecrets, "include-secrets", "", false, "Include private keys (signing-key, encryption-key, client-key) of settings into archive.")
+	machineExportCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Show exported manifest as JSON.")
+	machineImportCmd.Flags().StringArrayVarP(&profileTrusted, "trust", "", []string{}, "Public key trusted as signer of archive, in addition to trusted keys in settings. Can be given multiple times.")
+	machineImportCmd.Flags().BoolVarP(&profileInsecure, "insecure", "", false, "Import archive even when its signature cannot be verified (not recommended).")
+	machineImportCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Show import result as JSON.")
+}
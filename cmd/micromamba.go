@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/spf13/cobra"
+)
+
+var (
+	micromambaPin    string
+	micromambaDigest string
+	micromambaUnpin  bool
+	micromambaUpdate bool
+)
+
+var micromambaCmd = &cobra.Command{
+	Use:   "micromamba",
+	Short: "Show, pin, and update micromamba version used by rcc.",
+	Long: `Show, pin, and update micromamba version used by rcc.
+
+Without flags, shows which micromamba version is wanted and which is
+installed. With --pin, given version is used instead of one from settings
+(and --sha256 gives trusted digest of it for this platform), and with
+--update, wanted version is downloaded now, if installed one differs from it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if micromambaUnpin {
+			conda.PinMicromamba("", "")
+		}
+		pretty.Guard(len(micromambaDigest) == 0 || len(micromambaPin) > 0, 5, "Option --sha256 needs --pin.")
+		pretty.Guard(len(micromambaDigest) == 0 || conda.IsMicromambaDigest(strings.ToLower(micromambaDigest)), 5, "Option --sha256 must be sha256 digest in hex, not %q.", micromambaDigest)
+		if len(micromambaPin) > 0 {
+			conda.PinMicromamba(micromambaPin, micromambaDigest)
+			micromambaUpdate = true
+		}
+		if micromambaUpdate {
+			pretty.Guard(!conda.UsingSystemMicromamba(), 1, "Micromamba %q comes from PATH, and is not updated by rcc.", conda.BinMicromamba())
+			if !conda.HasMicroMamba() || conda.MicromambaOutdated() {
+				ok := conda.DoDownload(0) && conda.DoInstall() && conda.HasMicroMamba()
+				pretty.Guard(ok, 2, "Could not update micromamba to %s.", conda.MicromambaWanted())
+			}
+			pretty.Guard(!conda.MicromambaOutdated(), 3, "Downloaded micromamba is %q, but wanted version is %s.", conda.MicromambaVersion(), conda.MicromambaWanted())
+		}
+		if jsonFlag {
+			printJson(4, map[string]interface{}{
+				"wanted":     conda.MicromambaWanted(),
+				"pinned":     conda.MicromambaPinned(),
+				"installed":  conda.MicromambaVersion(),
+				"executable": conda.BinMicromamba(),
+				"from-path":  conda.UsingSystemMicromamba(),
+				"link":       conda.MicromambaLink(),
+				"sha256":     conda.MicromambaDigest(),
+			})
+			return
+		}
+		pinned := conda.MicromambaPinned()
+		if len(pinned) == 0 {
+			pinned = "-"
+		}
+		common.Log("Wanted version:    %s", conda.MicromambaWanted())
+		common.Log("Pinned version:    %s", pinned)
+		common.Log("Installed version: %s", conda.MicromambaVersion())
+		common.Log("Executable:        %s", conda.BinMicromamba())
+		common.Log("From PATH:         %v", conda.UsingSystemMicromamba())
+		common.Log("Download link:     %s", conda.MicromambaLink())
+		digest := conda.MicromambaDigest()
+		if len(digest) == 0 {
+			digest = "- (download is not verified against trusted digest)"
+		}
+		common.Log("Trusted sha256:    %s", digest)
+		pretty.Ok()
+	},
+}
+
+func init() {
+	configureCmd.AddCommand(micromambaCmd)
+	micromambaCmd.Flags().StringVarP(&micromambaPin, "pin", "", "", "Pin micromamba to this version (like v0.17.0), and download it now.")
+	micromambaCmd.Flags().StringVarP(&micromambaDigest, "sha256", "", "", "Trusted sha256 digest of pinned micromamba for this platform.")
+	micromambaCmd.Flags().BoolVarP(&micromambaUnpin, "unpin", "", false, "Remove pinned version, so that version from settings is used again.")
+	micromambaCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output micromamba versions in JSON format.")
+	micromambaCmd.Flags().BoolVarP(&micromambaUpdate, "update", "u", false, "Download wanted micromamba version now, if installed one differs.")
+}
@@ -45,17 +45,25 @@ func ExitProtection() {
 		exit, ok := status.(common.ExitCode)
 		if ok {
 			exit.ShowMessage()
+			code := common.CancelledExit(exit.Code)
+			cmd.Audit(code, false)
 			cloud.WaitTelemetry()
 			common.WaitLogs()
-			os.Exit(exit.Code)
+			os.Exit(code)
 		}
+		cmd.Audit(-1, true)
 		cloud.BackgroundMetric(common.ControllerIdentity(), "rcc.panic.origin", cmd.Origin())
 		cloud.WaitTelemetry()
 		common.WaitLogs()
 		panic(status)
 	}
+	code := common.CancelledExit(0)
+	cmd.Audit(code, false)
 	cloud.WaitTelemetry()
 	common.WaitLogs()
+	if code != 0 {
+		os.Exit(code)
+	}
 }
 
 func startTempRecycling() {
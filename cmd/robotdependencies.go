@@ -1,20 +1,68 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/robocorp/rcc/common"
 	"github.com/robocorp/rcc/conda"
 	"github.com/robocorp/rcc/operations"
 	"github.com/robocorp/rcc/pathlib"
 	"github.com/robocorp/rcc/pretty"
 	"github.com/robocorp/rcc/robot"
+	"github.com/robocorp/rcc/wizard"
 
 	"github.com/spf13/cobra"
 )
 
 var (
 	exportDependenciesFlag bool
+	updateDependenciesFlag bool
+	interactiveUpdateFlag  bool
 )
 
+func doUpdateDependencies() {
+	config, err := robot.LoadRobotYaml(robotFile, false)
+	pretty.Guard(err == nil, 4, "Error: %v", err)
+	pretty.Guard(config.UsesConda(), 5, "Robot %q has no conda.yaml to update.", robotFile)
+	condafile := config.CondaConfigFile()
+	environment, err := conda.ReadCondaYaml(condafile)
+	pretty.Guard(err == nil, 6, "Failed to read %q, reason: %v", condafile, err)
+	candidates, err := conda.FindUpdates(environment)
+	pretty.Guard(err == nil, 6, "Failed to check updates for %q, reason: %v", condafile, err)
+	if len(candidates) == 0 {
+		common.Log("All pinned dependencies in %q are up to date.", condafile)
+		pretty.Ok()
+		return
+	}
+	selected := make([]*conda.Candidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		common.Log("%s%s%s %s -> %s%s%s  %s", pretty.White, candidate.Name, pretty.Reset, candidate.Current, pretty.Green, candidate.Latest, pretty.Reset, candidate.Link)
+		if interactiveUpdateFlag {
+			yes, err := wizard.Confirm(fmt.Sprintf("Update %s to %s", candidate.Name, candidate.Latest), true)
+			pretty.Guard(err == nil, 7, "Error: %v", err)
+			if !yes {
+				continue
+			}
+		}
+		selected = append(selected, candidate)
+	}
+	if dryFlag {
+		common.Log("--")
+		common.Log("%sDry run, %d update(s) not applied to %q.%s", pretty.Yellow, len(selected), condafile, pretty.Reset)
+		pretty.Ok()
+		return
+	}
+	if !interactiveUpdateFlag {
+		pretty.Guard(common.AssumeYes || pretty.Interactive, 9, "Refusing to apply %d update(s) to %q without confirmation. Use --yes, --interactive or --dryrun.", len(selected), condafile)
+		confirmDestructive("Apply %d update(s) to %q?", len(selected), condafile)
+	}
+	err = conda.ApplyUpdates(condafile, selected)
+	pretty.Guard(err == nil, 8, "Failed to update %q, reason: %v", condafile, err)
+	common.Log("--")
+	common.Log("Applied %d update(s) to %q.", len(selected), condafile)
+	pretty.Ok()
+}
+
 func doShowDependencies(config robot.Robot, label string) {
 	filename, _ := config.DependenciesFile()
 	err := conda.SideBySideViewOfDependencies(conda.GoldenMasterFilename(label), filename)
@@ -42,6 +90,10 @@ var robotDependenciesCmd = &cobra.Command{
 		if common.DebugFlag {
 			defer common.Stopwatch("Robot dependencies run lasted").Report()
 		}
+		if updateDependenciesFlag {
+			doUpdateDependencies()
+			return
+		}
 		simple, config, _, label := operations.LoadAnyTaskEnvironment(robotFile, forceFlag)
 		pretty.Guard(!simple, 1, "Cannot view dependencies of simple robots.")
 		if exportDependenciesFlag {
@@ -57,6 +109,9 @@ var robotDependenciesCmd = &cobra.Command{
 func init() {
 	robotCmd.AddCommand(robotDependenciesCmd)
 	robotDependenciesCmd.Flags().BoolVarP(&exportDependenciesFlag, "export", "e", false, "Export execution environment description into robot dependencies.yaml, overwriting previous if exists.")
+	robotDependenciesCmd.Flags().BoolVarP(&updateDependenciesFlag, "update", "u", false, "Check channels and package index for newer versions of pinned dependencies, and update conda.yaml (needs --yes, --interactive or confirmation in terminal).")
+	robotDependenciesCmd.Flags().BoolVarP(&interactiveUpdateFlag, "interactive", "i", false, "Ask confirmation for each update separately (with --update).")
+	robotDependenciesCmd.Flags().BoolVarP(&dryFlag, "dryrun", "d", false, "Only show available updates, do not modify conda.yaml (with --update).")
 	robotDependenciesCmd.Flags().BoolVarP(&forceFlag, "force", "f", false, "Forced environment update.")
 	robotDependenciesCmd.Flags().StringVarP(&robotFile, "robot", "r", "robot.yaml", "Full path to the 'robot.yaml' configuration file.")
 	robotDependenciesCmd.Flags().StringVarP(&common.HolotreeSpace, "space", "s", "user", "Space to use for execution environment dependencies.")
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/robot"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	editSetMany    []string
+	editAddMany    []string
+	editRemoveMany []string
+)
+
+var robotEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit robot.yaml in place, keeping its comments and formatting.",
+	Long: `Edit robot.yaml in place, keeping its comments and formatting.
+
+Lists (PATH, PYTHONPATH, ignoreFiles, environmentConfigs) are edited with
+--add and --remove, and scalars (artifactsDir, condaConfigFile) with --set,
+all in key=value form, for example: --add PATH=bin --set artifactsDir=output`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if common.DebugFlag {
+			defer common.Stopwatch("Robot edit lasted").Report()
+		}
+		edits := &robot.Edits{
+			Dryrun: dryFlag,
+			Set:    editSetMany,
+			Add:    editAddMany,
+			Remove: editRemoveMany,
+		}
+		output, err := robot.EditRobotYaml(robotFile, edits)
+		if err != nil {
+			pretty.Exit(1, "Error: %v", err)
+		}
+		common.Stdout("%s", output)
+		pretty.Ok()
+	},
+}
+
+func init() {
+	robotCmd.AddCommand(robotEditCmd)
+	robotEditCmd.Flags().StringVarP(&robotFile, "robot", "", "robot.yaml", "Full path to the 'robot.yaml' configuration file to edit.")
+	robotEditCmd.Flags().StringArrayVarP(&editSetMany, "set", "s", []string{}, "Set scalar value, as key=value.")
+	robotEditCmd.Flags().StringArrayVarP(&editAddMany, "add", "a", []string{}, "Add value into list, as key=value.")
+	robotEditCmd.Flags().StringArrayVarP(&editRemoveMany, "remove", "r", []string{}, "Remove value from list, as key=value.")
+	robotEditCmd.Flags().BoolVarP(&dryFlag, "dryrun", "d", false, "Do not save the end result, just show what would happen.")
+}
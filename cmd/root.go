@@ -1,16 +1,21 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime/pprof"
 	"strings"
+	"time"
 
 	"github.com/robocorp/rcc/anywork"
 	"github.com/robocorp/rcc/common"
 	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/htfs"
 	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/plugins"
 	"github.com/robocorp/rcc/pretty"
 	"github.com/robocorp/rcc/xviper"
 
@@ -20,8 +25,24 @@ import (
 var (
 	profilefile string
 	profiling   *os.File
+	statsfile   string
 )
 
+// writeStats saves holotree telemetry counters of this run as JSON, so that
+// environment cache efficiency can be followed across machines.
+func writeStats() {
+	if len(statsfile) == 0 {
+		return
+	}
+	content, err := json.MarshalIndent(htfs.TelemetrySnapshot(), "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(statsfile, content, 0o644)
+	}
+	if err != nil {
+		common.Log("Could not write holotree stats to %q, reason: %v", statsfile, err)
+	}
+}
+
 func toplevelCommands(parent *cobra.Command) {
 	common.Log("\nToplevel commands")
 	for _, child := range parent.Commands() {
@@ -31,6 +52,39 @@ func toplevelCommands(parent *cobra.Command) {
 		name := strings.Split(child.Use, " ")
 		common.Log("| %s%-14s%s  %s", pretty.Cyan, name[0], pretty.Reset, child.Short)
 	}
+	found := plugins.Discover()
+	if len(found) > 0 {
+		common.Log("\nExternal plugins (from PATH)")
+		for _, name := range found {
+			common.Log("| %s%-14s%s  %s%s", pretty.Cyan, name, pretty.Reset, plugins.Prefix, name)
+		}
+	}
+}
+
+func isBuiltinCommand(name string) bool {
+	for _, child := range rootCmd.Commands() {
+		if child.Name() == name || child.HasAlias(name) {
+			return true
+		}
+	}
+	return name == "help" || name == "completion"
+}
+
+// runPlugin dispatches unknown toplevel command to external "rcc-<name>"
+// executable, git style, if one is found from PATH.
+func runPlugin(arguments []string) bool {
+	if len(arguments) == 0 || isBuiltinCommand(arguments[0]) {
+		return false
+	}
+	fullpath, ok := plugins.Find(arguments[0])
+	if !ok {
+		return false
+	}
+	code, err := plugins.Run(fullpath, arguments[1:])
+	if code != 0 {
+		pretty.Exit(code, "Error: plugin %q failed with exit code %d, reason: %v", arguments[0], code, err)
+	}
+	return true
 }
 
 func commandTree(level int, prefix string, parent *cobra.Command) {
@@ -75,6 +129,7 @@ func Origin() string {
 }
 
 func Execute() {
+	defer writeStats()
 	defer func() {
 		if profiling != nil {
 			common.Timeline("closing profiling started")
@@ -85,6 +140,11 @@ func Execute() {
 		}
 	}()
 
+	watchSignals()
+	if runPlugin(os.Args[1:]) {
+		return
+	}
+
 	rootCmd.SetArgs(os.Args[1:])
 	err := rootCmd.Execute()
 	pretty.Guard(err == nil, 1, "Error: [rcc %v] %v", common.Version, err)
@@ -96,7 +156,7 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&profilefile, "pprof", "", "Filename to save profiling information.")
 	rootCmd.PersistentFlags().StringVar(&common.ControllerType, "controller", "user", "internal, DO NOT USE (unless you know what you are doing)")
 	rootCmd.PersistentFlags().StringVar(&common.SemanticTag, "tag", "transient", "semantic reason/context, why are you invoking rcc")
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $ROBOCORP_HOME/rcc.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $ROBOCORP_HOME/rcc.yaml, or $ROBOCORP_WRITABLE_HOME/rcc.yaml)")
 
 	rootCmd.PersistentFlags().BoolVarP(&common.Silent, "silent", "", false, "be less verbose on output")
 	rootCmd.PersistentFlags().BoolVarP(&common.Liveonly, "liveonly", "", false, "do not create base environment from live ... DANGER! For containers only!")
@@ -109,9 +169,29 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&common.TraceFlag, "trace", "", false, "to get trace output where available (not for production use)")
 	rootCmd.PersistentFlags().BoolVarP(&common.TimelineEnabled, "timeline", "", false, "print timeline at the end of run")
 	rootCmd.PersistentFlags().BoolVarP(&common.StrictFlag, "strict", "", false, "be more strict on environment creation and handling")
+	rootCmd.PersistentFlags().BoolVarP(&common.OfflineFlag, "offline", "", false, "create environments only from local package caches and hololib catalogs, never from network")
+	rootCmd.PersistentFlags().BoolVarP(&common.RetryFailed, "retry-failed", "", false, "ignore remembered blueprint build failures and always try fresh build")
+	rootCmd.PersistentFlags().BoolVarP(&common.AssumeYes, "yes", "", false, "answer yes to confirmation questions of destructive commands (they are asked only when terminal is detected, so there is no separate --interactive flag)")
+	rootCmd.PersistentFlags().DurationVarP(&common.CommandTimeout, "timeout", "", 0, "cancel command (and its child processes) if it has not finished in this time, like 30m (0 means no timeout)")
+	rootCmd.PersistentFlags().IntVarP(&common.HeartbeatSeconds, "heartbeat", "", 60, "seconds of silence before status line is printed, when not attached to terminal (0 disables)")
+	rootCmd.PersistentFlags().IntVarP(&common.MicromambaTimeout, "micromamba-timeout", "", 0, "minutes before hung micromamba is killed (0 uses micromamba-timeout setting, which defaults to no timeout)")
+	rootCmd.PersistentFlags().IntVarP(&common.MicromambaRetries, "micromamba-retries", "", 0, "retries after micromamba timeout or transient download failure (0 uses micromamba-retries setting, which defaults to no retries)")
+	rootCmd.PersistentFlags().IntVarP(&common.IoLimit, "io-limit", "", 0, "limit holotree lift and restore disk reads to this many MB/s (0 uses io-limit setting, which defaults to unlimited)")
+	rootCmd.PersistentFlags().StringVarP(&statsfile, "stats-json", "", "", "write holotree telemetry counters (cache hits, lifted and decompressed bytes, repaired files) as JSON into this file")
+	rootCmd.PersistentFlags().BoolVarP(&common.ProgressFlag, "progress", "", false, "show progress of long running holotree lift and restore operations")
+	rootCmd.PersistentFlags().BoolVarP(&jsonFlag, "json", "", false, "output in JSON format, where command supports it (listing, inspection, and configuration commands)")
+	rootCmd.PersistentFlags().BoolVarP(&common.JsonProgressFlag, "json-progress", "", false, "stream environment build phases and holotree progress as JSON lines into stderr (for IDE integrations)")
 	rootCmd.PersistentFlags().IntVarP(&anywork.WorkerCount, "workers", "", 0, "scale background workers manually (do not use, unless you know what you are doing)")
 }
 
+func useConfigFile() {
+	if cfgFile != "" {
+		xviper.SetConfigFile(cfgFile)
+	} else {
+		xviper.SetConfigFile(filepath.Join(common.WritableHome(), "rcc.yaml"))
+	}
+}
+
 func initConfig() {
 	if profilefile != "" {
 		common.TimelineBegin("profiling run started")
@@ -121,16 +201,16 @@ func initConfig() {
 		pretty.Guard(err == nil, 6, "Failed to start CPU profile, reason %v.", err)
 		profiling = sink
 	}
-	if cfgFile != "" {
-		xviper.SetConfigFile(cfgFile)
-	} else {
-		xviper.SetConfigFile(filepath.Join(common.RobocorpHome(), "rcc.yaml"))
-	}
+	useConfigFile()
 
 	common.UnifyVerbosityFlags()
 	common.UnifyStageHandling()
+	startTimeout()
 
 	pretty.Setup()
+	if !pretty.Interactive {
+		common.StartHeartbeat(time.Duration(common.HeartbeatSeconds) * time.Second)
+	}
 	common.Timeline("%q", os.Args)
 	common.Trace("CLI command was: %#v", os.Args)
 	common.Debug("Using config file: %v", xviper.ConfigFileUsed())
@@ -1,9 +1,14 @@
 package cmd
 
 import (
+	"encoding/json"
+	"path/filepath"
+
 	"github.com/robocorp/rcc/cloud"
 	"github.com/robocorp/rcc/common"
 	"github.com/robocorp/rcc/operations"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/remote"
 	"github.com/robocorp/rcc/xviper"
 
 	"github.com/spf13/cobra"
@@ -13,6 +18,11 @@ var (
 	rcHosts         = []string{"RC_API_SECRET_HOST", "RC_API_WORKITEM_HOST"}
 	rcTokens        = []string{"RC_API_SECRET_TOKEN", "RC_API_WORKITEM_TOKEN"}
 	interactiveFlag bool
+	remoteAddress   string
+	allVariantsFlag bool
+	warmupFlag      bool
+	isolationLevel  string
+	allowedEnv      []string
 )
 
 var runCmd = &cobra.Command{
@@ -26,6 +36,15 @@ in your own machine.`,
 			defer common.Stopwatch("Task run lasted").Report()
 		}
 		defer xviper.RunMinutes().Done()
+		checkIsolationFlags()
+		if len(remoteAddress) > 0 {
+			remoteRun()
+			return
+		}
+		if allVariantsFlag {
+			runAllVariants(args)
+			return
+		}
 		simple, config, todo, label := operations.LoadTaskWithEnvironment(robotFile, runTask, forceFlag)
 		cloud.BackgroundMetric(common.ControllerIdentity(), "rcc.cli.run", common.Version)
 		commandline := todo.Commandline()
@@ -34,6 +53,34 @@ in your own machine.`,
 	},
 }
 
+func remoteRun() {
+	cloud.BackgroundMetric(common.ControllerIdentity(), "rcc.cli.run.remote", common.Version)
+	code, err := remote.Run(remoteAddress, robotFile, runTask, common.HolotreeSpace, forceFlag, ignores)
+	pretty.Guard(err == nil, 10, "Error: %v", err)
+	pretty.Guard(code == 0, 9, "Error: remote task failed with exit code %d.", code)
+	pretty.Ok()
+}
+
+func runAllVariants(args []string) {
+	cloud.BackgroundMetric(common.ControllerIdentity(), "rcc.cli.run.variants", common.Version)
+	matrix, config, err := operations.RunAllVariants(captureRunFlags(false), common.HolotreeSpace, forceFlag, args)
+	pretty.Guard(err == nil, 1, "Error: %v", err)
+	matrix.Print()
+	reportfile := filepath.Join(config.ArtifactDirectory(), "variants-report.json")
+	err = matrix.SaveAs(reportfile)
+	if err != nil {
+		pretty.Warning("Could not save %q, reason: %v", reportfile, err)
+	}
+	if jsonFlag {
+		body, err := json.MarshalIndent(matrix, "", "  ")
+		pretty.Guard(err == nil, 2, "Could not create json, reason: %v", err)
+		common.Stdout("%s\n", body)
+	}
+	failed := matrix.Failed()
+	pretty.Guard(failed == 0, 9, "Error: task failed in %d of %d variants.", failed, len(matrix.Results))
+	pretty.Ok()
+}
+
 func captureRunFlags(assistant bool) *operations.RunFlags {
 	return &operations.RunFlags{
 		AccountName:     AccountName(),
@@ -42,9 +89,25 @@ func captureRunFlags(assistant bool) *operations.RunFlags {
 		EnvironmentFile: environmentFile,
 		RobotYaml:       robotFile,
 		Assistant:       assistant,
+		TaskName:        runTask,
+		RunReport:       true,
+		ShowReport:      jsonFlag,
+		Warmup:          warmupFlag,
+		Isolation:       isolationLevel,
+		Allowed:         allowedEnv,
 	}
 }
 
+func checkIsolationFlags() {
+	err := operations.CheckIsolation(isolationLevel, allowedEnv)
+	pretty.Guard(err == nil, 1, "Error: %v", err)
+}
+
+func addIsolationFlags(command *cobra.Command) {
+	command.Flags().StringVarP(&isolationLevel, "isolation", "", operations.IsolationInherit, "Which environment variables of rcc task inherits: inherit (all), strict (only essential ones), or custom (essential ones and --allow-env patterns).")
+	command.Flags().StringArrayVarP(&allowedEnv, "allow-env", "", []string{}, "Environment variable name or pattern (like AWS_*) task inherits with --isolation=custom. Can be given multiple times.")
+}
+
 func init() {
 	taskCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(runCmd)
@@ -59,4 +122,11 @@ func init() {
 	runCmd.Flags().BoolVarP(&interactiveFlag, "interactive", "", false, "Allow robot to be interactive in terminal/command prompt. For development only, not for production!")
 	runCmd.Flags().StringVarP(&common.HolotreeSpace, "space", "s", "user", "Client specific name to identify this environment.")
 	runCmd.Flags().BoolVarP(&common.NoOutputCapture, "no-outputs", "", false, "Do not capture stderr/stdout into files.")
+	runCmd.Flags().StringVarP(&remoteAddress, "remote", "", "", "Run task on remote rcc daemon at given host:port instead of locally. OPTIONAL")
+	runCmd.Flags().StringArrayVarP(&ignores, "ignore", "i", []string{}, "File with ignore patterns, used when shipping robot to remote daemon.")
+	runCmd.Flags().StringVarP(&common.EnvironmentVariant, "variant", "", "", "Use this environment configuration variant (instead of default one) and its own artifacts directory.")
+	runCmd.Flags().BoolVarP(&allVariantsFlag, "all-variants", "", false, "Run task in every environment configuration variant of robot, and report pass/fail matrix.")
+	runCmd.Flags().BoolVarP(&warmupFlag, "warmup", "", false, "Warm-up run: after successful run, snapshot toolCaches of robot.yaml into holotree environment.")
+	runCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Print run report (also saved as run-report.json in artifacts) as JSON to stdout.")
+	addIsolationFlags(runCmd)
 }
@@ -15,17 +15,30 @@ var shellCmd = &cobra.Command{
 	Short:   "Run the given command inside the given environment",
 	Long: `Shell command executes the given command inside a managed virtual environment.
 It can be used to get inside a managed environment and execute your own
-command within that environment.`,
+command within that environment.
+
+Environment of robot.yaml is built (or restored) into holotree space first,
+and then platform shell (bash, or cmd.exe on Windows) is started with full
+activation applied. Prompt of that shell shows the space, like "(rcc user)",
+so it is easy to see when you are debugging inside robot environment. Exit
+shell to get back.`,
 	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		if common.DebugFlag {
 			defer common.Stopwatch("rcc shell lasted").Report()
 		}
+		checkIsolationFlags()
 		simple, config, todo, label := operations.LoadAnyTaskEnvironment(robotFile, forceFlag)
 		if simple {
 			pretty.Exit(1, "Cannot do shell for simple execution model.")
 		}
-		operations.ExecuteTask(captureRunFlags(false), conda.Shell, config, todo, label, true, nil)
+		flags := captureRunFlags(false)
+		flags.RunReport = false
+		prompt := map[string]string{
+			conda.ShellPromptVariable: conda.ShellPrompt(common.HolotreeSpace),
+		}
+		common.Log("%sEntering shell of space %q, exit shell to return.%s", pretty.Cyan, common.HolotreeSpace, pretty.Reset)
+		operations.ExecuteTask(flags, conda.Shell, config, todo, label, true, prompt)
 	},
 }
 
@@ -34,6 +47,8 @@ func init() {
 
 	shellCmd.Flags().StringVarP(&environmentFile, "environment", "e", "", "Full path to the 'env.json' development environment data file.")
 	shellCmd.Flags().StringVarP(&robotFile, "robot", "r", "robot.yaml", "Full path to the 'robot.yaml' configuration file.")
+	shellCmd.Flags().StringVarP(&common.HolotreeSpace, "space", "s", "user", "Client specific name to identify this environment.")
 	shellCmd.Flags().StringVarP(&runTask, "task", "t", "", "Task to configure shell from configuration file. <deprecated, non-functional>")
+	addIsolationFlags(shellCmd)
 	shellCmd.MarkFlagRequired("config")
 }
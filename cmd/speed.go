@@ -12,6 +12,7 @@ import (
 	"github.com/robocorp/rcc/common"
 	"github.com/robocorp/rcc/htfs"
 	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/robot"
 
 	"github.com/spf13/cobra"
 )
@@ -70,7 +71,7 @@ var speedtestCmd = &cobra.Command{
 			pretty.Exit(2, "Error: %v", err)
 		}
 		common.ForcedRobocorpHome = folder
-		_, score, err := htfs.NewEnvironment(condafile, "", true, true)
+		_, score, err := htfs.NewEnvironment(condafile, "", true, true, &robot.Settings{})
 		common.Silent, common.TraceFlag, common.DebugFlag = silent, trace, debug
 		common.UnifyVerbosityFlags()
 		if err != nil {
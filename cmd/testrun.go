@@ -27,6 +27,7 @@ var testrunCmd = &cobra.Command{
 			defer common.Stopwatch("Task testrun lasted").Report()
 		}
 		defer xviper.RunMinutes().Done()
+		checkIsolationFlags()
 		now := time.Now()
 		zipfile := filepath.Join(os.TempDir(), fmt.Sprintf("testrun%x.zip", common.When))
 		defer os.Remove(zipfile)
@@ -91,4 +92,5 @@ func init() {
 	testrunCmd.Flags().BoolVarP(&forceFlag, "force", "f", false, "Force conda cache update. (only for new environments)")
 	testrunCmd.Flags().StringVarP(&common.HolotreeSpace, "space", "s", "user", "Client specific name to identify this environment.")
 	testrunCmd.Flags().BoolVarP(&common.NoOutputCapture, "no-outputs", "", false, "Do not capture stderr/stdout into files.")
+	addIsolationFlags(testrunCmd)
 }
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate <conda.yaml+>",
+	Short: "Validate conda.yaml files before building environments from them.",
+	Long: `Validate conda.yaml files before building environments from them.
+
+Checks for unknown keys (typos), malformed version pins, pip syntax in conda
+dependencies (and conda syntax in pip ones), and channels micromamba cannot
+use. Problems are reported as file:line:column, and same check is also done
+automatically before environment is created. With --json, issues are written
+as JSON into stdout, keyed by filename.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		failures := 0
+		found := make(map[string]conda.SchemaIssues)
+		for _, filename := range args {
+			issues, err := conda.ReadCondaYamlIssues(filename)
+			pretty.Guard(err == nil, 1, "Could not read %q, reason: %v", filename, err)
+			failures += issues.Fatal()
+			if jsonFlag {
+				found[filename] = append(conda.SchemaIssues{}, issues...)
+				continue
+			}
+			for _, issue := range issues {
+				color := pretty.Yellow
+				if issue.Fatal {
+					color = pretty.Red
+				}
+				common.Stdout("%s%s%s\n", color, issue.Format(filename), pretty.Reset)
+			}
+		}
+		if jsonFlag {
+			printJson(3, found)
+		}
+		pretty.Guard(failures == 0, 2, "Found %d error(s) from conda.yaml file(s).", failures)
+		pretty.Ok()
+	},
+}
+
+func init() {
+	configureCmd.AddCommand(validateCmd)
+	validateCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output issues in JSON format.")
+}
@@ -13,6 +13,12 @@ var versionCmd = &cobra.Command{
 	Long:    `Show current version number of installed rcc.`,
 	Args:    cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
+		if jsonFlag {
+			printJson(1, map[string]string{
+				"version": common.Version,
+			})
+			return
+		}
 		common.Stdout("%s\n", common.Version)
 	},
 }
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/robocorp/rcc/anywork"
+)
+
+const (
+	// ChildGrace is how long child processes get to finish on their own
+	// after cancellation, before they are terminated, and after that, killed.
+	ChildGrace = 10 * time.Second
+	// ExitGrace is how long cancelled rcc waits for running operation to
+	// clean up and return, before it exits anyway.
+	ExitGrace = 45 * time.Second
+)
+
+var (
+	CommandTimeout time.Duration
+
+	cancelGuard   sync.Mutex
+	cancelContext context.Context
+	cancelFunc    context.CancelFunc
+	cancelCode    *ErrorCode
+	cancelReason  string
+)
+
+func init() {
+	cancelContext, cancelFunc = context.WithCancel(context.Background())
+}
+
+// Context is cancelled, when rcc is interrupted by signal or --timeout.
+// Long running operations (child processes, downloads, background work)
+// should stop when it is done.
+func Context() context.Context {
+	return cancelContext
+}
+
+// Cancel cancels Context, and stops background work that has not started
+// yet. Only first cancellation is recorded, later ones are ignored.
+func Cancel(code *ErrorCode, reason string) bool {
+	cancelGuard.Lock()
+	defer cancelGuard.Unlock()
+	if cancelCode != nil {
+		return false
+	}
+	cancelCode, cancelReason = code, reason
+	Timeline("cancelled: %s", reason)
+	anywork.Stop()
+	cancelFunc()
+	return true
+}
+
+// Cancellation tells why rcc was cancelled, or returns nil code, when it
+// was not.
+func Cancellation() (*ErrorCode, string) {
+	cancelGuard.Lock()
+	defer cancelGuard.Unlock()
+	return cancelCode, cancelReason
+}
+
+// CancelledExit tells why command was cancelled, and gives exit code of
+// cancellation instead of given exit code. Without cancellation, given exit
+// code is returned as is.
+func CancelledExit(exit int) int {
+	code, reason := Cancellation()
+	if code == nil {
+		return exit
+	}
+	Log("[%s] %s.", code.Code, reason)
+	return code.Exit
+}
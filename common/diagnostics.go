@@ -40,10 +40,11 @@ type DiagnosticCheck struct {
 	Status  string `json:"status"`
 	Message string `json:"message"`
 	Link    string `json:"url"`
+	Fix     string `json:"fix,omitempty"`
 }
 
 func (it *DiagnosticStatus) check(kind, status, message, link string) {
-	it.Checks = append(it.Checks, &DiagnosticCheck{kind, status, message, link})
+	it.Checks = append(it.Checks, &DiagnosticCheck{Type: kind, Status: status, Message: message, Link: link})
 }
 
 func (it *DiagnosticStatus) Diagnose(kind string) Diagnoser {
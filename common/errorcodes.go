@@ -0,0 +1,118 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrorCode is stable, documented identity of one failure kind, with exit
+// code rcc uses when that failure ends the command. See "rcc man exitcodes".
+type ErrorCode struct {
+	Code    string `json:"code"`
+	Exit    int    `json:"exit"`
+	Layer   string `json:"layer"`
+	Summary string `json:"summary"`
+}
+
+var (
+	errorCodes = make(map[string]*ErrorCode)
+
+	CodeRunHook         = newErrorCode("RCC-RUN-HOOK-001", 10, "cmd", "Robot run was blocked by pre-run hook.")
+	CodeEnvNetwork      = newErrorCode("RCC-ENV-NET-001", 21, "conda", "Environment resolver could not reach package channels (network or proxy).")
+	CodeEnvSolve        = newErrorCode("RCC-ENV-SOLVE-001", 22, "conda", "Environment resolver could not solve dependencies in conda.yaml.")
+	CodeEnvDisk         = newErrorCode("RCC-ENV-DISK-001", 23, "conda", "Environment build ran out of disk space or permissions.")
+	CodeEnvTimeout      = newErrorCode("RCC-ENV-TIMEOUT-001", 24, "conda", "Environment resolver timed out.")
+	CodeEnvResolver     = newErrorCode("RCC-ENV-SOLVE-002", 25, "conda", "Environment resolver failed for unclassified reason.")
+	CodeEnvPip          = newErrorCode("RCC-ENV-PIP-001", 26, "conda", "Installing pip dependencies failed.")
+	CodeEnvPipCheck     = newErrorCode("RCC-ENV-PIP-002", 27, "conda", "Pip check found broken dependencies in environment.")
+	CodeEnvValidation   = newErrorCode("RCC-ENV-VALID-001", 28, "conda", "Environment configuration or its validations failed.")
+	CodeEnvPolicy       = newErrorCode("RCC-ENV-POLICY-001", 29, "conda", "Environment violates configured package policy.")
+	CodeEnvNative       = newErrorCode("RCC-ENV-NATIVE-001", 30, "conda", "Native dependencies of environment could not be installed.")
+	CodeEnvPostInstall  = newErrorCode("RCC-ENV-POST-001", 31, "conda", "Post-install script of environment failed.")
+	CodeEnvCorrupted    = newErrorCode("RCC-ENV-CACHE-001", 32, "conda", "Package cache was corrupted and could not be repaired.")
+	CodeEnvSetup        = newErrorCode("RCC-ENV-SETUP-001", 33, "conda", "Environment build could not be set up (tools or files missing).")
+	CodeHoloSignature   = newErrorCode("RCC-HOLO-SIGN-001", 34, "htfs", "Catalog failed signature verification against trusted keys.")
+	CodeHoloLock        = newErrorCode("RCC-HOLO-LOCK-001", 35, "htfs", "Could not get holotree lock, another rcc is holding it.")
+	CodeHoloCatalog     = newErrorCode("RCC-HOLO-CATALOG-001", 36, "htfs", "Hololib does not have catalog needed for environment.")
+	CodeHoloRestoration = newErrorCode("RCC-HOLO-RESTORE-001", 37, "htfs", "Restoring environment from hololib into space failed.")
+	CodeCancelled       = newErrorCode("RCC-CMD-CANCEL-001", 38, "cmd", "Destructive operation was not confirmed, nothing was changed.")
+	CodeInterrupted     = newErrorCode("RCC-CMD-INTERRUPT-001", 39, "cmd", "Command was interrupted by signal (Ctrl-C or SIGTERM).")
+	CodeTimedOut        = newErrorCode("RCC-CMD-TIMEOUT-001", 40, "cmd", "Command did not finish within --timeout.")
+)
+
+func newErrorCode(code string, exit int, layer, summary string) *ErrorCode {
+	result := &ErrorCode{
+		Code:    code,
+		Exit:    exit,
+		Layer:   layer,
+		Summary: summary,
+	}
+	errorCodes[code] = result
+	return result
+}
+
+// ErrorCodes lists all known error codes, ordered by exit code.
+func ErrorCodes() []*ErrorCode {
+	result := make([]*ErrorCode, 0, len(errorCodes))
+	for _, code := range errorCodes {
+		result = append(result, code)
+	}
+	sort.SliceStable(result, func(left, right int) bool {
+		return result[left].Exit < result[right].Exit
+	})
+	return result
+}
+
+type codedFailure interface {
+	ErrorCode() *ErrorCode
+}
+
+// CodedError attaches error code to failure, and shows code in its message.
+type CodedError struct {
+	Code  *ErrorCode
+	Cause error
+}
+
+func (it *CodedError) Error() string {
+	return fmt.Sprintf("[%s] %v", it.Code.Code, it.Cause)
+}
+
+func (it *CodedError) Unwrap() error {
+	return it.Cause
+}
+
+func (it *CodedError) ErrorCode() *ErrorCode {
+	return it.Code
+}
+
+// WithCode attaches code to err, unless err is nil or already has a code.
+func WithCode(code *ErrorCode, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := CodeOf(err); ok {
+		return err
+	}
+	return &CodedError{Code: code, Cause: err}
+}
+
+// CodeOf finds first error code from err chain.
+func CodeOf(err error) (*ErrorCode, bool) {
+	var coded codedFailure
+	if err == nil || !errors.As(err, &coded) {
+		return nil, false
+	}
+	code := coded.ErrorCode()
+	return code, code != nil
+}
+
+// ExitCodeOf returns exit code of error code in err chain, or given
+// fallback code, when err has no error code.
+func ExitCodeOf(err error, fallback int) int {
+	code, ok := CodeOf(err)
+	if !ok {
+		return fallback
+	}
+	return code.Exit
+}
@@ -0,0 +1,42 @@
+package common_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestErrorCodesAreUniqueAndOrdered(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	codes := common.ErrorCodes()
+	wont_be.Equal(0, len(codes))
+	names := make(map[string]bool)
+	exits := make(map[int]bool)
+	previous := 0
+	for _, code := range codes {
+		wont_be.True(names[code.Code])
+		wont_be.True(exits[code.Exit])
+		must_be.True(code.Exit > previous)
+		names[code.Code] = true
+		exits[code.Exit] = true
+		previous = code.Exit
+	}
+}
+
+func TestCodedErrorsCarryExitCodesThroughWrapping(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	must_be.Nil(common.WithCode(common.CodeHoloLock, nil))
+	coded := common.WithCode(common.CodeHoloLock, errors.New("busy"))
+	must_be.Equal("[RCC-HOLO-LOCK-001] busy", coded.Error())
+	wrapped := fmt.Errorf("Failed, reason %w.", coded)
+	must_be.Equal(35, common.ExitCodeOf(wrapped, 1))
+	must_be.Equal(coded, common.WithCode(common.CodeHoloCatalog, coded))
+	must_be.Equal(1, common.ExitCodeOf(errors.New("plain"), 1))
+	_, ok := common.CodeOf(errors.New("plain"))
+	wont_be.True(ok)
+}
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+var (
+	HeartbeatSeconds int
+	lastActivity     int64
+	heartbeatStopped int32
+	currentPhase     atomic.Value
+)
+
+func markActivity() {
+	atomic.StoreInt64(&lastActivity, time.Now().UnixNano())
+}
+
+func markPhase(phase string) {
+	currentPhase.Store(phase)
+}
+
+func quietFor() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&lastActivity)))
+}
+
+func phase() string {
+	value, ok := currentPhase.Load().(string)
+	if !ok || len(value) == 0 {
+		return "startup"
+	}
+	return value
+}
+
+// StartHeartbeat emits status line, whenever nothing has been logged during
+// given interval, so that CI systems with inactivity timeouts do not kill
+// long, but legitimate, quiet phases (like solver, downloads, or restores).
+func StartHeartbeat(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	markActivity()
+	go heartbeatLoop(interval)
+}
+
+func heartbeatLoop(interval time.Duration) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		if atomic.LoadInt32(&heartbeatStopped) > 0 {
+			return
+		}
+		if quietFor() < interval {
+			continue
+		}
+		markActivity()
+		Log("####  Heartbeat: %ss elapsed, still working on: %s", Clock.Elapsed(), phase())
+	}
+}
+
+// StopHeartbeat is for long running services, which are quiet by design.
+func StopHeartbeat() {
+	atomic.StoreInt32(&heartbeatStopped, 1)
+}
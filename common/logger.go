@@ -35,6 +35,7 @@ func loggerLoop(writers logwriters) {
 		}
 		fmt.Fprintf(out, "%s%s\n", stamp, message)
 		out.Sync()
+		markActivity()
 		logbarrier.Done()
 	}
 }
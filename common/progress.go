@@ -0,0 +1,38 @@
+package common
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+const (
+	EventPhaseStart = `phase-start`
+	EventPhaseDone  = `phase-done`
+	EventProgress   = `progress`
+)
+
+// ProgressEvent is one JSON line streamed with --json-progress, so that
+// IDEs and other tools can show what long environment build is doing.
+type ProgressEvent struct {
+	Event   string      `json:"event"`
+	Phase   string      `json:"phase"`
+	Message string      `json:"message,omitempty"`
+	Elapsed float64     `json:"elapsed"`
+	When    string      `json:"when"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// EmitProgress writes event as single JSON line into stderr, when JSON
+// progress is requested. Events share log ordering with normal log lines.
+func EmitProgress(event *ProgressEvent) {
+	if !JsonProgressFlag || event == nil {
+		return
+	}
+	event.When = time.Now().Format(time.RFC3339Nano)
+	body, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	printout(os.Stderr, string(body))
+}
@@ -72,7 +72,9 @@ func IgnoreAllPanics() {
 
 func Timeline(form string, details ...interface{}) {
 	defer IgnoreAllPanics()
-	pipe <- fmt.Sprintf(form, details...)
+	message := fmt.Sprintf(form, details...)
+	markPhase(message)
+	pipe <- message
 }
 
 func TimelineBegin(form string, details ...interface{}) {
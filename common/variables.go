@@ -14,6 +14,8 @@ const (
 	ROBOCORP_HOME_VARIABLE                = `ROBOCORP_HOME`
 	VERBOSE_ENVIRONMENT_BUILDING          = `RCC_VERBOSE_ENVIRONMENT_BUILDING`
 	ROBOCORP_OVERRIDE_SYSTEM_REQUIREMENTS = `ROBOCORP_OVERRIDE_SYSTEM_REQUIREMENTS`
+	ROBOCORP_SYSTEM_HOLOLIB               = `ROBOCORP_SYSTEM_HOLOLIB`
+	ROBOCORP_WRITABLE_HOME                = `ROBOCORP_WRITABLE_HOME`
 )
 
 var (
@@ -21,13 +23,26 @@ var (
 	DebugFlag          bool
 	TraceFlag          bool
 	StrictFlag         bool
+	OfflineFlag        bool
+	RetryFailed        bool
+	AssumeYes          bool
 	LogLinenumbers     bool
 	NoCache            bool
 	NoOutputCapture    bool
+	ProgressFlag       bool
+	JsonProgressFlag   bool
+	IoLimit            int
+	MicromambaTimeout  int
+	MicromambaRetries  int
 	Liveonly           bool
 	StageFolder        string
+	StageSlot          int
 	ControllerType     string
 	HolotreeSpace      string
+	EnvironmentVariant string
+	BlobCompression    string
+	BlobDigest         string
+	RecordExcludes     []string
 	EnvironmentHash    string
 	SemanticTag        string
 	ForcedRobocorpHome string
@@ -45,9 +60,11 @@ func init() {
 	randomIdentifier = fmt.Sprintf("%016x", rand.Uint64()^uint64(os.Getpid()))
 	ensureDirectory(TemplateLocation())
 	ensureDirectory(BinLocation())
+	if !SplitHome() {
+		ensureDirectory(HololibCatalogLocation())
+		ensureDirectory(HololibLibraryLocation())
+	}
 	ensureDirectory(HolotreeLocation())
-	ensureDirectory(HololibCatalogLocation())
-	ensureDirectory(HololibLibraryLocation())
 	ensureDirectory(PipCache())
 	ensureDirectory(WheelCache())
 	ensureDirectory(RobotCache())
@@ -65,8 +82,26 @@ func RobocorpHome() string {
 	return ExpandPath(defaultRobocorpLocation)
 }
 
+// WritableHome is where rcc keeps its mutable state (spaces, temp, caches,
+// journals, and locks). By default it is ROBOCORP_HOME, but it can be split
+// away, so that ROBOCORP_HOME (with pre-seeded hololib) can be read-only.
+func WritableHome() string {
+	home := os.Getenv(ROBOCORP_WRITABLE_HOME)
+	if len(home) > 0 {
+		return ExpandPath(home)
+	}
+	return RobocorpHome()
+}
+
+func SplitHome() bool {
+	return WritableHome() != RobocorpHome()
+}
+
 func RobocorpLock() string {
-	return filepath.Join(RobocorpHome(), "robocorp.lck")
+	if StageSlot > 0 {
+		return filepath.Join(WritableHome(), fmt.Sprintf("robocorp.slot%d.lck", StageSlot))
+	}
+	return filepath.Join(WritableHome(), "robocorp.lck")
 }
 
 func VerboseEnvironmentBuilding() bool {
@@ -86,15 +121,19 @@ func BinRcc() string {
 }
 
 func EventJournal() string {
-	return filepath.Join(RobocorpHome(), "event.log")
+	return filepath.Join(WritableHome(), "event.log")
+}
+
+func AuditLog() string {
+	return filepath.Join(WritableHome(), "audit.log")
 }
 
 func TemplateLocation() string {
-	return filepath.Join(RobocorpHome(), "templates")
+	return filepath.Join(WritableHome(), "templates")
 }
 
 func RobocorpTempRoot() string {
-	return filepath.Join(RobocorpHome(), "temp")
+	return filepath.Join(WritableHome(), "temp")
 }
 
 func RobocorpTemp() string {
@@ -111,7 +150,7 @@ func RobocorpTemp() string {
 }
 
 func BinLocation() string {
-	return filepath.Join(RobocorpHome(), "bin")
+	return filepath.Join(WritableHome(), "bin")
 }
 
 func HololibLocation() string {
@@ -122,6 +161,20 @@ func HololibCatalogLocation() string {
 	return filepath.Join(HololibLocation(), "catalog")
 }
 
+// HololibLockLocation and HololibStagingLocation are written even when
+// hololib itself is only read, so they live in writable home.
+func HololibLockLocation() string {
+	return filepath.Join(WritableHome(), "hololib", "locks")
+}
+
+func HololibPackLocation() string {
+	return filepath.Join(HololibLocation(), "packs")
+}
+
+func HololibStagingLocation() string {
+	return filepath.Join(WritableHome(), "hololib", "staging")
+}
+
 func HololibLibraryLocation() string {
 	return filepath.Join(HololibLocation(), "library")
 }
@@ -130,8 +183,22 @@ func HolotreeLock() string {
 	return fmt.Sprintf("%s.lck", HolotreeLocation())
 }
 
+// StageLock is lock held while environment is built into holotree stage.
+// Stage slots have their own locks, so that environments can be built
+// concurrently in separate processes, and default stage uses holotree lock.
+func StageLock() string {
+	if StageSlot > 0 {
+		return fmt.Sprintf("%s.slot%d.lck", HolotreeLocation(), StageSlot)
+	}
+	return HolotreeLock()
+}
+
 func HolotreeLocation() string {
-	return filepath.Join(RobocorpHome(), "holotree")
+	return filepath.Join(WritableHome(), "holotree")
+}
+
+func BlueprintFailureLocation() string {
+	return filepath.Join(HolotreeLocation(), "failures")
 }
 
 func UsesHolotree() bool {
@@ -139,19 +206,19 @@ func UsesHolotree() bool {
 }
 
 func PipCache() string {
-	return filepath.Join(RobocorpHome(), "pipcache")
+	return filepath.Join(WritableHome(), "pipcache")
 }
 
 func WheelCache() string {
-	return filepath.Join(RobocorpHome(), "wheels")
+	return filepath.Join(WritableHome(), "wheels")
 }
 
 func RobotCache() string {
-	return filepath.Join(RobocorpHome(), "robots")
+	return filepath.Join(WritableHome(), "robots")
 }
 
 func MambaPackages() string {
-	return ExpandPath(filepath.Join(RobocorpHome(), "pkgs"))
+	return ExpandPath(filepath.Join(WritableHome(), "pkgs"))
 }
 
 func UnifyVerbosityFlags() {
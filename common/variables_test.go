@@ -0,0 +1,33 @@
+package common_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestCanSplitWritableHomeAway(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	original := os.Getenv(common.ROBOCORP_WRITABLE_HOME)
+	defer os.Setenv(common.ROBOCORP_WRITABLE_HOME, original)
+
+	os.Setenv(common.ROBOCORP_WRITABLE_HOME, "")
+	wont_be.True(common.SplitHome())
+	must_be.Equal(common.RobocorpHome(), common.WritableHome())
+
+	writable := filepath.Join(os.TempDir(), "rcc_writable_home")
+	os.Setenv(common.ROBOCORP_WRITABLE_HOME, writable)
+	must_be.True(common.SplitHome())
+	must_be.Equal(writable, common.WritableHome())
+	must_be.True(strings.HasPrefix(common.HolotreeLocation(), writable))
+	must_be.True(strings.HasPrefix(common.RobocorpTempRoot(), writable))
+	must_be.True(strings.HasPrefix(common.EventJournal(), writable))
+	must_be.True(strings.HasPrefix(common.RobocorpLock(), writable))
+	must_be.True(strings.HasPrefix(common.HololibLocation(), common.RobocorpHome()))
+	wont_be.True(strings.HasPrefix(common.HololibLocation(), writable))
+}
@@ -1,5 +1,5 @@
 package common
 
 const (
-	Version = `v11.5.0`
+	Version = `v11.105.0`
 )
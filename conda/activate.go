@@ -4,11 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"html/template"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"text/template"
 
 	"github.com/robocorp/rcc/common"
 )
@@ -46,19 +46,34 @@ func capturePreformatted(incoming string) ([]string, string) {
 	return result, strings.Join(other, "")
 }
 
-func createScript(targetFolder string) (string, error) {
+// ActivationHooks are script snippets from conda.yaml and robot.yaml, which
+// are put into activation script, before and after micromamba activation.
+type ActivationHooks struct {
+	Pre  []string
+	Post []string
+}
+
+func createScript(targetFolder string, hooks *ActivationHooks) (string, error) {
 	script := template.New("script")
 	script, err := script.Parse(activateScript)
 	if err != nil {
 		return "", err
 	}
-	details := make(map[string]string)
+	if hooks == nil {
+		hooks = &ActivationHooks{}
+	}
+	details := make(map[string]interface{})
 	details["Rcc"] = common.BinRcc()
 	details["Robocorphome"] = common.RobocorpHome()
 	details["Micromamba"] = BinMicromamba()
 	details["Live"] = targetFolder
+	details["PreActivate"] = hooks.Pre
+	details["PostActivate"] = hooks.Post
 	buffer := bytes.NewBuffer(nil)
-	script.Execute(buffer, details)
+	err = script.Execute(buffer, details)
+	if err != nil {
+		return "", err
+	}
 
 	scriptfile := filepath.Join(targetFolder, fmt.Sprintf("rcc_activate%s", commandSuffix))
 	err = ioutil.WriteFile(scriptfile, buffer.Bytes(), 0o755)
@@ -97,7 +112,7 @@ func diffStringMaps(before, after map[string]string) map[string]string {
 	return result
 }
 
-func Activate(sink *os.File, targetFolder string) error {
+func Activate(sink *os.File, targetFolder string, hooks *ActivationHooks) error {
 	envCommand := []string{common.BinRcc(), "internal", "env", "--label", "before"}
 	out, _, err := LiveCapture(targetFolder, envCommand...)
 	if err != nil {
@@ -112,7 +127,7 @@ func Activate(sink *os.File, targetFolder string) error {
 		return err
 	}
 
-	script, err := createScript(targetFolder)
+	script, err := createScript(targetFolder, hooks)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,61 @@
+package conda
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestActivationScriptHasHooksInOrder(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	folder, err := ioutil.TempDir("", "activate")
+	must_be.Nil(err)
+	defer os.RemoveAll(folder)
+
+	hooks := &ActivationHooks{
+		Pre:  []string{"export VENDOR_HOME=\"/opt/vendor & co\""},
+		Post: []string{"source \"$VENDOR_HOME/env.sh\"", "echo done"},
+	}
+	scriptfile, err := createScript(folder, hooks)
+	must_be.Nil(err)
+	body, err := ioutil.ReadFile(scriptfile)
+	must_be.Nil(err)
+	script := string(body)
+
+	pre := strings.Index(script, hooks.Pre[0])
+	activate := strings.Index(script, "activate")
+	post := strings.Index(script, hooks.Post[0])
+	done := strings.Index(script, hooks.Post[1])
+	after := strings.Index(script, "internal env -l after")
+	wont_be.Equal(-1, pre)
+	must_be.True(pre < activate)
+	must_be.True(activate < post)
+	must_be.True(post < done)
+	must_be.True(done < after)
+
+	plain, err := createScript(folder, nil)
+	must_be.Nil(err)
+	body, err = ioutil.ReadFile(plain)
+	must_be.Nil(err)
+	wont_be.True(strings.Contains(string(body), "VENDOR_HOME"))
+}
+
+func TestActivationHooksAreMergedInOrder(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	left := &Environment{PreActivate: []string{"first"}, PostActivate: []string{"fi"}}
+	right := &Environment{PreActivate: []string{"second"}, PostActivate: []string{"fi"}}
+	merged, err := left.Merge(right)
+	must_be.Nil(err)
+	must_be.Equal([]string{"first", "second"}, merged.PreActivate)
+	must_be.Equal([]string{"fi", "fi"}, merged.PostActivate)
+
+	content, err := merged.AsYaml()
+	must_be.Nil(err)
+	must_be.True(strings.Contains(content, "rccPreActivate:\n- first\n- second\n"))
+	must_be.Equal(0, len(CondaYamlIssues([]byte(content))))
+}
@@ -0,0 +1,133 @@
+package conda
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/shell"
+)
+
+// PlannedPackage is conda package, which build would either download or
+// take from package cache.
+type PlannedPackage struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+}
+
+// BuildPlan tells what building environment would do, without doing it.
+// Estimate is in seconds, and basis tells where it came from.
+type BuildPlan struct {
+	Blueprint     string            `json:"blueprint"`
+	Hololib       bool              `json:"hololib"`
+	CondaLayer    bool              `json:"conda-layer"`
+	Solver        string            `json:"solver"`
+	Download      []*PlannedPackage `json:"download"`
+	Cached        []*PlannedPackage `json:"cached"`
+	DownloadBytes int64             `json:"download-bytes"`
+	Linked        int               `json:"linked"`
+	Pip           []string          `json:"pip"`
+	Estimate      float64           `json:"estimate"`
+	Basis         string            `json:"basis"`
+}
+
+func newBuildPlan(blueprint string) *BuildPlan {
+	return &BuildPlan{
+		Blueprint: blueprint,
+		Solver:    SolverName(""),
+		Download:  []*PlannedPackage{},
+		Cached:    []*PlannedPackage{},
+		Pip:       []string{},
+	}
+}
+
+// HololibPlan is plan for environment, which is already in hololib, so
+// that only restore is needed.
+func HololibPlan(blueprint string) *BuildPlan {
+	plan := newBuildPlan(blueprint)
+	plan.Hololib = true
+	plan.Basis = "already in hololib, only restore is needed"
+	return plan
+}
+
+func plannedPackage(entry *Fetchable) *PlannedPackage {
+	return &PlannedPackage{
+		Name:     entry.Name,
+		Version:  entry.Version,
+		Filename: entry.Filename,
+		Size:     entry.Size,
+	}
+}
+
+func (it *BuildPlan) estimate() {
+	timings := LoadBuildTimings()
+	it.Estimate = timings.Estimate(len(it.Download), it.Linked, len(it.Pip))
+	if timings.Builds > 0 {
+		it.Basis = fmt.Sprintf("timings of %d earlier build(s) on this machine", timings.Builds)
+	} else {
+		it.Basis = "default timings, since there are no earlier builds on this machine"
+	}
+}
+
+// PlanBuild solves (as dry run) environment from identity file, and tells
+// which packages would be downloaded, and which are already in package cache,
+// and how long build would probably take. Nothing is installed.
+func PlanBuild(layers Layers, blueprint string, force bool, identity string) (plan *BuildPlan, err error) {
+	defer fail.Around(&err)
+
+	condaYaml := filepath.Join(os.TempDir(), fmt.Sprintf("buildplan_%x.yaml", common.When))
+	requirementsText := filepath.Join(os.TempDir(), fmt.Sprintf("buildplan_%x.txt", common.When))
+	defer os.Remove(condaYaml)
+	defer os.Remove(requirementsText)
+	_, _, finalEnv, err := temporaryConfig(condaYaml, requirementsText, true, identity)
+	fail.On(err != nil, "%v", err)
+
+	plan = newBuildPlan(blueprint)
+	for _, dependency := range finalEnv.Pip {
+		plan.Pip = append(plan.Pip, dependency.Original)
+	}
+	if layer := newCondaLayer(layers, condaYaml, requirementsText); layer != nil && !force {
+		plan.CondaLayer = layers.HasLayer(layer.blueprint)
+	}
+	if !plan.CondaLayer {
+		resolver, err := MustResolver("")
+		fail.On(err != nil, "%v", err)
+		plan.Solver = resolver.Name()
+		prefix := filepath.Join(common.RobocorpTemp(), fmt.Sprintf("buildplan_%x", common.When))
+		command, err := resolver.DryrunCommand(finalEnv, condaYaml, prefix, force)
+		fail.On(err != nil, "%v", err)
+		output, code, err := shell.New(resolver.Environment(), ".", command...).CaptureOutput()
+		fail.On(err != nil || code != 0, "Resolving conda packages failed [%d], reason: %v\n%s", code, err, output)
+		dryrun := &dryrunPlan{}
+		err = json.Unmarshal([]byte(output), dryrun)
+		fail.On(err != nil, "Could not parse %s plan, reason: %v", resolver.Name(), err)
+		plan.addPackages(PackagesCache(), dryrun)
+	}
+	plan.estimate()
+	return plan, nil
+}
+
+// addPackages sorts linked packages into ones to download and ones already
+// in package cache.
+func (it *BuildPlan) addPackages(cache string, dryrun *dryrunPlan) {
+	fetching := make(map[string]*Fetchable)
+	for _, entry := range dryrun.Actions.Fetch {
+		fetching[entry.Filename] = entry
+	}
+	it.Linked = len(dryrun.Actions.Link)
+	for _, entry := range dryrun.Actions.Link {
+		fetch, ok := fetching[entry.Filename]
+		if ok && !pathlib.IsFile(filepath.Join(cache, entry.Filename)) {
+			it.Download = append(it.Download, plannedPackage(fetch))
+			it.DownloadBytes += fetch.Size
+			continue
+		}
+		it.Cached = append(it.Cached, plannedPackage(entry))
+	}
+}
@@ -0,0 +1,54 @@
+package conda
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestBuildPlanSeparatesDownloadsFromCachedPackages(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	cache := t.TempDir()
+	must_be.Nil(ioutil.WriteFile(filepath.Join(cache, "pip-22.1.2-pyhd8ed1ab_0.tar.bz2"), []byte{}, 0o644))
+
+	dryrun := &dryrunPlan{}
+	dryrun.Actions.Fetch = []*Fetchable{
+		&Fetchable{Name: "python", Version: "3.9.13", Filename: "python-3.9.13-h2660328_0_cpython.tar.bz2", Size: 30000000},
+		&Fetchable{Name: "pip", Version: "22.1.2", Filename: "pip-22.1.2-pyhd8ed1ab_0.tar.bz2", Size: 1500000},
+	}
+	dryrun.Actions.Link = []*Fetchable{
+		&Fetchable{Name: "python", Version: "3.9.13", Filename: "python-3.9.13-h2660328_0_cpython.tar.bz2"},
+		&Fetchable{Name: "pip", Version: "22.1.2", Filename: "pip-22.1.2-pyhd8ed1ab_0.tar.bz2"},
+		&Fetchable{Name: "zlib", Version: "1.2.13", Filename: "zlib-1.2.13-h166bdaf_4.tar.bz2"},
+	}
+
+	plan := newBuildPlan("0123456789abcdef")
+	plan.addPackages(cache, dryrun)
+	must_be.Equal(3, plan.Linked)
+	must_be.Equal(1, len(plan.Download))
+	must_be.Equal("python", plan.Download[0].Name)
+	must_be.Equal(int64(30000000), plan.DownloadBytes)
+	must_be.Equal(2, len(plan.Cached))
+	must_be.Equal("pip", plan.Cached[0].Name)
+	must_be.Equal("zlib", plan.Cached[1].Name)
+
+	warm := HololibPlan("0123456789abcdef")
+	must_be.True(warm.Hololib)
+	must_be.Equal(0.0, warm.Estimate)
+}
+
+func TestBuildTimingsEstimateFromRates(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	defaults := &BuildTimings{}
+	must_be.Equal(10*defaultFetchRate+20*defaultLinkRate+5*defaultPipRate+25*defaultRecordRate, defaults.Estimate(10, 20, 5))
+
+	learned := &BuildTimings{Builds: 3, FetchRate: 2.0, LinkRate: 0.5, PipRate: 4.0, RecordRate: 0.2}
+	must_be.Equal(2*2.0+4*0.5+1*4.0+5*0.2, learned.Estimate(2, 4, 1))
+
+	must_be.Equal(4.0, movingAverage(0, 4.0))
+	must_be.Equal(1.0+timingWeight*(4.0-1.0), movingAverage(1.0, 4.0))
+}
@@ -0,0 +1,159 @@
+package conda
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+const (
+	timingWeight = 0.3
+
+	defaultFetchRate  = 1.0
+	defaultLinkRate   = 0.1
+	defaultPipRate    = 2.0
+	defaultRecordRate = 0.1
+)
+
+// BuildTimings are moving averages from earlier environment builds on this
+// machine, and they are used to estimate durations of new builds. All rates
+// are seconds per package (or per pip requirement).
+type BuildTimings struct {
+	Builds     int     `json:"builds"`
+	FetchRate  float64 `json:"fetch-rate"`
+	LinkRate   float64 `json:"link-rate"`
+	PipRate    float64 `json:"pip-rate"`
+	RecordRate float64 `json:"record-rate"`
+}
+
+func buildTimingsFile() string {
+	return filepath.Join(common.HolotreeLocation(), "buildtimes.json")
+}
+
+// LoadBuildTimings returns timings of earlier builds, where rates without
+// any observations are zero.
+func LoadBuildTimings() *BuildTimings {
+	result := &BuildTimings{}
+	content, err := ioutil.ReadFile(buildTimingsFile())
+	if err == nil {
+		err = json.Unmarshal(content, result)
+	}
+	if err != nil {
+		return &BuildTimings{}
+	}
+	return result
+}
+
+func rateOr(rate, fallback float64) float64 {
+	if rate > 0 {
+		return rate
+	}
+	return fallback
+}
+
+// Estimate tells how many seconds build would take, when given numbers of
+// packages are downloaded and linked, and pip requirements are installed.
+func (it *BuildTimings) Estimate(fetched, linked, requirements int) float64 {
+	seconds := float64(fetched) * rateOr(it.FetchRate, defaultFetchRate)
+	seconds += float64(linked) * rateOr(it.LinkRate, defaultLinkRate)
+	seconds += float64(requirements) * rateOr(it.PipRate, defaultPipRate)
+	seconds += float64(linked+requirements) * rateOr(it.RecordRate, defaultRecordRate)
+	return seconds
+}
+
+func (it *BuildTimings) save() {
+	content, err := json.MarshalIndent(it, "", "  ")
+	if err != nil {
+		return
+	}
+	filename := buildTimingsFile()
+	_, err = pathlib.EnsureParentDirectory(filename)
+	if err == nil {
+		err = ioutil.WriteFile(filename, content, 0o644)
+	}
+	if err != nil {
+		common.Debug("Could not save build timings %q, reason: %v", filename, err)
+	}
+}
+
+func movingAverage(previous, sample float64) float64 {
+	if previous <= 0 {
+		return sample
+	}
+	return previous + timingWeight*(sample-previous)
+}
+
+func updateTimings(update func(*BuildTimings)) {
+	timings := LoadBuildTimings()
+	update(timings)
+	timings.save()
+}
+
+// ObserveResolver learns from successful resolver run. Runs without
+// downloads teach link rate, and other runs teach fetch rate.
+func ObserveResolver(elapsed time.Duration, fetched, linked int) {
+	if linked == 0 {
+		return
+	}
+	updateTimings(func(timings *BuildTimings) {
+		seconds := elapsed.Seconds()
+		if fetched == 0 {
+			timings.LinkRate = movingAverage(timings.LinkRate, seconds/float64(linked))
+		} else {
+			remaining := seconds - float64(linked)*rateOr(timings.LinkRate, defaultLinkRate)
+			if remaining < 0 {
+				remaining = 0
+			}
+			timings.FetchRate = movingAverage(timings.FetchRate, remaining/float64(fetched))
+		}
+		timings.Builds += 1
+	})
+}
+
+// ObservePip learns from successful pip install.
+func ObservePip(elapsed time.Duration, requirements int) {
+	if requirements == 0 {
+		return
+	}
+	updateTimings(func(timings *BuildTimings) {
+		timings.PipRate = movingAverage(timings.PipRate, elapsed.Seconds()/float64(requirements))
+	})
+}
+
+// ObserveRecording learns from recording of stage into hololib, relative to
+// number of packages in stage.
+func ObserveRecording(elapsed time.Duration, stage string) {
+	packages := len(LoadWantedDependencies(GoldenMasterFilename(stage)))
+	if packages == 0 {
+		return
+	}
+	updateTimings(func(timings *BuildTimings) {
+		timings.RecordRate = movingAverage(timings.RecordRate, elapsed.Seconds()/float64(packages))
+	})
+}
+
+// pipRequirements lists requirement lines of requirements file.
+func pipRequirements(requirementsText string) []string {
+	result := []string{}
+	reader, err := os.Open(requirementsText)
+	if err != nil {
+		return result
+	}
+	defer reader.Close()
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") {
+			continue
+		}
+		result = append(result, line)
+	}
+	return result
+}
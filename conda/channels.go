@@ -0,0 +1,131 @@
+package conda
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/robocorp/rcc/keyring"
+	"github.com/robocorp/rcc/settings"
+)
+
+// ConfiguredChannels tells if settings override channels of conda.yaml.
+func ConfiguredChannels() bool {
+	return len(settings.Global.CondaChannels()) > 0
+}
+
+func channelToken(channel *settings.CondaChannel) (string, error) {
+	return secretToken(fmt.Sprintf("channel %q", channel.Channel), channel.TokenEnv, channel.Keyring)
+}
+
+// secretToken reads token from environment variable, or when that is not
+// set, from keyring. Environment variable wins, when both are given.
+func secretToken(owner, tokenEnv, service string) (string, error) {
+	if len(tokenEnv) > 0 {
+		token, ok := os.LookupEnv(tokenEnv)
+		if ok && len(strings.TrimSpace(token)) > 0 {
+			return strings.TrimSpace(token), nil
+		}
+		if len(service) == 0 {
+			return "", fmt.Errorf("Environment variable %q for %s is not set.", tokenEnv, owner)
+		}
+	}
+	if len(service) > 0 {
+		token, err := keyring.Secret(service)
+		if err != nil {
+			return "", fmt.Errorf("Keyring %q for %s failed, reason: %v", service, owner, err)
+		}
+		return strings.TrimSpace(token), nil
+	}
+	return "", nil
+}
+
+// authenticatedChannel adds token into channel URL, either as basic auth
+// password (when there is username) or as conda token ("/t/<token>/" path).
+func authenticatedChannel(channel *settings.CondaChannel) (string, error) {
+	name := strings.TrimSpace(channel.Channel)
+	if len(name) == 0 {
+		return "", fmt.Errorf("Conda channel entry without channel in settings.")
+	}
+	token, err := channelToken(channel)
+	if err != nil {
+		return "", err
+	}
+	if len(token) == 0 {
+		return name, nil
+	}
+	link, err := url.Parse(name)
+	if err != nil || len(link.Host) == 0 {
+		return "", fmt.Errorf("Channel %q has token, but it is not URL.", name)
+	}
+	if len(channel.Username) > 0 {
+		link.User = url.UserPassword(channel.Username, token)
+		return link.String(), nil
+	}
+	link.Path = fmt.Sprintf("/t/%s%s", url.PathEscape(token), link.Path)
+	return link.String(), nil
+}
+
+// channelName removes credentials (user info and "/t/<token>" path prefix)
+// from channel link, so that it can be shown and logged.
+func channelName(link string) string {
+	parsed, err := url.Parse(link)
+	if err != nil || len(parsed.Scheme) == 0 {
+		return link
+	}
+	parsed.User = nil
+	if strings.HasPrefix(parsed.Path, "/t/") {
+		parts := strings.SplitN(parsed.Path[len("/t/"):], "/", 2)
+		parsed.Path = "/"
+		if len(parts) == 2 {
+			parsed.Path += parts[1]
+		}
+		parsed.RawPath = ""
+	}
+	return parsed.String()
+}
+
+// ChannelLinks returns channels from settings, in priority order, with their
+// tokens applied.
+func ChannelLinks() ([]string, error) {
+	return channelLinks(settings.Global.CondaChannels())
+}
+
+// MirrorChannelLinks returns mirror channels from settings, in priority
+// order, with their tokens applied. These replace all channels, when
+// downloading from primary channels fails.
+func MirrorChannelLinks() ([]string, error) {
+	return channelLinks(settings.Global.MirrorChannels())
+}
+
+func channelLinks(channels []*settings.CondaChannel) ([]string, error) {
+	result := make([]string, 0, len(channels))
+	for _, channel := range channels {
+		link, err := authenticatedChannel(channel)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, link)
+	}
+	return result, nil
+}
+
+// ChannelNames returns channels from settings, without tokens, so that they
+// can be shown and logged.
+func ChannelNames() []string {
+	return channelNames(settings.Global.CondaChannels())
+}
+
+// MirrorChannelNames returns mirror channels from settings, without tokens.
+func MirrorChannelNames() []string {
+	return channelNames(settings.Global.MirrorChannels())
+}
+
+func channelNames(channels []*settings.CondaChannel) []string {
+	result := make([]string, 0, len(channels))
+	for _, channel := range channels {
+		result = append(result, strings.TrimSpace(channel.Channel))
+	}
+	return result
+}
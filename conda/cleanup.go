@@ -30,67 +30,70 @@ func safeRemove(hint, pathling string) error {
 	return err
 }
 
-func doCleanup(fullpath string, dryrun bool) error {
-	if !pathlib.Exists(fullpath) {
+// CleanupReport tells which paths and holotree spaces cleanup removed, or on
+// dry run, would remove.
+type CleanupReport struct {
+	Dryrun  bool     `json:"dryrun"`
+	Removed []string `json:"removed"`
+	Spaces  []string `json:"spaces"`
+	Pinned  []string `json:"pinned"`
+	Kept    []string `json:"kept"`
+}
+
+func newCleanupReport(dryrun bool) *CleanupReport {
+	return &CleanupReport{
+		Dryrun:  dryrun,
+		Removed: []string{},
+		Spaces:  []string{},
+		Pinned:  []string{},
+		Kept:    []string{},
+	}
+}
+
+func (it *CleanupReport) remove(hint, pathling string) error {
+	if !pathlib.Exists(pathling) {
+		common.Debug("[%s] Missing %v, not need to remove.", hint, pathling)
 		return nil
 	}
-	if dryrun {
-		common.Log("Would be removing: %s", fullpath)
+	if it.Dryrun {
+		common.Log("Would remove %s", pathling)
+		it.Removed = append(it.Removed, pathling)
 		return nil
 	}
-	return safeRemove("path", fullpath)
+	err := safeRemove(hint, pathling)
+	if err == nil {
+		it.Removed = append(it.Removed, pathling)
+	}
+	return err
 }
 
-func alwaysCleanup(dryrun bool) {
-	base := filepath.Join(common.RobocorpHome(), "base")
-	live := filepath.Join(common.RobocorpHome(), "live")
-	miniconda3 := filepath.Join(common.RobocorpHome(), "miniconda3")
-	if dryrun {
-		common.Log("Would be removing:")
-		common.Log("- %v", base)
-		common.Log("- %v", live)
-		common.Log("- %v", miniconda3)
-		return
-	}
-	safeRemove("legacy", base)
-	safeRemove("legacy", live)
-	safeRemove("legacy", miniconda3)
+func alwaysCleanup(report *CleanupReport) {
+	report.remove("legacy", filepath.Join(common.RobocorpHome(), "base"))
+	report.remove("legacy", filepath.Join(common.RobocorpHome(), "live"))
+	report.remove("legacy", filepath.Join(common.RobocorpHome(), "miniconda3"))
 }
 
-func quickCleanup(dryrun bool) error {
-	if dryrun {
-		common.Log("- %v", common.TemplateLocation())
-		common.Log("- %v", common.PipCache())
-		common.Log("- %v", common.HolotreeLocation())
-		common.Log("- %v", common.RobocorpTempRoot())
-		return nil
-	}
-	safeRemove("templates", common.TemplateLocation())
-	safeRemove("cache", common.PipCache())
-	err := safeRemove("cache", common.HolotreeLocation())
+func quickCleanup(report *CleanupReport) error {
+	report.remove("templates", common.TemplateLocation())
+	report.remove("cache", common.PipCache())
+	err := report.remove("cache", common.HolotreeLocation())
 	if err != nil {
 		return err
 	}
-	return safeRemove("temp", common.RobocorpTempRoot())
+	return report.remove("temp", common.RobocorpTempRoot())
 }
 
-func spotlessCleanup(dryrun bool) error {
-	err := quickCleanup(dryrun)
+func spotlessCleanup(report *CleanupReport) error {
+	err := quickCleanup(report)
 	if err != nil {
 		return err
 	}
-	if dryrun {
-		common.Log("- %v", common.MambaPackages())
-		common.Log("- %v", BinMicromamba())
-		common.Log("- %v", common.HololibLocation())
-		return nil
-	}
-	safeRemove("cache", common.MambaPackages())
-	safeRemove("executable", BinMicromamba())
-	return safeRemove("cache", common.HololibLocation())
+	report.remove("cache", common.MambaPackages())
+	report.remove("executable", downloadedMicromamba())
+	return report.remove("cache", common.HololibLocation())
 }
 
-func cleanupTemp(deadline time.Time, dryrun bool) error {
+func cleanupTemp(deadline time.Time, report *CleanupReport) error {
 	basedir := common.RobocorpTempRoot()
 	handle, err := os.Open(basedir)
 	if err != nil {
@@ -106,7 +109,8 @@ func cleanupTemp(deadline time.Time, dryrun bool) error {
 			continue
 		}
 		fullpath := filepath.Join(basedir, entry.Name())
-		if dryrun {
+		report.Removed = append(report.Removed, fullpath)
+		if report.Dryrun {
 			common.Log("Would remove temp %v.", fullpath)
 			continue
 		}
@@ -123,33 +127,45 @@ func cleanupTemp(deadline time.Time, dryrun bool) error {
 	return nil
 }
 
-func Cleanup(daylimit int, dryrun, quick, all, micromamba bool) error {
+// SpaceCleaner removes holotree spaces not used for days, and spaces of
+// controllers not seen for orphans days, and adds them into report. It comes
+// from holotree, which depends on this package.
+type SpaceCleaner func(days, orphans int, report *CleanupReport) error
+
+// Cleanup removes old environments, caches, and temporary files, and reports
+// what was removed (or on dry run, what would be removed).
+func Cleanup(daylimit, orphans int, dryrun, quick, all, micromamba bool, spaces SpaceCleaner) (*CleanupReport, error) {
+	report := newCleanupReport(dryrun)
 	lockfile := common.RobocorpLock()
 	locker, err := pathlib.Locker(lockfile, 30000)
 	if err != nil {
 		common.Log("Could not get lock on live environment. Quitting!")
-		return err
+		return report, err
 	}
 	defer locker.Release()
 
-	alwaysCleanup(dryrun)
+	alwaysCleanup(report)
 
 	if quick {
-		return quickCleanup(dryrun)
+		return report, quickCleanup(report)
 	}
 
 	if all {
-		return spotlessCleanup(dryrun)
+		return report, spotlessCleanup(report)
 	}
 
 	deadline := time.Now().Add(-48 * time.Duration(daylimit) * time.Hour)
-	cleanupTemp(deadline, dryrun)
+	cleanupTemp(deadline, report)
+
+	if spaces != nil {
+		err = spaces(daylimit, orphans, report)
+	}
 
 	if micromamba && err == nil {
-		err = doCleanup(common.MambaPackages(), dryrun)
+		err = report.remove("path", common.MambaPackages())
 	}
 	if micromamba && err == nil {
-		err = doCleanup(BinMicromamba(), dryrun)
+		err = report.remove("path", downloadedMicromamba())
 	}
-	return err
+	return report, err
 }
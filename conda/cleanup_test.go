@@ -0,0 +1,35 @@
+package conda
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+func TestCleanupReportOnDryrunKeepsPaths(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	folder := t.TempDir()
+	existing := filepath.Join(folder, "existing")
+	missing := filepath.Join(folder, "missing")
+	must_be.Nil(os.Mkdir(existing, 0o755))
+
+	report := newCleanupReport(true)
+	must_be.Nil(report.remove("test", existing))
+	must_be.Nil(report.remove("test", missing))
+	must_be.Equal([]string{existing}, report.Removed)
+	must_be.True(pathlib.IsDir(existing))
+
+	body, err := json.Marshal(newCleanupReport(true))
+	must_be.Nil(err)
+	must_be.Equal(`{"dryrun":true,"removed":[],"spaces":[],"pinned":[],"kept":[]}`, string(body))
+
+	report = newCleanupReport(false)
+	must_be.Nil(report.remove("test", existing))
+	must_be.Equal([]string{existing}, report.Removed)
+	wont_be.True(pathlib.Exists(existing))
+}
@@ -22,15 +22,21 @@ type internalEnvironment struct {
 	Dependencies []interface{} `yaml:"dependencies"`
 	Prefix       string        `yaml:"prefix,omitempty"`
 	PostInstall  []string      `yaml:"rccPostInstall,omitempty"`
+	Validate     []string      `yaml:"rccValidate,omitempty"`
+	PreActivate  []string      `yaml:"rccPreActivate,omitempty"`
+	PostActivate []string      `yaml:"rccPostActivate,omitempty"`
 }
 
 type Environment struct {
-	Name        string
-	Prefix      string
-	Channels    []string
-	Conda       []*Dependency
-	Pip         []*Dependency
-	PostInstall []string
+	Name         string
+	Prefix       string
+	Channels     []string
+	Conda        []*Dependency
+	Pip          []*Dependency
+	PostInstall  []string
+	Validate     []string
+	PreActivate  []string
+	PostActivate []string
 }
 
 type Dependency struct {
@@ -101,9 +107,14 @@ func (it *internalEnvironment) AsEnvironment() *Environment {
 		Name:        it.Name,
 		Prefix:      it.Prefix,
 		PostInstall: []string{},
+		Validate:    []string{},
 	}
 	seenScripts := make(map[string]bool)
 	result.PostInstall = addItem(seenScripts, it.PostInstall, result.PostInstall)
+	seenValidations := make(map[string]bool)
+	result.Validate = addItem(seenValidations, it.Validate, result.Validate)
+	result.PreActivate = append([]string{}, it.PreActivate...)
+	result.PostActivate = append([]string{}, it.PostActivate...)
 	channel, ok := LocalChannel()
 	if ok {
 		pushChannels(result, []string{channel})
@@ -136,12 +147,15 @@ func SummonEnvironment(filename string) *Environment {
 
 func (it *Environment) FreezeDependencies(fixed dependencies) *Environment {
 	result := &Environment{
-		Name:        it.Name,
-		Prefix:      it.Prefix,
-		Channels:    it.Channels,
-		Conda:       []*Dependency{},
-		Pip:         []*Dependency{},
-		PostInstall: it.PostInstall,
+		Name:         it.Name,
+		Prefix:       it.Prefix,
+		Channels:     it.Channels,
+		Conda:        []*Dependency{},
+		Pip:          []*Dependency{},
+		PostInstall:  it.PostInstall,
+		Validate:     it.Validate,
+		PreActivate:  it.PreActivate,
+		PostActivate: it.PostActivate,
 	}
 	used := make(map[string]bool)
 	for _, dependency := range fixed {
@@ -179,12 +193,15 @@ func (it *Environment) FreezeDependencies(fixed dependencies) *Environment {
 
 func (it *Environment) FromDependencies(fixed dependencies) (*Environment, bool) {
 	result := &Environment{
-		Name:        it.Name,
-		Prefix:      it.Prefix,
-		Channels:    it.Channels,
-		Conda:       []*Dependency{},
-		Pip:         []*Dependency{},
-		PostInstall: it.PostInstall,
+		Name:         it.Name,
+		Prefix:       it.Prefix,
+		Channels:     it.Channels,
+		Conda:        []*Dependency{},
+		Pip:          []*Dependency{},
+		PostInstall:  it.PostInstall,
+		Validate:     it.Validate,
+		PreActivate:  it.PreActivate,
+		PostActivate: it.PostActivate,
 	}
 	same := true
 	for _, dependency := range it.Conda {
@@ -326,6 +343,14 @@ func (it *Environment) Merge(right *Environment) (*Environment, error) {
 	result.PostInstall = addItem(seenScripts, it.PostInstall, result.PostInstall)
 	result.PostInstall = addItem(seenScripts, right.PostInstall, result.PostInstall)
 
+	seenValidations := make(map[string]bool)
+	result.Validate = addItem(seenValidations, it.Validate, result.Validate)
+	result.Validate = addItem(seenValidations, right.Validate, result.Validate)
+
+	// activation snippets are script lines, so order and duplicates matter
+	result.PreActivate = append(append([]string{}, it.PreActivate...), right.PreActivate...)
+	result.PostActivate = append(append([]string{}, it.PostActivate...), right.PostActivate...)
+
 	err := pushConda(result, it.Conda)
 	if err != nil {
 		return nil, err
@@ -434,6 +459,13 @@ func (it *Environment) SaveAs(filename string) error {
 
 func (it *Environment) SaveAsRequirements(filename string) error {
 	content := it.AsRequirementsText()
+	if pipRequireHashes() {
+		hashed, err := it.AsHashedRequirementsText()
+		if err != nil {
+			return err
+		}
+		content = hashed
+	}
 	common.Trace("FINAL pip requirements as %v:\n---\n%v\n---", filename, content)
 	return ioutil.WriteFile(filename, []byte(content), 0o640)
 }
@@ -446,6 +478,10 @@ func (it *Environment) AsYaml() (string, error) {
 	result.Dependencies = it.CondaList()
 	seenScripts := make(map[string]bool)
 	result.PostInstall = addItem(seenScripts, it.PostInstall, result.PostInstall)
+	seenValidations := make(map[string]bool)
+	result.Validate = addItem(seenValidations, it.Validate, result.Validate)
+	result.PreActivate = it.PreActivate
+	result.PostActivate = it.PostActivate
 	if len(it.Pip) > 0 {
 		result.Dependencies = append(result.Dependencies, it.PipMap())
 	}
@@ -531,6 +567,10 @@ func (it *Environment) Diagnostics(target *common.DiagnosticStatus, production b
 			ok = false
 			floating = true
 		}
+		if pipRequireHashes() && !dependency.IsHashPinned() {
+			diagnose.Fail("", "Pip dependency %q must have '--hash', since settings require pip hashes.", dependency.Original)
+			ok = false
+		}
 	}
 	if ok {
 		diagnose.Ok("Pip dependencies in conda.yaml are ok.")
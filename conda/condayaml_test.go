@@ -1,6 +1,10 @@
 package conda_test
 
 import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/robocorp/rcc/conda"
@@ -117,3 +121,145 @@ func TestCanCreateEmptyEnvironment(t *testing.T) {
 	sut := conda.SummonEnvironment("tmp/missing.yaml")
 	wont_be.Nil(sut)
 }
+
+func TestLibsUpdatePreservesComments(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	filename := filepath.Join(t.TempDir(), "conda.yaml")
+	original := "channels:\n  - conda-forge # main channel\n\ndependencies:\n  # keep this comment\n  - python=3.7.5\n  - pip=20.1\n  - pip:\n    - rpaframework==11.1.3 # notes\n"
+	must_be.Nil(ioutil.WriteFile(filename, []byte(original), 0o644))
+
+	changes := &conda.Changes{Pip: true, Add: []string{"rpaframework==11.2.0", "requests"}, Remove: []string{"rpaframework"}}
+	_, err := conda.UpdateEnvironment(filename, changes)
+	must_be.Nil(err)
+
+	content, err := ioutil.ReadFile(filename)
+	must_be.Nil(err)
+	expected := "channels:\n  - conda-forge # main channel\n\ndependencies:\n  # keep this comment\n  - python=3.7.5\n  - pip=20.1\n  - pip:\n    - rpaframework==11.2.0 # notes\n    - requests\n"
+	must_be.Equal(expected, string(content))
+
+	changes = &conda.Changes{Remove: []string{"pip"}}
+	_, err = conda.UpdateEnvironment(filename, changes)
+	must_be.Nil(err)
+	content, err = ioutil.ReadFile(filename)
+	must_be.Nil(err)
+	wont_be.True(strings.Contains(string(content), "pip=20.1"))
+	must_be.True(strings.Contains(string(content), "# keep this comment"))
+}
+
+func TestLibsUpdateNeverDropsComments(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	filename := filepath.Join(t.TempDir(), "conda.yaml")
+	original := "# documented dependencies\nchannels: [conda-forge]\ndependencies: [python=3.7.5, pip=20.1]\n"
+	must_be.Nil(ioutil.WriteFile(filename, []byte(original), 0o644))
+
+	changes := &conda.Changes{Add: []string{"nodejs=16.13.0"}}
+	_, err := conda.UpdateEnvironment(filename, changes)
+	must_be.Nil(err)
+	content, err := ioutil.ReadFile(filename)
+	must_be.Nil(err)
+	must_be.Equal("# documented dependencies\nchannels: [conda-forge]\ndependencies: [python=3.7.5, pip=20.1, nodejs=16.13.0]\n", string(content))
+
+	multiline := "# documented dependencies\nchannels: [conda-forge]\ndependencies: [\n  python=3.7.5,\n  pip=20.1]\n"
+	must_be.Nil(ioutil.WriteFile(filename, []byte(multiline), 0o644))
+	_, err = conda.UpdateEnvironment(filename, changes)
+	wont_be.Nil(err)
+	content, err = ioutil.ReadFile(filename)
+	must_be.Nil(err)
+	must_be.Equal(multiline, string(content))
+
+	must_be.Nil(ioutil.WriteFile(filename, []byte(multiline[len("# documented dependencies\n"):]), 0o644))
+	_, err = conda.UpdateEnvironment(filename, changes)
+	must_be.Nil(err)
+	content, err = ioutil.ReadFile(filename)
+	must_be.Nil(err)
+	must_be.True(strings.Contains(string(content), "nodejs=16.13.0"))
+}
+
+func TestCanCompareVersions(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	must.True(conda.CompareVersions("1.10.0", "1.9.2") > 0)
+	must.True(conda.CompareVersions("3.7.5", "3.7") > 0)
+	must.True(conda.CompareVersions("1.0rc1", "1.0") < 0)
+	must.True(conda.CompareVersions("2021.10", "2021.9.1") > 0)
+	must.Equal(0, conda.CompareVersions("4.0.1", "4.0.1"))
+	wont.True(conda.CompareVersions("0.9", "1.0") > 0)
+}
+
+func TestCanCompareVersionsUsingPep440(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	ordered := []string{
+		"1.0.dev0",
+		"1.0a1.dev1",
+		"1.0a1",
+		"1.0b2",
+		"1.0rc1",
+		"1.0",
+		"1.0+local.1",
+		"1.0.post1.dev0",
+		"1.0.post1",
+		"1.0.post2",
+		"1.0.1",
+		"1.1.dev1",
+		"1.1",
+		"1!0.5",
+	}
+	for at, left := range ordered {
+		for other, right := range ordered {
+			expected := 0
+			if at < other {
+				expected = -1
+			}
+			if at > other {
+				expected = 1
+			}
+			compared := conda.CompareVersions(left, right)
+			if compared < 0 {
+				compared = -1
+			}
+			if compared > 0 {
+				compared = 1
+			}
+			must.Equal(fmt.Sprintf("%s ? %s -> %d", left, right, expected), fmt.Sprintf("%s ? %s -> %d", left, right, compared))
+		}
+	}
+
+	equals := [][2]string{
+		{"1.0", "1.0.0"},
+		{"1.0.post1", "1.0-1"},
+		{"1.0.post1", "1.0.rev1"},
+		{"1.0rc1", "1.0c1"},
+		{"1.0a1", "1.0-alpha.1"},
+		{"1.0.dev0", "1.0-dev"},
+		{"v2.0", "2.0"},
+	}
+	for _, pair := range equals {
+		must.Equal(fmt.Sprintf("%s ? %s -> 0", pair[0], pair[1]), fmt.Sprintf("%s ? %s -> %d", pair[0], pair[1], conda.CompareVersions(pair[0], pair[1])))
+	}
+
+	must.True(conda.CompareVersions("2021.10_h1", "2021.9_h1") > 0)
+}
+
+func TestValidationCommandsAreMergedAndKept(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	left, err := conda.CondaYamlFrom([]byte("dependencies:\n  - python=3.9.13\nrccValidate:\n  - python -c \"import pandas\"\n"))
+	must_be.Nil(err)
+	right, err := conda.CondaYamlFrom([]byte("dependencies:\n  - nodejs=16.14.2\nrccValidate:\n  - node --version\n  - python -c \"import pandas\"\n"))
+	must_be.Nil(err)
+	must_be.Equal([]string{"python -c \"import pandas\""}, left.Validate)
+
+	sut, err := left.Merge(right)
+	must_be.Nil(err)
+	must_be.Equal([]string{"python -c \"import pandas\"", "node --version"}, sut.Validate)
+
+	content, err := sut.AsYaml()
+	must_be.Nil(err)
+	must_be.True(strings.Contains(content, "rccValidate:"))
+	pure, err := sut.AsPureConda().AsYaml()
+	must_be.Nil(err)
+	wont_be.True(strings.Contains(pure, "rccValidate:"))
+}
@@ -95,12 +95,12 @@ func GoldenMasterFilename(targetFolder string) string {
 	return filepath.Join(targetFolder, "golden-ee.yaml")
 }
 
-func goldenMaster(targetFolder string, pipUsed bool) (err error) {
+func goldenMaster(resolver Solver, targetFolder string, pipUsed bool) (err error) {
 	defer fail.Around(&err)
 
 	seen := make(map[string]string)
 	collector := make(dependencies, 0, 100)
-	collector, err = fillDependencies("mamba", targetFolder, seen, collector, BinMicromamba(), "list", "--json")
+	collector, err = fillDependencies("mamba", targetFolder, seen, collector, resolver.ListCommand(targetFolder)...)
 	fail.On(err != nil, "Failed to list micromamba dependencies, reason: %v", err)
 	if pipUsed {
 		collector, err = fillDependencies("pypi", targetFolder, seen, collector, "pip", "list", "--isolated", "--local", "--format", "json")
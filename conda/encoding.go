@@ -0,0 +1,36 @@
+package conda
+
+import (
+	"strings"
+
+	"github.com/robocorp/rcc/settings"
+)
+
+const (
+	utf8CodePage = 65001
+)
+
+func isUTF8(value string) bool {
+	lowered := strings.ToLower(value)
+	return strings.Contains(lowered, "utf-8") || strings.Contains(lowered, "utf8")
+}
+
+// UTF8Environment returns variables, which make Python use UTF-8 regardless
+// of console code page or locale, when enforce-utf8 is set in settings.
+func UTF8Environment() []string {
+	if !settings.Global.EnforceUTF8() {
+		return []string{}
+	}
+	result := []string{"PYTHONUTF8=1", "PYTHONIOENCODING=utf-8"}
+	return append(result, localeEnvironment()...)
+}
+
+// EnforceUTF8Console switches console to UTF-8 (like chcp 65001) when
+// enforce-utf8 is set in settings, and returns function which restores
+// original settings.
+func EnforceUTF8Console() func() {
+	if !settings.Global.EnforceUTF8() {
+		return func() {}
+	}
+	return utf8Console()
+}
@@ -0,0 +1,37 @@
+package conda_test
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestCanDetectUTF8Locale(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("locale is not used on windows")
+	}
+	must, wont := hamlet.Specifications(t)
+
+	original, ok := os.LookupEnv("LC_ALL")
+	if ok {
+		defer os.Setenv("LC_ALL", original)
+	} else {
+		defer os.Unsetenv("LC_ALL")
+	}
+
+	os.Setenv("LC_ALL", "en_US.UTF-8")
+	encoding, utf8 := conda.ConsoleEncoding()
+	must.True(utf8)
+	must.Equal("locale en_US.UTF-8", encoding)
+
+	os.Setenv("LC_ALL", "fi_FI.ISO-8859-15")
+	_, utf8 = conda.ConsoleEncoding()
+	wont.True(utf8)
+
+	os.Setenv("LC_ALL", "C.utf8")
+	_, utf8 = conda.ConsoleEncoding()
+	must.True(utf8)
+}
@@ -0,0 +1,40 @@
+//go:build darwin || linux || !windows
+// +build darwin linux !windows
+
+package conda
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+func currentLocale() string {
+	for _, key := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		value := os.Getenv(key)
+		if len(value) > 0 {
+			return value
+		}
+	}
+	return "C"
+}
+
+// ConsoleEncoding describes current locale, and tells if it is UTF-8.
+func ConsoleEncoding() (string, bool) {
+	locale := currentLocale()
+	return fmt.Sprintf("locale %s", locale), isUTF8(locale)
+}
+
+func localeEnvironment() []string {
+	if isUTF8(currentLocale()) {
+		return []string{}
+	}
+	if runtime.GOOS == "darwin" {
+		return []string{"LC_ALL=en_US.UTF-8"}
+	}
+	return []string{"LC_ALL=C.UTF-8"}
+}
+
+func utf8Console() func() {
+	return func() {}
+}
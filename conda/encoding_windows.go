@@ -0,0 +1,54 @@
+//go:build windows
+// +build windows
+
+package conda
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/robocorp/rcc/common"
+)
+
+var (
+	kernel32           = windows.NewLazySystemDLL("kernel32.dll")
+	getConsoleCP       = kernel32.NewProc("GetConsoleCP")
+	getConsoleOutputCP = kernel32.NewProc("GetConsoleOutputCP")
+	setConsoleCP       = kernel32.NewProc("SetConsoleCP")
+	setConsoleOutputCP = kernel32.NewProc("SetConsoleOutputCP")
+)
+
+func codePages() (uintptr, uintptr) {
+	input, _, _ := getConsoleCP.Call()
+	output, _, _ := getConsoleOutputCP.Call()
+	return input, output
+}
+
+// ConsoleEncoding describes console and ANSI code pages, and tells if
+// console is using UTF-8.
+func ConsoleEncoding() (string, bool) {
+	input, output := codePages()
+	if output == 0 {
+		return fmt.Sprintf("no console, ansi code page %d", windows.GetACP()), windows.GetACP() == utf8CodePage
+	}
+	return fmt.Sprintf("console code page %d/%d, ansi code page %d", input, output, windows.GetACP()), output == utf8CodePage
+}
+
+func localeEnvironment() []string {
+	return []string{}
+}
+
+func utf8Console() func() {
+	input, output := codePages()
+	if output == 0 || (input == utf8CodePage && output == utf8CodePage) {
+		return func() {}
+	}
+	common.Debug("Switching console code page from %d/%d to %d.", input, output, utf8CodePage)
+	setConsoleCP.Call(uintptr(utf8CodePage))
+	setConsoleOutputCP.Call(uintptr(utf8CodePage))
+	return func() {
+		setConsoleCP.Call(input)
+		setConsoleOutputCP.Call(output)
+	}
+}
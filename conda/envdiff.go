@@ -0,0 +1,98 @@
+package conda
+
+import (
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// PackageChange is one package added, removed, or changed between two
+// environments. Added packages have no "from" side, and removed ones have
+// no "to" side.
+type PackageChange struct {
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	From        string `json:"from,omitempty"`
+	To          string `json:"to,omitempty"`
+	FromChannel string `json:"from-channel,omitempty"`
+	ToChannel   string `json:"to-channel,omitempty"`
+}
+
+// EnvironmentDiff is package level difference between two environments.
+type EnvironmentDiff struct {
+	Added   []*PackageChange `json:"added"`
+	Removed []*PackageChange `json:"removed"`
+	Changed []*PackageChange `json:"changed"`
+}
+
+func (it *EnvironmentDiff) Empty() bool {
+	return len(it.Added)+len(it.Removed)+len(it.Changed) == 0
+}
+
+// DependenciesFrom parses dependency listing (golden-ee.yaml) content.
+func DependenciesFrom(content []byte) (dependencies, error) {
+	result := make(dependencies, 0, 100)
+	err := yaml.Unmarshal(content, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result.sorted(), nil
+}
+
+func dependencyKind(entry *dependency) string {
+	if entry.Origin == "pypi" {
+		return "pypi"
+	}
+	return "conda"
+}
+
+func indexDependencies(deps dependencies) map[string]*dependency {
+	result := make(map[string]*dependency)
+	for _, entry := range deps {
+		result[dependencyKind(entry)+":"+strings.ToLower(entry.Name)] = entry
+	}
+	return result
+}
+
+func sortedChanges(changes []*PackageChange) []*PackageChange {
+	sort.SliceStable(changes, func(left, right int) bool {
+		if changes[left].Kind != changes[right].Kind {
+			return changes[left].Kind < changes[right].Kind
+		}
+		return strings.ToLower(changes[left].Name) < strings.ToLower(changes[right].Name)
+	})
+	return changes
+}
+
+// DiffDependencies compares two dependency listings, where left is "old"
+// and right is "new" environment. Packages are matched by name separately
+// for conda and pypi packages.
+func DiffDependencies(left, right dependencies) *EnvironmentDiff {
+	result := &EnvironmentDiff{
+		Added:   []*PackageChange{},
+		Removed: []*PackageChange{},
+		Changed: []*PackageChange{},
+	}
+	before := indexDependencies(left)
+	after := indexDependencies(right)
+	for key, old := range before {
+		fresh, ok := after[key]
+		if !ok {
+			result.Removed = append(result.Removed, &PackageChange{Name: old.Name, Kind: dependencyKind(old), From: old.Version, FromChannel: old.Origin})
+			continue
+		}
+		if old.Version != fresh.Version || old.Origin != fresh.Origin {
+			result.Changed = append(result.Changed, &PackageChange{Name: fresh.Name, Kind: dependencyKind(fresh), From: old.Version, To: fresh.Version, FromChannel: old.Origin, ToChannel: fresh.Origin})
+		}
+	}
+	for key, fresh := range after {
+		if _, ok := before[key]; !ok {
+			result.Added = append(result.Added, &PackageChange{Name: fresh.Name, Kind: dependencyKind(fresh), To: fresh.Version, ToChannel: fresh.Origin})
+		}
+	}
+	sortedChanges(result.Added)
+	sortedChanges(result.Removed)
+	sortedChanges(result.Changed)
+	return result
+}
@@ -0,0 +1,66 @@
+package conda_test
+
+import (
+	"testing"
+
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+const (
+	oldDependencies = `
+- name: python
+  version: 3.9.13
+  origin: conda-forge
+- name: pip
+  version: 22.1.2
+  origin: conda-forge
+- name: requests
+  version: 2.27.1
+  origin: pypi
+- name: six
+  version: 1.16.0
+  origin: pypi
+`
+	newDependencies = `
+- name: python
+  version: 3.10.4
+  origin: conda-forge
+- name: pip
+  version: 22.1.2
+  origin: defaults
+- name: requests
+  version: 2.27.1
+  origin: pypi
+- name: urllib3
+  version: 1.26.9
+  origin: pypi
+`
+)
+
+func TestCanDiffDependencyListings(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	left, err := conda.DependenciesFrom([]byte(oldDependencies))
+	must_be.Nil(err)
+	right, err := conda.DependenciesFrom([]byte(newDependencies))
+	must_be.Nil(err)
+
+	diff := conda.DiffDependencies(left, right)
+	wont_be.True(diff.Empty())
+	must_be.Equal(1, len(diff.Added))
+	must_be.Equal("urllib3", diff.Added[0].Name)
+	must_be.Equal("pypi", diff.Added[0].Kind)
+	must_be.Equal(1, len(diff.Removed))
+	must_be.Equal("six", diff.Removed[0].Name)
+	must_be.Equal(2, len(diff.Changed))
+	must_be.Equal("pip", diff.Changed[0].Name)
+	must_be.Equal("defaults", diff.Changed[0].ToChannel)
+	must_be.Equal("python", diff.Changed[1].Name)
+	must_be.Equal("3.9.13", diff.Changed[1].From)
+	must_be.Equal("3.10.4", diff.Changed[1].To)
+
+	must_be.True(conda.DiffDependencies(left, left).Empty())
+	_, err = conda.DependenciesFrom([]byte("- {"))
+	wont_be.Nil(err)
+}
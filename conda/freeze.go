@@ -0,0 +1,184 @@
+package conda
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/pretty"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	freezeSource = `rcc freeze`
+)
+
+type condaMeta struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Url     string `json:"url"`
+	Md5     string `json:"md5"`
+	Sha256  string `json:"sha256"`
+}
+
+type pipInspection struct {
+	Installed []*pipInstalled `json:"installed"`
+}
+
+type pipInstalled struct {
+	Metadata struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"metadata"`
+	Installer    string `json:"installer"`
+	DownloadInfo *struct {
+		Url         string `json:"url"`
+		ArchiveInfo struct {
+			Hashes map[string]string `json:"hashes"`
+		} `json:"archive_info"`
+	} `json:"download_info"`
+}
+
+type pipListed struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// LockfileFilename is where environment lockfile is written inside
+// environment, when it is created.
+func LockfileFilename(targetFolder string) string {
+	return filepath.Join(targetFolder, "environment.lock.yaml")
+}
+
+func frozenConda(targetFolder string) ([]*lockedPackage, error) {
+	metafiles, err := filepath.Glob(filepath.Join(targetFolder, "conda-meta", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	result := []*lockedPackage{}
+	for _, metafile := range metafiles {
+		content, err := ioutil.ReadFile(metafile)
+		if err != nil {
+			return nil, err
+		}
+		meta := &condaMeta{}
+		err = json.Unmarshal(content, meta)
+		if err != nil || len(meta.Url) == 0 {
+			common.Debug("Skipping %q in freeze, since it has no url (error: %v).", metafile, err)
+			continue
+		}
+		hash := make(map[string]string)
+		if len(meta.Md5) > 0 {
+			hash["md5"] = meta.Md5
+		}
+		if len(meta.Sha256) > 0 {
+			hash["sha256"] = meta.Sha256
+		}
+		result = append(result, &lockedPackage{
+			Name:     meta.Name,
+			Version:  meta.Version,
+			Manager:  "conda",
+			Platform: LockPlatform(),
+			Url:      meta.Url,
+			Hash:     hash,
+		})
+	}
+	return result, nil
+}
+
+func pipLocked(name, version string) *lockedPackage {
+	return &lockedPackage{
+		Name:     name,
+		Version:  version,
+		Manager:  "pip",
+		Platform: LockPlatform(),
+	}
+}
+
+// frozenPip uses "pip inspect" to get download urls and hashes of pip
+// installed packages, and falls back to "pip list" (versions only) with
+// older pip versions.
+func frozenPip(targetFolder string) ([]*lockedPackage, error) {
+	result := []*lockedPackage{}
+	output, code, err := LiveCapture(targetFolder, "pip", "inspect", "--local")
+	if err == nil && code == 0 {
+		inspection := &pipInspection{}
+		err = json.Unmarshal([]byte(output), inspection)
+		if err != nil {
+			return nil, err
+		}
+		for _, installed := range inspection.Installed {
+			if installed.Installer != "pip" {
+				continue
+			}
+			locked := pipLocked(installed.Metadata.Name, installed.Metadata.Version)
+			if installed.DownloadInfo != nil && strings.HasPrefix(installed.DownloadInfo.Url, "http") {
+				locked.Url = installed.DownloadInfo.Url
+				locked.Hash = installed.DownloadInfo.ArchiveInfo.Hashes
+			}
+			result = append(result, locked)
+		}
+		return result, nil
+	}
+	common.Debug("Pip inspect failed (code %d, error: %v), using pip list instead.", code, err)
+	output, code, err = LiveCapture(targetFolder, "pip", "list", "--isolated", "--local", "--not-required", "--format", "json")
+	if err != nil || code != 0 {
+		return result, nil
+	}
+	listed := []*pipListed{}
+	err = json.Unmarshal([]byte(output), &listed)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range listed {
+		result = append(result, pipLocked(entry.Name, entry.Version))
+	}
+	return result, nil
+}
+
+// FreezeEnvironment returns conda-lock formatted lockfile of everything
+// installed in environment: conda packages with their urls and digests, and
+// pip packages with urls and digests when pip knows them. Result can be used
+// as lockfile for creating same environment again.
+func FreezeEnvironment(targetFolder string, withPip bool) (_ []byte, err error) {
+	defer fail.Around(&err)
+
+	packages, err := frozenConda(targetFolder)
+	fail.On(err != nil, "Failed to read conda packages, reason: %v", err)
+	fail.On(len(packages) == 0, "No conda packages found in %q.", targetFolder)
+	if withPip {
+		pips, err := frozenPip(targetFolder)
+		fail.On(err != nil, "Failed to list pip packages, reason: %v", err)
+		packages = append(packages, pips...)
+	}
+	sort.SliceStable(packages, func(left, right int) bool {
+		if packages[left].Manager != packages[right].Manager {
+			return packages[left].Manager < packages[right].Manager
+		}
+		return strings.ToLower(packages[left].Name) < strings.ToLower(packages[right].Name)
+	})
+	lock := &condaLock{
+		Version: 1,
+		Metadata: &lockMetadata{
+			Platforms: []string{LockPlatform()},
+			Sources:   []string{freezeSource},
+		},
+		Package: packages,
+	}
+	return yaml.Marshal(lock)
+}
+
+func freezeLockfile(targetFolder string, withPip bool) error {
+	content, err := FreezeEnvironment(targetFolder, withPip)
+	if err != nil {
+		return err
+	}
+	lockfile := LockfileFilename(targetFolder)
+	common.Debug("%sEnvironment lockfile at: %v%s", pretty.Yellow, lockfile, pretty.Reset)
+	return ioutil.WriteFile(lockfile, content, 0o644)
+}
@@ -0,0 +1,40 @@
+package conda_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestFrozenEnvironmentCanBeReadBackAsLockfile(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	folder, err := ioutil.TempDir("", "freeze")
+	must_be.Nil(err)
+	defer os.RemoveAll(folder)
+
+	_, err = conda.FreezeEnvironment(folder, false)
+	wont_be.Nil(err)
+
+	metadir := filepath.Join(folder, "conda-meta")
+	must_be.Nil(os.MkdirAll(metadir, 0o755))
+	python := `{"name": "python", "version": "3.9.13", "url": "https://conda.anaconda.org/conda-forge/linux-64/python-3.9.13-h2660328_0_cpython.tar.bz2", "md5": "4a5ff4b2d9c5b5a4b0e7a9b2c0b1e3d4"}`
+	must_be.Nil(ioutil.WriteFile(filepath.Join(metadir, "python-3.9.13-h2660328_0_cpython.json"), []byte(python), 0o644))
+	must_be.Nil(ioutil.WriteFile(filepath.Join(metadir, "history"), []byte("# history"), 0o644))
+
+	content, err := conda.FreezeEnvironment(folder, false)
+	must_be.Nil(err)
+
+	lockfile := conda.LockfileFilename(folder)
+	must_be.Nil(ioutil.WriteFile(lockfile, content, 0o644))
+	must_be.True(conda.IsLockfile(lockfile))
+
+	locked, err := conda.ReadLockfile(lockfile)
+	must_be.Nil(err)
+	must_be.Equal([]string{"https://conda.anaconda.org/conda-forge/linux-64/python-3.9.13-h2660328_0_cpython.tar.bz2#4a5ff4b2d9c5b5a4b0e7a9b2c0b1e3d4"}, locked.Explicit)
+	must_be.Equal(0, len(locked.Pip))
+}
@@ -0,0 +1,76 @@
+package conda
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/robocorp/rcc/settings"
+)
+
+const (
+	hashOption    = `--hash=`
+	hashFragment  = `#sha256=`
+	hashSeparator = " \\" + Newline + "    "
+)
+
+// Hashes returns "algorithm:digest" values given with --hash options of
+// pip dependency.
+func (it *Dependency) Hashes() []string {
+	result := []string{}
+	for _, field := range strings.Fields(it.Original) {
+		if strings.HasPrefix(field, hashOption) {
+			result = append(result, strings.TrimPrefix(field, hashOption))
+		}
+	}
+	return result
+}
+
+// IsHashPinned tells if dependency is pinned with "==" and has hashes, as
+// pip requires in hash-checking mode.
+func (it *Dependency) IsHashPinned() bool {
+	return it.Qualifier == "==" && len(it.Hashes()) > 0
+}
+
+func (it *Dependency) hashedRequirement() string {
+	version := strings.Fields(it.Versions)[0]
+	lines := []string{fmt.Sprintf("%s==%s", it.Name, version)}
+	for _, hash := range it.Hashes() {
+		lines = append(lines, hashOption+hash)
+	}
+	return strings.Join(lines, hashSeparator)
+}
+
+// AsHashedRequirementsText is requirements file for "pip --require-hashes",
+// and fails if some pip dependency is not pinned with hashes.
+func (it *Environment) AsHashedRequirementsText() (string, error) {
+	lines := make([]string, 0, len(it.Pip))
+	missing := []string{}
+	for _, entry := range it.Pip {
+		if !entry.IsHashPinned() {
+			missing = append(missing, entry.Original)
+			continue
+		}
+		lines = append(lines, entry.hashedRequirement())
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("Pip hashes are required (pip-require-hashes), but these pip dependencies are not pinned with '==' and '--hash': %s", strings.Join(missing, ", "))
+	}
+	return strings.Join(lines, Newline), nil
+}
+
+// hashedLocked turns locked "name @ url#sha256=digest" requirement into form
+// accepted in hash-checking mode.
+func hashedLocked(requirement string) (string, bool) {
+	if strings.Contains(requirement, hashOption) {
+		return requirement, true
+	}
+	at := strings.LastIndex(requirement, hashFragment)
+	if at < 0 {
+		return requirement, false
+	}
+	return fmt.Sprintf("%s %ssha256:%s", requirement[:at], hashOption, requirement[at+len(hashFragment):]), true
+}
+
+func pipRequireHashes() bool {
+	return settings.Global.PipRequireHashes()
+}
@@ -0,0 +1,47 @@
+package conda_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestCanTranslatePinnedPipDependenciesIntoHashedRequirements(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	hashed := []byte(`dependencies:
+  - python=3.9.13
+  - pip=22.1.2
+  - pip:
+    - robotframework==6.0.2 --hash=sha256:aaaa --hash=sha256:bbbb
+    - requests[socks]==2.28.1 --hash=sha256:cccc
+`)
+	sut, err := conda.CondaYamlFrom(hashed)
+	must_be.Nil(err)
+	must_be.Equal([]string{"sha256:aaaa", "sha256:bbbb"}, sut.Pip[0].Hashes())
+	must_be.True(sut.Pip[0].IsHashPinned())
+	text, err := sut.AsHashedRequirementsText()
+	must_be.Nil(err)
+	expected := []string{
+		"robotframework==6.0.2 \\",
+		"    --hash=sha256:aaaa \\",
+		"    --hash=sha256:bbbb",
+		"requests[socks]==2.28.1 \\",
+		"    --hash=sha256:cccc",
+	}
+	must_be.Equal(strings.Join(expected, conda.Newline), text)
+
+	floating := []byte(`dependencies:
+  - pip:
+    - robotframework==6.0.2
+    - requests>=2.28.1 --hash=sha256:cccc
+`)
+	sut, err = conda.CondaYamlFrom(floating)
+	must_be.Nil(err)
+	wont_be.True(sut.Pip[0].IsHashPinned())
+	wont_be.True(sut.Pip[1].IsHashPinned())
+	_, err = sut.AsHashedRequirementsText()
+	wont_be.Nil(err)
+}
@@ -1,15 +1,25 @@
 package conda
 
 import (
+	"fmt"
 	"os"
 	"time"
 
 	"github.com/robocorp/rcc/cloud"
 	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/settings"
 )
 
 func MustMicromamba() bool {
-	return HasMicroMamba() || ((DoDownload(1*time.Millisecond) || DoDownload(1*time.Second) || DoDownload(3*time.Second)) && DoInstall())
+	if HasMicroMamba() && (common.OfflineFlag || !(settings.Global.MicromambaUpdate() && MicromambaOutdated())) {
+		return true
+	}
+	if common.OfflineFlag {
+		common.Log("Offline mode: micromamba %s is not available, and it cannot be downloaded.", MicromambaWanted())
+		return false
+	}
+	return (DoDownload(1*time.Millisecond) || DoDownload(1*time.Second) || DoDownload(3*time.Second)) && DoInstall() && HasMicroMamba()
 }
 
 func DoDownload(delay time.Duration) bool {
@@ -17,14 +27,24 @@ func DoDownload(delay time.Duration) bool {
 		defer common.Stopwatch("Download done in").Report()
 	}
 
-	common.Log("Downloading micromamba, this may take awhile ...")
+	common.Log("Downloading micromamba %s, this may take awhile ...", MicromambaWanted())
 
 	time.Sleep(delay)
 
-	err := cloud.Download(MicromambaLink(), BinMicromamba())
+	link := MicromambaLink()
+	checksum := MicromambaDigest()
+	if len(checksum) == 0 {
+		if settings.Global.MicromambaVerify() {
+			common.Fatal("Download", fmt.Errorf("Micromamba %s for %s has no pinned sha256 digest (micromamba-sha256 in settings, or --sha256 with --pin), and micromamba-verify requires one.", MicromambaWanted(), micromambaPlatform))
+			return false
+		}
+		pretty.Warning("Micromamba %s for %s has no pinned sha256 digest (micromamba-sha256 in settings, or --sha256 with --pin). Download %q is NOT verified against trusted digest.", MicromambaWanted(), micromambaPlatform, link)
+		checksum = micromambaChecksum(link)
+	}
+	err := cloud.ResumableDownload(link, downloadedMicromamba(), checksum)
 	if err != nil {
 		common.Fatal("Download", err)
-		os.Remove(BinMicromamba())
+		os.Remove(downloadedMicromamba())
 		return false
 	}
 	cloud.BackgroundMetric(common.ControllerIdentity(), "rcc.micromamba.download", common.Version)
@@ -38,7 +58,7 @@ func DoInstall() bool {
 
 	common.Log("Making micromamba executable ...")
 
-	err := os.Chmod(BinMicromamba(), 0o755)
+	err := os.Chmod(downloadedMicromamba(), 0o755)
 	if err != nil {
 		common.Fatal("Install", err)
 		return false
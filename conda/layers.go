@@ -0,0 +1,79 @@
+package conda
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/pretty"
+)
+
+const (
+	condaLayerHeader = `# rcc conda layer`
+)
+
+// Layers lets environment builds reuse conda layer (stage right after
+// micromamba, before pip), so that changing only pip dependencies does not
+// rebuild conda part of environment. Holotree library implements this.
+type Layers interface {
+	HasLayer(blueprint []byte) bool
+	RestoreLayer(blueprint []byte) bool
+	RecordLayer(blueprint []byte) error
+}
+
+type condaLayer struct {
+	layers    Layers
+	blueprint []byte
+}
+
+// CondaLayerBlueprint is blueprint of conda layer built from given conda
+// (or explicit spec) file, which is also used as its catalog identity.
+func CondaLayerBlueprint(condaYaml string) ([]byte, error) {
+	content, err := ioutil.ReadFile(condaYaml)
+	if err != nil {
+		return nil, err
+	}
+	lines := []string{condaLayerHeader}
+	if ConfiguredChannels() {
+		lines = append(lines, fmt.Sprintf("# channels: %s", strings.Join(ChannelNames(), ", ")))
+	}
+	lines = append(lines, strings.TrimSpace(string(content)))
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// newCondaLayer returns layer only when it is useful, which means that there
+// are pip dependencies to be installed on top of conda layer.
+func newCondaLayer(layers Layers, condaYaml, requirementsText string) *condaLayer {
+	if layers == nil {
+		return nil
+	}
+	size, ok := pathlib.Size(requirementsText)
+	if !ok || size == 0 {
+		return nil
+	}
+	blueprint, err := CondaLayerBlueprint(condaYaml)
+	if err != nil {
+		common.Debug("No conda layer, reason: %v", err)
+		return nil
+	}
+	return &condaLayer{layers: layers, blueprint: blueprint}
+}
+
+func (it *condaLayer) restore(force bool) bool {
+	if it == nil || force {
+		return false
+	}
+	return it.layers.RestoreLayer(it.blueprint)
+}
+
+func (it *condaLayer) record() {
+	if it == nil {
+		return
+	}
+	err := it.layers.RecordLayer(it.blueprint)
+	if err != nil {
+		common.Log("%sConda layer recording failure: %v%s", pretty.Yellow, err, pretty.Reset)
+	}
+}
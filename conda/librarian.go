@@ -1,5 +1,14 @@
 package conda
 
+import (
+	"fmt"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/yamledit"
+)
+
 type Changes struct {
 	Name    string
 	Dryrun  bool
@@ -25,13 +34,115 @@ func UpdateEnvironment(filename string, changes *Changes) (string, error) {
 	if changes.Dryrun {
 		return environment.AsYaml()
 	}
-	err := environment.SaveAs(filename)
+	err := saveEnvironmentEdits(filename, environment)
+	if err != nil && hasComments(filename) {
+		return "", fmt.Errorf("Could not edit %q in place (only block style YAML and single line flow sequences can be edited without rewriting it), and full rewrite would lose its comments, so it was left untouched. Reason: %v", filename, err)
+	}
+	if err != nil {
+		common.Log("Warning: could not preserve formatting of %q, rewriting it fully. Reason: %v", filename, err)
+		err = environment.SaveAs(filename)
+	}
 	if err != nil {
 		return "", err
 	}
 	return environment.AsYaml()
 }
 
+func hasComments(filename string) bool {
+	document, err := yamledit.Load(filename)
+	return err == nil && document.HasComments()
+}
+
+// saveEnvironmentEdits applies only differences into existing file, so that
+// comments and formatting of user written conda.yaml survive.
+func saveEnvironmentEdits(filename string, updated *Environment) (err error) {
+	defer fail.Around(&err)
+
+	fail.On(!pathlib.IsFile(filename), "No existing %q to edit.", filename)
+	original, err := ReadCondaYaml(filename)
+	fail.On(err != nil, "%v", err)
+	document, err := yamledit.Load(filename)
+	fail.On(err != nil, "%v", err)
+	if updated.Name != original.Name {
+		err = document.SetScalar("name", updated.Name)
+		fail.On(err != nil, "%v", err)
+	}
+	err = syncSequence(document, updated.Channels, func(value string) string { return value }, "channels")
+	fail.On(err != nil, "%v", err)
+	err = syncSequence(document, originals(updated.Conda), dependencyName, "dependencies")
+	fail.On(err != nil, "%v", err)
+	err = syncSequence(document, originals(updated.Pip), dependencyName, "dependencies", "pip")
+	fail.On(err != nil, "%v", err)
+	if len(updated.Pip) == 0 {
+		items, err := document.Sequence("dependencies")
+		fail.On(err != nil, "%v", err)
+		for _, item := range items {
+			if item.Value == "pip:" {
+				document.Remove(item)
+				break
+			}
+		}
+	}
+	return document.SaveAs(filename)
+}
+
+func originals(dependencies []*Dependency) []string {
+	result := make([]string, 0, len(dependencies))
+	for _, dependency := range dependencies {
+		result = append(result, dependency.Original)
+	}
+	return result
+}
+
+func dependencyName(value string) string {
+	dependency := AsDependency(value)
+	if dependency == nil {
+		return value
+	}
+	return dependency.Representation()
+}
+
+func syncSequence(document *yamledit.Document, wanted []string, identity func(string) string, path ...string) error {
+	keep := make(map[string]bool)
+	for _, value := range wanted {
+		keep[identity(value)] = true
+	}
+	items, err := document.Sequence(path...)
+	if err != nil {
+		return err
+	}
+	for at := len(items) - 1; at >= 0; at-- {
+		item := items[at]
+		if !item.IsMapping() && !keep[identity(item.Unquoted())] {
+			document.Remove(item)
+		}
+	}
+	for _, value := range wanted {
+		items, err = document.Sequence(path...)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, item := range items {
+			if item.IsMapping() || identity(item.Unquoted()) != identity(value) {
+				continue
+			}
+			found = true
+			if item.Unquoted() != value {
+				document.Replace(item, value)
+			}
+			break
+		}
+		if !found {
+			err = document.Append(value, path...)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func Index(search string, members []string) int {
 	for at, member := range members {
 		if member == search {
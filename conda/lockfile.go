@@ -0,0 +1,233 @@
+package conda
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	explicitMarker = `@EXPLICIT`
+	lockfileHeader = `# rcc lockfile environment`
+	lockedPip      = `# pip: `
+)
+
+// Lockfile is fully resolved environment, either from conda-lock file or
+// from "@EXPLICIT" spec. It is installed as it is, without solver.
+type Lockfile struct {
+	Explicit []string
+	Pip      []string
+}
+
+type condaLock struct {
+	Version  int              `yaml:"version"`
+	Metadata *lockMetadata    `yaml:"metadata,omitempty"`
+	Package  []*lockedPackage `yaml:"package"`
+}
+
+type lockMetadata struct {
+	Platforms []string `yaml:"platforms"`
+	Sources   []string `yaml:"sources"`
+}
+
+type lockedPackage struct {
+	Name     string            `yaml:"name"`
+	Version  string            `yaml:"version"`
+	Manager  string            `yaml:"manager"`
+	Platform string            `yaml:"platform"`
+	Url      string            `yaml:"url,omitempty"`
+	Hash     map[string]string `yaml:"hash,omitempty"`
+	Optional bool              `yaml:"optional"`
+}
+
+// LockPlatform is conda platform name of this machine.
+func LockPlatform() string {
+	arch := "64"
+	if runtime.GOARCH == "arm64" {
+		arch = "arm64"
+		if runtime.GOOS == "linux" {
+			arch = "aarch64"
+		}
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return "osx-" + arch
+	case "windows":
+		return "win-" + arch
+	default:
+		return runtime.GOOS + "-" + arch
+	}
+}
+
+func hasExplicitMarker(content []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == explicitMarker {
+			return true
+		}
+	}
+	return false
+}
+
+func explicitLockfile(content []byte) (*Lockfile, error) {
+	result := &Lockfile{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case len(line) == 0 || line == explicitMarker:
+			continue
+		case strings.HasPrefix(line, lockedPip):
+			result.Pip = append(result.Pip, strings.TrimSpace(strings.TrimPrefix(line, lockedPip)))
+		case strings.HasPrefix(line, "#"):
+			continue
+		default:
+			result.Explicit = append(result.Explicit, line)
+		}
+	}
+	return result, scanner.Err()
+}
+
+func condaLockfile(content []byte) (*Lockfile, error) {
+	var lock condaLock
+	err := yaml.Unmarshal(content, &lock)
+	if err != nil {
+		return nil, err
+	}
+	if lock.Version < 1 || len(lock.Package) == 0 {
+		return nil, fmt.Errorf("Not a conda-lock file.")
+	}
+	platform := LockPlatform()
+	result := &Lockfile{}
+	for _, entry := range lock.Package {
+		if entry.Optional || entry.Platform != platform {
+			continue
+		}
+		switch entry.Manager {
+		case "conda":
+			if md5, ok := entry.Hash["md5"]; ok {
+				result.Explicit = append(result.Explicit, fmt.Sprintf("%s#%s", entry.Url, md5))
+			} else {
+				result.Explicit = append(result.Explicit, entry.Url)
+			}
+		case "pip":
+			if len(entry.Url) == 0 {
+				result.Pip = append(result.Pip, fmt.Sprintf("%s==%s", entry.Name, entry.Version))
+			} else if sha, ok := entry.Hash["sha256"]; ok {
+				result.Pip = append(result.Pip, fmt.Sprintf("%s @ %s#sha256=%s", entry.Name, entry.Url, sha))
+			} else {
+				result.Pip = append(result.Pip, fmt.Sprintf("%s @ %s", entry.Name, entry.Url))
+			}
+		}
+	}
+	return result, nil
+}
+
+// ReadLockfile reads conda-lock file (unified yaml format) or "@EXPLICIT"
+// spec file. Only packages of this platform are taken from conda-lock file.
+func ReadLockfile(filename string) (*Lockfile, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", filename, err)
+	}
+	var result *Lockfile
+	if hasExplicitMarker(content) {
+		result, err = explicitLockfile(content)
+	} else {
+		result, err = condaLockfile(content)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", filename, err)
+	}
+	if len(result.Explicit) == 0 {
+		return nil, fmt.Errorf("%q: no conda packages for platform %q.", filename, LockPlatform())
+	}
+	return result, nil
+}
+
+// IsLockfile tells if filename is lockfile instead of conda.yaml.
+func IsLockfile(filename string) bool {
+	_, err := ReadLockfile(filename)
+	return err == nil
+}
+
+// AsBlueprint is canonical form of lockfile, which is also valid explicit
+// spec file (pip requirements are there as comments).
+func (it *Lockfile) AsBlueprint() string {
+	lines := []string{lockfileHeader, explicitMarker}
+	lines = append(lines, it.Explicit...)
+	for _, requirement := range it.Pip {
+		lines = append(lines, lockedPip+requirement)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SaveExplicit writes conda packages as explicit spec file for resolver.
+func (it *Lockfile) SaveExplicit(filename string) error {
+	lines := append([]string{explicitMarker}, it.Explicit...)
+	return ioutil.WriteFile(filename, []byte(strings.Join(lines, "\n")+"\n"), 0o644)
+}
+
+// SaveRequirements writes pip packages as requirements file (which may be
+// empty).
+func (it *Lockfile) SaveRequirements(filename string) error {
+	requirements := it.Pip
+	if pipRequireHashes() {
+		requirements = make([]string, 0, len(it.Pip))
+		missing := []string{}
+		for _, requirement := range it.Pip {
+			hashed, ok := hashedLocked(requirement)
+			if !ok {
+				missing = append(missing, requirement)
+			}
+			requirements = append(requirements, hashed)
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("Pip hashes are required (pip-require-hashes), but these locked pip packages have no sha256: %s", strings.Join(missing, ", "))
+		}
+	}
+	content := ""
+	if len(requirements) > 0 {
+		content = strings.Join(requirements, "\n") + "\n"
+	}
+	return ioutil.WriteFile(filename, []byte(content), 0o644)
+}
+
+// LockfileBlueprint reads lockfile, and returns its canonical blueprint.
+func LockfileBlueprint(filename string) ([]byte, error) {
+	lockfile, err := ReadLockfile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(lockfile.AsBlueprint()), nil
+}
+
+func explicitSpec(filename string) bool {
+	content, err := ioutil.ReadFile(filename)
+	return err == nil && hasExplicitMarker(content)
+}
+
+func lockfileConfig(explicitfile, requirementsText, filename string) (string, string, error) {
+	lockfile, err := ReadLockfile(filename)
+	if err != nil {
+		return "", "", err
+	}
+	blueprint := lockfile.AsBlueprint()
+	common.Log("FINAL locked environment descriptor:\n---\n%v\n---", blueprint)
+	err = lockfile.SaveExplicit(explicitfile)
+	if err != nil {
+		return "", "", err
+	}
+	err = lockfile.SaveRequirements(requirementsText)
+	if err != nil {
+		return "", "", err
+	}
+	return common.ShortDigest(blueprint), blueprint, nil
+}
@@ -0,0 +1,52 @@
+package conda_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestCanReadCondaLockForThisPlatform(t *testing.T) {
+	if conda.LockPlatform() != "linux-64" {
+		t.Skip("Test lockfile has pip packages only for linux-64.")
+	}
+	must_be, wont_be := hamlet.Specifications(t)
+
+	wont_be.True(conda.IsLockfile("testdata/conda.yaml"))
+	must_be.True(conda.IsLockfile("testdata/conda-lock.yml"))
+
+	lockfile, err := conda.ReadLockfile("testdata/conda-lock.yml")
+	must_be.Nil(err)
+	must_be.Equal([]string{"https://conda.anaconda.org/conda-forge/linux-64/python-3.9.13-h2660328_0_cpython.tar.bz2#4a5ff4b2d9c5b5a4b0e7a9b2c0b1e3d4"}, lockfile.Explicit)
+	must_be.Equal(1, len(lockfile.Pip))
+	must_be.True(strings.HasPrefix(lockfile.Pip[0], "robotframework @ https://"))
+	must_be.True(strings.HasSuffix(lockfile.Pip[0], "#sha256=634cd6f9fdc21142eadd9aacdddf7ed9cdfa2ac14b8f7b6e5b1ab0e3c8a2b6c1"))
+}
+
+func TestLockfileBlueprintIsStableExplicitSpec(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	folder, err := ioutil.TempDir("", "lockfile")
+	must_be.Nil(err)
+	defer os.RemoveAll(folder)
+
+	explicit := filepath.Join(folder, "explicit.txt")
+	content := "# generated by conda\n@EXPLICIT\nhttps://conda.anaconda.org/conda-forge/noarch/tzdata-2022g-h191b570_0.conda#51fc4fcfb19f5d95ffc8c339db5068e8\n"
+	must_be.Nil(ioutil.WriteFile(explicit, []byte(content), 0o644))
+	must_be.True(conda.IsLockfile(explicit))
+
+	blueprint, err := conda.LockfileBlueprint(explicit)
+	must_be.Nil(err)
+	must_be.True(strings.Contains(string(blueprint), "\n@EXPLICIT\n"))
+
+	copied := filepath.Join(folder, "blueprint.txt")
+	must_be.Nil(ioutil.WriteFile(copied, blueprint, 0o644))
+	again, err := conda.LockfileBlueprint(copied)
+	must_be.Nil(err)
+	must_be.Equal(string(blueprint), string(again))
+}
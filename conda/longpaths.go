@@ -0,0 +1,64 @@
+package conda
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/robocorp/rcc/common"
+)
+
+// LongpathStatus is machine readable state of long path support.
+type LongpathStatus struct {
+	Platform string `json:"platform"`
+	Registry bool   `json:"registry"`
+	Working  bool   `json:"working"`
+	Elevated bool   `json:"elevated"`
+	Fixed    bool   `json:"fixed"`
+	Message  string `json:"message"`
+}
+
+// LongpathDiagnosis detects registry state and verifies it with test path.
+func LongpathDiagnosis() *LongpathStatus {
+	status := &LongpathStatus{
+		Platform: runtime.GOOS,
+		Elevated: IsElevated(),
+	}
+	registry, err := LongpathRegistryEnabled()
+	status.Registry = registry && err == nil
+	status.Working = HasLongPathSupport()
+	switch {
+	case err != nil:
+		status.Message = fmt.Sprintf("Could not read registry, reason: %v", err)
+	case status.Working:
+		status.Message = "Long paths are supported."
+	case status.Registry:
+		status.Message = "Registry has long paths enabled, but test path still fails. Reboot might be needed."
+	default:
+		status.Message = "Long paths are not enabled in registry."
+	}
+	return status
+}
+
+// FixLongpaths enables long path support, directly when process is already
+// elevated, and otherwise by asking elevation from user. Result is verified
+// with fresh diagnosis.
+func FixLongpaths() (*LongpathStatus, error) {
+	before := LongpathDiagnosis()
+	if before.Working {
+		return before, nil
+	}
+	var err error
+	if before.Elevated {
+		err = EnforceLongpathSupport()
+	} else {
+		common.Log("Not running as administrator, so requesting elevation for registry change.")
+		err = elevatedLongpathSupport()
+	}
+	after := LongpathDiagnosis()
+	if err != nil {
+		after.Message = fmt.Sprintf("Failure to modify registry: %v", err)
+		return after, err
+	}
+	after.Fixed = after.Registry && !before.Registry
+	return after, nil
+}
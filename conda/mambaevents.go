@@ -0,0 +1,224 @@
+package conda
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/shell"
+)
+
+// Resolver failure classes, derived from JSON result and log lines of
+// solver.
+const (
+	FailureNetwork = `network`
+	FailureSolver  = `solver`
+	FailureDisk    = `disk`
+	FailureTimeout = `timeout`
+	FailureUnknown = `unknown`
+
+	EventResolver = `resolver`
+)
+
+var (
+	diskMarkers = []string{
+		"no space left on device",
+		"disk quota exceeded",
+		"read-only file system",
+		"permission denied",
+		"not enough space on the disk",
+	}
+	solverExceptions = []string{
+		"packagesnotfound",
+		"resolvepackagenotfound",
+		"unsatisfiable",
+		"specsconfigurationconflict",
+	}
+	networkExceptions = []string{
+		"condahttperror",
+		"connectionerror",
+		"sslerror",
+	}
+	failureHints = map[string]string{
+		FailureNetwork: "check network connection, proxy settings, and channels (see rcc configure diagnostics)",
+		FailureSolver:  "check package names, versions, and channels in conda.yaml",
+		FailureDisk:    "check free disk space and permissions of ROBOCORP_HOME",
+		FailureTimeout: "check network connection, or raise micromamba-timeout",
+	}
+)
+
+// MambaResult is final JSON document, which micromamba (and conda) write
+// in JSON output mode.
+type MambaResult struct {
+	Success bool   `json:"success"`
+	DryRun  bool   `json:"dry_run"`
+	Prefix  string `json:"prefix"`
+	Actions struct {
+		Fetch []*dependency `json:"FETCH"`
+		Link  []*dependency `json:"LINK"`
+	} `json:"actions"`
+	SolverProblems []string `json:"solver_problems"`
+	Error          string   `json:"error"`
+	Message        string   `json:"message"`
+	ExceptionName  string   `json:"exception_name"`
+}
+
+// ResolverSummary is rcc-native view of one resolver run, given as details
+// of resolver event.
+type ResolverSummary struct {
+	Solver   string   `json:"solver"`
+	Code     int      `json:"code"`
+	Success  bool     `json:"success"`
+	Class    string   `json:"class,omitempty"`
+	Fetched  int      `json:"fetched"`
+	Linked   int      `json:"linked"`
+	Problems []string `json:"problems,omitempty"`
+	Hint     string   `json:"hint,omitempty"`
+}
+
+// parseMambaResult finds JSON document from resolver output, which may also
+// have log lines before and after it.
+func parseMambaResult(content []byte) (*MambaResult, bool) {
+	for offset := 0; offset < len(content); {
+		if content[offset] == '{' {
+			result := &MambaResult{}
+			decoder := json.NewDecoder(bytes.NewReader(content[offset:]))
+			if decoder.Decode(result) == nil {
+				return result, true
+			}
+		}
+		next := bytes.IndexByte(content[offset:], '\n')
+		if next < 0 {
+			break
+		}
+		offset += next + 1
+	}
+	return nil, false
+}
+
+// ResolverObserver extends transient failure detection with JSON result of
+// resolver and disk failure markers, so that failures can be classified.
+type ResolverObserver struct {
+	TransientObserver
+	disk   bool
+	output bytes.Buffer
+}
+
+func (it *ResolverObserver) Write(content []byte) (int, error) {
+	it.TransientObserver.Write(content)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		text := strings.ToLower(scanner.Text())
+		for _, marker := range diskMarkers {
+			if strings.Contains(text, marker) {
+				it.disk = true
+			}
+		}
+	}
+	return it.output.Write(content)
+}
+
+// Result returns JSON result of resolver, if there was one.
+func (it *ResolverObserver) Result() (*MambaResult, bool) {
+	return parseMambaResult(it.output.Bytes())
+}
+
+// Transient is like in TransientObserver, but solver problems in JSON result
+// and disk failures are never transient.
+func (it *ResolverObserver) Transient(code int) bool {
+	if code == shell.TimeoutExit {
+		return true
+	}
+	switch it.Classify(code) {
+	case FailureSolver, FailureDisk:
+		return false
+	}
+	return it.TransientObserver.Transient(code)
+}
+
+// Classify tells failure class of resolver run with exit code, or empty when
+// run was successful.
+func (it *ResolverObserver) Classify(code int) string {
+	if code == 0 {
+		return ""
+	}
+	if code == shell.TimeoutExit {
+		return FailureTimeout
+	}
+	if result, ok := it.Result(); ok {
+		exception := strings.ToLower(result.ExceptionName)
+		if len(result.SolverProblems) > 0 || containsAny(exception, solverExceptions) {
+			return FailureSolver
+		}
+		if containsAny(exception, networkExceptions) {
+			return FailureNetwork
+		}
+	}
+	switch {
+	case it.disk:
+		return FailureDisk
+	case it.permanent:
+		return FailureSolver
+	case it.transient:
+		return FailureNetwork
+	}
+	return FailureUnknown
+}
+
+func containsAny(text string, markers []string) bool {
+	for _, marker := range markers {
+		if strings.Contains(text, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary converts resolver run into rcc-native summary.
+func (it *ResolverObserver) Summary(solver string, code int) *ResolverSummary {
+	summary := &ResolverSummary{
+		Solver:  solver,
+		Code:    code,
+		Success: code == 0,
+		Class:   it.Classify(code),
+	}
+	summary.Hint = failureHints[summary.Class]
+	if result, ok := it.Result(); ok {
+		summary.Fetched = len(result.Actions.Fetch)
+		summary.Linked = len(result.Actions.Link)
+		summary.Problems = append(summary.Problems, result.SolverProblems...)
+		for _, message := range []string{result.Error, result.Message} {
+			if len(strings.TrimSpace(message)) > 0 {
+				summary.Problems = append(summary.Problems, strings.TrimSpace(message))
+			}
+		}
+	}
+	return summary
+}
+
+// Report shows summary of resolver run in log, and emits it as resolver
+// event for --json-progress.
+func (it *ResolverObserver) Report(solver string, code int) *ResolverSummary {
+	summary := it.Summary(solver, code)
+	common.EmitProgress(&common.ProgressEvent{
+		Event:   EventResolver,
+		Phase:   pretty.PhaseSolve,
+		Message: fmt.Sprintf("%s exited with code %d", solver, code),
+		Details: summary,
+	})
+	if summary.Success {
+		common.Debug("%s fetched %d and linked %d packages.", solver, summary.Fetched, summary.Linked)
+		return summary
+	}
+	for _, problem := range summary.Problems {
+		common.Log("%s%s problem: %s%s", pretty.Red, solver, problem, pretty.Reset)
+	}
+	if len(summary.Hint) > 0 {
+		common.Log("%s%s failure looks like %s failure, hint: %s.%s", pretty.Yellow, solver, summary.Class, summary.Hint, pretty.Reset)
+	}
+	return summary
+}
@@ -0,0 +1,115 @@
+package conda
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/shell"
+)
+
+const (
+	mambaSuccess = `warning  libmamba Cache file "repodata.json" was modified
+{
+    "actions": {
+        "FETCH": [
+            {"name": "python", "version": "3.9.13", "channel": "conda-forge"}
+        ],
+        "LINK": [
+            {"name": "python", "version": "3.9.13", "channel": "conda-forge"},
+            {"name": "pip", "version": "22.1.2", "channel": "conda-forge"}
+        ],
+        "PREFIX": "/tmp/env"
+    },
+    "dry_run": false,
+    "prefix": "/tmp/env",
+    "success": true
+}
+`
+	mambaProblems = `{
+    "solver_problems": ["nothing provides requested nodejs 99.*"],
+    "success": false
+}
+critical libmamba Could not solve for environment specs
+`
+)
+
+func TestMambaResultIsFoundFromOutput(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	result, ok := parseMambaResult([]byte(mambaSuccess))
+	must_be.True(ok)
+	must_be.True(result.Success)
+	must_be.Equal(1, len(result.Actions.Fetch))
+	must_be.Equal(2, len(result.Actions.Link))
+	must_be.Equal("pip", result.Actions.Link[1].Name)
+
+	_, ok = parseMambaResult([]byte("critical libmamba no json here\n"))
+	wont_be.True(ok)
+}
+
+func TestResolverObserverClassifiesFailures(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	success := &ResolverObserver{}
+	success.Write([]byte(mambaSuccess))
+	summary := success.Summary("micromamba", 0)
+	must_be.True(summary.Success)
+	must_be.Equal("", summary.Class)
+	must_be.Equal(1, summary.Fetched)
+	must_be.Equal(2, summary.Linked)
+
+	solver := &ResolverObserver{}
+	solver.Write([]byte(mambaProblems))
+	must_be.Equal(FailureSolver, solver.Classify(1))
+	wont_be.True(solver.Transient(1))
+	summary = solver.Summary("micromamba", 1)
+	must_be.Equal([]string{"nothing provides requested nodejs 99.*"}, summary.Problems)
+	wont_be.Equal("", summary.Hint)
+
+	network := &ResolverObserver{}
+	network.Write([]byte("critical libmamba Download error (6) Couldn't resolve host name\n"))
+	must_be.Equal(FailureNetwork, network.Classify(1))
+	must_be.True(network.Transient(1))
+
+	conda := &ResolverObserver{}
+	conda.Write([]byte(`{"exception_name": "CondaHTTPError", "error": "HTTP 000 CONNECTION FAILED"}`))
+	must_be.Equal(FailureNetwork, conda.Classify(1))
+
+	disk := &ResolverObserver{}
+	disk.Write([]byte("download error\nerror    libmamba [Errno 28] No space left on device\n"))
+	must_be.Equal(FailureDisk, disk.Classify(1))
+	wont_be.True(disk.Transient(1))
+
+	silent := &ResolverObserver{}
+	must_be.Equal(FailureUnknown, silent.Classify(1))
+	must_be.Equal(FailureTimeout, silent.Classify(shell.TimeoutExit))
+}
+
+func TestResolverFailuresHaveOwnExitCodes(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	must_be.Equal(failedMicromamba, resolverFailure(""))
+	must_be.Equal(failedMicromamba, resolverFailure(FailureUnknown))
+	must_be.Equal("micromamba-solver", resolverFailure(FailureSolver))
+
+	wrapped := fmt.Errorf("Failed to create environment, reason %w.", &BuildFailure{resolverFailure(FailureSolver), errors.New("boom")})
+	must_be.Equal(22, common.ExitCodeOf(wrapped, 4))
+	must_be.Equal(21, common.ExitCodeOf(&BuildFailure{resolverFailure(FailureNetwork), errors.New("boom")}, 4))
+	must_be.Equal(26, common.ExitCodeOf(&BuildFailure{failedPip, errors.New("boom")}, 4))
+	must_be.Equal(4, common.ExitCodeOf(&BuildFailure{failedOther, errors.New("boom")}, 4))
+	must_be.Equal(6, common.ExitCodeOf(errors.New("other"), 6))
+}
+
+func TestBuildFailuresShowErrorCodes(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	must_be.Equal("[RCC-ENV-SOLVE-001] boom", (&BuildFailure{resolverFailure(FailureSolver), errors.New("boom")}).Error())
+	must_be.Equal("boom", (&BuildFailure{failedOther, errors.New("boom")}).Error())
+	for reason, code := range failureCodes {
+		must_be.True(len(reason) > 0)
+		must_be.True(code != nil)
+	}
+}
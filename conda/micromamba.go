@@ -0,0 +1,161 @@
+package conda
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/robocorp/rcc/cloud"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+	"github.com/robocorp/rcc/xviper"
+)
+
+const (
+	micromambaPinKey     = `micromamba.pin`
+	micromambaDigestKey  = `micromamba.sha256`
+	micromambaDefault    = `v0.16.0`
+	micromambaMinimum    = 16000
+	micromambaDownloads  = `micromamba`
+	micromambaHexDigests = 64
+)
+
+func normalizedMicromamba(version string) string {
+	version = strings.TrimSpace(version)
+	if len(version) == 0 {
+		return ""
+	}
+	if !strings.HasPrefix(version, "v") {
+		return "v" + version
+	}
+	return version
+}
+
+// MicromambaPinned is version pinned with "rcc configure micromamba --pin",
+// or empty when there is no pin.
+func MicromambaPinned() string {
+	return normalizedMicromamba(xviper.GetString(micromambaPinKey))
+}
+
+// PinMicromamba makes given version used instead of one from settings;
+// empty version removes pin. Digest is known sha256 of pinned version for
+// this platform, or empty when it is not known.
+func PinMicromamba(version, digest string) {
+	xviper.Set(micromambaPinKey, normalizedMicromamba(version))
+	xviper.Set(micromambaDigestKey, strings.ToLower(strings.TrimSpace(digest)))
+}
+
+// IsMicromambaDigest tells if digest looks like sha256 hex digest.
+func IsMicromambaDigest(digest string) bool {
+	if len(digest) != micromambaHexDigests {
+		return false
+	}
+	_, err := hex.DecodeString(digest)
+	return err == nil
+}
+
+// MicromambaDigest is pinned sha256 of wanted micromamba for this platform:
+// one given with --pin, or one from micromamba-sha256 in settings (keyed
+// like "v0.16.0/linux64"). Empty when there is no pinned digest.
+func MicromambaDigest() string {
+	return MicromambaDigestOf(MicromambaWanted())
+}
+
+// MicromambaDigestOf is pinned sha256 of given micromamba version for this
+// platform, or empty when there is no pinned digest for it.
+func MicromambaDigestOf(version string) string {
+	version = normalizedMicromamba(version)
+	if pinned := MicromambaPinned(); len(pinned) > 0 && pinned == version {
+		if digest := xviper.GetString(micromambaDigestKey); len(digest) > 0 {
+			return digest
+		}
+	}
+	key := fmt.Sprintf("%s/%s", version, micromambaPlatform)
+	return strings.ToLower(strings.TrimSpace(settings.Global.MicromambaDigests()[key]))
+}
+
+// MicromambaWanted is micromamba version rcc downloads: pinned version, or
+// version from settings.
+func MicromambaWanted() string {
+	if pinned := MicromambaPinned(); len(pinned) > 0 {
+		return pinned
+	}
+	if configured := normalizedMicromamba(settings.Global.MicromambaVersion()); len(configured) > 0 {
+		return configured
+	}
+	return micromambaDefault
+}
+
+// MicromambaLink is download location of wanted micromamba version for this
+// platform, below configured channel.
+func MicromambaLink() string {
+	channel := strings.TrimRight(strings.TrimSpace(settings.Global.MicromambaChannel()), "/")
+	if len(channel) == 0 {
+		channel = micromambaDownloads
+	}
+	return settings.Global.DownloadsLink(fmt.Sprintf("%s/%s/%s/%s", channel, MicromambaWanted(), micromambaPlatform, micromambaName))
+}
+
+func systemMicromamba() (string, bool) {
+	if !settings.Global.SystemMicromamba() {
+		return "", false
+	}
+	found, err := exec.LookPath("micromamba")
+	if err != nil {
+		common.Debug("System micromamba requested, but not found from PATH, reason: %v", err)
+		return "", false
+	}
+	return found, true
+}
+
+// UsingSystemMicromamba tells if micromamba comes from PATH, and so rcc
+// neither downloads nor updates it.
+func UsingSystemMicromamba() bool {
+	_, ok := systemMicromamba()
+	return ok
+}
+
+// BinMicromamba is micromamba executable used: one from PATH when system
+// micromamba is configured and found, and downloaded one otherwise.
+func BinMicromamba() string {
+	if found, ok := systemMicromamba(); ok {
+		return found
+	}
+	return downloadedMicromamba()
+}
+
+// MicromambaOutdated tells if installed micromamba is other version than
+// wanted one. System micromamba is never outdated.
+func MicromambaOutdated() bool {
+	if UsingSystemMicromamba() {
+		return false
+	}
+	return MicromambaVersion() != strings.TrimPrefix(MicromambaWanted(), "v")
+}
+
+// micromambaChecksum fetches published sha256 of download link, if there is
+// one next to it (as "<link>.sha256"). Since it comes from same origin as
+// download itself, it only detects damaged downloads, not tampered ones.
+func micromambaChecksum(link string) string {
+	sidecar := link + ".sha256"
+	filename := cloud.DownloadLocation(sidecar, ".sha256")
+	defer os.Remove(filename)
+	err := cloud.Download(sidecar, filename)
+	if err != nil {
+		common.Debug("No checksum for %q available, reason: %v", link, err)
+		return ""
+	}
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 || len(fields[0]) != micromambaHexDigests {
+		common.Debug("Checksum file %q is not sha256 digest.", sidecar)
+		return ""
+	}
+	return strings.ToLower(fields[0])
+}
@@ -0,0 +1,62 @@
+package conda_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/settings"
+)
+
+func TestMicromambaLinkFollowsSettings(t *testing.T) {
+	if len(conda.MicromambaPinned()) > 0 {
+		t.Skip("Micromamba is pinned in this environment.")
+	}
+	must_be, wont_be := hamlet.Specifications(t)
+
+	config := settings.Global.Holotree()
+	version, channel := config.MicromambaVersion, config.MicromambaChannel
+	defer func() {
+		config.MicromambaVersion, config.MicromambaChannel = version, channel
+	}()
+
+	config.MicromambaVersion, config.MicromambaChannel = "", ""
+	must_be.Equal("v0.16.0", conda.MicromambaWanted())
+	must_be.True(strings.Contains(conda.MicromambaLink(), "/micromamba/v0.16.0/"))
+
+	config.MicromambaVersion = "0.17.0"
+	config.MicromambaChannel = "https://mirror.example.com/tools/micromamba/"
+	must_be.Equal("v0.17.0", conda.MicromambaWanted())
+	link := conda.MicromambaLink()
+	must_be.True(strings.HasPrefix(link, "https://mirror.example.com/tools/micromamba/v0.17.0/"))
+	wont_be.True(strings.Contains(link, "v0.16.0"))
+}
+
+func TestMicromambaDigestComesFromSettings(t *testing.T) {
+	if len(conda.MicromambaPinned()) > 0 {
+		t.Skip("Micromamba is pinned in this environment.")
+	}
+	must_be, wont_be := hamlet.Specifications(t)
+
+	config := settings.Global.Holotree()
+	version, digests := config.MicromambaVersion, config.MicromambaDigests
+	defer func() {
+		config.MicromambaVersion, config.MicromambaDigests = version, digests
+	}()
+
+	digest := strings.Repeat("ab", 32)
+	config.MicromambaVersion, config.MicromambaDigests = "", map[string]string{}
+	must_be.Equal("", conda.MicromambaDigest())
+	for _, platform := range []string{"linux64", "linuxarm64", "macos64", "macosarm64", "windows64"} {
+		config.MicromambaDigests["v0.16.0/"+platform] = strings.ToUpper(digest)
+	}
+	must_be.Equal(digest, conda.MicromambaDigest())
+	config.MicromambaVersion = "v0.17.0"
+	must_be.Equal("", conda.MicromambaDigest())
+	must_be.Equal(digest, conda.MicromambaDigestOf("0.16.0"))
+
+	must_be.True(conda.IsMicromambaDigest(digest))
+	wont_be.True(conda.IsMicromambaDigest("abc"))
+	wont_be.True(conda.IsMicromambaDigest(strings.Repeat("zz", 32)))
+}
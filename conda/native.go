@@ -0,0 +1,204 @@
+package conda
+
+import (
+	"debug/elf"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/settings"
+)
+
+// Native probe modes: do not probe, only warn about issues, or fail build.
+const (
+	NativeOff  = `off`
+	NativeWarn = `warn`
+	NativeFail = `fail`
+
+	nativeGlibc      = `glibc`
+	nativeMissing    = `missing-library`
+	nativeQuarantine = `quarantine`
+	glibcPrefix      = `GLIBC_`
+)
+
+var (
+	nativeTags = []string{NativeOff, NativeWarn, NativeFail}
+)
+
+// NativeIssue is one native library compatibility problem, with file
+// relative to environment root.
+type NativeIssue struct {
+	Kind   string `json:"kind"`
+	File   string `json:"file"`
+	Detail string `json:"detail"`
+}
+
+func (it *NativeIssue) String() string {
+	return fmt.Sprintf("%s: %s (%s)", it.Kind, it.File, it.Detail)
+}
+
+// NativeReport is structured result of native library probe.
+type NativeReport struct {
+	Platform string         `json:"platform"`
+	Glibc    string         `json:"glibc,omitempty"`
+	Scanned  int            `json:"scanned"`
+	Issues   []*NativeIssue `json:"issues"`
+	Notes    []string       `json:"notes"`
+}
+
+func (it *NativeReport) issue(kind, file, form string, details ...interface{}) {
+	it.Issues = append(it.Issues, &NativeIssue{
+		Kind:   kind,
+		File:   file,
+		Detail: fmt.Sprintf(form, details...),
+	})
+}
+
+func (it *NativeReport) note(form string, details ...interface{}) {
+	it.Notes = append(it.Notes, fmt.Sprintf(form, details...))
+}
+
+// NativeProbeMode tells if and how native libraries are probed after build.
+func NativeProbeMode() string {
+	mode := strings.ToLower(strings.TrimSpace(settings.Global.NativeProbe()))
+	for _, known := range nativeTags {
+		if mode == known {
+			return mode
+		}
+	}
+	return NativeOff
+}
+
+// ProbeNative scans native libraries of site-packages in environment for
+// glibc requirements newer than system has, missing shared objects, and
+// macOS quarantine attributes (depending on platform).
+func ProbeNative(targetFolder string) *NativeReport {
+	report := &NativeReport{
+		Platform: runtime.GOOS,
+		Issues:   []*NativeIssue{},
+		Notes:    []string{},
+	}
+	libraries := nativeLibraries(targetFolder)
+	report.Scanned = len(libraries)
+	if len(libraries) > 0 {
+		probeNativeFiles(report, targetFolder, libraries)
+	}
+	sort.SliceStable(report.Issues, func(left, right int) bool {
+		return report.Issues[left].File < report.Issues[right].File
+	})
+	return report
+}
+
+func isNativeLibrary(name string) bool {
+	return strings.HasSuffix(name, ".so") || strings.Contains(name, ".so.") || strings.HasSuffix(name, ".dylib")
+}
+
+// nativeLibraries lists native libraries in site-packages folders, relative
+// to environment root.
+func nativeLibraries(targetFolder string) []string {
+	result := []string{}
+	patterns := []string{
+		filepath.Join(targetFolder, "lib", "python*", "site-packages"),
+		filepath.Join(targetFolder, "Lib", "site-packages"),
+	}
+	for _, pattern := range patterns {
+		folders, _ := filepath.Glob(pattern)
+		for _, folder := range folders {
+			filepath.Walk(folder, func(fullpath string, info os.FileInfo, err error) error {
+				if err != nil || !info.Mode().IsRegular() || !isNativeLibrary(info.Name()) {
+					return nil
+				}
+				relative, err := filepath.Rel(targetFolder, fullpath)
+				if err == nil {
+					result = append(result, relative)
+				}
+				return nil
+			})
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// requiredGlibc returns newest glibc symbol version needed by ELF file, or
+// empty, when there is none.
+func requiredGlibc(filename string) (string, error) {
+	library, err := elf.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer library.Close()
+	symbols, err := library.ImportedSymbols()
+	if err != nil {
+		return "", err
+	}
+	newest := ""
+	for _, symbol := range symbols {
+		if !strings.HasPrefix(symbol.Version, glibcPrefix) {
+			continue
+		}
+		version := strings.TrimPrefix(symbol.Version, glibcPrefix)
+		if len(newest) == 0 || versionLess(newest, version) {
+			newest = version
+		}
+	}
+	return newest, nil
+}
+
+// versionLess compares dotted numeric versions, like "2.17" and "2.28".
+func versionLess(left, right string) bool {
+	lefts, rights := strings.Split(left, "."), strings.Split(right, ".")
+	for at := 0; at < len(lefts) || at < len(rights); at++ {
+		var first, second int
+		if at < len(lefts) {
+			first, _ = strconv.Atoi(lefts[at])
+		}
+		if at < len(rights) {
+			second, _ = strconv.Atoi(rights[at])
+		}
+		if first != second {
+			return first < second
+		}
+	}
+	return false
+}
+
+func nativeProbeAllows(sink io.Writer, targetFolder string) bool {
+	mode := NativeProbeMode()
+	if mode == NativeOff {
+		fmt.Fprintf(sink, "Native probe is off.\n")
+		return true
+	}
+	report := ProbeNative(targetFolder)
+	fmt.Fprintf(sink, "Native probe [%s] scanned %d libraries on %s.\n", mode, report.Scanned, report.Platform)
+	for _, note := range report.Notes {
+		fmt.Fprintf(sink, "Note: %s\n", note)
+		common.Debug("Native probe note: %s", note)
+	}
+	for _, issue := range report.Issues {
+		fmt.Fprintf(sink, "Issue: %s\n", issue)
+	}
+	if len(report.Issues) == 0 {
+		common.Timeline("native probe done.")
+		return true
+	}
+	if mode == NativeFail {
+		for _, issue := range report.Issues {
+			common.Log("%sNative library issue: %s%s", pretty.Red, issue, pretty.Reset)
+		}
+		common.Timeline("native probe fail.")
+		return false
+	}
+	for _, issue := range report.Issues {
+		pretty.Warning("Native library issue: %s", issue)
+	}
+	common.Timeline("native probe warned.")
+	return true
+}
@@ -0,0 +1,20 @@
+package conda
+
+import (
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	quarantineAttribute = `com.apple.quarantine`
+)
+
+func probeNativeFiles(report *NativeReport, targetFolder string, libraries []string) {
+	for _, library := range libraries {
+		size, err := unix.Getxattr(filepath.Join(targetFolder, library), quarantineAttribute, nil)
+		if err == nil && size >= 0 {
+			report.issue(nativeQuarantine, library, "has %s attribute, and Gatekeeper may refuse to load it", quarantineAttribute)
+		}
+	}
+}
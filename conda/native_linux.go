@@ -0,0 +1,64 @@
+package conda
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/robocorp/rcc/shell"
+)
+
+// systemGlibc asks glibc version of system, which is empty on systems
+// without glibc (like musl based ones).
+func systemGlibc() string {
+	output, err := exec.Command("getconf", "GNU_LIBC_VERSION").Output()
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) != 2 || fields[0] != "glibc" {
+		return ""
+	}
+	return fields[1]
+}
+
+// missingLibraries lists shared objects, which ldd could not find for file.
+func missingLibraries(ldd string, environment []string, fullpath string) []string {
+	output, _, _ := shell.New(environment, ".", ldd, fullpath).CaptureOutput()
+	result := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, "=> not found") {
+			continue
+		}
+		result = append(result, strings.TrimSpace(strings.Split(line, "=>")[0]))
+	}
+	return result
+}
+
+func probeNativeFiles(report *NativeReport, targetFolder string, libraries []string) {
+	report.Glibc = systemGlibc()
+	if len(report.Glibc) == 0 {
+		report.note("System glibc version is not known, so glibc requirements were not checked.")
+	}
+	ldd, err := exec.LookPath("ldd")
+	if err != nil {
+		report.note("No ldd available, so missing shared objects were not checked.")
+	}
+	environment := append(os.Environ(), "LD_LIBRARY_PATH="+filepath.Join(targetFolder, "lib"))
+	for _, library := range libraries {
+		fullpath := filepath.Join(targetFolder, library)
+		if len(report.Glibc) > 0 {
+			required, err := requiredGlibc(fullpath)
+			if err == nil && len(required) > 0 && versionLess(report.Glibc, required) {
+				report.issue(nativeGlibc, library, "needs glibc %s, but system has %s", required, report.Glibc)
+			}
+		}
+		if len(ldd) == 0 {
+			continue
+		}
+		for _, missing := range missingLibraries(ldd, environment, fullpath) {
+			report.issue(nativeMissing, library, "%s not found", missing)
+		}
+	}
+}
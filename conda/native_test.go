@@ -0,0 +1,68 @@
+package conda
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/settings"
+)
+
+func TestNativeVersionsCompareNumerically(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	must_be.True(versionLess("2.17", "2.28"))
+	must_be.True(versionLess("2.9", "2.17"))
+	must_be.True(versionLess("2.3", "2.3.4"))
+	wont_be.True(versionLess("2.28", "2.28"))
+	wont_be.True(versionLess("2.31", "2.28"))
+}
+
+func TestNativeProbeModeDefaultsToOff(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	config := settings.Global.Holotree()
+	original := config.NativeProbe
+	defer func() {
+		config.NativeProbe = original
+	}()
+
+	config.NativeProbe = ""
+	must_be.Equal(NativeOff, NativeProbeMode())
+	config.NativeProbe = " Fail "
+	must_be.Equal(NativeFail, NativeProbeMode())
+	config.NativeProbe = "explode"
+	must_be.Equal(NativeOff, NativeProbeMode())
+}
+
+func TestNativeProbeScansSitePackages(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Only linux has ELF libraries to probe.")
+	}
+	must_be, wont_be := hamlet.Specifications(t)
+
+	system, _ := filepath.Glob("/lib/*/libz.so.1")
+	if len(system) == 0 {
+		t.Skip("No system libz to probe.")
+	}
+	content, err := ioutil.ReadFile(system[0])
+	must_be.Nil(err)
+
+	root := t.TempDir()
+	library := filepath.Join(root, "lib", "python3.9", "site-packages", "zlibby", "libz.so.1")
+	must_be.Nil(os.MkdirAll(filepath.Dir(library), 0o755))
+	must_be.Nil(ioutil.WriteFile(library, content, 0o644))
+	must_be.Nil(ioutil.WriteFile(filepath.Join(filepath.Dir(library), "__init__.py"), []byte{}, 0o644))
+
+	must_be.Equal([]string{filepath.Join("lib", "python3.9", "site-packages", "zlibby", "libz.so.1")}, nativeLibraries(root))
+	required, err := requiredGlibc(library)
+	must_be.Nil(err)
+	wont_be.Equal("", required)
+
+	report := ProbeNative(root)
+	must_be.Equal(1, report.Scanned)
+	must_be.Equal(0, len(report.Issues))
+}
@@ -0,0 +1,5 @@
+package conda
+
+func probeNativeFiles(report *NativeReport, targetFolder string, libraries []string) {
+	report.note("Native library probe has no checks on Windows.")
+}
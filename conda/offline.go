@@ -0,0 +1,77 @@
+package conda
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pretty"
+)
+
+var (
+	offlineMarkers = []string{
+		"nothing provides",
+		"does not exist (perhaps",
+		"could not find a version that satisfies",
+		"no matching distribution found",
+		"couldn't resolve host",
+		"download error",
+		"package cache does not contain",
+	}
+)
+
+// OfflineObserver collects lines from micromamba and pip output, which tell
+// that something was needed from network, when running with --offline.
+type OfflineObserver struct {
+	missing []string
+	seen    map[string]bool
+}
+
+func NewOfflineObserver() *OfflineObserver {
+	return &OfflineObserver{
+		missing: make([]string, 0, 10),
+		seen:    make(map[string]bool),
+	}
+}
+
+func (it *OfflineObserver) Write(content []byte) (int, error) {
+	if !common.OfflineFlag {
+		return len(content), nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		text := strings.ToLower(line)
+		for _, marker := range offlineMarkers {
+			if strings.Contains(text, marker) && !it.seen[line] {
+				it.seen[line] = true
+				it.missing = append(it.missing, line)
+				break
+			}
+		}
+	}
+	return len(content), nil
+}
+
+// Missing lists recognized lines about packages not found from local caches.
+func (it *OfflineObserver) Missing() []string {
+	return it.missing
+}
+
+// Report tells what was missing from local caches, and returns true if there
+// was something to report.
+func (it *OfflineObserver) Report(phase string) bool {
+	if !common.OfflineFlag {
+		return false
+	}
+	common.Log("%sOffline mode: %s could not find everything from local caches.%s", pretty.Red, phase, pretty.Reset)
+	if len(it.missing) == 0 {
+		common.Log("%s  (no missing packages recognized, see output above)%s", pretty.Red, pretty.Reset)
+	}
+	for _, line := range it.missing {
+		common.Log("%s  - %s%s", pretty.Red, line, pretty.Reset)
+	}
+	common.Log("%sOffline mode: import missing packages into %q (or hololib catalogs with \"rcc holotree import\") and try again.%s", pretty.Red, PackagesCache(), pretty.Reset)
+	return true
+}
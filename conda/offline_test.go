@@ -0,0 +1,43 @@
+package conda_test
+
+import (
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestOfflineObserverCollectsMissingPackages(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	output := []byte(`Looking for: ['python=3.9.13', 'nodejs']
+
+Encountered problems while solving:
+  - nothing provides requested nodejs
+  - nothing provides requested nodejs
+ERROR: Could not find a version that satisfies the requirement robotframework==6.0.2 (from versions: none)
+ERROR: No matching distribution found for robotframework==6.0.2
+`)
+
+	backup := common.OfflineFlag
+	defer func() {
+		common.OfflineFlag = backup
+	}()
+
+	common.OfflineFlag = false
+	online := conda.NewOfflineObserver()
+	online.Write(output)
+	must_be.Equal(0, len(online.Missing()))
+	wont_be.True(online.Report("micromamba"))
+
+	common.OfflineFlag = true
+	offline := conda.NewOfflineObserver()
+	offline.Write(output)
+	must_be.Equal([]string{
+		"- nothing provides requested nodejs",
+		"ERROR: Could not find a version that satisfies the requirement robotframework==6.0.2 (from versions: none)",
+		"ERROR: No matching distribution found for robotframework==6.0.2",
+	}, offline.Missing())
+	must_be.True(offline.Report("micromamba"))
+}
@@ -0,0 +1,142 @@
+package conda
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PEP 440 versions: [N!]N(.N)*[{a|b|rc}N][.postN][.devN][+local], with
+// alternative spellings and separators normalized as PEP 440 describes.
+var (
+	pep440Pattern = regexp.MustCompile(`^v?(?:(\d+)!)?(\d+(?:\.\d+)*)` +
+		`(?:[-_.]?(a|b|c|rc|alpha|beta|pre|preview)[-_.]?(\d*))?` +
+		`(?:-(\d+)|[-_.]?(post|rev|r)[-_.]?(\d*))?` +
+		`(?:[-_.]?(dev)[-_.]?(\d*))?` +
+		`(?:\+([a-z0-9]+(?:[-_.][a-z0-9]+)*))?$`)
+	prePhases = map[string]int{"a": 0, "alpha": 0, "b": 1, "beta": 1, "c": 2, "rc": 2, "pre": 2, "preview": 2}
+)
+
+const (
+	noSegment = math.MinInt64
+	endless   = math.MaxInt64
+)
+
+type pep440Version struct {
+	epoch   int64
+	release []int64
+	phase   int64
+	pre     int64
+	post    int64
+	dev     int64
+	local   []string
+}
+
+func optionalNumber(text string) int64 {
+	number, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return number
+}
+
+func parsePep440(text string) (*pep440Version, bool) {
+	match := pep440Pattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(text)))
+	if match == nil {
+		return nil, false
+	}
+	result := &pep440Version{
+		epoch: optionalNumber(match[1]),
+		phase: endless,
+		pre:   endless,
+		post:  noSegment,
+		dev:   endless,
+	}
+	for _, part := range strings.Split(match[2], ".") {
+		result.release = append(result.release, optionalNumber(part))
+	}
+	for len(result.release) > 1 && result.release[len(result.release)-1] == 0 {
+		result.release = result.release[:len(result.release)-1]
+	}
+	if len(match[3]) > 0 {
+		result.phase, result.pre = int64(prePhases[match[3]]), optionalNumber(match[4])
+	}
+	switch {
+	case len(match[5]) > 0:
+		result.post = optionalNumber(match[5])
+	case len(match[6]) > 0:
+		result.post = optionalNumber(match[7])
+	}
+	if len(match[8]) > 0 {
+		result.dev = optionalNumber(match[9])
+		// "1.0.dev1" is before "1.0a1", but "1.0.post1.dev1" is after "1.0"
+		if result.phase == endless && result.post == noSegment {
+			result.phase, result.pre = noSegment, noSegment
+		}
+	}
+	if len(match[10]) > 0 {
+		result.local = strings.FieldsFunc(match[10], func(char rune) bool {
+			return char == '.' || char == '-' || char == '_'
+		})
+	}
+	return result, true
+}
+
+func compareNumbers(left, right int64) int {
+	switch {
+	case left < right:
+		return -1
+	case left > right:
+		return 1
+	}
+	return 0
+}
+
+// compareLocal orders local segments: numeric ones after alphanumeric ones,
+// and longer label after its own prefix.
+func compareLocal(left, right []string) int {
+	for at := 0; at < len(left) && at < len(right); at++ {
+		first, ferr := strconv.ParseInt(left[at], 10, 64)
+		second, serr := strconv.ParseInt(right[at], 10, 64)
+		switch {
+		case ferr == nil && serr == nil:
+			if compared := compareNumbers(first, second); compared != 0 {
+				return compared
+			}
+		case ferr == nil:
+			return 1
+		case serr == nil:
+			return -1
+		default:
+			if compared := strings.Compare(left[at], right[at]); compared != 0 {
+				return compared
+			}
+		}
+	}
+	return compareNumbers(int64(len(left)), int64(len(right)))
+}
+
+func (it *pep440Version) compare(other *pep440Version) int {
+	if compared := compareNumbers(it.epoch, other.epoch); compared != 0 {
+		return compared
+	}
+	for at := 0; at < len(it.release) || at < len(other.release); at++ {
+		left, right := int64(0), int64(0)
+		if at < len(it.release) {
+			left = it.release[at]
+		}
+		if at < len(other.release) {
+			right = other.release[at]
+		}
+		if compared := compareNumbers(left, right); compared != 0 {
+			return compared
+		}
+	}
+	for _, pair := range [][2]int64{{it.phase, other.phase}, {it.pre, other.pre}, {it.post, other.post}, {it.dev, other.dev}} {
+		if compared := compareNumbers(pair[0], pair[1]); compared != 0 {
+			return compared
+		}
+	}
+	return compareLocal(it.local, other.local)
+}
@@ -0,0 +1,62 @@
+package conda
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/robocorp/rcc/pretty"
+)
+
+var (
+	resolverPhaseNames   = []string{pretty.PhaseSolve, pretty.PhaseFetch, pretty.PhaseLink}
+	resolverPhaseMarkers = [][]string{
+		nil,
+		[]string{"transaction starting", "downloading and extracting packages"},
+		[]string{"linking ", "preparing transaction", "executing transaction"},
+	}
+)
+
+// resolverPhases follows solver output, and moves from solve phase into
+// fetch and link phases, when solver tells that it does so. Solvers do all
+// three in one command, so boundaries are only as good as solver output is.
+type resolverPhases struct {
+	sync.Mutex
+	solver  string
+	index   int
+	current *pretty.Phase
+}
+
+func newResolverPhases(solver string) *resolverPhases {
+	return &resolverPhases{
+		solver:  solver,
+		current: pretty.StartPhase(pretty.PhaseSolve, "Running %s.", solver),
+	}
+}
+
+func (it *resolverPhases) Write(content []byte) (int, error) {
+	text := strings.ToLower(string(content))
+	it.Lock()
+	defer it.Unlock()
+	for next := len(resolverPhaseMarkers) - 1; next > it.index; next-- {
+		for _, marker := range resolverPhaseMarkers[next] {
+			if strings.Contains(text, marker) {
+				it.advance(next)
+				return len(content), nil
+			}
+		}
+	}
+	return len(content), nil
+}
+
+func (it *resolverPhases) advance(next int) {
+	it.current.Done()
+	it.index = next
+	it.current = pretty.StartPhase(resolverPhaseNames[next], "Output of %s moved to %s phase.", it.solver, resolverPhaseNames[next])
+}
+
+// Done ends whatever phase solver was in.
+func (it *resolverPhases) Done() {
+	it.Lock()
+	defer it.Unlock()
+	it.current.Done()
+}
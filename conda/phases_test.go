@@ -0,0 +1,30 @@
+package conda
+
+import (
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/pretty"
+)
+
+func TestResolverPhasesFollowSolverOutput(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	phases := newResolverPhases("micromamba")
+	must_be.Equal(pretty.PhaseSolve, phases.current.Name())
+	phases.Write([]byte("conda-forge/linux-64    Using cache\n"))
+	must_be.Equal(pretty.PhaseSolve, phases.current.Name())
+	phases.Write([]byte("\nTransaction starting\n"))
+	must_be.Equal(pretty.PhaseFetch, phases.current.Name())
+	phases.Write([]byte("Linking python-3.9.13-h2660328_0_cpython\n"))
+	must_be.Equal(pretty.PhaseLink, phases.current.Name())
+	phases.Write([]byte("Transaction starting\n"))
+	must_be.Equal(pretty.PhaseLink, phases.current.Name())
+	phases.Done()
+	phases.Done()
+
+	phases = newResolverPhases("conda")
+	phases.Write([]byte("Preparing transaction: done\n"))
+	must_be.Equal(pretty.PhaseLink, phases.current.Name())
+	phases.Done()
+}
@@ -0,0 +1,101 @@
+package conda
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+)
+
+const (
+	pipTokenUser = `__token__`
+	redactedText = `***`
+)
+
+// ConfiguredPipIndex tells if settings replace pypi endpoint for pip.
+func ConfiguredPipIndex() bool {
+	index := settings.Global.PipIndexURL()
+	return index != nil && len(strings.TrimSpace(index.URL)) > 0
+}
+
+// authenticatedIndex adds token into index URL as basic auth password, with
+// "__token__" as username, when no username is given.
+func authenticatedIndex(index *settings.PipIndex) (string, error) {
+	name := strings.TrimSpace(index.URL)
+	if len(name) == 0 {
+		return "", fmt.Errorf("Pip index entry without url in settings.")
+	}
+	token, err := secretToken(fmt.Sprintf("pip index %q", name), index.TokenEnv, index.Keyring)
+	if err != nil {
+		return "", err
+	}
+	if len(token) == 0 {
+		return name, nil
+	}
+	link, err := url.Parse(name)
+	if err != nil || len(link.Host) == 0 {
+		return "", fmt.Errorf("Pip index %q has token, but it is not URL.", name)
+	}
+	username := strings.TrimSpace(index.Username)
+	if len(username) == 0 {
+		username = pipTokenUser
+	}
+	link.User = url.UserPassword(username, token)
+	return link.String(), nil
+}
+
+// withPipIndexes adds index options (with credentials applied) into pip
+// command. Pip is run in isolated mode, so these must be command line options
+// instead of environment variables or pip.conf settings.
+func withPipIndexes(command *common.Commander) error {
+	index := settings.Global.PypiURL()
+	if ConfiguredPipIndex() {
+		link, err := authenticatedIndex(settings.Global.PipIndexURL())
+		if err != nil {
+			return err
+		}
+		index = link
+	}
+	command.Option("--index-url", index)
+	for _, extra := range settings.Global.PipExtraIndexURLs() {
+		link, err := authenticatedIndex(extra)
+		if err != nil {
+			return err
+		}
+		command.Option("--extra-index-url", link)
+	}
+	command.Option("--trusted-host", settings.Global.PypiTrustedHost())
+	return nil
+}
+
+// PipIndexNames returns configured pip indexes, without tokens, so that they
+// can be shown and logged.
+func PipIndexNames() []string {
+	result := []string{}
+	if ConfiguredPipIndex() {
+		result = append(result, strings.TrimSpace(settings.Global.PipIndexURL().URL))
+	}
+	for _, extra := range settings.Global.PipExtraIndexURLs() {
+		result = append(result, strings.TrimSpace(extra.URL))
+	}
+	return result
+}
+
+// redactedCommand hides passwords of URLs in command, so that command can be
+// written into plan and log files.
+func redactedCommand(command []string) []string {
+	result := make([]string, 0, len(command))
+	for _, part := range command {
+		link, err := url.Parse(part)
+		if err == nil && link.User != nil {
+			if _, ok := link.User.Password(); ok {
+				link.User = url.UserPassword(link.User.Username(), redactedText)
+				part = strings.Replace(link.String(), url.QueryEscape(redactedText), redactedText, 1)
+			}
+		}
+		result = append(result, part)
+	}
+	return result
+}
@@ -0,0 +1,122 @@
+package conda
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/settings"
+)
+
+const (
+	sharedPackagesMode = os.ModeSetgid | 0o775
+	sharedFileMode     = 0o664
+)
+
+var (
+	sharedPackagesLock   sync.Mutex
+	sharedPackagesStatus = make(map[string]error)
+)
+
+// SharedPackagesCache returns location of conda package cache shared by all
+// users of machine, or empty, when there is none (or it is not usable).
+func SharedPackagesCache() string {
+	location := strings.TrimSpace(settings.Global.SharedPackages())
+	if len(location) == 0 {
+		return ""
+	}
+	location = common.ExpandPath(location)
+	sharedPackagesLock.Lock()
+	err, ok := sharedPackagesStatus[location]
+	if !ok {
+		err = prepareSharedPackages(location)
+		sharedPackagesStatus[location] = err
+	}
+	sharedPackagesLock.Unlock()
+	if err != nil {
+		common.Debug("Shared package cache %q is not usable, reason: %v", location, err)
+		return ""
+	}
+	return location
+}
+
+// PackagesCache is conda package cache in use, either shared one or user's
+// own one.
+func PackagesCache() string {
+	if shared := SharedPackagesCache(); len(shared) > 0 {
+		return shared
+	}
+	return common.MambaPackages()
+}
+
+// prepareSharedPackages creates shared cache, and makes sure that new files
+// and directories in it belong to same group (setgid), when it is owned by
+// current user. Others just need to be able to write there.
+func prepareSharedPackages(location string) error {
+	for _, directory := range []string{location, filepath.Join(location, ".locks")} {
+		_, err := pathlib.EnsureDirectory(directory)
+		if err != nil {
+			return err
+		}
+		stat, err := os.Stat(directory)
+		if err != nil {
+			return err
+		}
+		if stat.Mode()&sharedPackagesMode != sharedPackagesMode {
+			// fails when directory is owned by someone else, which is ok
+			os.Chmod(directory, sharedPackagesMode)
+		}
+	}
+	probe := filepath.Join(location, fmt.Sprintf(".probe_%s", <-common.Identities))
+	err := os.WriteFile(probe, []byte{}, sharedFileMode)
+	if err != nil {
+		return fmt.Errorf("%q is not writable, reason: %v", location, err)
+	}
+	return os.Remove(probe)
+}
+
+// packagesEnvironment points micromamba (and conda) to shared package cache.
+func packagesEnvironment() []string {
+	shared := SharedPackagesCache()
+	if len(shared) == 0 {
+		return []string{}
+	}
+	return []string{fmt.Sprintf("CONDA_PKGS_DIRS=%s", shared)}
+}
+
+// withSharedPackages runs work so that files created into shared package
+// cache are usable by other users too.
+func withSharedPackages(work func() error) error {
+	if len(SharedPackagesCache()) > 0 {
+		defer sharedUmask()()
+	}
+	return work()
+}
+
+// storePackage moves downloaded package file atomically into cache. Writers
+// of same file are serialized with lock, and file already there wins.
+func storePackage(cache, partname, filename string) (err error) {
+	locks, err := pathlib.EnsureDirectory(filepath.Join(cache, ".locks"))
+	if err != nil {
+		return err
+	}
+	locker, err := pathlib.KeyLocker(locks, filename, 30000)
+	if err != nil {
+		return err
+	}
+	defer locker.Release()
+
+	target := filepath.Join(cache, filename)
+	if pathlib.IsFile(target) {
+		return os.Remove(partname)
+	}
+	err = os.Chmod(partname, sharedFileMode)
+	if err != nil {
+		return err
+	}
+	return os.Rename(partname, target)
+}
@@ -0,0 +1,52 @@
+package conda
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/settings"
+)
+
+func TestSharedPackagesCacheIsGroupWritable(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	folder, err := ioutil.TempDir("", "pkgs")
+	must_be.Nil(err)
+	defer os.RemoveAll(folder)
+
+	config := settings.Global.Holotree()
+	original := config.SharedPackages
+	defer func() {
+		config.SharedPackages = original
+	}()
+
+	config.SharedPackages = ""
+	must_be.Equal(common.MambaPackages(), PackagesCache())
+	must_be.Equal(0, len(packagesEnvironment()))
+
+	shared := filepath.Join(folder, "shared", "pkgs")
+	config.SharedPackages = shared
+	must_be.Equal(shared, PackagesCache())
+	must_be.Equal([]string{"CONDA_PKGS_DIRS=" + shared}, packagesEnvironment())
+	if !IsWindows() {
+		stat, err := os.Stat(shared)
+		must_be.Nil(err)
+		must_be.Equal(sharedPackagesMode, stat.Mode()&sharedPackagesMode)
+	}
+
+	first := filepath.Join(folder, "first.part")
+	second := filepath.Join(folder, "second.part")
+	must_be.Nil(ioutil.WriteFile(first, []byte("first"), 0o600))
+	must_be.Nil(ioutil.WriteFile(second, []byte("second"), 0o600))
+	must_be.Nil(storePackage(shared, first, "foo-1.0-0.conda"))
+	must_be.Nil(storePackage(shared, second, "foo-1.0-0.conda"))
+	content, err := ioutil.ReadFile(filepath.Join(shared, "foo-1.0-0.conda"))
+	must_be.Nil(err)
+	must_be.Equal("first", string(content))
+	_, err = os.Stat(second)
+	wont_be.Nil(err)
+}
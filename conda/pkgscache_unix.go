@@ -0,0 +1,18 @@
+//go:build darwin || linux || !windows
+// +build darwin linux !windows
+
+package conda
+
+import (
+	"syscall"
+)
+
+// sharedUmask makes files created while it is active group-writable, so that
+// other users of shared package cache can use them. Returned function
+// restores original umask.
+func sharedUmask() func() {
+	original := syscall.Umask(0o002)
+	return func() {
+		syscall.Umask(original)
+	}
+}
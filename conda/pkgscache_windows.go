@@ -0,0 +1,10 @@
+//go:build windows
+// +build windows
+
+package conda
+
+// sharedUmask is not needed on Windows, where shared package cache gets its
+// permissions from ACLs of its parent directory.
+func sharedUmask() func() {
+	return func() {}
+}
@@ -6,17 +6,20 @@ import (
 	"path/filepath"
 
 	"github.com/robocorp/rcc/common"
-	"github.com/robocorp/rcc/settings"
 )
 
 const (
-	Newline        = "\n"
-	binSuffix      = "/bin"
-	activateScript = `#!/bin/bash
+	micromambaPlatform = "macos64"
+	micromambaName     = "micromamba"
+	Newline            = "\n"
+	binSuffix          = "/bin"
+	activateScript     = `#!/bin/bash
 
 export MAMBA_ROOT_PREFIX={{.Robocorphome}}
-eval "$('{{.Micromamba}}' shell activate -s bash -p {{.Live}})"
-"{{.Rcc}}" internal env -l after
+{{range .PreActivate}}{{.}}
+{{end}}eval "$('{{.Micromamba}}' shell activate -s bash -p {{.Live}})"
+{{range .PostActivate}}{{.}}
+{{end}}"{{.Rcc}}" internal env -l after
 `
 	commandSuffix = ".sh"
 )
@@ -28,25 +31,23 @@ var (
 
 func CondaEnvironment() []string {
 	env := os.Environ()
-	env = append(env, fmt.Sprintf("MAMBA_ROOT_PREFIX=%s", common.RobocorpHome()))
+	env = append(env, fmt.Sprintf("MAMBA_ROOT_PREFIX=%s", common.WritableHome()))
 	tempFolder := common.RobocorpTemp()
 	env = append(env, fmt.Sprintf("TEMP=%s", tempFolder))
 	env = append(env, fmt.Sprintf("TMP=%s", tempFolder))
+	env = append(env, ProfileEnvironment(nil)...)
+	env = append(env, packagesEnvironment()...)
 	return env
 }
 
-func BinMicromamba() string {
-	return common.ExpandPath(filepath.Join(common.BinLocation(), "micromamba"))
+func downloadedMicromamba() string {
+	return common.ExpandPath(filepath.Join(common.BinLocation(), micromambaName))
 }
 
 func CondaPaths(prefix string) []string {
 	return []string{prefix + binSuffix}
 }
 
-func MicromambaLink() string {
-	return settings.Global.DownloadsLink("micromamba/v0.16.0/macos64/micromamba")
-}
-
 func IsWindows() bool {
 	return false
 }
@@ -58,3 +59,15 @@ func HasLongPathSupport() bool {
 func EnforceLongpathSupport() error {
 	return nil
 }
+
+func LongpathRegistryEnabled() (bool, error) {
+	return true, nil
+}
+
+func IsElevated() bool {
+	return os.Geteuid() == 0
+}
+
+func elevatedLongpathSupport() error {
+	return nil
+}
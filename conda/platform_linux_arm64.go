@@ -0,0 +1,73 @@
+package conda
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/robocorp/rcc/common"
+)
+
+const (
+	micromambaPlatform = "linuxarm64"
+	micromambaName     = "micromamba"
+	Newline            = "\n"
+	binSuffix          = "/bin"
+	activateScript     = `#!/bin/bash
+
+export MAMBA_ROOT_PREFIX={{.Robocorphome}}
+{{range .PreActivate}}{{.}}
+{{end}}eval "$('{{.Micromamba}}' shell activate -s bash -p {{.Live}})"
+{{range .PostActivate}}{{.}}
+{{end}}"{{.Rcc}}" internal env -l after
+`
+	commandSuffix = ".sh"
+)
+
+var (
+	FileExtensions = []string{""}
+	Shell          = []string{"bash", "--noprofile", "--norc", "-i"}
+)
+
+func CondaEnvironment() []string {
+	env := os.Environ()
+	env = append(env, fmt.Sprintf("MAMBA_ROOT_PREFIX=%s", common.WritableHome()))
+	tempFolder := common.RobocorpTemp()
+	env = append(env, fmt.Sprintf("TEMP=%s", tempFolder))
+	env = append(env, fmt.Sprintf("TMP=%s", tempFolder))
+	env = append(env, ProfileEnvironment(nil)...)
+	env = append(env, packagesEnvironment()...)
+	return env
+}
+
+func downloadedMicromamba() string {
+	return common.ExpandPath(filepath.Join(common.BinLocation(), micromambaName))
+}
+
+func CondaPaths(prefix string) []string {
+	return []string{common.ExpandPath(prefix + binSuffix)}
+}
+
+func IsWindows() bool {
+	return false
+}
+
+func HasLongPathSupport() bool {
+	return true
+}
+
+func EnforceLongpathSupport() error {
+	return nil
+}
+
+func LongpathRegistryEnabled() (bool, error) {
+	return true, nil
+}
+
+func IsElevated() bool {
+	return os.Geteuid() == 0
+}
+
+func elevatedLongpathSupport() error {
+	return nil
+}
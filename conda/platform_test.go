@@ -36,3 +36,22 @@ func TestFlagsAreCorrectlySet(t *testing.T) {
 
 	wont_be.True(conda.IsWindows())
 }
+
+func TestLongpathDiagnosisOnNonWindows(t *testing.T) {
+	if conda.IsWindows() {
+		t.Skip("Not a windows test.")
+	}
+	must_be, wont_be := hamlet.Specifications(t)
+
+	status := conda.LongpathDiagnosis()
+	wont_be.Nil(status)
+	must_be.True(status.Registry)
+	must_be.True(status.Working)
+	wont_be.True(status.Fixed)
+	must_be.Equal("Long paths are supported.", status.Message)
+
+	fixed, err := conda.FixLongpaths()
+	must_be.Nil(err)
+	must_be.True(fixed.Working)
+	wont_be.True(fixed.Fixed)
+}
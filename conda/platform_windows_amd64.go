@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"golang.org/x/sys/windows"
 	"golang.org/x/sys/windows/registry"
 
 	"github.com/robocorp/rcc/common"
@@ -14,22 +15,24 @@ import (
 )
 
 const (
-	mingwSuffix    = "\\mingw-w64"
-	Newline        = "\r\n"
-	librarySuffix  = "\\Library"
-	scriptSuffix   = "\\Scripts"
-	usrSuffix      = "\\usr"
-	binSuffix      = "\\bin"
-	activateScript = "@echo off\n" +
+	micromambaPlatform = "windows64"
+	micromambaName     = "micromamba.exe"
+	mingwSuffix        = "\\mingw-w64"
+	Newline            = "\r\n"
+	librarySuffix      = "\\Library"
+	scriptSuffix       = "\\Scripts"
+	usrSuffix          = "\\usr"
+	binSuffix          = "\\bin"
+	activateScript     = "@echo off\n" +
 		"set \"MAMBA_ROOT_PREFIX={{.Robocorphome}}\"\n" +
-		"for /f \"tokens=* usebackq\" %%a in ( `call \"{{.Robocorphome}}\\bin\\micromamba.exe\" shell -s cmd.exe activate -p \"{{.Live}}\"` ) do ( call \"%%a\" )\n" +
+		"{{range .PreActivate}}{{.}}\n{{end}}" +
+		"for /f \"tokens=* usebackq\" %%a in ( `call \"{{.Micromamba}}\" shell -s cmd.exe activate -p \"{{.Live}}\"` ) do ( call \"%%a\" )\n" +
+		"{{range .PostActivate}}{{.}}\n{{end}}" +
 		"call \"{{.Rcc}}\" internal env -l after\n"
 	commandSuffix = ".cmd"
-)
 
-func MicromambaLink() string {
-	return settings.Global.DownloadsLink("micromamba/v0.16.0/windows64/micromamba.exe")
-}
+	longpathRegistryKey = `SYSTEM\CurrentControlSet\Control\FileSystem`
+)
 
 var (
 	Shell          = []string{"cmd.exe", "/K"}
@@ -38,15 +41,17 @@ var (
 
 func CondaEnvironment() []string {
 	env := os.Environ()
-	env = append(env, fmt.Sprintf("MAMBA_ROOT_PREFIX=%s", common.RobocorpHome()))
+	env = append(env, fmt.Sprintf("MAMBA_ROOT_PREFIX=%s", common.WritableHome()))
 	tempFolder := common.RobocorpTemp()
 	env = append(env, fmt.Sprintf("TEMP=%s", tempFolder))
 	env = append(env, fmt.Sprintf("TMP=%s", tempFolder))
+	env = append(env, ProfileEnvironment(nil)...)
+	env = append(env, packagesEnvironment()...)
 	return env
 }
 
-func BinMicromamba() string {
-	return common.ExpandPath(filepath.Join(common.BinLocation(), "micromamba.exe"))
+func downloadedMicromamba() string {
+	return common.ExpandPath(filepath.Join(common.BinLocation(), micromambaName))
 }
 
 func CondaPaths(prefix string) []string {
@@ -85,8 +90,36 @@ func HasLongPathSupport() bool {
 	return true
 }
 
+func LongpathRegistryEnabled() (bool, error) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, longpathRegistryKey, registry.QUERY_VALUE)
+	if err != nil {
+		return false, err
+	}
+	defer key.Close()
+	value, _, err := key.GetIntegerValue("LongPathsEnabled")
+	if err == registry.ErrNotExist {
+		return false, nil
+	}
+	return value == 1, err
+}
+
+func IsElevated() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+}
+
+// elevatedLongpathSupport asks user (with UAC prompt) to run registry change
+// as administrator.
+func elevatedLongpathSupport() error {
+	script := fmt.Sprintf("Start-Process -FilePath reg.exe -ArgumentList 'add','HKLM\\%s','/v','LongPathsEnabled','/t','REG_DWORD','/d','1','/f' -Verb RunAs -Wait -WindowStyle Hidden", longpathRegistryKey)
+	code, err := shell.New(nil, ".", "powershell.exe", "-NoProfile", "-NonInteractive", "-Command", script).Transparent()
+	if err != nil || code != 0 {
+		return fmt.Errorf("Elevation was refused or failed [%d], reason: %v", code, err)
+	}
+	return nil
+}
+
 func EnforceLongpathSupport() error {
-	key, _, err := registry.CreateKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Control\FileSystem`, registry.SET_VALUE)
+	key, _, err := registry.CreateKey(registry.LOCAL_MACHINE, longpathRegistryKey, registry.SET_VALUE)
 	if err != nil {
 		return err
 	}
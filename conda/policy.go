@@ -0,0 +1,354 @@
+package conda
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/robocorp/rcc/cloud"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/settings"
+	"github.com/robocorp/rcc/shell"
+	"gopkg.in/yaml.v2"
+)
+
+// PolicyRule matches packages by name (glob pattern, like "openssl" or
+// "*gpl*"), optionally limited to kind ("conda" or "pypi") and to version
+// range (comma separated constraints, like ">=1.0,<1.1.1").
+type PolicyRule struct {
+	Name     string `yaml:"name"               json:"name"`
+	Kind     string `yaml:"kind,omitempty"     json:"kind,omitempty"`
+	Versions string `yaml:"versions,omitempty" json:"versions,omitempty"`
+	Reason   string `yaml:"reason,omitempty"   json:"reason,omitempty"`
+}
+
+// PackagePolicy is centrally managed list of denied packages, and optional
+// list of allowed packages. When allow list is not empty, only packages
+// matching it are allowed.
+type PackagePolicy struct {
+	Deny  []*PolicyRule `yaml:"deny"  json:"deny"`
+	Allow []*PolicyRule `yaml:"allow" json:"allow"`
+}
+
+// PolicyViolation is one package which is not allowed by policy.
+type PolicyViolation struct {
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Version string `json:"version"`
+	Reason  string `json:"reason"`
+}
+
+func (it *PolicyViolation) String() string {
+	return fmt.Sprintf("%s package %s %s: %s", it.Kind, it.Name, it.Version, it.Reason)
+}
+
+// PackagePolicyFile is location of policy file from settings, or empty
+// when there is no policy.
+func PackagePolicyFile() string {
+	location := strings.TrimSpace(settings.Global.PackagePolicy())
+	if len(location) == 0 {
+		return ""
+	}
+	return common.ExpandPath(location)
+}
+
+// LoadPackagePolicy loads policy configured in settings. Without configured
+// policy, result is nil (and everything is allowed).
+func LoadPackagePolicy() (*PackagePolicy, error) {
+	filename := PackagePolicyFile()
+	if len(filename) == 0 {
+		return nil, nil
+	}
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read package policy %q, reason: %v", filename, err)
+	}
+	policy, err := ParsePackagePolicy(content)
+	if err != nil {
+		return nil, fmt.Errorf("Package policy %q is not valid, reason: %v", filename, err)
+	}
+	return policy, nil
+}
+
+func ParsePackagePolicy(content []byte) (*PackagePolicy, error) {
+	policy := &PackagePolicy{}
+	err := yaml.Unmarshal(content, policy)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range append(policy.Deny, policy.Allow...) {
+		if _, err := path.Match(strings.ToLower(rule.Name), ""); err != nil || len(rule.Name) == 0 {
+			return nil, fmt.Errorf("Rule name %q is not valid name pattern.", rule.Name)
+		}
+		if _, err := versionConstraints(rule.Versions); err != nil {
+			return nil, err
+		}
+		kind := strings.ToLower(rule.Kind)
+		if len(kind) > 0 && kind != "conda" && kind != "pypi" {
+			return nil, fmt.Errorf("Rule %q has kind %q, but it should be conda or pypi.", rule.Name, rule.Kind)
+		}
+	}
+	return policy, nil
+}
+
+type versionConstraint struct {
+	operator string
+	version  string
+}
+
+func versionConstraints(text string) ([]*versionConstraint, error) {
+	result := []*versionConstraint{}
+	for _, part := range strings.Split(text, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+		operator := ""
+		for _, candidate := range []string{"<=", ">=", "==", "!=", "<", ">", "="} {
+			if strings.HasPrefix(part, candidate) {
+				operator = candidate
+				break
+			}
+		}
+		version := strings.TrimSpace(part[len(operator):])
+		if len(version) == 0 {
+			return nil, fmt.Errorf("Version constraint %q has no version.", part)
+		}
+		if operator == "" || operator == "=" {
+			operator = "=="
+		}
+		result = append(result, &versionConstraint{operator: operator, version: version})
+	}
+	return result, nil
+}
+
+func (it *versionConstraint) matches(version string) bool {
+	if strings.Contains(it.version, "*") {
+		matched, _ := path.Match(it.version, version)
+		if it.operator == "!=" {
+			return !matched
+		}
+		return matched
+	}
+	compared := CompareVersions(version, it.version)
+	switch it.operator {
+	case "<":
+		return compared < 0
+	case "<=":
+		return compared <= 0
+	case ">":
+		return compared > 0
+	case ">=":
+		return compared >= 0
+	case "!=":
+		return compared != 0
+	default:
+		return compared == 0
+	}
+}
+
+func (it *PolicyRule) Matches(kind, name, version string) bool {
+	if len(it.Kind) > 0 && !strings.EqualFold(it.Kind, kind) {
+		return false
+	}
+	matched, _ := path.Match(strings.ToLower(it.Name), strings.ToLower(name))
+	if !matched {
+		return false
+	}
+	constraints, _ := versionConstraints(it.Versions)
+	if len(version) == 0 && len(constraints) > 0 {
+		return false
+	}
+	for _, constraint := range constraints {
+		if !constraint.matches(version) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check tells if package is allowed, and if not, why.
+func (it *PackagePolicy) Check(kind, name, version string) (bool, string) {
+	if it == nil {
+		return true, ""
+	}
+	for _, rule := range it.Deny {
+		if rule.Matches(kind, name, version) {
+			reason := rule.Reason
+			if len(reason) == 0 {
+				reason = fmt.Sprintf("denied by rule %q %s", rule.Name, rule.Versions)
+			}
+			return false, strings.TrimSpace(reason)
+		}
+	}
+	if len(it.Allow) == 0 {
+		return true, ""
+	}
+	for _, rule := range it.Allow {
+		if rule.Matches(kind, name, version) {
+			return true, ""
+		}
+	}
+	return false, "not in allowed packages"
+}
+
+// Violations returns all packages in listing, which are not allowed.
+func (it *PackagePolicy) Violations(deps dependencies) []*PolicyViolation {
+	result := []*PolicyViolation{}
+	for _, entry := range deps {
+		kind := dependencyKind(entry)
+		ok, reason := it.Check(kind, entry.Name, entry.Version)
+		if !ok {
+			result = append(result, &PolicyViolation{Name: entry.Name, Kind: kind, Version: entry.Version, Reason: reason})
+		}
+	}
+	return result
+}
+
+type linkPlan struct {
+	Actions struct {
+		Link []*dependency `json:"LINK"`
+	} `json:"actions"`
+}
+
+// ParseResolvedPlan returns packages which dry run plan would install.
+func ParseResolvedPlan(content []byte) (dependencies, error) {
+	plan := &linkPlan{}
+	err := json.Unmarshal(content, plan)
+	if err != nil {
+		return nil, err
+	}
+	return dependencies(plan.Actions.Link).sorted(), nil
+}
+
+// resolvedPackages asks solver for packages, which environment would have,
+// without installing anything.
+func resolvedPackages(resolver Solver, environment *Environment, condaYaml string, force bool) (dependencies, error) {
+	prefix := filepath.Join(common.RobocorpTemp(), fmt.Sprintf("plan_%x_%d", common.When, os.Getpid()))
+	command, err := resolver.DryrunCommand(environment, condaYaml, prefix, force)
+	if err != nil {
+		return nil, err
+	}
+	output, code, err := shell.New(resolver.Environment(), ".", command...).CaptureOutput()
+	if err != nil || code != 0 {
+		return nil, fmt.Errorf("Resolving conda packages failed [%d], reason: %v\n%s", code, err, output)
+	}
+	return ParseResolvedPlan([]byte(output))
+}
+
+func reportViolations(sink io.Writer, violations []*PolicyViolation) bool {
+	for _, violation := range violations {
+		fmt.Fprintf(sink, "Package policy violation: %s\n", violation)
+		common.Log("%sPackage policy violation: %s%s", pretty.Red, violation, pretty.Reset)
+	}
+	if len(violations) > 0 {
+		cloud.BackgroundMetric(common.ControllerIdentity(), "rcc.env.fatal.policy", fmt.Sprintf("%d", len(violations)))
+		return false
+	}
+	return true
+}
+
+func loadPolicyFor(sink io.Writer) (*PackagePolicy, bool) {
+	policy, err := LoadPackagePolicy()
+	if err != nil {
+		fmt.Fprintf(sink, "%v\n", err)
+		common.Log("%s%v%s", pretty.Red, err, pretty.Reset)
+		return nil, false
+	}
+	return policy, true
+}
+
+// policyAllowsPlan checks solved, but not yet installed, conda packages
+// against package policy. When plan cannot be made (like with explicit spec
+// files), packages are checked only after installation.
+func policyAllowsPlan(sink io.Writer, resolver Solver, condaYaml string, force bool) bool {
+	policy, ok := loadPolicyFor(sink)
+	if !ok {
+		return false
+	}
+	if policy == nil || explicitSpec(condaYaml) {
+		return true
+	}
+	environment, err := ReadCondaYaml(condaYaml)
+	if err == nil {
+		resolved, err := resolvedPackages(resolver, environment, condaYaml, force)
+		if err == nil {
+			fmt.Fprintf(sink, "Package policy %q checked %d resolved conda packages.\n", PackagePolicyFile(), len(resolved))
+			return reportViolations(sink, policy.Violations(resolved))
+		}
+	}
+	fmt.Fprintf(sink, "Package policy pre-check skipped, reason: %v\n", err)
+	return true
+}
+
+// policyAllowsEnvironment checks all installed packages (conda and pip)
+// against package policy, before environment is taken into use.
+func policyAllowsEnvironment(sink io.Writer, targetFolder string) bool {
+	policy, ok := loadPolicyFor(sink)
+	if !ok {
+		return false
+	}
+	if policy == nil {
+		return true
+	}
+	installed := LoadWantedDependencies(GoldenMasterFilename(targetFolder))
+	fmt.Fprintf(sink, "Package policy %q checked %d installed packages.\n", PackagePolicyFile(), len(installed))
+	return reportViolations(sink, policy.Violations(installed))
+}
+
+// EnvironmentPlan is resolved (but not installed) environment, with package
+// policy violations, if any. Pip requirements are not resolved, so they are
+// listed as they are given.
+type EnvironmentPlan struct {
+	Blueprint  string             `json:"blueprint"`
+	Policy     string             `json:"policy"`
+	Conda      dependencies       `json:"conda"`
+	Pip        []string           `json:"pip"`
+	Violations []*PolicyViolation `json:"violations"`
+}
+
+// PlanEnvironment resolves environment from configurations without
+// installing it, and checks it against package policy.
+func PlanEnvironment(force bool, configurations ...string) (plan *EnvironmentPlan, err error) {
+	defer fail.Around(&err)
+
+	condaYaml := filepath.Join(os.TempDir(), fmt.Sprintf("plan_%x.yaml", common.When))
+	requirementsText := filepath.Join(os.TempDir(), fmt.Sprintf("plan_%x.txt", common.When))
+	defer os.Remove(condaYaml)
+	defer os.Remove(requirementsText)
+	key, _, finalEnv, err := temporaryConfig(condaYaml, requirementsText, true, configurations...)
+	fail.On(err != nil, "%v", err)
+
+	policy, err := LoadPackagePolicy()
+	fail.On(err != nil, "%v", err)
+	resolver, err := MustResolver("")
+	fail.On(err != nil, "%v", err)
+	resolved, err := resolvedPackages(resolver, finalEnv, condaYaml, force)
+	fail.On(err != nil, "%v", err)
+
+	plan = &EnvironmentPlan{
+		Blueprint:  key,
+		Policy:     PackagePolicyFile(),
+		Conda:      resolved,
+		Pip:        []string{},
+		Violations: policy.Violations(resolved),
+	}
+	for _, dependency := range finalEnv.Pip {
+		plan.Pip = append(plan.Pip, dependency.Original)
+		version := ""
+		if dependency.Qualifier == "==" {
+			version = dependency.Versions
+		}
+		if ok, reason := policy.Check("pypi", dependency.Representation(), version); !ok {
+			plan.Violations = append(plan.Violations, &PolicyViolation{Name: dependency.Representation(), Kind: "pypi", Version: version, Reason: reason})
+		}
+	}
+	return plan, nil
+}
@@ -0,0 +1,93 @@
+package conda_test
+
+import (
+	"testing"
+
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+const (
+	packagePolicy = `
+deny:
+  - name: openssl
+    versions: "<1.1.1"
+    reason: known vulnerabilities
+  - name: "*gpl*"
+  - name: pyyaml
+    kind: pypi
+    versions: "==5.*"
+`
+	allowPolicy = `
+allow:
+  - name: python
+  - name: pip
+    versions: ">=22,<23"
+`
+	resolvedPlan = `{"actions": {"FETCH": [], "LINK": [
+  {"name": "python", "version": "3.9.13", "channel": "conda-forge"},
+  {"name": "openssl", "version": "1.1.0", "channel": "conda-forge"}
+]}}`
+)
+
+func TestPackagePolicyDeniesPackages(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	policy, err := conda.ParsePackagePolicy([]byte(packagePolicy))
+	must_be.Nil(err)
+
+	ok, reason := policy.Check("conda", "openssl", "1.1.0")
+	wont_be.True(ok)
+	must_be.Equal("known vulnerabilities", reason)
+	ok, _ = policy.Check("conda", "openssl", "1.1.1")
+	must_be.True(ok)
+	ok, _ = policy.Check("conda", "libGPL-tools", "1.0")
+	wont_be.True(ok)
+	ok, _ = policy.Check("pypi", "PyYAML", "5.4.1")
+	wont_be.True(ok)
+	ok, _ = policy.Check("conda", "pyyaml", "5.4.1")
+	must_be.True(ok)
+	ok, _ = policy.Check("pypi", "pyyaml", "")
+	must_be.True(ok)
+
+	var missing *conda.PackagePolicy
+	ok, _ = missing.Check("conda", "openssl", "1.0")
+	must_be.True(ok)
+}
+
+func TestPackagePolicyAllowsOnlyListedPackages(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	policy, err := conda.ParsePackagePolicy([]byte(allowPolicy))
+	must_be.Nil(err)
+
+	ok, _ := policy.Check("conda", "python", "3.9.13")
+	must_be.True(ok)
+	ok, _ = policy.Check("conda", "pip", "22.1.2")
+	must_be.True(ok)
+	ok, reason := policy.Check("conda", "pip", "23.0")
+	wont_be.True(ok)
+	must_be.Equal("not in allowed packages", reason)
+	ok, _ = policy.Check("pypi", "requests", "2.27.1")
+	wont_be.True(ok)
+
+	_, err = conda.ParsePackagePolicy([]byte("deny:\n  - name: foo\n    versions: '>='\n"))
+	wont_be.Nil(err)
+	_, err = conda.ParsePackagePolicy([]byte("deny:\n  - name: foo\n    kind: npm\n"))
+	wont_be.Nil(err)
+}
+
+func TestPackagePolicyChecksResolvedPlan(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	resolved, err := conda.ParseResolvedPlan([]byte(resolvedPlan))
+	must_be.Nil(err)
+	must_be.Equal(2, len(resolved))
+
+	policy, err := conda.ParsePackagePolicy([]byte(packagePolicy))
+	must_be.Nil(err)
+	violations := policy.Violations(resolved)
+	must_be.Equal(1, len(violations))
+	must_be.Equal("openssl", violations[0].Name)
+	must_be.Equal("conda package openssl 1.1.0: known vulnerabilities", violations[0].String())
+}
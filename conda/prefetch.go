@@ -0,0 +1,221 @@
+package conda
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/robocorp/rcc/cloud"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/settings"
+	"github.com/robocorp/rcc/shell"
+)
+
+type Fetchable struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Filename string `json:"fn"`
+	Url      string `json:"url"`
+	Md5      string `json:"md5"`
+	Size     int64  `json:"size"`
+}
+
+type dryrunPlan struct {
+	Actions struct {
+		Fetch []*Fetchable `json:"FETCH"`
+		Link  []*Fetchable `json:"LINK"`
+	} `json:"actions"`
+}
+
+type PrefetchReport struct {
+	Blueprint  string   `json:"blueprint"`
+	Downloaded []string `json:"downloaded"`
+	Cached     []string `json:"cached"`
+	Pip        bool     `json:"pip"`
+}
+
+func ParseDryrunPlan(content []byte) ([]*Fetchable, error) {
+	plan := &dryrunPlan{}
+	err := json.Unmarshal(content, plan)
+	if err != nil {
+		return nil, err
+	}
+	return plan.Actions.Fetch, nil
+}
+
+func fileMd5(filename string) (string, error) {
+	source, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+	digest := md5.New()
+	_, err = io.Copy(digest, source)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", digest.Sum(nil)), nil
+}
+
+func (it *Fetchable) download(target string) (err error) {
+	defer fail.Around(&err)
+
+	partname := fmt.Sprintf("%s.part%s", target, <-common.Identities)
+	defer os.Remove(partname)
+	err = cloud.Download(it.Url, partname)
+	fail.On(err != nil, "%v", err)
+	if len(it.Md5) > 0 {
+		digest, err := fileMd5(partname)
+		fail.On(err != nil, "%v", err)
+		fail.On(digest != it.Md5, "Checksum mismatch for %q, expected %q, got %q.", it.Url, it.Md5, digest)
+	}
+	return storePackage(filepath.Dir(target), partname, filepath.Base(target))
+}
+
+func dryrunCommand(resolver *Resolver, environment *Environment, condaYaml, prefix string, force bool) []string {
+	if !resolver.Micromamba {
+		command := []string{resolver.Executable, "create", "--dry-run", "--json", "--override-channels", "--prefix", prefix}
+		for _, channel := range environment.Channels {
+			command = append(command, "--channel", channel)
+		}
+		for _, dependency := range environment.Conda {
+			command = append(command, dependency.Original)
+		}
+		return command
+	}
+	ttl := "57600"
+	if force {
+		ttl = "0"
+	}
+	command := common.NewCommander(resolver.Executable, "create", "--dry-run", "--json", "--no-rc", "--strict-channel-priority", "--repodata-ttl", ttl, "-y", "-f", condaYaml, "-p", prefix)
+	command.Option("--channel-alias", settings.Global.CondaURL())
+	command.ConditionalFlag(len(resolver.Channels) > 0, "--override-channels")
+	for _, channel := range resolver.Channels {
+		command.Option("--channel", channel)
+	}
+	return command.CLI()
+}
+
+func prefetchConda(resolver Solver, environment *Environment, condaYaml string, force bool, report *PrefetchReport) (err error) {
+	defer fail.Around(&err)
+
+	prefix := filepath.Join(common.RobocorpTemp(), fmt.Sprintf("prefetch_%x", common.When))
+	command, err := resolver.DryrunCommand(environment, condaYaml, prefix, force)
+	fail.On(err != nil, "%v", err)
+	output, code, err := shell.New(resolver.Environment(), ".", command...).CaptureOutput()
+	fail.On(err != nil || code != 0, "Resolving conda packages failed [%d], reason: %v\n%s", code, err, output)
+	packages, err := ParseDryrunPlan([]byte(output))
+	fail.On(err != nil, "Could not parse %s plan, reason: %v", resolver.Name(), err)
+	cache := PackagesCache()
+	err = os.MkdirAll(cache, 0o755)
+	fail.On(err != nil, "%v", err)
+	return withSharedPackages(func() error {
+		for _, entry := range packages {
+			target := filepath.Join(cache, entry.Filename)
+			if pathlib.IsFile(target) {
+				report.Cached = append(report.Cached, entry.Filename)
+				continue
+			}
+			common.Log("Downloading %s %s ...", entry.Name, entry.Version)
+			err := entry.download(target)
+			if err != nil {
+				return fmt.Errorf("Could not download %q, reason: %v", entry.Url, err)
+			}
+			report.Downloaded = append(report.Downloaded, entry.Filename)
+		}
+		return nil
+	})
+}
+
+// pipPython creates throwaway prefix with only python and pip of given
+// environment, so that wheels are downloaded by environment python (and so
+// match its version and ABI), and not by whatever pip happens to be in PATH.
+// Their packages are already in cache after prefetchConda.
+func pipPython(resolver Solver, environment *Environment, prefix string, force bool) (python string, err error) {
+	defer fail.Around(&err)
+
+	minimal := &Environment{Name: "prefetch", Channels: environment.Channels, Conda: []*Dependency{}, Pip: []*Dependency{}}
+	hasPip := false
+	for _, dependency := range environment.Conda {
+		switch dependency.Name {
+		case "python":
+			minimal.Conda = append(minimal.Conda, dependency)
+		case "pip":
+			minimal.Conda = append(minimal.Conda, dependency)
+			hasPip = true
+		}
+	}
+	fail.On(len(minimal.Conda) == 0 || (hasPip && len(minimal.Conda) == 1), "Prefetching pip dependencies needs python in conda dependencies.")
+	if !hasPip {
+		minimal.Conda = append(minimal.Conda, AsDependency("pip"))
+	}
+	if ConfiguredChannels() {
+		minimal.Channels = []string{}
+	}
+	condaYaml := prefix + ".yaml"
+	defer os.Remove(condaYaml)
+	err = minimal.SaveAs(condaYaml)
+	fail.On(err != nil, "%v", err)
+	code, _, err := runResolver(resolver, io.Discard, condaYaml, prefix, force)
+	fail.On(err != nil || code != 0, "Creating python for pip prefetch failed [%d], reason: %v", code, err)
+	searchPath := FindPath(prefix)
+	python, ok := searchPath.Which("python3", FileExtensions)
+	if !ok {
+		python, ok = searchPath.Which("python", FileExtensions)
+	}
+	fail.On(!ok, "Could not find python from %q.", prefix)
+	return python, nil
+}
+
+func prefetchPip(resolver Solver, environment *Environment, requirementsText string, force bool) (err error) {
+	defer fail.Around(&err)
+
+	prefix := filepath.Join(common.RobocorpTemp(), fmt.Sprintf("prefetch_python_%x_%d", common.When, os.Getpid()))
+	defer os.RemoveAll(prefix)
+	python, err := pipPython(resolver, environment, prefix, force)
+	fail.On(err != nil, "%v", err)
+	command := common.NewCommander(python, "-m", "pip", "download", "--isolated", "--no-color", "--disable-pip-version-check", "--prefer-binary", "--cache-dir", common.PipCache(), "--find-links", common.WheelCache(), "--dest", common.WheelCache(), "--requirement", requirementsText)
+	err = withPipIndexes(command)
+	fail.On(err != nil, "Pip index failure, reason: %v", err)
+	command.ConditionalFlag(common.VerboseEnvironmentBuilding(), "--verbose")
+	code, err := shell.New(CondaEnvironment(), ".", command.CLI()...).StderrOnly().Transparent()
+	fail.On(err != nil || code != 0, "Pip download failed [%d], reason: %v", code, err)
+	return nil
+}
+
+// Prefetch downloads conda packages and pip wheels needed by given conda.yaml
+// files into caches, without building environment, so that later builds can
+// happen offline (or at least faster).
+func Prefetch(force bool, configurations ...string) (report *PrefetchReport, err error) {
+	defer fail.Around(&err)
+
+	condaYaml := filepath.Join(os.TempDir(), fmt.Sprintf("prefetch_%x.yaml", common.When))
+	requirementsText := filepath.Join(os.TempDir(), fmt.Sprintf("prefetch_%x.txt", common.When))
+	defer os.Remove(condaYaml)
+	defer os.Remove(requirementsText)
+	key, _, finalEnv, err := temporaryConfig(condaYaml, requirementsText, true, configurations...)
+	fail.On(err != nil, "%v", err)
+
+	resolver, err := MustResolver("")
+	fail.On(err != nil, "%v", err)
+
+	report = &PrefetchReport{Blueprint: key, Downloaded: []string{}, Cached: []string{}}
+	common.Timeline("prefetch conda packages start")
+	err = prefetchConda(resolver, finalEnv, condaYaml, force, report)
+	common.Timeline("prefetch conda packages done")
+	fail.On(err != nil, "%v", err)
+
+	if len(finalEnv.Pip) > 0 {
+		common.Timeline("prefetch pip wheels start")
+		err = prefetchPip(resolver, finalEnv, requirementsText, force)
+		common.Timeline("prefetch pip wheels done")
+		fail.On(err != nil, "%v", err)
+		report.Pip = true
+	}
+	return report, nil
+}
@@ -0,0 +1,23 @@
+package conda_test
+
+import (
+	"testing"
+
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestCanParseDryrunPlan(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	plan := []byte(`{"actions": {"FETCH": [{"name": "python", "version": "3.9.13", "fn": "python-3.9.13-h12debd9_1.tar.bz2", "url": "https://conda.anaconda.org/conda-forge/linux-64/python-3.9.13-h12debd9_1.tar.bz2", "md5": "abcdef"}], "LINK": []}, "dry_run": true}`)
+	packages, err := conda.ParseDryrunPlan(plan)
+	must.Nil(err)
+	must.Equal(1, len(packages))
+	must.Equal("python-3.9.13-h12debd9_1.tar.bz2", packages[0].Filename)
+	must.Equal("abcdef", packages[0].Md5)
+
+	packages, err = conda.ParseDryrunPlan([]byte(`{"dry_run": true}`))
+	must.Nil(err)
+	must.Equal(0, len(packages))
+}
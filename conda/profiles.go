@@ -0,0 +1,57 @@
+package conda
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+)
+
+// ProfileNames returns active profile names from settings followed by extra
+// ones, without duplicates.
+func ProfileNames(extra ...string) []string {
+	seen := make(map[string]bool)
+	result := make([]string, 0, 5)
+	active := settings.Global.EnvironmentProfiles().Active
+	for _, name := range append(append([]string{}, active...), extra...) {
+		if len(name) == 0 || seen[name] {
+			continue
+		}
+		seen[name] = true
+		result = append(result, name)
+	}
+	return result
+}
+
+// FindProfile looks named profile first from local (robot.yaml) profiles and
+// then from settings.
+func FindProfile(local map[string]map[string]string, name string) (map[string]string, bool) {
+	if profile, ok := local[name]; ok {
+		return profile, true
+	}
+	profile, ok := settings.Global.EnvironmentProfiles().Profiles[name]
+	return profile, ok
+}
+
+// ProfileEnvironment returns variables of active profiles in order, so that
+// later profile overrides earlier one. Unknown profiles are skipped.
+func ProfileEnvironment(local map[string]map[string]string, extra ...string) []string {
+	result := make([]string, 0, 10)
+	for _, name := range ProfileNames(extra...) {
+		profile, ok := FindProfile(local, name)
+		if !ok {
+			common.Debug("Environment profile %q is not defined, skipping it.", name)
+			continue
+		}
+		keys := make([]string, 0, len(profile))
+		for key := range profile {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			result = append(result, fmt.Sprintf("%s=%s", key, profile[key]))
+		}
+	}
+	return result
+}
@@ -0,0 +1,195 @@
+package conda
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/robocorp/rcc/cloud"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/settings"
+)
+
+// Resolver is conda compatible executable, which creates environments.
+// Normally it is micromamba, but when that is not available, it can be
+// conda-standalone, mamba, or conda already installed on machine.
+type Resolver struct {
+	Executable string
+	Micromamba bool
+	Channels   []string
+	Label      string
+}
+
+func fallbackResolver() (*Resolver, bool) {
+	name := strings.TrimSpace(settings.Global.ResolverFallback())
+	if len(name) == 0 {
+		return nil, false
+	}
+	executable, err := exec.LookPath(common.ExpandPath(name))
+	if err != nil {
+		common.Debug("Resolver fallback %q is not available, reason: %v", name, err)
+		return nil, false
+	}
+	return &Resolver{Executable: executable}, true
+}
+
+// FallbackResolverName is for diagnostics, and tells which fallback resolver
+// would be used, if any.
+func FallbackResolverName() string {
+	fallback, ok := fallbackResolver()
+	if !ok {
+		return "N/A"
+	}
+	return fallback.Executable
+}
+
+// MustResolver returns wanted solver or one selected in settings, after
+// checking that it is actually capable of creating environments.
+func MustResolver(wanted string) (Solver, error) {
+	channels, err := ChannelLinks()
+	if err != nil {
+		return nil, err
+	}
+	var solver Solver
+	name := SolverName(wanted)
+	switch name {
+	case micromambaSolver:
+		solver, err = micromambaResolver(channels)
+	case condaSolver:
+		solver, err = condaResolver(channels)
+	case pixiSolver:
+		solver, err = newPixiSolver(channels)
+	default:
+		return nil, fmt.Errorf("Unknown solver %q, it should be one of: %s.", name, strings.Join(KnownSolvers(), ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = solver.Probe()
+	if err != nil {
+		return nil, err
+	}
+	common.Debug("Using solver %q to create environments.", solver.Name())
+	return solver, nil
+}
+
+// micromambaResolver returns micromamba, if it is available or can be
+// downloaded, and otherwise fallback resolver configured in settings.
+func micromambaResolver(channels []string) (*Resolver, error) {
+	if MustMicromamba() {
+		return &Resolver{Executable: BinMicromamba(), Micromamba: true, Channels: channels}, nil
+	}
+	fallback, ok := fallbackResolver()
+	if !ok {
+		return nil, fmt.Errorf("Could not get micromamba installed, and no usable resolver-fallback in settings.")
+	}
+	cloud.BackgroundMetric(common.ControllerIdentity(), "rcc.resolver.fallback", common.Version)
+	pretty.Warning("Micromamba is not available, using %q to create environment instead.", fallback.Executable)
+	fallback.Channels = channels
+	return fallback, nil
+}
+
+// condaResolver returns explicitly selected conda (or mamba) installation.
+func condaResolver(channels []string) (*Resolver, error) {
+	executable, err := solverExecutable(condaSolver)
+	if err != nil {
+		return nil, err
+	}
+	return &Resolver{Executable: executable, Channels: channels, Label: condaSolver}, nil
+}
+
+func (it *Resolver) Name() string {
+	if len(it.Label) > 0 {
+		return it.Label
+	}
+	if it.Micromamba {
+		return "micromamba"
+	}
+	return "fallback resolver"
+}
+
+// Probe checks that resolver executable actually runs. Micromamba is already
+// verified when it was installed.
+func (it *Resolver) Probe() error {
+	if it.Micromamba {
+		return nil
+	}
+	return probeCommand(it.Name(), it.Executable, "--version")
+}
+
+func (it *Resolver) Environment() []string {
+	environment := CondaEnvironment()
+	if !it.Micromamba {
+		alias := strings.TrimSpace(settings.Global.CondaURL())
+		if len(alias) > 0 {
+			environment = append(environment, fmt.Sprintf("CONDA_CHANNEL_ALIAS=%s", alias))
+		}
+		if common.OfflineFlag {
+			environment = append(environment, "CONDA_OFFLINE=true")
+		}
+		if len(it.Channels) > 0 {
+			environment = append(environment, fmt.Sprintf("CONDA_CHANNELS=%s", strings.Join(it.Channels, ",")))
+		}
+	}
+	return environment
+}
+
+// Mirrored returns copy of resolver, which uses given channels instead of
+// its own.
+func (it *Resolver) Mirrored(channels []string) (Solver, bool) {
+	mirrored := *it
+	mirrored.Channels = channels
+	return &mirrored, true
+}
+
+func (it *Resolver) CreateCommands(condaYaml, targetFolder string, force bool) ([][]string, error) {
+	return [][]string{it.CreateCommand(condaYaml, targetFolder, force)}, nil
+}
+
+func (it *Resolver) DryrunCommand(environment *Environment, condaYaml, prefix string, force bool) ([]string, error) {
+	return dryrunCommand(it, environment, condaYaml, prefix, force), nil
+}
+
+func (it *Resolver) CreateCommand(condaYaml, targetFolder string, force bool) []string {
+	if explicitSpec(condaYaml) {
+		return it.explicitCommand(condaYaml, targetFolder)
+	}
+	if !it.Micromamba {
+		command := common.NewCommander(it.Executable, "env", "create", "--quiet", "--file", condaYaml, "--prefix", targetFolder)
+		return command.CLI()
+	}
+	ttl := "57600"
+	if force {
+		ttl = "0"
+	}
+	command := common.NewCommander(it.Executable, "create", "--always-copy", "--no-rc", "--safety-checks", "enabled", "--extra-safety-checks", "--retry-clean-cache", "--strict-channel-priority", "--repodata-ttl", ttl, "--json", "-y", "-f", condaYaml, "-p", targetFolder)
+	command.Option("--channel-alias", settings.Global.CondaURL())
+	command.ConditionalFlag(len(it.Channels) > 0, "--override-channels")
+	for _, channel := range it.Channels {
+		command.Option("--channel", channel)
+	}
+	command.ConditionalFlag(common.OfflineFlag, "--offline")
+	command.ConditionalFlag(common.VerboseEnvironmentBuilding(), "--verbose")
+	return command.CLI()
+}
+
+// explicitCommand installs exactly packages listed in explicit spec file,
+// without solving anything.
+func (it *Resolver) explicitCommand(specfile, targetFolder string) []string {
+	if !it.Micromamba {
+		command := common.NewCommander(it.Executable, "create", "--quiet", "--yes", "--file", specfile, "--prefix", targetFolder)
+		return command.CLI()
+	}
+	command := common.NewCommander(it.Executable, "create", "--always-copy", "--no-rc", "--safety-checks", "enabled", "--extra-safety-checks", "--json", "-y", "-f", specfile, "-p", targetFolder)
+	command.ConditionalFlag(common.OfflineFlag, "--offline")
+	command.ConditionalFlag(common.VerboseEnvironmentBuilding(), "--verbose")
+	return command.CLI()
+}
+
+func (it *Resolver) ListCommand(targetFolder string) []string {
+	if !it.Micromamba {
+		return []string{it.Executable, "list", "--json", "--prefix", targetFolder}
+	}
+	return []string{it.Executable, "list", "--json"}
+}
@@ -0,0 +1,22 @@
+package conda_test
+
+import (
+	"testing"
+
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestFallbackResolverUsesCondaEnvCreate(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	fallback := &conda.Resolver{Executable: "conda-standalone"}
+	must.Equal("fallback resolver", fallback.Name())
+	must.Equal([]string{"conda-standalone", "env", "create", "--quiet", "--file", "conda.yaml", "--prefix", "/tmp/stage"}, fallback.CreateCommand("conda.yaml", "/tmp/stage", true))
+	must.Equal([]string{"conda-standalone", "list", "--json", "--prefix", "/tmp/stage"}, fallback.ListCommand("/tmp/stage"))
+
+	micromamba := &conda.Resolver{Executable: "micromamba", Micromamba: true}
+	must.Equal("micromamba", micromamba.Name())
+	must.Equal([]string{"micromamba", "list", "--json"}, micromamba.ListCommand("/tmp/stage"))
+	must.True(len(micromamba.CreateCommand("conda.yaml", "/tmp/stage", false)) > 10)
+}
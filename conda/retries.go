@@ -0,0 +1,204 @@
+package conda
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/robocorp/rcc/cloud"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+	"github.com/robocorp/rcc/shell"
+)
+
+const (
+	maximumBackoff = 5 * time.Minute
+)
+
+var (
+	transientMarkers = []string{
+		"download error",
+		"couldn't resolve host",
+		"could not resolve host",
+		"connection reset",
+		"connection refused",
+		"timed out",
+		"transfer closed",
+		"ssl connect error",
+		"http 500",
+		"http 502",
+		"http 503",
+		"http 504",
+	}
+	permanentMarkers = []string{
+		"nothing provides",
+		"could not solve",
+		"encountered problems while solving",
+		"packagesnotfounderror",
+	}
+)
+
+// MicromambaTimeout tells how long micromamba may run before it is killed,
+// where zero means no timeout. Command line --micromamba-timeout overrides
+// setting.
+func MicromambaTimeout() time.Duration {
+	if common.MicromambaTimeout > 0 {
+		return time.Duration(common.MicromambaTimeout) * time.Minute
+	}
+	return settings.Global.MicromambaTimeout()
+}
+
+// MicromambaRetries tells how many times failed micromamba is retried, when
+// failure looks transient. Command line --micromamba-retries overrides
+// setting, and offline mode never retries.
+func MicromambaRetries() int {
+	if common.OfflineFlag {
+		return 0
+	}
+	if common.MicromambaRetries > 0 {
+		return common.MicromambaRetries
+	}
+	if retries := settings.Global.MicromambaRetries(); retries > 0 {
+		return retries
+	}
+	return 0
+}
+
+func retryBackoff(initial time.Duration, retry int) time.Duration {
+	delay := initial
+	for round := 1; round < retry && delay < maximumBackoff; round++ {
+		delay *= 2
+	}
+	if delay > maximumBackoff {
+		return maximumBackoff
+	}
+	return delay
+}
+
+// TransientObserver recognizes from resolver output failures, which are
+// worth retrying (network and download problems), from ones which are not
+// (solver could not find packages).
+type TransientObserver struct {
+	transient bool
+	permanent bool
+}
+
+func (it *TransientObserver) Write(content []byte) (int, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		text := strings.ToLower(scanner.Text())
+		for _, marker := range permanentMarkers {
+			if strings.Contains(text, marker) {
+				it.permanent = true
+			}
+		}
+		for _, marker := range transientMarkers {
+			if strings.Contains(text, marker) {
+				it.transient = true
+			}
+		}
+	}
+	return len(content), nil
+}
+
+// DownloadFailure tells if failure was about downloading something, and
+// not about solving environment.
+func (it *TransientObserver) DownloadFailure() bool {
+	return it.transient && !it.permanent
+}
+
+// Transient tells if failure with exit code should be retried. Timeouts are
+// always transient.
+func (it *TransientObserver) Transient(code int) bool {
+	if code == shell.TimeoutExit {
+		return true
+	}
+	return it.transient && !it.permanent
+}
+
+// runResolver runs resolver with retries, and if it still fails because of
+// download problems, once more using mirror channels from settings. Summary
+// of last run is also returned.
+func runResolver(resolver Solver, sink io.Writer, condaYaml, targetFolder string, force bool) (int, *ResolverSummary, error) {
+	code, summary, err := retryResolver(resolver, sink, condaYaml, targetFolder, force)
+	if (err == nil && code == 0) || summary.Class != FailureNetwork {
+		return code, summary, err
+	}
+	mirrored, ok := mirroredResolver(resolver)
+	if !ok {
+		return code, summary, err
+	}
+	names := strings.Join(MirrorChannelNames(), ", ")
+	common.Log("%s failed [%d] to download packages, falling back to mirror channels: %s", resolver.Name(), code, names)
+	fmt.Fprintf(sink, "\n---  %s fallback to mirror channels %s after [%d: %v]  ---\n\n", resolver.Name(), names, code, err)
+	cloud.BackgroundMetric(common.ControllerIdentity(), "rcc.env.channel.fallback", fmt.Sprintf("%d_%x", code, code))
+	common.Timeline("%s channel fallback.", resolver.Name())
+	if renameRemove(targetFolder) != nil {
+		return code, summary, err
+	}
+	return retryResolver(mirrored, sink, condaYaml, targetFolder, force)
+}
+
+func mirroredResolver(resolver Solver) (Solver, bool) {
+	if common.OfflineFlag {
+		return nil, false
+	}
+	channels, err := MirrorChannelLinks()
+	if err != nil {
+		common.Log("Mirror channels are not usable, reason: %v", err)
+		return nil, false
+	}
+	if len(channels) == 0 {
+		return nil, false
+	}
+	return resolver.Mirrored(channels)
+}
+
+// retryResolver runs resolver, and retries it on transient failures. It
+// also returns summary of last run, and reports it as resolver event.
+func retryResolver(resolver Solver, sink io.Writer, condaYaml, targetFolder string, force bool) (code int, summary *ResolverSummary, err error) {
+	timeout := MicromambaTimeout()
+	retries := MicromambaRetries()
+	backoff := settings.Global.MicromambaBackoff()
+	for retry := 1; ; retry++ {
+		observer := &ResolverObserver{}
+		tee := io.MultiWriter(sink, observer)
+		code, err = runCommands(resolver, tee, timeout, condaYaml, targetFolder, force)
+		if err == nil && code == 0 {
+			return code, observer.Report(resolver.Name(), code), nil
+		}
+		if retry > retries || !observer.Transient(code) || common.Context().Err() != nil {
+			return code, observer.Report(resolver.Name(), code), err
+		}
+		delay := retryBackoff(backoff, retry)
+		common.Log("%s failed [%d] with transient error, retry %d/%d in %s.", resolver.Name(), code, retry, retries, delay)
+		fmt.Fprintf(sink, "\n---  %s retry %d/%d after %s [%d: %v]  ---\n\n", resolver.Name(), retry, retries, delay, code, err)
+		cloud.BackgroundMetric(common.ControllerIdentity(), "rcc.env.micromamba.retry", fmt.Sprintf("%d_%x", code, code))
+		common.Timeline("%s retry %d.", resolver.Name(), retry)
+		time.Sleep(delay)
+		if renameRemove(targetFolder) != nil {
+			return code, observer.Summary(resolver.Name(), code), err
+		}
+	}
+}
+
+// runCommands runs all solver commands in sequence, stopping on first failure.
+func runCommands(resolver Solver, sink io.Writer, timeout time.Duration, condaYaml, targetFolder string, force bool) (code int, err error) {
+	commands, err := resolver.CreateCommands(condaYaml, targetFolder, force)
+	if err != nil {
+		return -1, err
+	}
+	err = withSharedPackages(func() error {
+		for _, command := range commands {
+			code, err = shell.New(resolver.Environment(), ".", command...).Timeout(timeout).Tracked(sink, false)
+			if err != nil || code != 0 {
+				return err
+			}
+		}
+		return nil
+	})
+	return code, err
+}
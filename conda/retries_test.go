@@ -0,0 +1,123 @@
+package conda
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/settings"
+	"github.com/robocorp/rcc/shell"
+)
+
+func TestRetryBackoffGrowsExponentially(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	must_be.Equal(10*time.Second, retryBackoff(10*time.Second, 1))
+	must_be.Equal(20*time.Second, retryBackoff(10*time.Second, 2))
+	must_be.Equal(80*time.Second, retryBackoff(10*time.Second, 4))
+	must_be.Equal(maximumBackoff, retryBackoff(10*time.Second, 30))
+	must_be.Equal(time.Duration(0), retryBackoff(0, 3))
+}
+
+func TestTransientObserverSeparatesNetworkFromSolverFailures(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	network := &TransientObserver{}
+	network.Write([]byte("Download error (28) Timeout was reached [https://conda.anaconda.org/conda-forge/noarch/repodata.json]\n"))
+	must_be.True(network.Transient(1))
+
+	solver := &TransientObserver{}
+	solver.Write([]byte("Encountered problems while solving:\n  - nothing provides requested nodejs\ndownload error\n"))
+	wont_be.True(solver.Transient(1))
+	must_be.True(solver.Transient(shell.TimeoutExit))
+
+	silent := &TransientObserver{}
+	wont_be.True(silent.Transient(1))
+}
+
+func TestResolverIsRetriedOnTransientFailure(t *testing.T) {
+	if IsWindows() {
+		t.Skip("Not a windows test.")
+	}
+	must_be, wont_be := hamlet.Specifications(t)
+
+	folder, err := ioutil.TempDir("", "retries")
+	must_be.Nil(err)
+	defer os.RemoveAll(folder)
+
+	script := filepath.Join(folder, "resolver")
+	content := "#!/bin/sh\necho x >> \"$0.count\"\nif [ $(wc -l < \"$0.count\") -lt 3 ]; then echo 'download error: connection reset'; exit 1; fi\necho solved\n"
+	must_be.Nil(ioutil.WriteFile(script, []byte(content), 0o755))
+
+	config := settings.Global.Holotree()
+	retries, backoff, flag := config.MicromambaRetries, config.MicromambaBackoff, common.MicromambaRetries
+	defer func() {
+		config.MicromambaRetries, config.MicromambaBackoff, common.MicromambaRetries = retries, backoff, flag
+	}()
+	config.MicromambaBackoff = 0
+	common.MicromambaRetries = 0
+
+	resolver := &Resolver{Executable: script}
+	target := filepath.Join(folder, "target")
+
+	config.MicromambaRetries = 1
+	sink := bytes.NewBuffer(nil)
+	code, _, err := runResolver(resolver, sink, "conda.yaml", target, false)
+	wont_be.Nil(err)
+	must_be.Equal(1, code)
+	must_be.Equal(1, bytes.Count(sink.Bytes(), []byte("retry 1/1")))
+
+	os.Remove(script + ".count")
+	config.MicromambaRetries = 2
+	sink = bytes.NewBuffer(nil)
+	code, _, err = runResolver(resolver, sink, "conda.yaml", target, false)
+	must_be.Nil(err)
+	must_be.Equal(0, code)
+	must_be.True(bytes.Contains(sink.Bytes(), []byte("solved")))
+}
+
+func TestResolverFallsBackToMirrorChannels(t *testing.T) {
+	if IsWindows() {
+		t.Skip("Not a windows test.")
+	}
+	must_be, wont_be := hamlet.Specifications(t)
+
+	folder, err := ioutil.TempDir("", "mirrors")
+	must_be.Nil(err)
+	defer os.RemoveAll(folder)
+
+	script := filepath.Join(folder, "resolver")
+	content := "#!/bin/sh\ncase \"$CONDA_CHANNELS\" in *mirror*) echo solved; exit 0;; esac\necho 'HTTP 503 Service Unavailable'\nexit 1\n"
+	must_be.Nil(ioutil.WriteFile(script, []byte(content), 0o755))
+
+	config := settings.Global.Holotree()
+	retries, mirrors, flag := config.MicromambaRetries, config.MirrorChannels, common.MicromambaRetries
+	defer func() {
+		config.MicromambaRetries, config.MirrorChannels, common.MicromambaRetries = retries, mirrors, flag
+	}()
+	config.MicromambaRetries = 0
+	common.MicromambaRetries = 0
+
+	resolver := &Resolver{Executable: script, Channels: []string{"https://primary.example.com/conda-forge"}}
+	target := filepath.Join(folder, "target")
+
+	config.MirrorChannels = nil
+	sink := bytes.NewBuffer(nil)
+	code, _, err := runResolver(resolver, sink, "conda.yaml", target, false)
+	wont_be.Nil(err)
+	must_be.Equal(1, code)
+
+	config.MirrorChannels = []*settings.CondaChannel{{Channel: "https://mirror.example.com/conda-forge"}}
+	sink = bytes.NewBuffer(nil)
+	code, _, err = runResolver(resolver, sink, "conda.yaml", target, false)
+	must_be.Nil(err)
+	must_be.Equal(0, code)
+	must_be.True(bytes.Contains(sink.Bytes(), []byte("fallback to mirror channels https://mirror.example.com/conda-forge")))
+	must_be.True(bytes.Contains(sink.Bytes(), []byte("solved")))
+	must_be.Equal([]string{"https://primary.example.com/conda-forge"}, resolver.Channels)
+}
@@ -105,6 +105,8 @@ func EnvironmentExtensionFor(location string) []string {
 		searchPath.AsEnvironmental("PATH"),
 	)
 	environment = append(environment, LoadActivationEnvironment(location)...)
+	environment = append(environment, UTF8Environment()...)
+	environment = append(environment, ProfileEnvironment(nil)...)
 	return environment
 }
 
@@ -149,7 +151,7 @@ func HasMicroMamba() bool {
 		return false
 	}
 	version, versionText := asVersion(MicromambaVersion())
-	goodEnough := version >= 16000
+	goodEnough := version >= micromambaMinimum
 	common.Debug("%q version is %q -> %v (good enough: %v)", BinMicromamba(), versionText, version, goodEnough)
 	common.Timeline("µmamba version is %q (at %q).", versionText, BinMicromamba())
 	return goodEnough
@@ -0,0 +1,362 @@
+package conda
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pretty"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	yamlErrorLine  = regexp.MustCompile(`line (\d+):`)
+	channelPattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]+(/[A-Za-z0-9_.\-]+)*$`)
+	knownKeys      = []string{"name", "channels", "dependencies", "prefix", "variables", "rccPostInstall", "rccValidate", "rccPreActivate", "rccPostActivate"}
+	condaQualifier = map[string]bool{"": true, "=": true, "==": true, ">=": true, "<=": true, ">": true, "<": true, "!=": true}
+	pipQualifier   = map[string]bool{"": true, "==": true, ">=": true, "<=": true, ">": true, "<": true, "!=": true, "~=": true, "===": true}
+	channelSchemes = map[string]bool{"http": true, "https": true, "file": true}
+)
+
+// SchemaIssue is one problem found from conda.yaml, with location where it
+// was found. Line and column are zero, when location is not known.
+type SchemaIssue struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Fatal   bool   `json:"fatal"`
+	Message string `json:"message"`
+}
+
+func (it *SchemaIssue) Format(filename string) string {
+	severity := "warning"
+	if it.Fatal {
+		severity = "error"
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", filename, it.Line, it.Column, severity, it.Message)
+}
+
+type SchemaIssues []*SchemaIssue
+
+func (it SchemaIssues) Fatal() int {
+	count := 0
+	for _, issue := range it {
+		if issue.Fatal {
+			count++
+		}
+	}
+	return count
+}
+
+type schemaLocator struct {
+	lines  []string
+	issues SchemaIssues
+}
+
+func (it *schemaLocator) key(name string) int {
+	for index, line := range it.lines {
+		if strings.HasPrefix(line, name) && strings.HasPrefix(strings.TrimSpace(line[len(name):]), ":") {
+			return index + 1
+		}
+	}
+	return 0
+}
+
+// value finds line and column of text, starting from given line; returned
+// line is also next place to continue searching from.
+func (it *schemaLocator) value(text string, from int) (int, int) {
+	if from < 1 {
+		from = 1
+	}
+	for index := from - 1; index < len(it.lines); index++ {
+		line := it.lines[index]
+		if comment := strings.Index(line, " #"); comment >= 0 {
+			line = line[:comment]
+		}
+		if column := strings.Index(line, text); column >= 0 && len(text) > 0 {
+			return index + 1, column + 1
+		}
+	}
+	return from, 0
+}
+
+func (it *schemaLocator) report(fatal bool, line, column int, form string, details ...interface{}) {
+	it.issues = append(it.issues, &SchemaIssue{
+		Line:    line,
+		Column:  column,
+		Fatal:   fatal,
+		Message: fmt.Sprintf(form, details...),
+	})
+}
+
+func closestKey(name string) string {
+	best, distance := "", 3
+	for _, known := range knownKeys {
+		if current := editDistance(strings.ToLower(name), strings.ToLower(known)); current < distance {
+			best, distance = known, current
+		}
+	}
+	return best
+}
+
+func editDistance(left, right string) int {
+	previous := make([]int, len(right)+1)
+	for index := range previous {
+		previous[index] = index
+	}
+	for row := 1; row <= len(left); row++ {
+		current := make([]int, len(right)+1)
+		current[0] = row
+		for column := 1; column <= len(right); column++ {
+			cost := 1
+			if left[row-1] == right[column-1] {
+				cost = 0
+			}
+			current[column] = minimum(previous[column]+1, current[column-1]+1, previous[column-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(right)]
+}
+
+func minimum(first int, rest ...int) int {
+	for _, value := range rest {
+		if value < first {
+			first = value
+		}
+	}
+	return first
+}
+
+func (it *schemaLocator) checkChannels(value interface{}) {
+	start := it.key("channels")
+	channels, ok := value.([]interface{})
+	if !ok {
+		if value != nil {
+			it.report(true, start, 1, "channels: must be list of channel names or URLs, like [conda-forge]")
+		}
+		return
+	}
+	cursor := start
+	for _, entry := range channels {
+		channel, ok := entry.(string)
+		if !ok {
+			it.report(true, cursor, 0, "channel %v must be text, like conda-forge", entry)
+			continue
+		}
+		line, column := it.value(channel, cursor)
+		cursor = line
+		channel = strings.TrimSpace(channel)
+		switch {
+		case channel == "defaults":
+			it.report(false, line, column, "channel %q is not available for micromamba (it ignores .condarc), prefer conda-forge", channel)
+		case strings.Contains(channel, "://"):
+			link, err := url.Parse(channel)
+			if err != nil || !channelSchemes[link.Scheme] || len(link.Host)+len(link.Path) == 0 {
+				it.report(true, line, column, "unsupported channel %q, only http, https, and file URLs or plain channel names work", channel)
+			}
+		case !channelPattern.MatchString(channel):
+			it.report(true, line, column, "malformed channel %q, expected name like conda-forge or URL", channel)
+		}
+	}
+}
+
+func (it *schemaLocator) checkConda(text string, line, column int) {
+	trimmed := strings.TrimSpace(text)
+	dependency := AsDependency(trimmed)
+	switch {
+	case len(trimmed) == 0:
+		it.report(true, line, column, "empty conda dependency")
+	case strings.HasPrefix(trimmed, "-") || strings.Contains(trimmed, " @ ") || strings.Contains(trimmed, "["):
+		it.report(true, line, column, "pip syntax in conda dependency %q, move it under \"- pip:\" section", trimmed)
+	case dependency == nil:
+		it.report(true, line, column, "malformed conda dependency %q, expected name=version, like python=3.9.13", trimmed)
+	case dependency.Qualifier == "~=" || dependency.Qualifier == "===":
+		it.report(true, line, column, "pip version pin %q in conda dependencies, use name=version or move it under \"- pip:\" section", trimmed)
+	case !condaQualifier[dependency.Qualifier]:
+		it.report(true, line, column, "malformed version pin %q in conda dependency %q, use '=' or '=='", dependency.Qualifier, trimmed)
+	}
+}
+
+func (it *schemaLocator) checkPip(text string, line, column int) {
+	trimmed := strings.TrimSpace(text)
+	if strings.HasPrefix(trimmed, "-") || strings.Contains(trimmed, " @ ") {
+		return
+	}
+	dependency := AsDependency(trimmed)
+	switch {
+	case len(trimmed) == 0:
+		it.report(true, line, column, "empty pip dependency")
+	case dependency == nil:
+		it.report(true, line, column, "malformed pip dependency %q, expected name==version, like requests==2.28.1", trimmed)
+	case strings.Contains(dependency.Name, "::"):
+		it.report(true, line, column, "conda channel syntax in pip dependency %q, move it out of \"- pip:\" section", trimmed)
+	case dependency.Qualifier == "=":
+		it.report(true, line, column, "conda version pin in pip dependency %q, pip needs '=='", trimmed)
+	case !pipQualifier[dependency.Qualifier]:
+		it.report(true, line, column, "malformed version pin %q in pip dependency %q, use '=='", dependency.Qualifier, trimmed)
+	default:
+		fields := strings.Fields(strings.SplitN(dependency.Versions, ";", 2)[0])
+		if len(fields) > 0 && strings.Contains(fields[0], "=") {
+			it.report(true, line, column, "conda build string in pip dependency %q, pip accepts only version", trimmed)
+		}
+	}
+}
+
+func (it *schemaLocator) checkDependencies(value interface{}) {
+	start := it.key("dependencies")
+	if value == nil {
+		it.report(true, start, 1, "missing dependencies, at least python is needed, like [python=3.9.13]")
+		return
+	}
+	dependencies, ok := value.([]interface{})
+	if !ok {
+		it.report(true, start, 1, "dependencies: must be list of conda dependencies and optional \"- pip:\" section")
+		return
+	}
+	cursor := start + 1
+	for _, entry := range dependencies {
+		switch item := entry.(type) {
+		case string:
+			line, column := it.value(item, cursor)
+			cursor = line + 1
+			it.checkConda(item, line, column)
+		case map[interface{}]interface{}:
+			for key, content := range item {
+				name := fmt.Sprintf("%v", key)
+				line, column := it.value(name+":", cursor)
+				cursor = line + 1
+				if name != "pip" {
+					it.report(true, line, column, "unknown dependency section %q, only \"pip:\" is supported", name)
+					continue
+				}
+				requirements, ok := content.([]interface{})
+				if !ok {
+					it.report(true, line, column, "pip: must be list of pip requirements, like [requests==2.28.1]")
+					continue
+				}
+				for _, requirement := range requirements {
+					text, ok := requirement.(string)
+					if !ok {
+						it.report(true, cursor, 0, "pip dependency %v must be text, like requests==2.28.1", requirement)
+						continue
+					}
+					line, column := it.value(text, cursor)
+					cursor = line + 1
+					it.checkPip(text, line, column)
+				}
+			}
+		default:
+			it.report(true, cursor, 0, "dependency %v must be text, like python=3.9.13", entry)
+		}
+	}
+}
+
+func (it *schemaLocator) checkCommands(name string, value interface{}) {
+	if value == nil {
+		return
+	}
+	commands, ok := value.([]interface{})
+	for _, entry := range commands {
+		if _, text := entry.(string); !text {
+			ok = false
+		}
+	}
+	if !ok {
+		it.report(true, it.key(name), 1, "%s: must be list of command lines", name)
+	}
+}
+
+// CondaYamlIssues checks conda.yaml content against what rcc and micromamba
+// actually support, and tells where problems were found.
+func CondaYamlIssues(content []byte) SchemaIssues {
+	locator := &schemaLocator{
+		lines:  strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n"),
+		issues: SchemaIssues{},
+	}
+	var document interface{}
+	err := yaml.Unmarshal(content, &document)
+	if err != nil {
+		line := 0
+		if found := yamlErrorLine.FindStringSubmatch(err.Error()); len(found) > 1 {
+			line, _ = strconv.Atoi(found[1])
+		}
+		locator.report(true, line, 0, "%v", err)
+		return locator.issues
+	}
+	top, ok := document.(map[interface{}]interface{})
+	if !ok {
+		locator.report(true, 1, 1, "conda.yaml must be mapping with channels and dependencies")
+		return locator.issues
+	}
+	keys := make([]string, 0, len(top))
+	for key := range top {
+		keys = append(keys, fmt.Sprintf("%v", key))
+	}
+	sort.Strings(keys)
+	known := make(map[string]bool)
+	for _, key := range knownKeys {
+		known[key] = true
+	}
+	for _, key := range keys {
+		if known[key] {
+			continue
+		}
+		if suggestion := closestKey(key); len(suggestion) > 0 {
+			locator.report(true, locator.key(key), 1, "unknown key %q, did you mean %q?", key, suggestion)
+		} else {
+			locator.report(true, locator.key(key), 1, "unknown key %q, known keys are %s", key, strings.Join(knownKeys, ", "))
+		}
+	}
+	for _, key := range []string{"name", "prefix"} {
+		switch top[key].(type) {
+		case []interface{}, map[interface{}]interface{}:
+			locator.report(true, locator.key(key), 1, "%s: must be plain text", key)
+		}
+	}
+	locator.checkChannels(top["channels"])
+	locator.checkDependencies(top["dependencies"])
+	locator.checkCommands("rccPostInstall", top["rccPostInstall"])
+	locator.checkCommands("rccValidate", top["rccValidate"])
+	locator.checkCommands("rccPreActivate", top["rccPreActivate"])
+	locator.checkCommands("rccPostActivate", top["rccPostActivate"])
+	sort.SliceStable(locator.issues, func(left, right int) bool {
+		return locator.issues[left].Line < locator.issues[right].Line
+	})
+	return locator.issues
+}
+
+// ReadCondaYamlIssues reads conda.yaml file and checks it.
+func ReadCondaYamlIssues(filename string) (SchemaIssues, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", filename, err)
+	}
+	return CondaYamlIssues(content), nil
+}
+
+// ValidateCondaYaml is run before environment creation. Warnings are logged,
+// and errors stop environment creation before micromamba gets confused.
+func ValidateCondaYaml(filename string) error {
+	issues, err := ReadCondaYamlIssues(filename)
+	if err != nil {
+		return err
+	}
+	errors := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		if issue.Fatal {
+			errors = append(errors, issue.Format(filename))
+		} else {
+			common.Log("%s%s%s", pretty.Yellow, issue.Format(filename), pretty.Reset)
+		}
+	}
+	if len(errors) > 0 {
+		return fmt.Errorf("%q is not valid conda.yaml (check it with \"rcc configure validate\"):\n  %s", filename, strings.Join(errors, "\n  "))
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package conda_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestValidCondaYamlHasNoIssues(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	for _, filename := range []string{"testdata/conda.yaml", "testdata/third.yaml", "../templates/standard/conda.yaml", "../robot_tests/conda.yaml"} {
+		issues, err := conda.ReadCondaYamlIssues(filename)
+		must_be.Nil(err)
+		must_be.Equal(0, issues.Fatal())
+	}
+}
+
+func TestSchemaIssuesHaveLocations(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	content := `channels:
+  - conda-forge
+  - ftp://example.com/channel
+dependencis:
+  - python
+dependencies:
+  - python=3.9.13
+  - pandas~=1.5
+  - numpy=>1.23
+  - pip:
+    - requests=2.28.1
+    - robotframework==6.0.2
+rccPostInstall: rfbrowser init
+`
+	issues := conda.CondaYamlIssues([]byte(content))
+	must_be.Equal(6, issues.Fatal())
+	formatted := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		formatted = append(formatted, issue.Format("conda.yaml"))
+	}
+	must_be.Equal("conda.yaml:3:5: error: unsupported channel \"ftp://example.com/channel\", only http, https, and file URLs or plain channel names work", formatted[0])
+	must_be.Equal("conda.yaml:4:1: error: unknown key \"dependencis\", did you mean \"dependencies\"?", formatted[1])
+	must_be.True(strings.HasPrefix(formatted[2], "conda.yaml:8:5: error: pip version pin"))
+	must_be.True(strings.HasPrefix(formatted[3], "conda.yaml:9:5: error: malformed version pin \"=>\""))
+	must_be.True(strings.HasPrefix(formatted[4], "conda.yaml:11:7: error: conda version pin in pip dependency"))
+	must_be.True(strings.HasPrefix(formatted[5], "conda.yaml:13:1: error: rccPostInstall:"))
+
+	issues = conda.CondaYamlIssues([]byte("channels:\n  - defaults\ndependencies:\n  - python=3.9.13\n"))
+	must_be.Equal(1, len(issues))
+	wont_be.True(issues[0].Fatal)
+
+	issues = conda.CondaYamlIssues([]byte("channels:\n  - conda-forge\ndependencies:\n  - python=3.9.13\n    pip: 22.3\n"))
+	must_be.Equal(1, issues.Fatal())
+	must_be.Equal(5, issues[0].Line)
+}
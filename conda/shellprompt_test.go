@@ -0,0 +1,16 @@
+package conda_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestShellPromptShowsSpace(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	wont_be.Equal("", conda.ShellPromptVariable)
+	must_be.True(strings.HasPrefix(conda.ShellPrompt("debug"), "(rcc debug) "))
+}
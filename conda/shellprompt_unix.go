@@ -0,0 +1,18 @@
+//go:build darwin || linux || !windows
+// +build darwin linux !windows
+
+package conda
+
+import (
+	"fmt"
+)
+
+const (
+	ShellPromptVariable = "PS1"
+)
+
+// ShellPrompt is prompt of interactive task shell, showing holotree space
+// that shell is activated for.
+func ShellPrompt(space string) string {
+	return fmt.Sprintf(`(rcc %s) \w \$ `, space)
+}
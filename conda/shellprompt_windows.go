@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package conda
+
+import (
+	"fmt"
+)
+
+const (
+	ShellPromptVariable = "PROMPT"
+)
+
+// ShellPrompt is prompt of interactive task shell, showing holotree space
+// that shell is activated for.
+func ShellPrompt(space string) string {
+	return fmt.Sprintf(`(rcc %s) $P$G`, space)
+}
@@ -0,0 +1,179 @@
+package conda
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+	"github.com/robocorp/rcc/shell"
+)
+
+const (
+	micromambaSolver = `micromamba`
+	condaSolver      = `conda`
+	pixiSolver       = `pixi`
+)
+
+// Solver is something that can turn conda.yaml (or explicit spec file) into
+// environment in target folder. Creation can take multiple commands, which
+// are run in sequence.
+type Solver interface {
+	Name() string
+	Probe() error
+	Environment() []string
+	CreateCommands(condaYaml, targetFolder string, force bool) ([][]string, error)
+	ListCommand(targetFolder string) []string
+	DryrunCommand(environment *Environment, condaYaml, prefix string, force bool) ([]string, error)
+	Mirrored(channels []string) (Solver, bool)
+}
+
+// KnownSolvers lists names accepted in "solver" setting and in robot.yaml.
+func KnownSolvers() []string {
+	return []string{micromambaSolver, condaSolver, pixiSolver}
+}
+
+// IsKnownSolver tells if name is valid solver name (empty means default).
+func IsKnownSolver(name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if len(name) == 0 {
+		return true
+	}
+	for _, known := range KnownSolvers() {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+// SolverName returns wanted solver (as selected by robot.yaml), or one from
+// settings, or micromamba as default.
+func SolverName(wanted string) string {
+	name := strings.TrimSpace(wanted)
+	if len(name) == 0 {
+		name = strings.TrimSpace(settings.Global.Solver())
+	}
+	if len(name) == 0 {
+		return micromambaSolver
+	}
+	return strings.ToLower(name)
+}
+
+func solverExecutable(name string) (string, error) {
+	wanted := strings.TrimSpace(settings.Global.SolverExecutable())
+	if len(wanted) == 0 {
+		wanted = name
+	}
+	executable, err := exec.LookPath(common.ExpandPath(wanted))
+	if err != nil {
+		return "", fmt.Errorf("Solver %q was selected, but executable %q is not available, reason: %v [hint: check solver-executable in settings]", name, wanted, err)
+	}
+	return executable, nil
+}
+
+func probeCommand(name, executable string, arguments ...string) error {
+	command := append([]string{executable}, arguments...)
+	output, code, err := shell.New(nil, ".", command...).CaptureOutput()
+	if err != nil || code != 0 {
+		return fmt.Errorf("Solver %q is not usable, %q failed [%d], reason: %v\n%s", name, strings.Join(command, " "), code, err, output)
+	}
+	return nil
+}
+
+// PixiSolver resolves environment with pixi into explicit spec file, and
+// then installs that file using micromamba (or fallback resolver).
+type PixiSolver struct {
+	Executable string
+	Platform   string
+	Installer  *Resolver
+}
+
+func newPixiSolver(channels []string) (*PixiSolver, error) {
+	executable, err := solverExecutable(pixiSolver)
+	if err != nil {
+		return nil, err
+	}
+	installer, err := micromambaResolver(channels)
+	if err != nil {
+		return nil, err
+	}
+	return &PixiSolver{Executable: executable, Platform: pixiPlatform(), Installer: installer}, nil
+}
+
+func pixiPlatform() string {
+	switch runtime.GOOS + "/" + runtime.GOARCH {
+	case "linux/arm64":
+		return "linux-aarch64"
+	case "darwin/amd64":
+		return "osx-64"
+	case "darwin/arm64":
+		return "osx-arm64"
+	case "windows/amd64":
+		return "win-64"
+	default:
+		return "linux-64"
+	}
+}
+
+func (it *PixiSolver) Name() string {
+	return pixiSolver
+}
+
+// Probe makes sure that pixi is new enough to export explicit spec files,
+// since that is the way environment gets from pixi to holotree.
+func (it *PixiSolver) Probe() error {
+	if common.OfflineFlag {
+		return fmt.Errorf("Solver %q cannot resolve environments in offline mode, use micromamba solver with --offline instead.", pixiSolver)
+	}
+	err := probeCommand(it.Name(), it.Executable, "project", "export", "conda-explicit-spec", "--help")
+	if err != nil {
+		return fmt.Errorf("%v [hint: pixi v0.26 or newer is needed]", err)
+	}
+	return it.Installer.Probe()
+}
+
+func (it *PixiSolver) Environment() []string {
+	return it.Installer.Environment()
+}
+
+func (it *PixiSolver) SpecFile(workdir string) string {
+	return filepath.Join(workdir, fmt.Sprintf("default_%s_conda_spec.txt", it.Platform))
+}
+
+func (it *PixiSolver) CreateCommands(condaYaml, targetFolder string, force bool) ([][]string, error) {
+	if explicitSpec(condaYaml) {
+		return [][]string{it.Installer.explicitCommand(condaYaml, targetFolder)}, nil
+	}
+	workdir, err := ioutil.TempDir(common.RobocorpTemp(), "pixi")
+	if err != nil {
+		return nil, err
+	}
+	return it.commands(condaYaml, workdir, targetFolder), nil
+}
+
+func (it *PixiSolver) commands(condaYaml, workdir, targetFolder string) [][]string {
+	manifest := filepath.Join(workdir, "pixi.toml")
+	initialize := common.NewCommander(it.Executable, "init", "--import", condaYaml, workdir)
+	export := common.NewCommander(it.Executable, "project", "export", "conda-explicit-spec", "--manifest-path", manifest, "--platform", it.Platform, workdir)
+	export.ConditionalFlag(common.VerboseEnvironmentBuilding(), "--verbose")
+	install := it.Installer.explicitCommand(it.SpecFile(workdir), targetFolder)
+	return [][]string{initialize.CLI(), export.CLI(), install}
+}
+
+func (it *PixiSolver) ListCommand(targetFolder string) []string {
+	return it.Installer.ListCommand(targetFolder)
+}
+
+// Mirrored is not supported, since pixi takes its channels from conda.yaml.
+func (it *PixiSolver) Mirrored(channels []string) (Solver, bool) {
+	return nil, false
+}
+
+func (it *PixiSolver) DryrunCommand(environment *Environment, condaYaml, prefix string, force bool) ([]string, error) {
+	return nil, fmt.Errorf("Solver %q does not support dry run plans, so prefetch needs micromamba or conda solver.", pixiSolver)
+}
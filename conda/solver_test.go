@@ -0,0 +1,46 @@
+package conda
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestPixiSolverExportsAndInstallsExplicitSpec(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	installer := &Resolver{Executable: "micromamba", Micromamba: true}
+	pixi := &PixiSolver{Executable: "pixi", Platform: "linux-64", Installer: installer}
+	must_be.Equal("pixi", pixi.Name())
+
+	workdir := filepath.Join("tmp", "pixi")
+	commands := pixi.commands("conda.yaml", workdir, "/tmp/stage")
+	must_be.Equal(3, len(commands))
+	must_be.Equal([]string{"pixi", "init", "--import", "conda.yaml", workdir}, commands[0])
+	must_be.Equal("conda-explicit-spec", commands[1][3])
+	must_be.Equal(filepath.Join(workdir, "pixi.toml"), commands[1][5])
+	must_be.Equal(workdir, commands[1][8])
+	must_be.Equal("micromamba", commands[2][0])
+	must_be.True(strings.Contains(strings.Join(commands[2], " "), filepath.Join(workdir, "default_linux-64_conda_spec.txt")))
+
+	_, err := pixi.DryrunCommand(nil, "conda.yaml", "/tmp/stage", false)
+	must_be.True(err != nil)
+	must_be.Equal([]string{"micromamba", "list", "--json"}, pixi.ListCommand("/tmp/stage"))
+}
+
+func TestSolverSelectionIsValidated(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	must_be.True(IsKnownSolver(""))
+	must_be.True(IsKnownSolver(" Pixi "))
+	wont_be.True(IsKnownSolver("poetry"))
+
+	must_be.Equal("conda", SolverName("Conda"))
+
+	solver, err := MustResolver("poetry")
+	must_be.Nil(solver)
+	must_be.True(err != nil)
+	must_be.True(strings.Contains(err.Error(), "Unknown solver \"poetry\""))
+}
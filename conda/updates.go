@@ -0,0 +1,349 @@
+package conda
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/settings"
+	"github.com/robocorp/rcc/yamledit"
+)
+
+const (
+	anacondaPackageApi = `https://api.anaconda.org/package/%s/%s`
+	anacondaChannels   = `https://conda.anaconda.org`
+)
+
+var (
+	versionPartPattern = regexp.MustCompile(`\d+|[A-Za-z]+`)
+	changelogKeys      = []string{"changelog", "changes", "release notes", "releasenotes", "history", "news"}
+)
+
+type Candidate struct {
+	Name    string `json:"name"`
+	Pip     bool   `json:"pip"`
+	Current string `json:"current"`
+	Latest  string `json:"latest"`
+	Link    string `json:"link"`
+	Replace string `json:"replace"`
+}
+
+type pypiRelease struct {
+	Info struct {
+		Version     string            `json:"version"`
+		ProjectUrl  string            `json:"project_url"`
+		ProjectUrls map[string]string `json:"project_urls"`
+	} `json:"info"`
+	Urls []*pypiFile `json:"urls"`
+}
+
+type anacondaRelease struct {
+	LatestVersion string `json:"latest_version"`
+	HtmlUrl       string `json:"html_url"`
+	DevUrl        string `json:"dev_url"`
+}
+
+type repodataPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type repodata struct {
+	Packages      map[string]*repodataPackage `json:"packages"`
+	CondaPackages map[string]*repodataPackage `json:"packages.conda"`
+}
+
+func fetchJson(link string, target interface{}) error {
+	client := &http.Client{Transport: settings.Global.ConfiguredHttpTransport()}
+	request, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("User-Agent", common.UserAgent())
+	request.Header.Set("Accept", "application/json")
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s (%s)", response.Status, link)
+	}
+	return json.NewDecoder(response.Body).Decode(target)
+}
+
+// prerelease tells how shorter version compares to longer one, with
+// given extra part: "1.0" is after "1.0rc1", but before "1.0.1".
+func prerelease(extra string) int {
+	_, err := strconv.Atoi(extra)
+	if err != nil {
+		return 1
+	}
+	return -1
+}
+
+// CompareVersions compares versions using PEP 440 ordering (epochs, pre,
+// post, dev and local segments), when both are PEP 440 versions, and
+// otherwise as dotted version strings, numerically where parts are numbers.
+// Result is negative, zero or positive as usual.
+func CompareVersions(left, right string) int {
+	first, lok := parsePep440(left)
+	second, rok := parsePep440(right)
+	if lok && rok {
+		return first.compare(second)
+	}
+	return compareDotted(left, right)
+}
+
+func compareDotted(left, right string) int {
+	lefts := versionPartPattern.FindAllString(left, -1)
+	rights := versionPartPattern.FindAllString(right, -1)
+	for at := 0; at < len(lefts) || at < len(rights); at++ {
+		if at >= len(lefts) {
+			return prerelease(rights[at])
+		}
+		if at >= len(rights) {
+			return -prerelease(lefts[at])
+		}
+		first, ferr := strconv.Atoi(lefts[at])
+		second, serr := strconv.Atoi(rights[at])
+		switch {
+		case ferr == nil && serr == nil:
+			if first != second {
+				return first - second
+			}
+		case ferr == nil:
+			return 1
+		case serr == nil:
+			return -1
+		default:
+			if compared := strings.Compare(lefts[at], rights[at]); compared != 0 {
+				return compared
+			}
+		}
+	}
+	return 0
+}
+
+func isPinned(dependency *Dependency) bool {
+	return (dependency.Qualifier == "=" || dependency.Qualifier == "==") && len(dependency.Versions) > 0
+}
+
+func changelogLink(release *pypiRelease) string {
+	for _, wanted := range changelogKeys {
+		for key, link := range release.Info.ProjectUrls {
+			if strings.ToLower(key) == wanted {
+				return link
+			}
+		}
+	}
+	return release.Info.ProjectUrl
+}
+
+func pipCandidate(dependency *Dependency) (*Candidate, error) {
+	release := &pypiRelease{}
+	err := fetchJson(settings.Global.PypiLink(fmt.Sprintf("/pypi/%s/json", dependency.Representation())), release)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{
+		Name:    dependency.Name,
+		Pip:     true,
+		Current: dependency.Versions,
+		Latest:  release.Info.Version,
+		Link:    changelogLink(release),
+		Replace: fmt.Sprintf("%s%s%s", dependency.Name, dependency.Qualifier, release.Info.Version),
+	}, nil
+}
+
+// channelSubdir is conda platform subdirectory of this machine.
+func channelSubdir() string {
+	arch := "64"
+	if runtime.GOARCH == "arm64" {
+		arch = "arm64"
+		if runtime.GOOS == "linux" {
+			arch = "aarch64"
+		}
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return "osx-" + arch
+	case "windows":
+		return "win-" + arch
+	default:
+		return runtime.GOOS + "-" + arch
+	}
+}
+
+// channelLink turns channel name into URL using configured channel alias,
+// same way as micromamba does with --channel-alias.
+func channelLink(channel string) string {
+	if strings.Contains(channel, "://") {
+		return strings.TrimSuffix(channel, "/")
+	}
+	alias := strings.TrimSuffix(settings.Global.CondaURL(), "/")
+	if len(alias) == 0 {
+		alias = anacondaChannels
+	}
+	return fmt.Sprintf("%s/%s", alias, strings.Trim(channel, "/"))
+}
+
+// anacondaChannel gives name of public anaconda.org channel, which can be
+// queried from package API instead of downloading its (huge) repodata.
+func anacondaChannel(link string) (string, bool) {
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.User != nil || fmt.Sprintf("%s://%s", parsed.Scheme, parsed.Host) != anacondaChannels {
+		return "", false
+	}
+	name := strings.Trim(parsed.Path, "/")
+	if len(name) == 0 || strings.Contains(name, "/") {
+		return "", false
+	}
+	return name, true
+}
+
+func anacondaLatest(channel, name string) (string, string, error) {
+	release := &anacondaRelease{}
+	err := fetchJson(fmt.Sprintf(anacondaPackageApi, channel, name), release)
+	if err != nil {
+		return "", "", err
+	}
+	link := release.DevUrl
+	if len(link) == 0 {
+		link = release.HtmlUrl
+	}
+	return release.LatestVersion, link, nil
+}
+
+func repodataLatest(link, name string) (string, error) {
+	latest := ""
+	var err error
+	for _, subdir := range []string{channelSubdir(), "noarch"} {
+		index := &repodata{}
+		failure := fetchJson(fmt.Sprintf("%s/%s/repodata.json", link, subdir), index)
+		if failure != nil {
+			err = failure
+			continue
+		}
+		for _, packages := range []map[string]*repodataPackage{index.Packages, index.CondaPackages} {
+			for _, candidate := range packages {
+				if candidate.Name == name && (len(latest) == 0 || CompareVersions(candidate.Version, latest) > 0) {
+					latest = candidate.Version
+				}
+			}
+		}
+	}
+	if len(latest) == 0 && err == nil {
+		err = fmt.Errorf("No %q in channel.", name)
+	}
+	if len(latest) == 0 {
+		return "", err
+	}
+	return latest, nil
+}
+
+// condaCandidate looks dependency from channels in priority order, and first
+// channel having it decides latest version. Links are used for queries, and
+// shown to user without possible tokens.
+func condaCandidate(links []string, dependency *Dependency) (*Candidate, error) {
+	var err error
+	for _, channel := range links {
+		link := channelLink(channel)
+		latest, changelog := "", channelName(channel)
+		if public, ok := anacondaChannel(link); ok {
+			latest, changelog, err = anacondaLatest(public, dependency.Name)
+		} else {
+			latest, err = repodataLatest(link, dependency.Name)
+		}
+		if err != nil {
+			continue
+		}
+		return &Candidate{
+			Name:    dependency.Name,
+			Pip:     false,
+			Current: dependency.Versions,
+			Latest:  latest,
+			Link:    changelog,
+			Replace: fmt.Sprintf("%s%s%s", dependency.Name, dependency.Qualifier, latest),
+		}, nil
+	}
+	if err == nil {
+		err = fmt.Errorf("No channels to check %q from.", dependency.Name)
+	}
+	return nil, err
+}
+
+// FindUpdates checks channels and package index for newer versions of
+// pinned dependencies and returns those that could be bumped. Channels from
+// settings (with their tokens) replace channels of conda.yaml.
+func FindUpdates(environment *Environment) ([]*Candidate, error) {
+	links := environment.Channels
+	if ConfiguredChannels() {
+		var err error
+		links, err = ChannelLinks()
+		if err != nil {
+			return nil, err
+		}
+	}
+	result := []*Candidate{}
+	consider := func(candidate *Candidate, err error, name string) {
+		if err != nil {
+			common.Debug("Could not check updates for %q, reason: %v", name, err)
+			return
+		}
+		if CompareVersions(candidate.Latest, candidate.Current) > 0 {
+			result = append(result, candidate)
+		}
+	}
+	for _, dependency := range environment.Conda {
+		if isPinned(dependency) {
+			candidate, err := condaCandidate(links, dependency)
+			consider(candidate, err, dependency.Name)
+		}
+	}
+	for _, dependency := range environment.Pip {
+		if isPinned(dependency) {
+			candidate, err := pipCandidate(dependency)
+			consider(candidate, err, dependency.Name)
+		}
+	}
+	return result, nil
+}
+
+// ApplyUpdates replaces pinned versions in place with comment preserving
+// editor, so that no other part of conda.yaml changes.
+func ApplyUpdates(filename string, candidates []*Candidate) (err error) {
+	defer fail.Around(&err)
+
+	document, err := yamledit.Load(filename)
+	fail.On(err != nil, "%v", err)
+	for _, candidate := range candidates {
+		path := []string{"dependencies"}
+		if candidate.Pip {
+			path = append(path, "pip")
+		}
+		items, err := document.Sequence(path...)
+		fail.On(err != nil, "%v", err)
+		found := false
+		for _, item := range items {
+			dependency := AsDependency(item.Unquoted())
+			if item.IsMapping() || dependency == nil || dependency.Name != candidate.Name {
+				continue
+			}
+			document.Replace(item, candidate.Replace)
+			found = true
+			break
+		}
+		fail.On(!found, "Could not find %q from %q, so it was left untouched.", candidate.Name, filename)
+	}
+	_, err = CondaYamlFrom(document.Bytes())
+	fail.On(err != nil, "Updated %q would not be valid conda.yaml, so it was left untouched. Reason: %v", filename, err)
+	return document.SaveAs(filename)
+}
@@ -0,0 +1,115 @@
+package conda_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/settings"
+)
+
+func privateChannel(t *testing.T, prefix string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if !strings.HasPrefix(request.URL.Path, prefix) {
+			http.NotFound(response, request)
+			return
+		}
+		switch {
+		case strings.HasSuffix(request.URL.Path, "/noarch/repodata.json"):
+			fmt.Fprint(response, `{"packages": {"rpaframework-4.3.0-py_0.tar.bz2": {"name": "rpaframework", "version": "4.3.0"}}}`)
+		case strings.HasSuffix(request.URL.Path, "/repodata.json"):
+			fmt.Fprint(response, `{"packages": {"nodejs-16.13.0-h1.tar.bz2": {"name": "nodejs", "version": "16.13.0"}}, "packages.conda": {"nodejs-16.14.2-h1.conda": {"name": "nodejs", "version": "16.14.2"}}}`)
+		default:
+			http.NotFound(response, request)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestUpdatesAreFoundFromUrlChannels(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	server := privateChannel(t, "/conda/private/")
+	environment := &conda.Environment{
+		Channels: []string{server.URL + "/conda/missing", server.URL + "/conda/private/"},
+		Conda: []*conda.Dependency{
+			conda.AsDependency("nodejs=16.13.0"),
+			conda.AsDependency("rpaframework=4.1.0"),
+			conda.AsDependency("python=3.9.13"),
+		},
+	}
+	candidates, err := conda.FindUpdates(environment)
+	must.Nil(err)
+	must.Equal(2, len(candidates))
+	must.Equal("nodejs=16.14.2", candidates[0].Replace)
+	must.Equal(server.URL+"/conda/private/", candidates[0].Link)
+	must.Equal("rpaframework=4.3.0", candidates[1].Replace)
+	wont.True(candidates[1].Pip)
+}
+
+func TestUpdatesUseChannelsFromSettings(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	config := settings.Global.Holotree()
+	original := config.CondaChannels
+	defer func() {
+		config.CondaChannels = original
+	}()
+
+	os.Setenv("RCC_TEST_CHANNEL_TOKEN", "s3cret")
+	defer os.Unsetenv("RCC_TEST_CHANNEL_TOKEN")
+
+	server := privateChannel(t, "/t/s3cret/conda/private/")
+	config.CondaChannels = []*settings.CondaChannel{
+		&settings.CondaChannel{Channel: server.URL + "/conda/private", TokenEnv: "RCC_TEST_CHANNEL_TOKEN"},
+	}
+	environment := &conda.Environment{
+		Channels: []string{"conda-forge"},
+		Conda:    []*conda.Dependency{conda.AsDependency("nodejs=16.13.0")},
+	}
+	candidates, err := conda.FindUpdates(environment)
+	must.Nil(err)
+	must.Equal(1, len(candidates))
+	must.Equal("16.14.2", candidates[0].Latest)
+	must.Equal(server.URL+"/conda/private", candidates[0].Link)
+
+	user := privateChannel(t, "/conda/private/")
+	config.CondaChannels[0].Channel = user.URL + "/conda/private"
+	config.CondaChannels[0].Username = "robot"
+	candidates, err = conda.FindUpdates(environment)
+	must.Nil(err)
+	must.Equal(1, len(candidates))
+	must.Equal(user.URL+"/conda/private", candidates[0].Link)
+
+	config.CondaChannels[0].TokenEnv = "RCC_TEST_CHANNEL_TOKEN_MISSING"
+	_, err = conda.FindUpdates(environment)
+	wont.Nil(err)
+}
+
+func TestAppliedUpdatesKeepCommentsAndFormatting(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	filename := t.TempDir() + "/conda.yaml"
+	original := "# reports environment\nchannels: [conda-forge]\ndependencies:\n  - nodejs=16.13.0 # for browser\n  - pip:\n    # keywords\n    - 'rpaframework==4.2.0'\n"
+	must.Nil(os.WriteFile(filename, []byte(original), 0o644))
+
+	candidates := []*conda.Candidate{
+		{Name: "nodejs", Replace: "nodejs=16.14.2"},
+		{Name: "rpaframework", Pip: true, Replace: "rpaframework==4.3.0"},
+	}
+	must.Nil(conda.ApplyUpdates(filename, candidates))
+	content, err := os.ReadFile(filename)
+	must.Nil(err)
+	must.Equal("# reports environment\nchannels: [conda-forge]\ndependencies:\n  - nodejs=16.14.2 # for browser\n  - pip:\n    # keywords\n    - 'rpaframework==4.3.0'\n", string(content))
+
+	wont.Nil(conda.ApplyUpdates(filename, []*conda.Candidate{{Name: "missing", Replace: "missing=1.0"}}))
+	unchanged, err := os.ReadFile(filename)
+	must.Nil(err)
+	must.Equal(string(content), string(unchanged))
+}
@@ -0,0 +1,227 @@
+package conda
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/robocorp/rcc/anywork"
+	"github.com/robocorp/rcc/cloud"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/settings"
+)
+
+type pypiFile struct {
+	Filename    string            `json:"filename"`
+	Url         string            `json:"url"`
+	Packagetype string            `json:"packagetype"`
+	Digests     map[string]string `json:"digests"`
+	Yanked      bool              `json:"yanked"`
+}
+
+// Prefetcher downloads pinned pip wheels into wheel cache in background,
+// while micromamba is still creating conda part of environment.
+type Prefetcher struct {
+	group   sync.WaitGroup
+	fetched uint64
+	failed  uint64
+	marker  string
+}
+
+// pinnedPip lists exactly pinned ("==") requirements from requirements file,
+// leaving out URL requirements and options.
+func pinnedPip(requirementsText string) []*Dependency {
+	result := []*Dependency{}
+	reader, err := os.Open(requirementsText)
+	if err != nil {
+		return result
+	}
+	defer reader.Close()
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(scanner.Text()), "\\"))
+		if len(line) == 0 || strings.HasPrefix(line, "-") || strings.HasPrefix(line, "#") || strings.Contains(line, "@") {
+			continue
+		}
+		dependency := AsDependency(line)
+		if dependency == nil || dependency.Qualifier != "==" || strings.ContainsAny(dependency.Versions, "*;,") {
+			continue
+		}
+		result = append(result, dependency)
+	}
+	return result
+}
+
+// pythonTag is wheel tag (like "cp39") of python in conda.yaml, or empty
+// when python is not pinned there.
+func pythonTag(condaYaml string) string {
+	environment, err := ReadCondaYaml(condaYaml)
+	if err != nil {
+		return ""
+	}
+	for _, dependency := range environment.Conda {
+		if dependency.Name != "python" {
+			continue
+		}
+		parts := strings.Split(dependency.Versions, ".")
+		if len(parts) < 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return ""
+		}
+		return fmt.Sprintf("cp%s%s", parts[0], parts[1])
+	}
+	return ""
+}
+
+func wheelPlatform(tag string) bool {
+	if tag == "any" {
+		return true
+	}
+	switch runtime.GOOS {
+	case "windows":
+		return tag == "win_amd64"
+	case "darwin":
+		if runtime.GOARCH == "arm64" {
+			return strings.HasPrefix(tag, "macosx") && (strings.HasSuffix(tag, "_arm64") || strings.HasSuffix(tag, "_universal2"))
+		}
+		return strings.HasPrefix(tag, "macosx") && (strings.HasSuffix(tag, "_x86_64") || strings.HasSuffix(tag, "_intel") || strings.HasSuffix(tag, "_universal2"))
+	default:
+		if runtime.GOARCH == "arm64" {
+			return strings.HasPrefix(tag, "manylinux") && strings.HasSuffix(tag, "_aarch64")
+		}
+		return strings.HasPrefix(tag, "manylinux") && strings.HasSuffix(tag, "_x86_64")
+	}
+}
+
+// wheelScore tells how good match wheel filename is for this platform and
+// python (zero means that it is not usable at all).
+func wheelScore(filename, python string) int {
+	if !strings.HasSuffix(filename, ".whl") {
+		return 0
+	}
+	parts := strings.Split(strings.TrimSuffix(filename, ".whl"), "-")
+	if len(parts) < 5 {
+		return 0
+	}
+	pytags, abi, platforms := parts[len(parts)-3], parts[len(parts)-2], parts[len(parts)-1]
+	platform := false
+	for _, tag := range strings.Split(platforms, ".") {
+		platform = platform || wheelPlatform(tag)
+	}
+	if !platform {
+		return 0
+	}
+	score := 0
+	for _, tag := range strings.Split(pytags, ".") {
+		switch {
+		case len(python) > 0 && tag == python && abi != "abi3":
+			score = 4
+		case len(python) > 0 && abi == "abi3" && strings.HasPrefix(tag, "cp3") && tag <= python && score < 3:
+			score = 3
+		case abi == "none" && (tag == "py3" || tag == "py2.py3") && score < 2:
+			score = 2
+		}
+	}
+	if score > 0 && platforms != "any" {
+		score += 10
+	}
+	return score
+}
+
+func bestWheel(release *pypiRelease, python string) *pypiFile {
+	var best *pypiFile
+	top := 0
+	for _, candidate := range release.Urls {
+		if candidate.Yanked || candidate.Packagetype != "bdist_wheel" {
+			continue
+		}
+		score := wheelScore(candidate.Filename, python)
+		if score > top {
+			best, top = candidate, score
+		}
+	}
+	return best
+}
+
+func (it *Prefetcher) fetch(dependency *Dependency, python, wheelCache string) {
+	defer it.group.Done()
+
+	name, version := dependency.Representation(), strings.Fields(dependency.Versions)[0]
+	release := &pypiRelease{}
+	err := fetchJson(settings.Global.PypiLink(fmt.Sprintf("/pypi/%s/%s/json", name, version)), release)
+	if err != nil {
+		common.Debug("Prefetch of %s==%s metadata failed: %v", name, version, err)
+		atomic.AddUint64(&it.failed, 1)
+		return
+	}
+	wheel := bestWheel(release, python)
+	if wheel == nil {
+		common.Debug("Prefetch of %s==%s found no usable wheel.", name, version)
+		return
+	}
+	target := filepath.Join(wheelCache, wheel.Filename)
+	if pathlib.IsFile(target) {
+		return
+	}
+	err = cloud.ResumableDownload(wheel.Url, target, wheel.Digests["sha256"])
+	if err != nil {
+		common.Debug("Prefetch of %q failed: %v", wheel.Filename, err)
+		atomic.AddUint64(&it.failed, 1)
+		return
+	}
+	atomic.AddUint64(&it.fetched, 1)
+}
+
+// PrefetchWheels starts downloading pinned pip wheels in parallel (using
+// anywork pool), and is keyed by pinned requirement list, so that same list
+// is not fetched again.
+func PrefetchWheels(condaYaml, requirementsText string) *Prefetcher {
+	it := &Prefetcher{}
+	if common.OfflineFlag || ConfiguredPipIndex() {
+		return it
+	}
+	pinned := pinnedPip(requirementsText)
+	if len(pinned) == 0 {
+		return it
+	}
+	python := pythonTag(condaYaml)
+	keys := make([]string, 0, len(pinned)+2)
+	keys = append(keys, runtime.GOOS, runtime.GOARCH, python)
+	for _, dependency := range pinned {
+		keys = append(keys, dependency.Original)
+	}
+	sort.Strings(keys)
+	wheelCache := common.WheelCache()
+	it.marker = filepath.Join(wheelCache, fmt.Sprintf("prefetch_%s.done", common.ShortDigest(strings.Join(keys, "\n"))))
+	if pathlib.IsFile(it.marker) {
+		common.Debug("Pip wheels already prefetched, see %q.", it.marker)
+		it.marker = ""
+		return it
+	}
+	pathlib.EnsureDirectory(wheelCache)
+	common.Debug("Prefetching %d pinned pip wheels for %q in background.", len(pinned), python)
+	for _, dependency := range pinned {
+		it.group.Add(1)
+		dependency := dependency
+		anywork.Backlog(func() {
+			it.fetch(dependency, python, wheelCache)
+		})
+	}
+	return it
+}
+
+// Wait blocks until prefetch is done, and returns number of fetched wheels.
+func (it *Prefetcher) Wait() uint64 {
+	it.group.Wait()
+	if len(it.marker) > 0 && atomic.LoadUint64(&it.failed) == 0 {
+		ioutil.WriteFile(it.marker, []byte(common.Version), 0o644)
+	}
+	return atomic.LoadUint64(&it.fetched)
+}
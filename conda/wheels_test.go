@@ -0,0 +1,48 @@
+package conda
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestPinnedPipRequirementsAreFoundForPrefetch(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	folder, err := ioutil.TempDir("", "wheels")
+	must_be.Nil(err)
+	defer os.RemoveAll(folder)
+
+	requirements := filepath.Join(folder, "requirements.txt")
+	content := "robotframework==6.0.2 \\\n    --hash=sha256:aaaa\nrequests>=2.28\npandas\nrpaframework @ https://example.com/rpaframework.whl\n--index-url https://example.com/simple\nnumpy==1.23.*\n"
+	must_be.Nil(ioutil.WriteFile(requirements, []byte(content), 0o644))
+
+	pinned := pinnedPip(requirements)
+	must_be.Equal(1, len(pinned))
+	must_be.Equal("robotframework", pinned[0].Name)
+}
+
+func TestWheelsAreScoredForPlatformAndPython(t *testing.T) {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("Wheel tags in this test are for linux amd64.")
+	}
+	must_be, _ := hamlet.Specifications(t)
+
+	must_be.Equal(0, wheelScore("robotframework-6.0.2.tar.gz", "cp39"))
+	must_be.Equal(2, wheelScore("robotframework-6.0.2-py3-none-any.whl", "cp39"))
+	must_be.Equal(14, wheelScore("numpy-1.23.5-cp39-cp39-manylinux_2_17_x86_64.manylinux2014_x86_64.whl", "cp39"))
+	must_be.Equal(0, wheelScore("numpy-1.23.5-cp310-cp310-manylinux_2_17_x86_64.manylinux2014_x86_64.whl", "cp39"))
+	must_be.Equal(0, wheelScore("numpy-1.23.5-cp39-cp39-win_amd64.whl", "cp39"))
+	must_be.Equal(13, wheelScore("cryptography-38.0.4-cp36-abi3-manylinux_2_28_x86_64.whl", "cp39"))
+
+	release := &pypiRelease{Urls: []*pypiFile{
+		&pypiFile{Filename: "numpy-1.23.5.tar.gz", Packagetype: "sdist"},
+		&pypiFile{Filename: "numpy-1.23.5-cp39-cp39-win_amd64.whl", Packagetype: "bdist_wheel"},
+		&pypiFile{Filename: "numpy-1.23.5-cp39-cp39-manylinux_2_17_x86_64.manylinux2014_x86_64.whl", Packagetype: "bdist_wheel"},
+	}}
+	must_be.Equal("numpy-1.23.5-cp39-cp39-manylinux_2_17_x86_64.manylinux2014_x86_64.whl", bestWheel(release, "cp39").Filename)
+}
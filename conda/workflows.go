@@ -16,11 +16,41 @@ import (
 	"github.com/robocorp/rcc/common"
 	"github.com/robocorp/rcc/pathlib"
 	"github.com/robocorp/rcc/pretty"
-	"github.com/robocorp/rcc/settings"
 	"github.com/robocorp/rcc/shell"
 	"github.com/robocorp/rcc/xviper"
 )
 
+const (
+	failedCorrupted   = `corrupted-cache`
+	failedMicromamba  = `micromamba`
+	failedNative      = `native`
+	failedOther       = `other`
+	failedPip         = `pip`
+	failedPipCheck    = `pip-check`
+	failedPolicy      = `policy`
+	failedPostInstall = `post-install`
+	failedValidation  = `validation`
+	failedSetup       = `setup`
+)
+
+var (
+	failureCodes = map[string]*common.ErrorCode{
+		failedCorrupted:                         common.CodeEnvCorrupted,
+		failedMicromamba:                        common.CodeEnvResolver,
+		failedMicromamba + "-" + FailureNetwork: common.CodeEnvNetwork,
+		failedMicromamba + "-" + FailureSolver:  common.CodeEnvSolve,
+		failedMicromamba + "-" + FailureDisk:    common.CodeEnvDisk,
+		failedMicromamba + "-" + FailureTimeout: common.CodeEnvTimeout,
+		failedNative:                            common.CodeEnvNative,
+		failedPip:                               common.CodeEnvPip,
+		failedPipCheck:                          common.CodeEnvPipCheck,
+		failedPolicy:                            common.CodeEnvPolicy,
+		failedPostInstall:                       common.CodeEnvPostInstall,
+		failedValidation:                        common.CodeEnvValidation,
+		failedSetup:                             common.CodeEnvSetup,
+	}
+)
+
 func metafile(folder string) string {
 	return common.ExpandPath(folder + ".meta")
 }
@@ -48,7 +78,7 @@ func LiveCapture(liveFolder string, command ...string) (string, int, error) {
 
 func LiveExecution(sink *os.File, liveFolder string, command ...string) (int, error) {
 	defer sink.Sync()
-	fmt.Fprintf(sink, "Command %q at %q:\n", command, liveFolder)
+	fmt.Fprintf(sink, "Command %q at %q:\n", redactedCommand(command), liveFolder)
 	task, err := livePrepare(liveFolder, command...)
 	if err != nil {
 		return 0, err
@@ -76,7 +106,7 @@ func (it InstallObserver) HasFailures(targetFolder string) bool {
 	if it["safetyerror"] && it["corrupted"] && len(it) > 2 {
 		cloud.BackgroundMetric(common.ControllerIdentity(), "rcc.env.creation.failure", common.Version)
 		renameRemove(targetFolder)
-		location := filepath.Join(common.RobocorpHome(), "pkgs")
+		location := PackagesCache()
 		common.Log("%sWARNING! Conda environment is unstable, see above error.%s", pretty.Red, pretty.Reset)
 		common.Log("%sWARNING! To fix it, try to remove directory: %v%s", pretty.Red, location, pretty.Reset)
 		return true
@@ -84,20 +114,22 @@ func (it InstallObserver) HasFailures(targetFolder string) bool {
 	return false
 }
 
-func newLive(yaml, condaYaml, requirementsText, key string, force, freshInstall bool, postInstall []string) (bool, error) {
-	if !MustMicromamba() {
-		return false, fmt.Errorf("Could not get micromamba installed.")
+func newLive(solver string, layers Layers, yaml, condaYaml, requirementsText, key string, force, freshInstall bool, postInstall, validations []string, hooks *ActivationHooks) (bool, string, error) {
+	resolver, err := MustResolver(solver)
+	if err != nil {
+		return false, failedMicromamba, err
 	}
 	targetFolder := common.StageFolder
 	common.Debug("===  pre cleanup phase ===")
 	common.Timeline("pre cleanup phase.")
-	err := renameRemove(targetFolder)
+	err = renameRemove(targetFolder)
 	if err != nil {
-		return false, err
+		return false, failedSetup, err
 	}
+	layer := newCondaLayer(layers, condaYaml, requirementsText)
 	common.Debug("===  first try phase ===")
 	common.Timeline("first try.")
-	success, fatal := newLiveInternal(yaml, condaYaml, requirementsText, key, force, freshInstall, postInstall)
+	success, fatal, reason := newLiveInternal(resolver, layer, yaml, condaYaml, requirementsText, key, force, freshInstall, postInstall, validations, hooks)
 	if !success && !force && !fatal {
 		cloud.BackgroundMetric(common.ControllerIdentity(), "rcc.env.creation.retry", common.Version)
 		common.Debug("===  second try phase ===")
@@ -106,19 +138,19 @@ func newLive(yaml, condaYaml, requirementsText, key string, force, freshInstall
 		common.Log("Retry! First try failed ... now retrying with debug and force options!")
 		err = renameRemove(targetFolder)
 		if err != nil {
-			return false, err
+			return false, failedSetup, err
 		}
-		success, _ = newLiveInternal(yaml, condaYaml, requirementsText, key, true, freshInstall, postInstall)
+		success, _, reason = newLiveInternal(resolver, layer, yaml, condaYaml, requirementsText, key, true, freshInstall, postInstall, validations, hooks)
 	}
-	return success, nil
+	return success, reason, nil
 }
 
-func newLiveInternal(yaml, condaYaml, requirementsText, key string, force, freshInstall bool, postInstall []string) (bool, bool) {
+func newLiveInternal(resolver Solver, layer *condaLayer, yaml, condaYaml, requirementsText, key string, force, freshInstall bool, postInstall, validations []string, hooks *ActivationHooks) (bool, bool, string) {
 	targetFolder := common.StageFolder
 	planfile := fmt.Sprintf("%s.plan", targetFolder)
 	planWriter, err := os.OpenFile(planfile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
 	if err != nil {
-		return false, false
+		return false, false, failedSetup
 	}
 	defer func() {
 		planWriter.Close()
@@ -134,28 +166,45 @@ func newLiveInternal(yaml, condaYaml, requirementsText, key string, force, fresh
 	fmt.Fprintf(planWriter, "%s\n", yaml)
 
 	common.Debug("Setting up new conda environment using %v to folder %v", condaYaml, targetFolder)
-	ttl := "57600"
-	if force {
-		ttl = "0"
-	}
-	common.Progress(5, "Running micromamba phase.")
-	mambaCommand := common.NewCommander(BinMicromamba(), "create", "--always-copy", "--no-rc", "--safety-checks", "enabled", "--extra-safety-checks", "--retry-clean-cache", "--strict-channel-priority", "--repodata-ttl", ttl, "-y", "-f", condaYaml, "-p", targetFolder)
-	mambaCommand.Option("--channel-alias", settings.Global.CondaURL())
-	mambaCommand.ConditionalFlag(common.VerboseEnvironmentBuilding(), "--verbose")
-	observer := make(InstallObserver)
-	common.Debug("===  micromamba create phase ===")
-	fmt.Fprintf(planWriter, "\n---  micromamba plan @%ss  ---\n\n", stopwatch)
-	tee := io.MultiWriter(observer, planWriter)
-	code, err := shell.New(CondaEnvironment(), ".", mambaCommand.CLI()...).Tracked(tee, false)
-	if err != nil || code != 0 {
-		cloud.BackgroundMetric(common.ControllerIdentity(), "rcc.env.fatal.micromamba", fmt.Sprintf("%d_%x", code, code))
-		common.Timeline("micromamba fail.")
-		common.Fatal(fmt.Sprintf("Micromamba [%d/%x]", code, code), err)
-		return false, false
-	}
-	common.Timeline("micromamba done.")
-	if observer.HasFailures(targetFolder) {
-		return false, true
+	common.Debug("===  %s create phase ===", resolver.Name())
+	fmt.Fprintf(planWriter, "\n---  %s plan @%ss  ---\n\n", resolver.Name(), stopwatch)
+	prefetch := PrefetchWheels(condaYaml, requirementsText)
+	var code int
+	if layer.restore(force) {
+		common.Progress(5, "Conda layer restored from hololib, skipping %s phase.", resolver.Name())
+		fmt.Fprintf(planWriter, "Conda layer restored from hololib, %s was not run.\n", resolver.Name())
+		common.Timeline("conda layer restored.")
+		prefetch.Wait()
+	} else {
+		common.Progress(5, "Running %s phase.", resolver.Name())
+		observer := make(InstallObserver)
+		offline := NewOfflineObserver()
+		if !policyAllowsPlan(planWriter, resolver, condaYaml, force) {
+			common.Timeline("package policy fail.")
+			return false, true, failedPolicy
+		}
+		phases := newResolverPhases(resolver.Name())
+		tee := io.MultiWriter(observer, offline, phases, planWriter)
+		var summary *ResolverSummary
+		resolving := common.Stopwatch("resolver")
+		code, summary, err = runResolver(resolver, tee, condaYaml, targetFolder, force)
+		phases.Done()
+		if err != nil || code != 0 {
+			cloud.BackgroundMetric(common.ControllerIdentity(), "rcc.env.fatal.micromamba", fmt.Sprintf("%d_%x", code, code))
+			common.Timeline("micromamba fail.")
+			common.Fatal(fmt.Sprintf("Micromamba [%d/%x]", code, code), err)
+			return false, offline.Report(resolver.Name()), resolverFailure(summary.Class)
+		}
+		ObserveResolver(time.Duration(resolving.Elapsed()), summary.Fetched, summary.Linked)
+		common.Timeline("micromamba done.")
+		if fetched := prefetch.Wait(); fetched > 0 {
+			common.Debug("Prefetched %d pip wheels while %s was running.", fetched, resolver.Name())
+			common.Timeline("prefetched %d pip wheels.", fetched)
+		}
+		if observer.HasFailures(targetFolder) {
+			return false, true, failedCorrupted
+		}
+		layer.record()
 	}
 	fmt.Fprintf(planWriter, "\n---  pip plan @%ss  ---\n\n", stopwatch)
 	pipUsed, pipCache, wheelCache := false, common.PipCache(), common.WheelCache()
@@ -166,18 +215,33 @@ func newLiveInternal(yaml, condaYaml, requirementsText, key string, force, fresh
 		common.Progress(6, "Running pip install phase.")
 		common.Debug("Updating new environment at %v with pip requirements from %v (size: %v)", targetFolder, requirementsText, size)
 		pipCommand := common.NewCommander("pip", "install", "--isolated", "--no-color", "--disable-pip-version-check", "--prefer-binary", "--cache-dir", pipCache, "--find-links", wheelCache, "--requirement", requirementsText)
-		pipCommand.Option("--index-url", settings.Global.PypiURL())
-		pipCommand.Option("--trusted-host", settings.Global.PypiTrustedHost())
+		if !common.OfflineFlag {
+			err = withPipIndexes(pipCommand)
+			if err != nil {
+				common.Fatal("pip index", err)
+				common.Log("%sPip index failure: %v%s", pretty.Red, err, pretty.Reset)
+				return false, false, failedPip
+			}
+		}
+		pipCommand.ConditionalFlag(common.OfflineFlag, "--no-index")
+		pipCommand.ConditionalFlag(pipRequireHashes(), "--require-hashes")
 		pipCommand.ConditionalFlag(common.VerboseEnvironmentBuilding(), "--verbose")
 		common.Debug("===  pip install phase ===")
+		phase := pretty.StartPhase(pretty.PhasePip, "Installing pip dependencies.")
 		code, err = LiveExecution(planWriter, targetFolder, pipCommand.CLI()...)
+		elapsed := phase.Done()
 		if err != nil || code != 0 {
 			cloud.BackgroundMetric(common.ControllerIdentity(), "rcc.env.fatal.pip", fmt.Sprintf("%d_%x", code, code))
 			common.Timeline("pip fail.")
 			common.Fatal(fmt.Sprintf("Pip [%d/%x]", code, code), err)
-			return false, false
+			offline := NewOfflineObserver()
+			if content, err := ioutil.ReadFile(planfile); err == nil {
+				offline.Write(content)
+			}
+			return false, offline.Report("pip"), failedPip
 		}
 		common.Timeline("pip done.")
+		ObservePip(elapsed, len(pipRequirements(requirementsText)))
 		pipUsed = true
 	}
 	fmt.Fprintf(planWriter, "\n---  post install plan @%ss  ---\n\n", stopwatch)
@@ -189,14 +253,14 @@ func newLiveInternal(yaml, condaYaml, requirementsText, key string, force, fresh
 			if err != nil {
 				common.Fatal("post-install", err)
 				common.Log("%sScript '%s' parsing failure: %v%s", pretty.Red, script, err, pretty.Reset)
-				return false, false
+				return false, false, failedPostInstall
 			}
 			common.Debug("Running post install script '%s' ...", script)
 			_, err = LiveExecution(planWriter, targetFolder, scriptCommand...)
 			if err != nil {
 				common.Fatal("post-install", err)
 				common.Log("%sScript '%s' failure: %v%s", pretty.Red, script, err, pretty.Reset)
-				return false, false
+				return false, false, failedPostInstall
 			}
 		}
 	} else {
@@ -205,17 +269,26 @@ func newLiveInternal(yaml, condaYaml, requirementsText, key string, force, fresh
 	common.Progress(8, "Activate environment started phase.")
 	common.Debug("===  activate phase ===")
 	fmt.Fprintf(planWriter, "\n---  activation plan @%ss  ---\n\n", stopwatch)
-	err = Activate(planWriter, targetFolder)
+	err = Activate(planWriter, targetFolder, hooks)
 	if err != nil {
 		common.Log("%sActivation failure: %v%s", pretty.Yellow, err, pretty.Reset)
 	}
 	for _, line := range LoadActivationEnvironment(targetFolder) {
 		fmt.Fprintf(planWriter, "%s\n", line)
 	}
-	err = goldenMaster(targetFolder, pipUsed)
+	err = goldenMaster(resolver, targetFolder, pipUsed)
 	if err != nil {
 		common.Log("%sGolden EE failure: %v%s", pretty.Yellow, err, pretty.Reset)
 	}
+	fmt.Fprintf(planWriter, "\n---  package policy plan @%ss  ---\n\n", stopwatch)
+	if !policyAllowsEnvironment(planWriter, targetFolder) {
+		common.Timeline("package policy fail.")
+		return false, true, failedPolicy
+	}
+	err = freezeLockfile(targetFolder, pipUsed)
+	if err != nil {
+		common.Log("%sLockfile failure: %v%s", pretty.Yellow, err, pretty.Reset)
+	}
 	fmt.Fprintf(planWriter, "\n---  pip check plan @%ss  ---\n\n", stopwatch)
 	if common.StrictFlag && pipUsed {
 		common.Progress(9, "Running pip check phase.")
@@ -227,12 +300,38 @@ func newLiveInternal(yaml, condaYaml, requirementsText, key string, force, fresh
 			cloud.BackgroundMetric(common.ControllerIdentity(), "rcc.env.fatal.pipcheck", fmt.Sprintf("%d_%x", code, code))
 			common.Timeline("pip check fail.")
 			common.Fatal(fmt.Sprintf("Pip check [%d/%x]", code, code), err)
-			return false, false
+			return false, false, failedPipCheck
 		}
 		common.Timeline("pip check done.")
 	} else {
 		common.Progress(9, "Pip check skipped.")
 	}
+	fmt.Fprintf(planWriter, "\n---  validation plan @%ss  ---\n\n", stopwatch)
+	if len(validations) > 0 {
+		common.Debug("===  validation phase ===")
+		for _, validation := range validations {
+			validationCommand, err := shlex.Split(validation)
+			if err != nil {
+				common.Fatal("validation", err)
+				common.Log("%sValidation '%s' parsing failure: %v%s", pretty.Red, validation, err, pretty.Reset)
+				return false, false, failedValidation
+			}
+			common.Debug("Running validation '%s' ...", validation)
+			code, err = LiveExecution(planWriter, targetFolder, validationCommand...)
+			if err != nil || code != 0 {
+				cloud.BackgroundMetric(common.ControllerIdentity(), "rcc.env.fatal.validation", fmt.Sprintf("%d_%x", code, code))
+				common.Timeline("validation fail.")
+				common.Fatal(fmt.Sprintf("Validation [%d/%x]", code, code), err)
+				common.Log("%sValidation '%s' failed, environment is not usable.%s", pretty.Red, validation, pretty.Reset)
+				return false, false, failedValidation
+			}
+		}
+		common.Timeline("validation done.")
+	}
+	fmt.Fprintf(planWriter, "\n---  native probe plan @%ss  ---\n\n", stopwatch)
+	if !nativeProbeAllows(planWriter, targetFolder) {
+		return false, false, failedNative
+	}
 	fmt.Fprintf(planWriter, "\n---  installation plan complete @%ss  ---\n\n", stopwatch)
 	planWriter.Sync()
 	planWriter.Close()
@@ -244,10 +343,10 @@ func newLiveInternal(yaml, condaYaml, requirementsText, key string, force, fresh
 	markerFile := filepath.Join(targetFolder, "identity.yaml")
 	err = ioutil.WriteFile(markerFile, []byte(yaml), 0o644)
 	if err != nil {
-		return false, false
+		return false, false, failedSetup
 	}
 
-	return true, false
+	return true, false, ""
 }
 
 func temporaryConfig(condaYaml, requirementsText string, save bool, filenames ...string) (string, string, *Environment, error) {
@@ -282,11 +381,15 @@ func temporaryConfig(condaYaml, requirementsText string, save bool, filenames ..
 		return "", "", nil, err
 	}
 	pure := right.AsPureConda()
+	if ConfiguredChannels() {
+		common.Log("Channels from settings replace channels of conda.yaml: %s", strings.Join(ChannelNames(), ", "))
+		pure.Channels = []string{}
+	}
 	err = pure.SaveAs(condaYaml)
 	return hash, yaml, right, err
 }
 
-func LegacyEnvironment(force bool, configurations ...string) error {
+func LegacyEnvironment(force bool, solver string, layers Layers, configurations ...string) error {
 	cloud.BackgroundMetric(common.ControllerIdentity(), "rcc.env.create.start", common.Version)
 
 	lockfile := common.RobocorpLock()
@@ -312,10 +415,24 @@ func LegacyEnvironment(force bool, configurations ...string) error {
 		xviper.Set("stats.env.merges", merges)
 	}
 
-	condaYaml := filepath.Join(os.TempDir(), fmt.Sprintf("conda_%x.yaml", common.When))
-	requirementsText := filepath.Join(os.TempDir(), fmt.Sprintf("require_%x.txt", common.When))
+	condaYaml := filepath.Join(os.TempDir(), fmt.Sprintf("conda_%x_%d.yaml", common.When, os.Getpid()))
+	requirementsText := filepath.Join(os.TempDir(), fmt.Sprintf("require_%x_%d.txt", common.When, os.Getpid()))
 	common.Debug("Using temporary conda.yaml file: %v and requirement.txt file: %v", condaYaml, requirementsText)
-	key, yaml, finalEnv, err := temporaryConfig(condaYaml, requirementsText, true, configurations...)
+	var key, yaml string
+	var postInstall, validations []string
+	var hooks *ActivationHooks
+	if len(configurations) == 1 && IsLockfile(configurations[0]) {
+		condaYaml = filepath.Join(os.TempDir(), fmt.Sprintf("explicit_%x_%d.txt", common.When, os.Getpid()))
+		key, yaml, err = lockfileConfig(condaYaml, requirementsText, configurations[0])
+	} else {
+		var finalEnv *Environment
+		key, yaml, finalEnv, err = temporaryConfig(condaYaml, requirementsText, true, configurations...)
+		if err == nil {
+			postInstall = finalEnv.PostInstall
+			validations = finalEnv.Validate
+			hooks = &ActivationHooks{Pre: finalEnv.PreActivate, Post: finalEnv.PostActivate}
+		}
+	}
 	if err != nil {
 		failures += 1
 		xviper.Set("stats.env.failures", failures)
@@ -324,9 +441,9 @@ func LegacyEnvironment(force bool, configurations ...string) error {
 	defer os.Remove(condaYaml)
 	defer os.Remove(requirementsText)
 
-	success, err := newLive(yaml, condaYaml, requirementsText, key, force, freshInstall, finalEnv.PostInstall)
+	success, reason, err := newLive(solver, layers, yaml, condaYaml, requirementsText, key, force, freshInstall, postInstall, validations, hooks)
 	if err != nil {
-		return err
+		return &BuildFailure{reason, err}
 	}
 	if success {
 		misses += 1
@@ -336,7 +453,46 @@ func LegacyEnvironment(force bool, configurations ...string) error {
 
 	failures += 1
 	xviper.Set("stats.env.failures", failures)
-	return errors.New("Could not create environment.")
+	return &BuildFailure{reason, errors.New("Could not create environment.")}
+}
+
+type BuildFailure struct {
+	Reason string
+	Cause  error
+}
+
+func (it *BuildFailure) Error() string {
+	code := it.ErrorCode()
+	if code == nil {
+		return it.Cause.Error()
+	}
+	return fmt.Sprintf("[%s] %v", code.Code, it.Cause)
+}
+
+// ErrorCode is documented error code of build failure reason, or nil for
+// unclassified failures.
+func (it *BuildFailure) ErrorCode() *common.ErrorCode {
+	return failureCodes[it.Reason]
+}
+
+func (it *BuildFailure) Unwrap() error {
+	return it.Cause
+}
+
+// resolverFailure is build failure reason with resolver failure class.
+func resolverFailure(class string) string {
+	if len(class) == 0 || class == FailureUnknown {
+		return failedMicromamba
+	}
+	return failedMicromamba + "-" + class
+}
+
+func FailureReason(err error) string {
+	var failure *BuildFailure
+	if errors.As(err, &failure) && len(failure.Reason) > 0 {
+		return failure.Reason
+	}
+	return failedOther
 }
 
 func renameRemove(location string) error {
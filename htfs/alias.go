@@ -0,0 +1,136 @@
+package htfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/journal"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+const (
+	slotBlue  = `blue`
+	slotGreen = `green`
+)
+
+// SpaceAlias maps space name, that long running agents use, into one of
+// two physical "slot" spaces. Next version is built into inactive slot,
+// and then alias is switched atomically (with possibility to roll back).
+type SpaceAlias struct {
+	Controller string `json:"controller"`
+	Space      string `json:"space"`
+	Active     string `json:"active"`
+	Previous   string `json:"previous"`
+	Staged     string `json:"staged"`
+	Switched   string `json:"switched"`
+}
+
+func aliasFilename(space string) string {
+	name := ControllerSpaceName([]byte(common.ControllerIdentity()), []byte(space))
+	return filepath.Join(common.HolotreeLocation(), fmt.Sprintf("%s.alias", name))
+}
+
+func LoadSpaceAlias(space string) (*SpaceAlias, bool) {
+	content, err := ioutil.ReadFile(aliasFilename(space))
+	if err != nil {
+		return &SpaceAlias{Controller: common.ControllerIdentity(), Space: space}, false
+	}
+	alias := &SpaceAlias{}
+	err = json.Unmarshal(content, alias)
+	if err != nil {
+		common.Debug("Ignoring broken space alias for %q, reason: %v", space, err)
+		return &SpaceAlias{Controller: common.ControllerIdentity(), Space: space}, false
+	}
+	return alias, true
+}
+
+// ActiveSpace resolves space name through alias, if there is one.
+func ActiveSpace(space string) string {
+	alias, ok := LoadSpaceAlias(space)
+	if !ok || len(alias.Active) == 0 {
+		return space
+	}
+	common.Debug("Space %q is alias for active space %q.", space, alias.Active)
+	return alias.Active
+}
+
+// RestoreTarget gives space where environment with given blueprint key is
+// restored. When space is alias, and its active slot has other environment,
+// that slot is in use and must not change, so inactive slot is given with
+// alias to switch after restore. Otherwise alias is nil.
+func RestoreTarget(space, key string) (string, *SpaceAlias) {
+	alias, ok := LoadSpaceAlias(space)
+	if !ok || len(alias.Active) == 0 {
+		return space, nil
+	}
+	if slotBlueprint(alias.Active) == key {
+		return alias.Active, nil
+	}
+	return alias.Inactive(), alias
+}
+
+func slotBlueprint(slot string) string {
+	name := ControllerSpaceName([]byte(common.ControllerIdentity()), []byte(slot))
+	metafile := filepath.Join(common.HolotreeLocation(), fmt.Sprintf("%s.meta", name))
+	shadow, err := NewRoot(filepath.Join(common.HolotreeLocation(), name))
+	if err != nil || shadow.LoadFrom(metafile) != nil {
+		return ""
+	}
+	return shadow.Blueprint
+}
+
+func (it *SpaceAlias) save() (err error) {
+	defer fail.Around(&err)
+
+	content, err := json.MarshalIndent(it, "", "  ")
+	fail.On(err != nil, "Could not serialize alias -> %v", err)
+	filename := aliasFilename(it.Space)
+	_, err = pathlib.EnsureParentDirectory(filename)
+	fail.On(err != nil, "Could not create directory for alias -> %v", err)
+	partname := fmt.Sprintf("%s.part%s", filename, <-common.Identities)
+	defer os.Remove(partname)
+	err = ioutil.WriteFile(partname, content, 0o644)
+	fail.On(err != nil, "Could not write alias -> %v", err)
+	return TryRename("alias", partname, filename)
+}
+
+// Inactive returns slot space name, which is not currently active.
+func (it *SpaceAlias) Inactive() string {
+	blue := fmt.Sprintf("%s.%s", it.Space, slotBlue)
+	if it.Active == blue {
+		return fmt.Sprintf("%s.%s", it.Space, slotGreen)
+	}
+	return blue
+}
+
+func (it *SpaceAlias) Staging(slot string) error {
+	it.Staged = slot
+	journal.Post("space-alias", it.Space, "slot %q staged, active is %q", slot, it.Active)
+	return it.save()
+}
+
+func (it *SpaceAlias) Switch() error {
+	if len(it.Staged) == 0 {
+		return fmt.Errorf("Nothing staged for space %q, prepare it first.", it.Space)
+	}
+	it.Previous, it.Active, it.Staged = it.Active, it.Staged, ""
+	it.Switched = time.Now().Format(time.RFC3339)
+	journal.Post("space-alias", it.Space, "switched from %q to %q", it.Previous, it.Active)
+	return it.save()
+}
+
+func (it *SpaceAlias) Rollback() error {
+	if len(it.Previous) == 0 {
+		return fmt.Errorf("No previous slot for space %q to roll back into.", it.Space)
+	}
+	it.Previous, it.Active = it.Active, it.Previous
+	it.Switched = time.Now().Format(time.RFC3339)
+	journal.Post("space-alias", it.Space, "rolled back from %q to %q", it.Previous, it.Active)
+	return it.save()
+}
@@ -0,0 +1,77 @@
+package htfs_test
+
+import (
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestCanSwitchAndRollbackSpaceAlias(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, t.TempDir())
+	common.ControllerType = "unittest"
+	space := "bluegreen-unittest"
+
+	sut, _ := htfs.LoadSpaceAlias(space)
+	sut.Active, sut.Previous = "", ""
+	must.Nil(sut.Staging(""))
+	must.Equal(space, htfs.ActiveSpace(space))
+	wont.Nil(sut.Switch())
+	wont.Nil(sut.Rollback())
+
+	first := sut.Inactive()
+	must.Equal(space+".blue", first)
+	must.Nil(sut.Staging(first))
+	must.Nil(sut.Switch())
+	must.Equal(first, htfs.ActiveSpace(space))
+
+	second := sut.Inactive()
+	must.Equal(space+".green", second)
+	must.Nil(sut.Staging(second))
+	must.Equal(first, htfs.ActiveSpace(space))
+	must.Nil(sut.Switch())
+	must.Equal(second, htfs.ActiveSpace(space))
+
+	must.Nil(sut.Rollback())
+	must.Equal(first, htfs.ActiveSpace(space))
+	loaded, ok := htfs.LoadSpaceAlias(space)
+	must.True(ok)
+	must.Equal(second, loaded.Previous)
+}
+
+func TestRestoresIntoInactiveSlotWhenActiveHasOtherEnvironment(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	common.ControllerType = "unittest"
+	space := "slots-unittest"
+	first, second := []byte("slots: first"), []byte("slots: second")
+
+	library := testLibrary(t, nil)
+	for _, blueprint := range [][]byte{first, second} {
+		testStage(t, library, map[string]string{"version.txt": string(blueprint)})
+		must.Nil(library.Record(blueprint))
+	}
+
+	target, alias := htfs.RestoreTarget(space, htfs.BlueprintHash(first))
+	must.Equal(space, target)
+	must.Nil(alias)
+
+	sut, _ := htfs.LoadSpaceAlias(space)
+	blue := sut.Inactive()
+	must.Nil(sut.Staging(blue))
+	must.Nil(sut.Switch())
+	_, err := library.Restore(first, []byte(common.ControllerIdentity()), []byte(blue))
+	must.Nil(err)
+
+	target, alias = htfs.RestoreTarget(space, htfs.BlueprintHash(first))
+	must.Equal(blue, target)
+	must.Nil(alias)
+
+	target, alias = htfs.RestoreTarget(space, htfs.BlueprintHash(second))
+	must.Equal(space+".green", target)
+	wont.Nil(alias)
+	must.Equal(blue, alias.Active)
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/robot"
+)
+
+// Functions here are stable way to use holotree from other Go programs,
+// without running rcc binary and parsing its output. Holotree location comes
+// from ROBOCORP_HOME (and settings), as with rcc itself. Calls are
+// serialized, since holotree operations use process wide state.
+
+type (
+	ImportReport    = htfs.ImportReport
+	IntegrityReport = htfs.IntegrityReport
+	ProgressReport  = htfs.ProgressReport
+	RestorePlan     = htfs.RestorePlan
+)
+
+// Space is one restored holotree space.
+type Space struct {
+	Identity   string `json:"id"`
+	Controller string `json:"controller"`
+	Space      string `json:"space"`
+	Blueprint  string `json:"blueprint"`
+	Path       string `json:"path"`
+}
+
+// Options tell who restores space (controller, like "orchestrator") and as
+// which space name, and if environment must be rebuilt even if it exists.
+type Options struct {
+	Controller string
+	Space      string
+	Force      bool
+}
+
+var (
+	serialize sync.Mutex
+)
+
+// SetProgressCallback makes long lift and restore operations report their
+// progress periodically to callback; nil turns reporting off.
+func SetProgressCallback(callback func(*ProgressReport)) {
+	htfs.SetProgressCallback(callback)
+}
+
+func withOptions(options Options, task func() error) error {
+	serialize.Lock()
+	defer serialize.Unlock()
+
+	controller, space := common.ControllerType, common.HolotreeSpace
+	defer func() {
+		common.ControllerType, common.HolotreeSpace = controller, space
+	}()
+	if len(options.Controller) > 0 {
+		common.ControllerType = options.Controller
+	}
+	common.HolotreeSpace = options.Space
+	if len(common.HolotreeSpace) == 0 {
+		common.HolotreeSpace = "user"
+	}
+	return task()
+}
+
+func spaceOf(root *htfs.Root) *Space {
+	return &Space{
+		Identity:   root.Identity,
+		Controller: root.Controller,
+		Space:      root.Space,
+		Blueprint:  root.Blueprint,
+		Path:       root.Path,
+	}
+}
+
+// Blueprint gives holotree blueprint hash (catalog key) for environment
+// configuration file (conda.yaml).
+func Blueprint(condafile string) (string, error) {
+	_, blueprint, err := htfs.ComposeFinalBlueprint([]string{condafile}, "")
+	if err != nil {
+		return "", err
+	}
+	return htfs.BlueprintHash(blueprint), nil
+}
+
+// RecordEnvironment builds environment from configuration file into
+// hololib (unless it is already there, or force is given), without
+// restoring any space, and returns its blueprint hash.
+func RecordEnvironment(condafile string, force bool) (blueprint string, err error) {
+	err = withOptions(Options{Force: force}, func() error {
+		_, _, err := htfs.NewEnvironment(condafile, "", false, force, &robot.Settings{})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return Blueprint(condafile)
+}
+
+// RestoreSpace builds environment (when needed) and restores it as space of
+// given controller and space name.
+func RestoreSpace(condafile string, options Options) (space *Space, err error) {
+	path := ""
+	err = withOptions(options, func() error {
+		path, _, err = htfs.NewEnvironment(condafile, "", true, options.Force, &robot.Settings{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, candidate := range ListSpaces() {
+		if candidate.Path == path {
+			return candidate, nil
+		}
+	}
+	return nil, fmt.Errorf("Space %q was restored, but has no metadata.", path)
+}
+
+// PlanSpace tells what RestoreSpace would add, replace, and remove in space
+// with same options, without changing anything. Environment must already be
+// in hololib.
+func PlanSpace(condafile string, options Options) (plan *RestorePlan, err error) {
+	err = withOptions(options, func() error {
+		plan, err = htfs.PlanEnvironment(condafile)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// ListSpaces gives all holotree spaces, ordered by path.
+func ListSpaces() []*Space {
+	result := []*Space{}
+	for _, root := range htfs.Spaces() {
+		result = append(result, spaceOf(root))
+	}
+	sort.Slice(result, func(left, right int) bool {
+		return result[left].Path < result[right].Path
+	})
+	return result
+}
+
+// RemoveSpace removes space by its identity (see Space.Identity).
+func RemoveSpace(identity string) error {
+	serialize.Lock()
+	defer serialize.Unlock()
+	return htfs.RemoveHolotreeSpace(identity)
+}
+
+// ListCatalogs gives names of catalogs in hololib.
+func ListCatalogs() []string {
+	return htfs.Catalogs()
+}
+
+// ExportCatalog writes given catalogs (by name) and their blobs into zip
+// archive, which ImportCatalog (or "rcc holotree import") can read.
+func ExportCatalog(catalogs []string, archive string) error {
+	serialize.Lock()
+	defer serialize.Unlock()
+
+	known := make(map[string]bool)
+	for _, catalog := range htfs.Catalogs() {
+		known[catalog] = true
+	}
+	for _, catalog := range catalogs {
+		if !known[catalog] {
+			return fmt.Errorf("No catalog %q in hololib.", catalog)
+		}
+	}
+	library, err := htfs.New()
+	if err != nil {
+		return err
+	}
+	return library.Export(catalogs, []string{}, archive)
+}
+
+// ImportCatalog imports catalogs of local platform (and their blobs) from
+// exported archive into hololib.
+func ImportCatalog(archive string) (*ImportReport, error) {
+	serialize.Lock()
+	defer serialize.Unlock()
+
+	if !pathlib.IsFile(archive) {
+		return nil, fmt.Errorf("Archive %q does not exist.", archive)
+	}
+	return htfs.ImportBundle(archive)
+}
+
+// CheckIntegrity verifies hololib blobs without changing anything.
+func CheckIntegrity() (*IntegrityReport, error) {
+	serialize.Lock()
+	defer serialize.Unlock()
+	return htfs.InspectIntegrity()
+}
+
+// HololibLocation is where catalogs and blobs are.
+func HololibLocation() string {
+	return filepath.Clean(common.HololibLocation())
+}
@@ -0,0 +1,61 @@
+package api_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/htfs/api"
+)
+
+func TestCanExportAndImportCatalogsThroughApi(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	folder, err := ioutil.TempDir("", "api")
+	must.Nil(err)
+	defer os.RemoveAll(folder)
+	original := os.Getenv(common.ROBOCORP_HOME_VARIABLE)
+	defer os.Setenv(common.ROBOCORP_HOME_VARIABLE, original)
+	os.Setenv(common.ROBOCORP_HOME_VARIABLE, filepath.Join(folder, "builder"))
+
+	common.ControllerType = "unittest"
+	blueprint := []byte("api: unittest")
+	library, err := htfs.New()
+	must.Nil(err)
+	must.Nil(htfs.CleanupHolotreeStage(library))
+	must.Nil(os.MkdirAll(library.Stage(), 0o755))
+	must.Nil(ioutil.WriteFile(filepath.Join(library.Stage(), "api.txt"), []byte("api content"), 0o644))
+	must.Nil(library.Record(blueprint))
+	_, err = library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("api"))
+	must.Nil(err)
+
+	catalogs := api.ListCatalogs()
+	must.Equal(1, len(catalogs))
+	spaces := api.ListSpaces()
+	must.Equal(1, len(spaces))
+	must.Equal("api", spaces[0].Space)
+	must.Equal(htfs.BlueprintHash(blueprint), spaces[0].Blueprint)
+
+	report, err := api.CheckIntegrity()
+	must.Nil(err)
+	must.Equal(0, len(report.Damaged))
+
+	archive := filepath.Join(folder, "api.zip")
+	wont.Nil(api.ExportCatalog([]string{"missing.linux_amd64"}, archive))
+	must.Nil(api.ExportCatalog(catalogs, archive))
+	must.Nil(api.RemoveSpace(spaces[0].Identity))
+	must.Equal(0, len(api.ListSpaces()))
+
+	os.Setenv(common.ROBOCORP_HOME_VARIABLE, filepath.Join(folder, "user"))
+	_, err = api.ImportCatalog(filepath.Join(folder, "missing.zip"))
+	wont.Nil(err)
+	imported, err := api.ImportCatalog(archive)
+	must.Nil(err)
+	must.Equal(catalogs, imported.Imported)
+	must.Equal(catalogs, api.ListCatalogs())
+	must.Equal(filepath.Join(folder, "user", "hololib"), api.HololibLocation())
+}
@@ -0,0 +1,22 @@
+package htfs
+
+import (
+	"io/fs"
+
+	"github.com/robocorp/rcc/settings"
+)
+
+// PreserveAttributes tells if platform file attributes (on Windows, hidden
+// and readonly) are captured into catalogs, and restored into spaces together
+// with inherited ACL of target directory.
+func PreserveAttributes() bool {
+	return settings.Global.PreserveAttributes()
+}
+
+func capturedAttributes(info fs.FileInfo) uint32 {
+	attributes := fileAttributes(info)
+	if attributes == 0 || !PreserveAttributes() {
+		return 0
+	}
+	return attributes
+}
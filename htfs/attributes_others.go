@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package htfs
+
+import (
+	"io/fs"
+)
+
+func fileAttributes(info fs.FileInfo) uint32 {
+	return 0
+}
+
+func restoreAttributes(fullpath string, details *File) error {
+	return nil
+}
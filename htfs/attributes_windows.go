@@ -0,0 +1,51 @@
+package htfs
+
+import (
+	"io/fs"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	preservedAttributes = windows.FILE_ATTRIBUTE_HIDDEN | windows.FILE_ATTRIBUTE_READONLY
+)
+
+func fileAttributes(info fs.FileInfo) uint32 {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return 0
+	}
+	return data.FileAttributes & preservedAttributes
+}
+
+// inheritAcl replaces explicit ACL of file with empty one, and allows
+// inheritance, so that file gets same permissions as freshly created files
+// in that directory would (and not those of place where it was created).
+func inheritAcl(fullpath string) error {
+	acl, err := windows.ACLFromEntries(nil, nil)
+	if err != nil {
+		return err
+	}
+	information := windows.SECURITY_INFORMATION(windows.DACL_SECURITY_INFORMATION | windows.UNPROTECTED_DACL_SECURITY_INFORMATION)
+	return windows.SetNamedSecurityInfo(fullpath, windows.SE_FILE_OBJECT, information, nil, nil, acl, nil)
+}
+
+func restoreAttributes(fullpath string, details *File) error {
+	err := inheritAcl(fullpath)
+	if err != nil {
+		return err
+	}
+	if details.Attributes == 0 {
+		return nil
+	}
+	name, err := windows.UTF16PtrFromString(fullpath)
+	if err != nil {
+		return err
+	}
+	current, err := windows.GetFileAttributes(name)
+	if err != nil {
+		return err
+	}
+	return windows.SetFileAttributes(name, (current&^preservedAttributes)|details.Attributes)
+}
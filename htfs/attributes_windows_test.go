@@ -0,0 +1,46 @@
+package htfs_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/settings"
+	"golang.org/x/sys/windows"
+)
+
+func TestCanPreserveWindowsAttributes(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	holotree := settings.Global.Holotree()
+	defer func(previous bool) { holotree.PreserveAttributes = previous }(holotree.PreserveAttributes)
+	holotree.PreserveAttributes = true
+
+	common.ControllerType = "unittest"
+	blueprint := []byte("attributes: unittest")
+	library := testLibrary(t, map[string]string{
+		"hidden.txt":  "hidden content",
+		"visible.txt": "visible content",
+	})
+	hidden := filepath.Join(library.Stage(), "hidden.txt")
+	name, err := windows.UTF16PtrFromString(hidden)
+	must.Nil(err)
+	must.Nil(windows.SetFileAttributes(name, windows.FILE_ATTRIBUTE_HIDDEN))
+	must.Nil(library.Record(blueprint))
+
+	space, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("attributes"))
+	must.Nil(err)
+
+	name, err = windows.UTF16PtrFromString(filepath.Join(space, "hidden.txt"))
+	must.Nil(err)
+	attributes, err := windows.GetFileAttributes(name)
+	must.Nil(err)
+	must.True(attributes&windows.FILE_ATTRIBUTE_HIDDEN != 0)
+
+	name, err = windows.UTF16PtrFromString(filepath.Join(space, "visible.txt"))
+	must.Nil(err)
+	attributes, err = windows.GetFileAttributes(name)
+	must.Nil(err)
+	wont.True(attributes&windows.FILE_ATTRIBUTE_HIDDEN != 0)
+}
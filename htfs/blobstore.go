@@ -0,0 +1,258 @@
+package htfs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/robocorp/rcc/anywork"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/settings"
+)
+
+const (
+	directoryStore = `directory`
+)
+
+// BlobStore keeps hololib blobs in their stored form (compressed, and maybe
+// encrypted), addressed by digest. Put gets whole stored content of blob,
+// and it must make blob visible atomically (all or nothing).
+type BlobStore interface {
+	Name() string
+	Has(digest string) bool
+	Open(digest string) (io.ReadCloser, error)
+	Put(digest string, stored io.Reader) error
+	Remove(digest string) error
+}
+
+// FileBlobStore is store whose blobs are plain files. Features that work on
+// blob files directly (hardlink and reflink restore modes, garbage
+// collection, integrity check, export, and shared holotree) need it.
+type FileBlobStore interface {
+	BlobStore
+	Location(digest string) string
+	ExactLocation(digest string) string
+}
+
+// BlobStoreFactory creates store, given location of system hololib (which
+// may be empty).
+type BlobStoreFactory func(system string) (BlobStore, error)
+
+var (
+	blobStoreLock sync.Mutex
+	blobStores    = map[string]BlobStoreFactory{
+		directoryStore: newDirectoryBlobStore,
+		packStore:      newPackBlobStore,
+	}
+)
+
+// RegisterBlobStore makes alternative blob store available by name, for
+// "blob-store" setting.
+func RegisterBlobStore(name string, factory BlobStoreFactory) {
+	blobStoreLock.Lock()
+	defer blobStoreLock.Unlock()
+	blobStores[strings.ToLower(name)] = factory
+}
+
+func BlobStores() []string {
+	blobStoreLock.Lock()
+	defer blobStoreLock.Unlock()
+	result := make([]string, 0, len(blobStores))
+	for name, _ := range blobStores {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// BlobStoreName is configured blob store, default being directory tree under
+// hololib/library.
+func BlobStoreName() string {
+	name := strings.ToLower(strings.TrimSpace(settings.Global.BlobStore()))
+	if len(name) == 0 {
+		return directoryStore
+	}
+	return name
+}
+
+func openBlobStore(system string) (BlobStore, error) {
+	name := BlobStoreName()
+	blobStoreLock.Lock()
+	factory, ok := blobStores[name]
+	blobStoreLock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("Unknown blob store %q, available are: %s.", name, strings.Join(BlobStores(), ", "))
+	}
+	return factory(system)
+}
+
+// hololibStore is blob store of own hololib (with system hololib below it),
+// for operations that work on blobs without opening library first.
+func hololibStore() (BlobStore, error) {
+	return openBlobStore(SystemHololib())
+}
+
+// storedSize is size of blob in its stored form, when store knows it.
+func storedSize(store BlobStore, digest string) (int64, bool) {
+	switch it := store.(type) {
+	case FileBlobStore:
+		info, err := os.Stat(it.ExactLocation(digest))
+		if err != nil {
+			return 0, false
+		}
+		return info.Size(), true
+	case *packBlobStore:
+		entry, ok := it.lookup(digest)
+		if ok {
+			return entry.size, true
+		}
+		filename, ok := it.systemBlob(digest)
+		if !ok {
+			return 0, false
+		}
+		info, err := os.Stat(filename)
+		if err != nil {
+			return 0, false
+		}
+		return info.Size(), true
+	}
+	return 0, false
+}
+
+// keepStoredBlob moves received and verified blob (partname, in its stored
+// form) into library: renamed into place with file based stores, and put
+// through blob store otherwise.
+func keepStoredBlob(library MutableLibrary, partname, digest string) (err error) {
+	defer fail.Around(&err)
+
+	locker, err := keyLock(digest)
+	fail.On(err != nil, "%v", err)
+	defer locker.Release()
+	store, ok := storeOf(library)
+	if !ok {
+		return TryRename("blob", partname, filepath.Join(library.Location(digest), digest))
+	}
+	source, err := os.Open(partname)
+	fail.On(err != nil, "%v", err)
+	defer source.Close()
+	return store.Put(digest, source)
+}
+
+// openStoredBlob gives blob in its stored form (compressed, maybe encrypted),
+// as it is copied between hololibs.
+func openStoredBlob(library MutableLibrary, digest string) (io.ReadCloser, error) {
+	local, ok := library.(*hololib)
+	if ok {
+		return local.store.Open(digest)
+	}
+	return os.Open(library.ExactLocation(digest))
+}
+
+// directoryBlobStore is hololib/library tree, with system hololib library
+// as read-only fallback below it.
+type directoryBlobStore struct {
+	system string
+}
+
+func newDirectoryBlobStore(system string) (BlobStore, error) {
+	return &directoryBlobStore{system: system}, nil
+}
+
+func (it *directoryBlobStore) Name() string {
+	return directoryStore
+}
+
+func (it *directoryBlobStore) Location(digest string) string {
+	return filepath.Join(common.HololibLibraryLocation(), digest[:2], digest[2:4], digest[4:6])
+}
+
+func (it *directoryBlobStore) ExactLocation(digest string) string {
+	return exactBlobLocation(it.system, digest)
+}
+
+func (it *directoryBlobStore) Has(digest string) bool {
+	return pathlib.IsFile(it.ExactLocation(digest))
+}
+
+func (it *directoryBlobStore) Open(digest string) (io.ReadCloser, error) {
+	return os.Open(it.ExactLocation(digest))
+}
+
+func (it *directoryBlobStore) Put(digest string, stored io.Reader) error {
+	directory := it.Location(digest)
+	err := os.MkdirAll(directory, 0o755)
+	if err != nil {
+		return err
+	}
+	filename := filepath.Join(directory, digest)
+	partname := fmt.Sprintf("%s.part%s", filename, <-common.Identities)
+	defer os.Remove(partname)
+	sink, err := os.Create(partname)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(sink, stored)
+	if err != nil {
+		sink.Close()
+		return err
+	}
+	err = sink.Close()
+	if err != nil {
+		return err
+	}
+	return TryRename("blobstore", partname, filename)
+}
+
+func (it *directoryBlobStore) Remove(digest string) error {
+	filename := filepath.Join(it.Location(digest), digest)
+	if !pathlib.IsFile(filename) {
+		return nil
+	}
+	return TryRemove("blobstore", filename)
+}
+
+// StoreBlob compresses (and maybe encrypts) source file into store, unless
+// store already has it.
+func StoreBlob(store BlobStore, sourcename, digest string) anywork.Work {
+	return func() {
+		locker, err := keyLock(digest)
+		anywork.OnErrPanicCloseAll(err)
+		defer locker.Release()
+		if store.Has(digest) {
+			common.Trace("StoreBlob %q already stored by other process.", digest)
+			return
+		}
+		source, err := os.Open(sourcename)
+		anywork.OnErrPanicCloseAll(err)
+		defer source.Close()
+		anywork.OnErrPanicCloseAll(storeStream(store, digest, throttled(source)))
+	}
+}
+
+// storeStream compresses (and encrypts) source into store as blob.
+func storeStream(store BlobStore, digest string, source io.Reader) error {
+	reader, writer := io.Pipe()
+	go func() {
+		buffered := bufio.NewReader(source)
+		compressor, err := compressingWriter(writer, buffered)
+		if err == nil {
+			var size int64
+			size, err = io.Copy(compressor, buffered)
+			countLifted(size)
+			if err == nil {
+				err = compressor.Close()
+			}
+		}
+		writer.CloseWithError(err)
+	}()
+	err := store.Put(digest, reader)
+	reader.CloseWithError(err)
+	return err
+}
@@ -0,0 +1,109 @@
+package htfs_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/settings"
+)
+
+type memoryStore struct {
+	sync.Mutex
+	blobs map[string][]byte
+}
+
+func (it *memoryStore) Name() string {
+	return "memory"
+}
+
+func (it *memoryStore) Has(digest string) bool {
+	it.Lock()
+	defer it.Unlock()
+	_, ok := it.blobs[digest]
+	return ok
+}
+
+func (it *memoryStore) Open(digest string) (io.ReadCloser, error) {
+	it.Lock()
+	defer it.Unlock()
+	content, ok := it.blobs[digest]
+	if !ok {
+		return nil, fmt.Errorf("No blob %q.", digest)
+	}
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (it *memoryStore) Put(digest string, stored io.Reader) error {
+	content, err := ioutil.ReadAll(stored)
+	if err != nil {
+		return err
+	}
+	it.Lock()
+	defer it.Unlock()
+	it.blobs[digest] = content
+	return nil
+}
+
+func (it *memoryStore) Remove(digest string) error {
+	it.Lock()
+	defer it.Unlock()
+	delete(it.blobs, digest)
+	return nil
+}
+
+func TestCanUseRegisteredBlobStore(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, t.TempDir())
+
+	config := settings.Global.Holotree()
+	previous := config.BlobStore
+	defer func() {
+		config.BlobStore = previous
+	}()
+
+	store := &memoryStore{blobs: make(map[string][]byte)}
+	htfs.RegisterBlobStore("memory", func(system string) (htfs.BlobStore, error) {
+		return store, nil
+	})
+	must.Equal([]string{"directory", "memory", "pack"}, htfs.BlobStores())
+
+	config.BlobStore = "bogus"
+	_, err := htfs.New()
+	wont.Nil(err)
+
+	config.BlobStore = "Memory"
+	must.Equal("memory", htfs.BlobStoreName())
+	common.ControllerType = "unittest"
+	blueprint := []byte("blobstore: unittest")
+	library := testLibrary(t, map[string]string{
+		"first.txt":  "first file",
+		"second.txt": "second file",
+	})
+	must.Nil(library.Record(blueprint))
+	must.Equal(2, len(store.blobs))
+	must.Equal(2, len(htfs.LoadHololibHashes()))
+	for digest, _ := range htfs.LoadHololibHashes() {
+		must.True(library.HasBlob(digest))
+		_, err := os.Stat(library.ExactLocation(digest))
+		wont.Nil(err)
+	}
+
+	library, err = htfs.New()
+	must.Nil(err)
+	must.True(library.HasBlueprint(blueprint))
+	path, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("blobstore"))
+	must.Nil(err)
+	content, err := ioutil.ReadFile(filepath.Join(path, "second.txt"))
+	must.Nil(err)
+	must.Equal("second file", string(content))
+}
@@ -0,0 +1,129 @@
+package htfs
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+// SpaceEntry is one holotree space, as shown in holotree browser. Label is
+// directory name of space, which identifies it in delete and pin.
+type SpaceEntry struct {
+	Label      string    `json:"label"`
+	Controller string    `json:"controller"`
+	Space      string    `json:"space"`
+	Blueprint  string    `json:"blueprint"`
+	Catalog    string    `json:"catalog"`
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	Files      int       `json:"files"`
+	Used       time.Time `json:"used"`
+	Created    string    `json:"created,omitempty"`
+	Pinned     bool      `json:"pinned"`
+}
+
+// CatalogEntry is one hololib catalog, as shown in holotree browser.
+type CatalogEntry struct {
+	*CatalogInfo
+	Used   time.Time `json:"used"`
+	Pinned bool      `json:"pinned"`
+}
+
+// BrowseSpaces lists spaces, most recently used first.
+func BrowseSpaces() []*SpaceEntry {
+	pins := LoadPins()
+	result := []*SpaceEntry{}
+	for _, space := range Spaces() {
+		label := filepath.Base(space.Path)
+		size, files := treeSize(space.Tree)
+		entry := &SpaceEntry{
+			Label:      label,
+			Controller: space.Controller,
+			Space:      space.Space,
+			Blueprint:  space.Blueprint,
+			Path:       space.Path,
+			Size:       size,
+			Files:      files,
+			Used:       lastUsed(space.Path + ".meta"),
+			Created:    space.Created,
+			Pinned:     pins.SpacePinned(label),
+		}
+		if len(space.Blueprint) > 0 {
+			entry.Catalog = CatalogName(space.Blueprint)
+		}
+		result = append(result, entry)
+	}
+	sort.SliceStable(result, func(left, right int) bool {
+		if result[left].Used.Equal(result[right].Used) {
+			return result[left].Label < result[right].Label
+		}
+		return result[left].Used.After(result[right].Used)
+	})
+	return result
+}
+
+// BrowseCatalogs lists catalogs, most recently used first.
+func BrowseCatalogs() ([]*CatalogEntry, error) {
+	infos, err := CatalogInfos()
+	if err != nil {
+		return nil, err
+	}
+	pinned := LoadPins().PinnedCatalogs()
+	result := make([]*CatalogEntry, 0, len(infos))
+	for _, info := range infos {
+		result = append(result, &CatalogEntry{
+			CatalogInfo: info,
+			Used:        lastUsed(filepath.Join(common.HololibCatalogLocation(), info.Name)),
+			Pinned:      pinned[info.Name],
+		})
+	}
+	sort.SliceStable(result, func(left, right int) bool {
+		if result[left].Used.Equal(result[right].Used) {
+			return result[left].Name < result[right].Name
+		}
+		return result[left].Used.After(result[right].Used)
+	})
+	return result, nil
+}
+
+// RemoveCatalog removes one catalog (and its signature) from hololib, unless
+// it is pinned. Blobs are left for garbage collection.
+func RemoveCatalog(name string) (err error) {
+	defer fail.Around(&err)
+
+	locker, err := pathlib.Locker(common.HolotreeLock(), 30000)
+	fail.On(err != nil, "Could not get lock for holotree. Quiting.")
+	defer locker.Release()
+
+	fail.On(LoadPins().PinnedCatalogs()[name], "Catalog %q is pinned, unpin it first.", name)
+	catalog := filepath.Join(common.HololibCatalogLocation(), name)
+	fail.On(!pathlib.IsFile(catalog), "No catalog %q in hololib.", name)
+	err = TryRemove("catalog", catalog)
+	fail.On(err != nil, "%v", err)
+	if pathlib.IsFile(SignatureFile(catalog)) {
+		err = TryRemove("signature", SignatureFile(catalog))
+		fail.On(err != nil, "%v", err)
+	}
+	return nil
+}
+
+// CatalogHealth tells, using integrity report, whether named catalog is
+// intact, or how it is broken.
+func CatalogHealth(report *IntegrityReport, name string) string {
+	for _, catalog := range report.Unreadable {
+		if filepath.Base(catalog) == name {
+			return "unreadable"
+		}
+	}
+	for _, catalog := range report.Affected {
+		if filepath.Base(catalog) == name {
+			return fmt.Sprintf("affected by %d damaged and %d missing blob(s)", len(report.Damaged), len(report.Missing))
+		}
+	}
+	return "intact"
+}
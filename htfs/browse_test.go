@@ -0,0 +1,58 @@
+package htfs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestBrowsingSpacesAndCatalogs(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	library := testLibrary(t, map[string]string{"browse.txt": "browse"})
+	blueprint := []byte("browse: test")
+	must.Nil(library.Record(blueprint))
+	catalog := htfs.CatalogName(htfs.BlueprintHash(blueprint))
+
+	recent, err := library.Restore(blueprint, []byte("browser"), []byte("recent"))
+	must.Nil(err)
+	older, err := library.Restore(blueprint, []byte("browser"), []byte("older"))
+	must.Nil(err)
+	old := time.Now().Add(-5 * 24 * time.Hour)
+	must.Nil(os.Chtimes(older+".meta", old, old))
+
+	spaces := htfs.BrowseSpaces()
+	must.Equal(2, len(spaces))
+	must.Equal(filepath.Base(recent), spaces[0].Label)
+	must.Equal(filepath.Base(older), spaces[1].Label)
+	must.Equal(catalog, spaces[0].Catalog)
+	must.Equal(int64(6), spaces[0].Size)
+	wont.True(spaces[0].Pinned)
+
+	drift, err := htfs.CheckLabelDrift(spaces[0].Label)
+	must.Nil(err)
+	wont.True(drift.Dirty())
+
+	_, err = htfs.Pin([]string{spaces[1].Label}, false)
+	must.Nil(err)
+	must.True(htfs.BrowseSpaces()[1].Pinned)
+
+	catalogs, err := htfs.BrowseCatalogs()
+	must.Nil(err)
+	must.Equal(1, len(catalogs))
+	must.Equal(catalog, catalogs[0].Name)
+	must.True(catalogs[0].Pinned)
+	wont.Nil(htfs.RemoveCatalog(catalog))
+
+	_, err = htfs.Pin([]string{spaces[1].Label}, true)
+	must.Nil(err)
+	must.Nil(htfs.RemoveCatalog(catalog))
+	catalogs, err = htfs.BrowseCatalogs()
+	must.Nil(err)
+	must.Equal(0, len(catalogs))
+	wont.Nil(htfs.RemoveCatalog(catalog))
+}
@@ -0,0 +1,125 @@
+package htfs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/robot"
+	"github.com/robocorp/rcc/settings"
+)
+
+const (
+	gigabyte       = 1024 * 1024 * 1024
+	budgetTopLimit = 10
+)
+
+type SizeEntry struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+type SpaceUsage struct {
+	Total   int64        `json:"total"`
+	Largest []*SizeEntry `json:"largest"`
+}
+
+// usageKey returns entry that file is accounted for. Inside site-packages
+// that is package directory, elsewhere top level directory of space.
+func usageKey(relative string) string {
+	parts := strings.Split(filepath.ToSlash(relative), "/")
+	for at, part := range parts {
+		if part == "site-packages" && at+1 < len(parts) {
+			return path.Join(parts[:at+2]...)
+		}
+	}
+	return parts[0]
+}
+
+// MeasureSpace walks given space and returns its total size and largest
+// packages/directories in it.
+func MeasureSpace(space string, limit int) (*SpaceUsage, error) {
+	sizes := make(map[string]int64)
+	result := &SpaceUsage{}
+	err := filepath.Walk(space, func(fullpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		relative, err := filepath.Rel(space, fullpath)
+		if err != nil {
+			return err
+		}
+		result.Total += info.Size()
+		sizes[usageKey(relative)] += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Largest = make([]*SizeEntry, 0, len(sizes))
+	for key, size := range sizes {
+		result.Largest = append(result.Largest, &SizeEntry{Path: key, Size: size})
+	}
+	sort.SliceStable(result.Largest, func(left, right int) bool {
+		if result.Largest[left].Size == result.Largest[right].Size {
+			return result.Largest[left].Path < result.Largest[right].Path
+		}
+		return result.Largest[left].Size > result.Largest[right].Size
+	})
+	if len(result.Largest) > limit {
+		result.Largest = result.Largest[:limit]
+	}
+	return result, nil
+}
+
+// environmentBudget comes from robot.yaml, or from settings as fallback.
+func environmentBudget(robotSettings *robot.Settings) (float64, bool) {
+	if robotSettings.Budget > 0 {
+		return robotSettings.Budget, robotSettings.EnforceBudget
+	}
+	return settings.Global.EnvironmentBudget()
+}
+
+func gigabytes(size int64) string {
+	return fmt.Sprintf("%.2fG", float64(size)/gigabyte)
+}
+
+// checkEnvironmentBudget compares size of freshly built environment against
+// budget, and warns (or fails, if budget is enforced) with breakdown of
+// largest parts of environment. This is done once, before environment is
+// recorded, and not on every run.
+func checkEnvironmentBudget(space string, robotSettings *robot.Settings) error {
+	budget, enforce := environmentBudget(robotSettings)
+	if budget <= 0 || len(space) == 0 {
+		return nil
+	}
+	common.Timeline("environment budget check started")
+	defer common.Timeline("environment budget check done")
+	usage, err := MeasureSpace(space, budgetTopLimit)
+	if err != nil {
+		pretty.Warning("Could not measure environment size, reason: %v", err)
+		return nil
+	}
+	limit := int64(budget * gigabyte)
+	if usage.Total <= limit {
+		common.Debug("Environment size %s is within budget of %s.", gigabytes(usage.Total), gigabytes(limit))
+		return nil
+	}
+	common.Log("Largest parts of environment %q:", space)
+	for _, entry := range usage.Largest {
+		common.Log("  %8s  %s", gigabytes(entry.Size), entry.Path)
+	}
+	if enforce {
+		return common.WithCode(common.CodeEnvValidation, fmt.Errorf("Environment size %s exceeds budget of %s.", gigabytes(usage.Total), gigabytes(limit)))
+	}
+	pretty.Warning("Environment size %s exceeds budget of %s.", gigabytes(usage.Total), gigabytes(limit))
+	return nil
+}
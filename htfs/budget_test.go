@@ -0,0 +1,42 @@
+package htfs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestCanMeasureSpaceUsage(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	space, err := ioutil.TempDir("", "budget")
+	must.Nil(err)
+	defer os.RemoveAll(space)
+
+	files := map[string]int{
+		"lib/python3.9/site-packages/numpy/core.so":     300,
+		"lib/python3.9/site-packages/numpy/__init__.py": 100,
+		"lib/python3.9/site-packages/six.py":            50,
+		"bin/python":                                    200,
+		"README":                                        10,
+	}
+	for name, size := range files {
+		fullpath := filepath.Join(space, filepath.FromSlash(name))
+		must.Nil(os.MkdirAll(filepath.Dir(fullpath), 0o755))
+		must.Nil(ioutil.WriteFile(fullpath, make([]byte, size), 0o644))
+	}
+
+	usage, err := htfs.MeasureSpace(space, 3)
+	must.Nil(err)
+	wont.Nil(usage)
+	must.Equal(int64(660), usage.Total)
+	must.Equal(3, len(usage.Largest))
+	must.Equal("lib/python3.9/site-packages/numpy", usage.Largest[0].Path)
+	must.Equal(int64(400), usage.Largest[0].Size)
+	must.Equal("bin", usage.Largest[1].Path)
+	must.Equal("lib/python3.9/site-packages/six.py", usage.Largest[2].Path)
+}
@@ -0,0 +1,33 @@
+package htfs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/fail"
+)
+
+// PlanBuild tells what building environment of condafile would do, without
+// building it. Environment already in hololib only needs restore, and
+// otherwise conda layer and package caches are checked.
+func PlanBuild(condafile string, force bool) (plan *conda.BuildPlan, err error) {
+	defer fail.Around(&err)
+
+	_, blueprint, err := ComposeFinalBlueprint([]string{condafile}, "")
+	fail.On(err != nil, "%s", err)
+	key := BlueprintHash(blueprint)
+	tree, err := New()
+	fail.On(err != nil, "%s", err)
+	if !force && tree.HasBlueprint(blueprint) {
+		return conda.HololibPlan(key), nil
+	}
+	identity := filepath.Join(os.TempDir(), fmt.Sprintf("identity_%x.yaml", common.When))
+	err = ioutil.WriteFile(identity, blueprint, 0o644)
+	fail.On(err != nil, "Failed to save %q, reason %v.", identity, err)
+	defer os.Remove(identity)
+	return conda.PlanBuild(EnvironmentLayers(tree), key, force, identity)
+}
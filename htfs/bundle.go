@@ -0,0 +1,373 @@
+package htfs
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+const (
+	catalogFolder = `catalog`
+	libraryFolder = `library`
+)
+
+// zipName normalizes bundle entry names, so that bundles exported on
+// different platforms can be merged and read anywhere.
+func zipName(name string) string {
+	return strings.ReplaceAll(name, `\`, "/")
+}
+
+func blobName(digest string) string {
+	return path.Join(libraryFolder, digest[:2], digest[2:4], digest[4:6], digest)
+}
+
+func catalogName(name string) string {
+	return path.Join(catalogFolder, name)
+}
+
+// catalogPlatform returns platform part of catalog name, like "linux_amd64"
+// from "catalog/0123456789abcdef.linux_amd64".
+func catalogPlatform(name string) string {
+	parts := strings.SplitN(path.Base(name), ".", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// Merge copies catalogs and blobs from other bundle, skipping ones already
+// in this bundle.
+func (it *zipseen) Merge(bundle string) (err error) {
+	defer fail.Around(&err)
+
+	source, err := zip.OpenReader(bundle)
+	fail.On(err != nil, "Could not open bundle %q -> %v", bundle, err)
+	defer source.Close()
+	for _, entry := range source.File {
+		name := zipName(entry.Name)
+		if it.seen[name] || strings.HasSuffix(name, "/") {
+			continue
+		}
+		it.seen[name] = true
+		reader, err := entry.Open()
+		fail.On(err != nil, "Could not open %q from %q -> %v", name, bundle, err)
+		target, err := it.Create(name)
+		if err == nil {
+			_, err = io.Copy(target, reader)
+		}
+		reader.Close()
+		fail.On(err != nil, "Could not merge %q from %q -> %v", name, bundle, err)
+	}
+	return nil
+}
+
+type ImportReport struct {
+	Dryrun   bool     `json:"dryrun"`
+	Imported []string `json:"imported"`
+	Replaced []string `json:"replaced"`
+	Skipped  []string `json:"skipped"`
+	Blobs    int      `json:"blobs"`
+	Resumed  int      `json:"resumed"`
+}
+
+// stagingLocation is where content of bundle is extracted and verified before
+// it is moved into hololib. It is named by bundle content (entry names and
+// checksums), so that interrupted import of same bundle resumes from there.
+func stagingLocation(source *zip.ReadCloser) string {
+	digest := sha256.New()
+	for _, entry := range source.File {
+		fmt.Fprintf(digest, "%s:%08x:%d\n", entry.Name, entry.CRC32, entry.UncompressedSize64)
+	}
+	return filepath.Join(common.HololibStagingLocation(), fmt.Sprintf("%02x", digest.Sum(nil)[:8]))
+}
+
+// stageBlob extracts and verifies one blob into staging, unless already
+// staged (and still valid) by earlier, interrupted import.
+func stageBlob(entry *zip.File, digest, staged string) (resumed bool, err error) {
+	defer fail.Around(&err)
+
+	if pathlib.IsFile(staged) {
+		actual, err := matchingDigest(staged, digest, false)
+		if err == nil && actual == digest {
+			return true, nil
+		}
+		os.Remove(staged)
+	}
+	err = extractEntry(entry, staged)
+	fail.On(err != nil, "%v", err)
+	actual, err := matchingDigest(staged, digest, false)
+	fail.On(err != nil, "%v", err)
+	if actual != digest {
+		os.Remove(staged)
+		fail.On(true, "Digest mismatch for %q, expected %q, got %q.", entry.Name, digest, actual)
+	}
+	return false, nil
+}
+
+// commitStaged moves staged blob or catalog into hololib. Blobs already put
+// there by some other process are kept, but catalogs are replaced.
+func commitStaged(staged, target string, replace bool) (err error) {
+	defer fail.Around(&err)
+
+	locker, err := keyLock(filepath.Base(target))
+	fail.On(err != nil, "%v", err)
+	defer locker.Release()
+	if !replace && pathlib.IsFile(target) {
+		return os.Remove(staged)
+	}
+	err = os.MkdirAll(filepath.Dir(target), 0o755)
+	fail.On(err != nil, "%v", err)
+	if os.Rename(staged, target) == nil {
+		return nil
+	}
+	// staging is in writable home, which can be on other device than hololib
+	partname := fmt.Sprintf("%s.part%s", target, <-common.Identities)
+	defer os.Remove(partname)
+	err = pathlib.CopyFile(staged, partname, true)
+	fail.On(err != nil, "%v", err)
+	err = TryRename("import", partname, target)
+	fail.On(err != nil, "%v", err)
+	return os.Remove(staged)
+}
+
+// commitStagedBlob moves staged blob into hololib, through blob store when
+// it does not keep blobs as files.
+func commitStagedBlob(store BlobStore, staged, target string) (err error) {
+	defer fail.Around(&err)
+
+	if _, files := store.(FileBlobStore); files {
+		return commitStaged(staged, target, false)
+	}
+	digest := filepath.Base(target)
+	locker, err := keyLock(digest)
+	fail.On(err != nil, "%v", err)
+	defer locker.Release()
+	source, err := os.Open(staged)
+	fail.On(err != nil, "%v", err)
+	defer os.Remove(staged)
+	defer source.Close()
+	return store.Put(digest, source)
+}
+
+func extractEntry(entry *zip.File, target string) (err error) {
+	defer fail.Around(&err)
+
+	err = os.MkdirAll(filepath.Dir(target), 0o755)
+	fail.On(err != nil, "%v", err)
+	reader, err := entry.Open()
+	fail.On(err != nil, "%v", err)
+	defer reader.Close()
+	partname := fmt.Sprintf("%s.part%s", target, <-common.Identities)
+	defer os.Remove(partname)
+	sink, err := os.Create(partname)
+	fail.On(err != nil, "%v", err)
+	_, err = io.Copy(sink, reader)
+	sink.Close()
+	fail.On(err != nil, "%v", err)
+	return TryRename("bundle", partname, target)
+}
+
+func catalogRoot(entry *zip.File) (root *Root, err error) {
+	defer fail.Around(&err)
+
+	reader, err := entry.Open()
+	fail.On(err != nil, "%v", err)
+	defer reader.Close()
+	unzipped, err := gzip.NewReader(reader)
+	fail.On(err != nil, "%v", err)
+	defer unzipped.Close()
+	root, err = NewRoot(".")
+	fail.On(err != nil, "%v", err)
+	err = root.ReadFrom(unzipped)
+	fail.On(err != nil, "%v", err)
+	return root, nil
+}
+
+func catalogDigests(root *Root) (result map[string]string, err error) {
+	result = make(map[string]string)
+	err = root.Treetop(DigestMapper(result))
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func readEntry(entry *zip.File) ([]byte, error) {
+	reader, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// verifyEntry checks that catalog entry has signature by trusted key.
+func verifyEntry(catalog, signature *zip.File) (string, error) {
+	if signature == nil {
+		return "", fmt.Errorf("Catalog is not signed.")
+	}
+	content, err := readEntry(catalog)
+	if err != nil {
+		return "", err
+	}
+	line, err := readEntry(signature)
+	if err != nil {
+		return "", err
+	}
+	return VerifySignature(content, string(line), TrustedKeys())
+}
+
+// ImportBundle imports catalogs of local platform (and blobs they need) from
+// possibly multi-platform bundle into local hololib. Everything is first
+// extracted and verified in staging area, and only then moved into hololib,
+// blobs before catalogs, so failed import never leaves catalogs referring to
+// missing blobs, and next import of same bundle resumes from staged files.
+func ImportBundle(bundle string) (*ImportReport, error) {
+	return importBundle(bundle, false)
+}
+
+// PreviewBundle checks signatures, platforms, and blobs of bundle, and
+// reports what importing it would do (including existing catalogs it would
+// replace), without changing hololib.
+func PreviewBundle(bundle string) (*ImportReport, error) {
+	return importBundle(bundle, true)
+}
+
+func importBundle(bundle string, dryrun bool) (report *ImportReport, err error) {
+	defer fail.Around(&err)
+
+	source, err := zip.OpenReader(bundle)
+	fail.On(err != nil, "Could not open bundle %q -> %v", bundle, err)
+	defer source.Close()
+	store, err := hololibStore()
+	fail.On(err != nil, "%v", err)
+
+	report = &ImportReport{Dryrun: dryrun, Imported: []string{}, Replaced: []string{}, Skipped: []string{}}
+	catalogs := []*zip.File{}
+	signatures := make(map[string]*zip.File)
+	blobs := make(map[string]*zip.File)
+	for _, entry := range source.File {
+		name := zipName(entry.Name)
+		switch {
+		case strings.HasPrefix(name, catalogFolder+"/") && isSignatureFile(name):
+			signatures[strings.TrimSuffix(name, signatureSuffix)] = entry
+		case strings.HasPrefix(name, catalogFolder+"/"):
+			if catalogPlatform(name) == common.Platform() {
+				catalogs = append(catalogs, entry)
+				report.Imported = append(report.Imported, path.Base(name))
+			} else {
+				report.Skipped = append(report.Skipped, path.Base(name))
+			}
+		case strings.HasPrefix(name, libraryFolder+"/"):
+			blobs[path.Base(name)] = entry
+		}
+	}
+	if SigningRequired() {
+		for _, catalog := range catalogs {
+			signer, err := verifyEntry(catalog, signatures[zipName(catalog.Name)])
+			fail.On(err != nil, "%w", common.WithCode(common.CodeHoloSignature, fmt.Errorf("Catalog %q failed signature verification -> %v", catalog.Name, err)))
+			common.Debug("Catalog %q is signed by trusted key %q.", catalog.Name, signer)
+		}
+	}
+	staging := stagingLocation(source)
+	common.Debug("Staging import of %q in %q.", bundle, staging)
+	staged := make(map[string]string)
+	for _, catalog := range catalogs {
+		root, err := catalogRoot(catalog)
+		fail.On(err != nil, "Could not read catalog %q -> %v", catalog.Name, err)
+		err = root.CompatiblePlatform()
+		fail.On(err != nil, "Could not import catalog %q -> %v", catalog.Name, err)
+		err = guardImportCollisions(root, path.Base(zipName(catalog.Name)))
+		fail.On(err != nil, "%v", err)
+		wanted, err := catalogDigests(root)
+		fail.On(err != nil, "Could not read catalog %q -> %v", catalog.Name, err)
+		for digest, _ := range wanted {
+			target := filepath.Join(common.HololibLocation(), filepath.FromSlash(blobName(digest)))
+			_, seen := staged[target]
+			if seen || store.Has(digest) {
+				continue
+			}
+			// delta bundles leave out blobs, which hololib already has
+			entry, ok := blobs[digest]
+			fail.On(!ok, "Bundle %q is missing blob %q.", bundle, digest)
+			if dryrun {
+				staged[target] = ""
+				report.Blobs += 1
+				continue
+			}
+			stagename := filepath.Join(staging, filepath.FromSlash(blobName(digest)))
+			resumed, err := stageBlob(entry, digest, stagename)
+			fail.On(err != nil, "Could not stage %q -> %v", entry.Name, err)
+			if resumed {
+				report.Resumed += 1
+			}
+			staged[target] = stagename
+			report.Blobs += 1
+		}
+	}
+	for _, catalog := range catalogs {
+		name := path.Base(zipName(catalog.Name))
+		if pathlib.IsFile(filepath.Join(common.HololibCatalogLocation(), name)) {
+			report.Replaced = append(report.Replaced, name)
+		}
+	}
+	sort.Strings(report.Imported)
+	sort.Strings(report.Replaced)
+	sort.Strings(report.Skipped)
+	if dryrun {
+		return report, nil
+	}
+	stagedCatalogs := make(map[string]string)
+	stagedSignatures := make(map[string]string)
+	for _, catalog := range catalogs {
+		name := path.Base(zipName(catalog.Name))
+		stagename := filepath.Join(staging, catalogFolder, name)
+		err = extractEntry(catalog, stagename)
+		fail.On(err != nil, "Could not stage %q -> %v", catalog.Name, err)
+		target := filepath.Join(common.HololibCatalogLocation(), name)
+		stagedCatalogs[target] = stagename
+		signature, ok := signatures[zipName(catalog.Name)]
+		if !ok {
+			// unsigned catalog replaces signed one, so old signature must go
+			stagedSignatures[SignatureFile(target)] = ""
+			continue
+		}
+		stagename = SignatureFile(stagename)
+		err = extractEntry(signature, stagename)
+		fail.On(err != nil, "Could not stage %q -> %v", signature.Name, err)
+		stagedSignatures[SignatureFile(target)] = stagename
+	}
+	for target, stagename := range staged {
+		err = commitStagedBlob(store, stagename, target)
+		fail.On(err != nil, "Could not import blob %q -> %v", target, err)
+	}
+	for target, stagename := range stagedCatalogs {
+		err = commitStaged(stagename, target, true)
+		fail.On(err != nil, "Could not import catalog %q -> %v", target, err)
+	}
+	for target, stagename := range stagedSignatures {
+		if len(stagename) == 0 {
+			if pathlib.IsFile(target) {
+				err = TryRemove("signature", target)
+			}
+		} else {
+			err = commitStaged(stagename, target, true)
+		}
+		fail.On(err != nil, "Could not import signature %q -> %v", target, err)
+	}
+	err = os.RemoveAll(staging)
+	fail.On(err != nil, "Could not remove staging %q -> %v", staging, err)
+	return report, nil
+}
@@ -0,0 +1,206 @@
+package htfs_test
+
+import (
+	"archive/zip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func writeForeignBundle(filename string) error {
+	handle, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer handle.Close()
+	writer := zip.NewWriter(handle)
+	defer writer.Close()
+	for _, name := range []string{`catalog\0123456789abcdef.windows_amd64`, `library\ff\ee\dd\ffeeddcc`} {
+		sink, err := writer.Create(name)
+		if err != nil {
+			return err
+		}
+		sink.Write([]byte("foreign"))
+	}
+	return nil
+}
+
+func TestCanExportAndImportMultiPlatformBundles(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	folder := t.TempDir()
+	blueprint := []byte("bundle: unittest")
+	foreign := filepath.Join(folder, "windows.zip")
+	bundle := filepath.Join(folder, "bundle.zip")
+	must.Nil(writeForeignBundle(foreign))
+
+	library := testLibrary(t, map[string]string{"bundled.txt": "bundled content"})
+	must.Nil(library.Record(blueprint))
+	catalogs := htfs.Catalogs()
+	must.Equal(1, len(catalogs))
+	must.Nil(library.Export(catalogs, []string{foreign}, bundle))
+
+	zipped, err := htfs.ZipLibrary(bundle)
+	must.Nil(err)
+	must.True(zipped.HasBlueprint(blueprint))
+
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, filepath.Join(folder, "user"))
+	report, err := htfs.ImportBundle(bundle)
+	must.Nil(err)
+	must.Equal(catalogs, report.Imported)
+	must.Equal([]string{"0123456789abcdef.windows_amd64"}, report.Skipped)
+	must.Equal(1, report.Blobs)
+	must.Equal(catalogs, htfs.Catalogs())
+
+	library, err = htfs.New()
+	must.Nil(err)
+	must.True(library.HasBlueprint(blueprint))
+	_, err = os.Stat(filepath.Join(folder, "user", "hololib", "library", "ff"))
+	wont.Nil(err)
+}
+
+func TestCanExportMultipleCatalogsWithSharedBlobsOnce(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	library := testLibrary(t, nil)
+	for _, name := range []string{"first", "second"} {
+		testStage(t, library, map[string]string{
+			"shared.txt": "shared content",
+			"own.txt":    name,
+		})
+		must.Nil(library.Record([]byte("bundle: " + name)))
+	}
+	catalogs := htfs.Catalogs()
+	must.Equal(2, len(catalogs))
+
+	bundle := filepath.Join(t.TempDir(), "bundle.zip")
+	must.Nil(library.Export(catalogs, []string{}, bundle))
+
+	archive, err := zip.OpenReader(bundle)
+	must.Nil(err)
+	defer archive.Close()
+	seen := make(map[string]bool)
+	blobs := 0
+	for _, entry := range archive.File {
+		wont.True(seen[entry.Name])
+		seen[entry.Name] = true
+		if strings.HasPrefix(entry.Name, "library/") {
+			blobs += 1
+		}
+	}
+	must.Equal(5, len(archive.File))
+	must.Equal(3, blobs)
+}
+
+func corruptBundle(source, target string) error {
+	reader, err := zip.OpenReader(source)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	handle, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer handle.Close()
+	writer := zip.NewWriter(handle)
+	defer writer.Close()
+	corrupted := false
+	for _, entry := range reader.File {
+		sink, err := writer.Create(entry.Name)
+		if err != nil {
+			return err
+		}
+		if !corrupted && strings.HasPrefix(entry.Name, "library/") {
+			corrupted = true
+			sink.Write([]byte("corrupted"))
+			continue
+		}
+		content, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(sink, content)
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestImportOfBrokenBundleLeavesNoPartialState(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	folder := t.TempDir()
+	blueprint := []byte("staging: unittest")
+	bundle := filepath.Join(folder, "bundle.zip")
+	broken := filepath.Join(folder, "broken.zip")
+
+	library := testLibrary(t, map[string]string{
+		"first.txt":  "first content",
+		"second.txt": "second content",
+	})
+	must.Nil(library.Record(blueprint))
+	must.Nil(library.Export(htfs.Catalogs(), []string{}, bundle))
+	must.Nil(corruptBundle(bundle, broken))
+
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, filepath.Join(folder, "user"))
+	_, err := htfs.ImportBundle(broken)
+	wont.Nil(err)
+	must.Equal(0, len(htfs.Catalogs()))
+	must.Equal(0, len(htfs.LoadHololibHashes()))
+	_, err = os.Stat(common.HololibLibraryLocation())
+	wont.Nil(err)
+
+	report, err := htfs.ImportBundle(bundle)
+	must.Nil(err)
+	must.Equal(2, report.Blobs)
+	must.Equal(1, len(htfs.Catalogs()))
+	library, err = htfs.New()
+	must.Nil(err)
+	must.True(library.HasBlueprint(blueprint))
+	entries, err := ioutil.ReadDir(common.HololibStagingLocation())
+	must.Nil(err)
+	must.Equal(1, len(entries))
+}
+
+func TestPreviewOfBundleDoesNotChangeHololib(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	folder := t.TempDir()
+	blueprint := []byte("bundle: preview")
+	bundle := filepath.Join(folder, "bundle.zip")
+
+	library := testLibrary(t, map[string]string{"preview.txt": "preview content"})
+	must.Nil(library.Record(blueprint))
+	catalogs := htfs.Catalogs()
+	must.Equal(1, len(catalogs))
+	must.Nil(library.Export(catalogs, []string{}, bundle))
+
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, filepath.Join(folder, "user"))
+	preview, err := htfs.PreviewBundle(bundle)
+	must.Nil(err)
+	must.True(preview.Dryrun)
+	must.Equal(catalogs, preview.Imported)
+	must.Equal(0, len(preview.Replaced))
+	must.Equal(1, preview.Blobs)
+	must.Equal(0, len(htfs.Catalogs()))
+
+	report, err := htfs.ImportBundle(bundle)
+	must.Nil(err)
+	wont.True(report.Dryrun)
+	must.Equal(0, len(report.Replaced))
+
+	preview, err = htfs.PreviewBundle(bundle)
+	must.Nil(err)
+	must.Equal(catalogs, preview.Replaced)
+	must.Equal(0, preview.Blobs)
+}
@@ -0,0 +1,230 @@
+package htfs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/robocorp/rcc/anywork"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+)
+
+// Files larger than chunk threshold are stored as content-defined chunks,
+// so that huge files do not need to be handled as one blob, and files that
+// differ only partially (like model versions) share their common chunks.
+// Cut points come from gear rolling hash, and they depend only on content,
+// so inserting data moves only nearby cut points.
+const (
+	chunkMinimum = 1 << 20
+	chunkMaximum = 16 << 20
+	chunkMask    = (1 << 22) - 1
+)
+
+var (
+	gearTable [256]uint64
+)
+
+func init() {
+	for at := range gearTable {
+		digest := sha256.Sum256([]byte{byte(at)})
+		gearTable[at] = binary.LittleEndian.Uint64(digest[:8])
+	}
+}
+
+// ChunkThreshold is file size (in bytes) from which files are chunked, or
+// zero when chunking is disabled.
+func ChunkThreshold() int64 {
+	megabytes := settings.Global.ChunkThreshold()
+	if megabytes < 1 {
+		return 0
+	}
+	return int64(megabytes) * 1024 * 1024
+}
+
+func chunkable(size int64) bool {
+	threshold := ChunkThreshold()
+	return threshold > 0 && size >= threshold
+}
+
+// Blobs tells which hololib blobs hold content of file.
+func (it *File) Blobs() []string {
+	if len(it.Chunks) > 0 {
+		return it.Chunks
+	}
+	return []string{it.Digest}
+}
+
+type chunker struct {
+	source io.Reader
+	buffer []byte
+	filled int
+	eof    bool
+}
+
+func newChunker(source io.Reader) *chunker {
+	return &chunker{
+		source: source,
+		buffer: make([]byte, chunkMaximum),
+	}
+}
+
+func cutPoint(data []byte) int {
+	if len(data) <= chunkMinimum {
+		return len(data)
+	}
+	hash := uint64(0)
+	for at := chunkMinimum; at < len(data); at++ {
+		hash = (hash << 1) + gearTable[data[at]]
+		if hash&chunkMask == 0 {
+			return at + 1
+		}
+	}
+	return len(data)
+}
+
+// Next returns next chunk of source, or io.EOF when there is no more.
+func (it *chunker) Next() ([]byte, error) {
+	for !it.eof && it.filled < len(it.buffer) {
+		size, err := it.source.Read(it.buffer[it.filled:])
+		it.filled += size
+		if err == io.EOF {
+			it.eof = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if it.filled == 0 {
+		return nil, io.EOF
+	}
+	cut := cutPoint(it.buffer[:it.filled])
+	chunk := make([]byte, cut)
+	copy(chunk, it.buffer[:cut])
+	copy(it.buffer, it.buffer[cut:it.filled])
+	it.filled -= cut
+	return chunk, nil
+}
+
+func storeChunk(library MutableLibrary, digest string, content []byte) (err error) {
+	locker, err := keyLock(digest)
+	if err != nil {
+		return err
+	}
+	defer locker.Release()
+	if library.HasBlob(digest) {
+		return nil
+	}
+	if store, ok := storeOf(library); ok {
+		return storeStream(store, digest, bytes.NewReader(content))
+	}
+	countLifted(int64(len(content)))
+	directory := library.Location(digest)
+	err = os.MkdirAll(directory, 0o755)
+	if err != nil {
+		return err
+	}
+	sinkname := filepath.Join(directory, digest)
+	partname := fmt.Sprintf("%s.part%s", sinkname, <-common.Identities)
+	defer os.Remove(partname)
+	sink, err := os.Create(partname)
+	if err != nil {
+		return err
+	}
+	defer sink.Close()
+	buffered := bufio.NewReader(bytes.NewReader(content))
+	writer, err := compressingWriter(sink, buffered)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, buffered)
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	err = sink.Close()
+	if err != nil {
+		return err
+	}
+	return TryRename("liftchunk", partname, sinkname)
+}
+
+// LiftChunks stores large file as chunks, and records their digests into
+// file details.
+func LiftChunks(library MutableLibrary, sourcename string, details *File) anywork.Work {
+	return func() {
+		source, err := os.Open(sourcename)
+		anywork.OnErrPanicCloseAll(err)
+		defer source.Close()
+
+		chunks := []string{}
+		splitter := newChunker(throttled(source))
+		for {
+			chunk, err := splitter.Next()
+			if err == io.EOF {
+				break
+			}
+			anywork.OnErrPanicCloseAll(err)
+			digest := fmt.Sprintf("%02x", sha256.Sum256(chunk))
+			anywork.OnErrPanicCloseAll(storeChunk(library, digest, chunk))
+			chunks = append(chunks, digest)
+		}
+		common.Trace("LiftChunks %q stored as %d chunks.", sourcename, len(chunks))
+		details.Chunks = chunks
+	}
+}
+
+// shareChunks copies chunk lists to files, which have same content as some
+// chunked file, since only one of those was lifted.
+func shareChunks(tree *Dir) {
+	known := make(map[string][]string)
+	var collect, share func(*Dir)
+	collect = func(dir *Dir) {
+		for _, file := range dir.Files {
+			if len(file.Chunks) > 0 {
+				known[file.Digest] = file.Chunks
+			}
+		}
+		for _, subdir := range dir.Dirs {
+			collect(subdir)
+		}
+	}
+	share = func(dir *Dir) {
+		for _, file := range dir.Files {
+			chunks, ok := known[file.Digest]
+			if ok && len(file.Chunks) == 0 {
+				file.Chunks = chunks
+			}
+		}
+		for _, subdir := range dir.Dirs {
+			share(subdir)
+		}
+	}
+	collect(tree)
+	if len(known) > 0 {
+		share(tree)
+	}
+}
+
+func dropChunks(library Library, chunks []string, sink io.Writer) error {
+	for _, chunk := range chunks {
+		reader, closer, err := library.Open(chunk)
+		if err != nil {
+			return err
+		}
+		size, err := io.Copy(sink, throttled(reader))
+		closer()
+		countDecompressed(size)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package htfs_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/settings"
+)
+
+func TestLargeFilesAreStoredAsSharedChunks(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	config := settings.Global.Holotree()
+	previous := config.ChunkThreshold
+	defer func() {
+		config.ChunkThreshold = previous
+	}()
+	config.ChunkThreshold = 1
+	must.Equal(int64(1024*1024), htfs.ChunkThreshold())
+
+	data := make([]byte, 20*1024*1024)
+	rand.New(rand.NewSource(1035)).Read(data)
+	edited := append([]byte("inserted in front"), data...)
+
+	common.ControllerType = "unittest"
+	blueprint := []byte("chunks: unittest")
+	library := testLibrary(t, map[string]string{
+		"model.bin":  string(data),
+		"copy.bin":   string(data),
+		"edited.bin": string(edited),
+		"small.txt":  "small",
+	})
+	must.Nil(library.Record(blueprint))
+
+	catalogs := htfs.Catalogs()
+	must.Equal(1, len(catalogs))
+	fs, err := htfs.NewRoot(".")
+	must.Nil(err)
+	must.Nil(fs.LoadFrom(filepath.Join(common.HololibCatalogLocation(), catalogs[0])))
+	model := fs.Tree.Files["model.bin"]
+	must.True(len(model.Chunks) > 2)
+	must.Equal(model.Chunks, fs.Tree.Files["copy.bin"].Chunks)
+	wont.True(library.HasBlob(model.Digest))
+	must.Equal(0, len(fs.Tree.Files["small.txt"].Chunks))
+
+	shared := make(map[string]bool)
+	for _, chunk := range model.Chunks {
+		shared[chunk] = true
+		must.True(library.HasBlob(chunk))
+	}
+	overlap := 0
+	for _, chunk := range fs.Tree.Files["edited.bin"].Chunks {
+		if shared[chunk] {
+			overlap += 1
+		}
+	}
+	must.Equal(len(model.Chunks)-1, overlap)
+
+	report, err := htfs.InspectIntegrity()
+	must.Nil(err)
+	must.Equal(0, len(report.Damaged))
+	must.Equal(0, len(report.Missing))
+	must.Equal(0, len(report.Orphans))
+
+	space, err := library.Restore(blueprint, []byte("unittest"), []byte("chunks"))
+	must.Nil(err)
+	restored, err := ioutil.ReadFile(filepath.Join(space, "model.bin"))
+	must.Nil(err)
+	must.True(bytes.Equal(data, restored))
+	restored, err = ioutil.ReadFile(filepath.Join(space, "edited.bin"))
+	must.Nil(err)
+	must.True(bytes.Equal(edited, restored))
+}
@@ -0,0 +1,152 @@
+package htfs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/robocorp/rcc/anywork"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/journal"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+// clonedFiles records digests of files written into clone, so that fixup
+// restore after cloning can trust them.
+type clonedFiles struct {
+	sync.Mutex
+	digests map[string]string
+}
+
+func (it *clonedFiles) cloned(sinkname, digest string) {
+	it.Lock()
+	defer it.Unlock()
+	it.digests[sinkname] = digest
+}
+
+// originDigest is digest of origin file content, with given algorithm.
+func originDigest(origin, algorithm string) (string, error) {
+	source, err := os.Open(origin)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+	digest := newDigest(algorithm)
+	_, err = io.Copy(digest, source)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%02x", digest.Sum(nil)), nil
+}
+
+// reusable tells if origin file still has content catalog says it has.
+// Relocated files have origin path embedded, so their content cannot be
+// verified against catalog digest, and they are never reused.
+func reusable(origin, algorithm string, details *File) bool {
+	if details.Relocated() {
+		return false
+	}
+	info, err := os.Stat(origin)
+	if err != nil || !details.Match(info) {
+		return false
+	}
+	actual, err := originDigest(origin, algorithm)
+	return err == nil && actual == details.Digest
+}
+
+// cloneFile makes sinkname from same file in origin space, as reflink where
+// filesystem supports it, or as hardlink with hardlink restore mode, and
+// otherwise as plain copy. Files changed in origin space (and relocated
+// files) are dropped from library instead.
+func cloneFile(library Library, origin, sinkname, algorithm string, details *File, rewrite *Rewriting, record *clonedFiles) anywork.Work {
+	return func() {
+		defer record.cloned(sinkname, details.Digest)
+		if !reusable(origin, algorithm, details) {
+			common.Trace("* Holotree: clone from library     %q", sinkname)
+			DropFile(library, details.Digest, sinkname, details, rewrite)()
+			return
+		}
+		if RestoreMode() == restoreHardlink {
+			if os.Link(origin, sinkname) == nil {
+				return
+			}
+		}
+		partname := fmt.Sprintf("%s.part%s", sinkname, <-common.Identities)
+		defer os.Remove(partname)
+		err := reflinkFile(origin, partname)
+		if err != nil {
+			os.Remove(partname)
+			anywork.OnErrPanicCloseAll(pathlib.CopyFile(origin, partname, true))
+		}
+		sink, err := os.OpenFile(partname, os.O_RDWR, 0)
+		anywork.OnErrPanicCloseAll(err)
+		anywork.OnErrPanicCloseAll(relocateFile(sink, details, rewrite), sink)
+		anywork.OnErrPanicCloseAll(sink.Close())
+		anywork.OnErrPanicCloseAll(TryRename("clonefile", partname, sinkname))
+		anywork.OnErrPanicCloseAll(os.Chmod(sinkname, details.Mode))
+		anywork.OnErrPanicCloseAll(os.Chtimes(sinkname, motherTime, motherTime))
+		if PreserveAttributes() {
+			anywork.OnErrPanicCloseAll(restoreAttributes(sinkname, details))
+		}
+	}
+}
+
+func cloneFiles(library Library, fs *Root, origin string, record *clonedFiles) Filetask {
+	algorithm := fs.DigestAlgorithm()
+	return func(fullpath string, details *File) anywork.Work {
+		relative, err := filepath.Rel(fs.Path, fullpath)
+		anywork.OnErrPanicCloseAll(err)
+		return cloneFile(library, filepath.Join(origin, relative), fullpath, algorithm, details, fs.Rewrite(), record)
+	}
+}
+
+// CloneSpace creates new space (with given space name, for same controller)
+// from existing space of current controller, reusing its files instead of
+// restoring them from hololib. Metadata of new space is copied from metadata
+// of existing one.
+func CloneSpace(library Library, space, tag string) (targetdir string, err error) {
+	defer fail.Around(&err)
+	defer common.Stopwatch("Holotree clone took:").Debug()
+
+	active := ActiveSpace(space)
+	current := ControllerSpaceName([]byte(common.ControllerIdentity()), []byte(active))
+	source := filepath.Join(common.HolotreeLocation(), fmt.Sprintf("%s.meta", current))
+	fs, err := NewRoot(filepath.Join(common.HolotreeLocation(), current))
+	fail.On(err != nil, "Failed to create root -> %v", err)
+	err = fs.LoadFrom(source)
+	fail.On(err != nil, "Space %q has no metadata (not restored yet?) -> %v", active, err)
+	origin := fs.Path
+
+	name := ControllerSpaceName([]byte(fs.Controller), []byte(tag))
+	metafile := filepath.Join(fs.HolotreeBase(), fmt.Sprintf("%s.meta", name))
+	targetdir = filepath.Join(fs.HolotreeBase(), name)
+	lockfile := filepath.Join(fs.HolotreeBase(), fmt.Sprintf("%s.lck", name))
+	locker, err := pathlib.Locker(lockfile, 30000)
+	fail.On(err != nil, "Could not get lock for %s. Quiting.", targetdir)
+	defer locker.Release()
+	fail.On(pathlib.IsFile(metafile) || !emptyTarget(targetdir), "Space %q already exists at %q.", tag, targetdir)
+
+	common.TimelineBegin("holotree clone start [%q -> %q]", origin, targetdir)
+	defer common.TimelineEnd()
+	journal.Post("space-cloned", metafile, "cloned holotree space from %s with blueprint %s", origin, fs.Blueprint)
+	err = fs.Relocate(targetdir)
+	fail.On(err != nil, "Failed to relocate %s -> %v", targetdir, err)
+	err = fs.Treetop(MakeBranches)
+	fail.On(err != nil, "Failed to make branches -> %v", err)
+	record := &clonedFiles{digests: make(map[string]string)}
+	err = fs.AllFiles(cloneFiles(library, fs, origin, record))
+	fail.On(err != nil, "Failed to clone files -> %v", err)
+	// links, and anything clone missed, are restored as usual
+	score := &stats{}
+	err = fs.AllDirs(RestoreDirectory(library, fs, record.digests, score))
+	fail.On(err != nil, "Failed to restore directories -> %v", err)
+	common.Debug("Holotree clone fixup workload: %d/%d\n", score.dirty, score.total)
+	countRestore(score)
+	fs.Space = tag
+	err = fs.SaveAs(metafile)
+	fail.On(err != nil, "Failed to save metafile %q -> %v", metafile, err)
+	return targetdir, nil
+}
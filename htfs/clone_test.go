@@ -0,0 +1,92 @@
+package htfs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestCanCloneSpaceWithRewrites(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	common.ControllerType = "unittest"
+	blueprint := []byte("clone: unittest")
+	library := testLibrary(t, map[string]string{
+		"plain.txt":   "plain",
+		"changed.txt": "original",
+	})
+	stage := library.Stage()
+	must.Nil(os.MkdirAll(filepath.Join(stage, "bin"), 0o755))
+	must.Nil(ioutil.WriteFile(filepath.Join(stage, "bin", "script"), []byte("#!"+stage+"/bin/python\n"), 0o755))
+	must.Nil(library.Record(blueprint))
+
+	origin, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("origin"))
+	must.Nil(err)
+	must.Nil(ioutil.WriteFile(filepath.Join(origin, "changed.txt"), []byte("changed in origin"), 0o644))
+
+	clone, err := htfs.CloneSpace(library, "origin", "copy")
+	must.Nil(err)
+	wont.Equal(origin, clone)
+	must.Equal(len(origin), len(clone))
+
+	content, err := ioutil.ReadFile(filepath.Join(clone, "bin", "script"))
+	must.Nil(err)
+	must.Equal("#!"+clone+"/bin/python\n", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(origin, "bin", "script"))
+	must.Nil(err)
+	must.Equal("#!"+origin+"/bin/python\n", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(clone, "plain.txt"))
+	must.Nil(err)
+	must.Equal("plain", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(clone, "changed.txt"))
+	must.Nil(err)
+	must.Equal("original", string(content))
+
+	found := false
+	for _, space := range htfs.Spaces() {
+		if space.Path == clone {
+			found = true
+			must.Equal("copy", space.Space)
+			must.Equal(htfs.BlueprintHash(blueprint), space.Blueprint)
+		}
+	}
+	must.True(found)
+
+	_, err = htfs.CloneSpace(library, "origin", "copy")
+	wont.Nil(err)
+	_, err = htfs.CloneSpace(library, "missing", "other")
+	wont.Nil(err)
+}
+
+func TestCloneDoesNotReuseSameSizeModifiedFiles(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	common.ControllerType = "unittest"
+	blueprint := []byte("clone: same size")
+	library := testLibrary(t, map[string]string{
+		"drifted.txt": "original content",
+		"intact.txt":  "intact content",
+	})
+	must.Nil(library.Record(blueprint))
+
+	origin, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("drifting"))
+	must.Nil(err)
+	must.Nil(ioutil.WriteFile(filepath.Join(origin, "drifted.txt"), []byte("ORIGINAL CONTENT"), 0o644))
+
+	clone, err := htfs.CloneSpace(library, "drifting", "fresh")
+	must.Nil(err)
+	content, err := ioutil.ReadFile(filepath.Join(clone, "drifted.txt"))
+	must.Nil(err)
+	must.Equal("original content", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(clone, "intact.txt"))
+	must.Nil(err)
+	must.Equal("intact content", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(origin, "drifted.txt"))
+	must.Nil(err)
+	wont.Equal("original content", string(content))
+}
@@ -0,0 +1,224 @@
+package htfs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/settings"
+)
+
+// Case collision strategies: warn only, fail recording and import, or
+// rename colliding entries when restoring on case-insensitive filesystem.
+const (
+	CollisionWarn   = `warn`
+	CollisionError  = `error`
+	CollisionRename = `rename`
+)
+
+var (
+	caseProbe     sync.Once
+	caseFolding   bool
+	collisionTags = []string{CollisionWarn, CollisionError, CollisionRename}
+)
+
+// CollisionStrategy tells how paths differing only by case are handled.
+func CollisionStrategy() string {
+	strategy := strings.ToLower(strings.TrimSpace(settings.Global.CaseCollisions()))
+	for _, known := range collisionTags {
+		if strategy == known {
+			return strategy
+		}
+	}
+	return CollisionWarn
+}
+
+// CaseInsensitive tells if holotree location is on filesystem, where names
+// differing only by case point to same file. Probed once per process.
+func CaseInsensitive() bool {
+	caseProbe.Do(func() {
+		caseFolding = probeCaseFolding(common.HolotreeLocation())
+	})
+	return caseFolding
+}
+
+func probeCaseFolding(directory string) bool {
+	probe := filepath.Join(directory, fmt.Sprintf("CaseProbe%s.tmp", <-common.Identities))
+	err := ioutil.WriteFile(probe, []byte{}, 0o644)
+	if err != nil {
+		common.Debug("Could not probe case sensitivity of %q -> %v", directory, err)
+		return false
+	}
+	defer os.Remove(probe)
+	_, err = os.Stat(filepath.Join(directory, strings.ToLower(filepath.Base(probe))))
+	return err == nil
+}
+
+func (it *Dir) entryNames() []string {
+	result := make([]string, 0, len(it.Dirs)+len(it.Files)+len(it.Links))
+	for name, _ := range it.Dirs {
+		result = append(result, name)
+	}
+	for name, _ := range it.Files {
+		result = append(result, name)
+	}
+	for name, _ := range it.Links {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// collisionGroups returns entry names of directory grouped by their case
+// folded form, only for groups having more than one name.
+func (it *Dir) collisionGroups() [][]string {
+	groups := make(map[string][]string)
+	folded := []string{}
+	for _, name := range it.entryNames() {
+		key := strings.ToLower(name)
+		if len(groups[key]) == 0 {
+			folded = append(folded, key)
+		}
+		groups[key] = append(groups[key], name)
+	}
+	result := [][]string{}
+	for _, key := range folded {
+		if len(groups[key]) > 1 {
+			result = append(result, groups[key])
+		}
+	}
+	return result
+}
+
+func caseCollisions(prefix string, dir *Dir, result *[]string) {
+	for _, group := range dir.collisionGroups() {
+		for _, name := range group {
+			*result = append(*result, path.Join(prefix, name))
+		}
+	}
+	for name, subdir := range dir.Dirs {
+		caseCollisions(path.Join(prefix, name), subdir, result)
+	}
+}
+
+// CaseCollisions lists paths (relative, slash separated) of tree, which
+// differ only by case from some sibling entry.
+func (it *Root) CaseCollisions() []string {
+	result := []string{}
+	caseCollisions("", it.Tree, &result)
+	sort.Strings(result)
+	return result
+}
+
+func (it *Dir) freeName(name string) string {
+	taken := make(map[string]bool)
+	for _, existing := range it.entryNames() {
+		taken[strings.ToLower(existing)] = true
+	}
+	for counter := 2; ; counter++ {
+		candidate := fmt.Sprintf("%s~%d", name, counter)
+		if !taken[strings.ToLower(candidate)] {
+			return candidate
+		}
+	}
+}
+
+func (it *Dir) rename(name, newname string) {
+	if dir, ok := it.Dirs[name]; ok {
+		delete(it.Dirs, name)
+		dir.Name = newname
+		it.Dirs[newname] = dir
+	}
+	if file, ok := it.Files[name]; ok {
+		delete(it.Files, name)
+		file.Name = newname
+		it.Files[newname] = file
+	}
+	if link, ok := it.Links[name]; ok {
+		delete(it.Links, name)
+		link.Name = newname
+		it.Links[newname] = link
+	}
+}
+
+func renameCollisions(prefix string, dir *Dir, renamed map[string]string) {
+	for _, group := range dir.collisionGroups() {
+		for _, name := range group[1:] {
+			newname := dir.freeName(name)
+			dir.rename(name, newname)
+			renamed[path.Join(prefix, name)] = path.Join(prefix, newname)
+		}
+	}
+	for name, subdir := range dir.Dirs {
+		renameCollisions(path.Join(prefix, name), subdir, renamed)
+	}
+}
+
+// RenameCollisions renames entries colliding by case, so that first one (in
+// sorted order) keeps its name, and others get "~2", "~3", ... suffix.
+// Returns mapping from original to new relative paths.
+func (it *Root) RenameCollisions() map[string]string {
+	renamed := make(map[string]string)
+	renameCollisions("", it.Tree, renamed)
+	return renamed
+}
+
+func collisionMessage(collisions []string) string {
+	return fmt.Sprintf("%d path(s) differ only by case, and will lose files on case-insensitive filesystems: %s", len(collisions), strings.Join(collisions, ", "))
+}
+
+// guardRecordCollisions fails recording with "error" strategy, when tree
+// has case collisions. Otherwise they are only noted in debug output, since
+// they are normal on Linux (like terminfo entries), and matter only when
+// restoring on case-insensitive filesystem.
+func guardRecordCollisions(fs *Root) error {
+	collisions := fs.CaseCollisions()
+	if len(collisions) == 0 {
+		return nil
+	}
+	if CollisionStrategy() == CollisionError {
+		return fmt.Errorf("Recording %s", collisionMessage(collisions))
+	}
+	common.Debug("Recorded catalog has %s", collisionMessage(collisions))
+	return nil
+}
+
+// guardImportCollisions refuses catalogs with case collisions when local
+// filesystem is case-insensitive, unless they will be renamed on restore.
+func guardImportCollisions(fs *Root, name string) error {
+	collisions := fs.CaseCollisions()
+	if len(collisions) == 0 || !CaseInsensitive() {
+		return nil
+	}
+	if CollisionStrategy() == CollisionRename {
+		pretty.Warning("Catalog %q has %s; they will be renamed on restore.", name, collisionMessage(collisions))
+		return nil
+	}
+	return fmt.Errorf("Catalog %q has %s. Use \"case-collisions: rename\" setting to import it anyway.", name, collisionMessage(collisions))
+}
+
+// resolveRestoreCollisions renames colliding entries before restore on
+// case-insensitive filesystem, when "rename" strategy is used.
+func resolveRestoreCollisions(fs *Root) {
+	if !CaseInsensitive() {
+		return
+	}
+	collisions := fs.CaseCollisions()
+	if len(collisions) == 0 {
+		return
+	}
+	if CollisionStrategy() != CollisionRename {
+		pretty.Warning("Restored space has %s", collisionMessage(collisions))
+		return
+	}
+	for original, renamed := range fs.RenameCollisions() {
+		common.Debug("Case collision %q restored as %q.", original, renamed)
+	}
+}
@@ -0,0 +1,45 @@
+package htfs_test
+
+import (
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/settings"
+)
+
+func TestCaseCollisionsAreDetectedAndRenamed(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	config := settings.Global.Holotree()
+	previous := config.CaseCollisions
+	defer func() {
+		config.CaseCollisions = previous
+	}()
+
+	library := testLibrary(t, map[string]string{
+		"terminfo/E/Eterm": "upper",
+		"terminfo/e/eterm": "lower",
+		"Readme":           "readme",
+		"README":           "README",
+		"readme~2":         "taken",
+	})
+
+	config.CaseCollisions = htfs.CollisionError
+	must.Equal(htfs.CollisionError, htfs.CollisionStrategy())
+	wont.Nil(library.Record([]byte("collisions: error")))
+	config.CaseCollisions = "bogus"
+	must.Equal(htfs.CollisionWarn, htfs.CollisionStrategy())
+	must.Nil(library.Record([]byte("collisions: warn")))
+
+	fs, err := htfs.NewRoot(library.Stage())
+	must.Nil(err)
+	must.Nil(fs.Lift())
+	must.Equal([]string{"README", "Readme", "terminfo/E", "terminfo/e"}, fs.CaseCollisions())
+	renamed := fs.RenameCollisions()
+	must.Equal(2, len(renamed))
+	must.Equal("Readme~3", renamed["Readme"])
+	must.Equal("terminfo/e~2", renamed["terminfo/e"])
+	must.Equal(0, len(fs.CaseCollisions()))
+	must.Equal("Readme~3", fs.Tree.Files["Readme~3"].Name)
+}
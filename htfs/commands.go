@@ -1,6 +1,8 @@
 package htfs
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -11,25 +13,27 @@ import (
 	"github.com/robocorp/rcc/conda"
 	"github.com/robocorp/rcc/fail"
 	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/plugins"
+	"github.com/robocorp/rcc/pretty"
 	"github.com/robocorp/rcc/robot"
 	"github.com/robocorp/rcc/xviper"
 )
 
-func NewEnvironment(condafile, holozip string, restore, force bool) (label string, scorecard common.Scorecard, err error) {
+func NewEnvironment(condafile, holozip string, restore, force bool, robotSettings *robot.Settings) (label string, scorecard common.Scorecard, err error) {
 	defer fail.Around(&err)
 
 	defer common.Progress(13, "Fresh holotree done [with %d workers].", anywork.Scale())
 	common.Progress(1, "Fresh holotree environment %v.", xviper.TrackingIdentity())
 
 	callback := pathlib.LockWaitMessage("Serialized environment creation")
-	locker, err := pathlib.Locker(common.HolotreeLock(), 30000)
+	locker, err := pathlib.Locker(common.StageLock(), 30000)
 	callback()
-	fail.On(err != nil, "Could not get lock for holotree. Quiting.")
+	fail.On(err != nil, "%w", common.WithCode(common.CodeHoloLock, errors.New("Could not get lock for holotree. Quiting.")))
 	defer locker.Release()
 
 	haszip := len(holozip) > 0
 
-	_, holotreeBlueprint, err := ComposeFinalBlueprint([]string{condafile}, "")
+	holotreeBlueprint, err := composeBlueprint([]string{condafile}, []string{condafile}, robotSettings)
 	fail.On(err != nil, "%s", err)
 	common.EnvironmentHash = BlueprintHash(holotreeBlueprint)
 	common.Progress(2, "Holotree blueprint is %q.", common.EnvironmentHash)
@@ -37,6 +41,10 @@ func NewEnvironment(condafile, holozip string, restore, force bool) (label strin
 	tree, err := New()
 	fail.On(err != nil, "%s", err)
 
+	if !haszip {
+		PullSharedBlueprint(tree, holotreeBlueprint)
+	}
+
 	if !haszip && !tree.HasBlueprint(holotreeBlueprint) && common.Liveonly {
 		tree = Virtual()
 		common.Timeline("downgraded to virtual holotree library")
@@ -49,29 +57,76 @@ func NewEnvironment(condafile, holozip string, restore, force bool) (label strin
 		common.Timeline("downgraded to holotree zip library")
 	} else {
 		scorecard.Start()
-		err = RecordEnvironment(tree, holotreeBlueprint, force, scorecard)
-		fail.On(err != nil, "%s", err)
+		err = RecordEnvironment(tree, holotreeBlueprint, force, scorecard, robotSettings)
+		fail.On(err != nil, "%w", err)
 		library = tree
 	}
 
 	path := ""
 	if restore {
 		common.Progress(12, "Restore space from library [with %d workers].", anywork.Scale())
-		path, err = library.Restore(holotreeBlueprint, []byte(common.ControllerIdentity()), []byte(common.HolotreeSpace))
-		fail.On(err != nil, "Failed to restore blueprint %q, reason: %v", string(holotreeBlueprint), err)
+		space, alias := RestoreTarget(common.HolotreeSpace, common.EnvironmentHash)
+		path, err = library.Restore(holotreeBlueprint, []byte(common.ControllerIdentity()), []byte(space))
+		fail.On(err != nil, "%w", common.WithCode(common.CodeHoloRestoration, fmt.Errorf("Failed to restore blueprint %q, reason: %v", string(holotreeBlueprint), err)))
+		if alias != nil {
+			err = alias.Staging(space)
+			fail.On(err != nil, "%v", err)
+			err = alias.Switch()
+			fail.On(err != nil, "%v", err)
+			common.Log("Space %q now uses slot %q.", alias.Space, alias.Active)
+		}
 	} else {
 		common.Progress(12, "Restoring space skipped.")
 	}
 	return path, scorecard, nil
 }
 
+// EnvironmentCatalog makes sure that environment from condafile is recorded
+// into hololib (without restoring it into any space), and returns library
+// and catalog of that environment.
+func EnvironmentCatalog(condafile string) (library Library, catalog string, err error) {
+	defer fail.Around(&err)
+
+	_, _, err = NewEnvironment(condafile, "", false, false, &robot.Settings{})
+	fail.On(err != nil, "%w", err)
+	tree, err := New()
+	fail.On(err != nil, "%v", err)
+	local, ok := tree.(*hololib)
+	fail.On(!ok, "Holotree library does not have catalogs.")
+	catalog = local.CatalogPath(common.EnvironmentHash)
+	fail.On(!pathlib.IsFile(catalog), "%w", common.WithCode(common.CodeHoloCatalog, fmt.Errorf("Catalog %q for %q does not exist.", catalog, condafile)))
+	return local, catalog, nil
+}
+
 func CleanupHolotreeStage(tree MutableLibrary) error {
 	common.Timeline("holotree stage removal start")
 	defer common.Timeline("holotree stage removal done")
 	return TryRemoveAll("stage", tree.Stage())
 }
 
-func RecordEnvironment(tree MutableLibrary, blueprint []byte, force bool, scorecard common.Scorecard) (err error) {
+// runBuildHooks runs build hooks from settings, and then ones from robot.yaml.
+// Build hooks always run outside of environment, in robot directory.
+func runBuildHooks(stage string, context plugins.Context, robotSettings *robot.Settings) error {
+	err := plugins.RunHooks(stage, context)
+	if err != nil {
+		return err
+	}
+	var environment []string
+	if stage == plugins.PostBuild {
+		environment = toolCacheEnvironment(fmt.Sprintf("%v", context["stage"]), robotSettings.ToolCaches)
+	}
+	hooks := make([]*plugins.RobotHook, 0, len(robotSettings.BuildHooks[stage]))
+	for _, command := range robotSettings.BuildHooks[stage] {
+		hook, err := plugins.NewRobotHook(command, robotSettings.Directory, environment)
+		if err != nil {
+			return err
+		}
+		hooks = append(hooks, hook)
+	}
+	return plugins.RunRobotHooks(stage, hooks, context)
+}
+
+func RecordEnvironment(tree MutableLibrary, blueprint []byte, force bool, scorecard common.Scorecard, robotSettings *robot.Settings) (err error) {
 	defer fail.Around(&err)
 
 	// following must be setup here
@@ -85,8 +140,15 @@ func RecordEnvironment(tree MutableLibrary, blueprint []byte, force bool, scorec
 	common.Debug("Holotree stage is %q.", tree.Stage())
 	exists := tree.HasBlueprint(blueprint)
 	common.Debug("Has blueprint environment: %v", exists)
+	countCatalog(exists)
 
 	if force || !exists {
+		key := BlueprintHash(blueprint)
+		if !force {
+			err = CheckBlueprintFailure(key)
+			fail.On(err != nil, "%v", err)
+		}
+
 		common.Progress(3, "Cleanup holotree stage for fresh install.")
 		err = CleanupHolotreeStage(tree)
 		fail.On(err != nil, "Failed to clean stage, reason %v.", err)
@@ -98,14 +160,31 @@ func RecordEnvironment(tree MutableLibrary, blueprint []byte, force bool, scorec
 		identityfile := filepath.Join(tree.Stage(), "identity.yaml")
 		err = ioutil.WriteFile(identityfile, blueprint, 0o644)
 		fail.On(err != nil, "Failed to save %q, reason %w.", identityfile, err)
-		err = conda.LegacyEnvironment(force, identityfile)
+		context := plugins.Context{"blueprint": key, "identity": identityfile, "stage": tree.Stage()}
+		err = runBuildHooks(plugins.PreBuild, context, robotSettings)
+		fail.On(err != nil, "Environment build blocked by hook: %v", err)
+		err = conda.LegacyEnvironment(force, robotSettings.Solver, EnvironmentLayers(tree), identityfile)
+		context["success"] = err == nil
+		if hookErr := runBuildHooks(plugins.PostBuild, context, robotSettings); hookErr != nil {
+			pretty.Warning("%v", hookErr)
+		}
+		if err != nil {
+			common.Error("blueprint failure", RecordBlueprintFailure(key, err))
+		}
 		fail.On(err != nil, "Failed to create environment, reason %w.", err)
+		ForgetBlueprintFailure(key)
+		err = checkEnvironmentBudget(tree.Stage(), robotSettings)
+		fail.On(err != nil, "%w", err)
 
 		scorecard.Midpoint()
 
 		common.Progress(11, "Record holotree stage to hololib [with %d workers].", anywork.Scale())
+		phase := pretty.StartPhase(pretty.PhaseRecord, "Recording stage into hololib.")
 		err = tree.Record(blueprint)
+		elapsed := phase.Done()
 		fail.On(err != nil, "Failed to record blueprint %q, reason: %w", string(blueprint), err)
+		conda.ObserveRecording(elapsed, tree.Stage())
+		PushSharedBlueprint(tree, blueprint)
 	}
 
 	return nil
@@ -136,6 +215,7 @@ func RemoveHolotreeSpace(label string) (err error) {
 			continue
 		}
 		TryRemove("metafile", metafile)
+		os.Remove(restoreMarker(directory))
 		err = TryRemoveAll("space", directory)
 		fail.On(err != nil, "Problem removing %q, reason: %s.", directory, err)
 	}
@@ -158,15 +238,36 @@ func RobotBlueprints(userBlueprints []string, packfile string) (robot.Robot, []s
 	return config, append(blueprints, userBlueprints...)
 }
 
+// robotActivation adds activation hooks of robot.yaml into environment.
+func robotActivation(environment *conda.Environment, robotSettings *robot.Settings) {
+	environment.PreActivate = append(environment.PreActivate, robotSettings.PreActivate...)
+	environment.PostActivate = append(environment.PostActivate, robotSettings.PostActivate...)
+}
+
 func ComposeFinalBlueprint(userFiles []string, packfile string) (config robot.Robot, blueprint []byte, err error) {
+	config, filenames := RobotBlueprints(userFiles, packfile)
+	blueprint, err = composeBlueprint(userFiles, filenames, robot.SettingsOf(config))
+	return config, blueprint, err
+}
+
+func composeBlueprint(userFiles, filenames []string, robotSettings *robot.Settings) (blueprint []byte, err error) {
 	defer fail.Around(&err)
 
 	var left, right *conda.Environment
 
-	config, filenames := RobotBlueprints(userFiles, packfile)
+	// lockfile is complete environment, so robot conda.yaml is not used
+	if len(userFiles) == 1 && conda.IsLockfile(userFiles[0]) {
+		blueprint, err = conda.LockfileBlueprint(userFiles[0])
+		fail.On(err != nil, "Failure: %v", err)
+		noteSourceDigest(blueprint, userFiles)
+		noteLockfileDigest(blueprint, userFiles[0])
+		return blueprint, nil
+	}
 
 	for _, filename := range filenames {
 		left = right
+		err = conda.ValidateCondaYaml(filename)
+		fail.On(err != nil, "Failure: %v", err)
 		right, err = conda.ReadCondaYaml(filename)
 		fail.On(err != nil, "Failure: %v", err)
 		if left == nil {
@@ -176,7 +277,10 @@ func ComposeFinalBlueprint(userFiles []string, packfile string) (config robot.Ro
 		fail.On(err != nil, "Failure: %v", err)
 	}
 	fail.On(right == nil, "Missing environment specification(s).")
+	robotActivation(right, robotSettings)
 	content, err := right.AsYaml()
 	fail.On(err != nil, "YAML error: %v", err)
-	return config, []byte(strings.TrimSpace(content)), nil
+	blueprint = []byte(strings.TrimSpace(content))
+	noteSourceDigest(blueprint, filenames)
+	return blueprint, nil
 }
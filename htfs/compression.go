@@ -0,0 +1,190 @@
+package htfs
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+)
+
+const (
+	defaultCodec = `gzip`
+	magicLength  = 4
+)
+
+type (
+	codecWriter func(io.Writer) (io.WriteCloser, error)
+	codecReader func(io.Reader) (io.ReadCloser, error)
+
+	// codec is one compression format for hololib blobs. Codecs are detected
+	// from magic bytes on read, so library can contain blobs of mixed codecs.
+	codec struct {
+		name   string
+		magic  []byte
+		writer codecWriter
+		reader codecReader
+	}
+)
+
+var (
+	codecs = map[string]*codec{}
+)
+
+func init() {
+	registerCodec(`gzip`, []byte{0x1f, 0x8b}, gzipWriter, gzipReader)
+	registerCodec(`zstd`, []byte{0x28, 0xb5, 0x2f, 0xfd}, zstdWriter, zstdReader)
+	registerCodec(`none`, nil, plainWriter, plainReader)
+}
+
+func registerCodec(name string, magic []byte, writer codecWriter, reader codecReader) {
+	codecs[name] = &codec{
+		name:   name,
+		magic:  magic,
+		writer: writer,
+		reader: reader,
+	}
+}
+
+type plainSink struct {
+	io.Writer
+}
+
+func (it plainSink) Close() error {
+	return nil
+}
+
+// plain blobs are stored as is, so that they can be linked into spaces
+func plainWriter(sink io.Writer) (io.WriteCloser, error) {
+	return plainSink{sink}, nil
+}
+
+func plainReader(source io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(source), nil
+}
+
+func gzipWriter(sink io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(sink, gzip.BestSpeed)
+}
+
+func gzipReader(source io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(source)
+}
+
+func zstdWriter(sink io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(sink, zstd.WithEncoderLevel(zstd.SpeedFastest))
+}
+
+func zstdReader(source io.Reader) (io.ReadCloser, error) {
+	decoder, err := zstd.NewReader(source, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return decoder.IOReadCloser(), nil
+}
+
+// Codecs lists names of codecs usable for writing new blobs.
+func Codecs() []string {
+	result := make([]string, 0, len(codecs))
+	for name := range codecs {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// BlobCodec is name of codec for new blobs, from command line flag first,
+// then from settings, and defaulting to gzip.
+func BlobCodec() string {
+	name := strings.TrimSpace(common.BlobCompression)
+	if len(name) == 0 {
+		name = strings.TrimSpace(settings.Global.Compression())
+	}
+	if len(name) == 0 {
+		return defaultCodec
+	}
+	return strings.ToLower(name)
+}
+
+func selectedCodec() (*codec, error) {
+	name := BlobCodec()
+	found, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown hololib compression %q, available are: %s", name, strings.Join(Codecs(), ", "))
+	}
+	return found, nil
+}
+
+// ValidCompression verifies that selected codec can be used for new blobs.
+func ValidCompression() error {
+	_, err := selectedCodec()
+	return err
+}
+
+// compressingWriter uses selected codec, except when that stores content
+// as is, and content itself starts with magic of some codec (like .gz file
+// would), since then it could not be told apart on read. Such content gets
+// compressed with default codec instead. When encryption is configured,
+// compressed content is also encrypted.
+func compressingWriter(sink io.Writer, source *bufio.Reader) (io.WriteCloser, error) {
+	selected, err := selectedCodec()
+	if err != nil {
+		return nil, err
+	}
+	if len(selected.magic) == 0 && (detectCodec(source) != nil || isEncrypted(source)) {
+		selected = codecs[defaultCodec]
+	}
+	if !BlobEncryption() {
+		return selected.writer(sink)
+	}
+	encrypted, err := newEncryptingWriter(sink)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := selected.writer(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	return layeredWriter{writer, encrypted}, nil
+}
+
+func detectCodec(source *bufio.Reader) *codec {
+	magic, _ := source.Peek(magicLength)
+	for _, candidate := range codecs {
+		if len(candidate.magic) > 0 && bytes.HasPrefix(magic, candidate.magic) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// decompressingReader detects encryption and codec from magic bytes of
+// source, and returns reader for decrypted and decompressed content. Content
+// without known magic is returned as is, with compressed flag false.
+func decompressingReader(source io.Reader) (reader io.ReadCloser, compressed bool, err error) {
+	buffered := bufio.NewReader(source)
+	encrypted := isEncrypted(buffered)
+	if encrypted {
+		decrypted, err := newDecryptingReader(buffered)
+		if err != nil {
+			return nil, true, err
+		}
+		buffered = bufio.NewReader(decrypted)
+	}
+	candidate := detectCodec(buffered)
+	if candidate == nil {
+		return ioutil.NopCloser(buffered), encrypted, nil
+	}
+	reader, err = candidate.reader(buffered)
+	if err != nil {
+		return nil, true, err
+	}
+	return reader, true, nil
+}
@@ -0,0 +1,77 @@
+package htfs_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestCanSelectAndDetectBlobCompression(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	original := common.BlobCompression
+	defer func() {
+		common.BlobCompression = original
+	}()
+
+	must.Equal([]string{"gzip", "none", "zstd"}, htfs.Codecs())
+
+	common.BlobCompression = "zstd"
+	must.Nil(htfs.ValidCompression())
+	common.BlobCompression = "bogus"
+	wont.Nil(htfs.ValidCompression())
+	common.BlobCompression = "GZIP"
+	must.Nil(htfs.ValidCompression())
+	must.Equal("gzip", htfs.BlobCodec())
+
+	directory, err := ioutil.TempDir("", "rcc_compression")
+	must.Nil(err)
+	defer os.RemoveAll(directory)
+
+	content := []byte("hello, compressed hololib world\n")
+	expected := fmt.Sprintf("%02x", sha256.Sum256(content))
+	source := filepath.Join(directory, "source.txt")
+	must.Nil(ioutil.WriteFile(source, content, 0o644))
+
+	blob := filepath.Join(directory, "blob")
+	htfs.LiftFile(source, blob)()
+	lifted, err := ioutil.ReadFile(blob)
+	must.Nil(err)
+	must.Equal([]byte{0x1f, 0x8b}, lifted[:2])
+
+	details := &htfs.File{Name: "blob"}
+	htfs.Hasher(map[string]map[string]bool{"blob": nil})(blob, details)()
+	must.Equal(expected, details.Digest)
+
+	plain := &htfs.File{Name: "source.txt"}
+	htfs.Hasher(map[string]map[string]bool{"source.txt": nil})(source, plain)()
+	must.Equal(expected, plain.Digest)
+
+	common.BlobCompression = "zstd"
+	zstd := filepath.Join(directory, "zstd")
+	htfs.LiftFile(source, zstd)()
+	lifted, err = ioutil.ReadFile(zstd)
+	must.Nil(err)
+	must.Equal([]byte{0x28, 0xb5, 0x2f, 0xfd}, lifted[:4])
+
+	packed := &htfs.File{Name: "zstd"}
+	htfs.Hasher(map[string]map[string]bool{"zstd": nil})(zstd, packed)()
+	must.Equal(expected, packed.Digest)
+
+	frame := []byte{0x28, 0xb5, 0x2f, 0xfd, 0, 0}
+	odd := filepath.Join(directory, "odd.zst")
+	must.Nil(ioutil.WriteFile(odd, frame, 0o644))
+	stored := filepath.Join(directory, "stored")
+	common.BlobCompression = "none"
+	htfs.LiftFile(odd, stored)()
+	unknown := &htfs.File{Name: "stored"}
+	htfs.Hasher(map[string]map[string]bool{"stored": nil})(stored, unknown)()
+	must.Equal(fmt.Sprintf("%02x", sha256.Sum256(frame)), unknown.Digest)
+}
@@ -1,7 +1,6 @@
 package htfs
 
 import (
-	"compress/gzip"
 	"io"
 	"os"
 
@@ -14,17 +13,24 @@ func delegateOpen(it MutableLibrary, digest string, ungzip bool) (readable io.Re
 	filename := it.ExactLocation(digest)
 	source, err := os.Open(filename)
 	fail.On(err != nil, "Failed to open %q -> %v", filename, err)
+	return storedReader(source, filename, digest, ungzip)
+}
+
+// storedReader unwraps blob content from its stored form.
+func storedReader(source io.ReadCloser, filename, digest string, ungzip bool) (readable io.Reader, closer Closer, err error) {
+	defer fail.Around(&err)
 
-	var reader io.ReadCloser
-	reader, err = gzip.NewReader(source)
-	if err != nil || !ungzip {
-		_, err = source.Seek(0, 0)
-		fail.On(err != nil, "Failed to seek %q -> %v", filename, err)
-		reader = source
+	if !ungzip {
+		return source, source.Close, nil
 	}
+	reader, _, err := decompressingReader(source)
+	if err != nil {
+		source.Close()
+	}
+	fail.On(err != nil, "Failed to open %q -> %v", filename, err)
 	closer = func() error {
 		reader.Close()
 		return source.Close()
 	}
-	return reader, closer, nil
+	return verifiedReader(reader, digest), closer, nil
 }
@@ -0,0 +1,101 @@
+package htfs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/robocorp/rcc/fail"
+)
+
+// DeltaReport tells what went into delta bundle, and how many blobs were
+// left out, since receiving side (by its manifest) already has them.
+type DeltaReport struct {
+	Catalogs []string `json:"catalogs"`
+	Blobs    int      `json:"blobs"`
+	Skipped  int      `json:"skipped"`
+}
+
+// DeltaManifest lists digests of blobs, which catalogs of local hololib refer
+// to, and which really are in hololib. Other machine can then export delta
+// bundle without those blobs.
+func DeltaManifest() (digests []string, err error) {
+	defer fail.Around(&err)
+
+	store, err := hololibStore()
+	fail.On(err != nil, "%v", err)
+	digests = []string{}
+	for digest, _ := range LoadHololibHashes() {
+		if store.Has(digest) {
+			digests = append(digests, digest)
+		}
+	}
+	sort.Strings(digests)
+	return digests, nil
+}
+
+// WriteDeltaManifest writes manifest of local hololib, one digest per line.
+func WriteDeltaManifest(filename string) (int, error) {
+	digests, err := DeltaManifest()
+	if err != nil {
+		return 0, err
+	}
+	sink, err := os.Create(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer sink.Close()
+	for _, digest := range digests {
+		_, err = fmt.Fprintln(sink, digest)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(digests), sink.Sync()
+}
+
+// LoadDeltaManifest reads manifest written by other machine. Empty lines and
+// lines starting with "#" are ignored, but anything else must be digest.
+func LoadDeltaManifest(filename string) (known map[string]bool, err error) {
+	defer fail.Around(&err)
+
+	source, err := os.Open(filename)
+	fail.On(err != nil, "Could not open manifest %q -> %v", filename, err)
+	defer source.Close()
+	known = make(map[string]bool)
+	scanner := bufio.NewScanner(source)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fail.On(!digestPattern.MatchString(line), "Manifest %q line %d is not a digest: %q", filename, number, line)
+		known[line] = true
+	}
+	fail.On(scanner.Err() != nil, "Could not read manifest %q -> %v", filename, scanner.Err())
+	return known, nil
+}
+
+// ExportDelta is like export, but leaves out blobs which are known to
+// receiving side. Resulting bundle is imported with normal import, which
+// takes missing blobs from local hololib.
+func ExportDelta(library MutableLibrary, catalogs []string, known map[string]bool, archive string) (report *DeltaReport, err error) {
+	defer fail.Around(&err)
+
+	local, ok := library.(*hololib)
+	fail.On(!ok, "Delta export requires local hololib.")
+	zipper, err := local.export(catalogs, nil, known, archive)
+	fail.On(err != nil, "%v", err)
+	report = &DeltaReport{
+		Catalogs: catalogs,
+		Skipped:  zipper.skipped,
+	}
+	for name, _ := range zipper.seen {
+		if strings.HasPrefix(name, libraryFolder+"/") && !zipper.known[name] {
+			report.Blobs += 1
+		}
+	}
+	return report, nil
+}
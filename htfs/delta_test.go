@@ -0,0 +1,75 @@
+package htfs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestDeltaBundlesCarryOnlyBlobsMissingFromManifest(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	folder := t.TempDir()
+	first := []byte("delta: first")
+	second := []byte("delta: second")
+	library := testLibrary(t, map[string]string{
+		"shared.txt": "shared content",
+		"first.txt":  "first content",
+	})
+	must.Nil(library.Record(first))
+	testStage(t, library, map[string]string{
+		"shared.txt": "shared content",
+		"second.txt": "second content",
+	})
+	must.Nil(library.Record(second))
+	sender := os.Getenv(common.ROBOCORP_HOME_VARIABLE)
+	firstCatalog := htfs.CatalogName(htfs.BlueprintHash(first))
+	secondCatalog := htfs.CatalogName(htfs.BlueprintHash(second))
+	full := filepath.Join(folder, "full.zip")
+	must.Nil(library.Export([]string{firstCatalog}, []string{}, full))
+
+	receiver := filepath.Join(folder, "receiver")
+	manifest := filepath.Join(folder, "receiver.manifest")
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, receiver)
+	_, err := htfs.ImportBundle(full)
+	must.Nil(err)
+	count, err := htfs.WriteDeltaManifest(manifest)
+	must.Nil(err)
+	must.Equal(2, count)
+
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, sender)
+	known, err := htfs.LoadDeltaManifest(manifest)
+	must.Nil(err)
+	must.Equal(2, len(known))
+	delta := filepath.Join(folder, "delta.zip")
+	report, err := htfs.ExportDelta(library, []string{secondCatalog}, known, delta)
+	must.Nil(err)
+	must.Equal(1, report.Blobs)
+	must.Equal(1, report.Skipped)
+
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, filepath.Join(folder, "stranger"))
+	_, err = htfs.ImportBundle(delta)
+	wont.Nil(err)
+
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, receiver)
+	imported, err := htfs.ImportBundle(delta)
+	must.Nil(err)
+	must.Equal([]string{secondCatalog}, imported.Imported)
+	must.Equal(1, imported.Blobs)
+	restorer, err := htfs.New()
+	must.Nil(err)
+	path, err := restorer.Restore(second, []byte(common.ControllerIdentity()), []byte("delta"))
+	must.Nil(err)
+	content, err := ioutil.ReadFile(filepath.Join(path, "shared.txt"))
+	must.Nil(err)
+	must.Equal("shared content", string(content))
+
+	must.Nil(ioutil.WriteFile(manifest, []byte("# comment\nnot-a-digest\n"), 0o644))
+	_, err = htfs.LoadDeltaManifest(manifest)
+	wont.Nil(err)
+}
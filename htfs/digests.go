@@ -0,0 +1,152 @@
+package htfs
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/robocorp/rcc/blake3"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+)
+
+// Catalogs tell which digest algorithm their file digests use, but blob
+// names (and so digests) look same for all algorithms. So when blob is
+// checked against its name, SHA-256 is tried first, and BLAKE3 only when
+// that did not match.
+const (
+	digestSha256 = `sha256`
+	digestBlake3 = `blake3`
+)
+
+var (
+	// set when catalog using BLAKE3 has been loaded in this process
+	blake3Seen int32
+)
+
+// DigestAlgorithm is algorithm for new catalogs, from command line flag
+// first, then from settings, and defaulting to sha256.
+func DigestAlgorithm() string {
+	name := strings.TrimSpace(common.BlobDigest)
+	if len(name) == 0 {
+		name = strings.TrimSpace(settings.Global.DigestAlgorithm())
+	}
+	if strings.ToLower(name) == digestBlake3 {
+		return digestBlake3
+	}
+	return digestSha256
+}
+
+// ValidDigestAlgorithm verifies that selected digest algorithm is known.
+func ValidDigestAlgorithm() error {
+	name := strings.ToLower(strings.TrimSpace(common.BlobDigest))
+	if len(name) == 0 {
+		name = strings.ToLower(strings.TrimSpace(settings.Global.DigestAlgorithm()))
+	}
+	switch name {
+	case "", digestSha256, digestBlake3:
+		return nil
+	default:
+		return fmt.Errorf("Unknown digest algorithm %q, available are: %s, %s", name, digestBlake3, digestSha256)
+	}
+}
+
+func newDigest(algorithm string) hash.Hash {
+	if algorithm == digestBlake3 {
+		return blake3.New()
+	}
+	return sha256.New()
+}
+
+func noteAlgorithm(algorithm string) {
+	if algorithm == digestBlake3 {
+		atomic.StoreInt32(&blake3Seen, 1)
+	}
+}
+
+// activeAlgorithms are those, that blobs read in this process might use.
+func activeAlgorithms() []string {
+	if DigestAlgorithm() == digestBlake3 || atomic.LoadInt32(&blake3Seen) == 1 {
+		return []string{digestSha256, digestBlake3}
+	}
+	return []string{digestSha256}
+}
+
+// DigestAlgorithm of catalog, where empty (older catalogs) means sha256.
+func (it *Root) DigestAlgorithm() string {
+	if it.Algorithm == digestBlake3 {
+		return digestBlake3
+	}
+	return digestSha256
+}
+
+// blobContentDigest is digest of decompressed (and decrypted) blob content.
+// With raw, content that cannot be decompressed is hashed as is.
+func blobContentDigest(filename, algorithm string, raw bool) (string, error) {
+	source, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+	reader, _, err := decompressingReader(source)
+	if err != nil && !raw {
+		return "", err
+	}
+	if err != nil {
+		_, err = source.Seek(0, 0)
+		if err != nil {
+			return "", err
+		}
+		reader = source
+	}
+	defer reader.Close()
+	digest := newDigest(algorithm)
+	_, err = io.Copy(digest, reader)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%02x", digest.Sum(nil)), nil
+}
+
+// storedMatchingDigest is like matchingDigest, but for blob read through
+// blob store, so that blobs which are not files can be verified too.
+func storedMatchingDigest(store BlobStore, expected string) (string, error) {
+	source, err := store.Open(expected)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+	reader, _, err := decompressingReader(source)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	primary, secondary := newDigest(digestSha256), newDigest(digestBlake3)
+	_, err = io.Copy(io.MultiWriter(primary, secondary), reader)
+	if err != nil {
+		return "", err
+	}
+	actual := fmt.Sprintf("%02x", primary.Sum(nil))
+	if other := fmt.Sprintf("%02x", secondary.Sum(nil)); other == expected {
+		return other, nil
+	}
+	return actual, nil
+}
+
+// matchingDigest returns expected digest, if blob content matches it with
+// any algorithm, and otherwise SHA-256 of content.
+func matchingDigest(filename, expected string, raw bool) (string, error) {
+	actual, err := blobContentDigest(filename, digestSha256, raw)
+	if err != nil || actual == expected || !isDigestName(expected) {
+		return actual, err
+	}
+	other, err := blobContentDigest(filename, digestBlake3, raw)
+	if err == nil && other == expected {
+		return other, nil
+	}
+	return actual, nil
+}
@@ -0,0 +1,68 @@
+package htfs_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/blake3"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/settings"
+)
+
+func TestCanRecordCatalogsWithBlake3(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	config := settings.Global.Holotree()
+	verify, digest := config.VerifyBlobs, common.BlobDigest
+	defer func() {
+		config.VerifyBlobs, common.BlobDigest = verify, digest
+	}()
+
+	common.BlobDigest = "bogus"
+	wont.Nil(htfs.ValidDigestAlgorithm())
+	common.BlobDigest = "BLAKE3"
+	must.Nil(htfs.ValidDigestAlgorithm())
+	must.Equal("blake3", htfs.DigestAlgorithm())
+
+	common.ControllerType = "unittest"
+	library := testLibrary(t, nil)
+	content := []byte("content hashed with blake3")
+	for _, algorithm := range []string{"blake3", "sha256"} {
+		common.BlobDigest = algorithm
+		testStage(t, library, map[string]string{"content.txt": string(content)})
+		must.Nil(library.Record([]byte("digests: " + algorithm)))
+	}
+
+	catalogs := htfs.Catalogs()
+	must.Equal(2, len(catalogs))
+	algorithms := map[string]bool{}
+	for _, catalog := range catalogs {
+		root, err := htfs.NewRoot(".")
+		must.Nil(err)
+		must.Nil(root.LoadFrom(filepath.Join(common.HololibCatalogLocation(), catalog)))
+		algorithms[root.DigestAlgorithm()] = true
+		if root.DigestAlgorithm() == "blake3" {
+			expected := fmt.Sprintf("%02x", blake3.Sum256(content))
+			must.Equal(expected, root.Tree.Files["content.txt"].Digest)
+		}
+	}
+	must.Equal(2, len(algorithms))
+
+	config.VerifyBlobs = true
+	common.BlobDigest = "sha256"
+	space, err := library.Restore([]byte("digests: blake3"), []byte(common.ControllerIdentity()), []byte("blake3"))
+	must.Nil(err)
+	restored, err := ioutil.ReadFile(filepath.Join(space, "content.txt"))
+	must.Nil(err)
+	must.Equal(content, restored)
+
+	report, err := htfs.RepairIntegrity("")
+	must.Nil(err)
+	must.Equal(0, len(report.Damaged))
+	must.Equal(0, len(report.Missing))
+	must.Equal(0, len(report.Purged))
+}
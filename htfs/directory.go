@@ -35,14 +35,30 @@ type Dirtask func(string, *Dir) anywork.Work
 type Treetop func(string, *Dir) error
 
 type Root struct {
-	Identity   string `json:"identity"`
-	Path       string `json:"path"`
-	Controller string `json:"controller"`
-	Space      string `json:"space"`
-	Platform   string `json:"platform"`
-	Blueprint  string `json:"blueprint"`
-	Lifted     bool   `json:"lifted"`
-	Tree       *Dir   `json:"tree"`
+	Identity   string      `json:"identity"`
+	Path       string      `json:"path"`
+	Controller string      `json:"controller"`
+	Space      string      `json:"space"`
+	Platform   string      `json:"platform"`
+	Blueprint  string      `json:"blueprint"`
+	Algorithm  string      `json:"algorithm,omitempty"`
+	Sparse     []string    `json:"sparse,omitempty"`
+	Excludes   []string    `json:"excludes,omitempty"`
+	Provenance *Provenance `json:"provenance,omitempty"`
+	Created    string      `json:"created,omitempty"`
+	Lifted     bool        `json:"lifted"`
+	Tree       *Dir        `json:"tree"`
+	origin     []byte
+	prefix     []byte
+}
+
+// Rewriting is what is written into recorded rewrite positions of files:
+// space identity, and when space was moved into other holotree, also new
+// holotree path in place of Origin in front of identity.
+type Rewriting struct {
+	Identity []byte
+	Origin   []byte
+	Prefix   []byte
 }
 
 func NewRoot(path string) (*Root, error) {
@@ -64,25 +80,45 @@ func (it *Root) HolotreeBase() string {
 	return filepath.Dir(it.Path)
 }
 
+// CompatiblePlatform fails when catalog was recorded on other platform, like
+// amd64 catalog on arm64 host. Catalogs without platform are accepted.
+func (it *Root) CompatiblePlatform() error {
+	if len(it.Platform) == 0 || it.Platform == common.Platform() {
+		return nil
+	}
+	return fmt.Errorf("Catalog platform %q does not match host platform %q.", it.Platform, common.Platform())
+}
+
 func (it *Root) Signature() uint64 {
 	return sipit([]byte(strings.ToLower(fmt.Sprintf("%s %q", it.Platform, it.Path))))
 }
 
-func (it *Root) Rewrite() []byte {
-	return []byte(it.Identity)
+func (it *Root) Rewrite() *Rewriting {
+	return &Rewriting{
+		Identity: []byte(it.Identity),
+		Origin:   it.origin,
+		Prefix:   it.prefix,
+	}
 }
 
+// Relocate moves catalog into target space. Normally only space identity
+// changes, but target can also be in other holotree (like user's own
+// holotree for system catalogs). Then new holotree path is padded with
+// separators into length of recorded one, since offsets in files must stay
+// valid, so it cannot be longer than recorded holotree path.
 func (it *Root) Relocate(target string) error {
 	locate := filepath.Dir(target)
-	if it.HolotreeBase() != locate {
-		return fmt.Errorf("Base directory mismatch: %q vs %q.", it.HolotreeBase(), locate)
-	}
 	basename := filepath.Base(target)
 	if len(it.Identity) != len(basename) {
 		return fmt.Errorf("Base name length mismatch: %q vs %q.", it.Identity, basename)
 	}
-	if len(it.Path) != len(target) {
-		return fmt.Errorf("Path length mismatch: %q vs %q.", it.Path, target)
+	if it.HolotreeBase() != locate {
+		origin := it.HolotreeBase() + string(filepath.Separator)
+		if len(locate) >= len(origin) {
+			return fmt.Errorf("Cannot move %q into holotree %q, since its path is longer than recorded holotree %q.", it.Identity, locate, it.HolotreeBase())
+		}
+		padding := strings.Repeat(string(filepath.Separator), len(origin)-len(locate))
+		it.origin, it.prefix = []byte(origin), []byte(locate+padding)
 	}
 	it.Path = target
 	it.Identity = basename
@@ -90,11 +126,16 @@ func (it *Root) Relocate(target string) error {
 }
 
 func (it *Root) Lift() error {
+	return it.LiftExcluding(nil)
+}
+
+// LiftExcluding lifts tree, but leaves out entries matching exclusions.
+func (it *Root) LiftExcluding(exclusions *Exclusions) error {
 	if it.Lifted {
 		return nil
 	}
 	it.Lifted = true
-	return it.Tree.Lift(it.Path)
+	return it.Tree.liftWithin(it.Path, it.Path, exclusions)
 }
 
 func (it *Root) Treetop(task Treetop) error {
@@ -125,32 +166,48 @@ func (it *Root) AsJson() ([]byte, error) {
 	return json.MarshalIndent(it, "", "  ")
 }
 
+// SaveAs writes through partial file, so that readers never see partially
+// written catalog.
 func (it *Root) SaveAs(filename string) error {
 	content, err := it.AsJson()
 	if err != nil {
 		return err
 	}
+	partname := fmt.Sprintf("%s.part%s", filename, <-common.Identities)
+	defer os.Remove(partname)
+	err = writeGzipped(partname, content)
+	if err != nil {
+		return err
+	}
+	return TryRename("saveas", partname, filename)
+}
+
+func writeGzipped(filename string, content []byte) error {
 	sink, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
 	defer sink.Close()
-	defer sink.Sync()
 	writer, err := gzip.NewWriterLevel(sink, gzip.BestSpeed)
 	if err != nil {
 		return err
 	}
-	defer writer.Close()
 	_, err = writer.Write(content)
 	if err != nil {
 		return err
 	}
-	return nil
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	return sink.Sync()
 }
 
 func (it *Root) ReadFrom(source io.Reader) error {
 	decoder := json.NewDecoder(source)
-	return decoder.Decode(&it)
+	err := decoder.Decode(&it)
+	noteAlgorithm(it.Algorithm)
+	return err
 }
 
 func (it *Root) LoadFrom(filename string) error {
@@ -174,6 +231,7 @@ type Dir struct {
 	Mode  fs.FileMode      `json:"mode"`
 	Dirs  map[string]*Dir  `json:"subdirs"`
 	Files map[string]*File `json:"files"`
+	Links map[string]*Link `json:"links,omitempty"`
 }
 
 func (it *Dir) AllDirs(path string, task Dirtask) {
@@ -196,11 +254,18 @@ func (it *Dir) AllFiles(path string, task Filetask) {
 }
 
 func (it *Dir) Lift(path string) error {
+	return it.liftWithin(path, path, nil)
+}
+
+func (it *Dir) liftWithin(root, path string, exclusions *Exclusions) error {
 	stat, err := os.Stat(path)
 	if err != nil {
 		return err
 	}
 	it.Mode = stat.Mode()
+	if it.Links == nil {
+		it.Links = make(map[string]*Link)
+	}
 	content, err := os.ReadDir(path)
 	if err != nil {
 		return err
@@ -209,6 +274,19 @@ func (it *Dir) Lift(path string) error {
 		if killfile[part.Name()] || killfile[filepath.Ext(part.Name())] {
 			continue
 		}
+		if exclusions != nil {
+			relative, err := filepath.Rel(root, filepath.Join(path, part.Name()))
+			if err == nil && exclusions.Excluded(relative) {
+				continue
+			}
+		}
+		if part.Type()&fs.ModeSymlink != 0 {
+			link, ok := internalLink(root, path, part.Name())
+			if ok {
+				it.Links[part.Name()] = link
+				continue
+			}
+		}
 		// following must be done to get by symbolic links
 		info, err := os.Stat(filepath.Join(path, part.Name()))
 		if err != nil {
@@ -221,7 +299,7 @@ func (it *Dir) Lift(path string) error {
 		it.Files[part.Name()] = newFile(info)
 	}
 	for name, dir := range it.Dirs {
-		err = dir.Lift(filepath.Join(path, name))
+		err = dir.liftWithin(root, filepath.Join(path, name), exclusions)
 		if err != nil {
 			return err
 		}
@@ -230,11 +308,70 @@ func (it *Dir) Lift(path string) error {
 }
 
 type File struct {
-	Name    string      `json:"name"`
-	Size    int64       `json:"size"`
-	Mode    fs.FileMode `json:"mode"`
-	Digest  string      `json:"digest"`
-	Rewrite []int64     `json:"rewrite"`
+	Name       string      `json:"name"`
+	Size       int64       `json:"size"`
+	Mode       fs.FileMode `json:"mode"`
+	Executable bool        `json:"executable"`
+	Attributes uint32      `json:"attributes,omitempty"`
+	Digest     string      `json:"digest"`
+	Rewrite    []int64     `json:"rewrite"`
+	Chunks     []string    `json:"chunks,omitempty"`
+
+	Relocations map[string][]int64 `json:"relocations,omitempty"`
+}
+
+// Relocated tells if file content is changed when it is restored, because
+// it has holotree path or other relocated strings in it.
+func (it *File) Relocated() bool {
+	return len(it.Rewrite) > 0 || len(it.Relocations) > 0
+}
+
+// Link is symbolic link pointing inside same tree. Its target is always
+// relative, so that it stays valid when tree is relocated.
+type Link struct {
+	Name   string `json:"name"`
+	Target string `json:"target"`
+}
+
+// internalLink returns link entry for symbolic link, if it points inside
+// root. Links pointing outside are followed instead, as before.
+func internalLink(root, path, name string) (*Link, bool) {
+	target, err := os.Readlink(filepath.Join(path, name))
+	if err != nil {
+		return nil, false
+	}
+	resolved := target
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(path, resolved)
+	}
+	inside, err := filepath.Rel(root, filepath.Clean(resolved))
+	if err != nil || inside == ".." || strings.HasPrefix(inside, ".."+string(filepath.Separator)) {
+		return nil, false
+	}
+	if filepath.IsAbs(target) {
+		target, err = filepath.Rel(path, filepath.Clean(resolved))
+		if err != nil {
+			return nil, false
+		}
+	}
+	return &Link{Name: name, Target: target}, true
+}
+
+// Inside tells if link in path points inside root. Only such links are
+// recorded, so anything else comes from tampered or handcrafted catalog.
+func (it *Link) Inside(root, path string) bool {
+	return !filepath.IsAbs(it.Target) && pathlib.IsWithin(root, filepath.Join(path, it.Target))
+}
+
+func (it *Link) Match(fullpath string) bool {
+	target, err := os.Readlink(fullpath)
+	return err == nil && target == it.Target
+}
+
+// IsExecutable also works for catalogs recorded before executable bit was
+// stored explicitly.
+func (it *File) IsExecutable() bool {
+	return it.Executable || it.Mode&0o111 != 0
 }
 
 func (it *File) Match(info fs.FileInfo) bool {
@@ -249,15 +386,18 @@ func newDir(name string) *Dir {
 		Name:  name,
 		Dirs:  make(map[string]*Dir),
 		Files: make(map[string]*File),
+		Links: make(map[string]*Link),
 	}
 }
 
 func newFile(info fs.FileInfo) *File {
 	return &File{
-		Name:    info.Name(),
-		Mode:    info.Mode(),
-		Size:    info.Size(),
-		Digest:  "N/A",
-		Rewrite: make([]int64, 0),
+		Name:       info.Name(),
+		Mode:       info.Mode(),
+		Size:       info.Size(),
+		Executable: info.Mode()&0o111 != 0,
+		Attributes: capturedAttributes(info),
+		Digest:     "N/A",
+		Rewrite:    make([]int64, 0),
 	}
 }
@@ -0,0 +1,102 @@
+package htfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+)
+
+// SpaceDrift lists what restore would do to bring space back to its
+// catalog, without doing it. Paths are relative to space directory.
+type SpaceDrift struct {
+	Space     string   `json:"space"`
+	Path      string   `json:"path"`
+	Blueprint string   `json:"blueprint"`
+	Added     []string `json:"added"`
+	Replaced  []string `json:"replaced"`
+	Deleted   []string `json:"deleted"`
+}
+
+func (it *SpaceDrift) Dirty() bool {
+	return len(it.Added)+len(it.Replaced)+len(it.Deleted) > 0
+}
+
+// driftOf turns restore plan into drift, with paths relative to space, and
+// removed directories marked with trailing separator.
+func driftOf(space string, plan *RestorePlan) *SpaceDrift {
+	relative := func(fullpath string) string {
+		result, err := filepath.Rel(plan.Path, fullpath)
+		if err != nil {
+			return fullpath
+		}
+		return result
+	}
+	drift := &SpaceDrift{
+		Space:     space,
+		Path:      plan.Path,
+		Blueprint: plan.Blueprint,
+		Added:     make([]string, 0, len(plan.Added)),
+		Replaced:  make([]string, 0, len(plan.Replaced)),
+		Deleted:   make([]string, 0, len(plan.Removed)),
+	}
+	for _, fullpath := range plan.Added {
+		drift.Added = append(drift.Added, relative(fullpath))
+	}
+	for _, fullpath := range plan.Replaced {
+		drift.Replaced = append(drift.Replaced, relative(fullpath))
+	}
+	for _, fullpath := range plan.Removed {
+		if info, err := os.Lstat(fullpath); err == nil && info.IsDir() {
+			drift.Deleted = append(drift.Deleted, relative(fullpath)+string(filepath.Separator))
+		} else {
+			drift.Deleted = append(drift.Deleted, relative(fullpath))
+		}
+	}
+	return drift
+}
+
+// CheckSpaceDrift compares current content of space (resolved through
+// possible alias) against catalog it was restored from.
+func CheckSpaceDrift(space string) (*SpaceDrift, error) {
+	active := ActiveSpace(space)
+	name := ControllerSpaceName([]byte(common.ControllerIdentity()), []byte(active))
+	return spaceDrift(active, name)
+}
+
+// CheckLabelDrift is like CheckSpaceDrift, but space is given by its full
+// label (directory name), so spaces of other controllers can be checked.
+func CheckLabelDrift(label string) (*SpaceDrift, error) {
+	return spaceDrift(label, label)
+}
+
+func spaceDrift(active, name string) (drift *SpaceDrift, err error) {
+	defer fail.Around(&err)
+
+	tree, err := New()
+	fail.On(err != nil, "%s", err)
+	library, ok := tree.(*hololib)
+	fail.On(!ok, "Drift check requires local hololib.")
+
+	targetdir := filepath.Join(common.HolotreeLocation(), name)
+	metafile := filepath.Join(common.HolotreeLocation(), fmt.Sprintf("%s.meta", name))
+
+	shadow, err := NewRoot(targetdir)
+	fail.On(err != nil, "%s", err)
+	err = shadow.LoadFrom(metafile)
+	fail.On(err != nil, "Space %q has no metadata (not restored yet?) -> %v", active, err)
+
+	// drift is what restore from space's own catalog would do to it
+	plan := &RestorePlan{
+		Blueprint: shadow.Blueprint,
+		Added:     []string{},
+		Replaced:  []string{},
+		Removed:   []string{},
+	}
+	plan.Path, err = library.restore(shadow.Blueprint, name, []byte(shadow.Controller), []byte(shadow.Space), plan)
+	fail.On(err != nil, "Failed to check space drift -> %v", err)
+	plan.sort()
+	return driftOf(active, plan), nil
+}
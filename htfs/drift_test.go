@@ -0,0 +1,49 @@
+package htfs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestCanListSpaceDriftWithoutRestoring(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	common.ControllerType = "unittest"
+	space := "drift-unittest"
+	blueprint := []byte("drift: unittest")
+
+	library := testLibrary(t, map[string]string{
+		"keep.txt":     "keep",
+		"lib/patch.py": "original",
+		"lib/gone.py":  "gone",
+	})
+	must.Nil(library.Record(blueprint))
+
+	path, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte(space))
+	must.Nil(err)
+
+	drift, err := htfs.CheckSpaceDrift(space)
+	must.Nil(err)
+	wont.True(drift.Dirty())
+
+	must.Nil(ioutil.WriteFile(filepath.Join(path, "lib", "patch.py"), []byte("hotfixed!"), 0o644))
+	must.Nil(os.Remove(filepath.Join(path, "lib", "gone.py")))
+	must.Nil(ioutil.WriteFile(filepath.Join(path, "extra.txt"), []byte("extra"), 0o644))
+
+	drift, err = htfs.CheckSpaceDrift(space)
+	must.Nil(err)
+	must.True(drift.Dirty())
+	must.Equal([]string{filepath.Join("lib", "gone.py")}, drift.Added)
+	must.Equal([]string{filepath.Join("lib", "patch.py")}, drift.Replaced)
+	must.Equal([]string{"extra.txt"}, drift.Deleted)
+
+	content, err := ioutil.ReadFile(filepath.Join(path, "lib", "patch.py"))
+	must.Nil(err)
+	must.Equal("hotfixed!", string(content))
+}
@@ -0,0 +1,77 @@
+package htfs
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/robocorp/rcc/anywork"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+)
+
+const (
+	planAdded    = `added`
+	planReplaced = `replaced`
+	planRemoved  = `removed`
+)
+
+// RestorePlan is what restoring environment would do to space: full paths
+// of files, links, and directories to be added, replaced, or removed.
+type RestorePlan struct {
+	sync.Mutex
+	Blueprint string   `json:"blueprint"`
+	Path      string   `json:"path"`
+	Added     []string `json:"added"`
+	Replaced  []string `json:"replaced"`
+	Removed   []string `json:"removed"`
+}
+
+// Changes tells how many changes restore would make.
+func (it *RestorePlan) Changes() int {
+	return len(it.Added) + len(it.Replaced) + len(it.Removed)
+}
+
+func (it *RestorePlan) note(change, fullpath string) {
+	it.Lock()
+	defer it.Unlock()
+	switch change {
+	case planAdded:
+		it.Added = append(it.Added, fullpath)
+	case planReplaced:
+		it.Replaced = append(it.Replaced, fullpath)
+	case planRemoved:
+		it.Removed = append(it.Removed, fullpath)
+	}
+}
+
+func (it *RestorePlan) sort() {
+	sort.Strings(it.Added)
+	sort.Strings(it.Replaced)
+	sort.Strings(it.Removed)
+}
+
+// schedule backlogs restore work, or on dry run only notes it into plan.
+func (it *stats) schedule(change, fullpath string, work anywork.Work) {
+	if it.plan != nil {
+		it.plan.note(change, fullpath)
+		return
+	}
+	anywork.Backlog(work)
+}
+
+// PlanEnvironment tells what restoring environment of condafile into active
+// space would do, without building environment or touching space. Only
+// environments already in hololib can be planned.
+func PlanEnvironment(condafile string) (plan *RestorePlan, err error) {
+	defer fail.Around(&err)
+
+	_, blueprint, err := ComposeFinalBlueprint([]string{condafile}, "")
+	fail.On(err != nil, "%s", err)
+	tree, err := New()
+	fail.On(err != nil, "%s", err)
+	fail.On(!tree.HasBlueprint(blueprint), "Environment %q is not in hololib, so there is nothing to compare space to.", BlueprintHash(blueprint))
+	library, ok := tree.(*hololib)
+	fail.On(!ok, "Dry run is not supported with %T library.", tree)
+	space, _ := RestoreTarget(common.HolotreeSpace, BlueprintHash(blueprint))
+	return library.PlanRestore(blueprint, []byte(common.ControllerIdentity()), []byte(space))
+}
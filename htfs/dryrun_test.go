@@ -0,0 +1,68 @@
+package htfs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestDryRunReportsChangesWithoutTouchingSpace(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	space := common.HolotreeSpace
+	defer func() {
+		common.HolotreeSpace = space
+	}()
+	common.HolotreeSpace = "dryrun"
+
+	common.ControllerType = "unittest"
+	library := testLibrary(t, map[string]string{
+		"bin/python":  "bin/python",
+		"lib/kept":    "lib/kept",
+		"lib/changed": "lib/changed",
+		"lib/deleted": "lib/deleted",
+	})
+
+	condafile := filepath.Join(t.TempDir(), "conda.yaml")
+	must.Nil(ioutil.WriteFile(condafile, []byte("dependencies:\n- python=3.9.13\n"), 0o644))
+	_, blueprint, err := htfs.ComposeFinalBlueprint([]string{condafile}, "")
+	must.Nil(err)
+
+	_, err = htfs.PlanEnvironment(condafile)
+	wont.Nil(err)
+	must.Nil(library.Record(blueprint))
+
+	plan, err := htfs.PlanEnvironment(condafile)
+	must.Nil(err)
+	must.Equal(4, len(plan.Added))
+	must.Equal(0, len(plan.Replaced)+len(plan.Removed))
+	_, err = os.Stat(plan.Path)
+	wont.Nil(err)
+
+	target, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte(htfs.ActiveSpace(common.HolotreeSpace)))
+	must.Nil(err)
+	must.Equal(plan.Path, target)
+	must.Nil(ioutil.WriteFile(filepath.Join(target, "lib", "changed"), []byte("something else"), 0o644))
+	must.Nil(os.Remove(filepath.Join(target, "lib", "deleted")))
+	must.Nil(ioutil.WriteFile(filepath.Join(target, "lib", "extra"), []byte("extra"), 0o644))
+
+	plan, err = htfs.PlanEnvironment(condafile)
+	must.Nil(err)
+	must.Equal([]string{filepath.Join(target, "lib", "deleted")}, plan.Added)
+	must.Equal([]string{filepath.Join(target, "lib", "changed")}, plan.Replaced)
+	must.Equal([]string{filepath.Join(target, "lib", "extra")}, plan.Removed)
+	must.Equal(3, plan.Changes())
+
+	content, err := ioutil.ReadFile(filepath.Join(target, "lib", "changed"))
+	must.Nil(err)
+	must.Equal("something else", string(content))
+	_, err = os.Stat(filepath.Join(target, "lib", "extra"))
+	must.Nil(err)
+	_, err = os.Stat(filepath.Join(target, "lib", "deleted"))
+	wont.Nil(err)
+}
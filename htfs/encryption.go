@@ -0,0 +1,272 @@
+package htfs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/robocorp/rcc/keyring"
+	"github.com/robocorp/rcc/settings"
+)
+
+// Encrypted blobs are: magic, random salt, random nonce prefix, and then
+// chunks of [final flag][ciphertext length][ciphertext], where each chunk is
+// sealed with AES-GCM using nonce prefix and chunk counter. Final flag is
+// authenticated, so truncated blobs are detected.
+//
+// Key is derived from secret with HKDF-SHA256 using salt stored in blob
+// itself, so every blob has its own key.
+const (
+	RCC_HOLOLIB_KEY = `RCC_HOLOLIB_KEY`
+
+	encryptionChunk = 64 * 1024
+	prefixLength    = 8
+	saltLength      = 16
+	encryptionInfo  = "rcc hololib blob"
+)
+
+var (
+	encryptionMagic = []byte{0x52, 0x43, 0x43, 0xe2}
+
+	keyringLock    sync.Mutex
+	keyringSecrets = make(map[string]string)
+)
+
+// keyringSecret asks secret from OS keyring only once per service.
+func keyringSecret(service string) (string, error) {
+	keyringLock.Lock()
+	defer keyringLock.Unlock()
+	secret, ok := keyringSecrets[service]
+	if ok {
+		return secret, nil
+	}
+	secret, err := keyring.Secret(service)
+	if err != nil {
+		return "", fmt.Errorf("Could not read hololib encryption secret from keyring %q, reason: %v", service, err)
+	}
+	secret = strings.TrimSpace(secret)
+	keyringSecrets[service] = secret
+	return secret, nil
+}
+
+// encryptionSecret comes from environment variable, OS keyring, or from
+// settings, in that order.
+func encryptionSecret() (string, error) {
+	secret := strings.TrimSpace(os.Getenv(RCC_HOLOLIB_KEY))
+	if len(secret) > 0 {
+		return secret, nil
+	}
+	service := strings.TrimSpace(settings.Global.EncryptionKeyring())
+	if len(service) > 0 {
+		return keyringSecret(service)
+	}
+	return strings.TrimSpace(settings.Global.EncryptionKey()), nil
+}
+
+// BlobEncryption tells if new hololib blobs get encrypted.
+func BlobEncryption() bool {
+	if len(strings.TrimSpace(settings.Global.EncryptionKeyring())) > 0 {
+		return true
+	}
+	secret, _ := encryptionSecret()
+	return len(secret) > 0
+}
+
+// derivedKey is HKDF-SHA256 (RFC 5869) with single output block.
+func derivedKey(secret string, salt []byte) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write([]byte(secret))
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write([]byte(encryptionInfo))
+	expand.Write([]byte{1})
+	return expand.Sum(nil)
+}
+
+func blobCipher(salt []byte) (cipher.AEAD, error) {
+	secret, err := encryptionSecret()
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("Blob is encrypted, but there is no hololib encryption key (%s, encryption-keyring or encryption-key in settings).", RCC_HOLOLIB_KEY)
+	}
+	block, err := aes.NewCipher(derivedKey(secret, salt))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, counter uint32) []byte {
+	nonce := make([]byte, 0, prefixLength+4)
+	nonce = append(nonce, prefix...)
+	return append(nonce, byte(counter>>24), byte(counter>>16), byte(counter>>8), byte(counter))
+}
+
+func isEncrypted(source *bufio.Reader) bool {
+	magic, _ := source.Peek(len(encryptionMagic))
+	return bytes.Equal(magic, encryptionMagic)
+}
+
+type encryptingWriter struct {
+	sink    io.Writer
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buffer  []byte
+}
+
+func newEncryptingWriter(sink io.Writer) (io.WriteCloser, error) {
+	random := make([]byte, saltLength+prefixLength)
+	_, err := io.ReadFull(rand.Reader, random)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := blobCipher(random[:saltLength])
+	if err != nil {
+		return nil, err
+	}
+	_, err = sink.Write(append(append([]byte{}, encryptionMagic...), random...))
+	if err != nil {
+		return nil, err
+	}
+	return &encryptingWriter{
+		sink:   sink,
+		aead:   aead,
+		prefix: random[saltLength:],
+		buffer: make([]byte, 0, encryptionChunk),
+	}, nil
+}
+
+func (it *encryptingWriter) seal(plaintext []byte, final byte) error {
+	flag := []byte{final}
+	sealed := it.aead.Seal(nil, chunkNonce(it.prefix, it.counter), plaintext, flag)
+	it.counter += 1
+	header := make([]byte, 5)
+	header[0] = final
+	binary.BigEndian.PutUint32(header[1:], uint32(len(sealed)))
+	_, err := it.sink.Write(header)
+	if err != nil {
+		return err
+	}
+	_, err = it.sink.Write(sealed)
+	return err
+}
+
+func (it *encryptingWriter) Write(content []byte) (int, error) {
+	it.buffer = append(it.buffer, content...)
+	for len(it.buffer) > encryptionChunk {
+		err := it.seal(it.buffer[:encryptionChunk], 0)
+		if err != nil {
+			return 0, err
+		}
+		it.buffer = append(it.buffer[:0], it.buffer[encryptionChunk:]...)
+	}
+	return len(content), nil
+}
+
+func (it *encryptingWriter) Close() error {
+	return it.seal(it.buffer, 1)
+}
+
+type decryptingReader struct {
+	source  io.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buffer  []byte
+	final   bool
+}
+
+func newDecryptingReader(source io.Reader) (io.Reader, error) {
+	magic := make([]byte, len(encryptionMagic))
+	_, err := io.ReadFull(source, magic)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, saltLength)
+	_, err = io.ReadFull(source, salt)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, prefixLength)
+	_, err = io.ReadFull(source, prefix)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := blobCipher(salt)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{
+		source: source,
+		aead:   aead,
+		prefix: prefix,
+	}, nil
+}
+
+func (it *decryptingReader) open() error {
+	header := make([]byte, 5)
+	_, err := io.ReadFull(it.source, header)
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > encryptionChunk+uint32(it.aead.Overhead()) {
+		return fmt.Errorf("Encrypted blob has too big chunk (%d bytes).", size)
+	}
+	sealed := make([]byte, size)
+	_, err = io.ReadFull(it.source, sealed)
+	if err != nil {
+		return err
+	}
+	plaintext, err := it.aead.Open(nil, chunkNonce(it.prefix, it.counter), sealed, header[:1])
+	if err != nil {
+		return fmt.Errorf("Could not decrypt blob (wrong key, or damaged blob) -> %v", err)
+	}
+	it.counter += 1
+	it.buffer = plaintext
+	it.final = header[0] == 1
+	return nil
+}
+
+func (it *decryptingReader) Read(target []byte) (int, error) {
+	for len(it.buffer) == 0 {
+		if it.final {
+			return 0, io.EOF
+		}
+		err := it.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+	count := copy(target, it.buffer)
+	it.buffer = it.buffer[count:]
+	return count, nil
+}
+
+type layeredWriter struct {
+	io.WriteCloser
+	inner io.Closer
+}
+
+func (it layeredWriter) Close() error {
+	err := it.WriteCloser.Close()
+	if err != nil {
+		return err
+	}
+	return it.inner.Close()
+}
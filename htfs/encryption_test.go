@@ -0,0 +1,74 @@
+package htfs_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestCanEncryptBlobsAtRest(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	original := os.Getenv(htfs.RCC_HOLOLIB_KEY)
+	defer os.Setenv(htfs.RCC_HOLOLIB_KEY, original)
+
+	directory, err := ioutil.TempDir("", "rcc_encryption")
+	must.Nil(err)
+	defer os.RemoveAll(directory)
+
+	content := bytes.Repeat([]byte("proprietary wheel content\n"), 10000)
+	expected := fmt.Sprintf("%02x", sha256.Sum256(content))
+	source := filepath.Join(directory, "source.whl")
+	must.Nil(ioutil.WriteFile(source, content, 0o644))
+
+	digestOf := func(blob string) (digest string) {
+		defer func() {
+			if recover() != nil {
+				digest = ""
+			}
+		}()
+		details := &htfs.File{Name: filepath.Base(blob)}
+		htfs.Hasher(map[string]map[string]bool{details.Name: nil})(blob, details)()
+		return details.Digest
+	}
+
+	os.Setenv(htfs.RCC_HOLOLIB_KEY, "top secret")
+	must.True(htfs.BlobEncryption())
+	blob := filepath.Join(directory, "blob")
+	htfs.LiftFile(source, blob)()
+	lifted, err := ioutil.ReadFile(blob)
+	must.Nil(err)
+	must.Equal([]byte{0x52, 0x43, 0x43, 0xe2}, lifted[:4])
+	must.Equal(expected, digestOf(blob))
+
+	again := filepath.Join(directory, "again")
+	htfs.LiftFile(source, again)()
+	salted, err := ioutil.ReadFile(again)
+	must.Nil(err)
+	wont.Equal(lifted[4:20], salted[4:20])
+	must.Equal(expected, digestOf(again))
+
+	os.Setenv(htfs.RCC_HOLOLIB_KEY, "wrong secret")
+	wont.Equal(expected, digestOf(blob))
+
+	os.Setenv(htfs.RCC_HOLOLIB_KEY, "top secret")
+	damaged := append([]byte{}, lifted...)
+	damaged[len(damaged)/2] ^= 0xff
+	must.Nil(ioutil.WriteFile(blob, damaged, 0o644))
+	wont.Equal(expected, digestOf(blob))
+	must.Nil(ioutil.WriteFile(blob, lifted[:len(lifted)-20], 0o644))
+	wont.Equal(expected, digestOf(blob))
+
+	os.Setenv(htfs.RCC_HOLOLIB_KEY, "")
+	wont.True(htfs.BlobEncryption())
+	plain := filepath.Join(directory, "plain")
+	htfs.LiftFile(source, plain)()
+	must.Equal(expected, digestOf(plain))
+}
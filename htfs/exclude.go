@@ -0,0 +1,123 @@
+package htfs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+)
+
+const (
+	ignoreFilename = `.holotreeignore`
+)
+
+// Exclusions are glob patterns of files and directories that are left out
+// of catalog when it is recorded (so they are not hashed, lifted, or
+// restored). Pattern without slash matches name at any depth, and pattern
+// with slash matches path relative to root of tree (using forward slashes).
+type Exclusions struct {
+	patterns []string
+}
+
+// RecordingExclusions collects patterns from settings, from --exclude
+// options, and from .holotreeignore file at root of recorded tree (when root
+// is given).
+func RecordingExclusions(root string) *Exclusions {
+	result := &Exclusions{}
+	result.Add(configuredPatterns()...)
+	if len(root) > 0 {
+		result.Add(ignoreFileLines(filepath.Join(root, ignoreFilename))...)
+	}
+	return result
+}
+
+func configuredPatterns() []string {
+	result := append([]string{}, settings.Global.ExcludePatterns()...)
+	return append(result, common.RecordExcludes...)
+}
+
+// excludingBlueprint folds configured exclusions into blueprint, so that
+// environments recorded with different exclusions have different hashes.
+// Patterns from .holotreeignore are not needed here, since that file is
+// itself part of recorded tree. Without exclusions blueprint is used as is.
+func excludingBlueprint(blueprint []byte) []byte {
+	patterns := []string{}
+	for _, pattern := range configuredPatterns() {
+		pattern = normalizedPattern(pattern)
+		if len(pattern) > 0 && !strings.HasPrefix(pattern, "#") {
+			patterns = append(patterns, pattern)
+		}
+	}
+	if len(patterns) == 0 {
+		return blueprint
+	}
+	sort.Strings(patterns)
+	folded := append([]byte{}, blueprint...)
+	return append(folded, []byte(fmt.Sprintf("\n# holotree exclusions: %q\n", patterns))...)
+}
+
+func normalizedPattern(pattern string) string {
+	return strings.Trim(strings.TrimSpace(filepath.ToSlash(pattern)), "/")
+}
+
+func ignoreFileLines(filename string) []string {
+	handle, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer handle.Close()
+	result := []string{}
+	scanner := bufio.NewScanner(handle)
+	for scanner.Scan() {
+		result = append(result, scanner.Text())
+	}
+	return result
+}
+
+func (it *Exclusions) Add(patterns ...string) {
+	for _, pattern := range patterns {
+		pattern = normalizedPattern(pattern)
+		if len(pattern) == 0 || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			common.Log("Ignoring bad exclude pattern %q, reason: %v", pattern, err)
+			continue
+		}
+		it.patterns = append(it.patterns, pattern)
+	}
+}
+
+func (it *Exclusions) Patterns() []string {
+	if it == nil {
+		return []string{}
+	}
+	return append([]string{}, it.patterns...)
+}
+
+// Excluded tells if entry at relative path (forward slashes) is left out.
+func (it *Exclusions) Excluded(relative string) bool {
+	if it == nil || len(it.patterns) == 0 {
+		return false
+	}
+	relative = filepath.ToSlash(relative)
+	name := path.Base(relative)
+	for _, pattern := range it.patterns {
+		candidate := name
+		if strings.Contains(pattern, "/") {
+			candidate = relative
+		}
+		matched, _ := path.Match(pattern, candidate)
+		if matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package htfs_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestCanMatchExclusionPatterns(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	exclusions := &htfs.Exclusions{}
+	exclusions.Add("# comment", "", "tests/", "*.log", "lib/*/data", "[")
+	must.Equal([]string{"tests", "*.log", "lib/*/data"}, exclusions.Patterns())
+	must.True(exclusions.Excluded("tests"))
+	must.True(exclusions.Excluded("lib/python3.9/tests"))
+	must.True(exclusions.Excluded("bin/install.log"))
+	must.True(exclusions.Excluded("lib/python3.9/data"))
+	wont.True(exclusions.Excluded("data"))
+	wont.True(exclusions.Excluded("lib/python3.9/site-packages/data"))
+	wont.True(exclusions.Excluded("testsuite"))
+
+	var nothing *htfs.Exclusions
+	wont.True(nothing.Excluded("tests"))
+}
+
+func TestRecordingLeavesExcludedFilesOut(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	excludes := common.RecordExcludes
+	defer func() {
+		common.RecordExcludes = excludes
+	}()
+	common.ControllerType = "unittest"
+	blueprint := []byte("exclude: unittest")
+	common.RecordExcludes = []string{}
+	plain := htfs.BlueprintHash(blueprint)
+	common.RecordExcludes = []string{"*.log"}
+	must.True(plain != htfs.BlueprintHash(blueprint))
+	common.RecordExcludes = []string{"/*.log/", "# comment"}
+	excluded := htfs.BlueprintHash(blueprint)
+	common.RecordExcludes = []string{"*.log"}
+	must.Equal(excluded, htfs.BlueprintHash(blueprint))
+	library := testLibrary(t, map[string]string{
+		".holotreeignore":    "# test data\ntests\n",
+		"lib/kept.txt":       "kept",
+		"lib/build.log":      "log",
+		"lib/tests/data.txt": "data",
+	})
+	must.Nil(library.Record(blueprint))
+
+	catalogs, roots := htfs.LoadCatalogs()
+	must.Equal(1, len(catalogs))
+	must.Equal(htfs.CatalogName(excluded), filepath.Base(catalogs[0]))
+	must.Equal([]string{"*.log", "tests"}, roots[0].Excludes)
+	tree := roots[0].Tree
+	must.Equal(2, len(tree.Files)+len(tree.Dirs))
+	lib, ok := tree.Dirs["lib"]
+	must.True(ok)
+	must.Equal(0, len(lib.Dirs))
+	must.Equal(1, len(lib.Files))
+	_, ok = lib.Files["kept.txt"]
+	must.True(ok)
+	_, ok = tree.Files[".holotreeignore"]
+	must.True(ok)
+}
@@ -0,0 +1,110 @@
+package htfs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+)
+
+// Subtree finds directory (or single file, wrapped into directory) at
+// relative subpath inside catalog tree.
+func (it *Root) Subtree(subpath string) (*Dir, error) {
+	current := it.Tree
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(subpath)), "/")
+	for at, part := range parts {
+		if part == "" || part == "." {
+			continue
+		}
+		if part == ".." {
+			return nil, fmt.Errorf("Subpath %q must stay inside catalog.", subpath)
+		}
+		found, ok := current.Dirs[part]
+		if ok {
+			current = found
+			continue
+		}
+		file, ok := current.Files[part]
+		if ok && at == len(parts)-1 {
+			single := newDir(current.Name)
+			single.Mode = current.Mode
+			single.Files[part] = file
+			return single, nil
+		}
+		return nil, fmt.Errorf("Subpath %q not found in catalog (no %q).", subpath, part)
+	}
+	return current, nil
+}
+
+func emptyTarget(target string) bool {
+	content, err := os.ReadDir(target)
+	return os.IsNotExist(err) || (err == nil && len(content) == 0)
+}
+
+// RestoreSubtree restores only subpath of catalog into target directory,
+// which must not exist yet, or be empty. Relocated files keep paths of
+// their original space, so this is meant for extracting self-contained
+// parts (like single tools) from large environments.
+func RestoreSubtree(library Library, catalog, subpath, target string) (err error) {
+	defer fail.Around(&err)
+	defer common.Stopwatch("Holotree subtree restore took:").Debug()
+
+	fail.On(!emptyTarget(target), "Target %q already exists and is not empty.", target)
+	fs, err := NewRoot(".")
+	fail.On(err != nil, "Failed to create root -> %v", err)
+	err = fs.LoadFrom(catalog)
+	fail.On(err != nil, "Failed to load catalog %s -> %v", catalog, err)
+	subtree, err := fs.Subtree(subpath)
+	fail.On(err != nil, "%v", err)
+	fullpath, err := filepath.Abs(target)
+	fail.On(err != nil, "%v", err)
+
+	partial := &Root{
+		Identity:  fs.Identity,
+		Path:      fullpath,
+		Platform:  fs.Platform,
+		Blueprint: fs.Blueprint,
+		Lifted:    true,
+		Tree:      subtree,
+	}
+	err = partial.Treetop(MakeBranches)
+	fail.On(err != nil, "Failed to make branches -> %v", err)
+	score := &stats{}
+	err = partial.AllDirs(RestoreDirectory(library, partial, map[string]string{}, score))
+	fail.On(err != nil, "Failed to restore %q from %s -> %v", subpath, catalog, err)
+	common.Debug("Holotree subtree workload: %d/%d\n", score.dirty, score.total)
+	return nil
+}
+
+// CatalogFile reads content of single file at relative subpath inside
+// catalog directly from library. Content is as stored in hololib, so
+// relocations to any space path are not applied.
+func CatalogFile(library Library, catalog, subpath string) (content []byte, err error) {
+	defer fail.Around(&err)
+
+	fs, err := NewRoot(".")
+	fail.On(err != nil, "Failed to create root -> %v", err)
+	err = fs.LoadFrom(catalog)
+	fail.On(err != nil, "Failed to load catalog %s -> %v", catalog, err)
+	subtree, err := fs.Subtree(subpath)
+	fail.On(err != nil, "%v", err)
+	file, ok := subtree.Files[filepath.Base(subpath)]
+	fail.On(!ok, "Subpath %q is not a file in catalog %s.", subpath, catalog)
+	sink := bytes.NewBuffer(make([]byte, 0, file.Size))
+	if len(file.Chunks) > 0 {
+		err = dropChunks(library, file.Chunks, sink)
+		fail.On(err != nil, "Failed to read %q from %s -> %v", subpath, catalog, err)
+		return sink.Bytes(), nil
+	}
+	reader, closer, err := library.Open(file.Digest)
+	fail.On(err != nil, "Failed to open %q from %s -> %v", subpath, catalog, err)
+	defer closer()
+	_, err = io.Copy(sink, reader)
+	fail.On(err != nil, "Failed to read %q from %s -> %v", subpath, catalog, err)
+	return sink.Bytes(), nil
+}
@@ -0,0 +1,58 @@
+package htfs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+func TestCanRestoreCatalogSubtree(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	folder := t.TempDir()
+	library := testLibrary(t, map[string]string{
+		"lib/tools/foo/foo.py":       "print('foo')",
+		"lib/tools/foo/data/foo.txt": "foo data",
+	})
+	must.Nil(os.MkdirAll(filepath.Join(library.Stage(), "bin"), 0o755))
+	must.Nil(ioutil.WriteFile(filepath.Join(library.Stage(), "bin", "other"), []byte("other tool"), 0o755))
+	must.Nil(library.Record([]byte("extract: unittest")))
+
+	catalogs := htfs.Catalogs()
+	must.Equal(1, len(catalogs))
+	catalog := filepath.Join(common.HololibCatalogLocation(), catalogs[0])
+
+	target := filepath.Join(folder, "target")
+	must.Nil(htfs.RestoreSubtree(library, catalog, "lib/tools/foo", target))
+	content, err := ioutil.ReadFile(filepath.Join(target, "foo.py"))
+	must.Nil(err)
+	must.Equal("print('foo')", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(target, "data", "foo.txt"))
+	must.Nil(err)
+	must.Equal("foo data", string(content))
+	wont.True(pathlib.Exists(filepath.Join(target, "bin")))
+	wont.True(pathlib.Exists(filepath.Join(target, "lib")))
+
+	wont.Nil(htfs.RestoreSubtree(library, catalog, "lib/tools/foo", target))
+
+	single := filepath.Join(folder, "single")
+	must.Nil(htfs.RestoreSubtree(library, catalog, "bin/other", single))
+	content, err = ioutil.ReadFile(filepath.Join(single, "other"))
+	must.Nil(err)
+	must.Equal("other tool", string(content))
+
+	wont.Nil(htfs.RestoreSubtree(library, catalog, "lib/missing", filepath.Join(folder, "missing")))
+	wont.Nil(htfs.RestoreSubtree(library, catalog, "../outside", filepath.Join(folder, "outside")))
+
+	content, err = htfs.CatalogFile(library, catalog, "lib/tools/foo/data/foo.txt")
+	must.Nil(err)
+	must.Equal("foo data", string(content))
+	_, err = htfs.CatalogFile(library, catalog, "lib/tools/foo")
+	wont.Nil(err)
+}
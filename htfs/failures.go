@@ -0,0 +1,95 @@
+package htfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/journal"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/settings"
+)
+
+type BlueprintFailure struct {
+	Blueprint string `json:"blueprint"`
+	Platform  string `json:"platform"`
+	Reason    string `json:"reason"`
+	Message   string `json:"message"`
+	When      int64  `json:"when"`
+	Version   string `json:"rcc"`
+}
+
+func (it *BlueprintFailure) Age() time.Duration {
+	return time.Since(time.Unix(it.When, 0)).Round(time.Second)
+}
+
+func blueprintFailureFile(key string) string {
+	name := fmt.Sprintf("%s.%s.json", key, common.Platform())
+	return filepath.Join(common.BlueprintFailureLocation(), name)
+}
+
+func LoadBlueprintFailure(key string) (*BlueprintFailure, bool) {
+	content, err := ioutil.ReadFile(blueprintFailureFile(key))
+	if err != nil {
+		return nil, false
+	}
+	result := &BlueprintFailure{}
+	err = json.Unmarshal(content, result)
+	if err != nil {
+		return nil, false
+	}
+	return result, true
+}
+
+func RecordBlueprintFailure(key string, failure error) (err error) {
+	defer fail.Around(&err)
+
+	reason := conda.FailureReason(failure)
+	record := &BlueprintFailure{
+		Blueprint: key,
+		Platform:  common.Platform(),
+		Reason:    reason,
+		Message:   failure.Error(),
+		When:      time.Now().Unix(),
+		Version:   common.Version,
+	}
+	content, err := json.MarshalIndent(record, "", "  ")
+	fail.On(err != nil, "Could not serialize blueprint failure -> %v", err)
+	filename := blueprintFailureFile(key)
+	_, err = pathlib.EnsureParentDirectory(filename)
+	fail.On(err != nil, "Could not create directory for %q -> %v", filename, err)
+	err = ioutil.WriteFile(filename, content, 0o644)
+	fail.On(err != nil, "Could not save %q -> %v", filename, err)
+	journal.Post("blueprint-failed", key, "build failed with reason %q: %s", reason, failure.Error())
+	return nil
+}
+
+func ForgetBlueprintFailure(key string) {
+	filename := blueprintFailureFile(key)
+	if pathlib.IsFile(filename) {
+		common.Error("forget failure", os.Remove(filename))
+	}
+}
+
+func CheckBlueprintFailure(key string) error {
+	cooldown := settings.Global.FailureCooldown()
+	if cooldown == 0 || common.RetryFailed {
+		return nil
+	}
+	failure, ok := LoadBlueprintFailure(key)
+	if !ok {
+		return nil
+	}
+	age := failure.Age()
+	if age > cooldown {
+		return nil
+	}
+	common.Timeline("blueprint %s failed %s ago, fast fail", key, age)
+	return fmt.Errorf("Blueprint %q failed %s ago with reason %q (%s), and failure cooldown of %s is still in effect. Use --retry-failed to force fresh attempt.", key, age, failure.Reason, failure.Message, cooldown)
+}
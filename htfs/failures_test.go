@@ -0,0 +1,39 @@
+package htfs_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestCanRememberBlueprintFailures(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, t.TempDir())
+	common.ControllerType = "unittest"
+	key := "feedfacecafebeef"
+
+	htfs.ForgetBlueprintFailure(key)
+	_, ok := htfs.LoadBlueprintFailure(key)
+	wont.True(ok)
+	must.Nil(htfs.CheckBlueprintFailure(key))
+
+	must.Nil(htfs.RecordBlueprintFailure(key, errors.New("unittest failure")))
+	failure, ok := htfs.LoadBlueprintFailure(key)
+	must.True(ok)
+	wont.Nil(failure)
+	must.Equal(key, failure.Blueprint)
+	must.Equal("other", failure.Reason)
+	must.Equal("unittest failure", failure.Message)
+	wont.Nil(htfs.CheckBlueprintFailure(key))
+
+	common.RetryFailed = true
+	must.Nil(htfs.CheckBlueprintFailure(key))
+	common.RetryFailed = false
+
+	htfs.ForgetBlueprintFailure(key)
+	must.Nil(htfs.CheckBlueprintFailure(key))
+}
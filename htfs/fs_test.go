@@ -1,6 +1,7 @@
 package htfs_test
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -56,3 +57,45 @@ func TestZipLibrary(t *testing.T) {
 	wont.Nil(sut)
 	must.True(sut.HasBlueprint(blueprint))
 }
+
+func TestCatalogPlatformIsChecked(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	fs, err := htfs.NewRoot(".")
+	must.Nil(err)
+	must.Equal(common.Platform(), fs.Platform)
+	must.Nil(fs.CompatiblePlatform())
+
+	fs.Platform = ""
+	must.Nil(fs.CompatiblePlatform())
+
+	fs.Platform = "plan9_mips"
+	wont.Nil(fs.CompatiblePlatform())
+}
+
+// testLibrary points ROBOCORP_HOME into fresh temporary folder and returns
+// library which stage contains given files (slash separated relative paths).
+func testLibrary(t *testing.T, files map[string]string) htfs.MutableLibrary {
+	t.Helper()
+	must, _ := hamlet.Specifications(t)
+
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, t.TempDir())
+	library, err := htfs.New()
+	must.Nil(err)
+	testStage(t, library, files)
+	return library
+}
+
+// testStage replaces content of library stage with given files.
+func testStage(t *testing.T, library htfs.MutableLibrary, files map[string]string) {
+	t.Helper()
+	must, _ := hamlet.Specifications(t)
+
+	must.Nil(htfs.CleanupHolotreeStage(library))
+	must.Nil(os.MkdirAll(library.Stage(), 0o755))
+	for name, content := range files {
+		fullpath := filepath.Join(library.Stage(), filepath.FromSlash(name))
+		must.Nil(os.MkdirAll(filepath.Dir(fullpath), 0o755))
+		must.Nil(ioutil.WriteFile(fullpath, []byte(content), 0o644))
+	}
+}
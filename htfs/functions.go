@@ -1,8 +1,7 @@
 package htfs
 
 import (
-	"compress/gzip"
-	"crypto/sha256"
+	"bufio"
 	"fmt"
 	"io"
 	"math/rand"
@@ -20,8 +19,7 @@ import (
 
 func JustFileExistCheck(library MutableLibrary, path, name, digest string) anywork.Work {
 	return func() {
-		location := library.ExactLocation(digest)
-		if !pathlib.IsFile(location) {
+		if !library.HasBlob(digest) {
 			fullpath := filepath.Join(path, name)
 			panic(fmt.Errorf("Content for %q [%s] is missing!", fullpath, digest))
 		}
@@ -32,7 +30,9 @@ func CatalogCheck(library MutableLibrary, fs *Root) Treetop {
 	var tool Treetop
 	tool = func(path string, it *Dir) error {
 		for name, file := range it.Files {
-			anywork.Backlog(JustFileExistCheck(library, path, name, file.Digest))
+			for _, digest := range file.Blobs() {
+				anywork.Backlog(JustFileExistCheck(library, path, name, digest))
+			}
 		}
 		for name, subdir := range it.Dirs {
 			err := tool(filepath.Join(path, name), subdir)
@@ -52,7 +52,9 @@ func DigestMapper(target map[string]string) Treetop {
 			tool(filepath.Join(path, name), subdir)
 		}
 		for name, file := range it.Files {
-			target[file.Digest] = filepath.Join(path, name)
+			for _, digest := range file.Blobs() {
+				target[digest] = filepath.Join(path, name)
+			}
 		}
 		return nil
 	}
@@ -90,36 +92,28 @@ func IntegrityCheck(result map[string]string) Treetop {
 }
 
 func Hasher(known map[string]map[string]bool) Filetask {
+	return hashingTask(known, true)
+}
+
+// hashingTask computes digests of blobs, and with cleanup, removes blobs that
+// no catalog knows about.
+func hashingTask(known map[string]map[string]bool, cleanup bool) Filetask {
 	return func(fullpath string, details *File) anywork.Work {
 		return func() {
 			_, ok := known[details.Name]
-			if !ok {
+			if !ok && cleanup {
 				defer anywork.Backlog(RemoveFile(fullpath))
 			}
-			source, err := os.Open(fullpath)
-			if err != nil {
-				panic(fmt.Sprintf("Open %q, reason: %v", fullpath, err))
-			}
-			defer source.Close()
-
-			var reader io.ReadCloser
-			reader, err = gzip.NewReader(source)
-			if err != nil {
-				_, err = source.Seek(0, 0)
-				fail.On(err != nil, "Failed to seek %q -> %v", fullpath, err)
-				reader = source
-			}
-			digest := sha256.New()
-			_, err = io.Copy(digest, reader)
+			digest, err := matchingDigest(fullpath, details.Name, true)
 			if err != nil {
 				panic(fmt.Sprintf("Copy %q, reason: %v", fullpath, err))
 			}
-			details.Digest = fmt.Sprintf("%02x", digest.Sum(nil))
+			details.Digest = digest
 		}
 	}
 }
 
-func Locator(seek string) Filetask {
+func Locator(seek, algorithm string) Filetask {
 	return func(fullpath string, details *File) anywork.Work {
 		return func() {
 			source, err := os.Open(fullpath)
@@ -127,13 +121,29 @@ func Locator(seek string) Filetask {
 				panic(fmt.Sprintf("Open %q, reason: %v", fullpath, err))
 			}
 			defer source.Close()
-			digest := sha256.New()
+			digest := newDigest(algorithm)
 			locator := trollhash.LocateWriter(digest, seek)
-			_, err = io.Copy(locator, source)
+			var sink io.Writer = locator
+			searches := relocationSearches()
+			extras := make([]trollhash.WriteLocator, len(searches))
+			for at, search := range searches {
+				extras[at] = trollhash.LocateWriter(sink, search)
+				sink = extras[at]
+			}
+			_, err = io.Copy(sink, source)
 			if err != nil {
 				panic(fmt.Sprintf("Copy %q, reason: %v", fullpath, err))
 			}
 			details.Rewrite = locator.Locations()
+			details.Relocations = nil
+			for at, search := range searches {
+				if found := extras[at].Locations(); len(found) > 0 {
+					if details.Relocations == nil {
+						details.Relocations = make(map[string][]int64)
+					}
+					details.Relocations[search] = found
+				}
+			}
 			details.Digest = fmt.Sprintf("%02x", digest.Sum(nil))
 		}
 	}
@@ -168,25 +178,46 @@ func ScheduleLifters(library MutableLibrary, stats *stats) Treetop {
 				continue
 			}
 			seen[file.Digest] = true
+			ok := library.HasBlob(file.Digest)
+			stats.Dirty(!ok)
+			if ok {
+				continue
+			}
+			sourcepath := filepath.Join(path, name)
+			if chunkable(file.Size) {
+				anywork.Backlog(tracked(file.Size, LiftChunks(library, sourcepath, file)))
+				continue
+			}
+			if store, ok := storeOf(library); ok {
+				anywork.Backlog(tracked(file.Size, StoreBlob(store, sourcepath, file.Digest)))
+				continue
+			}
 			directory := library.Location(file.Digest)
 			if !seen[directory] && !pathlib.IsDir(directory) {
 				os.MkdirAll(directory, 0o755)
 			}
 			seen[directory] = true
 			sinkpath := filepath.Join(directory, file.Digest)
-			ok := pathlib.IsFile(sinkpath)
-			stats.Dirty(!ok)
-			if ok {
-				continue
-			}
-			sourcepath := filepath.Join(path, name)
-			anywork.Backlog(LiftFile(sourcepath, sinkpath))
+			anywork.Backlog(tracked(file.Size, LiftFile(sourcepath, sinkpath)))
 		}
 		return nil
 	}
 	return scheduler
 }
 
+// storeOf returns blob store of library, when its blobs are not plain files,
+// and so must be lifted through store itself.
+func storeOf(library MutableLibrary) (BlobStore, bool) {
+	local, ok := library.(*hololib)
+	if !ok {
+		return nil, false
+	}
+	if _, files := local.fileStore(); files {
+		return nil, false
+	}
+	return local.store, true
+}
+
 func TryRemove(context, target string) (err error) {
 	for delay := 0; delay < 5; delay += 1 {
 		time.Sleep(time.Duration(delay*100) * time.Millisecond)
@@ -237,6 +268,14 @@ func TryRename(context, source, target string) (err error) {
 
 func LiftFile(sourcename, sinkname string) anywork.Work {
 	return func() {
+		locker, err := keyLock(filepath.Base(sinkname))
+		anywork.OnErrPanicCloseAll(err)
+		defer locker.Release()
+		if pathlib.IsFile(sinkname) {
+			common.Trace("LiftFile %q already lifted by other process.", sinkname)
+			return
+		}
+
 		source, err := os.Open(sourcename)
 		anywork.OnErrPanicCloseAll(err)
 
@@ -247,11 +286,13 @@ func LiftFile(sourcename, sinkname string) anywork.Work {
 		anywork.OnErrPanicCloseAll(err)
 
 		defer sink.Close()
-		writer, err := gzip.NewWriterLevel(sink, gzip.BestSpeed)
+		buffered := bufio.NewReader(throttled(source))
+		writer, err := compressingWriter(sink, buffered)
 		anywork.OnErrPanicCloseAll(err, sink)
 
-		_, err = io.Copy(writer, source)
+		size, err := io.Copy(writer, buffered)
 		anywork.OnErrPanicCloseAll(err, sink)
+		countLifted(size)
 
 		anywork.OnErrPanicCloseAll(writer.Close(), sink)
 
@@ -263,36 +304,50 @@ func LiftFile(sourcename, sinkname string) anywork.Work {
 	}
 }
 
-func DropFile(library Library, digest, sinkname string, details *File, rewrite []byte) anywork.Work {
+func DropFile(library Library, digest, sinkname string, details *File, rewrite *Rewriting) anywork.Work {
 	return func() {
-		reader, closer, err := library.Open(digest)
-		anywork.OnErrPanicCloseAll(err)
-
-		defer closer()
 		partname := fmt.Sprintf("%s.part%s", sinkname, <-common.Identities)
 		defer os.Remove(partname)
-		sink, err := os.Create(partname)
-		anywork.OnErrPanicCloseAll(err)
 
-		_, err = io.Copy(sink, reader)
-		anywork.OnErrPanicCloseAll(err, sink)
+		var sink *os.File
+		chunked := len(details.Chunks) > 0
+		linked := !chunked && linkBlob(library, digest, partname, details)
+		if linked {
+			linked, err := os.OpenFile(partname, os.O_RDWR, 0)
+			anywork.OnErrPanicCloseAll(err)
+			sink = linked
+		} else if chunked {
+			created, err := os.Create(partname)
+			anywork.OnErrPanicCloseAll(err)
+			sink = created
 
-		for _, position := range details.Rewrite {
-			_, err = sink.Seek(position, 0)
-			if err != nil {
-				sink.Close()
-				panic(fmt.Sprintf("%v %d", err, position))
-			}
-			_, err = sink.Write(rewrite)
+			anywork.OnErrPanicCloseAll(dropChunks(library, details.Chunks, sink), sink)
+		} else {
+			reader, closer, err := library.Open(digest)
+			anywork.OnErrPanicCloseAll(err)
+
+			defer closer()
+			created, err := os.Create(partname)
+			anywork.OnErrPanicCloseAll(err)
+			sink = created
+
+			size, err := io.Copy(sink, throttled(reader))
 			anywork.OnErrPanicCloseAll(err, sink)
+			countDecompressed(size)
 		}
 
+		anywork.OnErrPanicCloseAll(relocateFile(sink, details, rewrite), sink)
+
 		anywork.OnErrPanicCloseAll(sink.Close())
 
 		anywork.OnErrPanicCloseAll(TryRename("dropfile", partname, sinkname))
 
 		anywork.OnErrPanicCloseAll(os.Chmod(sinkname, details.Mode))
 		anywork.OnErrPanicCloseAll(os.Chtimes(sinkname, motherTime, motherTime))
+		// linked files share attributes and ACL with blob itself
+		if !linked && PreserveAttributes() {
+			anywork.OnErrPanicCloseAll(restoreAttributes(sinkname, details))
+		}
 	}
 }
 
@@ -308,19 +363,50 @@ func RemoveDirectory(dirname string) anywork.Work {
 	}
 }
 
+func DropLink(sinkname string, link *Link) anywork.Work {
+	return func() {
+		if _, err := os.Lstat(sinkname); err == nil {
+			anywork.OnErrPanicCloseAll(TryRemoveAll("link", sinkname))
+		}
+		anywork.OnErrPanicCloseAll(os.Symlink(link.Target, sinkname))
+	}
+}
+
 func RestoreDirectory(library Library, fs *Root, current map[string]string, stats *stats) Dirtask {
 	return func(path string, it *Dir) anywork.Work {
 		return func() {
 			content, err := os.ReadDir(path)
+			if err != nil && stats.plan != nil && os.IsNotExist(err) {
+				content, err = nil, nil
+			}
 			anywork.OnErrPanicCloseAll(err)
+			links := make(map[string]*Link)
+			for name, link := range it.Links {
+				if !link.Inside(fs.Path, path) {
+					common.Log("Warning: not restoring link %q, since its target %q points outside of %q.", filepath.Join(path, name), link.Target, fs.Path)
+					continue
+				}
+				links[name] = link
+			}
 			files := make(map[string]bool)
 			for _, part := range content {
 				directpath := filepath.Join(path, part.Name())
+				link, ok := links[part.Name()]
+				if ok {
+					files[part.Name()] = true
+					ok = link.Match(directpath)
+					stats.Dirty(!ok)
+					if !ok {
+						common.Trace("* Holotree: update changed link    %q", directpath)
+						stats.schedule(planReplaced, directpath, DropLink(directpath, link))
+					}
+					continue
+				}
 				if part.IsDir() {
 					_, ok := it.Dirs[part.Name()]
 					if !ok {
 						common.Trace("* Holotree: remove extra directory %q", directpath)
-						anywork.Backlog(RemoveDirectory(directpath))
+						stats.schedule(planRemoved, directpath, RemoveDirectory(directpath))
 					}
 					stats.Dirty(!ok)
 					continue
@@ -329,7 +415,7 @@ func RestoreDirectory(library Library, fs *Root, current map[string]string, stat
 				found, ok := it.Files[part.Name()]
 				if !ok {
 					common.Trace("* Holotree: remove extra file      %q", directpath)
-					anywork.Backlog(RemoveFile(directpath))
+					stats.schedule(planRemoved, directpath, RemoveFile(directpath))
 					stats.Dirty(true)
 					continue
 				}
@@ -341,7 +427,7 @@ func RestoreDirectory(library Library, fs *Root, current map[string]string, stat
 				stats.Dirty(!ok)
 				if !ok {
 					common.Trace("* Holotree: update changed file    %q", directpath)
-					anywork.Backlog(DropFile(library, found.Digest, directpath, found, fs.Rewrite()))
+					stats.schedule(planReplaced, directpath, tracked(found.Size, DropFile(library, found.Digest, directpath, found, fs.Rewrite())))
 				}
 			}
 			for name, found := range it.Files {
@@ -350,7 +436,16 @@ func RestoreDirectory(library Library, fs *Root, current map[string]string, stat
 				if !seen {
 					stats.Dirty(true)
 					common.Trace("* Holotree: add missing file       %q", directpath)
-					anywork.Backlog(DropFile(library, found.Digest, directpath, found, fs.Rewrite()))
+					stats.schedule(planAdded, directpath, tracked(found.Size, DropFile(library, found.Digest, directpath, found, fs.Rewrite())))
+				}
+			}
+			for name, link := range links {
+				directpath := filepath.Join(path, name)
+				_, seen := files[name]
+				if !seen {
+					stats.Dirty(true)
+					common.Trace("* Holotree: add missing link       %q", directpath)
+					stats.schedule(planAdded, directpath, DropLink(directpath, link))
 				}
 			}
 		}
@@ -361,18 +456,23 @@ type Zipper interface {
 	Add(fullpath, relativepath string) error
 }
 
+// ZipRoot adds blobs of catalog into sink, as files, so it refuses to work
+// with blob stores that do not keep blobs as files.
 func ZipRoot(library MutableLibrary, fs *Root, sink Zipper) Treetop {
 	var tool Treetop
-	baseline := common.HololibLocation()
 	tool = func(path string, it *Dir) (err error) {
 		defer fail.Around(&err)
 
+		if store, ok := storeOf(library); ok {
+			fail.On(true, "Exporting needs blobs as files, but hololib uses %q blob store.", store.Name())
+		}
+
 		for _, file := range it.Files {
-			location := library.ExactLocation(file.Digest)
-			relative, err := filepath.Rel(baseline, location)
-			fail.On(err != nil, "Relative path error: %s -> %s -> %v", baseline, location, err)
-			err = sink.Add(location, relative)
-			fail.On(err != nil, "%v", err)
+			for _, digest := range file.Blobs() {
+				location := library.ExactLocation(digest)
+				err = sink.Add(location, blobName(digest))
+				fail.On(err != nil, "%v", err)
+			}
 		}
 		for name, subdir := range it.Dirs {
 			err := tool(filepath.Join(path, name), subdir)
@@ -384,9 +484,20 @@ func ZipRoot(library MutableLibrary, fs *Root, sink Zipper) Treetop {
 }
 
 func LoadHololibHashes() map[string]map[string]bool {
+	result, _ := loadHololibHashes()
+	return result
+}
+
+// loadHololibHashes also tells which catalogs could not be loaded.
+func loadHololibHashes() (map[string]map[string]bool, []string) {
 	catalogs, roots := LoadCatalogs()
 	slots := make([]map[string]string, len(roots))
+	unreadable := []string{}
 	for at, root := range roots {
+		if root == nil {
+			unreadable = append(unreadable, catalogs[at])
+			continue
+		}
 		anywork.Backlog(DigestLoader(root, at, slots))
 	}
 	result := make(map[string]map[string]bool)
@@ -403,7 +514,7 @@ func LoadHololibHashes() map[string]map[string]bool {
 			found[catalog] = true
 		}
 	}
-	return result
+	return result, unreadable
 }
 
 func DigestLoader(root *Root, at int, slots []map[string]string) anywork.Work {
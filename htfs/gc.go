@@ -0,0 +1,112 @@
+package htfs
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+const (
+	// blobs younger than this are never collected, since they might belong
+	// to catalog which is still being recorded (like tool cache snapshots)
+	collectGracePeriod = 1 * time.Hour
+)
+
+type GarbageReport struct {
+	Dryrun    bool     `json:"dryrun"`
+	Catalogs  int      `json:"catalogs"`
+	Kept      int      `json:"kept"`
+	Removed   []string `json:"removed"`
+	Freed     int64    `json:"freed"`
+	Remaining int64    `json:"remaining"`
+	Repack    bool     `json:"repack,omitempty"`
+}
+
+func isDigestName(name string) bool {
+	if len(name) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+// CollectGarbage removes blobs from own hololib library, which are not
+// referenced by any catalog anymore. With dryrun, nothing is removed, but
+// report tells what would have been.
+func CollectGarbage(dryrun bool) (report *GarbageReport, err error) {
+	return collectGarbage(dryrun, map[string]bool{})
+}
+
+// collectGarbage ignores excluded catalogs (full paths), as if they were
+// already removed (which is needed for dryrun of pruning).
+func collectGarbage(dryrun bool, excluded map[string]bool) (report *GarbageReport, err error) {
+	defer fail.Around(&err)
+
+	callback := pathlib.LockWaitMessage("Serialized holotree garbage collection")
+	locker, err := pathlib.Locker(common.HolotreeLock(), 30000)
+	callback()
+	fail.On(err != nil, "Could not get lock for holotree. Quiting.")
+	defer locker.Release()
+
+	catalogs, roots := LoadCatalogs()
+	referenced := make(map[string]string)
+	counted := 0
+	for at, root := range roots {
+		if excluded[catalogs[at]] {
+			continue
+		}
+		counted += 1
+		fail.On(root == nil, "Could not load catalog %q, refusing to collect garbage.", catalogs[at])
+		err = root.Treetop(DigestMapper(referenced))
+		fail.On(err != nil, "Could not read catalog %q -> %v", catalogs[at], err)
+	}
+
+	report = &GarbageReport{
+		Dryrun:   dryrun,
+		Catalogs: counted,
+		Removed:  []string{},
+	}
+	deadline := time.Now().Add(-collectGracePeriod)
+	err = filepath.Walk(common.HololibLibraryLocation(), func(fullpath string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		name := info.Name()
+		_, ok := referenced[name]
+		if ok || !isDigestName(name) || info.ModTime().After(deadline) {
+			report.Kept += 1
+			report.Remaining += info.Size()
+			return nil
+		}
+		if !dryrun {
+			err = TryRemove("blob", fullpath)
+			if err != nil {
+				return err
+			}
+		}
+		report.Removed = append(report.Removed, name)
+		report.Freed += info.Size()
+		return nil
+	})
+	fail.On(err != nil, "Could not collect hololib garbage -> %v", err)
+	store, err := hololibStore()
+	fail.On(err != nil, "%v", err)
+	if packs, ok := store.(*packBlobStore); ok {
+		err = packs.collect(referenced, deadline, dryrun, report)
+		fail.On(err != nil, "Could not collect packed garbage -> %v", err)
+	}
+	sort.Strings(report.Removed)
+	return report, nil
+}
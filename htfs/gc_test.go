@@ -0,0 +1,65 @@
+package htfs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+func TestCanCollectOrphanedBlobs(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	library := testLibrary(t, nil)
+	for _, name := range []string{"first", "second"} {
+		testStage(t, library, map[string]string{
+			"shared.txt": "shared by all catalogs",
+			"unique.txt": "unique to " + name,
+		})
+		must.Nil(library.Record([]byte("gc: " + name)))
+	}
+
+	report, err := htfs.CollectGarbage(true)
+	must.Nil(err)
+	must.Equal(0, len(report.Removed))
+	must.Equal(3, report.Kept)
+
+	catalogs := htfs.Catalogs()
+	must.Equal(2, len(catalogs))
+	must.Nil(os.Remove(filepath.Join(common.HololibCatalogLocation(), catalogs[0])))
+
+	report, err = htfs.CollectGarbage(false)
+	must.Nil(err)
+	must.Equal(0, len(report.Removed))
+
+	old := time.Now().Add(-2 * time.Hour)
+	filepath.Walk(common.HololibLibraryLocation(), func(fullpath string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			os.Chtimes(fullpath, old, old)
+		}
+		return nil
+	})
+
+	report, err = htfs.CollectGarbage(true)
+	must.Nil(err)
+	must.True(report.Dryrun)
+	must.Equal(1, len(report.Removed))
+	must.Equal(1, report.Catalogs)
+	must.True(report.Freed > 0)
+	must.True(pathlib.IsFile(library.ExactLocation(report.Removed[0])))
+
+	report, err = htfs.CollectGarbage(false)
+	must.Nil(err)
+	must.Equal(1, len(report.Removed))
+	must.Equal(2, report.Kept)
+	wont.True(pathlib.IsFile(library.ExactLocation(report.Removed[0])))
+
+	usage, err := htfs.DiskUsage()
+	must.Nil(err)
+	must.Equal(int64(0), usage.Orphaned)
+}
@@ -0,0 +1,295 @@
+package htfs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/robocorp/rcc/anywork"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+// IntegrityReport tells damaged blobs (path to digest), blobs missing from
+// library, orphan blobs not referenced by any catalog, catalogs that could
+// not be read, and catalogs affected by damaged or missing blobs. Purged are
+// those affected catalogs that were actually removed.
+type IntegrityReport struct {
+	Dryrun     bool              `json:"dryrun,omitempty"`
+	Damaged    map[string]string `json:"damaged"`
+	Missing    []string          `json:"missing"`
+	Orphans    []string          `json:"orphans"`
+	Unreadable []string          `json:"unreadable"`
+	Affected   []string          `json:"affected"`
+	Repaired   map[string]string `json:"repaired,omitempty"`
+	Purged     []string          `json:"purged"`
+}
+
+// CheckIntegrity verifies all hololib blobs against their digests, removes
+// blobs not referenced by any catalog, and purges catalogs which refer to
+// damaged blobs (so that they get rebuilt on next use).
+func CheckIntegrity() (report *IntegrityReport, err error) {
+	return checkIntegrity(false, false, "")
+}
+
+// InspectIntegrity is like CheckIntegrity, but only reports problems, and
+// does not remove anything (for monitoring).
+func InspectIntegrity() (report *IntegrityReport, err error) {
+	return checkIntegrity(false, true, "")
+}
+
+// RepairIntegrity is like CheckIntegrity, but also finds blobs missing from
+// library, and re-lifts missing and damaged blobs (in parallel) from intact
+// files in live spaces, or from given source hololib. Only catalogs which
+// still have broken blobs after that are purged.
+func RepairIntegrity(source string) (report *IntegrityReport, err error) {
+	return checkIntegrity(true, false, source)
+}
+
+func orphanBlobs(tree *Dir, known map[string]map[string]bool, result []string) []string {
+	for _, subdir := range tree.Dirs {
+		result = orphanBlobs(subdir, known, result)
+	}
+	for name, _ := range tree.Files {
+		if _, ok := known[name]; !ok {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+func checkIntegrity(repair, dryrun bool, source string) (report *IntegrityReport, err error) {
+	defer fail.Around(&err)
+
+	common.Timeline("holotree integrity check start")
+	defer common.Timeline("holotree integrity check done")
+	fs, err := NewRoot(common.HololibLibraryLocation())
+	fail.On(err != nil, "%s", err)
+	common.Timeline("holotree integrity lift")
+	err = fs.Lift()
+	fail.On(err != nil, "%s", err)
+	common.Timeline("holotree integrity hasher")
+	store, err := hololibStore()
+	fail.On(err != nil, "%s", err)
+	known, unreadable := loadHololibHashes()
+	err = fs.AllFiles(hashingTask(known, !dryrun))
+	fail.On(err != nil, "%s", err)
+	report = &IntegrityReport{
+		Dryrun:     dryrun,
+		Damaged:    make(map[string]string),
+		Missing:    missingBlobs(store, known),
+		Orphans:    orphanBlobs(fs.Tree, known, []string{}),
+		Unreadable: unreadable,
+		Affected:   []string{},
+		Purged:     []string{},
+	}
+	sort.Strings(report.Orphans)
+	common.Timeline("holotree integrity collector")
+	err = fs.Treetop(IntegrityCheck(report.Damaged))
+	common.Timeline("holotree integrity report")
+	fail.On(err != nil, "%s", err)
+	if packs, ok := store.(*packBlobStore); ok {
+		err = packs.damagedBlobs(known, report.Damaged)
+		fail.On(err != nil, "%s", err)
+	}
+	broken := make(map[string]bool)
+	for k, _ := range report.Damaged {
+		broken[filepath.Base(k)] = true
+	}
+	report.Affected = affectedCatalogs(broken, report.Missing, known)
+	if repair {
+		common.Timeline("holotree integrity repair")
+		for _, digest := range report.Missing {
+			broken[digest] = true
+		}
+		report.Repaired, err = repairBlobs(store, broken, source)
+		fail.On(err != nil, "%s", err)
+		for digest, _ := range report.Repaired {
+			delete(broken, digest)
+		}
+	}
+	purge := make(map[string]bool)
+	for digest, _ := range broken {
+		found, ok := known[digest]
+		if !ok {
+			continue
+		}
+		for catalog, _ := range found {
+			purge[catalog] = true
+		}
+	}
+	for k, _ := range purge {
+		if dryrun {
+			break
+		}
+		report.Purged = append(report.Purged, k)
+		anywork.Backlog(RemoveFile(k))
+	}
+	sort.Strings(report.Purged)
+	err = anywork.Sync()
+	fail.On(err != nil, "%s", err)
+	return report, nil
+}
+
+func affectedCatalogs(broken map[string]bool, missing []string, known map[string]map[string]bool) []string {
+	affected := make(map[string]bool)
+	for _, digest := range missing {
+		for catalog, _ := range known[digest] {
+			affected[catalog] = true
+		}
+	}
+	for digest, _ := range broken {
+		for catalog, _ := range known[digest] {
+			affected[catalog] = true
+		}
+	}
+	result := make([]string, 0, len(affected))
+	for catalog, _ := range affected {
+		result = append(result, catalog)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// missingBlobs are not in own blob store, nor in system library below it.
+func missingBlobs(store BlobStore, known map[string]map[string]bool) []string {
+	result := []string{}
+	for digest, _ := range known {
+		if !store.Has(digest) {
+			result = append(result, digest)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// IntactFiles maps digests to files in space, which can be lifted back as
+// they are (relocated files have space path in them, so they cannot).
+func IntactFiles(target map[string]string) Treetop {
+	var tool Treetop
+	tool = func(path string, it *Dir) error {
+		for name, subdir := range it.Dirs {
+			tool(filepath.Join(path, name), subdir)
+		}
+		for name, file := range it.Files {
+			if !file.Relocated() && len(file.Chunks) == 0 {
+				target[file.Digest] = filepath.Join(path, name)
+			}
+		}
+		return nil
+	}
+	return tool
+}
+
+type blobCandidate struct {
+	filename   string
+	compressed bool
+}
+
+func repairCandidates(broken map[string]bool, source string) map[string][]*blobCandidate {
+	result := make(map[string][]*blobCandidate)
+	if len(source) > 0 {
+		for digest, _ := range broken {
+			location := filepath.Join(source, "library", digest[:2], digest[2:4], digest[4:6], digest)
+			if pathlib.IsFile(location) {
+				result[digest] = append(result[digest], &blobCandidate{location, true})
+			}
+		}
+	}
+	for _, space := range Spaces() {
+		intact := make(map[string]string)
+		space.Treetop(IntactFiles(intact))
+		for digest, _ := range broken {
+			location, ok := intact[digest]
+			if ok && pathlib.IsFile(location) {
+				result[digest] = append(result[digest], &blobCandidate{location, false})
+			}
+		}
+	}
+	return result
+}
+
+// relift writes candidate as blob, but only if its content really matches
+// the digest. Stores which do not keep blobs as files get it through Put,
+// after damaged copy is forgotten.
+func relift(store BlobStore, candidate *blobCandidate, digest string) (err error) {
+	defer fail.Around(&err)
+
+	directory := filepath.Join(common.HololibLibraryLocation(), digest[:2], digest[2:4], digest[4:6])
+	err = os.MkdirAll(directory, 0o755)
+	fail.On(err != nil, "Could not create %q -> %v", directory, err)
+	filename := filepath.Join(directory, digest)
+	partname := fmt.Sprintf("%s.part%s", filename, <-common.Identities)
+	defer os.Remove(partname)
+
+	source, err := os.Open(candidate.filename)
+	fail.On(err != nil, "%v", err)
+	defer source.Close()
+	if candidate.compressed {
+		err = receiveInto(source, partname)
+		fail.On(err != nil, "%v", err)
+	} else {
+		sink, err := os.Create(partname)
+		fail.On(err != nil, "%v", err)
+		defer sink.Close()
+		buffered := bufio.NewReader(source)
+		writer, err := compressingWriter(sink, buffered)
+		fail.On(err != nil, "%v", err)
+		_, err = io.Copy(writer, buffered)
+		fail.On(err != nil, "%v", err)
+		fail.On(writer.Close() != nil, "Could not compress %q.", candidate.filename)
+		fail.On(sink.Close() != nil, "Could not write %q.", partname)
+	}
+	actual, err := matchingDigest(partname, digest, false)
+	fail.On(err != nil, "%v", err)
+	fail.On(actual != digest, "Digest mismatch for %q, expected %q, got %q.", candidate.filename, digest, actual)
+	locker, err := keyLock(digest)
+	fail.On(err != nil, "%v", err)
+	defer locker.Release()
+	if _, files := store.(FileBlobStore); files {
+		return TryRename("repair", partname, filename)
+	}
+	err = store.Remove(digest)
+	fail.On(err != nil, "%v", err)
+	repaired, err := os.Open(partname)
+	fail.On(err != nil, "%v", err)
+	defer repaired.Close()
+	return store.Put(digest, repaired)
+}
+
+func repairBlobs(store BlobStore, broken map[string]bool, source string) (map[string]string, error) {
+	if len(source) > 0 && !pathlib.IsDir(filepath.Join(source, "library")) {
+		return nil, fmt.Errorf("Repair source %q is not a hololib (no library directory in it).", source)
+	}
+	candidates := repairCandidates(broken, source)
+	repaired := make(map[string]string)
+	var guard sync.Mutex
+	for digest, _ := range broken {
+		options := candidates[digest]
+		if len(options) == 0 {
+			common.Debug("No repair source for blob %q.", digest)
+			continue
+		}
+		digest := digest
+		anywork.Backlog(func() {
+			for _, candidate := range options {
+				err := relift(store, candidate, digest)
+				if err != nil {
+					common.Debug("Repair of %q from %q failed, reason: %v", digest, candidate.filename, err)
+					continue
+				}
+				guard.Lock()
+				repaired[digest] = candidate.filename
+				guard.Unlock()
+				return
+			}
+		})
+	}
+	err := anywork.Sync()
+	return repaired, err
+}
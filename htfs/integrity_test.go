@@ -0,0 +1,108 @@
+package htfs_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+func TestCanRepairBrokenBlobs(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	common.ControllerType = "unittest"
+	blueprint := []byte("repair: unittest")
+	library := testLibrary(t, map[string]string{
+		"first.txt":  "first file",
+		"second.txt": "second file",
+	})
+	must.Nil(library.Record(blueprint))
+	_, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("repair"))
+	must.Nil(err)
+
+	hashes := htfs.LoadHololibHashes()
+	must.Equal(2, len(hashes))
+	blobs := []string{}
+	for digest, _ := range hashes {
+		blobs = append(blobs, library.ExactLocation(digest))
+	}
+	must.Nil(os.Remove(blobs[0]))
+	must.Nil(ioutil.WriteFile(blobs[1], []byte("garbage"), 0o644))
+
+	report, err := htfs.RepairIntegrity("")
+	must.Nil(err)
+	must.Equal(1, len(report.Damaged))
+	must.Equal(1, len(report.Missing))
+	must.Equal(2, len(report.Repaired))
+	must.Equal(0, len(report.Purged))
+	must.True(pathlib.IsFile(blobs[0]))
+
+	report, err = htfs.CheckIntegrity()
+	must.Nil(err)
+	must.Equal(0, len(report.Damaged))
+	must.Equal(1, len(htfs.Catalogs()))
+
+	must.Nil(os.Remove(blobs[0]))
+	for space, metafile := range htfs.Spacemap() {
+		must.Nil(os.RemoveAll(space))
+		must.Nil(os.Remove(metafile))
+	}
+	report, err = htfs.RepairIntegrity("")
+	must.Nil(err)
+	must.Equal(1, len(report.Missing))
+	must.Equal(0, len(report.Repaired))
+	must.Equal(1, len(report.Purged))
+	wont.True(pathlib.IsFile(blobs[0]))
+	must.Equal(0, len(htfs.Catalogs()))
+}
+
+func TestCanInspectIntegrityWithoutChanges(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	common.ControllerType = "unittest"
+	blueprint := []byte("inspect: unittest")
+	library := testLibrary(t, map[string]string{
+		"first.txt":  "first file",
+		"second.txt": "second file",
+	})
+	must.Nil(library.Record(blueprint))
+
+	hashes := htfs.LoadHololibHashes()
+	must.Equal(2, len(hashes))
+	blobs := []string{}
+	for digest, _ := range hashes {
+		blobs = append(blobs, library.ExactLocation(digest))
+	}
+	must.Nil(os.Remove(blobs[0]))
+	must.Nil(ioutil.WriteFile(blobs[1], []byte("garbage"), 0o644))
+	orphan := library.ExactLocation(fmt.Sprintf("%02x", sha256.Sum256([]byte("orphan"))))
+	must.Nil(os.MkdirAll(filepath.Dir(orphan), 0o755))
+	must.Nil(ioutil.WriteFile(orphan, []byte("orphan"), 0o644))
+
+	report, err := htfs.InspectIntegrity()
+	must.Nil(err)
+	must.True(report.Dryrun)
+	must.Equal(1, len(report.Damaged))
+	must.Equal(1, len(report.Missing))
+	must.Equal(1, len(report.Orphans))
+	must.Equal(1, len(report.Affected))
+	must.Equal(0, len(report.Unreadable))
+	must.Equal(0, len(report.Purged))
+	must.True(pathlib.IsFile(orphan))
+	must.Equal(1, len(htfs.Catalogs()))
+
+	report, err = htfs.CheckIntegrity()
+	must.Nil(err)
+	wont.True(report.Dryrun)
+	must.Equal(1, len(report.Orphans))
+	must.Equal(1, len(report.Purged))
+	wont.True(pathlib.IsFile(orphan))
+	must.Equal(0, len(htfs.Catalogs()))
+}
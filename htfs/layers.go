@@ -0,0 +1,80 @@
+package htfs
+
+import (
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/fail"
+)
+
+// copyingLibrary hides mutable side of library, so that files are always
+// copied (never hardlinked or reflinked), since stage is modified after
+// layer is restored into it.
+type copyingLibrary struct {
+	Library
+}
+
+type condaLayers struct {
+	library *hololib
+}
+
+// EnvironmentLayers returns conda layers of library, or nil if library
+// cannot have them.
+func EnvironmentLayers(tree MutableLibrary) conda.Layers {
+	library, ok := tree.(*hololib)
+	if !ok {
+		return nil
+	}
+	return &condaLayers{library: library}
+}
+
+func (it *condaLayers) HasLayer(blueprint []byte) bool {
+	return it.library.HasBlueprint(blueprint)
+}
+
+func (it *condaLayers) RestoreLayer(blueprint []byte) bool {
+	if !it.library.HasBlueprint(blueprint) {
+		common.Debug("Conda layer %q is not in hololib.", BlueprintHash(blueprint))
+		return false
+	}
+	err := it.library.restoreLayer(blueprint)
+	if err != nil {
+		common.Log("Could not restore conda layer %q, reason: %v", BlueprintHash(blueprint), err)
+		return false
+	}
+	return true
+}
+
+func (it *condaLayers) RecordLayer(blueprint []byte) error {
+	key := BlueprintHash(blueprint)
+	common.Debug("Recording conda layer %q.", key)
+	delete(it.library.queryCache, key)
+	return it.library.Record(blueprint)
+}
+
+// restoreLayer restores layer catalog into stage, instead of space.
+func (it *hololib) restoreLayer(blueprint []byte) (err error) {
+	defer fail.Around(&err)
+
+	key := BlueprintHash(blueprint)
+	common.TimelineBegin("holotree layer restore start [%s]", key)
+	defer common.TimelineEnd()
+	catalog := it.CatalogPath(key)
+	fs, err := NewRoot(it.Stage())
+	fail.On(err != nil, "Failed to create stage -> %v", err)
+	err = fs.LoadFrom(catalog)
+	fail.On(err != nil, "Failed to load catalog %s -> %v", key, err)
+	err = fs.CompatiblePlatform()
+	fail.On(err != nil, "Refusing to restore layer %s -> %v", key, err)
+	err = ValidRelocations()
+	fail.On(err != nil, "Invalid relocations setting -> %v", err)
+	err = fs.Relocate(it.Stage())
+	fail.On(err != nil, "Failed to relocate %s -> %v", it.Stage(), err)
+	err = fs.Treetop(MakeBranches)
+	fail.On(err != nil, "Failed to make branches -> %v", err)
+	score := &stats{}
+	err = fs.AllDirs(RestoreDirectory(&copyingLibrary{it}, fs, make(map[string]string), score))
+	fail.On(err != nil, "Failed to restore directories -> %v", err)
+	countRestore(score)
+	touchCatalog(catalog)
+	return nil
+}
@@ -0,0 +1,55 @@
+package htfs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/settings"
+)
+
+func TestCondaLayerIsRestoredIntoStageAsCopies(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	hololib := settings.Global.HololibSettings()
+	mode, compression := hololib.RestoreMode, hololib.Compression
+	defer func() {
+		hololib.RestoreMode, hololib.Compression = mode, compression
+	}()
+	hololib.RestoreMode, hololib.Compression = "hardlink", "none"
+
+	common.ControllerType = "unittest"
+	library := testLibrary(t, nil)
+	layers := htfs.EnvironmentLayers(library)
+	wont.Nil(layers)
+	must.Nil(htfs.EnvironmentLayers(htfs.Virtual()))
+
+	blueprint := []byte("# rcc conda layer\ndependencies:\n- python=3.9.13\n")
+	wont.True(layers.RestoreLayer(blueprint))
+
+	must.Nil(htfs.CleanupHolotreeStage(library))
+	stage := library.Stage()
+	python := filepath.Join(stage, "bin", "python")
+	must.Nil(os.MkdirAll(filepath.Dir(python), 0o755))
+	must.Nil(ioutil.WriteFile(python, []byte("conda python"), 0o755))
+	must.Nil(layers.RecordLayer(blueprint))
+
+	must.Nil(htfs.CleanupHolotreeStage(library))
+	_, err := os.Stat(python)
+	wont.Nil(err)
+
+	must.True(layers.RestoreLayer(blueprint))
+	content, err := ioutil.ReadFile(python)
+	must.Nil(err)
+	must.Equal("conda python", string(content))
+
+	must.Nil(ioutil.WriteFile(python, []byte("changed by pip"), 0o755))
+	must.True(layers.RestoreLayer(blueprint))
+	content, err = ioutil.ReadFile(python)
+	must.Nil(err)
+	must.Equal("conda python", string(content))
+}
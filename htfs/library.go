@@ -33,6 +33,7 @@ type stats struct {
 	sync.Mutex
 	total uint64
 	dirty uint64
+	plan  *RestorePlan
 }
 
 func (it *stats) Dirty(dirty bool) {
@@ -58,7 +59,8 @@ type MutableLibrary interface {
 
 	Identity() string
 	ExactLocation(string) string
-	Export([]string, string) error
+	HasBlob(string) bool
+	Export([]string, []string, string) error
 	Location(string) string
 	Record([]byte) error
 	Stage() string
@@ -67,19 +69,47 @@ type MutableLibrary interface {
 type hololib struct {
 	identity   uint64
 	basedir    string
+	system     string
+	store      BlobStore
 	queryCache map[string]bool
 }
 
 func (it *hololib) Open(digest string) (readable io.Reader, closer Closer, err error) {
-	return delegateOpen(it, digest, true)
+	source, err := it.store.Open(digest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to open blob %q from %s store -> %v", digest, it.store.Name(), err)
+	}
+	return storedReader(source, digest, digest, true)
+}
+
+// fileStore is blob store of library, when its blobs are plain files.
+func (it *hololib) fileStore() (FileBlobStore, bool) {
+	files, ok := it.store.(FileBlobStore)
+	return files, ok
 }
 
+// Location is directory where new blob file goes (with file based store).
 func (it *hololib) Location(digest string) string {
-	return filepath.Join(common.HololibLibraryLocation(), digest[:2], digest[2:4], digest[4:6])
+	files, ok := it.fileStore()
+	if !ok {
+		return filepath.Join(common.HololibLibraryLocation(), digest[:2], digest[2:4], digest[4:6])
+	}
+	return files.Location(digest)
 }
 
+// ExactLocation is where blob can be read from: own library, or system
+// library when blob is only available there. New blobs go into Location.
+// With stores that do not keep blobs as files, there is no such file.
 func (it *hololib) ExactLocation(digest string) string {
-	return filepath.Join(common.HololibLibraryLocation(), digest[:2], digest[2:4], digest[4:6], digest)
+	files, ok := it.fileStore()
+	if !ok {
+		return exactBlobLocation(it.system, digest)
+	}
+	return files.ExactLocation(digest)
+}
+
+func (it *hololib) HasBlob(digest string) bool {
+	return it.store.Has(digest)
 }
 
 func (it *hololib) Identity() string {
@@ -95,18 +125,29 @@ func (it *hololib) Stage() string {
 	return stage
 }
 
+// zipseen writes every entry into zip only once, so blobs shared by multiple
+// catalogs are stored once; shared counts those skipped repeats.
 type zipseen struct {
 	*zip.Writer
-	seen map[string]bool
+	seen    map[string]bool
+	shared  int
+	known   map[string]bool
+	skipped int
 }
 
-func (it zipseen) Add(fullpath, relativepath string) (err error) {
+func (it *zipseen) Add(fullpath, relativepath string) (err error) {
 	defer fail.Around(&err)
 
+	relativepath = zipName(relativepath)
 	if it.seen[relativepath] {
+		it.shared += 1
 		return nil
 	}
 	it.seen[relativepath] = true
+	if it.known[relativepath] {
+		it.skipped += 1
+		return nil
+	}
 
 	source, err := os.Open(fullpath)
 	fail.On(err != nil, "Could not open: %q -> %v", fullpath, err)
@@ -118,7 +159,15 @@ func (it zipseen) Add(fullpath, relativepath string) (err error) {
 	return nil
 }
 
-func (it *hololib) Export(catalogs []string, archive string) (err error) {
+// Export writes given catalogs and their blobs into archive, and also merges
+// content of other bundles (typically exported on other platforms) into it.
+func (it *hololib) Export(catalogs, bundles []string, archive string) error {
+	_, err := it.export(catalogs, bundles, nil, archive)
+	return err
+}
+
+// export leaves out blobs with digests in known (for delta bundles).
+func (it *hololib) export(catalogs, bundles []string, known map[string]bool, archive string) (zipper *zipseen, err error) {
 	defer fail.Around(&err)
 
 	common.TimelineBegin("holotree export start")
@@ -129,9 +178,13 @@ func (it *hololib) Export(catalogs []string, archive string) (err error) {
 	writer := zip.NewWriter(handle)
 	defer writer.Close()
 
-	zipper := &zipseen{
-		writer,
-		make(map[string]bool),
+	zipper = &zipseen{
+		Writer: writer,
+		seen:   make(map[string]bool),
+		known:  make(map[string]bool),
+	}
+	for digest, _ := range known {
+		zipper.known[blobName(digest)] = true
 	}
 
 	for _, name := range catalogs {
@@ -140,6 +193,10 @@ func (it *hololib) Export(catalogs []string, archive string) (err error) {
 		fail.On(err != nil, "Could not get relative location for catalog -> %v.", err)
 		err = zipper.Add(catalog, relative)
 		fail.On(err != nil, "Could not add catalog to zip -> %v.", err)
+		if pathlib.IsFile(SignatureFile(catalog)) {
+			err = zipper.Add(SignatureFile(catalog), SignatureFile(relative))
+			fail.On(err != nil, "Could not add catalog signature to zip -> %v.", err)
+		}
 
 		fs, err := NewRoot(".")
 		fail.On(err != nil, "Could not create root location -> %v.", err)
@@ -148,47 +205,122 @@ func (it *hololib) Export(catalogs []string, archive string) (err error) {
 		err = fs.Treetop(ZipRoot(it, fs, zipper))
 		fail.On(err != nil, "Could not zip catalog %s -> %v.", catalog, err)
 	}
-	return nil
+	for _, bundle := range bundles {
+		err = zipper.Merge(bundle)
+		fail.On(err != nil, "Could not merge bundle %q -> %v.", bundle, err)
+	}
+	common.Debug("Exported %d catalog(s) and %d entries into %q, skipping %d shared blob reference(s) and %d known blob(s).", len(catalogs), len(zipper.seen)-zipper.skipped, archive, zipper.shared, zipper.skipped)
+	return zipper, nil
 }
 
 func (it *hololib) Record(blueprint []byte) error {
 	defer common.Stopwatch("Holotree recording took:").Debug()
 	key := BlueprintHash(blueprint)
+	err := writableHololib(key)
+	if err != nil {
+		return err
+	}
+	if common.StageSlot > 0 {
+		// stage slot builds hold only their own lock
+		locker, err := pathlib.Locker(common.HolotreeLock(), 30000)
+		if err != nil {
+			return err
+		}
+		defer locker.Release()
+	}
 	common.Timeline("holotree record start %s", key)
 	fs, err := NewRoot(it.Stage())
 	if err != nil {
 		return err
 	}
-	err = fs.Lift()
+	exclusions := RecordingExclusions(fs.Path)
+	err = fs.LiftExcluding(exclusions)
 	if err != nil {
 		return err
 	}
-	common.Timeline("holotree (re)locator start")
-	err = fs.AllFiles(Locator(it.Identity()))
+	fs.Excludes = exclusions.Patterns()
+	err = guardRecordCollisions(fs)
 	if err != nil {
 		return err
 	}
-	common.Timeline("holotree (re)locator done")
-	fs.Blueprint = key
-	catalog := it.CatalogPath(key)
-	err = fs.SaveAs(catalog)
+	common.Timeline("holotree (re)locator start")
+	fs.Algorithm = DigestAlgorithm()
+	err = fs.AllFiles(Locator(it.Identity(), fs.Algorithm))
 	if err != nil {
 		return err
 	}
+	common.Timeline("holotree (re)locator done")
+	fs.Blueprint = key
+	fs.Provenance = newProvenance(key)
+	catalog := it.localCatalogPath(key)
 	score := &stats{}
 	common.Timeline("holotree lift start %q", catalog)
+	finished := progressStart("lift")
 	err = fs.Treetop(ScheduleLifters(it, score))
+	finished()
+	shareChunks(fs.Tree)
 	common.Timeline("holotree lift done")
 	defer common.Timeline("- new %d/%d", score.dirty, score.total)
 	common.Debug("Holotree new workload: %d/%d\n", score.dirty, score.total)
-	return err
+	countRecord(score)
+	if err != nil {
+		return err
+	}
+	// catalog is saved only after all its blobs are in library, so that
+	// other processes never see catalog referring to missing blobs
+	return saveCatalog(fs, catalog)
 }
 
-func (it *hololib) CatalogPath(key string) string {
+func saveCatalog(fs *Root, catalog string) error {
+	locker, err := keyLock(filepath.Base(catalog))
+	if err != nil {
+		return err
+	}
+	defer locker.Release()
+	return writeCatalog(fs, catalog)
+}
+
+// writeCatalog replaces catalog atomically, and caller must hold its lock.
+func writeCatalog(fs *Root, catalog string) error {
+	// recorded catalog is new content, so old signature is not valid anymore
+	if pathlib.IsFile(SignatureFile(catalog)) {
+		err := os.Remove(SignatureFile(catalog))
+		if err != nil {
+			return err
+		}
+	}
+	return fs.SaveAs(catalog)
+}
+
+// touchCatalog marks catalog used, except when ROBOCORP_HOME is split away
+// from writable home, since then hololib is (possibly read-only) seed, which
+// is never written to.
+func touchCatalog(catalog string) {
+	if common.SplitHome() {
+		return
+	}
+	pathlib.TouchWhen(catalog, time.Now())
+}
+
+func (it *hololib) localCatalogPath(key string) string {
 	name := fmt.Sprintf("%s.%s", key, common.Platform())
 	return filepath.Join(common.HololibCatalogLocation(), name)
 }
 
+// CatalogPath is own catalog, or system library catalog, when only that one
+// exists.
+func (it *hololib) CatalogPath(key string) string {
+	local := it.localCatalogPath(key)
+	if len(it.system) == 0 || pathlib.IsFile(local) {
+		return local
+	}
+	system := filepath.Join(it.system, "catalog", filepath.Base(local))
+	if pathlib.IsFile(system) {
+		return system
+	}
+	return local
+}
+
 func (it *hololib) HasBlueprint(blueprint []byte) bool {
 	key := BlueprintHash(blueprint)
 	found, ok := it.queryCache[key]
@@ -229,6 +361,9 @@ func (it *hololib) queryBlueprint(key string) bool {
 func Catalogs() []string {
 	result := make([]string, 0, 10)
 	for _, catalog := range pathlib.Glob(common.HololibCatalogLocation(), "[0-9a-f]*.*") {
+		if isSignatureFile(catalog) {
+			continue
+		}
 		result = append(result, catalog)
 	}
 	sort.Strings(result)
@@ -268,31 +403,74 @@ func ControllerSpaceName(client, tag []byte) string {
 }
 
 func (it *hololib) Restore(blueprint, client, tag []byte) (result string, err error) {
+	return it.restore(BlueprintHash(blueprint), ControllerSpaceName(client, tag), client, tag, nil)
+}
+
+// PlanRestore tells what restoring blueprint into space would add, replace,
+// and remove, without changing anything in space.
+func (it *hololib) PlanRestore(blueprint, client, tag []byte) (plan *RestorePlan, err error) {
+	plan = &RestorePlan{
+		Blueprint: BlueprintHash(blueprint),
+		Added:     []string{},
+		Replaced:  []string{},
+		Removed:   []string{},
+	}
+	plan.Path, err = it.restore(plan.Blueprint, ControllerSpaceName(client, tag), client, tag, plan)
+	if err != nil {
+		return nil, err
+	}
+	plan.sort()
+	return plan, nil
+}
+
+// restore makes space match catalog, or with plan only notes what it would
+// do, which is also how drift of space is found.
+func (it *hololib) restore(key, name string, client, tag []byte, plan *RestorePlan) (result string, err error) {
 	defer fail.Around(&err)
 	defer common.Stopwatch("Holotree restore took:").Debug()
-	key := BlueprintHash(blueprint)
 	catalog := it.CatalogPath(key)
 	common.TimelineBegin("holotree space restore start [%s]", key)
 	defer common.TimelineEnd()
-	name := ControllerSpaceName(client, tag)
 	fs, err := NewRoot(it.Stage())
 	fail.On(err != nil, "Failed to create stage -> %v", err)
 	err = fs.LoadFrom(catalog)
 	fail.On(err != nil, "Failed to load catalog %s -> %v", catalog, err)
-	metafile := filepath.Join(fs.HolotreeBase(), fmt.Sprintf("%s.meta", name))
-	targetdir := filepath.Join(fs.HolotreeBase(), name)
-	lockfile := filepath.Join(fs.HolotreeBase(), fmt.Sprintf("%s.lck", name))
+	err = fs.CompatiblePlatform()
+	fail.On(err != nil, "Refusing to restore catalog %s -> %v", catalog, err)
+	base := fs.HolotreeBase()
+	if len(it.system) > 0 && pathlib.IsWithin(it.system, catalog) {
+		// spaces of system catalogs go into user's own holotree
+		base = common.HolotreeLocation()
+	}
+	metafile := filepath.Join(base, fmt.Sprintf("%s.meta", name))
+	targetdir := filepath.Join(base, name)
+	lockfile := filepath.Join(base, fmt.Sprintf("%s.lck", name))
 	locker, err := pathlib.Locker(lockfile, 30000)
 	fail.On(err != nil, "Could not get lock for %s. Quiting.", targetdir)
 	defer locker.Release()
-	journal.Post("space-used", metafile, "normal holotree with blueprint %s from %s", key, catalog)
+	if plan == nil {
+		journal.Post("space-used", metafile, "normal holotree with blueprint %s from %s", key, catalog)
+	}
+	if plan == nil && interruptedRestore(targetdir) {
+		common.Log("%sPrevious restore of %q was interrupted, restoring it from scratch.%s", pretty.Yellow, targetdir, pretty.Reset)
+		err = TryRemoveAll("interrupted", targetdir)
+		fail.On(err != nil, "Failed to remove interrupted space %q -> %v", targetdir, err)
+		if pathlib.IsFile(metafile) {
+			err = TryRemove("metafile", metafile)
+			fail.On(err != nil, "Failed to remove metafile %q -> %v", metafile, err)
+		}
+	}
 	currentstate := make(map[string]string)
 	mode := fmt.Sprintf("new space for %q", key)
+	created := time.Now().Format(time.RFC3339)
 	shadow, err := NewRoot(targetdir)
 	if err == nil {
 		err = shadow.LoadFrom(metafile)
 	}
 	if err == nil {
+		if len(shadow.Created) > 0 {
+			created = shadow.Created
+		}
 		if key == shadow.Blueprint {
 			mode = fmt.Sprintf("cleaned up space for %q", key)
 		} else {
@@ -304,24 +482,41 @@ func (it *hololib) Restore(blueprint, client, tag []byte) (result string, err er
 	}
 	common.Timeline("mode: %s", mode)
 	common.Debug("Holotree operating mode is: %s", mode)
+	err = ValidRelocations()
+	fail.On(err != nil, "Invalid relocations setting -> %v", err)
+	fs.Sparsify(SparseSkips(string(tag)))
+	resolveRestoreCollisions(fs)
 	err = fs.Relocate(targetdir)
 	fail.On(err != nil, "Failed to relocate %s -> %v", targetdir, err)
+	if plan != nil {
+		err = fs.AllDirs(RestoreDirectory(it, fs, currentstate, &stats{plan: plan}))
+		fail.On(err != nil, "Failed to plan restore of directories -> %v", err)
+		return targetdir, nil
+	}
+	err = markRestoring(targetdir)
+	fail.On(err != nil, "Failed to mark restore of %q -> %v", targetdir, err)
 	common.TimelineBegin("holotree make branches start")
 	err = fs.Treetop(MakeBranches)
 	common.TimelineEnd()
 	fail.On(err != nil, "Failed to make branches -> %v", err)
 	score := &stats{}
 	common.TimelineBegin("holotree restore start")
+	finished := progressStart("restore")
 	err = fs.AllDirs(RestoreDirectory(it, fs, currentstate, score))
+	finished()
 	fail.On(err != nil, "Failed to restore directories -> %v", err)
 	common.TimelineEnd()
 	defer common.Timeline("- dirty %d/%d", score.dirty, score.total)
 	common.Debug("Holotree dirty workload: %d/%d\n", score.dirty, score.total)
+	countRestore(score)
 	fs.Controller = string(client)
 	fs.Space = string(tag)
+	fs.Created = created
 	err = fs.SaveAs(metafile)
 	fail.On(err != nil, "Failed to save metafile %q -> %v", metafile, err)
-	pathlib.TouchWhen(catalog, time.Now())
+	err = restoreDone(targetdir)
+	fail.On(err != nil, "Failed to finish restore of %q -> %v", targetdir, err)
+	touchCatalog(catalog)
 	planfile := filepath.Join(targetdir, "rcc_plan.log")
 	if pathlib.FileExist(planfile) {
 		common.Log("%sInstallation plan is: %v%s", pretty.Yellow, planfile, pretty.Reset)
@@ -333,8 +528,10 @@ func (it *hololib) Restore(blueprint, client, tag []byte) (result string, err er
 	return targetdir, nil
 }
 
+// BlueprintHash identifies environment by its blueprint, and by configured
+// recording exclusions, since those change what ends up in catalog.
 func BlueprintHash(blueprint []byte) string {
-	return textual(sipit(blueprint), 0)
+	return textual(sipit(excludingBlueprint(blueprint)), 0)
 }
 
 func sipit(key []byte) uint64 {
@@ -363,6 +560,26 @@ func makedirs(prefix string, suffixes ...string) error {
 	return nil
 }
 
+// keyLock serializes writing of one blob or catalog into own hololib between
+// processes (like parallel CI jobs) sharing it.
+func keyLock(key string) (pathlib.Releaser, error) {
+	return pathlib.KeyLocker(common.HololibLockLocation(), key, 30000)
+}
+
+// writableHololib fails early when ROBOCORP_HOME is split away from writable
+// home and its hololib is read-only, so new environments cannot be recorded.
+func writableHololib(key string) error {
+	if !common.SplitHome() {
+		return nil
+	}
+	probe := filepath.Join(common.HololibLibraryLocation(), fmt.Sprintf(".probe_%s", <-common.Identities))
+	err := os.WriteFile(probe, []byte{}, 0o644)
+	if err != nil {
+		return fmt.Errorf("Hololib at %q is read-only, and environment %s is not pre-seeded there. Reason: %v", common.HololibLocation(), key, err)
+	}
+	return os.Remove(probe)
+}
+
 func New() (MutableLibrary, error) {
 	err := makedirs(common.HololibLocation(), "library", "catalog")
 	if err != nil {
@@ -370,9 +587,20 @@ func New() (MutableLibrary, error) {
 	}
 	basedir := common.RobocorpHome()
 	identity := strings.ToLower(fmt.Sprintf("%s %s", runtime.GOOS, runtime.GOARCH))
+	if common.StageSlot > 0 {
+		// separate stage, but with same length, so relocations still work
+		identity = fmt.Sprintf("%s slot %d", identity, common.StageSlot)
+	}
+	system := SystemHololib()
+	store, err := openBlobStore(system)
+	if err != nil {
+		return nil, err
+	}
 	return &hololib{
 		identity:   sipit([]byte(identity)),
 		basedir:    basedir,
+		system:     system,
+		store:      store,
 		queryCache: make(map[string]bool),
 	}, nil
 }
@@ -0,0 +1,83 @@
+package htfs
+
+import (
+	"os"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+)
+
+const (
+	restoreCopy     = `copy`
+	restoreReflink  = `reflink`
+	restoreHardlink = `hardlink`
+)
+
+// RestoreMode tells how files are dropped into spaces from blobs stored
+// without compression: "copy" (default), "reflink" (copy-on-write clone, on
+// filesystems supporting it), or "hardlink" (shares blob itself, so space
+// files must never be modified in place).
+func RestoreMode() string {
+	mode := strings.ToLower(strings.TrimSpace(settings.Global.RestoreMode()))
+	switch mode {
+	case restoreReflink, restoreHardlink:
+		return mode
+	default:
+		return restoreCopy
+	}
+}
+
+func plainBlob(filename string) bool {
+	source, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer source.Close()
+	reader, compressed, err := decompressingReader(source)
+	if err != nil {
+		return false
+	}
+	reader.Close()
+	return !compressed
+}
+
+// linkBlob tries to create partname as link (or clone) of blob, instead of
+// decompressing it, and tells if that succeeded. Failure is not an error,
+// since caller then just copies content as usual.
+func linkBlob(library Library, digest, partname string, details *File) bool {
+	mode := RestoreMode()
+	if mode == restoreCopy {
+		return false
+	}
+	located, ok := library.(MutableLibrary)
+	if !ok {
+		return false
+	}
+	blob := located.ExactLocation(digest)
+	if !plainBlob(blob) || !verifiedFile(blob, digest) {
+		return false
+	}
+	if mode == restoreReflink {
+		err := reflinkFile(blob, partname)
+		if err != nil {
+			common.Trace("Reflink %q failed, reason: %v", blob, err)
+			os.Remove(partname)
+			return false
+		}
+		return true
+	}
+	if details.Relocated() {
+		return false
+	}
+	stat, err := os.Stat(blob)
+	if err != nil || stat.Mode() != details.Mode {
+		return false
+	}
+	err = os.Link(blob, partname)
+	if err != nil {
+		common.Trace("Hardlink %q failed, reason: %v", blob, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package htfs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/settings"
+)
+
+func TestCanHardlinkPlainBlobsIntoSpaces(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	config := settings.Global.HololibSettings()
+	mode, compression := config.RestoreMode, common.BlobCompression
+	defer func() {
+		config.RestoreMode, common.BlobCompression = mode, compression
+	}()
+	config.RestoreMode, common.BlobCompression = "hardlink", "none"
+	must.Equal("hardlink", htfs.RestoreMode())
+
+	common.ControllerType = "unittest"
+	blueprint := []byte("hardlink: unittest")
+	library := testLibrary(t, map[string]string{
+		"plain.txt":  "plain content",
+		"archive.gz": string([]byte{0x1f, 0x8b, 0x08, 0x00, 0x42}),
+	})
+	must.Nil(ioutil.WriteFile(filepath.Join(library.Stage(), "relocated.txt"), []byte("path is "+library.Stage()), 0o644))
+	must.Nil(library.Record(blueprint))
+
+	space, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("hardlink"))
+	must.Nil(err)
+
+	catalog, err := htfs.NewRoot(library.Stage())
+	must.Nil(err)
+	must.Equal(1, len(htfs.Catalogs()))
+	must.Nil(catalog.LoadFrom(filepath.Join(common.HololibCatalogLocation(), htfs.Catalogs()[0])))
+	mapped := make(map[string]string)
+	must.Nil(catalog.Treetop(htfs.DigestMapper(mapped)))
+	digests := make(map[string]string)
+	for digest, fullpath := range mapped {
+		digests[filepath.Base(fullpath)] = digest
+	}
+
+	linked := func(name, content string) bool {
+		restored := filepath.Join(space, name)
+		body, err := ioutil.ReadFile(restored)
+		must.Nil(err)
+		must.Equal(content, string(body))
+		blob, err := os.Stat(library.ExactLocation(digests[name]))
+		must.Nil(err)
+		info, err := os.Stat(restored)
+		must.Nil(err)
+		return os.SameFile(blob, info)
+	}
+	must.True(linked("plain.txt", "plain content"))
+	wont.True(linked("relocated.txt", "path is "+space))
+	wont.True(linked("archive.gz", string([]byte{0x1f, 0x8b, 0x08, 0x00, 0x42})))
+
+	drift, err := htfs.CheckSpaceDrift("hardlink")
+	must.Nil(err)
+	wont.True(drift.Dirty())
+}
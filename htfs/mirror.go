@@ -0,0 +1,178 @@
+package htfs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+// MirrorReport tells which catalogs were mirrored, and how many blobs were
+// copied, or skipped since target already had them.
+type MirrorReport struct {
+	Target   string   `json:"target"`
+	Catalogs []string `json:"catalogs"`
+	Blobs    int      `json:"blobs"`
+	Skipped  int      `json:"skipped"`
+}
+
+// mirrorSink is other hololib, either directory or shared holotree server.
+type mirrorSink interface {
+	hasBlob(digest string) bool
+	putBlob(digest string, stored io.Reader) error
+	putCatalog(name, catalog string) error
+	putSignature(name, signature string) error
+}
+
+type directoryMirror struct {
+	location string
+}
+
+func (it *directoryMirror) hasBlob(digest string) bool {
+	return pathlib.IsFile(filepath.Join(it.location, filepath.FromSlash(blobName(digest))))
+}
+
+func (it *directoryMirror) copyInto(target string, source io.Reader) (err error) {
+	defer fail.Around(&err)
+
+	err = os.MkdirAll(filepath.Dir(target), 0o755)
+	fail.On(err != nil, "%v", err)
+	partname := fmt.Sprintf("%s.part%s", target, <-common.Identities)
+	defer os.Remove(partname)
+	sink, err := os.Create(partname)
+	fail.On(err != nil, "%v", err)
+	_, err = io.Copy(sink, source)
+	if err == nil {
+		err = sink.Sync()
+	}
+	sink.Close()
+	fail.On(err != nil, "Could not write %q -> %v", target, err)
+	return TryRename("mirror", partname, target)
+}
+
+func (it *directoryMirror) putBlob(digest string, stored io.Reader) error {
+	return it.copyInto(filepath.Join(it.location, filepath.FromSlash(blobName(digest))), stored)
+}
+
+func (it *directoryMirror) putCatalog(name, catalog string) error {
+	source, err := os.Open(catalog)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	return it.copyInto(filepath.Join(it.location, catalogFolder, name), source)
+}
+
+func (it *directoryMirror) putSignature(name, signature string) error {
+	source, err := os.Open(signature)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	return it.copyInto(SignatureFile(filepath.Join(it.location, catalogFolder, name)), source)
+}
+
+type serverMirror struct {
+	*sharedClient
+}
+
+func (it *serverMirror) hasBlob(digest string) bool {
+	return it.exists(blobPrefix + digest)
+}
+
+func (it *serverMirror) putBlob(digest string, stored io.Reader) error {
+	return it.uploadFrom(blobPrefix+digest, stored)
+}
+
+func (it *serverMirror) putCatalog(name, catalog string) error {
+	return it.upload(catalogPrefix+name, catalog)
+}
+
+func (it *serverMirror) putSignature(name, signature string) error {
+	return it.upload(catalogPrefix+name+signatureSuffix, signature)
+}
+
+func isMirrorUrl(target string) bool {
+	lower := strings.ToLower(target)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+func mirrorTarget(target string) (mirrorSink, error) {
+	if isMirrorUrl(target) {
+		client, err := newSharedClient(strings.TrimRight(target, "/"))
+		if err != nil {
+			return nil, err
+		}
+		return &serverMirror{client}, nil
+	}
+	if strings.Contains(target, "://") {
+		return nil, fmt.Errorf("Mirror target %q is not supported, use directory or shared holotree server URL.", target)
+	}
+	location, err := pathlib.Abs(common.ExpandPath(target))
+	if err != nil {
+		return nil, err
+	}
+	if location == common.HololibLocation() {
+		return nil, fmt.Errorf("Mirror target %q is own hololib.", target)
+	}
+	return &directoryMirror{location}, nil
+}
+
+// Mirror copies given catalogs (names in own hololib) and those of their
+// blobs, which target does not have yet, into other hololib. Target is
+// directory (local disk, or network share), or shared holotree server URL.
+// Blobs go before catalogs, so target never has catalogs with missing blobs.
+// Signatures go along with catalogs, and when trusted keys are configured,
+// catalogs without valid signature are not mirrored at all.
+func Mirror(library MutableLibrary, catalogs []string, target string) (report *MirrorReport, err error) {
+	defer fail.Around(&err)
+
+	sink, err := mirrorTarget(target)
+	fail.On(err != nil, "%v", err)
+	report = &MirrorReport{
+		Target:   target,
+		Catalogs: []string{},
+	}
+	seen := make(map[string]bool)
+	for _, name := range catalogs {
+		catalog := filepath.Join(common.HololibCatalogLocation(), name)
+		err = signedCatalog(catalog)
+		fail.On(err != nil, "%w", err)
+		root, err := NewRoot(".")
+		fail.On(err != nil, "%v", err)
+		err = root.LoadFrom(catalog)
+		fail.On(err != nil, "Could not load catalog %q -> %v", name, err)
+		wanted := make(map[string]string)
+		err = root.Treetop(DigestMapper(wanted))
+		fail.On(err != nil, "%v", err)
+		for digest, _ := range wanted {
+			if seen[digest] {
+				continue
+			}
+			seen[digest] = true
+			if sink.hasBlob(digest) {
+				report.Skipped += 1
+				continue
+			}
+			source, err := openStoredBlob(library, digest)
+			fail.On(err != nil, "Could not open blob %q -> %v", digest, err)
+			err = sink.putBlob(digest, source)
+			source.Close()
+			fail.On(err != nil, "Could not mirror blob %q -> %v", digest, err)
+			report.Blobs += 1
+		}
+		err = sink.putCatalog(name, catalog)
+		fail.On(err != nil, "Could not mirror catalog %q -> %v", name, err)
+		if pathlib.IsFile(SignatureFile(catalog)) {
+			err = sink.putSignature(name, SignatureFile(catalog))
+			fail.On(err != nil, "Could not mirror signature of %q -> %v", name, err)
+		}
+		report.Catalogs = append(report.Catalogs, name)
+	}
+	return report, nil
+}
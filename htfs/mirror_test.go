@@ -0,0 +1,49 @@
+package htfs_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestCanMirrorCatalogsIntoOtherHololib(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	folder := t.TempDir()
+	library := testLibrary(t, nil)
+	for _, name := range []string{"first", "second"} {
+		testStage(t, library, map[string]string{
+			"shared.txt": "shared content",
+			"own.txt":    name,
+		})
+		must.Nil(library.Record([]byte("mirror: " + name)))
+	}
+	catalogs := htfs.Catalogs()
+	must.Equal(2, len(catalogs))
+
+	target := filepath.Join(folder, "office", "hololib")
+	_, err := htfs.Mirror(library, catalogs, "s3://bucket/hololib")
+	wont.Nil(err)
+
+	report, err := htfs.Mirror(library, catalogs[:1], target)
+	must.Nil(err)
+	must.Equal(catalogs[:1], report.Catalogs)
+	must.Equal(2, report.Blobs)
+	must.Equal(0, report.Skipped)
+
+	report, err = htfs.Mirror(library, catalogs, target)
+	must.Nil(err)
+	must.Equal(catalogs, report.Catalogs)
+	must.Equal(1, report.Blobs)
+	must.Equal(2, report.Skipped)
+
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, filepath.Join(folder, "office"))
+	must.Equal(catalogs, htfs.Catalogs())
+	mirror, err := htfs.New()
+	must.Nil(err)
+	must.True(mirror.HasBlueprint([]byte("mirror: first")))
+	must.True(mirror.HasBlueprint([]byte("mirror: second")))
+}
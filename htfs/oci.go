@@ -0,0 +1,450 @@
+package htfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/settings"
+)
+
+const (
+	ociManifestType = `application/vnd.oci.image.manifest.v1+json`
+	ociIndexType    = `application/vnd.oci.image.index.v1+json`
+	ociArtifactType = `application/vnd.robocorp.holotree.v1`
+	ociConfigType   = `application/vnd.robocorp.holotree.config.v1+json`
+	ociCatalogType  = `application/vnd.robocorp.holotree.catalog.v1+gzip`
+	ociBlobType     = `application/vnd.robocorp.hololib.blob.v1`
+	ociTitle        = `org.opencontainers.image.title`
+
+	RCC_REGISTRY_USERNAME = `RCC_REGISTRY_USERNAME`
+	RCC_REGISTRY_PASSWORD = `RCC_REGISTRY_PASSWORD`
+)
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ociManifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	ArtifactType  string            `json:"artifactType"`
+	Config        *ociDescriptor    `json:"config"`
+	Layers        []*ociDescriptor  `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+type ociIndex struct {
+	SchemaVersion int              `json:"schemaVersion"`
+	MediaType     string           `json:"mediaType"`
+	Manifests     []*ociDescriptor `json:"manifests"`
+}
+
+type ociConfig struct {
+	Catalogs []string `json:"catalogs"`
+	Version  string   `json:"rcc"`
+}
+
+// OciExport is Zipper, which collects catalogs and blobs as layers of OCI
+// artifact, each layer titled with its location inside hololib (so that
+// artifact can be turned back into hololib).
+type OciExport struct {
+	Layers  []*ociDescriptor
+	sources map[string]string
+	seen    map[string]bool
+	config  []byte
+}
+
+func fileDescriptor(fullpath, mediatype string) (*ociDescriptor, error) {
+	source, err := os.Open(fullpath)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+	digest := sha256.New()
+	size, err := io.Copy(digest, source)
+	if err != nil {
+		return nil, err
+	}
+	return &ociDescriptor{
+		MediaType: mediatype,
+		Digest:    fmt.Sprintf("sha256:%02x", digest.Sum(nil)),
+		Size:      size,
+	}, nil
+}
+
+func bytesDescriptor(content []byte, mediatype string) *ociDescriptor {
+	return &ociDescriptor{
+		MediaType: mediatype,
+		Digest:    fmt.Sprintf("sha256:%02x", sha256.Sum256(content)),
+		Size:      int64(len(content)),
+	}
+}
+
+func (it *OciExport) Add(fullpath, relativepath string) error {
+	relativepath = zipName(relativepath)
+	if it.seen[relativepath] {
+		return nil
+	}
+	it.seen[relativepath] = true
+	mediatype := ociBlobType
+	if strings.HasPrefix(relativepath, catalogFolder+"/") {
+		mediatype = ociCatalogType
+	}
+	descriptor, err := fileDescriptor(fullpath, mediatype)
+	if err != nil {
+		return err
+	}
+	descriptor.Annotations = map[string]string{ociTitle: relativepath}
+	it.sources[descriptor.Digest] = fullpath
+	it.Layers = append(it.Layers, descriptor)
+	return nil
+}
+
+func (it *OciExport) manifest() ([]byte, error) {
+	manifest := &ociManifest{
+		SchemaVersion: 2,
+		MediaType:     ociManifestType,
+		ArtifactType:  ociArtifactType,
+		Config:        bytesDescriptor(it.config, ociConfigType),
+		Layers:        it.Layers,
+	}
+	return json.MarshalIndent(manifest, "", "  ")
+}
+
+// ExportOci collects given catalogs and their blobs into OCI artifact. When
+// trusted keys are configured, only catalogs signed by them are exported.
+func ExportOci(library MutableLibrary, catalogs []string) (export *OciExport, err error) {
+	defer fail.Around(&err)
+
+	export = &OciExport{
+		Layers:  []*ociDescriptor{},
+		sources: make(map[string]string),
+		seen:    make(map[string]bool),
+	}
+	for _, name := range catalogs {
+		catalog := filepath.Join(common.HololibCatalogLocation(), name)
+		err = signedCatalog(catalog)
+		fail.On(err != nil, "%w", err)
+		err = export.Add(catalog, catalogName(name))
+		fail.On(err != nil, "Could not add catalog to artifact -> %v.", err)
+		if pathlib.IsFile(SignatureFile(catalog)) {
+			err = export.Add(SignatureFile(catalog), SignatureFile(catalogName(name)))
+			fail.On(err != nil, "Could not add catalog signature to artifact -> %v.", err)
+		}
+
+		fs, err := NewRoot(".")
+		fail.On(err != nil, "Could not create root location -> %v.", err)
+		err = fs.LoadFrom(catalog)
+		fail.On(err != nil, "Could not load catalog from %s -> %v.", catalog, err)
+		err = fs.Treetop(ZipRoot(library, fs, export))
+		fail.On(err != nil, "Could not add catalog %s -> %v.", catalog, err)
+	}
+	export.config, err = json.Marshal(&ociConfig{Catalogs: catalogs, Version: common.Version})
+	fail.On(err != nil, "%v", err)
+	return export, nil
+}
+
+func tarBytes(writer *tar.Writer, name string, content []byte) error {
+	err := writer.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))})
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(content)
+	return err
+}
+
+func tarFile(writer *tar.Writer, name, fullpath string, size int64) error {
+	source, err := os.Open(fullpath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	err = writer.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: size})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, source)
+	return err
+}
+
+func ociBlobPath(digest string) string {
+	return path.Join("blobs", strings.Replace(digest, ":", "/", 1))
+}
+
+// WriteArchive writes artifact as OCI image layout in tar archive (usable
+// with tools like skopeo and oras as "oci-archive").
+func (it *OciExport) WriteArchive(filename string) (err error) {
+	defer fail.Around(&err)
+
+	manifest, err := it.manifest()
+	fail.On(err != nil, "%v", err)
+	descriptor := bytesDescriptor(manifest, ociManifestType)
+	index, err := json.MarshalIndent(&ociIndex{
+		SchemaVersion: 2,
+		MediaType:     ociIndexType,
+		Manifests:     []*ociDescriptor{descriptor},
+	}, "", "  ")
+	fail.On(err != nil, "%v", err)
+
+	sink, err := os.Create(filename)
+	fail.On(err != nil, "Could not create archive %q -> %v", filename, err)
+	defer sink.Close()
+	writer := tar.NewWriter(sink)
+	fail.On(tarBytes(writer, "oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`)) != nil, "Could not write %q.", filename)
+	fail.On(tarBytes(writer, "index.json", index) != nil, "Could not write %q.", filename)
+	fail.On(tarBytes(writer, ociBlobPath(descriptor.Digest), manifest) != nil, "Could not write %q.", filename)
+	fail.On(tarBytes(writer, ociBlobPath(bytesDescriptor(it.config, ociConfigType).Digest), it.config) != nil, "Could not write %q.", filename)
+	for _, layer := range it.Layers {
+		err = tarFile(writer, ociBlobPath(layer.Digest), it.sources[layer.Digest], layer.Size)
+		fail.On(err != nil, "Could not add layer %s -> %v", layer.Digest, err)
+	}
+	fail.On(writer.Close() != nil, "Could not finish archive %q.", filename)
+	return sink.Close()
+}
+
+type ociReference struct {
+	Scheme     string
+	Host       string
+	Repository string
+	Tag        string
+}
+
+// parseReference accepts "host[:port]/repository[:tag]", where host is
+// required, and tag defaults to "latest".
+func parseReference(reference string) (*ociReference, error) {
+	parts := strings.SplitN(reference, "/", 2)
+	if len(parts) != 2 || !strings.ContainsAny(parts[0], ".:") && parts[0] != "localhost" {
+		return nil, fmt.Errorf("Reference %q should be like registry.example.com/repository:tag.", reference)
+	}
+	result := &ociReference{
+		Scheme:     "https",
+		Host:       parts[0],
+		Repository: parts[1],
+		Tag:        "latest",
+	}
+	at := strings.LastIndex(result.Repository, ":")
+	if at > 0 {
+		result.Repository, result.Tag = result.Repository[:at], result.Repository[at+1:]
+	}
+	if strings.HasPrefix(result.Host, "localhost") || strings.HasPrefix(result.Host, "127.0.0.1") {
+		result.Scheme = "http"
+	}
+	return result, nil
+}
+
+func (it *ociReference) url(format string, rest ...interface{}) string {
+	return fmt.Sprintf("%s://%s/v2/%s/%s", it.Scheme, it.Host, it.Repository, fmt.Sprintf(format, rest...))
+}
+
+type registryClient struct {
+	client        *http.Client
+	authorization string
+}
+
+// challengeParts splits challenge on commas, which are not inside quotes
+// (like in scope="repository:team/env:pull,push").
+func challengeParts(challenge string) []string {
+	parts := []string{}
+	quoted, start := false, 0
+	for at, letter := range challenge {
+		switch {
+		case letter == '"':
+			quoted = !quoted
+		case letter == ',' && !quoted:
+			parts = append(parts, challenge[start:at])
+			start = at + 1
+		}
+	}
+	return append(parts, challenge[start:])
+}
+
+func challengeValues(challenge string) map[string]string {
+	result := make(map[string]string)
+	for _, part := range challengeParts(challenge) {
+		pair := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(pair) == 2 {
+			result[pair[0]] = strings.Trim(pair[1], `"`)
+		}
+	}
+	return result
+}
+
+// authorize answers to registry authentication challenge, with credentials
+// from environment (if any), either as basic auth or by getting bearer token.
+func (it *registryClient) authorize(challenge string) (err error) {
+	defer fail.Around(&err)
+
+	username := os.Getenv(RCC_REGISTRY_USERNAME)
+	password := os.Getenv(RCC_REGISTRY_PASSWORD)
+	if strings.HasPrefix(challenge, "Basic") {
+		fail.On(len(username) == 0, "Registry requires credentials (%s and %s).", RCC_REGISTRY_USERNAME, RCC_REGISTRY_PASSWORD)
+		request, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+		request.SetBasicAuth(username, password)
+		it.authorization = request.Header.Get("Authorization")
+		return nil
+	}
+	values := challengeValues(strings.TrimPrefix(challenge, "Bearer "))
+	realm, err := url.Parse(values["realm"])
+	if err != nil || len(values["realm"]) == 0 {
+		return fmt.Errorf("Unsupported registry challenge %q.", challenge)
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if len(values[key]) > 0 {
+			query.Set(key, values[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+	request, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if len(username) > 0 {
+		request.SetBasicAuth(username, password)
+	}
+	response, err := it.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("Registry token request failed with status %d.", response.StatusCode)
+	}
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	err = json.NewDecoder(response.Body).Decode(&token)
+	if err != nil {
+		return err
+	}
+	if len(token.Token) == 0 {
+		token.Token = token.AccessToken
+	}
+	it.authorization = fmt.Sprintf("Bearer %s", token.Token)
+	return nil
+}
+
+// do sends request, and when registry challenges it, authorizes and retries
+// once. Body is given as function, so that it can be opened again for retry.
+func (it *registryClient) do(method, location, mediatype string, size int64, body func() (io.ReadCloser, error)) (*http.Response, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		var reader io.ReadCloser
+		if body != nil {
+			opened, err := body()
+			if err != nil {
+				return nil, err
+			}
+			reader = opened
+		}
+		request, err := http.NewRequest(method, location, reader)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			request.ContentLength = size
+			request.Header.Set("Content-Type", mediatype)
+		}
+		if method == http.MethodHead || method == http.MethodGet {
+			request.Header.Set("Accept", mediatype)
+		}
+		if len(it.authorization) > 0 {
+			request.Header.Set("Authorization", it.authorization)
+		}
+		response, err := it.client.Do(request)
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return response, nil
+		}
+		response.Body.Close()
+		err = it.authorize(response.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("Registry refused authorization.")
+}
+
+func (it *registryClient) pushBlob(reference *ociReference, digest, mediatype string, size int64, body func() (io.ReadCloser, error)) (err error) {
+	defer fail.Around(&err)
+
+	response, err := it.do(http.MethodHead, reference.url("blobs/%s", digest), mediatype, 0, nil)
+	fail.On(err != nil, "%v", err)
+	response.Body.Close()
+	if response.StatusCode == http.StatusOK {
+		common.Trace("Blob %s already in registry.", digest)
+		return nil
+	}
+	response, err = it.do(http.MethodPost, reference.url("blobs/uploads/"), mediatype, 0, nil)
+	fail.On(err != nil, "%v", err)
+	response.Body.Close()
+	fail.On(response.StatusCode != http.StatusAccepted, "Starting upload of %s failed with status %d.", digest, response.StatusCode)
+	location, err := response.Request.URL.Parse(response.Header.Get("Location"))
+	fail.On(err != nil, "Bad upload location -> %v", err)
+	query := location.Query()
+	query.Set("digest", digest)
+	location.RawQuery = query.Encode()
+	response, err = it.do(http.MethodPut, location.String(), "application/octet-stream", size, body)
+	fail.On(err != nil, "%v", err)
+	response.Body.Close()
+	fail.On(response.StatusCode != http.StatusCreated, "Upload of %s failed with status %d.", digest, response.StatusCode)
+	return nil
+}
+
+func bytesBody(content []byte) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(content)), nil
+	}
+}
+
+func fileBody(fullpath string) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return os.Open(fullpath)
+	}
+}
+
+// Push uploads artifact into container registry, using OCI distribution
+// API. Blobs already in registry are not uploaded again.
+func (it *OciExport) Push(target string) (err error) {
+	defer fail.Around(&err)
+
+	reference, err := parseReference(target)
+	fail.On(err != nil, "%v", err)
+	client := &registryClient{
+		client: &http.Client{Transport: settings.Global.ConfiguredHttpTransport()},
+	}
+	for at, layer := range it.Layers {
+		common.Debug("Pushing layer %d/%d: %s", at+1, len(it.Layers), layer.Annotations[ociTitle])
+		err = client.pushBlob(reference, layer.Digest, layer.MediaType, layer.Size, fileBody(it.sources[layer.Digest]))
+		fail.On(err != nil, "%v", err)
+	}
+	config := bytesDescriptor(it.config, ociConfigType)
+	err = client.pushBlob(reference, config.Digest, config.MediaType, config.Size, bytesBody(it.config))
+	fail.On(err != nil, "%v", err)
+	manifest, err := it.manifest()
+	fail.On(err != nil, "%v", err)
+	response, err := client.do(http.MethodPut, reference.url("manifests/%s", reference.Tag), ociManifestType, int64(len(manifest)), bytesBody(manifest))
+	fail.On(err != nil, "%v", err)
+	response.Body.Close()
+	fail.On(response.StatusCode != http.StatusCreated, "Pushing manifest failed with status %d.", response.StatusCode)
+	return nil
+}
@@ -0,0 +1,121 @@
+package htfs_test
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+type fakeRegistry struct {
+	sync.Mutex
+	blobs     map[string]int
+	manifests map[string][]byte
+	scopes    []string
+}
+
+func (it *fakeRegistry) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	it.Lock()
+	defer it.Unlock()
+	if request.URL.Path == "/token" {
+		it.scopes = append(it.scopes, request.URL.Query().Get("scope"))
+		response.Write([]byte(`{"token": "secret"}`))
+		return
+	}
+	if request.Header.Get("Authorization") != "Bearer secret" {
+		response.Header().Set("WWW-Authenticate", `Bearer realm="http://`+request.Host+`/token",service="fake",scope="repository:team/env:pull,push"`)
+		response.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case request.Method == http.MethodHead:
+		digest := request.URL.Path[strings.LastIndex(request.URL.Path, "/")+1:]
+		if _, ok := it.blobs[digest]; ok {
+			response.WriteHeader(http.StatusOK)
+		} else {
+			response.WriteHeader(http.StatusNotFound)
+		}
+	case request.Method == http.MethodPost:
+		response.Header().Set("Location", "/v2/team/env/blobs/uploads/1234")
+		response.WriteHeader(http.StatusAccepted)
+	case request.Method == http.MethodPut && strings.Contains(request.URL.Path, "/manifests/"):
+		body, _ := ioutil.ReadAll(request.Body)
+		it.manifests[request.URL.Path] = body
+		response.WriteHeader(http.StatusCreated)
+	case request.Method == http.MethodPut:
+		body, _ := ioutil.ReadAll(request.Body)
+		it.blobs[request.URL.Query().Get("digest")] = len(body)
+		response.WriteHeader(http.StatusCreated)
+	default:
+		response.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestCanExportCatalogsAsOciArtifact(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	library := testLibrary(t, map[string]string{
+		"first.txt":  "first",
+		"second.txt": "second",
+	})
+	must.Nil(library.Record([]byte("oci: unittest")))
+
+	export, err := htfs.ExportOci(library, htfs.Catalogs())
+	must.Nil(err)
+	must.Equal(3, len(export.Layers))
+
+	archive := filepath.Join(t.TempDir(), "hololib.oci.tar")
+	must.Nil(export.WriteArchive(archive))
+	source, err := os.Open(archive)
+	must.Nil(err)
+	defer source.Close()
+	entries := make(map[string][]byte)
+	reader := tar.NewReader(source)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		must.Nil(err)
+		body, err := ioutil.ReadAll(reader)
+		must.Nil(err)
+		entries[header.Name] = body
+	}
+	wont.Nil(entries["oci-layout"])
+	index := struct {
+		Manifests []struct {
+			Digest string `json:"digest"`
+		} `json:"manifests"`
+	}{}
+	must.Nil(json.Unmarshal(entries["index.json"], &index))
+	must.Equal(1, len(index.Manifests))
+	manifest := entries["blobs/"+strings.Replace(index.Manifests[0].Digest, ":", "/", 1)]
+	wont.Nil(manifest)
+	must.True(strings.Contains(string(manifest), "catalog/"))
+	must.Equal(7, len(entries))
+
+	registry := &fakeRegistry{blobs: make(map[string]int), manifests: make(map[string][]byte)}
+	server := httptest.NewServer(registry)
+	defer server.Close()
+	host := strings.Replace(strings.TrimPrefix(server.URL, "http://"), "127.0.0.1", "localhost", 1)
+
+	wont.Nil(export.Push("noregistry"))
+	must.Nil(export.Push(host + "/team/env:v1"))
+	wont.Equal(0, len(registry.scopes))
+	must.Equal("repository:team/env:pull,push", registry.scopes[0])
+	must.Equal(4, len(registry.blobs))
+	must.Equal(string(manifest), string(registry.manifests["/v2/team/env/manifests/v1"]))
+	must.Nil(export.Push(host + "/team/env"))
+	must.Equal(4, len(registry.blobs))
+	wont.Nil(registry.manifests["/v2/team/env/manifests/latest"])
+}
@@ -0,0 +1,371 @@
+package htfs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robocorp/rcc/anywork"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+const (
+	packStore     = `pack`
+	packIndexName = `index`
+	packLockName  = `packs.lck`
+	packLimit     = 512 * 1024 * 1024
+)
+
+// packEntry tells where stored blob is: pack number, offset, and size.
+type packEntry struct {
+	pack   int
+	offset int64
+	size   int64
+}
+
+// packBlobStore keeps blobs appended into few large pack files, and their
+// locations in append-only index (one "digest pack offset size" line per
+// blob, and "digest -" line for removed blob), like git packs. This avoids
+// having one file (and inode) per blob, which is slow on network
+// filesystems. Blobs of system hololib are still read from its directory
+// tree.
+type packBlobStore struct {
+	sync.Mutex
+	directory string
+	system    string
+	index     map[string]*packEntry
+	current   int
+	stamp     os.FileInfo
+	seen      int64
+}
+
+func newPackBlobStore(system string) (BlobStore, error) {
+	store := &packBlobStore{
+		directory: common.HololibPackLocation(),
+		system:    system,
+	}
+	err := os.MkdirAll(store.directory, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return store, store.reload()
+}
+
+func (it *packBlobStore) Name() string {
+	return packStore
+}
+
+func (it *packBlobStore) packname(pack int) string {
+	return filepath.Join(it.directory, fmt.Sprintf("%06d.pack", pack))
+}
+
+func (it *packBlobStore) indexname() string {
+	return filepath.Join(it.directory, packIndexName)
+}
+
+// parsePackIndex applies index lines into index, and returns highest pack
+// number seen.
+func parsePackIndex(reader io.Reader, index map[string]*packEntry) int {
+	current := 0
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == "-" {
+			delete(index, fields[0])
+			continue
+		}
+		if len(fields) != 4 {
+			continue
+		}
+		pack, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		offset, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			continue
+		}
+		index[fields[0]] = &packEntry{pack, offset, size}
+		if pack > current {
+			current = pack
+		}
+	}
+	return current
+}
+
+// reload reads index lines, which other processes have appended since last
+// reload. Unchanged index (same size and modification time) is not read at
+// all, and whole index is read again only when repack has replaced it.
+func (it *packBlobStore) reload() error {
+	handle, err := os.Open(it.indexname())
+	if os.IsNotExist(err) {
+		it.Lock()
+		it.index, it.current, it.stamp, it.seen = make(map[string]*packEntry), 0, nil, 0
+		it.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer handle.Close()
+	info, err := handle.Stat()
+	if err != nil {
+		return err
+	}
+	it.Lock()
+	defer it.Unlock()
+	appended := it.stamp != nil && os.SameFile(it.stamp, info) && info.Size() >= it.seen
+	if appended && info.Size() == it.seen && info.ModTime().Equal(it.stamp.ModTime()) {
+		return nil
+	}
+	if !appended {
+		it.index, it.current, it.seen = make(map[string]*packEntry), 0, 0
+	}
+	_, err = handle.Seek(it.seen, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	tail, err := ioutil.ReadAll(handle)
+	if err != nil {
+		return err
+	}
+	// line still being appended by other process is left for next reload
+	complete := bytes.LastIndexByte(tail, '\n') + 1
+	if current := parsePackIndex(bytes.NewReader(tail[:complete]), it.index); current > it.current {
+		it.current = current
+	}
+	it.seen += int64(complete)
+	it.stamp = info
+	return nil
+}
+
+func (it *packBlobStore) lookup(digest string) (*packEntry, bool) {
+	it.Lock()
+	entry, ok := it.index[digest]
+	it.Unlock()
+	if ok {
+		return entry, true
+	}
+	if it.reload() != nil {
+		return nil, false
+	}
+	it.Lock()
+	defer it.Unlock()
+	entry, ok = it.index[digest]
+	return entry, ok
+}
+
+func (it *packBlobStore) systemBlob(digest string) (string, bool) {
+	if len(it.system) == 0 {
+		return "", false
+	}
+	filename := filepath.Join(it.system, "library", digest[:2], digest[2:4], digest[4:6], digest)
+	return filename, pathlib.IsFile(filename)
+}
+
+func (it *packBlobStore) Has(digest string) bool {
+	_, ok := it.lookup(digest)
+	if ok {
+		return true
+	}
+	_, ok = it.systemBlob(digest)
+	return ok
+}
+
+type packReader struct {
+	io.Reader
+	io.Closer
+}
+
+func (it *packBlobStore) Open(digest string) (io.ReadCloser, error) {
+	entry, ok := it.lookup(digest)
+	if !ok {
+		filename, ok := it.systemBlob(digest)
+		if !ok {
+			return nil, fmt.Errorf("Blob %q is not in packs.", digest)
+		}
+		return os.Open(filename)
+	}
+	handle, err := os.Open(it.packname(entry.pack))
+	if os.IsNotExist(err) {
+		// pack was repacked away after lookup, so try once more
+		err = it.reload()
+		if err != nil {
+			return nil, err
+		}
+		entry, ok = it.lookup(digest)
+		if !ok {
+			return nil, fmt.Errorf("Blob %q is not in packs.", digest)
+		}
+		handle, err = os.Open(it.packname(entry.pack))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &packReader{io.NewSectionReader(handle, entry.offset, entry.size), handle}, nil
+}
+
+func (it *packBlobStore) locked() (pathlib.Releaser, error) {
+	return pathlib.Locker(filepath.Join(it.directory, packLockName), 30000)
+}
+
+func (it *packBlobStore) appendIndex(line string) error {
+	handle, err := os.OpenFile(it.indexname(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = handle.WriteString(line)
+	if err != nil {
+		handle.Close()
+		return err
+	}
+	return handle.Close()
+}
+
+// Put appends blob into current pack, and only after that into index, so
+// that interrupted write leaves only unreferenced bytes at end of pack
+// (which repack reclaims).
+func (it *packBlobStore) Put(digest string, stored io.Reader) (err error) {
+	defer fail.Around(&err)
+
+	locker, err := it.locked()
+	fail.On(err != nil, "Could not lock packs -> %v", err)
+	defer locker.Release()
+	err = it.reload()
+	fail.On(err != nil, "Could not read pack index -> %v", err)
+	it.Lock()
+	_, ok := it.index[digest]
+	it.Unlock()
+	if ok {
+		return nil
+	}
+	pack := it.current
+	if pack == 0 {
+		pack = 1
+	}
+	if info, err := os.Stat(it.packname(pack)); err == nil && info.Size() >= packLimit {
+		pack += 1
+	}
+	handle, err := os.OpenFile(it.packname(pack), os.O_RDWR|os.O_CREATE, 0o644)
+	fail.On(err != nil, "Could not open pack -> %v", err)
+	defer handle.Close()
+	offset, err := handle.Seek(0, io.SeekEnd)
+	fail.On(err != nil, "Could not seek pack -> %v", err)
+	size, err := io.Copy(handle, stored)
+	if err != nil {
+		handle.Truncate(offset)
+	}
+	fail.On(err != nil, "Could not write pack -> %v", err)
+	err = handle.Sync()
+	fail.On(err != nil, "Could not sync pack -> %v", err)
+	err = it.appendIndex(fmt.Sprintf("%s %d %d %d\n", digest, pack, offset, size))
+	fail.On(err != nil, "Could not update pack index -> %v", err)
+	it.Lock()
+	it.index[digest] = &packEntry{pack, offset, size}
+	it.current = pack
+	it.Unlock()
+	return nil
+}
+
+// Remove only forgets blob from index, and repack reclaims its space.
+func (it *packBlobStore) Remove(digest string) (err error) {
+	defer fail.Around(&err)
+
+	locker, err := it.locked()
+	fail.On(err != nil, "Could not lock packs -> %v", err)
+	defer locker.Release()
+	if _, ok := it.lookup(digest); !ok {
+		return nil
+	}
+	err = it.appendIndex(fmt.Sprintf("%s -\n", digest))
+	fail.On(err != nil, "Could not update pack index -> %v", err)
+	it.Lock()
+	delete(it.index, digest)
+	it.Unlock()
+	return nil
+}
+
+// damagedBlobs verifies packed blobs which catalogs refer to, and adds ones
+// whose content does not match their digest into damaged (as location in
+// packs, so that base name is still the digest).
+func (it *packBlobStore) damagedBlobs(known map[string]map[string]bool, damaged map[string]string) error {
+	err := it.reload()
+	if err != nil {
+		return err
+	}
+	var guard sync.Mutex
+	for digest, _ := range known {
+		if _, ok := it.lookup(digest); !ok {
+			continue
+		}
+		digest := digest
+		anywork.Backlog(func() {
+			actual, err := storedMatchingDigest(it, digest)
+			if err != nil {
+				common.Debug("Reading packed blob %q failed, reason: %v", digest, err)
+				actual = "N/A"
+			}
+			if actual == digest {
+				return
+			}
+			guard.Lock()
+			damaged[filepath.Join(it.directory, digest)] = actual
+			guard.Unlock()
+		})
+	}
+	return anywork.Sync()
+}
+
+// collect forgets blobs which no catalog refers to from index, and repack
+// then reclaims their space. Packed blobs have no age of their own, so blobs
+// in packs written within grace period are kept.
+func (it *packBlobStore) collect(referenced map[string]string, deadline time.Time, dryrun bool, report *GarbageReport) error {
+	err := it.reload()
+	if err != nil {
+		return err
+	}
+	it.Lock()
+	entries := make(map[string]*packEntry, len(it.index))
+	for digest, entry := range it.index {
+		entries[digest] = entry
+	}
+	it.Unlock()
+	report.Repack = true
+	young := make(map[int]bool)
+	for digest, entry := range entries {
+		recent, ok := young[entry.pack]
+		if !ok {
+			info, err := os.Stat(it.packname(entry.pack))
+			recent = err != nil || info.ModTime().After(deadline)
+			young[entry.pack] = recent
+		}
+		if _, ok := referenced[digest]; ok || recent {
+			report.Kept += 1
+			report.Remaining += entry.size
+			continue
+		}
+		if !dryrun {
+			err = it.Remove(digest)
+			if err != nil {
+				return err
+			}
+		}
+		report.Removed = append(report.Removed, digest)
+		report.Freed += entry.size
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package htfs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/settings"
+)
+
+func TestCanMigrateBlobsIntoPacks(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	config := settings.Global.Holotree()
+	previous := config.BlobStore
+	defer func() {
+		config.BlobStore = previous
+	}()
+
+	common.ControllerType = "unittest"
+	first := []byte("packs: first")
+	second := []byte("packs: second")
+	library := testLibrary(t, map[string]string{"loose.txt": "loose file"})
+	must.Nil(library.Record(first))
+
+	_, err := htfs.Repack()
+	wont.Nil(err)
+
+	config.BlobStore = "pack"
+	report, err := htfs.Repack()
+	must.Nil(err)
+	must.Equal(1, report.Migrated)
+	must.Equal(1, report.Blobs)
+	must.Equal(1, report.Packs)
+	hashes := htfs.LoadHololibHashes()
+	for digest, _ := range hashes {
+		wont.True(pathlib.IsFile(library.ExactLocation(digest)))
+	}
+
+	library, err = htfs.New()
+	must.Nil(err)
+	must.True(library.HasBlueprint(first))
+	testStage(t, library, map[string]string{
+		"loose.txt":  "loose file",
+		"packed.txt": "packed file",
+	})
+	must.Nil(library.Record(second))
+	must.True(library.HasBlueprint(second))
+
+	path, err := library.Restore(first, []byte(common.ControllerIdentity()), []byte("packs"))
+	must.Nil(err)
+	content, err := ioutil.ReadFile(filepath.Join(path, "loose.txt"))
+	must.Nil(err)
+	must.Equal("loose file", string(content))
+	path, err = library.Restore(second, []byte(common.ControllerIdentity()), []byte("packs"))
+	must.Nil(err)
+	content, err = ioutil.ReadFile(filepath.Join(path, "packed.txt"))
+	must.Nil(err)
+	must.Equal("packed file", string(content))
+
+	report, err = htfs.Repack()
+	must.Nil(err)
+	must.Equal(0, report.Migrated)
+	must.Equal(2, report.Blobs)
+	must.Equal(1, report.Packs)
+	must.Equal(int64(0), report.Reclaimed)
+	packs := pathlib.Glob(common.HololibPackLocation(), "*.pack")
+	must.Equal(1, len(packs))
+
+	library, err = htfs.New()
+	must.Nil(err)
+	path, err = library.Restore(second, []byte(common.ControllerIdentity()), []byte("after"))
+	must.Nil(err)
+	content, err = ioutil.ReadFile(filepath.Join(path, "loose.txt"))
+	must.Nil(err)
+	must.Equal("loose file", string(content))
+}
+
+func TestRepairAndGarbageCollectionWorkWithPacks(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	folder := t.TempDir()
+	config := settings.Global.Holotree()
+	previous := config.BlobStore
+	defer func() {
+		config.BlobStore = previous
+	}()
+	config.BlobStore = "pack"
+
+	common.ControllerType = "unittest"
+	first := []byte("packs: kept")
+	second := []byte("packs: collected")
+	library := testLibrary(t, map[string]string{"kept.txt": "kept file"})
+	must.Nil(library.Record(first))
+	testStage(t, library, map[string]string{"collected.txt": "collected file"})
+	must.Nil(library.Record(second))
+	must.Equal(2, len(htfs.Catalogs()))
+
+	integrity, err := htfs.RepairIntegrity("")
+	must.Nil(err)
+	must.Equal(0, len(integrity.Missing))
+	must.Equal(0, len(integrity.Damaged))
+	must.Equal(0, len(integrity.Purged))
+	must.Equal(2, len(htfs.Catalogs()))
+
+	must.Nil(os.Remove(filepath.Join(common.HololibCatalogLocation(), htfs.CatalogName(htfs.BlueprintHash(second)))))
+	garbage, err := htfs.CollectGarbage(false)
+	must.Nil(err)
+	must.Equal(0, len(garbage.Removed))
+
+	old := time.Now().Add(-2 * time.Hour)
+	for _, pack := range pathlib.Glob(common.HololibPackLocation(), "*.pack") {
+		must.Nil(os.Chtimes(filepath.Join(common.HololibPackLocation(), pack), old, old))
+	}
+	garbage, err = htfs.CollectGarbage(false)
+	must.Nil(err)
+	must.True(garbage.Repack)
+	must.Equal(1, len(garbage.Removed))
+	must.Equal(1, garbage.Kept)
+
+	integrity, err = htfs.CheckIntegrity()
+	must.Nil(err)
+	must.Equal(0, len(integrity.Missing))
+	must.Equal(1, len(htfs.Catalogs()))
+
+	library, err = htfs.New()
+	must.Nil(err)
+	path, err := library.Restore(first, []byte(common.ControllerIdentity()), []byte("collected"))
+	must.Nil(err)
+	content, err := ioutil.ReadFile(filepath.Join(path, "kept.txt"))
+	must.Nil(err)
+	must.Equal("kept file", string(content))
+	wont.True(library.HasBlueprint(second))
+	wont.Nil(library.Export(htfs.Catalogs(), []string{}, filepath.Join(folder, "export.zip")))
+}
+
+func TestPackIndexFollowsOtherWritersAndRepacks(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	config := settings.Global.Holotree()
+	previous := config.BlobStore
+	defer func() {
+		config.BlobStore = previous
+	}()
+	config.BlobStore = "pack"
+
+	common.ControllerType = "unittest"
+	first := []byte("packs: reader")
+	second := []byte("packs: writer")
+	reader := testLibrary(t, map[string]string{"reader.txt": "reader file"})
+	must.Nil(reader.Record(first))
+
+	writer, err := htfs.New()
+	must.Nil(err)
+	testStage(t, writer, map[string]string{"writer.txt": "writer file"})
+	must.Nil(writer.Record(second))
+
+	path, err := reader.Restore(second, []byte(common.ControllerIdentity()), []byte("appended"))
+	must.Nil(err)
+	content, err := ioutil.ReadFile(filepath.Join(path, "writer.txt"))
+	must.Nil(err)
+	must.Equal("writer file", string(content))
+
+	report, err := htfs.Repack()
+	must.Nil(err)
+	must.Equal(2, report.Blobs)
+
+	path, err = reader.Restore(first, []byte(common.ControllerIdentity()), []byte("repacked"))
+	must.Nil(err)
+	content, err = ioutil.ReadFile(filepath.Join(path, "reader.txt"))
+	must.Nil(err)
+	must.Equal("reader file", string(content))
+}
@@ -0,0 +1,224 @@
+package htfs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+// Pins are spaces and catalogs excluded from cleanup and pruning. Pinned
+// space also keeps catalog it was restored from.
+type Pins struct {
+	Spaces   []string `json:"spaces"`
+	Catalogs []string `json:"catalogs"`
+}
+
+type SpaceCleanupReport struct {
+	Dryrun  bool     `json:"dryrun"`
+	Removed []string `json:"removed"`
+	Pinned  []string `json:"pinned"`
+	Kept    []string `json:"kept"`
+}
+
+func pinsFile() string {
+	return filepath.Join(common.HolotreeLocation(), "pins.json")
+}
+
+func LoadPins() *Pins {
+	result := &Pins{Spaces: []string{}, Catalogs: []string{}}
+	content, err := ioutil.ReadFile(pinsFile())
+	if err != nil {
+		return result
+	}
+	err = json.Unmarshal(content, result)
+	if err != nil {
+		common.Log("Ignoring broken pins file %q, reason: %v", pinsFile(), err)
+		return &Pins{Spaces: []string{}, Catalogs: []string{}}
+	}
+	return result
+}
+
+func (it *Pins) Save() (err error) {
+	defer fail.Around(&err)
+
+	sort.Strings(it.Spaces)
+	sort.Strings(it.Catalogs)
+	content, err := json.MarshalIndent(it, "", "  ")
+	fail.On(err != nil, "Could not serialize pins -> %v", err)
+	filename := pinsFile()
+	_, err = pathlib.EnsureParentDirectory(filename)
+	fail.On(err != nil, "Could not create directory for %q -> %v", filename, err)
+	err = ioutil.WriteFile(filename, content, 0o644)
+	fail.On(err != nil, "Could not save %q -> %v", filename, err)
+	return nil
+}
+
+func contains(names []string, name string) bool {
+	for _, candidate := range names {
+		if candidate == name {
+			return true
+		}
+	}
+	return false
+}
+
+func toggle(names []string, name string, add bool) []string {
+	result := make([]string, 0, len(names)+1)
+	for _, candidate := range names {
+		if candidate != name {
+			result = append(result, candidate)
+		}
+	}
+	if add {
+		result = append(result, name)
+	}
+	return result
+}
+
+func (it *Pins) SpacePinned(label string) bool {
+	return contains(it.Spaces, label)
+}
+
+// PinnedCatalogs returns names of catalogs pinned directly, or through
+// pinned spaces restored from them.
+func (it *Pins) PinnedCatalogs() map[string]bool {
+	result := make(map[string]bool)
+	for _, name := range it.Catalogs {
+		result[name] = true
+	}
+	for _, space := range Spaces() {
+		if len(space.Blueprint) > 0 && it.SpacePinned(filepath.Base(space.Path)) {
+			result[CatalogName(space.Blueprint)] = true
+		}
+	}
+	return result
+}
+
+// Pin pins (or unpins) catalogs by their exact name, and spaces by their
+// partial identity, and returns what was changed.
+func Pin(names []string, unpin bool) (changed []string, err error) {
+	defer fail.Around(&err)
+
+	locker, err := pathlib.Locker(common.HolotreeLock(), 30000)
+	fail.On(err != nil, "Could not get lock for holotree. Quiting.")
+	defer locker.Release()
+
+	pins := LoadPins()
+	catalogs := Catalogs()
+	changed = []string{}
+	for _, name := range names {
+		if contains(catalogs, name) || (unpin && contains(pins.Catalogs, name)) {
+			pins.Catalogs = toggle(pins.Catalogs, name, !unpin)
+			changed = append(changed, name)
+			continue
+		}
+		spaces := FindEnvironment(name)
+		if unpin && contains(pins.Spaces, name) {
+			spaces = append(spaces, name)
+		}
+		fail.On(len(spaces) == 0, "No space or catalog matches %q.", name)
+		for _, label := range spaces {
+			pins.Spaces = toggle(pins.Spaces, label, !unpin)
+			changed = append(changed, label)
+		}
+	}
+	err = pins.Save()
+	fail.On(err != nil, "%v", err)
+	return changed, nil
+}
+
+func lastUsed(metafile string) time.Time {
+	stat, err := os.Stat(metafile)
+	if err != nil {
+		return time.Time{}
+	}
+	return stat.ModTime()
+}
+
+// CleanupSpaces removes spaces not used for given days, and spaces whose
+// controller has not used any space for orphans days. Zero disables either
+// limit, and pinned spaces are always kept.
+func CleanupSpaces(days, orphans int, dryrun bool) (report *SpaceCleanupReport, err error) {
+	defer fail.Around(&err)
+
+	report = &SpaceCleanupReport{
+		Dryrun:  dryrun,
+		Removed: []string{},
+		Pinned:  []string{},
+		Kept:    []string{},
+	}
+	if days <= 0 && orphans <= 0 {
+		return report, nil
+	}
+	locker, err := pathlib.Locker(common.HolotreeLock(), 30000)
+	fail.On(err != nil, "Could not get lock for holotree. Quiting.")
+	defer locker.Release()
+
+	pins := LoadPins()
+	spacemap := Spacemap()
+	controllers := make(map[string]time.Time)
+	spaces := Spaces()
+	for _, space := range spaces {
+		used := lastUsed(spacemap[space.Path])
+		if used.After(controllers[space.Controller]) {
+			controllers[space.Controller] = used
+		}
+	}
+	now := time.Now()
+	stale := now.Add(time.Duration(-days) * 24 * time.Hour)
+	orphaned := now.Add(time.Duration(-orphans) * 24 * time.Hour)
+	sort.SliceStable(spaces, func(left, right int) bool {
+		return spaces[left].Path < spaces[right].Path
+	})
+	for _, space := range spaces {
+		label := filepath.Base(space.Path)
+		if pins.SpacePinned(label) {
+			report.Pinned = append(report.Pinned, label)
+			continue
+		}
+		used := lastUsed(spacemap[space.Path])
+		expired := days > 0 && used.Before(stale)
+		orphan := orphans > 0 && controllers[space.Controller].Before(orphaned)
+		if !expired && !orphan {
+			report.Kept = append(report.Kept, label)
+			continue
+		}
+		if !dryrun {
+			err = RemoveHolotreeSpace(label)
+			fail.On(err != nil, "%v", err)
+		}
+		report.Removed = append(report.Removed, label)
+	}
+	return report, nil
+}
+
+// SpaceCleaner adapts CleanupSpaces for conda.Cleanup.
+func SpaceCleaner(days, orphans int, cleanup *conda.CleanupReport) error {
+	report, err := CleanupSpaces(days, orphans, cleanup.Dryrun)
+	if err != nil {
+		return err
+	}
+	cleanup.Spaces = append(cleanup.Spaces, report.Removed...)
+	cleanup.Pinned = append(cleanup.Pinned, report.Pinned...)
+	cleanup.Kept = append(cleanup.Kept, report.Kept...)
+	verb := "Removed"
+	if report.Dryrun {
+		verb = "Would remove"
+	}
+	for _, label := range report.Removed {
+		common.Log("%s space %s.", verb, label)
+	}
+	if len(report.Pinned) > 0 {
+		common.Debug("Kept pinned spaces: %v", report.Pinned)
+	}
+	common.Debug("%s %d space(s), kept %d and %d pinned.", verb, len(report.Removed), len(report.Kept), len(report.Pinned))
+	return nil
+}
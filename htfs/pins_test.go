@@ -0,0 +1,61 @@
+package htfs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestPinnedSpacesSurviveCleanup(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	library := testLibrary(t, map[string]string{"pinned.txt": "pinned"})
+	blueprint := []byte("pins: test")
+	must.Nil(library.Record(blueprint))
+
+	spaces := make(map[string]string)
+	for _, setup := range [][]string{{"active", "fresh"}, {"active", "stale"}, {"gone", "pinned"}, {"gone", "other"}} {
+		path, err := library.Restore(blueprint, []byte(setup[0]), []byte(setup[1]))
+		must.Nil(err)
+		spaces[setup[0]+"/"+setup[1]] = filepath.Base(path)
+	}
+	old := time.Now().Add(-40 * 24 * time.Hour)
+	for _, name := range []string{"active/stale", "gone/pinned", "gone/other"} {
+		metafile := filepath.Join(common.HolotreeLocation(), spaces[name]+".meta")
+		must.Nil(os.Chtimes(metafile, old, old))
+	}
+
+	changed, err := htfs.Pin([]string{spaces["gone/pinned"]}, false)
+	must.Nil(err)
+	must.Equal([]string{spaces["gone/pinned"]}, changed)
+	_, err = htfs.Pin([]string{"nonexisting"}, false)
+	wont.Nil(err)
+	must.True(htfs.LoadPins().PinnedCatalogs()[htfs.CatalogName(htfs.BlueprintHash(blueprint))])
+
+	report, err := htfs.CleanupSpaces(0, 30, true)
+	must.Nil(err)
+	must.Equal([]string{spaces["gone/other"]}, report.Removed)
+	must.Equal([]string{spaces["gone/pinned"]}, report.Pinned)
+	must.Equal(4, len(htfs.Spaces()))
+
+	report, err = htfs.CleanupSpaces(30, 0, false)
+	must.Nil(err)
+	must.Equal(2, len(report.Removed))
+	must.Equal(2, len(htfs.Spaces()))
+
+	catalog := filepath.Join(common.HololibCatalogLocation(), htfs.CatalogName(htfs.BlueprintHash(blueprint)))
+	must.Nil(os.Chtimes(catalog, old, old))
+	pruned, err := htfs.PruneCatalogs(1, 0, true)
+	must.Nil(err)
+	must.Equal(0, len(pruned.Removed))
+	must.Equal(1, len(pruned.Kept))
+
+	_, err = htfs.Pin([]string{spaces["gone/pinned"]}, true)
+	must.Nil(err)
+	must.Equal(0, len(htfs.LoadPins().Spaces))
+}
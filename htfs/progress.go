@@ -0,0 +1,172 @@
+package htfs
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/robocorp/rcc/anywork"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pretty"
+)
+
+const (
+	progressInterval = 1 * time.Second
+)
+
+var (
+	progressLock     sync.Mutex
+	progressCallback ProgressCallback
+	progressCurrent  *progressTracker
+)
+
+// ProgressReport is snapshot of long running lift or restore operation.
+type ProgressReport struct {
+	Operation string        `json:"operation"`
+	Files     int64         `json:"files"`
+	FilesDone int64         `json:"files_done"`
+	Bytes     int64         `json:"bytes"`
+	BytesDone int64         `json:"bytes_done"`
+	Elapsed   time.Duration `json:"elapsed"`
+	ETA       time.Duration `json:"eta"`
+	Finished  bool          `json:"finished"`
+}
+
+// ProgressCallback receives periodic progress reports, and one final report
+// with Finished set, when operation is done.
+type ProgressCallback func(*ProgressReport)
+
+type progressTracker struct {
+	operation string
+	started   time.Time
+	files     int64
+	filesDone int64
+	bytes     int64
+	bytesDone int64
+}
+
+// SetProgressCallback sets callback for library users; nil removes it.
+func SetProgressCallback(callback ProgressCallback) {
+	progressLock.Lock()
+	defer progressLock.Unlock()
+	progressCallback = callback
+}
+
+func (it *ProgressReport) FilesRemaining() int64 {
+	return it.Files - it.FilesDone
+}
+
+func (it *ProgressReport) Percent() float64 {
+	if it.Bytes > 0 {
+		return 100.0 * float64(it.BytesDone) / float64(it.Bytes)
+	}
+	if it.Files > 0 {
+		return 100.0 * float64(it.FilesDone) / float64(it.Files)
+	}
+	return 100.0
+}
+
+func (it *progressTracker) report(finished bool) *ProgressReport {
+	result := &ProgressReport{
+		Operation: it.operation,
+		Files:     atomic.LoadInt64(&it.files),
+		FilesDone: atomic.LoadInt64(&it.filesDone),
+		Bytes:     atomic.LoadInt64(&it.bytes),
+		BytesDone: atomic.LoadInt64(&it.bytesDone),
+		Elapsed:   time.Since(it.started),
+		Finished:  finished,
+	}
+	if result.BytesDone > 0 && result.Bytes > result.BytesDone {
+		rate := float64(result.Elapsed) / float64(result.BytesDone)
+		result.ETA = time.Duration(rate * float64(result.Bytes-result.BytesDone))
+	}
+	return result
+}
+
+func activeProgressCallback() ProgressCallback {
+	progressLock.Lock()
+	defer progressLock.Unlock()
+	if progressCallback != nil {
+		return progressCallback
+	}
+	if common.JsonProgressFlag {
+		return jsonProgress
+	}
+	if common.ProgressFlag {
+		return prettyProgress
+	}
+	return nil
+}
+
+// progressStart begins tracking of operation, and returned function must be
+// called to end it. Without callback, tracking is a no-op.
+func progressStart(operation string) func() {
+	callback := activeProgressCallback()
+	if callback == nil {
+		return func() {}
+	}
+	tracker := &progressTracker{
+		operation: operation,
+		started:   time.Now(),
+	}
+	progressLock.Lock()
+	progressCurrent = tracker
+	progressLock.Unlock()
+	done := make(chan bool)
+	stopped := make(chan bool)
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				callback(tracker.report(false))
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		progressLock.Lock()
+		progressCurrent = nil
+		progressLock.Unlock()
+		callback(tracker.report(true))
+	}
+}
+
+func activeProgress() *progressTracker {
+	progressLock.Lock()
+	defer progressLock.Unlock()
+	return progressCurrent
+}
+
+// tracked accounts work of given size into active progress, if there is one.
+func tracked(size int64, work anywork.Work) anywork.Work {
+	tracker := activeProgress()
+	if tracker == nil {
+		return work
+	}
+	atomic.AddInt64(&tracker.files, 1)
+	atomic.AddInt64(&tracker.bytes, size)
+	return func() {
+		defer atomic.AddInt64(&tracker.filesDone, 1)
+		defer atomic.AddInt64(&tracker.bytesDone, size)
+		work()
+	}
+}
+
+func jsonProgress(report *ProgressReport) {
+	common.EmitProgress(&common.ProgressEvent{
+		Event:   common.EventProgress,
+		Phase:   report.Operation,
+		Elapsed: report.Elapsed.Seconds(),
+		Details: report,
+	})
+}
+
+func prettyProgress(report *ProgressReport) {
+	pretty.Progress(report.Operation, report.Percent(), report.FilesRemaining(), report.ETA, report.Finished)
+}
@@ -0,0 +1,43 @@
+package htfs_test
+
+import (
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestProgressIsReportedOnLiftAndRestore(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	finals := make(map[string]*htfs.ProgressReport)
+	htfs.SetProgressCallback(func(report *htfs.ProgressReport) {
+		if report.Finished {
+			finals[report.Operation] = report
+		}
+	})
+	defer htfs.SetProgressCallback(nil)
+
+	common.ControllerType = "unittest"
+	blueprint := []byte("progress: unittest")
+	library := testLibrary(t, map[string]string{
+		"first.txt":  "first",
+		"second.txt": "second one",
+	})
+	must.Nil(library.Record(blueprint))
+	_, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("progress"))
+	must.Nil(err)
+
+	for _, operation := range []string{"lift", "restore"} {
+		report, ok := finals[operation]
+		must.True(ok)
+		must.Equal(int64(2), report.Files)
+		must.Equal(int64(2), report.FilesDone)
+		must.Equal(int64(15), report.Bytes)
+		must.Equal(int64(15), report.BytesDone)
+		must.Equal(int64(0), report.FilesRemaining())
+		must.Equal(100.0, report.Percent())
+		wont.True(report.ETA > 0)
+	}
+}
@@ -0,0 +1,122 @@
+package htfs
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+var (
+	sourceLock      sync.Mutex
+	sourceDigests   = make(map[string]string)
+	lockfileDigests = make(map[string]string)
+)
+
+// Provenance tells where catalog came from: digest of source conda.yaml
+// file(s), rcc version, and platform used, and when it was created.
+type Provenance struct {
+	CondaHash    string `json:"conda-hash,omitempty"`
+	LockfileHash string `json:"lockfile-hash,omitempty"`
+	RccVersion   string `json:"rcc-version"`
+	OS           string `json:"os"`
+	Arch         string `json:"arch"`
+	Created      string `json:"created"`
+}
+
+// CatalogInfo is catalog of hololib with its provenance, when known.
+type CatalogInfo struct {
+	Name       string      `json:"name"`
+	Blueprint  string      `json:"blueprint"`
+	Platform   string      `json:"platform"`
+	Files      int         `json:"files"`
+	Bytes      int64       `json:"bytes"`
+	Signed     bool        `json:"signed"`
+	Provenance *Provenance `json:"provenance"`
+}
+
+func sourceDigest(filenames []string) (string, bool) {
+	digest := sha256.New()
+	for _, filename := range filenames {
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			common.Debug("Could not read %q for provenance -> %v", filename, err)
+			return "", false
+		}
+		digest.Write(content)
+	}
+	return fmt.Sprintf("%02x", digest.Sum(nil)), true
+}
+
+// noteDigest remembers first digest noted for blueprint, since blueprint is
+// composed first from user files, and later again from its own copy.
+func noteDigest(target map[string]string, blueprint []byte, filenames []string) {
+	digest, ok := sourceDigest(filenames)
+	if !ok {
+		return
+	}
+	sourceLock.Lock()
+	defer sourceLock.Unlock()
+	key := BlueprintHash(blueprint)
+	if _, known := target[key]; !known {
+		target[key] = digest
+	}
+}
+
+// noteSourceDigest remembers digest of source files for blueprint, so that
+// recording it can tell where it came from.
+func noteSourceDigest(blueprint []byte, filenames []string) {
+	noteDigest(sourceDigests, blueprint, filenames)
+}
+
+// noteLockfileDigest remembers digest of lockfile blueprint was made from.
+func noteLockfileDigest(blueprint []byte, lockfile string) {
+	noteDigest(lockfileDigests, blueprint, []string{lockfile})
+}
+
+func newProvenance(key string) *Provenance {
+	sourceLock.Lock()
+	defer sourceLock.Unlock()
+	return &Provenance{
+		CondaHash:    sourceDigests[key],
+		LockfileHash: lockfileDigests[key],
+		RccVersion:   common.Version,
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		Created:      time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// CatalogInfos loads all catalogs of hololib, and tells what they are.
+// Catalogs recorded before provenance was stored have nil provenance.
+func CatalogInfos() ([]*CatalogInfo, error) {
+	result := []*CatalogInfo{}
+	for _, name := range Catalogs() {
+		catalog := filepath.Join(common.HololibCatalogLocation(), name)
+		fs, err := NewRoot(".")
+		if err != nil {
+			return nil, err
+		}
+		err = fs.LoadFrom(catalog)
+		if err != nil {
+			return nil, fmt.Errorf("Could not load catalog %q -> %v", name, err)
+		}
+		size, files := treeSize(fs.Tree)
+		result = append(result, &CatalogInfo{
+			Name:       name,
+			Blueprint:  fs.Blueprint,
+			Platform:   fs.Platform,
+			Files:      files,
+			Bytes:      size,
+			Signed:     pathlib.IsFile(SignatureFile(catalog)),
+			Provenance: fs.Provenance,
+		})
+	}
+	return result, nil
+}
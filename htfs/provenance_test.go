@@ -0,0 +1,64 @@
+package htfs_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestCatalogsKnowTheirProvenance(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	library := testLibrary(t, map[string]string{"python": "python"})
+
+	source := []byte("channels:\n- conda-forge\ndependencies:\n- python=3.9.13\n")
+	condafile := filepath.Join(t.TempDir(), "conda.yaml")
+	must.Nil(ioutil.WriteFile(condafile, source, 0o644))
+	_, blueprint, err := htfs.ComposeFinalBlueprint([]string{condafile}, "")
+	must.Nil(err)
+
+	must.Nil(library.Record(blueprint))
+
+	infos, err := htfs.CatalogInfos()
+	must.Nil(err)
+	must.Equal(1, len(infos))
+	info := infos[0]
+	must.Equal(htfs.BlueprintHash(blueprint), info.Blueprint)
+	must.Equal(1, info.Files)
+	must.Equal(int64(6), info.Bytes)
+	wont.True(info.Signed)
+	wont.Nil(info.Provenance)
+	must.Equal(fmt.Sprintf("%02x", sha256.Sum256(source)), info.Provenance.CondaHash)
+	must.Equal(common.Version, info.Provenance.RccVersion)
+	must.Equal(runtime.GOOS, info.Provenance.OS)
+	must.Equal(runtime.GOARCH, info.Provenance.Arch)
+	wont.Equal("", info.Provenance.Created)
+}
+
+func TestLockfileCatalogsKnowTheirLockfile(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	library := testLibrary(t, map[string]string{"tzdata": "tzdata"})
+
+	source := []byte("@EXPLICIT\nhttps://conda.anaconda.org/conda-forge/noarch/tzdata-2022g-h191b570_0.conda#51fc4fcfb19f5d95ffc8c339db5068e8\n")
+	lockfile := filepath.Join(t.TempDir(), "explicit.lock")
+	must.Nil(ioutil.WriteFile(lockfile, source, 0o644))
+	_, blueprint, err := htfs.ComposeFinalBlueprint([]string{lockfile}, "")
+	must.Nil(err)
+	must.True(conda.IsLockfile(lockfile))
+
+	must.Nil(library.Record(blueprint))
+
+	infos, err := htfs.CatalogInfos()
+	must.Nil(err)
+	must.Equal(1, len(infos))
+	must.Equal(fmt.Sprintf("%02x", sha256.Sum256(source)), infos[0].Provenance.LockfileHash)
+}
@@ -0,0 +1,94 @@
+package htfs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+// Catalog modification time is its last-used timestamp, since catalogs are
+// touched on every restore from them.
+
+type PruneReport struct {
+	Dryrun  bool           `json:"dryrun"`
+	Removed []string       `json:"removed"`
+	Kept    []string       `json:"kept"`
+	Garbage *GarbageReport `json:"garbage"`
+}
+
+type catalogUse struct {
+	path string
+	used time.Time
+}
+
+func catalogsByUse() ([]*catalogUse, error) {
+	result := []*catalogUse{}
+	for _, name := range Catalogs() {
+		fullpath := filepath.Join(common.HololibCatalogLocation(), name)
+		stat, err := os.Stat(fullpath)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &catalogUse{fullpath, stat.ModTime()})
+	}
+	sort.SliceStable(result, func(left, right int) bool {
+		return result[left].used.After(result[right].used)
+	})
+	return result, nil
+}
+
+func pruneCatalogs(days, keep int, dryrun bool, report *PruneReport) (err error) {
+	defer fail.Around(&err)
+
+	locker, err := pathlib.Locker(common.HolotreeLock(), 30000)
+	fail.On(err != nil, "Could not get lock for holotree. Quiting.")
+	defer locker.Release()
+
+	catalogs, err := catalogsByUse()
+	fail.On(err != nil, "%v", err)
+	pinned := LoadPins().PinnedCatalogs()
+	deadline := time.Now().Add(time.Duration(-days) * 24 * time.Hour)
+	for at, catalog := range catalogs {
+		if at < keep || catalog.used.After(deadline) || pinned[filepath.Base(catalog.path)] {
+			report.Kept = append(report.Kept, catalog.path)
+			continue
+		}
+		if !dryrun {
+			err = TryRemove("catalog", catalog.path)
+			fail.On(err != nil, "%v", err)
+			if pathlib.IsFile(SignatureFile(catalog.path)) {
+				err = TryRemove("signature", SignatureFile(catalog.path))
+				fail.On(err != nil, "%v", err)
+			}
+		}
+		report.Removed = append(report.Removed, catalog.path)
+	}
+	return nil
+}
+
+// PruneCatalogs removes catalogs that have not been used for given number
+// of days, but always keeps given number of most recently used ones. Then
+// blobs no longer needed by remaining catalogs are garbage collected.
+func PruneCatalogs(days, keep int, dryrun bool) (report *PruneReport, err error) {
+	defer fail.Around(&err)
+
+	report = &PruneReport{
+		Dryrun:  dryrun,
+		Removed: []string{},
+		Kept:    []string{},
+	}
+	err = pruneCatalogs(days, keep, dryrun, report)
+	fail.On(err != nil, "%v", err)
+	excluded := make(map[string]bool)
+	for _, catalog := range report.Removed {
+		excluded[catalog] = true
+	}
+	report.Garbage, err = collectGarbage(dryrun, excluded)
+	fail.On(err != nil, "%v", err)
+	return report, nil
+}
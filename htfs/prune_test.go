@@ -0,0 +1,55 @@
+package htfs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+func TestCanPruneStaleCatalogs(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	library := testLibrary(t, nil)
+	for _, name := range []string{"first", "second", "third"} {
+		testStage(t, library, map[string]string{"unique.txt": "unique to " + name})
+		must.Nil(library.Record([]byte("prune: " + name)))
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	filepath.Walk(common.HololibLibraryLocation(), func(fullpath string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			os.Chtimes(fullpath, old, old)
+		}
+		return nil
+	})
+	catalogs := htfs.Catalogs()
+	must.Equal(3, len(catalogs))
+	for at, catalog := range catalogs {
+		used := time.Now().Add(time.Duration(-10*at) * 24 * time.Hour)
+		must.Nil(os.Chtimes(filepath.Join(common.HololibCatalogLocation(), catalog), used, used))
+	}
+
+	report, err := htfs.PruneCatalogs(5, 0, true)
+	must.Nil(err)
+	must.True(report.Dryrun)
+	must.Equal(2, len(report.Removed))
+	must.Equal(1, len(report.Kept))
+	must.Equal(2, len(report.Garbage.Removed))
+	must.Equal(3, len(htfs.Catalogs()))
+
+	report, err = htfs.PruneCatalogs(5, 2, false)
+	must.Nil(err)
+	must.Equal(1, len(report.Removed))
+	must.Equal(2, len(report.Kept))
+	must.Equal(1, len(report.Garbage.Removed))
+	must.Equal(filepath.Join(common.HololibCatalogLocation(), catalogs[2]), report.Removed[0])
+	wont.True(pathlib.IsFile(report.Removed[0]))
+	wont.True(pathlib.IsFile(library.ExactLocation(report.Garbage.Removed[0])))
+	must.Equal(2, len(htfs.Catalogs()))
+}
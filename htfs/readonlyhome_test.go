@@ -0,0 +1,100 @@
+package htfs_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/settings"
+)
+
+func snapshotTree(root string) (map[string]string, error) {
+	result := make(map[string]string)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		result[path] = fmt.Sprintf("%v %d %d", info.Mode(), info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return result, err
+}
+
+func chmodTree(root string, writable bool) {
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		mode := os.FileMode(0o444)
+		if info.IsDir() {
+			mode = 0o555
+		}
+		if writable {
+			mode |= 0o200
+		}
+		os.Chmod(path, mode)
+		return nil
+	})
+}
+
+func TestReadonlyHomeIsNeverWritten(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("read-only directories work differently on windows")
+	}
+	must, wont := hamlet.Specifications(t)
+
+	folder := t.TempDir()
+	home := filepath.Join(folder, "home")
+	writable := filepath.Join(folder, "writable")
+	original := os.Getenv(common.ROBOCORP_HOME_VARIABLE)
+	defer os.Setenv(common.ROBOCORP_HOME_VARIABLE, original)
+	originalWritable := os.Getenv(common.ROBOCORP_WRITABLE_HOME)
+	defer os.Setenv(common.ROBOCORP_WRITABLE_HOME, originalWritable)
+
+	common.ControllerType = "unittest"
+	blueprint := []byte("readonly home: unittest")
+	os.Setenv(common.ROBOCORP_HOME_VARIABLE, home)
+	os.Setenv(common.ROBOCORP_WRITABLE_HOME, writable)
+	library, err := htfs.New()
+	must.Nil(err)
+	must.Nil(ioutil.WriteFile(filepath.Join(library.Stage(), "seeded.txt"), []byte("seeded content"), 0o644))
+	must.Nil(library.Record(blueprint))
+	defaults, err := settings.DefaultSettings()
+	must.Nil(err)
+	must.Nil(ioutil.WriteFile(filepath.Join(home, "settings.yaml"), defaults, 0o644))
+	must.Nil(os.RemoveAll(writable))
+
+	chmodTree(home, false)
+	defer chmodTree(home, true)
+	before, err := snapshotTree(home)
+	must.Nil(err)
+
+	must.True(common.SplitHome())
+	for _, location := range []string{common.BinLocation(), common.TemplateLocation(), common.HololibLockLocation(), common.HololibStagingLocation(), settings.WritableSettingsLocation()} {
+		must.True(strings.HasPrefix(location, writable))
+	}
+	must.Equal(filepath.Join(home, "settings.yaml"), settings.SettingsFileLocation())
+
+	library, err = htfs.New()
+	must.Nil(err)
+	must.True(library.HasBlueprint(blueprint))
+	path, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("readonly-unittest"))
+	must.Nil(err)
+	must.True(strings.HasPrefix(path, writable))
+	content, err := ioutil.ReadFile(filepath.Join(path, "seeded.txt"))
+	must.Nil(err)
+	must.Equal("seeded content", string(content))
+	_, err = os.Stat(filepath.Join(writable, "settings.yaml"))
+	wont.Nil(err)
+
+	after, err := snapshotTree(home)
+	must.Nil(err)
+	must.Equal(before, after)
+}
@@ -0,0 +1,21 @@
+package htfs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func reflinkFile(source, target string) error {
+	reader, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	writer, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+	return unix.IoctlFileClone(int(writer.Fd()), int(reader.Fd()))
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package htfs
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func reflinkFile(source, target string) error {
+	return fmt.Errorf("Reflink is not supported on %s.", runtime.GOOS)
+}
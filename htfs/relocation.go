@@ -0,0 +1,103 @@
+package htfs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/robocorp/rcc/settings"
+)
+
+// relocationSearches lists search strings of "relocations" setting, which
+// are located (in addition to holotree path) when files are lifted.
+func relocationSearches() []string {
+	result := []string{}
+	for _, relocation := range settings.Global.Relocations() {
+		if relocation != nil && len(relocation.Search) > 0 {
+			result = append(result, relocation.Search)
+		}
+	}
+	return result
+}
+
+// relocationReplacements maps search strings to their replacements on this
+// machine. Replacements may refer to environment variables, and when they
+// are shorter than search, they are padded to same length (with "/" unless
+// other padding is given), so that file offsets stay valid.
+func relocationReplacements() (map[string][]byte, error) {
+	result := make(map[string][]byte)
+	for _, relocation := range settings.Global.Relocations() {
+		if relocation == nil {
+			continue
+		}
+		if len(relocation.Search) == 0 {
+			return nil, fmt.Errorf("Relocation with replacement %q has empty search.", relocation.Replace)
+		}
+		replace := []byte(os.ExpandEnv(relocation.Replace))
+		if len(replace) > len(relocation.Search) {
+			return nil, fmt.Errorf("Relocation replacement %q is longer than its search %q.", replace, relocation.Search)
+		}
+		padding := byte('/')
+		if len(relocation.Padding) > 0 {
+			padding = relocation.Padding[0]
+		}
+		filler := bytes.Repeat([]byte{padding}, len(relocation.Search)-len(replace))
+		result[relocation.Search] = append(replace, filler...)
+	}
+	return result, nil
+}
+
+// ValidRelocations checks that all relocations can be applied.
+func ValidRelocations() error {
+	_, err := relocationReplacements()
+	return err
+}
+
+// relocateFile writes holotree path and configured replacements into their
+// recorded positions in sink. Search strings, which are not configured on
+// this machine, are left as they were.
+func relocateFile(sink *os.File, details *File, rewrite *Rewriting) error {
+	if len(details.Relocations) > 0 {
+		replacements, err := relocationReplacements()
+		if err != nil {
+			return err
+		}
+		for search, positions := range details.Relocations {
+			replace, ok := replacements[search]
+			if !ok {
+				continue
+			}
+			for _, position := range positions {
+				_, err := sink.WriteAt(replace, position)
+				if err != nil {
+					return fmt.Errorf("%v %d", err, position)
+				}
+			}
+		}
+	}
+	for _, position := range details.Rewrite {
+		err := rewrite.writeAt(sink, position)
+		if err != nil {
+			return fmt.Errorf("%v %d", err, position)
+		}
+	}
+	return nil
+}
+
+// writeAt writes identity into position, and new holotree path in front of
+// it, when file has recorded holotree path there.
+func (it *Rewriting) writeAt(sink *os.File, position int64) error {
+	offset := position - int64(len(it.Origin))
+	if len(it.Prefix) > 0 && offset >= 0 {
+		recorded := make([]byte, len(it.Origin))
+		_, err := sink.ReadAt(recorded, offset)
+		if err == nil && bytes.Equal(recorded, it.Origin) {
+			_, err = sink.WriteAt(it.Prefix, offset)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	_, err := sink.WriteAt(it.Identity, position)
+	return err
+}
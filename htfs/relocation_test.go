@@ -0,0 +1,50 @@
+package htfs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/settings"
+)
+
+func TestRelocationsReplaceExtraPrefixesWithPadding(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	defer os.Unsetenv("RCC_TEST_TOOLS")
+	os.Setenv("RCC_TEST_TOOLS", "/tools")
+
+	config := settings.Global.Holotree()
+	previous := config.Relocations
+	defer func() {
+		config.Relocations = previous
+	}()
+	config.Relocations = []*settings.Relocation{
+		{Search: "/opt/buildtools/v1", Replace: "$RCC_TEST_TOOLS"},
+		{Search: "/opt/unused/prefix", Replace: "/other"},
+	}
+
+	common.ControllerType = "unittest"
+	blueprint := []byte("relocation: unittest")
+	library := testLibrary(t, map[string]string{"bin/plain": "nothing to relocate\n"})
+	must.Nil(ioutil.WriteFile(filepath.Join(library.Stage(), "bin", "script"), []byte("#!/opt/buildtools/v1/bin/python\nuse /opt/buildtools/v1/lib\n"), 0o755))
+	must.Nil(library.Record(blueprint))
+
+	space, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("relocation"))
+	must.Nil(err)
+	content, err := ioutil.ReadFile(filepath.Join(space, "bin", "script"))
+	must.Nil(err)
+	must.Equal("#!/tools/////////////bin/python\nuse /tools/////////////lib\n", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(space, "bin", "plain"))
+	must.Nil(err)
+	must.Equal("nothing to relocate\n", string(content))
+
+	config.Relocations[0].Replace = "/much/longer/than/search/string"
+	wont.Nil(htfs.ValidRelocations())
+	_, err = library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("relocation"))
+	wont.Nil(err)
+}
@@ -0,0 +1,196 @@
+package htfs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+// RepackReport tells how many loose blobs were migrated from directory tree
+// into packs, how many blobs are in packs after repack, and how many bytes
+// of removed blobs and interrupted writes were reclaimed.
+type RepackReport struct {
+	Migrated  int   `json:"migrated"`
+	Blobs     int   `json:"blobs"`
+	Packs     int   `json:"packs"`
+	Reclaimed int64 `json:"reclaimed"`
+}
+
+func (it *packBlobStore) packSizes() (total int64, err error) {
+	for _, name := range pathlib.Glob(it.directory, "*.pack") {
+		info, err := os.Stat(filepath.Join(it.directory, name))
+		if err != nil {
+			return 0, err
+		}
+		total += info.Size()
+	}
+	return total, nil
+}
+
+func (it *packBlobStore) copyEntry(digest string, entry *packEntry, sink *os.File) (int64, error) {
+	source, err := it.Open(digest)
+	if err != nil {
+		return 0, err
+	}
+	defer source.Close()
+	return io.Copy(sink, source)
+}
+
+// compact rewrites all blobs known by index into new packs, and then replaces
+// index and removes old packs.
+func (it *packBlobStore) compact() (report *RepackReport, err error) {
+	defer fail.Around(&err)
+
+	locker, err := it.locked()
+	fail.On(err != nil, "Could not lock packs -> %v", err)
+	defer locker.Release()
+	err = it.reload()
+	fail.On(err != nil, "Could not read pack index -> %v", err)
+	before, err := it.packSizes()
+	fail.On(err != nil, "%v", err)
+	old := pathlib.Glob(it.directory, "*.pack")
+
+	digests := make([]string, 0, len(it.index))
+	for digest, _ := range it.index {
+		digests = append(digests, digest)
+	}
+	sort.Strings(digests)
+
+	report = &RepackReport{}
+	index := make(map[string]*packEntry)
+	// new packs are numbered above all existing ones, even unreferenced
+	pack := it.current
+	for _, name := range old {
+		var number int
+		_, err := fmt.Sscanf(name, "%d.pack", &number)
+		if err == nil && number > pack {
+			pack = number
+		}
+	}
+	var sink *os.File
+	var offset int64
+	defer func() {
+		if sink != nil {
+			sink.Close()
+		}
+	}()
+	for _, digest := range digests {
+		if sink == nil || offset >= packLimit {
+			if sink != nil {
+				fail.On(sink.Sync() != nil, "Could not sync pack %d.", pack)
+				sink.Close()
+			}
+			pack, offset = pack+1, 0
+			sink, err = os.Create(it.packname(pack))
+			fail.On(err != nil, "Could not create pack -> %v", err)
+			report.Packs += 1
+		}
+		size, err := it.copyEntry(digest, it.index[digest], sink)
+		fail.On(err != nil, "Could not repack blob %q -> %v", digest, err)
+		index[digest] = &packEntry{pack, offset, size}
+		offset += size
+		report.Blobs += 1
+	}
+	if sink != nil {
+		fail.On(sink.Sync() != nil, "Could not sync pack %d.", pack)
+	}
+
+	partname := fmt.Sprintf("%s.part%s", it.indexname(), <-common.Identities)
+	defer os.Remove(partname)
+	handle, err := os.Create(partname)
+	fail.On(err != nil, "Could not create pack index -> %v", err)
+	for _, digest := range digests {
+		entry := index[digest]
+		_, err = fmt.Fprintf(handle, "%s %d %d %d\n", digest, entry.pack, entry.offset, entry.size)
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = handle.Sync()
+	}
+	handle.Close()
+	fail.On(err != nil, "Could not write pack index -> %v", err)
+	err = TryRename("repack", partname, it.indexname())
+	fail.On(err != nil, "%v", err)
+
+	it.Lock()
+	// replaced index is read again as whole on next reload
+	it.index, it.current, it.stamp, it.seen = index, pack, nil, 0
+	it.Unlock()
+	for _, name := range old {
+		err := os.Remove(filepath.Join(it.directory, name))
+		if err != nil {
+			common.Debug("Could not remove old pack %q, reason: %v", name, err)
+		}
+	}
+	after, err := it.packSizes()
+	fail.On(err != nil, "%v", err)
+	report.Reclaimed = before - after
+	return report, nil
+}
+
+// migrate moves loose blobs of own library directory tree into packs.
+func (it *packBlobStore) migrate() (migrated int, err error) {
+	defer fail.Around(&err)
+
+	library := common.HololibLibraryLocation()
+	loose := []string{}
+	err = filepath.Walk(library, func(fullpath string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() && isDigestName(info.Name()) {
+			loose = append(loose, fullpath)
+		}
+		return nil
+	})
+	fail.On(err != nil, "Could not list loose blobs -> %v", err)
+	for _, fullpath := range loose {
+		digest := filepath.Base(fullpath)
+		if _, ok := it.lookup(digest); !ok {
+			source, err := os.Open(fullpath)
+			fail.On(err != nil, "%v", err)
+			err = it.Put(digest, source)
+			source.Close()
+			fail.On(err != nil, "Could not pack blob %q -> %v", digest, err)
+		}
+		err = TryRemove("repack", fullpath)
+		fail.On(err != nil, "%v", err)
+		migrated += 1
+	}
+	return migrated, nil
+}
+
+// Repack migrates loose blobs of hololib into packs, and rewrites packs
+// without removed blobs. It requires "pack" blob store to be selected in
+// settings, since after migration blobs are only found from packs.
+func Repack() (report *RepackReport, err error) {
+	defer fail.Around(&err)
+
+	fail.On(BlobStoreName() != packStore, "Repack requires blob-store %q in settings, but it is %q.", packStore, BlobStoreName())
+	callback := pathlib.LockWaitMessage("Serialized holotree repack")
+	locker, err := pathlib.Locker(common.HolotreeLock(), 30000)
+	callback()
+	fail.On(err != nil, "Could not get lock for holotree. Quiting.")
+	defer locker.Release()
+
+	created, err := newPackBlobStore(SystemHololib())
+	fail.On(err != nil, "%v", err)
+	store := created.(*packBlobStore)
+	migrated, err := store.migrate()
+	fail.On(err != nil, "%v", err)
+	report, err = store.compact()
+	fail.On(err != nil, "%v", err)
+	report.Migrated = migrated
+	return report, nil
+}
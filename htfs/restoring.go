@@ -0,0 +1,28 @@
+package htfs
+
+import (
+	"os"
+
+	"github.com/robocorp/rcc/pathlib"
+)
+
+// Restore marker exists next to space while restore is changing files in
+// it. Files are compared by size and modification time only, so space left
+// by interrupted restore (signal, timeout, or crash) cannot be trusted, and
+// next restore starts it from scratch.
+
+func restoreMarker(targetdir string) string {
+	return targetdir + ".restoring"
+}
+
+func interruptedRestore(targetdir string) bool {
+	return pathlib.IsFile(restoreMarker(targetdir))
+}
+
+func markRestoring(targetdir string) error {
+	return os.WriteFile(restoreMarker(targetdir), []byte("restoring"), 0o644)
+}
+
+func restoreDone(targetdir string) error {
+	return TryRemove("marker", restoreMarker(targetdir))
+}
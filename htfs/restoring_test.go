@@ -0,0 +1,44 @@
+package htfs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestInterruptedRestoreIsRestoredFromScratch(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	library := testLibrary(t, map[string]string{"content.txt": "original"})
+	blueprint := []byte("restoring: test")
+	must.Nil(library.Record(blueprint))
+
+	space, err := library.Restore(blueprint, []byte("interrupted"), []byte("space"))
+	must.Nil(err)
+	marker := space + ".restoring"
+	wont.True(fileExists(marker))
+
+	target := filepath.Join(space, "content.txt")
+	stat, err := os.Stat(target)
+	must.Nil(err)
+	must.Nil(ioutil.WriteFile(target, []byte("modified"), 0o644))
+	must.Nil(os.Chtimes(target, stat.ModTime(), stat.ModTime()))
+	must.Nil(ioutil.WriteFile(filepath.Join(space, "debris.txt"), []byte("debris"), 0o644))
+	must.Nil(ioutil.WriteFile(marker, []byte("restoring"), 0o644))
+
+	_, err = library.Restore(blueprint, []byte("interrupted"), []byte("space"))
+	must.Nil(err)
+	wont.True(fileExists(marker))
+	wont.True(fileExists(filepath.Join(space, "debris.txt")))
+	content, err := ioutil.ReadFile(target)
+	must.Nil(err)
+	must.Equal("original", string(content))
+}
+
+func fileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
@@ -0,0 +1,456 @@
+package htfs
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/journal"
+	"github.com/robocorp/rcc/pathlib"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	catalogPrefix  = `/catalog/`
+	blobPrefix     = `/blob/`
+	signatureLimit = 4096
+)
+
+var (
+	catalogPattern = regexp.MustCompile(`^[0-9a-f]{16}\.[a-z0-9_]+$`)
+	digestPattern  = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+type Permission struct {
+	Read  []string `yaml:"read"`
+	Write []string `yaml:"write"`
+}
+
+type SharedAccess struct {
+	Tokens      map[string]string      `yaml:"tokens"`
+	Permissions map[string]*Permission `yaml:"permissions"`
+}
+
+func LoadSharedAccess(filename string) (access *SharedAccess, err error) {
+	defer fail.Around(&err)
+
+	content, err := ioutil.ReadFile(filename)
+	fail.On(err != nil, "Could not read access file %q -> %v", filename, err)
+	access = &SharedAccess{}
+	err = yaml.Unmarshal(content, access)
+	fail.On(err != nil, "Could not parse access file %q -> %v", filename, err)
+	return access, nil
+}
+
+// Identity resolves who is calling, either from bearer token or from
+// verified client certificate common name. Empty identity means anonymous.
+// Authorization header without exact "Bearer " prefix is ignored.
+func (it *SharedAccess) Identity(request *http.Request) string {
+	header := request.Header.Get("Authorization")
+	bearer := strings.TrimPrefix(header, "Bearer ")
+	if len(bearer) > 0 && len(bearer) < len(header) {
+		for token, identity := range it.Tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(bearer)) == 1 {
+				return identity
+			}
+		}
+	}
+	if request.TLS != nil && len(request.TLS.VerifiedChains) > 0 {
+		return request.TLS.VerifiedChains[0][0].Subject.CommonName
+	}
+	return ""
+}
+
+func matchesAny(patterns []string, catalog string) bool {
+	for _, pattern := range patterns {
+		found, err := filepath.Match(pattern, catalog)
+		if err == nil && found {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed tells if identity can read (or write) given catalog. Catalog "*"
+// means "any catalog", which is used to guard listing and blob uploads.
+// Reading blobs is further scoped by server to catalogs referring to them.
+func (it *SharedAccess) Allowed(identity, catalog string, write bool) bool {
+	if len(identity) == 0 {
+		return false
+	}
+	permission, ok := it.Permissions[identity]
+	if !ok || permission == nil {
+		return false
+	}
+	patterns := permission.Read
+	if write {
+		patterns = permission.Write
+	}
+	if catalog == "*" {
+		return len(patterns) > 0
+	}
+	return matchesAny(patterns, catalog)
+}
+
+type sharedServer struct {
+	access   *SharedAccess
+	library  MutableLibrary
+	readonly bool
+	lock     sync.Mutex
+	stamp    time.Time
+	owners   map[string]map[string]bool
+}
+
+func NewSharedServer(access *SharedAccess, library MutableLibrary) http.Handler {
+	return newServer(&sharedServer{
+		access:  access,
+		library: library,
+	})
+}
+
+// NewReadonlyServer serves catalogs and blobs to anyone, but does not
+// accept uploads, and verifies blobs before serving them, so that damaged
+// content never spreads from build host to other machines.
+func NewReadonlyServer(library MutableLibrary) http.Handler {
+	return newServer(&sharedServer{
+		access:   &SharedAccess{},
+		library:  library,
+		readonly: true,
+	})
+}
+
+func newServer(it *sharedServer) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(catalogPrefix, it.catalog)
+	mux.HandleFunc(blobPrefix, it.blob)
+	return mux
+}
+
+func (it *sharedServer) authorized(response http.ResponseWriter, request *http.Request, catalog string, write bool) bool {
+	if it.readonly {
+		if write {
+			http.Error(response, "read-only server", http.StatusMethodNotAllowed)
+		}
+		return !write
+	}
+	identity := it.access.Identity(request)
+	if len(identity) == 0 {
+		http.Error(response, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	if !it.access.Allowed(identity, catalog, write) {
+		common.Debug("Shared holotree: %q denied access to %q (write=%v).", identity, catalog, write)
+		http.Error(response, "forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// ownersOf tells which catalogs refer to blob. Mapping is loaded again only
+// when catalog directory has changed.
+func (it *sharedServer) ownersOf(digest string) map[string]bool {
+	it.lock.Lock()
+	defer it.lock.Unlock()
+
+	stat, err := os.Stat(common.HololibCatalogLocation())
+	if err != nil {
+		return nil
+	}
+	if it.owners == nil || !stat.ModTime().Equal(it.stamp) {
+		it.owners = LoadHololibHashes()
+		it.stamp = stat.ModTime()
+	}
+	return it.owners[digest]
+}
+
+// readableBlob allows fetching blob only when identity can read some catalog
+// which refers to it, so that digests leaked from other teams are useless.
+func (it *sharedServer) readableBlob(response http.ResponseWriter, request *http.Request, digest string) bool {
+	if it.readonly {
+		return true
+	}
+	identity := it.access.Identity(request)
+	for catalog, _ := range it.ownersOf(digest) {
+		if it.access.Allowed(identity, filepath.Base(catalog), false) {
+			return true
+		}
+	}
+	common.Debug("Shared holotree: %q denied access to blob %q.", identity, digest)
+	http.Error(response, "forbidden", http.StatusForbidden)
+	return false
+}
+
+// catalogs lists catalogs caller is allowed to read, as JSON array.
+func (it *sharedServer) catalogs(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		http.Error(response, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !it.authorized(response, request, "*", false) {
+		return
+	}
+	identity := it.access.Identity(request)
+	result := []string{}
+	for _, name := range Catalogs() {
+		if it.readonly || it.access.Allowed(identity, name, false) {
+			result = append(result, name)
+		}
+	}
+	body, err := json.Marshal(result)
+	if err != nil {
+		http.Error(response, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response.Header().Set("Content-Type", "application/json")
+	response.Write(body)
+}
+
+func (it *sharedServer) catalog(response http.ResponseWriter, request *http.Request) {
+	name := strings.TrimPrefix(request.URL.Path, catalogPrefix)
+	if len(name) == 0 {
+		it.catalogs(response, request)
+		return
+	}
+	signature := isSignatureFile(name)
+	name = strings.TrimSuffix(name, signatureSuffix)
+	if !catalogPattern.MatchString(name) {
+		http.Error(response, "invalid catalog name", http.StatusBadRequest)
+		return
+	}
+	filename := filepath.Join(common.HololibCatalogLocation(), name)
+	served := filename
+	if signature {
+		served = SignatureFile(filename)
+	}
+	switch request.Method {
+	case http.MethodGet, http.MethodHead:
+		if !it.authorized(response, request, name, false) {
+			return
+		}
+		if !pathlib.IsFile(served) {
+			http.NotFound(response, request)
+			return
+		}
+		http.ServeFile(response, request, served)
+	case http.MethodPut:
+		if !it.authorized(response, request, name, true) {
+			return
+		}
+		var err error
+		if signature {
+			err = receiveSignature(request.Body, filename)
+		} else {
+			err = it.receiveCatalog(request.Body, filename)
+		}
+		if err != nil {
+			http.Error(response, err.Error(), http.StatusBadRequest)
+			return
+		}
+		what := "catalog"
+		if signature {
+			what = "signature"
+		}
+		journal.Post("shared-holotree", name, "%s uploaded by %q", what, it.access.Identity(request))
+		response.WriteHeader(http.StatusCreated)
+	default:
+		http.Error(response, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (it *sharedServer) receiveCatalog(source io.Reader, filename string) (err error) {
+	defer fail.Around(&err)
+
+	partname := fmt.Sprintf("%s.part%s", filename, <-common.Identities)
+	defer os.Remove(partname)
+	err = receiveInto(source, partname)
+	fail.On(err != nil, "Could not receive catalog -> %v", err)
+	root, err := NewRoot(".")
+	fail.On(err != nil, "Could not create root -> %v", err)
+	err = root.LoadFrom(partname)
+	fail.On(err != nil, "Not a valid catalog -> %v", err)
+	err = root.Treetop(CatalogCheck(it.library, root))
+	fail.On(err != nil, "Catalog refers to missing blobs -> %v", err)
+	locker, err := keyLock(filepath.Base(filename))
+	fail.On(err != nil, "%v", err)
+	defer locker.Release()
+	err = os.Remove(SignatureFile(filename))
+	fail.On(err != nil && !os.IsNotExist(err), "Could not remove old signature -> %v", err)
+	return TryRename("catalog", partname, filename)
+}
+
+// receiveSignature accepts only signature, which really is valid for
+// catalog already on server. Is signer trusted, is decided by clients.
+func receiveSignature(source io.Reader, filename string) (err error) {
+	defer fail.Around(&err)
+
+	signature, err := ioutil.ReadAll(io.LimitReader(source, signatureLimit))
+	fail.On(err != nil, "Could not receive signature -> %v", err)
+	content, err := ioutil.ReadFile(filename)
+	fail.On(err != nil, "No catalog %q for signature.", filepath.Base(filename))
+	fields := strings.Fields(string(signature))
+	fail.On(len(fields) != 3, "Signature is not in %q format.", signatureAlgorithm)
+	_, err = VerifySignature(content, string(signature), fields[1:2])
+	fail.On(err != nil, "%v", err)
+	locker, err := keyLock(filepath.Base(filename))
+	fail.On(err != nil, "%v", err)
+	defer locker.Release()
+	partname := fmt.Sprintf("%s.part%s", SignatureFile(filename), <-common.Identities)
+	defer os.Remove(partname)
+	err = ioutil.WriteFile(partname, signature, 0o644)
+	fail.On(err != nil, "%v", err)
+	return TryRename("signature", partname, SignatureFile(filename))
+}
+
+func (it *sharedServer) blob(response http.ResponseWriter, request *http.Request) {
+	digest := strings.TrimPrefix(request.URL.Path, blobPrefix)
+	if !digestPattern.MatchString(digest) {
+		http.Error(response, "invalid digest", http.StatusBadRequest)
+		return
+	}
+	switch request.Method {
+	case http.MethodGet, http.MethodHead:
+		if !it.authorized(response, request, "*", false) || !it.readableBlob(response, request, digest) {
+			return
+		}
+		if !it.library.HasBlob(digest) {
+			http.NotFound(response, request)
+			return
+		}
+		store, packed := storeOf(it.library)
+		if it.readonly {
+			var actual string
+			var err error
+			if packed {
+				actual, err = storedMatchingDigest(store, digest)
+			} else {
+				actual, err = matchingDigest(it.library.ExactLocation(digest), digest, false)
+			}
+			if err != nil || actual != digest {
+				common.Log("Read-only holotree server: blob %q is damaged, not serving it.", digest)
+				http.Error(response, "damaged blob", http.StatusInternalServerError)
+				return
+			}
+		}
+		response.Header().Set("Content-Type", "application/octet-stream")
+		if !packed {
+			http.ServeFile(response, request, it.library.ExactLocation(digest))
+			return
+		}
+		source, err := store.Open(digest)
+		if err != nil {
+			http.Error(response, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer source.Close()
+		response.WriteHeader(http.StatusOK)
+		if request.Method == http.MethodGet {
+			io.Copy(response, source)
+		}
+	case http.MethodPut:
+		if !it.authorized(response, request, "*", true) {
+			return
+		}
+		if it.library.HasBlob(digest) {
+			response.WriteHeader(http.StatusOK)
+			return
+		}
+		err := receiveBlob(it.library, request.Body, digest)
+		if err != nil {
+			http.Error(response, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response.WriteHeader(http.StatusCreated)
+	default:
+		http.Error(response, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func receiveInto(source io.Reader, filename string) error {
+	sink, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer sink.Close()
+	_, err = io.Copy(sink, source)
+	if err != nil {
+		return err
+	}
+	return sink.Sync()
+}
+
+// receiveBlob stores gzipped blob into library, but only after verifying
+// that its content really matches the digest it claims to have.
+func receiveBlob(library MutableLibrary, source io.Reader, digest string) (err error) {
+	defer fail.Around(&err)
+
+	directory := library.Location(digest)
+	err = os.MkdirAll(directory, 0o755)
+	fail.On(err != nil, "Could not create %q -> %v", directory, err)
+	partname := fmt.Sprintf("%s.part%s", filepath.Join(directory, digest), <-common.Identities)
+	defer os.Remove(partname)
+	err = receiveInto(source, partname)
+	fail.On(err != nil, "Could not receive blob %q -> %v", digest, err)
+	actual, err := matchingDigest(partname, digest, false)
+	fail.On(err != nil, "Could not verify blob %q -> %v", digest, err)
+	fail.On(actual != digest, "Blob digest mismatch, expected %q, got %q.", digest, actual)
+	return keepStoredBlob(library, partname, digest)
+}
+
+// ServeReadonly exposes local hololib to anyone who can connect, for
+// reading only, as lightweight environment cache.
+func ServeReadonly(address string) (err error) {
+	defer fail.Around(&err)
+
+	library, err := New()
+	fail.On(err != nil, "%v", err)
+	server := &http.Server{
+		Addr:    address,
+		Handler: NewReadonlyServer(library),
+	}
+	journal.Post("shared-holotree", "started", "read-only holotree server listening at %q", address)
+	return server.ListenAndServe()
+}
+
+// ServeShared exposes local hololib to other machines. With clientca given,
+// clients must present certificate signed by that authority (mTLS).
+func ServeShared(address, accessfile, certfile, keyfile, clientca string) (err error) {
+	defer fail.Around(&err)
+
+	access, err := LoadSharedAccess(accessfile)
+	fail.On(err != nil, "%v", err)
+	library, err := New()
+	fail.On(err != nil, "%v", err)
+	server := &http.Server{
+		Addr:    address,
+		Handler: NewSharedServer(access, library),
+	}
+	journal.Post("shared-holotree", "started", "shared holotree server listening at %q", address)
+	if len(certfile) == 0 {
+		common.Log("WARNING: shared holotree server at %q is not using TLS!", address)
+		return server.ListenAndServe()
+	}
+	if len(clientca) > 0 {
+		content, err := ioutil.ReadFile(clientca)
+		fail.On(err != nil, "Could not read client CA %q -> %v", clientca, err)
+		pool := x509.NewCertPool()
+		fail.On(!pool.AppendCertsFromPEM(content), "No certificates found from %q.", clientca)
+		server.TLSConfig = &tls.Config{
+			ClientCAs:  pool,
+			ClientAuth: tls.VerifyClientCertIfGiven,
+		}
+	}
+	return server.ListenAndServeTLS(certfile, keyfile)
+}
@@ -0,0 +1,200 @@
+package htfs_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+func TestSharedAccessChecksPermissionsPerCatalog(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	sut := &htfs.SharedAccess{
+		Tokens: map[string]string{"alpha": "team-a", "beta": "team-b"},
+		Permissions: map[string]*htfs.Permission{
+			"team-a": &htfs.Permission{Read: []string{"*"}, Write: []string{"0123*"}},
+			"team-b": &htfs.Permission{Read: []string{"fedc*"}},
+		},
+	}
+
+	request, err := http.NewRequest("GET", "http://localhost/catalog/x", nil)
+	must.Nil(err)
+	must.Equal("", sut.Identity(request))
+	request.Header.Set("Authorization", "Bearer beta")
+	must.Equal("team-b", sut.Identity(request))
+	request.Header.Set("Authorization", "Bearer gamma")
+	must.Equal("", sut.Identity(request))
+	request.Header.Set("Authorization", "beta")
+	must.Equal("", sut.Identity(request))
+	request.Header.Set("Authorization", "bearer beta")
+	must.Equal("", sut.Identity(request))
+
+	must.True(sut.Allowed("team-a", "0123456789abcdef.linux_amd64", true))
+	must.True(sut.Allowed("team-a", "fedcba9876543210.linux_amd64", false))
+	wont.True(sut.Allowed("team-a", "fedcba9876543210.linux_amd64", true))
+	must.True(sut.Allowed("team-b", "fedcba9876543210.linux_amd64", false))
+	wont.True(sut.Allowed("team-b", "0123456789abcdef.linux_amd64", false))
+	wont.True(sut.Allowed("team-b", "*", true))
+	must.True(sut.Allowed("team-b", "*", false))
+	wont.True(sut.Allowed("", "*", false))
+	wont.True(sut.Allowed("team-c", "*", false))
+}
+
+func TestReadonlyServerServesVerifiedContentOnly(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	library := testLibrary(t, map[string]string{
+		"served.txt":  "served",
+		"damaged.txt": "damaged",
+	})
+	must.Nil(library.Record([]byte("readonly: unittest")))
+	catalogs := htfs.Catalogs()
+	must.Equal(1, len(catalogs))
+
+	server := httptest.NewServer(htfs.NewReadonlyServer(library))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/catalog/")
+	must.Nil(err)
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	must.Nil(err)
+	must.Equal(http.StatusOK, response.StatusCode)
+	must.Equal(fmt.Sprintf("[%q]", catalogs[0]), string(body))
+
+	response, err = http.Get(server.URL + "/catalog/" + catalogs[0])
+	must.Nil(err)
+	response.Body.Close()
+	must.Equal(http.StatusOK, response.StatusCode)
+
+	served := fmt.Sprintf("%02x", sha256.Sum256([]byte("served")))
+	response, err = http.Get(server.URL + "/blob/" + served)
+	must.Nil(err)
+	response.Body.Close()
+	must.Equal(http.StatusOK, response.StatusCode)
+
+	damaged := fmt.Sprintf("%02x", sha256.Sum256([]byte("damaged")))
+	must.Nil(ioutil.WriteFile(library.ExactLocation(damaged), []byte("tampered"), 0o644))
+	response, err = http.Get(server.URL + "/blob/" + damaged)
+	must.Nil(err)
+	response.Body.Close()
+	must.Equal(http.StatusInternalServerError, response.StatusCode)
+
+	request, err := http.NewRequest(http.MethodPut, server.URL+"/blob/"+served, strings.NewReader("upload"))
+	must.Nil(err)
+	response, err = http.DefaultClient.Do(request)
+	must.Nil(err)
+	response.Body.Close()
+	must.Equal(http.StatusMethodNotAllowed, response.StatusCode)
+}
+
+func TestSharedServerAuthorizesAndVerifiesUploads(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	library := testLibrary(t, map[string]string{"shared.txt": "shared"})
+	must.Nil(library.Record([]byte("shared: unittest")))
+	catalogs := htfs.Catalogs()
+	must.Equal(1, len(catalogs))
+	catalog, err := ioutil.ReadFile(filepath.Join(common.HololibCatalogLocation(), catalogs[0]))
+	must.Nil(err)
+
+	access := &htfs.SharedAccess{
+		Tokens: map[string]string{"writer": "builders", "reader": "readers", "other": "others"},
+		Permissions: map[string]*htfs.Permission{
+			"builders": &htfs.Permission{Read: []string{"*"}, Write: []string{"*"}},
+			"readers":  &htfs.Permission{Read: []string{catalogs[0]}},
+			"others":   &htfs.Permission{Read: []string{"0000*"}},
+		},
+	}
+	server := httptest.NewServer(htfs.NewSharedServer(access, library))
+	defer server.Close()
+
+	call := func(method, path, token string, body []byte) (int, string) {
+		request, err := http.NewRequest(method, server.URL+path, strings.NewReader(string(body)))
+		must.Nil(err)
+		if len(token) > 0 {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		response, err := http.DefaultClient.Do(request)
+		must.Nil(err)
+		defer response.Body.Close()
+		content, err := ioutil.ReadAll(response.Body)
+		must.Nil(err)
+		return response.StatusCode, string(content)
+	}
+
+	code, _ := call(http.MethodGet, "/catalog/"+catalogs[0], "", nil)
+	must.Equal(http.StatusUnauthorized, code)
+	code, _ = call(http.MethodGet, "/catalog/"+catalogs[0], "nobody", nil)
+	must.Equal(http.StatusUnauthorized, code)
+	code, _ = call(http.MethodGet, "/catalog/"+catalogs[0], "other", nil)
+	must.Equal(http.StatusForbidden, code)
+	code, body := call(http.MethodGet, "/catalog/", "other", nil)
+	must.Equal(http.StatusOK, code)
+	must.Equal("[]", body)
+	code, _ = call(http.MethodGet, "/catalog/not-a-catalog", "reader", nil)
+	must.Equal(http.StatusBadRequest, code)
+
+	code, body = call(http.MethodGet, "/catalog/", "reader", nil)
+	must.Equal(http.StatusOK, code)
+	must.Equal(fmt.Sprintf("[%q]", catalogs[0]), body)
+	code, body = call(http.MethodGet, "/catalog/"+catalogs[0], "reader", nil)
+	must.Equal(http.StatusOK, code)
+	must.Equal(string(catalog), body)
+	code, _ = call(http.MethodPut, "/catalog/"+catalogs[0], "reader", catalog)
+	must.Equal(http.StatusForbidden, code)
+
+	content := []byte("uploaded blob content")
+	digest := fmt.Sprintf("%02x", sha256.Sum256(content))
+	folder := t.TempDir()
+	source := filepath.Join(folder, "upload.txt")
+	must.Nil(ioutil.WriteFile(source, content, 0o644))
+	lifted := filepath.Join(folder, "upload.blob")
+	htfs.LiftFile(source, lifted)()
+	blob, err := ioutil.ReadFile(lifted)
+	must.Nil(err)
+
+	code, _ = call(http.MethodPut, "/blob/"+digest, "", blob)
+	must.Equal(http.StatusUnauthorized, code)
+	code, _ = call(http.MethodPut, "/blob/"+digest, "reader", blob)
+	must.Equal(http.StatusForbidden, code)
+	wont.True(pathlib.IsFile(library.ExactLocation(digest)))
+
+	wrong := fmt.Sprintf("%02x", sha256.Sum256([]byte("something else")))
+	code, body = call(http.MethodPut, "/blob/"+wrong, "writer", blob)
+	must.Equal(http.StatusBadRequest, code)
+	must.True(strings.Contains(body, "mismatch"))
+	wont.True(pathlib.IsFile(library.ExactLocation(wrong)))
+
+	code, _ = call(http.MethodPut, "/blob/"+digest, "writer", blob)
+	must.Equal(http.StatusCreated, code)
+	must.True(pathlib.IsFile(library.ExactLocation(digest)))
+	code, _ = call(http.MethodPut, "/blob/"+digest, "writer", blob)
+	must.Equal(http.StatusOK, code)
+	code, _ = call(http.MethodGet, "/blob/"+digest, "reader", nil)
+	must.Equal(http.StatusForbidden, code)
+	code, _ = call(http.MethodGet, "/blob/"+digest, "", nil)
+	must.Equal(http.StatusUnauthorized, code)
+
+	owned := ""
+	for candidate, _ := range htfs.LoadHololibHashes() {
+		owned = candidate
+	}
+	expected, err := ioutil.ReadFile(library.ExactLocation(owned))
+	must.Nil(err)
+	code, body = call(http.MethodGet, "/blob/"+owned, "reader", nil)
+	must.Equal(http.StatusOK, code)
+	must.Equal(string(expected), body)
+	code, _ = call(http.MethodGet, "/blob/"+owned, "other", nil)
+	must.Equal(http.StatusForbidden, code)
+}
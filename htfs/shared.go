@@ -0,0 +1,284 @@
+package htfs
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/robocorp/rcc/anywork"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/settings"
+)
+
+const (
+	HOLOTREE_TOKEN_VARIABLE = `RCC_HOLOTREE_TOKEN`
+)
+
+type sharedClient struct {
+	endpoint string
+	token    string
+	push     bool
+	client   *http.Client
+}
+
+func CatalogName(key string) string {
+	return fmt.Sprintf("%s.%s", key, common.Platform())
+}
+
+func sharedHolotree() (*sharedClient, error) {
+	config := settings.Global.Holotree()
+	endpoint := strings.TrimRight(strings.TrimSpace(config.SharedServer), "/")
+	if len(endpoint) == 0 {
+		return nil, nil
+	}
+	if common.OfflineFlag {
+		common.Debug("Offline mode, not using shared holotree %s.", endpoint)
+		return nil, nil
+	}
+	return newSharedClient(endpoint)
+}
+
+// newSharedClient talks to shared holotree server at endpoint, using client
+// certificate and token configured for shared holotree.
+func newSharedClient(endpoint string) (*sharedClient, error) {
+	config := settings.Global.Holotree()
+	transport := settings.Global.ConfiguredHttpTransport().Clone()
+	if len(config.ClientCertificate) > 0 {
+		certificate, err := tls.LoadX509KeyPair(config.ClientCertificate, config.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("Could not load shared holotree client certificate, reason: %v", err)
+		}
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return &sharedClient{
+		endpoint: endpoint,
+		token:    strings.TrimSpace(os.Getenv(HOLOTREE_TOKEN_VARIABLE)),
+		push:     config.SharedPush,
+		client:   &http.Client{Transport: transport},
+	}, nil
+}
+
+func (it *sharedClient) do(method, path string, body io.Reader) (*http.Response, error) {
+	request, err := http.NewRequest(method, it.endpoint+path, body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("User-Agent", common.UserAgent())
+	if len(it.token) > 0 {
+		request.Header.Set("Authorization", "Bearer "+it.token)
+	}
+	return it.client.Do(request)
+}
+
+func (it *sharedClient) download(path, filename string) (err error) {
+	defer fail.Around(&err)
+
+	response, err := it.do(http.MethodGet, path, nil)
+	fail.On(err != nil, "Shared holotree GET %q failed -> %v", path, err)
+	defer response.Body.Close()
+	fail.On(response.StatusCode != http.StatusOK, "Shared holotree GET %q status %d.", path, response.StatusCode)
+	return receiveInto(response.Body, filename)
+}
+
+// fetch reads small response, like catalog signature, into memory. Missing
+// content is not an error, it just gives nothing.
+func (it *sharedClient) fetch(path string) (content []byte, err error) {
+	defer fail.Around(&err)
+
+	response, err := it.do(http.MethodGet, path, nil)
+	fail.On(err != nil, "Shared holotree GET %q failed -> %v", path, err)
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return []byte{}, nil
+	}
+	fail.On(response.StatusCode != http.StatusOK, "Shared holotree GET %q status %d.", path, response.StatusCode)
+	return ioutil.ReadAll(io.LimitReader(response.Body, signatureLimit))
+}
+
+func (it *sharedClient) upload(path, filename string) (err error) {
+	defer fail.Around(&err)
+
+	source, err := os.Open(filename)
+	fail.On(err != nil, "Could not open %q -> %v", filename, err)
+	defer source.Close()
+	return it.uploadFrom(path, source)
+}
+
+func (it *sharedClient) uploadFrom(path string, source io.Reader) (err error) {
+	defer fail.Around(&err)
+
+	response, err := it.do(http.MethodPut, path, source)
+	fail.On(err != nil, "Shared holotree PUT %q failed -> %v", path, err)
+	defer response.Body.Close()
+	reason, _ := ioutil.ReadAll(response.Body)
+	ok := response.StatusCode == http.StatusOK || response.StatusCode == http.StatusCreated
+	fail.On(!ok, "Shared holotree PUT %q status %d: %s", path, response.StatusCode, strings.TrimSpace(string(reason)))
+	return nil
+}
+
+func (it *sharedClient) exists(path string) bool {
+	response, err := it.do(http.MethodHead, path, nil)
+	if err != nil {
+		return false
+	}
+	response.Body.Close()
+	return response.StatusCode == http.StatusOK
+}
+
+func (it *sharedClient) blobFetcher(library MutableLibrary, digest string) anywork.Work {
+	return func() {
+		if library.HasBlob(digest) {
+			return
+		}
+		directory := library.Location(digest)
+		partname := fmt.Sprintf("%s.part%s", filepath.Join(directory, digest), <-common.Identities)
+		defer os.Remove(partname)
+		anywork.OnErrPanicCloseAll(os.MkdirAll(directory, 0o755))
+		anywork.OnErrPanicCloseAll(it.download(blobPrefix+digest, partname))
+		actual, err := matchingDigest(partname, digest, false)
+		anywork.OnErrPanicCloseAll(err)
+		if actual != digest {
+			panic(fmt.Sprintf("Shared blob digest mismatch, expected %q, got %q.", digest, actual))
+		}
+		anywork.OnErrPanicCloseAll(keepStoredBlob(library, partname, digest))
+	}
+}
+
+func (it *sharedClient) Pull(library MutableLibrary, key string) (err error) {
+	defer fail.Around(&err)
+
+	name := CatalogName(key)
+	catalog := filepath.Join(common.HololibCatalogLocation(), name)
+	common.TimelineBegin("shared holotree pull %q", name)
+	defer common.TimelineEnd()
+	partname := fmt.Sprintf("%s.part%s", catalog, <-common.Identities)
+	defer os.Remove(partname)
+	err = it.download(catalogPrefix+name, partname)
+	fail.On(err != nil, "%v", err)
+	signature, err := it.fetch(catalogPrefix + name + signatureSuffix)
+	fail.On(err != nil, "%v", err)
+	content, err := ioutil.ReadFile(partname)
+	fail.On(err != nil, "%v", err)
+	err = verifiedSignature(name, content, signature)
+	fail.On(err != nil, "%w", err)
+	root, err := NewRoot(".")
+	fail.On(err != nil, "%v", err)
+	err = root.LoadFrom(partname)
+	fail.On(err != nil, "Shared catalog %q is not valid -> %v", name, err)
+	wanted := make(map[string]string)
+	err = root.Treetop(DigestMapper(wanted))
+	fail.On(err != nil, "%v", err)
+	missing := 0
+	for digest, _ := range wanted {
+		if !library.HasBlob(digest) {
+			missing += 1
+			anywork.Backlog(it.blobFetcher(library, digest))
+		}
+	}
+	err = anywork.Sync()
+	fail.On(err != nil, "Could not fetch shared blobs -> %v", err)
+	common.Debug("Shared holotree pulled %q with %d/%d new blobs.", name, missing, len(wanted))
+	locker, err := keyLock(name)
+	fail.On(err != nil, "%v", err)
+	defer locker.Release()
+	if len(signature) > 0 {
+		err = ioutil.WriteFile(SignatureFile(catalog), signature, 0o644)
+		fail.On(err != nil, "Could not write signature of %q -> %v", name, err)
+	}
+	return TryRename("sharedcatalog", partname, catalog)
+}
+
+func (it *sharedClient) Push(library MutableLibrary, key string) (err error) {
+	defer fail.Around(&err)
+
+	name := CatalogName(key)
+	catalog := filepath.Join(common.HololibCatalogLocation(), name)
+	fail.On(!pathlib.IsFile(catalog), "No local catalog %q to push.", name)
+	common.TimelineBegin("shared holotree push %q", name)
+	defer common.TimelineEnd()
+	root, err := NewRoot(".")
+	fail.On(err != nil, "%v", err)
+	err = root.LoadFrom(catalog)
+	fail.On(err != nil, "%v", err)
+	wanted := make(map[string]string)
+	err = root.Treetop(DigestMapper(wanted))
+	fail.On(err != nil, "%v", err)
+	for digest, _ := range wanted {
+		path := blobPrefix + digest
+		if it.exists(path) {
+			continue
+		}
+		source, err := openStoredBlob(library, digest)
+		fail.On(err != nil, "%v", err)
+		err = it.uploadFrom(path, source)
+		source.Close()
+		fail.On(err != nil, "%v", err)
+	}
+	err = it.upload(catalogPrefix+name, catalog)
+	fail.On(err != nil, "%v", err)
+	if pathlib.IsFile(SignatureFile(catalog)) {
+		return it.upload(catalogPrefix+name+signatureSuffix, SignatureFile(catalog))
+	}
+	return nil
+}
+
+// PullSharedBlueprint brings catalog and its blobs from configured shared
+// holotree server, when it is not yet locally available.
+func PullSharedBlueprint(library MutableLibrary, blueprint []byte) {
+	key := BlueprintHash(blueprint)
+	if pathlib.IsFile(filepath.Join(common.HololibCatalogLocation(), CatalogName(key))) {
+		return
+	}
+	system := SystemHololib()
+	if len(system) > 0 && pathlib.IsFile(filepath.Join(system, "catalog", CatalogName(key))) {
+		return
+	}
+	shared, err := sharedHolotree()
+	if err != nil {
+		common.Log("Warning: %v", err)
+		return
+	}
+	if shared == nil {
+		return
+	}
+	err = shared.Pull(library, key)
+	if _, coded := common.CodeOf(err); coded {
+		common.Log("Warning: shared holotree %s did not provide trusted %q, reason: %v", shared.endpoint, key, err)
+		return
+	}
+	if err != nil {
+		common.Debug("Shared holotree did not provide %q, reason: %v", key, err)
+		return
+	}
+	common.Log("Blueprint %q was pulled from shared holotree %s.", key, shared.endpoint)
+}
+
+// PushSharedBlueprint uploads freshly built catalog to shared holotree server,
+// if that is configured in settings.
+func PushSharedBlueprint(library MutableLibrary, blueprint []byte) {
+	shared, err := sharedHolotree()
+	if err != nil {
+		common.Log("Warning: %v", err)
+		return
+	}
+	if shared == nil || !shared.push {
+		return
+	}
+	key := BlueprintHash(blueprint)
+	err = shared.Push(library, key)
+	if err != nil {
+		common.Log("Warning: could not push %q to shared holotree, reason: %v", key, err)
+		return
+	}
+	common.Log("Blueprint %q was pushed to shared holotree %s.", key, shared.endpoint)
+}
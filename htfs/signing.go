@@ -0,0 +1,205 @@
+package htfs
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/settings"
+)
+
+// Catalog signature is kept next to catalog, as "<catalog>.sig" file with
+// single line: "ed25519 <base64 public key> <base64 signature>". Signature
+// is made over exact bytes of catalog file.
+const (
+	RCC_SIGNING_KEY = `RCC_SIGNING_KEY`
+
+	signatureSuffix    = `.sig`
+	signatureAlgorithm = `ed25519`
+)
+
+// SignatureFile tells where signature of given catalog file is.
+func SignatureFile(catalog string) string {
+	return catalog + signatureSuffix
+}
+
+func isSignatureFile(name string) bool {
+	return strings.HasSuffix(name, signatureSuffix)
+}
+
+func decodeKey(text string, size int) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != size {
+		return nil, fmt.Errorf("Key length is %d bytes, expected %d.", len(key), size)
+	}
+	return key, nil
+}
+
+// GenerateSigningKey writes new private key into keyfile, and returns
+// matching public key, to be shared as trusted key.
+func GenerateSigningKey(keyfile string) (public string, err error) {
+	defer fail.Around(&err)
+
+	fail.On(pathlib.Exists(keyfile), "Key file %q already exists, not overwriting it.", keyfile)
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	fail.On(err != nil, "Could not generate key -> %v", err)
+	encoded := base64.StdEncoding.EncodeToString(privateKey.Seed())
+	err = ioutil.WriteFile(keyfile, []byte(encoded+"\n"), 0o600)
+	fail.On(err != nil, "Could not write key file %q -> %v", keyfile, err)
+	return base64.StdEncoding.EncodeToString(publicKey), nil
+}
+
+func signingKey() (ed25519.PrivateKey, error) {
+	keyfile := os.Getenv(RCC_SIGNING_KEY)
+	if len(keyfile) == 0 {
+		keyfile = settings.Global.SigningKey()
+	}
+	if len(keyfile) == 0 {
+		return nil, fmt.Errorf("There is no signing key (%s or signing-key in settings).", RCC_SIGNING_KEY)
+	}
+	content, err := ioutil.ReadFile(keyfile)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := decodeKey(string(content), ed25519.SeedSize)
+	if err != nil {
+		return nil, fmt.Errorf("Signing key %q is not valid: %v", keyfile, err)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// TrustedKeys returns public keys configured as trusted signers.
+func TrustedKeys() []string {
+	result := []string{}
+	for _, key := range settings.Global.TrustedKeys() {
+		key = strings.TrimSpace(key)
+		if len(key) > 0 {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+// SigningRequired tells if imported catalogs must be signed by trusted key.
+func SigningRequired() bool {
+	return len(TrustedKeys()) > 0
+}
+
+func signatureLine(key ed25519.PrivateKey, content []byte) string {
+	public := key.Public().(ed25519.PublicKey)
+	signature := ed25519.Sign(key, content)
+	return fmt.Sprintf("%s %s %s\n", signatureAlgorithm, base64.StdEncoding.EncodeToString(public), base64.StdEncoding.EncodeToString(signature))
+}
+
+// VerifySignature checks that signature line is valid signature of content,
+// made by one of trusted keys, and returns that key.
+func VerifySignature(content []byte, signature string, trusted []string) (string, error) {
+	parts := strings.Fields(signature)
+	if len(parts) != 3 || parts[0] != signatureAlgorithm {
+		return "", fmt.Errorf("Signature is not in %q format.", signatureAlgorithm)
+	}
+	found := false
+	for _, key := range trusted {
+		if key == parts[1] {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("Signing key %q is not trusted.", parts[1])
+	}
+	public, err := decodeKey(parts[1], ed25519.PublicKeySize)
+	if err != nil {
+		return "", err
+	}
+	raw, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(public), content, raw) {
+		return "", fmt.Errorf("Signature does not match content.")
+	}
+	return parts[1], nil
+}
+
+// SignCatalogs signs given catalogs (names in hololib catalog directory)
+// with configured signing key, and returns public key used.
+func SignCatalogs(catalogs []string) (public string, err error) {
+	defer fail.Around(&err)
+
+	key, err := signingKey()
+	fail.On(err != nil, "%v", err)
+	for _, name := range catalogs {
+		catalog := filepath.Join(common.HololibCatalogLocation(), name)
+		content, err := ioutil.ReadFile(catalog)
+		fail.On(err != nil, "Could not read catalog %q -> %v", catalog, err)
+		err = ioutil.WriteFile(SignatureFile(catalog), []byte(signatureLine(key, content)), 0o644)
+		fail.On(err != nil, "Could not write signature of %q -> %v", catalog, err)
+	}
+	return base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)), nil
+}
+
+// VerifyCatalog checks signature of catalog file against trusted keys.
+func VerifyCatalog(catalog string) (string, error) {
+	content, err := ioutil.ReadFile(catalog)
+	if err != nil {
+		return "", err
+	}
+	signature, err := ioutil.ReadFile(SignatureFile(catalog))
+	if err != nil {
+		return "", fmt.Errorf("Catalog %q is not signed.", filepath.Base(catalog))
+	}
+	return VerifySignature(content, string(signature), TrustedKeys())
+}
+
+// SignContent signs content with configured signing key, and returns
+// signature line and public key used.
+func SignContent(content []byte) (signature string, public string, err error) {
+	key, err := signingKey()
+	if err != nil {
+		return "", "", err
+	}
+	return signatureLine(key, content), base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)), nil
+}
+
+// verifiedSignature checks, when signing is required, that signature is
+// valid for catalog content, made by trusted key. Without trusted keys,
+// everything is accepted.
+func verifiedSignature(name string, content, signature []byte) error {
+	if !SigningRequired() {
+		return nil
+	}
+	if len(signature) == 0 {
+		return common.WithCode(common.CodeHoloSignature, fmt.Errorf("Catalog %q is not signed.", name))
+	}
+	signer, err := VerifySignature(content, string(signature), TrustedKeys())
+	if err != nil {
+		return common.WithCode(common.CodeHoloSignature, fmt.Errorf("Catalog %q failed signature verification -> %v", name, err))
+	}
+	common.Debug("Catalog %q is signed by trusted key %q.", name, signer)
+	return nil
+}
+
+// signedCatalog refuses, when signing is required, to pass along catalog
+// file which is not signed by trusted key.
+func signedCatalog(catalog string) error {
+	if !SigningRequired() {
+		return nil
+	}
+	_, err := VerifyCatalog(catalog)
+	if err != nil {
+		return common.WithCode(common.CodeHoloSignature, fmt.Errorf("Catalog %q failed signature verification -> %v", filepath.Base(catalog), err))
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package htfs_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/settings"
+)
+
+func TestImportAcceptsOnlyCatalogsSignedByTrustedKeys(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	folder := t.TempDir()
+
+	config := settings.Global.Holotree()
+	signing, trusted := config.SigningKey, config.TrustedKeys
+	defer func() {
+		config.SigningKey, config.TrustedKeys = signing, trusted
+	}()
+
+	keyfile := filepath.Join(folder, "signing.key")
+	public, err := htfs.GenerateSigningKey(keyfile)
+	must.Nil(err)
+	_, err = htfs.GenerateSigningKey(keyfile)
+	wont.Nil(err)
+	stranger, err := htfs.GenerateSigningKey(filepath.Join(folder, "stranger.key"))
+	must.Nil(err)
+	config.SigningKey = keyfile
+	config.TrustedKeys = []string{}
+
+	library := testLibrary(t, map[string]string{"signed.txt": "signed content"})
+	must.Nil(library.Record([]byte("signing: unittest")))
+	catalogs := htfs.Catalogs()
+	must.Equal(1, len(catalogs))
+	unsigned := filepath.Join(folder, "unsigned.zip")
+	must.Nil(library.Export(catalogs, []string{}, unsigned))
+	signer, err := htfs.SignCatalogs(catalogs)
+	must.Nil(err)
+	must.Equal(public, signer)
+	must.Equal(catalogs, htfs.Catalogs())
+	signed := filepath.Join(folder, "signed.zip")
+	must.Nil(library.Export(catalogs, []string{}, signed))
+
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, filepath.Join(folder, "user"))
+	config.TrustedKeys = []string{stranger}
+	_, err = htfs.ImportBundle(signed)
+	wont.Nil(err)
+	must.Equal(0, len(htfs.Catalogs()))
+	zipped, err := htfs.ZipLibrary(signed)
+	must.Nil(err)
+	_, err = zipped.Restore([]byte("signing: unittest"), []byte("unittest"), []byte("signed"))
+	wont.Nil(err)
+	unzipped, err := htfs.ZipLibrary(unsigned)
+	must.Nil(err)
+	_, err = unzipped.Restore([]byte("signing: unittest"), []byte("unittest"), []byte("unsigned"))
+	wont.Nil(err)
+	config.TrustedKeys = []string{public}
+	_, err = zipped.Restore([]byte("signing: unittest"), []byte("unittest"), []byte("signed"))
+	must.Nil(err)
+	_, err = unzipped.Restore([]byte("signing: unittest"), []byte("unittest"), []byte("unsigned"))
+	wont.Nil(err)
+
+	config.TrustedKeys = []string{stranger, public}
+	_, err = htfs.ImportBundle(unsigned)
+	wont.Nil(err)
+	must.Equal(0, len(htfs.Catalogs()))
+	_, err = htfs.ImportBundle(signed)
+	must.Nil(err)
+	must.Equal(catalogs, htfs.Catalogs())
+	signer, err = htfs.VerifyCatalog(filepath.Join(common.HololibCatalogLocation(), catalogs[0]))
+	must.Nil(err)
+	must.Equal(public, signer)
+
+	config.TrustedKeys = []string{}
+	_, err = htfs.ImportBundle(unsigned)
+	must.Nil(err)
+	_, err = os.Stat(htfs.SignatureFile(filepath.Join(common.HololibCatalogLocation(), catalogs[0])))
+	wont.Nil(err)
+}
+
+func TestSharedPullAcceptsOnlyCatalogsSignedByTrustedKeys(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	folder := t.TempDir()
+
+	config := settings.Global.Holotree()
+	signing, trusted, server := config.SigningKey, config.TrustedKeys, config.SharedServer
+	defer func() {
+		config.SigningKey, config.TrustedKeys, config.SharedServer = signing, trusted, server
+	}()
+
+	keyfile := filepath.Join(folder, "signing.key")
+	public, err := htfs.GenerateSigningKey(keyfile)
+	must.Nil(err)
+	config.SigningKey = keyfile
+	config.TrustedKeys = []string{}
+
+	blueprint := []byte("signed pull: unittest")
+	builder := testLibrary(t, map[string]string{"pulled.txt": "pulled content"})
+	home := common.RobocorpHome()
+	must.Nil(builder.Record(blueprint))
+	catalogs := htfs.Catalogs()
+	must.Equal(1, len(catalogs))
+	hololib := common.HololibLocation()
+
+	shared := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		name := filepath.Base(request.URL.Path)
+		switch {
+		case strings.HasPrefix(request.URL.Path, "/catalog/"):
+			http.ServeFile(response, request, filepath.Join(hololib, "catalog", name))
+		case strings.HasPrefix(request.URL.Path, "/blob/"):
+			http.ServeFile(response, request, filepath.Join(hololib, "library", name[:2], name[2:4], name[4:6], name))
+		default:
+			http.NotFound(response, request)
+		}
+	}))
+	defer shared.Close()
+	config.SharedServer = shared.URL
+	config.TrustedKeys = []string{public}
+
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, filepath.Join(folder, "user"))
+	library, err := htfs.New()
+	must.Nil(err)
+	htfs.PullSharedBlueprint(library, blueprint)
+	must.Equal(0, len(htfs.Catalogs()))
+	wont.True(library.HasBlueprint(blueprint))
+
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, home)
+	_, err = htfs.SignCatalogs(catalogs)
+	must.Nil(err)
+
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, filepath.Join(folder, "user"))
+	htfs.PullSharedBlueprint(library, blueprint)
+	must.Equal(catalogs, htfs.Catalogs())
+	pulled, err := htfs.New()
+	must.Nil(err)
+	must.True(pulled.HasBlueprint(blueprint))
+	signer, err := htfs.VerifyCatalog(filepath.Join(common.HololibCatalogLocation(), catalogs[0]))
+	must.Nil(err)
+	must.Equal(public, signer)
+}
@@ -0,0 +1,39 @@
+package htfs_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestStageSlotsHaveOwnStagesAndLocks(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, t.TempDir())
+	original := common.StageSlot
+	defer func() {
+		common.StageSlot = original
+	}()
+
+	common.StageSlot = 0
+	library, err := htfs.New()
+	must.Nil(err)
+	stage := library.Stage()
+	must.Equal(common.HolotreeLock(), common.StageLock())
+
+	common.StageSlot = 2
+	slotted, err := htfs.New()
+	must.Nil(err)
+	wont.Equal(stage, slotted.Stage())
+	must.Equal(filepath.Dir(stage), filepath.Dir(slotted.Stage()))
+	must.Equal(len(stage), len(slotted.Stage()))
+	wont.Equal(common.HolotreeLock(), common.StageLock())
+
+	common.StageSlot = 3
+	other, err := htfs.New()
+	must.Nil(err)
+	wont.Equal(slotted.Stage(), other.Stage())
+}
@@ -0,0 +1,135 @@
+package htfs
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SortBySize    = `size`
+	SortByUsed    = `used`
+	SortByCreated = `created`
+)
+
+// SpaceOrders are known orders of space listings.
+var SpaceOrders = []string{SortBySize, SortByUsed, SortByCreated}
+
+// SpaceFilter selects spaces for listings. Zero value (and nil) selects all
+// spaces. Patterns are globs matched against space name and label (directory
+// name of space), and space is selected when any of them matches.
+type SpaceFilter struct {
+	Controller string
+	OlderThan  int
+	Patterns   []string
+}
+
+// ValidPatterns fails on first malformed glob pattern.
+func (it *SpaceFilter) ValidPatterns() error {
+	if it == nil {
+		return nil
+	}
+	for _, pattern := range it.Patterns {
+		_, err := filepath.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("Invalid pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// controlledBy accepts controller both as full identity (like "rcc.vscode")
+// and as controller type given to rcc (like "vscode").
+func (it *SpaceFilter) controlledBy(controller string) bool {
+	owner := strings.SplitN(controller, "@", 2)[0]
+	for _, name := range []string{controller, owner, strings.TrimPrefix(owner, "rcc.")} {
+		if strings.EqualFold(it.Controller, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match tells if space is selected by filter. OlderThan means days since
+// space was last used.
+func (it *SpaceFilter) Match(label, controller, space string, used time.Time) bool {
+	if it == nil {
+		return true
+	}
+	if len(it.Controller) > 0 && !it.controlledBy(controller) {
+		return false
+	}
+	if it.OlderThan > 0 && time.Since(used) < time.Duration(it.OlderThan)*24*time.Hour {
+		return false
+	}
+	if len(it.Patterns) == 0 {
+		return true
+	}
+	for _, pattern := range it.Patterns {
+		for _, name := range []string{space, label} {
+			if matched, _ := filepath.Match(pattern, name); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func createdTime(created string) time.Time {
+	when, err := time.Parse(time.RFC3339, created)
+	if err != nil {
+		return time.Time{}
+	}
+	return when
+}
+
+// SortSpaces orders spaces by size (largest first), by last use, or by
+// creation (most recent first). Empty order sorts by label.
+func SortSpaces(spaces []*SpaceEntry, order string) error {
+	var before func(left, right *SpaceEntry) bool
+	switch order {
+	case "":
+		before = func(left, right *SpaceEntry) bool { return false }
+	case SortBySize:
+		before = func(left, right *SpaceEntry) bool { return left.Size > right.Size }
+	case SortByUsed:
+		before = func(left, right *SpaceEntry) bool { return left.Used.After(right.Used) }
+	case SortByCreated:
+		before = func(left, right *SpaceEntry) bool {
+			return createdTime(left.Created).After(createdTime(right.Created))
+		}
+	default:
+		return fmt.Errorf("Unknown sort order %q, expected one of: %s", order, strings.Join(SpaceOrders, ", "))
+	}
+	sort.SliceStable(spaces, func(left, right int) bool {
+		if before(spaces[left], spaces[right]) {
+			return true
+		}
+		if before(spaces[right], spaces[left]) {
+			return false
+		}
+		return spaces[left].Label < spaces[right].Label
+	})
+	return nil
+}
+
+// ListSpaces lists spaces selected by filter, in given order.
+func ListSpaces(filter *SpaceFilter, order string) ([]*SpaceEntry, error) {
+	err := filter.ValidPatterns()
+	if err != nil {
+		return nil, err
+	}
+	result := []*SpaceEntry{}
+	for _, space := range BrowseSpaces() {
+		if filter.Match(space.Label, space.Controller, space.Space, space.Used) {
+			result = append(result, space)
+		}
+	}
+	err = SortSpaces(result, order)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
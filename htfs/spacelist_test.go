@@ -0,0 +1,71 @@
+package htfs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestListingSpacesWithFiltersAndOrder(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	library := testLibrary(t, map[string]string{"list.txt": "list"})
+	blueprint := []byte("list: test")
+	must.Nil(library.Record(blueprint))
+
+	recent, err := library.Restore(blueprint, []byte("rcc.vscode"), []byte("prod-recent"))
+	must.Nil(err)
+	older, err := library.Restore(blueprint, []byte("rcc.user"), []byte("prod-older"))
+	must.Nil(err)
+	other, err := library.Restore(blueprint, []byte("rcc.user"), []byte("devel"))
+	must.Nil(err)
+	old := time.Now().Add(-5 * 24 * time.Hour)
+	must.Nil(os.Chtimes(older+".meta", old, old))
+
+	all, err := htfs.ListSpaces(nil, "")
+	must.Nil(err)
+	must.Equal(3, len(all))
+	for _, space := range all {
+		wont.Equal("", space.Created)
+	}
+
+	vscode, err := htfs.ListSpaces(&htfs.SpaceFilter{Controller: "vscode"}, "")
+	must.Nil(err)
+	must.Equal(1, len(vscode))
+	must.Equal(filepath.Base(recent), vscode[0].Label)
+
+	prod, err := htfs.ListSpaces(&htfs.SpaceFilter{Patterns: []string{"prod-*"}}, htfs.SortByUsed)
+	must.Nil(err)
+	must.Equal(2, len(prod))
+	must.Equal(filepath.Base(recent), prod[0].Label)
+	must.Equal(filepath.Base(older), prod[1].Label)
+
+	stale, err := htfs.ListSpaces(&htfs.SpaceFilter{OlderThan: 3}, "")
+	must.Nil(err)
+	must.Equal(1, len(stale))
+	must.Equal(filepath.Base(older), stale[0].Label)
+
+	byLabel, err := htfs.ListSpaces(&htfs.SpaceFilter{Patterns: []string{filepath.Base(other)}}, htfs.SortBySize)
+	must.Nil(err)
+	must.Equal(1, len(byLabel))
+
+	_, err = htfs.ListSpaces(nil, "color")
+	wont.Nil(err)
+	_, err = htfs.ListSpaces(&htfs.SpaceFilter{Patterns: []string{"[broken"}}, "")
+	wont.Nil(err)
+
+	created := stale[0].Created
+	_, err = library.Restore(blueprint, []byte("rcc.user"), []byte("prod-older"))
+	must.Nil(err)
+	again, err := htfs.ListSpaces(&htfs.SpaceFilter{Patterns: []string{"prod-older"}}, "")
+	must.Nil(err)
+	must.Equal(created, again[0].Created)
+
+	sizes := htfs.FilteredSpaceSizes(&htfs.SpaceFilter{Controller: "user"})
+	must.Equal(2, len(sizes.Spaces))
+	must.Equal(1, len(sizes.Controllers))
+}
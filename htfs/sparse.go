@@ -0,0 +1,60 @@
+package htfs
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+)
+
+// SparseSkips returns catalog directories, which are not restored into
+// given space, as configured in "sparse-spaces" setting.
+func SparseSkips(space string) []string {
+	result := []string{}
+	for _, skip := range settings.Global.SparseSpaces()[space] {
+		skip = path.Clean(filepath.ToSlash(strings.TrimSpace(skip)))
+		if skip == "." || skip == ".." || strings.HasPrefix(skip, "../") || strings.HasPrefix(skip, "/") {
+			continue
+		}
+		result = append(result, skip)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Sparsify drops given directories (relative, slash separated) from tree,
+// so that they are neither restored nor expected to be there, and records
+// them in root. Returns number of bytes left out.
+func (it *Root) Sparsify(skips []string) int64 {
+	it.Sparse = skips
+	if len(skips) == 0 {
+		return 0
+	}
+	total := int64(0)
+	for _, skip := range skips {
+		parent := it.Tree
+		parts := strings.Split(skip, "/")
+		for _, part := range parts[:len(parts)-1] {
+			parent = parent.Dirs[part]
+			if parent == nil {
+				break
+			}
+		}
+		if parent == nil {
+			continue
+		}
+		name := parts[len(parts)-1]
+		found, ok := parent.Dirs[name]
+		if !ok {
+			continue
+		}
+		size, _ := treeSize(found)
+		total += size
+		delete(parent.Dirs, name)
+	}
+	common.Debug("Sparse space leaves out %v, total of %d bytes.", skips, total)
+	return total
+}
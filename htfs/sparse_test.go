@@ -0,0 +1,62 @@
+package htfs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/settings"
+)
+
+func TestSparseSpaceLeavesOutConfiguredDirectories(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	config := settings.Global.Holotree()
+	previous := config.SparseSpaces
+	defer func() {
+		config.SparseSpaces = previous
+	}()
+	config.SparseSpaces = map[string][]string{}
+
+	common.ControllerType = "unittest"
+	blueprint := []byte("sparse: unittest")
+	library := testLibrary(t, map[string]string{
+		"bin/tool":         "bin/tool",
+		"share/doc/readme": "share/doc/readme",
+		"share/data/table": "share/data/table",
+		"man/page":         "man/page",
+	})
+	must.Nil(library.Record(blueprint))
+
+	space, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("sparse"))
+	must.Nil(err)
+	_, err = os.Stat(filepath.Join(space, "share", "doc", "readme"))
+	must.Nil(err)
+
+	config.SparseSpaces["sparse"] = []string{"share/doc", "man", "missing/dir", "../outside"}
+	again, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("sparse"))
+	must.Nil(err)
+	must.Equal(space, again)
+	for _, name := range []string{"share/doc", "man"} {
+		_, err = os.Stat(filepath.Join(space, filepath.FromSlash(name)))
+		wont.Nil(err)
+	}
+	for _, name := range []string{"bin/tool", "share/data/table"} {
+		_, err = os.Stat(filepath.Join(space, filepath.FromSlash(name)))
+		must.Nil(err)
+	}
+
+	found := false
+	for _, root := range htfs.Spaces() {
+		if root.Path == space {
+			found = true
+			must.Equal([]string{"man", "missing/dir", "share/doc"}, root.Sparse)
+			_, ok := root.Tree.Dirs["man"]
+			wont.True(ok)
+		}
+	}
+	must.True(found)
+}
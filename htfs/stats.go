@@ -0,0 +1,84 @@
+package htfs
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+const (
+	largestFiles = 20
+)
+
+type FileStat struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// CatalogStats summarizes one catalog. Total is size of files when restored,
+// and compressed is size of blobs in hololib (own library, or system library
+// below it), each counted once.
+type CatalogStats struct {
+	Blueprint   string      `json:"blueprint"`
+	Platform    string      `json:"platform"`
+	Dirs        int         `json:"dirs"`
+	Files       int         `json:"files"`
+	Links       int         `json:"links"`
+	Executables int         `json:"executables"`
+	Blobs       int         `json:"blobs"`
+	Missing     int         `json:"missing"`
+	Total       int64       `json:"total"`
+	Compressed  int64       `json:"compressed"`
+	Largest     []*FileStat `json:"largest"`
+}
+
+func (it *CatalogStats) visit(path string, dir *Dir, digests map[string]bool) {
+	it.Dirs += 1
+	it.Links += len(dir.Links)
+	for name, file := range dir.Files {
+		it.Files += 1
+		it.Total += file.Size
+		if file.IsExecutable() {
+			it.Executables += 1
+		}
+		digests[file.Digest] = true
+		it.Largest = append(it.Largest, &FileStat{filepath.Join(path, name), file.Size})
+	}
+	for name, subdir := range dir.Dirs {
+		it.visit(filepath.Join(path, name), subdir, digests)
+	}
+}
+
+// Stats walks catalog tree and measures its blobs from hololib.
+func (it *Root) Stats() *CatalogStats {
+	stats := &CatalogStats{
+		Blueprint: it.Blueprint,
+		Platform:  it.Platform,
+		Largest:   []*FileStat{},
+	}
+	digests := make(map[string]bool)
+	stats.visit("", it.Tree, digests)
+	store, err := hololibStore()
+	for digest, _ := range digests {
+		if len(digest) < 6 || err != nil {
+			stats.Missing += 1
+			continue
+		}
+		size, ok := storedSize(store, digest)
+		if !ok {
+			stats.Missing += 1
+			continue
+		}
+		stats.Blobs += 1
+		stats.Compressed += size
+	}
+	sort.SliceStable(stats.Largest, func(left, right int) bool {
+		if stats.Largest[left].Size == stats.Largest[right].Size {
+			return stats.Largest[left].Path < stats.Largest[right].Path
+		}
+		return stats.Largest[left].Size > stats.Largest[right].Size
+	})
+	if len(stats.Largest) > largestFiles {
+		stats.Largest = stats.Largest[:largestFiles]
+	}
+	return stats
+}
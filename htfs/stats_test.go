@@ -0,0 +1,47 @@
+package htfs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestCanReportCatalogStats(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	library := testLibrary(t, map[string]string{
+		"big.txt":   strings.Repeat("big content ", 1000),
+		"small.txt": "small",
+	})
+	bin := filepath.Join(library.Stage(), "bin")
+	must.Nil(os.MkdirAll(bin, 0o755))
+	must.Nil(ioutil.WriteFile(filepath.Join(bin, "tool"), []byte("#!/bin/sh\necho tool\n"), 0o755))
+	must.Nil(library.Record([]byte("stats: unittest")))
+
+	catalogs := htfs.Catalogs()
+	must.Equal(1, len(catalogs))
+	root, err := htfs.NewRoot(common.HolotreeLocation())
+	must.Nil(err)
+	must.Nil(root.LoadFrom(filepath.Join(common.HololibCatalogLocation(), catalogs[0])))
+
+	stats := root.Stats()
+	must.Equal(3, stats.Files)
+	must.Equal(2, stats.Dirs)
+	must.Equal(1, stats.Executables)
+	must.Equal(3, stats.Blobs)
+	must.Equal(0, stats.Missing)
+	must.Equal(int64(12000+5+20), stats.Total)
+	must.True(stats.Compressed > 0)
+	must.True(stats.Compressed < stats.Total)
+	must.Equal(3, len(stats.Largest))
+	must.Equal("big.txt", stats.Largest[0].Path)
+	must.Equal(int64(12000), stats.Largest[0].Size)
+	wont.True(root.Tree.Files["small.txt"].Executable)
+	must.True(root.Tree.Dirs["bin"].Files["tool"].Executable)
+}
@@ -0,0 +1,85 @@
+package htfs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestCanRestoreSymbolicLinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need privileges on windows")
+	}
+	must, wont := hamlet.Specifications(t)
+
+	outside := filepath.Join(t.TempDir(), "outside.txt")
+	must.Nil(ioutil.WriteFile(outside, []byte("outside of tree"), 0o644))
+
+	common.ControllerType = "unittest"
+	blueprint := []byte("symlink: unittest")
+	library := testLibrary(t, map[string]string{"lib/libfoo.so.1.2": "shared library"})
+	lib := filepath.Join(library.Stage(), "lib")
+	must.Nil(os.Symlink("libfoo.so.1.2", filepath.Join(lib, "libfoo.so.1")))
+	must.Nil(os.Symlink(filepath.Join(lib, "libfoo.so.1"), filepath.Join(lib, "libfoo.so")))
+	must.Nil(os.Symlink("lib", filepath.Join(library.Stage(), "lib64")))
+	must.Nil(os.Symlink(outside, filepath.Join(lib, "outside.txt")))
+	must.Nil(library.Record(blueprint))
+
+	space, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("links"))
+	must.Nil(err)
+
+	target, err := os.Readlink(filepath.Join(space, "lib", "libfoo.so.1"))
+	must.Nil(err)
+	must.Equal("libfoo.so.1.2", target)
+	target, err = os.Readlink(filepath.Join(space, "lib", "libfoo.so"))
+	must.Nil(err)
+	must.Equal("libfoo.so.1", target)
+	target, err = os.Readlink(filepath.Join(space, "lib64"))
+	must.Nil(err)
+	must.Equal("lib", target)
+	content, err := ioutil.ReadFile(filepath.Join(space, "lib64", "libfoo.so"))
+	must.Nil(err)
+	must.Equal("shared library", string(content))
+
+	info, err := os.Lstat(filepath.Join(space, "lib", "outside.txt"))
+	must.Nil(err)
+	must.True(info.Mode().IsRegular())
+
+	must.Nil(os.Remove(filepath.Join(space, "lib", "libfoo.so.1")))
+	must.Nil(ioutil.WriteFile(filepath.Join(space, "lib", "libfoo.so.1"), []byte("not a link"), 0o644))
+	must.Nil(os.Remove(filepath.Join(space, "lib64")))
+
+	drift, err := htfs.CheckSpaceDrift("links")
+	must.Nil(err)
+	must.True(drift.Dirty())
+	must.Equal([]string{"lib64"}, drift.Added)
+	must.Equal([]string{filepath.Join("lib", "libfoo.so.1")}, drift.Replaced)
+
+	_, err = library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("links"))
+	must.Nil(err)
+	target, err = os.Readlink(filepath.Join(space, "lib", "libfoo.so.1"))
+	must.Nil(err)
+	must.Equal("libfoo.so.1.2", target)
+
+	drift, err = htfs.CheckSpaceDrift("links")
+	must.Nil(err)
+	wont.True(drift.Dirty())
+}
+
+func TestLinksPointingOutsideOfSpaceAreRefused(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	root := filepath.Join(t.TempDir(), "space")
+	lib := filepath.Join(root, "lib")
+	must.True((&htfs.Link{Name: "libfoo.so", Target: "libfoo.so.1"}).Inside(root, lib))
+	must.True((&htfs.Link{Name: "lib64", Target: "lib"}).Inside(root, root))
+	must.True((&htfs.Link{Name: "up", Target: "../bin/python"}).Inside(root, lib))
+	wont.True((&htfs.Link{Name: "escape", Target: "../../etc/passwd"}).Inside(root, lib))
+	wont.True((&htfs.Link{Name: "absolute", Target: filepath.Join(root, "lib", "libfoo.so")}).Inside(root, lib))
+}
@@ -0,0 +1,56 @@
+package htfs
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/settings"
+)
+
+// SystemHololib returns location of machine-wide, admin-managed hololib,
+// which is used read-only as fallback for catalogs and blobs (so that users
+// on same machine do not need their own copies). Empty means there is none.
+//
+// Spaces from system catalogs are restored into user's own holotree, so
+// system hololib (and holotree next to it) stays read-only for users. Since
+// holotree path is rewritten into files in place, system catalogs must be
+// recorded in holotree, which path is at least as long as users' holotrees.
+func SystemHololib() string {
+	location := os.Getenv(common.ROBOCORP_SYSTEM_HOLOLIB)
+	if len(location) == 0 {
+		location = settings.Global.SystemHololib()
+	}
+	if len(location) == 0 {
+		return ""
+	}
+	location = common.ExpandPath(location)
+	if location == common.HololibLocation() {
+		return ""
+	}
+	if !pathlib.IsDir(location) {
+		common.Debug("System hololib %q is not a directory, ignoring it.", location)
+		return ""
+	}
+	return location
+}
+
+func exactBlobLocation(system, digest string) string {
+	local := filepath.Join(common.HololibLibraryLocation(), digest[:2], digest[2:4], digest[4:6], digest)
+	if len(system) == 0 || pathlib.IsFile(local) {
+		return local
+	}
+	shared := filepath.Join(system, "library", digest[:2], digest[2:4], digest[4:6], digest)
+	if pathlib.IsFile(shared) {
+		return shared
+	}
+	return local
+}
+
+// ExactBlobLocation finds blob from own writable library first, and then
+// falls through to system hololib, so that own library works as per-user
+// overlay on top of read-only system library.
+func ExactBlobLocation(digest string) string {
+	return exactBlobLocation(SystemHololib(), digest)
+}
@@ -0,0 +1,99 @@
+package htfs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+func TestCanUseSystemHololibAsReadonlyFallback(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	defer os.Unsetenv(common.ROBOCORP_SYSTEM_HOLOLIB)
+
+	common.ControllerType = "unittest"
+	blueprint := []byte("system: unittest")
+	library := testLibrary(t, map[string]string{"shared.txt": "shared content"})
+	stage := library.Stage()
+	must.Nil(ioutil.WriteFile(filepath.Join(stage, "where.txt"), []byte("home="+stage+"/bin\n"), 0o644))
+	must.Nil(library.Record(blueprint))
+	admin := common.RobocorpHome()
+	user := t.TempDir()
+
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, user)
+	library, err := htfs.New()
+	must.Nil(err)
+	wont.True(library.HasBlueprint(blueprint))
+
+	os.Setenv(common.ROBOCORP_SYSTEM_HOLOLIB, filepath.Join(admin, "hololib"))
+	must.Equal(filepath.Join(admin, "hololib"), htfs.SystemHololib())
+	library, err = htfs.New()
+	must.Nil(err)
+	must.True(library.HasBlueprint(blueprint))
+
+	path, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("system-unittest"))
+	must.Nil(err)
+	must.Equal(filepath.Join(user, "holotree"), filepath.Dir(path))
+	must.Equal(htfs.ControllerSpaceName([]byte(common.ControllerIdentity()), []byte("system-unittest")), filepath.Base(path))
+	content, err := ioutil.ReadFile(filepath.Join(path, "shared.txt"))
+	must.Nil(err)
+	must.Equal("shared content", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(path, "where.txt"))
+	must.Nil(err)
+	must.Equal("home="+path+"/bin\n", string(content))
+	spaces, err := filepath.Glob(filepath.Join(admin, "holotree", "*.meta"))
+	must.Nil(err)
+	must.Equal(0, len(spaces))
+
+	blobs, err := filepath.Glob(filepath.Join(user, "hololib", "library", "*", "*", "*", "*"))
+	must.Nil(err)
+	must.Equal(0, len(blobs))
+
+	testStage(t, library, map[string]string{
+		"shared.txt": "shared content",
+		"own.txt":    "own content",
+	})
+	must.Nil(library.Record([]byte("system: overlay")))
+
+	blobs, err = filepath.Glob(filepath.Join(user, "hololib", "library", "*", "*", "*", "*"))
+	must.Nil(err)
+	must.Equal(1, len(blobs))
+	systemblobs, err := filepath.Glob(filepath.Join(admin, "hololib", "library", "*", "*", "*", "*"))
+	must.Nil(err)
+	must.Equal(2, len(systemblobs))
+	must.Equal(systemblobs[0], htfs.ExactBlobLocation(filepath.Base(systemblobs[0])))
+
+	shorter := filepath.Dir(t.TempDir())
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, shorter)
+	library, err = htfs.New()
+	must.Nil(err)
+	path, err = library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("system-unittest"))
+	must.Nil(err)
+	must.Equal(filepath.Join(shorter, "holotree"), filepath.Dir(path))
+	content, err = ioutil.ReadFile(filepath.Join(path, "where.txt"))
+	must.Nil(err)
+	padded := strings.TrimSuffix(strings.TrimPrefix(string(content), "home="), "/bin\n")
+	must.Equal(len(stage), len(padded))
+	must.Equal(filepath.Base(path), filepath.Base(padded))
+	must.True(pathlib.IsFile(filepath.Join(padded, "shared.txt")))
+
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, filepath.Join(user, "much", "longer", "home"))
+	library, err = htfs.New()
+	must.Nil(err)
+	_, err = library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("system-unittest"))
+	wont.Nil(err)
+
+	t.Setenv(common.ROBOCORP_HOME_VARIABLE, user)
+	report, err := htfs.RepairIntegrity("")
+	must.Nil(err)
+	must.Equal(0, len(report.Missing))
+	must.Equal(0, len(report.Purged))
+	must.Equal(1, len(htfs.Catalogs()))
+}
@@ -0,0 +1,95 @@
+package htfs
+
+import (
+	"sync/atomic"
+
+	"github.com/robocorp/rcc/common"
+)
+
+var (
+	counters telemetryCounters
+)
+
+type telemetryCounters struct {
+	catalogHits       uint64
+	catalogMisses     uint64
+	blobHits          uint64
+	blobsLifted       uint64
+	bytesLifted       uint64
+	bytesDecompressed uint64
+	restores          uint64
+	filesChecked      uint64
+	filesRepaired     uint64
+}
+
+// Telemetry tells how well hololib worked as environment cache in this
+// process. Catalog hits are environments found already built, and blob hits
+// are files which did not need lifting, since hololib had them already.
+// Repaired files are ones added or replaced when spaces were restored.
+type Telemetry struct {
+	CatalogHits       uint64  `json:"catalog-hits"`
+	CatalogMisses     uint64  `json:"catalog-misses"`
+	BlobHits          uint64  `json:"blob-hits"`
+	BlobsLifted       uint64  `json:"blobs-lifted"`
+	BytesLifted       uint64  `json:"bytes-lifted"`
+	BytesDecompressed uint64  `json:"bytes-decompressed"`
+	CacheHitRatio     float64 `json:"cache-hit-ratio"`
+	Restores          uint64  `json:"restores"`
+	FilesChecked      uint64  `json:"files-checked"`
+	FilesRepaired     uint64  `json:"files-repaired"`
+}
+
+// TelemetrySnapshot returns current values of holotree counters.
+func TelemetrySnapshot() *Telemetry {
+	result := &Telemetry{
+		CatalogHits:       atomic.LoadUint64(&counters.catalogHits),
+		CatalogMisses:     atomic.LoadUint64(&counters.catalogMisses),
+		BlobHits:          atomic.LoadUint64(&counters.blobHits),
+		BlobsLifted:       atomic.LoadUint64(&counters.blobsLifted),
+		BytesLifted:       atomic.LoadUint64(&counters.bytesLifted),
+		BytesDecompressed: atomic.LoadUint64(&counters.bytesDecompressed),
+		Restores:          atomic.LoadUint64(&counters.restores),
+		FilesChecked:      atomic.LoadUint64(&counters.filesChecked),
+		FilesRepaired:     atomic.LoadUint64(&counters.filesRepaired),
+	}
+	blobs := result.BlobHits + result.BlobsLifted
+	if blobs > 0 {
+		result.CacheHitRatio = float64(result.BlobHits) / float64(blobs)
+	}
+	return result
+}
+
+func countCatalog(hit bool) {
+	if hit {
+		atomic.AddUint64(&counters.catalogHits, 1)
+	} else {
+		atomic.AddUint64(&counters.catalogMisses, 1)
+	}
+}
+
+func countLifted(bytes int64) {
+	atomic.AddUint64(&counters.bytesLifted, uint64(bytes))
+}
+
+func countDecompressed(bytes int64) {
+	atomic.AddUint64(&counters.bytesDecompressed, uint64(bytes))
+}
+
+func countRecord(score *stats) {
+	atomic.AddUint64(&counters.blobHits, score.total-score.dirty)
+	atomic.AddUint64(&counters.blobsLifted, score.dirty)
+	telemetryTimeline()
+}
+
+func countRestore(score *stats) {
+	atomic.AddUint64(&counters.restores, 1)
+	atomic.AddUint64(&counters.filesChecked, score.total)
+	atomic.AddUint64(&counters.filesRepaired, score.dirty)
+	telemetryTimeline()
+}
+
+func telemetryTimeline() {
+	now := TelemetrySnapshot()
+	common.Timeline("holotree stats: catalogs %d/%d hit, blobs %d lifted (%d bytes) with hit ratio %.3f, %d bytes decompressed, %d/%d files repaired in %d restore(s)",
+		now.CatalogHits, now.CatalogHits+now.CatalogMisses, now.BlobsLifted, now.BytesLifted, now.CacheHitRatio, now.BytesDecompressed, now.FilesRepaired, now.FilesChecked, now.Restores)
+}
@@ -0,0 +1,48 @@
+package htfs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestTelemetryCountsLiftsAndRepairs(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	common.ControllerType = "unittest"
+	blueprint := []byte("telemetry: unittest")
+	library := testLibrary(t, map[string]string{
+		"first":  "first file",
+		"second": "second file",
+	})
+
+	before := htfs.TelemetrySnapshot()
+	must.Nil(library.Record(blueprint))
+	lifted := htfs.TelemetrySnapshot()
+	must.Equal(before.BlobsLifted+2, lifted.BlobsLifted)
+	must.Equal(before.BytesLifted+21, lifted.BytesLifted)
+
+	must.Nil(library.Record(blueprint))
+	again := htfs.TelemetrySnapshot()
+	must.Equal(lifted.BlobsLifted, again.BlobsLifted)
+	must.Equal(lifted.BlobHits+2, again.BlobHits)
+	must.True(again.CacheHitRatio > 0)
+
+	space, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("telemetry"))
+	must.Nil(err)
+	restored := htfs.TelemetrySnapshot()
+	must.Equal(again.Restores+1, restored.Restores)
+	must.Equal(again.FilesRepaired+2, restored.FilesRepaired)
+
+	must.Nil(os.Remove(filepath.Join(space, "second")))
+	_, err = library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("telemetry"))
+	must.Nil(err)
+	repaired := htfs.TelemetrySnapshot()
+	must.Equal(restored.Restores+1, repaired.Restores)
+	must.Equal(restored.FilesRepaired+1, repaired.FilesRepaired)
+	must.Equal(restored.FilesChecked+2, repaired.FilesChecked)
+}
@@ -0,0 +1,73 @@
+package htfs
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+)
+
+var (
+	diskThrottle = &throttle{}
+)
+
+// throttle is shared by all workers, so that their combined reads stay
+// under limit. Each read reserves its slot after previous ones, and waits
+// until that slot is due.
+type throttle struct {
+	sync.Mutex
+	next time.Time
+}
+
+type throttledReader struct {
+	source io.Reader
+	rate   float64
+}
+
+// IoLimit tells maximum MB/s lift and restore may read, where zero means
+// unlimited. Command line --io-limit overrides setting.
+func IoLimit() int {
+	if common.IoLimit > 0 {
+		return common.IoLimit
+	}
+	if limit := settings.Global.IoLimit(); limit > 0 {
+		return limit
+	}
+	return 0
+}
+
+func (it *throttle) wait(size int, rate float64) {
+	it.Lock()
+	now := time.Now()
+	if it.next.Before(now) {
+		it.next = now
+	}
+	delay := it.next.Sub(now)
+	it.next = it.next.Add(time.Duration(float64(size) / rate * float64(time.Second)))
+	it.Unlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+func (it *throttledReader) Read(target []byte) (int, error) {
+	size, err := it.source.Read(target)
+	if size > 0 {
+		diskThrottle.wait(size, it.rate)
+	}
+	return size, err
+}
+
+// throttled limits reading speed of source, when I/O limit is set.
+func throttled(source io.Reader) io.Reader {
+	limit := IoLimit()
+	if limit == 0 {
+		return source
+	}
+	return &throttledReader{
+		source: source,
+		rate:   float64(limit) * 1024 * 1024,
+	}
+}
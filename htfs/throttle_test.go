@@ -0,0 +1,46 @@
+package htfs_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/settings"
+)
+
+func TestIoLimitThrottlesLiftAndRestore(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	config := settings.Global.Holotree()
+	previous, flag := config.IoLimit, common.IoLimit
+	defer func() {
+		config.IoLimit, common.IoLimit = previous, flag
+	}()
+	config.IoLimit = 8
+	common.IoLimit = 0
+	must.Equal(8, htfs.IoLimit())
+	common.IoLimit = 1
+	must.Equal(1, htfs.IoLimit())
+
+	common.ControllerType = "unittest"
+	blueprint := []byte("throttle: unittest")
+	content := bytes.Repeat([]byte("throttled"), 40000)
+	library := testLibrary(t, map[string]string{"large.bin": string(content)})
+
+	started := time.Now()
+	must.Nil(library.Record(blueprint))
+	wont.True(time.Since(started) < 250*time.Millisecond)
+
+	started = time.Now()
+	space, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("throttle"))
+	must.Nil(err)
+	wont.True(time.Since(started) < 250*time.Millisecond)
+	restored, err := ioutil.ReadFile(filepath.Join(space, "large.bin"))
+	must.Nil(err)
+	must.Equal(len(content), len(restored))
+}
@@ -0,0 +1,173 @@
+package htfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+func relativeParts(directory string) []string {
+	cleaned := filepath.ToSlash(filepath.Clean(directory))
+	return strings.Split(strings.Trim(cleaned, "/"), "/")
+}
+
+func (it *Dir) lookup(parts []string) (*Dir, bool) {
+	current := it
+	for _, part := range parts {
+		next, ok := current.Dirs[part]
+		if !ok {
+			return nil, false
+		}
+		current = next
+	}
+	return current, true
+}
+
+// ensure creates missing directory entries along given path, taking their
+// modes from real directories under base.
+func (it *Dir) ensure(base string, parts []string) (*Dir, error) {
+	current := it
+	for at, part := range parts {
+		next, ok := current.Dirs[part]
+		if !ok {
+			stat, err := os.Stat(filepath.Join(base, filepath.Join(parts[:at+1]...)))
+			if err != nil {
+				return nil, err
+			}
+			next = newDir(part)
+			next.Mode = stat.Mode()
+			current.Dirs[part] = next
+		}
+		current = next
+	}
+	return current, nil
+}
+
+func graft(target, source *Dir) {
+	for name, subdir := range source.Dirs {
+		existing, ok := target.Dirs[name]
+		if ok {
+			graft(existing, subdir)
+		} else {
+			target.Dirs[name] = subdir
+		}
+	}
+	for name, file := range source.Files {
+		target.Files[name] = file
+	}
+	for name, link := range source.Links {
+		if target.Links == nil {
+			target.Links = make(map[string]*Link)
+		}
+		target.Links[name] = link
+	}
+}
+
+func graftCatalog(library *hololib, blueprint string, snapshot *Root) error {
+	target := library.localCatalogPath(blueprint)
+	locker, err := keyLock(filepath.Base(target))
+	if err != nil {
+		return err
+	}
+	defer locker.Release()
+	fs, err := NewRoot(library.Stage())
+	if err != nil {
+		return err
+	}
+	err = fs.LoadFrom(library.CatalogPath(blueprint))
+	if err != nil {
+		return err
+	}
+	graft(fs.Tree, snapshot.Tree)
+	return writeCatalog(fs, target)
+}
+
+// toolCacheEnvironment points tool cache variables of robot into stage, so
+// that post-build hooks can fill those caches, and they get recorded with
+// rest of environment. Without tool caches, it is nil (rcc's own environment).
+func toolCacheEnvironment(stage string, toolCaches map[string]string) []string {
+	if len(toolCaches) == 0 {
+		return nil
+	}
+	variables := make([]string, 0, len(toolCaches))
+	for variable, directory := range toolCaches {
+		variables = append(variables, fmt.Sprintf("%s=%s", variable, filepath.Join(stage, filepath.FromSlash(directory))))
+	}
+	sort.Strings(variables)
+	return append(os.Environ(), variables...)
+}
+
+// SnapshotToolCaches adds given directories (relative to space) into the
+// catalog of that space, and into its metadata, so that later restores bring
+// them back instead of tools downloading them again at runtime. Directories
+// already in catalog, or not existing in space, are skipped. This is for
+// warm-up runs only, and extended catalog replaces old one atomically, while
+// holding its lock (but only after blobs are lifted).
+func SnapshotToolCaches(space string, directories []string) (added []string, err error) {
+	defer fail.Around(&err)
+
+	tree, err := New()
+	fail.On(err != nil, "%s", err)
+	library, ok := tree.(*hololib)
+	fail.On(!ok, "Tool cache snapshot requires local hololib.")
+
+	metafile := fmt.Sprintf("%s.meta", space)
+	lockfile := fmt.Sprintf("%s.lck", space)
+	locker, err := pathlib.Locker(lockfile, 30000)
+	fail.On(err != nil, "Could not get lock for %s -> %v", space, err)
+	defer locker.Release()
+
+	shadow, err := NewRoot(space)
+	fail.On(err != nil, "%s", err)
+	err = shadow.LoadFrom(metafile)
+	fail.On(err != nil, "Space %q has no metadata -> %v", space, err)
+
+	catalog := library.CatalogPath(shadow.Blueprint)
+	fs, err := NewRoot(library.Stage())
+	fail.On(err != nil, "%s", err)
+	err = fs.LoadFrom(catalog)
+	fail.On(err != nil, "Failed to load catalog %s -> %v", catalog, err)
+
+	snapshot, err := NewRoot(space)
+	fail.On(err != nil, "%s", err)
+	added = []string{}
+	for _, directory := range directories {
+		parts := relativeParts(directory)
+		if !pathlib.IsDir(filepath.Join(space, filepath.Join(parts...))) {
+			common.Debug("Tool cache %q does not exist in %q, skipping it.", directory, space)
+			continue
+		}
+		if _, ok := fs.Tree.lookup(parts); ok {
+			continue
+		}
+		subtree, err := snapshot.Tree.ensure(space, parts)
+		fail.On(err != nil, "%s", err)
+		err = subtree.Lift(filepath.Join(space, filepath.Join(parts...)))
+		fail.On(err != nil, "Failed to lift tool cache %q -> %v", directory, err)
+		added = append(added, directory)
+	}
+	if len(added) == 0 {
+		return added, nil
+	}
+
+	// grafted files must use same digest algorithm as rest of catalog
+	err = snapshot.AllFiles(Locator(snapshot.Identity, fs.DigestAlgorithm()))
+	fail.On(err != nil, "%s", err)
+	err = snapshot.Treetop(ScheduleLifters(library, &stats{}))
+	fail.On(err != nil, "%s", err)
+
+	// blobs are lifted before taking catalog lock, since key locks must not
+	// be nested, and catalog is reloaded under lock to keep concurrent changes
+	err = graftCatalog(library, shadow.Blueprint, snapshot)
+	fail.On(err != nil, "%s", err)
+	graft(shadow.Tree, snapshot.Tree)
+	err = shadow.SaveAs(metafile)
+	fail.On(err != nil, "%s", err)
+	return added, nil
+}
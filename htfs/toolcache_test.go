@@ -0,0 +1,51 @@
+package htfs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestCanSnapshotToolCachesIntoCatalog(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	common.ControllerType = "unittest"
+	blueprint := []byte("toolcache: unittest")
+	library := testLibrary(t, map[string]string{"python.txt": "python"})
+	must.Nil(library.Record(blueprint))
+
+	first, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("first"))
+	must.Nil(err)
+	browsers := filepath.Join(first, "caches", "browsers")
+	must.Nil(os.MkdirAll(browsers, 0o755))
+	must.Nil(ioutil.WriteFile(filepath.Join(browsers, "chromium.bin"), []byte("chromium for "+first), 0o644))
+
+	added, err := htfs.SnapshotToolCaches(first, []string{"caches/browsers", "caches/missing"})
+	must.Nil(err)
+	must.Equal([]string{"caches/browsers"}, added)
+
+	added, err = htfs.SnapshotToolCaches(first, []string{"caches/browsers"})
+	must.Nil(err)
+	must.Equal(0, len(added))
+
+	drift, err := htfs.CheckSpaceDrift("first")
+	must.Nil(err)
+	wont.True(drift.Dirty())
+
+	second, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("second"))
+	must.Nil(err)
+	content, err := ioutil.ReadFile(filepath.Join(second, "caches", "browsers", "chromium.bin"))
+	must.Nil(err)
+	must.Equal("chromium for "+second, string(content))
+
+	_, err = library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("first"))
+	must.Nil(err)
+	content, err = ioutil.ReadFile(filepath.Join(browsers, "chromium.bin"))
+	must.Nil(err)
+	must.Equal("chromium for "+first, string(content))
+}
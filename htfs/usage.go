@@ -0,0 +1,242 @@
+package htfs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+)
+
+// UsageEntry tells how many bytes on disk are attributed to one catalog or
+// space. Unique bytes would be freed if entry was removed, shared bytes are
+// blobs also used by some other catalog.
+type UsageEntry struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Blueprint string `json:"blueprint,omitempty"`
+	Total     int64  `json:"total"`
+	Unique    int64  `json:"unique"`
+	Shared    int64  `json:"shared"`
+	Files     int    `json:"files"`
+}
+
+type UsageReport struct {
+	Catalogs []*UsageEntry `json:"catalogs"`
+	Spaces   []*UsageEntry `json:"spaces"`
+	Library  int64         `json:"library"`
+	Orphaned int64         `json:"orphaned"`
+	Holotree int64         `json:"holotree"`
+}
+
+func blobSizes(library string) (map[string]int64, error) {
+	result := make(map[string]int64)
+	err := filepath.Walk(library, func(fullpath string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			result[info.Name()] = info.Size()
+		}
+		return nil
+	})
+	return result, err
+}
+
+func directoryUsage(directory string) (size int64, files int, err error) {
+	err = filepath.Walk(directory, func(fullpath string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+			files += 1
+		}
+		return nil
+	})
+	return size, files, err
+}
+
+func catalogUsage(catalog string, root *Root, digests map[string]string, blobs map[string]int64, references map[string]int) *UsageEntry {
+	entry := &UsageEntry{
+		Name:      filepath.Base(catalog),
+		Path:      catalog,
+		Blueprint: root.Blueprint,
+		Files:     len(digests),
+	}
+	if info, err := os.Stat(catalog); err == nil {
+		entry.Unique += info.Size()
+	}
+	for digest, _ := range digests {
+		size := blobs[digest]
+		if references[digest] > 1 {
+			entry.Shared += size
+		} else {
+			entry.Unique += size
+		}
+	}
+	entry.Total = entry.Unique + entry.Shared
+	return entry
+}
+
+// DiskUsage attributes actual bytes on disk to catalogs and spaces. Blobs
+// are counted once, even when many catalogs refer to them, and spaces are
+// measured from their real files.
+func DiskUsage() (report *UsageReport, err error) {
+	defer fail.Around(&err)
+
+	blobs, err := blobSizes(common.HololibLibraryLocation())
+	fail.On(err != nil, "Could not measure hololib library -> %v", err)
+
+	catalogs, roots := LoadCatalogs()
+	digests := make([]map[string]string, len(roots))
+	references := make(map[string]int)
+	for at, root := range roots {
+		digests[at] = make(map[string]string)
+		err = root.Treetop(DigestMapper(digests[at]))
+		fail.On(err != nil, "Could not read catalog %q -> %v", catalogs[at], err)
+		for digest, _ := range digests[at] {
+			references[digest] += 1
+		}
+	}
+
+	report = &UsageReport{
+		Catalogs: make([]*UsageEntry, 0, len(roots)),
+		Spaces:   make([]*UsageEntry, 0, 20),
+	}
+	for digest, size := range blobs {
+		report.Library += size
+		if references[digest] == 0 {
+			report.Orphaned += size
+		}
+	}
+	for at, root := range roots {
+		report.Catalogs = append(report.Catalogs, catalogUsage(catalogs[at], root, digests[at], blobs, references))
+	}
+	for _, space := range Spaces() {
+		size, files, err := directoryUsage(space.Path)
+		fail.On(err != nil, "Could not measure space %q -> %v", space.Path, err)
+		entry := &UsageEntry{
+			Name:      space.Space,
+			Path:      space.Path,
+			Blueprint: space.Blueprint,
+			Total:     size,
+			Unique:    size,
+			Files:     files,
+		}
+		report.Holotree += size
+		report.Spaces = append(report.Spaces, entry)
+	}
+	sortUsage(report.Catalogs)
+	sortUsage(report.Spaces)
+	return report, nil
+}
+
+func sortUsage(entries []*UsageEntry) {
+	sort.SliceStable(entries, func(left, right int) bool {
+		if entries[left].Total == entries[right].Total {
+			return entries[left].Path < entries[right].Path
+		}
+		return entries[left].Total > entries[right].Total
+	})
+}
+
+// SpaceSize is size of one space as recorded in its metadata (catalog it was
+// restored from), so computing it does not walk space on disk.
+type SpaceSize struct {
+	Identity   string `json:"id"`
+	Controller string `json:"controller"`
+	Space      string `json:"space"`
+	Blueprint  string `json:"blueprint"`
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	Files      int    `json:"files"`
+}
+
+type ControllerSize struct {
+	Controller string `json:"controller"`
+	Spaces     int    `json:"spaces"`
+	Size       int64  `json:"size"`
+	Files      int    `json:"files"`
+}
+
+type SpaceSizes struct {
+	Spaces      []*SpaceSize      `json:"spaces"`
+	Controllers []*ControllerSize `json:"controllers"`
+	Total       int64             `json:"total"`
+}
+
+func treeSize(dir *Dir) (size int64, files int) {
+	for _, file := range dir.Files {
+		size += file.Size
+		files += 1
+	}
+	for _, subdir := range dir.Dirs {
+		subsize, subfiles := treeSize(subdir)
+		size += subsize
+		files += subfiles
+	}
+	return size, files
+}
+
+// SpaceSizesFromMetadata accounts sizes of spaces, and totals of them per
+// controller, from space metadata files. Sizes are logical, so spaces that
+// share hardlinked files are counted fully for each space.
+func SpaceSizesFromMetadata() *SpaceSizes {
+	return FilteredSpaceSizes(nil)
+}
+
+// FilteredSpaceSizes is like SpaceSizesFromMetadata, but only for spaces
+// selected by filter.
+func FilteredSpaceSizes(filter *SpaceFilter) *SpaceSizes {
+	report := &SpaceSizes{
+		Spaces:      make([]*SpaceSize, 0, 20),
+		Controllers: make([]*ControllerSize, 0, 5),
+	}
+	controllers := make(map[string]*ControllerSize)
+	for _, space := range Spaces() {
+		if !filter.Match(filepath.Base(space.Path), space.Controller, space.Space, lastUsed(space.Path+".meta")) {
+			continue
+		}
+		size, files := treeSize(space.Tree)
+		report.Spaces = append(report.Spaces, &SpaceSize{
+			Identity:   space.Identity,
+			Controller: space.Controller,
+			Space:      space.Space,
+			Blueprint:  space.Blueprint,
+			Path:       space.Path,
+			Size:       size,
+			Files:      files,
+		})
+		controller, ok := controllers[space.Controller]
+		if !ok {
+			controller = &ControllerSize{Controller: space.Controller}
+			controllers[space.Controller] = controller
+			report.Controllers = append(report.Controllers, controller)
+		}
+		controller.Spaces += 1
+		controller.Size += size
+		controller.Files += files
+		report.Total += size
+	}
+	sort.SliceStable(report.Spaces, func(left, right int) bool {
+		if report.Spaces[left].Size == report.Spaces[right].Size {
+			return report.Spaces[left].Path < report.Spaces[right].Path
+		}
+		return report.Spaces[left].Size > report.Spaces[right].Size
+	})
+	sort.SliceStable(report.Controllers, func(left, right int) bool {
+		if report.Controllers[left].Size == report.Controllers[right].Size {
+			return report.Controllers[left].Controller < report.Controllers[right].Controller
+		}
+		return report.Controllers[left].Size > report.Controllers[right].Size
+	})
+	return report
+}
@@ -0,0 +1,66 @@
+package htfs_test
+
+import (
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+)
+
+func TestCanAttributeDiskUsageToCatalogs(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	library := testLibrary(t, nil)
+	for _, name := range []string{"first", "second"} {
+		testStage(t, library, map[string]string{
+			"shared.txt": "shared by all catalogs",
+			"unique.txt": "unique to " + name,
+		})
+		must.Nil(library.Record([]byte("usage: " + name)))
+	}
+
+	report, err := htfs.DiskUsage()
+	must.Nil(err)
+	wont.Nil(report)
+	must.Equal(2, len(report.Catalogs))
+	must.Equal(int64(0), report.Orphaned)
+	wont.Equal(int64(0), report.Library)
+	shared := report.Catalogs[0].Shared
+	wont.Equal(int64(0), shared)
+	must.Equal(shared, report.Catalogs[1].Shared)
+	for _, catalog := range report.Catalogs {
+		must.Equal(2, catalog.Files)
+		must.True(catalog.Unique > 0)
+		must.Equal(catalog.Total, catalog.Unique+catalog.Shared)
+	}
+	must.True(report.Library < report.Catalogs[0].Total+report.Catalogs[1].Total)
+}
+
+func TestCanAccountSpaceSizesPerController(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	blueprint := []byte("sizes: unittest")
+	library := testLibrary(t, map[string]string{
+		"first.txt":  "12345",
+		"second.txt": "1234567890",
+	})
+	must.Nil(library.Record(blueprint))
+	for _, pair := range [][2]string{{"alpha", "one"}, {"alpha", "two"}, {"beta", "one"}} {
+		_, err := library.Restore(blueprint, []byte(pair[0]), []byte(pair[1]))
+		must.Nil(err)
+	}
+
+	report := htfs.SpaceSizesFromMetadata()
+	must.Equal(3, len(report.Spaces))
+	must.Equal(2, len(report.Controllers))
+	must.Equal(int64(45), report.Total)
+	for _, space := range report.Spaces {
+		must.Equal(2, space.Files)
+		must.Equal(int64(15), space.Size)
+	}
+	must.Equal("alpha", report.Controllers[0].Controller)
+	must.Equal(2, report.Controllers[0].Spaces)
+	must.Equal(int64(30), report.Controllers[0].Size)
+	must.Equal("beta", report.Controllers[1].Controller)
+	must.Equal(1, report.Controllers[1].Spaces)
+}
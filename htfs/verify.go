@@ -0,0 +1,66 @@
+package htfs
+
+import (
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/robocorp/rcc/settings"
+)
+
+// VerifyBlobs tells if content of every blob read from library is checked
+// against its digest (opt-in, since it costs one more hashing per file).
+func VerifyBlobs() bool {
+	return settings.Global.VerifyBlobs()
+}
+
+// verifyingReader hashes content while it is read, and instead of plain EOF
+// returns error, if content did not match expected digest.
+// Content is hashed with all digest algorithms active in this process, since
+// blob itself does not tell which one was used.
+type verifyingReader struct {
+	source   io.Reader
+	digests  []hash.Hash
+	expected string
+}
+
+func verifiedReader(source io.Reader, expected string) io.Reader {
+	if !VerifyBlobs() {
+		return source
+	}
+	digests := []hash.Hash{}
+	for _, algorithm := range activeAlgorithms() {
+		digests = append(digests, newDigest(algorithm))
+	}
+	return &verifyingReader{
+		source:   source,
+		digests:  digests,
+		expected: expected,
+	}
+}
+
+func (it *verifyingReader) Read(target []byte) (int, error) {
+	count, err := it.source.Read(target)
+	for _, digest := range it.digests {
+		digest.Write(target[:count])
+	}
+	if err == io.EOF {
+		actual := ""
+		for _, digest := range it.digests {
+			actual = fmt.Sprintf("%02x", digest.Sum(nil))
+			if actual == it.expected {
+				return count, err
+			}
+		}
+		return count, fmt.Errorf("Blob %q is corrupted, its content has digest %q.", it.expected, actual)
+	}
+	return count, err
+}
+
+func verifiedFile(filename, expected string) bool {
+	if !VerifyBlobs() {
+		return true
+	}
+	actual, err := matchingDigest(filename, expected, false)
+	return err == nil && actual == expected
+}
@@ -0,0 +1,46 @@
+package htfs_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/settings"
+)
+
+func TestCanVerifyBlobsOnRestore(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	config := settings.Global.Holotree()
+	verify := config.VerifyBlobs
+	defer func() {
+		config.VerifyBlobs = verify
+	}()
+
+	common.ControllerType = "unittest"
+	blueprint := []byte("verify: unittest")
+	library := testLibrary(t, map[string]string{"content.txt": "original content"})
+	must.Nil(library.Record(blueprint))
+
+	config.VerifyBlobs = true
+	must.True(htfs.VerifyBlobs())
+	_, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("verified"))
+	must.Nil(err)
+
+	for digest, _ := range htfs.LoadHololibHashes() {
+		must.Nil(ioutil.WriteFile(library.ExactLocation(digest), []byte("silently corrupted"), 0o644))
+	}
+
+	_, err = library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("poisoned"))
+	wont.Nil(err)
+
+	config.VerifyBlobs = false
+	space, err := library.Restore(blueprint, []byte(common.ControllerIdentity()), []byte("unverified"))
+	must.Nil(err)
+	content, err := ioutil.ReadFile(filepath.Join(space, "content.txt"))
+	must.Nil(err)
+	must.Equal("silently corrupted", string(content))
+}
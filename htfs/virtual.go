@@ -38,7 +38,7 @@ func (it *virtual) Stage() string {
 	return stage
 }
 
-func (it *virtual) Export([]string, string) error {
+func (it *virtual) Export([]string, []string, string) error {
 	return fmt.Errorf("Not supported yet on virtual holotree.")
 }
 
@@ -49,10 +49,13 @@ func (it *virtual) Record(blueprint []byte) (err error) {
 	common.Timeline("holotree record start %s (virtual)", key)
 	fs, err := NewRoot(it.Stage())
 	fail.On(err != nil, "Failed to create stage root: %v", err)
-	err = fs.Lift()
+	exclusions := RecordingExclusions(fs.Path)
+	err = fs.LiftExcluding(exclusions)
 	fail.On(err != nil, "Failed to lift structure out of stage: %v", err)
+	fs.Excludes = exclusions.Patterns()
 	common.Timeline("holotree (re)locator start (virtual)")
-	err = fs.AllFiles(Locator(it.Identity()))
+	fs.Algorithm = DigestAlgorithm()
+	err = fs.AllFiles(Locator(it.Identity(), fs.Algorithm))
 	fail.On(err != nil, "Failed to apply relocate to stage: %v", err)
 	common.Timeline("holotree (re)locator done (virtual)")
 	it.registry = make(map[string]string)
@@ -105,6 +108,7 @@ func (it *virtual) Restore(blueprint, client, tag []byte) (string, error) {
 	common.Timeline("holotree restore done (virtual)")
 	defer common.Timeline("- dirty %d/%d", score.dirty, score.total)
 	common.Debug("Holotree dirty workload: %d/%d\n", score.dirty, score.total)
+	countRestore(score)
 	fs.Controller = string(client)
 	fs.Space = string(tag)
 	err = fs.SaveAs(metafile)
@@ -122,6 +126,10 @@ func (it *virtual) ExactLocation(key string) string {
 	return it.registry[key]
 }
 
+func (it *virtual) HasBlob(key string) bool {
+	return pathlib.IsFile(it.registry[key])
+}
+
 func (it *virtual) Location(key string) string {
 	panic("Location is not supported on virtual holotree.")
 }
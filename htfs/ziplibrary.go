@@ -2,9 +2,9 @@ package htfs
 
 import (
 	"archive/zip"
-	"compress/gzip"
 	"fmt"
 	"io"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -29,7 +29,7 @@ func ZipLibrary(zipfile string) (Library, error) {
 	}
 	lookup := make(map[string]*zip.File)
 	for _, entry := range content.File {
-		lookup[entry.Name] = entry
+		lookup[zipName(entry.Name)] = entry
 	}
 	identity := strings.ToLower(fmt.Sprintf("%s %s", runtime.GOOS, runtime.GOARCH))
 	return &ziplibrary{
@@ -46,7 +46,7 @@ func (it *ziplibrary) HasBlueprint(blueprint []byte) bool {
 }
 
 func (it *ziplibrary) openFile(filename string) (readable io.Reader, closer Closer, err error) {
-	content, ok := it.lookup[filename]
+	content, ok := it.lookup[zipName(filename)]
 	if !ok {
 		return nil, nil, fmt.Errorf("Missing file: %q", filename)
 	}
@@ -54,8 +54,9 @@ func (it *ziplibrary) openFile(filename string) (readable io.Reader, closer Clos
 	if err != nil {
 		return nil, nil, err
 	}
-	wrapper, err := gzip.NewReader(file)
+	wrapper, _, err := decompressingReader(file)
 	if err != nil {
+		file.Close()
 		return nil, nil, err
 	}
 	closer = func() error {
@@ -66,12 +67,39 @@ func (it *ziplibrary) openFile(filename string) (readable io.Reader, closer Clos
 }
 
 func (it *ziplibrary) Open(digest string) (readable io.Reader, closer Closer, err error) {
-	filename := filepath.Join("library", digest[:2], digest[2:4], digest[4:6], digest)
-	return it.openFile(filename)
+	readable, closer, err = it.openFile(blobName(digest))
+	if err != nil {
+		return nil, nil, err
+	}
+	return verifiedReader(readable, digest), closer, nil
 }
 
 func (it *ziplibrary) CatalogPath(key string) string {
-	return filepath.Join("catalog", fmt.Sprintf("%s.%s", key, common.Platform()))
+	return catalogName(fmt.Sprintf("%s.%s", key, common.Platform()))
+}
+
+// verified refuses, when signing is required, catalogs which do not have
+// signature entry made by trusted key next to them in zip.
+func (it *ziplibrary) verified(catalog string) error {
+	if !SigningRequired() {
+		return nil
+	}
+	content, ok := it.lookup[catalog]
+	if !ok {
+		return fmt.Errorf("Missing file: %q", catalog)
+	}
+	raw, err := readEntry(content)
+	if err != nil {
+		return err
+	}
+	signature := []byte{}
+	if entry, ok := it.lookup[SignatureFile(catalog)]; ok {
+		signature, err = readEntry(entry)
+		if err != nil {
+			return err
+		}
+	}
+	return verifiedSignature(path.Base(catalog), raw, signature)
 }
 
 func (it *ziplibrary) Restore(blueprint, client, tag []byte) (result string, err error) {
@@ -83,11 +111,15 @@ func (it *ziplibrary) Restore(blueprint, client, tag []byte) (result string, err
 	fs, err := NewRoot(".")
 	fail.On(err != nil, "Failed to create root -> %v", err)
 	catalog := it.CatalogPath(key)
+	err = it.verified(catalog)
+	fail.On(err != nil, "%w", err)
 	reader, closer, err := it.openFile(catalog)
 	fail.On(err != nil, "Failed to open catalog %q -> %v", catalog, err)
 	defer closer()
 	err = fs.ReadFrom(reader)
 	fail.On(err != nil, "Failed to read catalog %q -> %v", catalog, err)
+	err = fs.CompatiblePlatform()
+	fail.On(err != nil, "Refusing to restore catalog %q -> %v", catalog, err)
 	metafile := filepath.Join(fs.HolotreeBase(), fmt.Sprintf("%s.meta", name))
 	targetdir := filepath.Join(fs.HolotreeBase(), name)
 	lockfile := filepath.Join(fs.HolotreeBase(), fmt.Sprintf("%s.lck", name))
@@ -105,6 +137,10 @@ func (it *ziplibrary) Restore(blueprint, client, tag []byte) (result string, err
 		shadow.Treetop(DigestRecorder(currentstate))
 		common.TimelineEnd()
 	}
+	err = ValidRelocations()
+	fail.On(err != nil, "Invalid relocations setting -> %v", err)
+	fs.Sparsify(SparseSkips(string(tag)))
+	resolveRestoreCollisions(fs)
 	err = fs.Relocate(targetdir)
 	fail.On(err != nil, "Failed to relocate %q -> %v", targetdir, err)
 	common.TimelineBegin("holotree make branches start (zip)")
@@ -118,6 +154,7 @@ func (it *ziplibrary) Restore(blueprint, client, tag []byte) (result string, err
 	common.TimelineEnd()
 	defer common.Timeline("- dirty %d/%d", score.dirty, score.total)
 	common.Debug("Holotree dirty workload: %d/%d\n", score.dirty, score.total)
+	countRestore(score)
 	fs.Controller = string(client)
 	fs.Space = string(tag)
 	err = fs.SaveAs(metafile)
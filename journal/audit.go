@@ -0,0 +1,70 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/robocorp/rcc/fail"
+)
+
+const (
+	AuditOk        = `ok`
+	AuditFailed    = `failed`
+	AuditCancelled = `cancelled`
+	AuditPanic     = `panic`
+)
+
+// Invocation is one audit log entry, telling who ran which rcc command,
+// where, how long it took, and how it ended.
+type Invocation struct {
+	Started    string   `json:"started"`
+	Seconds    float64  `json:"seconds"`
+	User       string   `json:"user"`
+	Host       string   `json:"host"`
+	Pid        int      `json:"pid"`
+	Controller string   `json:"controller"`
+	Version    string   `json:"version"`
+	Command    string   `json:"command"`
+	Args       []string `json:"args"`
+	Workdir    string   `json:"workdir"`
+	Space      string   `json:"space,omitempty"`
+	Exit       int      `json:"exit"`
+	Code       string   `json:"code,omitempty"`
+	Outcome    string   `json:"outcome"`
+}
+
+// Audit appends invocation into audit log in filename (JSON lines).
+func Audit(filename string, invocation *Invocation) (err error) {
+	defer fail.Around(&err)
+	blob, err := json.Marshal(invocation)
+	fail.On(err != nil, "Could not serialize audit entry: %v", err)
+	err = os.MkdirAll(filepath.Dir(filename), 0o750)
+	fail.On(err != nil, "Could not create directory for audit log %v -> %v", filename, err)
+	return appendJournal(filename, blob)
+}
+
+// AuditTrail reads all entries from audit log in filename. Lines which are
+// not valid entries are skipped.
+func AuditTrail(filename string) (result []*Invocation, err error) {
+	defer fail.Around(&err)
+	handle, err := os.Open(filename)
+	fail.On(err != nil, "Failed to open audit log %v -> %v", filename, err)
+	defer handle.Close()
+	source := bufio.NewReader(handle)
+	result = make([]*Invocation, 0, 100)
+	for {
+		line, err := source.ReadBytes('\n')
+		if err == io.EOF {
+			return result, nil
+		}
+		fail.On(err != nil, "Failed to read audit log %v -> %v", filename, err)
+		invocation := &Invocation{}
+		if json.Unmarshal(line, invocation) != nil {
+			continue
+		}
+		result = append(result, invocation)
+	}
+}
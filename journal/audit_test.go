@@ -0,0 +1,28 @@
+package journal_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/journal"
+)
+
+func TestAuditLogIsAppendedAndRead(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	filename := filepath.Join(t.TempDir(), "audit", "audit.log")
+	_, err := journal.AuditTrail(filename)
+	wont.Nil(err)
+
+	first := &journal.Invocation{Command: "rcc holotree vars", Args: []string{"holotree", "vars"}, Space: "unittest", Outcome: journal.AuditOk}
+	second := &journal.Invocation{Command: "rcc run", Exit: 38, Code: "RCC-CMD-CANCEL-001", Outcome: journal.AuditCancelled}
+	must.Nil(journal.Audit(filename, first))
+	must.Nil(journal.Audit(filename, second))
+
+	trail, err := journal.AuditTrail(filename)
+	must.Nil(err)
+	must.Equal(2, len(trail))
+	must.Equal(first, trail[0])
+	must.Equal(second, trail[1])
+}
@@ -40,18 +40,16 @@ func Post(event, detail, commentForm string, fields ...interface{}) (err error)
 	}
 	blob, err := json.Marshal(message)
 	fail.On(err != nil, "Could not serialize event: %v -> %v", event, err)
-	return appendJournal(blob)
+	return appendJournal(common.EventJournal(), blob)
 }
 
-func appendJournal(blob []byte) (err error) {
+func appendJournal(filename string, blob []byte) (err error) {
 	defer fail.Around(&err)
-	handle, err := os.OpenFile(common.EventJournal(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
-	fail.On(err != nil, "Failed to open event journal %v -> %v", common.EventJournal(), err)
+	handle, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	fail.On(err != nil, "Failed to open journal %v -> %v", filename, err)
 	defer handle.Close()
-	_, err = handle.Write(blob)
-	fail.On(err != nil, "Failed to write event journal %v -> %v", common.EventJournal(), err)
-	_, err = handle.Write([]byte{'\n'})
-	fail.On(err != nil, "Failed to write event journal %v -> %v", common.EventJournal(), err)
+	_, err = handle.Write(append(blob, '\n'))
+	fail.On(err != nil, "Failed to write journal %v -> %v", filename, err)
 	return handle.Sync()
 }
 
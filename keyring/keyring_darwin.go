@@ -0,0 +1,15 @@
+package keyring
+
+import (
+	"os/exec"
+)
+
+// Secret reads generic password from macOS keychain, stored for
+// example with "security add-generic-password -s <name> -a $USER -w".
+func Secret(service string) (string, error) {
+	output, err := exec.Command("security", "find-generic-password", "-s", service, "-w").Output()
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
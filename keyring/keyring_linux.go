@@ -0,0 +1,15 @@
+package keyring
+
+import (
+	"os/exec"
+)
+
+// Secret reads secret from freedesktop secret service (like GNOME
+// keyring), stored for example with "secret-tool store service <name>".
+func Secret(service string) (string, error) {
+	output, err := exec.Command("secret-tool", "lookup", "service", service).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
@@ -0,0 +1,70 @@
+package keyring
+
+import (
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	CRED_TYPE_GENERIC = 1
+)
+
+// https://docs.microsoft.com/en-us/windows/win32/api/wincred/ns-wincred-credentialw
+// https://docs.microsoft.com/en-us/windows/win32/api/wincred/nf-wincred-credreadw
+
+var (
+	advapi32 = windows.NewLazySystemDLL("advapi32.dll")
+	credRead = advapi32.NewProc("CredReadW")
+	credFree = advapi32.NewProc("CredFree")
+)
+
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// Secret reads generic credential from Windows Credential Manager,
+// stored for example with "cmdkey /generic:<name> /user:<user> /pass".
+func Secret(service string) (string, error) {
+	target, err := windows.UTF16PtrFromString(service)
+	if err != nil {
+		return "", err
+	}
+	var found *credential
+	ok, _, err := credRead.Call(uintptr(unsafe.Pointer(target)), CRED_TYPE_GENERIC, 0, uintptr(unsafe.Pointer(&found)))
+	if ok == 0 {
+		return "", err
+	}
+	defer credFree.Call(uintptr(unsafe.Pointer(found)))
+	size := found.CredentialBlobSize
+	if size == 0 {
+		return "", nil
+	}
+	blob := (*[1 << 20]byte)(unsafe.Pointer(found.CredentialBlob))[:size:size]
+	return decodeCredential(blob), nil
+}
+
+// decodeCredential decodes secrets stored with CredWrite (Credential Manager
+// and cmdkey), which are always UTF-16LE, so non-ASCII secrets survive.
+func decodeCredential(blob []byte) string {
+	if len(blob)%2 != 0 {
+		return string(blob)
+	}
+	wide := make([]uint16, 0, len(blob)/2)
+	for at := 0; at < len(blob); at += 2 {
+		wide = append(wide, uint16(blob[at])|uint16(blob[at+1])<<8)
+	}
+	return string(utf16.Decode(wide))
+}
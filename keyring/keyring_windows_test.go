@@ -0,0 +1,25 @@
+package keyring
+
+import (
+	"testing"
+	"unicode/utf16"
+
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestCanDecodeCredentialBlobs(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	encode := func(secret string) []byte {
+		blob := []byte{}
+		for _, wide := range utf16.Encode([]rune(secret)) {
+			blob = append(blob, byte(wide), byte(wide>>8))
+		}
+		return blob
+	}
+
+	must.Equal("plain", decodeCredential(encode("plain")))
+	must.Equal("Ωmega ščř", decodeCredential(encode("Ωmega ščř")))
+	must.Equal("秘密", decodeCredential(encode("秘密")))
+	must.Equal("odd", decodeCredential([]byte("odd")))
+}
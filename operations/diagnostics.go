@@ -17,6 +17,7 @@ import (
 	"github.com/robocorp/rcc/cloud"
 	"github.com/robocorp/rcc/common"
 	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/htfs"
 	"github.com/robocorp/rcc/pathlib"
 	"github.com/robocorp/rcc/pretty"
 	"github.com/robocorp/rcc/robot"
@@ -52,7 +53,39 @@ func RunDiagnostics() *common.DiagnosticStatus {
 	result.Details["rcc"] = common.Version
 	result.Details["stats"] = rccStatusLine()
 	result.Details["micromamba"] = conda.MicromambaVersion()
+	result.Details["micromamba-wanted"] = conda.MicromambaWanted()
+	result.Details["micromamba-executable"] = conda.BinMicromamba()
+	result.Details["micromamba-timeout"] = fmt.Sprintf("%s (0s is no timeout)", conda.MicromambaTimeout())
+	result.Details["micromamba-retries"] = fmt.Sprintf("%d", conda.MicromambaRetries())
+	result.Details["environment-profiles"] = strings.Join(conda.ProfileNames(), ", ")
+	result.Details["conda-channels"] = strings.Join(conda.ChannelNames(), ", ")
+	result.Details["mirror-channels"] = strings.Join(conda.MirrorChannelNames(), ", ")
+	result.Details["native-probe"] = conda.NativeProbeMode()
+	result.Details["package-policy"] = conda.PackagePolicyFile()
+	result.Details["pip-indexes"] = strings.Join(conda.PipIndexNames(), ", ")
+	result.Details["pip-require-hashes"] = fmt.Sprintf("%v", settings.Global.PipRequireHashes())
+	result.Details["pkgs-cache"] = conda.PackagesCache()
+	result.Details["resolver-fallback"] = conda.FallbackResolverName()
+	result.Details["solver"] = conda.SolverName("")
 	result.Details["ROBOCORP_HOME"] = common.RobocorpHome()
+	result.Details["ROBOCORP_WRITABLE_HOME"] = common.WritableHome()
+	result.Details["hololib-compression"] = htfs.BlobCodec()
+	result.Details["hololib-digest"] = htfs.DigestAlgorithm()
+	result.Details["hololib-blob-store"] = htfs.BlobStoreName()
+	result.Details["holotree-restore-mode"] = htfs.RestoreMode()
+	result.Details["hololib-encryption"] = fmt.Sprintf("%v", htfs.BlobEncryption())
+	result.Details["hololib-trusted-keys"] = fmt.Sprintf("%d", len(htfs.TrustedKeys()))
+	result.Details["hololib-verify-blobs"] = fmt.Sprintf("%v", htfs.VerifyBlobs())
+	result.Details["holotree-preserve-attributes"] = fmt.Sprintf("%v", htfs.PreserveAttributes())
+	result.Details["holotree-case-collisions"] = htfs.CollisionStrategy()
+	result.Details["hololib-chunk-threshold"] = fmt.Sprintf("%d bytes (0 disables chunking)", htfs.ChunkThreshold())
+	result.Details["holotree-io-limit"] = fmt.Sprintf("%d MB/s (0 is unlimited)", htfs.IoLimit())
+	result.Details["holotree-relocations"] = fmt.Sprintf("%d", len(settings.Global.Relocations()))
+	result.Details["holotree-sparse-spaces"] = fmt.Sprintf("%d", len(settings.Global.SparseSpaces()))
+	result.Details["holotree-exclude-patterns"] = strings.Join(htfs.RecordingExclusions("").Patterns(), ", ")
+	retention, keep := settings.Global.CatalogRetention()
+	result.Details["hololib-catalog-retention"] = fmt.Sprintf("%d days, keep last %d", retention, keep)
+	result.Details["ROBOCORP_SYSTEM_HOLOLIB"] = htfs.SystemHololib()
 	result.Details["ROBOCORP_OVERRIDE_SYSTEM_REQUIREMENTS"] = fmt.Sprintf("%v", common.OverrideSystemRequirements())
 	result.Details["RCC_VERBOSE_ENVIRONMENT_BUILDING"] = fmt.Sprintf("%v", common.VerboseEnvironmentBuilding())
 	result.Details["user-cache-dir"] = justText(os.UserCacheDir)
@@ -65,6 +98,7 @@ func RunDiagnostics() *common.DiagnosticStatus {
 	result.Details["installationId"] = xviper.TrackingIdentity()
 	result.Details["telemetry-enabled"] = fmt.Sprintf("%v", xviper.CanTrack())
 	result.Details["os"] = common.Platform()
+	result.Details["encoding"], _ = conda.ConsoleEncoding()
 	result.Details["cpus"] = fmt.Sprintf("%d", runtime.NumCPU())
 	result.Details["when"] = time.Now().Format(time.RFC3339 + " (MST)")
 
@@ -79,6 +113,7 @@ func RunDiagnostics() *common.DiagnosticStatus {
 	result.Checks = append(result.Checks, anyPathCheck("PLAYWRIGHT_BROWSERS_PATH"))
 	result.Checks = append(result.Checks, anyPathCheck("NODE_OPTIONS"))
 	result.Checks = append(result.Checks, anyPathCheck("NODE_PATH"))
+	result.Checks = append(result.Checks, encodingCheck())
 	if !common.OverrideSystemRequirements() {
 		result.Checks = append(result.Checks, longPathSupportCheck())
 	}
@@ -138,6 +173,25 @@ func anyPathCheck(key string) *common.DiagnosticCheck {
 	}
 }
 
+func encodingCheck() *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	encoding, utf8 := conda.ConsoleEncoding()
+	if utf8 || settings.Global.EnforceUTF8() {
+		return &common.DiagnosticCheck{
+			Type:    "OS",
+			Status:  statusOk,
+			Message: fmt.Sprintf("Encoding is %s, and robots will use UTF-8 [enforced: %v].", encoding, settings.Global.EnforceUTF8()),
+			Link:    supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:    "OS",
+		Status:  statusWarning,
+		Message: fmt.Sprintf("Encoding is %s, which is not UTF-8. Non-ASCII data may break robots. Consider setting enforce-utf8 in settings.", encoding),
+		Link:    supportGeneralUrl,
+	}
+}
+
 func robocorpHomeCheck() *common.DiagnosticCheck {
 	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
 	if !conda.ValidLocation(common.RobocorpHome()) {
@@ -0,0 +1,321 @@
+package operations
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/settings"
+)
+
+const (
+	gigabyte          = 1024 * 1024 * 1024
+	diskFailLimit     = 1 * gigabyte
+	diskWarningLimit  = 5 * gigabyte
+	endpointTimeout   = 15 * time.Second
+	fixLongpaths      = `Run "rcc configure longpaths --fix" (as administrator), or enable long paths in group policy.`
+	fixRepairHololib  = `Run "rcc holotree check --repair", which re-lifts broken blobs from spaces and purges unrepairable catalogs.`
+	fixUpdateMamba    = `Run "rcc configure micromamba --update" to download wanted micromamba now.`
+	fixReinstallMamba = `Run "rcc configure cleanup --micromamba", and then "rcc configure micromamba --update".`
+)
+
+var (
+	networkFixes = []struct {
+		markers []string
+		fix     string
+	}{
+		{[]string{"x509", "certificate"}, "TLS verification failed: add root certificate of your organization (or TLS inspecting proxy) into system trust store, or as last resort, set certificates/verify-ssl to false in settings.yaml."},
+		{[]string{"proxyconnect"}, "Connection to proxy failed: check HTTPS_PROXY, HTTP_PROXY, and NO_PROXY environment variables."},
+		{[]string{"no such host", "server misbehaving"}, "DNS lookup failed: check network connection and DNS, or use proxy with HTTPS_PROXY environment variable."},
+		{[]string{"timeout", "deadline exceeded"}, "Connection timed out: check that firewall allows HTTPS to this host, or use proxy with HTTPS_PROXY environment variable."},
+		{[]string{"connection refused", "connection reset"}, "Connection was refused or reset: check firewall and proxy settings."},
+	}
+)
+
+// NetworkFix suggests fix for error from HTTP request.
+func NetworkFix(err error) string {
+	text := strings.ToLower(err.Error())
+	for _, candidate := range networkFixes {
+		for _, marker := range candidate.markers {
+			if strings.Contains(text, marker) {
+				return candidate.fix
+			}
+		}
+	}
+	return "Check network connection, firewall, and proxy settings (HTTPS_PROXY environment variable)."
+}
+
+// DiskSpaceFix suggests fix for free space, or nothing when there is
+// enough space.
+func DiskSpaceFix(free uint64) (string, string) {
+	switch {
+	case free < diskFailLimit:
+		return statusFail, `Free disk space, or run "rcc configure cleanup --days 7" and "rcc holotree gc" to remove old environments and blobs.`
+	case free < diskWarningLimit:
+		return statusWarning, `Disk is getting full, consider "rcc configure cleanup --days 14" and "rcc holotree gc".`
+	}
+	return statusOk, ""
+}
+
+func existingParent(pathname string) string {
+	for !pathlib.Exists(pathname) {
+		parent := filepath.Dir(pathname)
+		if parent == pathname {
+			break
+		}
+		pathname = parent
+	}
+	return pathname
+}
+
+func diskSpaceCheck(label, pathname string) *common.DiagnosticCheck {
+	check := &common.DiagnosticCheck{
+		Type: "disk",
+		Link: settings.Global.DocsLink("troubleshooting"),
+	}
+	free, err := pathlib.FreeSpace(existingParent(pathname))
+	if err != nil {
+		check.Status = statusWarning
+		check.Message = fmt.Sprintf("Could not find free space of %s (%s): %v", label, pathname, err)
+		return check
+	}
+	check.Status, check.Fix = DiskSpaceFix(free)
+	check.Message = fmt.Sprintf("%s (%s) has %.1fG free space.", label, pathname, float64(free)/gigabyte)
+	return check
+}
+
+func doctorLongPathCheck() *common.DiagnosticCheck {
+	check := longPathSupportCheck()
+	if check.Status != statusOk {
+		check.Fix = fixLongpaths
+	}
+	return check
+}
+
+// doctorEndpoints are configured network endpoints rcc talks to.
+func doctorEndpoints() [][2]string {
+	result := [][2]string{}
+	seen := make(map[string]bool)
+	add := func(name, link string) {
+		if !strings.HasPrefix(link, "http") || seen[link] {
+			return
+		}
+		seen[link] = true
+		result = append(result, [2]string{name, link})
+	}
+	if endpoints := settings.Global.Endpoints(); endpoints != nil {
+		add("cloud-api", endpoints.CloudApi)
+		add("downloads", endpoints.Downloads)
+		add("conda", endpoints.Conda)
+		add("pypi", endpoints.Pypi)
+		add("telemetry", endpoints.Telemetry)
+	}
+	if index := settings.Global.PipIndexURL(); index != nil {
+		add("pip-index-url", index.URL)
+	}
+	for _, index := range settings.Global.PipExtraIndexURLs() {
+		add("pip-extra-index-url", index.URL)
+	}
+	for _, channel := range settings.Global.CondaChannels() {
+		add("conda-channel", channel.Channel)
+	}
+	return result
+}
+
+func endpointCheck(client *http.Client, name, link string) *common.DiagnosticCheck {
+	check := &common.DiagnosticCheck{
+		Type: "network",
+		Link: settings.Global.DocsLink("troubleshooting/firewall-and-proxies"),
+	}
+	request, err := http.NewRequest(http.MethodHead, link, nil)
+	if err != nil {
+		check.Status = statusFail
+		check.Message = fmt.Sprintf("%s endpoint %q is not valid URL: %v", name, link, err)
+		check.Fix = "Fix URL in settings.yaml (see rcc configure settings)."
+		return check
+	}
+	request.Header.Set("User-Agent", common.UserAgent())
+	via := "directly"
+	if proxy, err := http.ProxyFromEnvironment(request); err == nil && proxy != nil {
+		via = fmt.Sprintf("via proxy %s", proxy.Host)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		check.Status = statusFail
+		check.Message = fmt.Sprintf("%s endpoint %s is not reachable %s: %v", name, link, via, err)
+		check.Fix = NetworkFix(err)
+		return check
+	}
+	response.Body.Close()
+	if response.StatusCode >= 500 {
+		check.Status = statusWarning
+		check.Message = fmt.Sprintf("%s endpoint %s answered %s with status %d.", name, link, via, response.StatusCode)
+		check.Fix = "Service (or proxy) has problems, try again later, or check that proxy allows this host."
+		return check
+	}
+	check.Status = statusOk
+	check.Message = fmt.Sprintf("%s endpoint %s is reachable %s (status %d).", name, link, via, response.StatusCode)
+	return check
+}
+
+func micromambaCheck() *common.DiagnosticCheck {
+	check := &common.DiagnosticCheck{
+		Type: "micromamba",
+		Link: settings.Global.DocsLink("troubleshooting"),
+	}
+	binary := conda.BinMicromamba()
+	switch {
+	case !pathlib.IsFile(binary):
+		check.Status = statusWarning
+		check.Message = fmt.Sprintf("Micromamba is not installed yet (%s), it is downloaded on first environment build.", binary)
+		check.Fix = fixUpdateMamba
+	case !conda.HasMicroMamba():
+		check.Status = statusFail
+		check.Message = fmt.Sprintf("Micromamba %s does not work, or is too old: %s", binary, conda.MicromambaVersion())
+		check.Fix = fixReinstallMamba
+	case conda.MicromambaOutdated():
+		check.Status = statusWarning
+		check.Message = fmt.Sprintf("Installed micromamba is %s, but wanted version is %s.", conda.MicromambaVersion(), conda.MicromambaWanted())
+		check.Fix = fixUpdateMamba
+	default:
+		check.Status = statusOk
+		check.Message = fmt.Sprintf("Micromamba %s works (%s).", conda.MicromambaVersion(), binary)
+	}
+	return check
+}
+
+func hololibQuickCheck() *common.DiagnosticCheck {
+	check := &common.DiagnosticCheck{
+		Type: "hololib",
+		Link: settings.Global.DocsLink("troubleshooting"),
+	}
+	infos, err := htfs.CatalogInfos()
+	if err != nil {
+		check.Status = statusFail
+		check.Message = fmt.Sprintf("Some hololib catalogs cannot be read: %v", err)
+		check.Fix = fixRepairHololib
+		return check
+	}
+	total := int64(0)
+	for _, info := range infos {
+		total += info.Bytes
+	}
+	check.Status = statusOk
+	check.Message = fmt.Sprintf("Hololib has %d readable catalog(s), with %.1fM of files (use --deep to verify blobs).", len(infos), float64(total)/(1024*1024))
+	return check
+}
+
+func hololibDeepCheck() *common.DiagnosticCheck {
+	check := &common.DiagnosticCheck{
+		Type: "hololib",
+		Link: settings.Global.DocsLink("troubleshooting"),
+	}
+	report, err := htfs.InspectIntegrity()
+	if err != nil {
+		check.Status = statusFail
+		check.Message = fmt.Sprintf("Hololib integrity could not be checked: %v", err)
+		check.Fix = fixRepairHololib
+		return check
+	}
+	broken := len(report.Damaged) + len(report.Missing) + len(report.Unreadable)
+	switch {
+	case broken > 0:
+		check.Status = statusFail
+		check.Message = fmt.Sprintf("Hololib has %d damaged and %d missing blob(s), and %d unreadable catalog(s), affecting %d catalog(s).", len(report.Damaged), len(report.Missing), len(report.Unreadable), len(report.Affected))
+		check.Fix = fixRepairHololib
+	case len(report.Orphans) > 0:
+		check.Status = statusWarning
+		check.Message = fmt.Sprintf("Hololib blobs are intact, but %d blob(s) are not used by any catalog.", len(report.Orphans))
+		check.Fix = `Run "rcc holotree gc" to remove unused blobs.`
+	default:
+		check.Status = statusOk
+		check.Message = "Hololib blobs are intact."
+	}
+	return check
+}
+
+func lockCheck(name, lockfile string) *common.DiagnosticCheck {
+	check := &common.DiagnosticCheck{
+		Type: "locks",
+		Link: settings.Global.DocsLink("troubleshooting"),
+	}
+	if pathlib.LockHeld(lockfile) {
+		check.Status = statusWarning
+		check.Message = fmt.Sprintf("%s lock (%s) is held by other process right now, so commands needing it will wait.", name, lockfile)
+		check.Fix = "Let other rcc processes finish. Lock is released when process holding it exits, so if this persists, look for hanging rcc processes and stop them."
+		return check
+	}
+	check.Status = statusOk
+	check.Message = fmt.Sprintf("%s lock (%s) is free.", name, lockfile)
+	return check
+}
+
+// RunDoctor runs consolidated checks of things support tickets usually need,
+// and gives concrete fix for every problem found. Deep also verifies blobs
+// of hololib, which takes time.
+func RunDoctor(deep bool) *common.DiagnosticStatus {
+	result := &common.DiagnosticStatus{
+		Details: make(map[string]string),
+		Checks:  []*common.DiagnosticCheck{},
+	}
+	result.Details["rcc"] = common.Version
+	result.Details["os"] = common.Platform()
+	result.Details["ROBOCORP_HOME"] = common.RobocorpHome()
+	result.Details["micromamba"] = conda.BinMicromamba()
+	result.Details["verify-ssl"] = "true"
+	if transport := settings.Global.ConfiguredHttpTransport(); transport.TLSClientConfig != nil && transport.TLSClientConfig.InsecureSkipVerify {
+		result.Details["verify-ssl"] = "false"
+	}
+	for _, key := range []string{"HTTPS_PROXY", "HTTP_PROXY", "NO_PROXY"} {
+		result.Details[key] = os.Getenv(key)
+		if len(result.Details[key]) == 0 {
+			result.Details[key] = os.Getenv(strings.ToLower(key))
+		}
+	}
+
+	result.Checks = append(result.Checks, diskSpaceCheck("ROBOCORP_HOME", common.RobocorpHome()))
+	result.Checks = append(result.Checks, diskSpaceCheck("temp directory", os.TempDir()))
+	if !common.OverrideSystemRequirements() {
+		result.Checks = append(result.Checks, doctorLongPathCheck())
+	}
+	client := &http.Client{
+		Transport: settings.Global.ConfiguredHttpTransport(),
+		Timeout:   endpointTimeout,
+	}
+	for _, endpoint := range doctorEndpoints() {
+		result.Checks = append(result.Checks, endpointCheck(client, endpoint[0], endpoint[1]))
+	}
+	result.Checks = append(result.Checks, micromambaCheck())
+	if deep {
+		result.Checks = append(result.Checks, hololibDeepCheck())
+	} else {
+		result.Checks = append(result.Checks, hololibQuickCheck())
+	}
+	result.Checks = append(result.Checks, lockCheck("rcc", common.RobocorpLock()))
+	result.Checks = append(result.Checks, lockCheck("holotree", common.HolotreeLock()))
+	return result
+}
+
+// HumaneDoctor writes doctor report with fixes for human readers.
+func HumaneDoctor(sink io.Writer, details *common.DiagnosticStatus) {
+	humaneDiagnostics(sink, details)
+	fatal, fail, warning, _ := details.Counts()
+	if fatal+fail+warning == 0 {
+		fmt.Fprintln(sink, "\nNo problems found.")
+		return
+	}
+	fmt.Fprintln(sink, "\nFixes:")
+	for _, check := range details.Checks {
+		if len(check.Fix) > 0 {
+			fmt.Fprintf(sink, " - [%s] %s\n   -> %s\n", check.Status, check.Message, check.Fix)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package operations_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/operations"
+)
+
+func TestDoctorSuggestsNetworkFixes(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	fix := operations.NetworkFix(errors.New(`Head "https://conda.example.com": x509: certificate signed by unknown authority`))
+	must_be.True(strings.HasPrefix(fix, "TLS verification failed"))
+	fix = operations.NetworkFix(errors.New(`proxyconnect tcp: dial tcp 10.0.0.1:3128: connect: connection refused`))
+	must_be.True(strings.HasPrefix(fix, "Connection to proxy failed"))
+	fix = operations.NetworkFix(errors.New(`dial tcp: lookup pypi.example.com: no such host`))
+	must_be.True(strings.HasPrefix(fix, "DNS lookup failed"))
+	fix = operations.NetworkFix(errors.New(`context deadline exceeded (Client.Timeout exceeded while awaiting headers)`))
+	must_be.True(strings.HasPrefix(fix, "Connection timed out"))
+	fix = operations.NetworkFix(errors.New(`something else`))
+	must_be.True(strings.HasPrefix(fix, "Check network connection"))
+}
+
+func TestDoctorSuggestsDiskSpaceFixes(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	status, fix := operations.DiskSpaceFix(100 * 1024 * 1024)
+	must_be.Equal("fail", status)
+	must_be.True(strings.Contains(fix, "rcc configure cleanup"))
+	status, fix = operations.DiskSpaceFix(3 * 1024 * 1024 * 1024)
+	must_be.Equal("warning", status)
+	status, fix = operations.DiskSpaceFix(50 * 1024 * 1024 * 1024)
+	must_be.Equal("ok", status)
+	must_be.Equal("", fix)
+}
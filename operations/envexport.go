@@ -0,0 +1,115 @@
+package operations
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	ExportBash          = `bash`
+	ExportPowershell    = `powershell`
+	ExportCmd           = `cmd`
+	ExportDotenv        = `dotenv`
+	ExportGithubActions = `github-actions`
+)
+
+var (
+	ExportFormats  = []string{ExportBash, ExportPowershell, ExportCmd, ExportDotenv, ExportGithubActions}
+	identifierName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	dotenvPlain    = regexp.MustCompile(`^[A-Za-z0-9_./:,@+-]*$`)
+	dotenvEscapes  = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, `$`, `\$`)
+)
+
+type exporter func(key, value string) (string, bool)
+
+func bashExport(key, value string) (string, bool) {
+	if !identifierName.MatchString(key) {
+		return "", false
+	}
+	return fmt.Sprintf("export %s='%s'", key, strings.ReplaceAll(value, `'`, `'\''`)), true
+}
+
+func powershellExport(key, value string) (string, bool) {
+	if strings.ContainsAny(key, "{}`") {
+		return "", false
+	}
+	return fmt.Sprintf("${env:%s} = '%s'", key, strings.ReplaceAll(value, `'`, `''`)), true
+}
+
+func cmdExport(key, value string) (string, bool) {
+	if strings.ContainsAny(value, "\r\n") || strings.ContainsAny(key, `"%`) {
+		return "", false
+	}
+	return fmt.Sprintf(`set "%s=%s"`, key, strings.ReplaceAll(value, `%`, `%%`)), true
+}
+
+func dotenvExport(key, value string) (string, bool) {
+	if !identifierName.MatchString(key) {
+		return "", false
+	}
+	if dotenvPlain.MatchString(value) {
+		return fmt.Sprintf("%s=%s", key, value), true
+	}
+	return fmt.Sprintf(`%s="%s"`, key, dotenvEscapes.Replace(value)), true
+}
+
+func githubExport(key, value string) (string, bool) {
+	if strings.ContainsAny(key, "\r\n<") {
+		return "", false
+	}
+	if !strings.ContainsAny(value, "\r\n") {
+		return fmt.Sprintf("%s=%s", key, value), true
+	}
+	delimiter := "RCC_EOF"
+	for at := 1; strings.Contains(value, delimiter); at++ {
+		delimiter = fmt.Sprintf("RCC_EOF_%d", at)
+	}
+	return fmt.Sprintf("%s<<%s\n%s\n%s", key, delimiter, value, delimiter), true
+}
+
+func exporterFor(format string) (exporter, error) {
+	switch format {
+	case ExportBash:
+		return bashExport, nil
+	case ExportPowershell:
+		return powershellExport, nil
+	case ExportCmd:
+		return cmdExport, nil
+	case ExportDotenv:
+		return dotenvExport, nil
+	case ExportGithubActions:
+		return githubExport, nil
+	}
+	return nil, fmt.Errorf("Unknown export format %q, expected one of: %s", format, strings.Join(ExportFormats, ", "))
+}
+
+// ValidExportFormat fails when format is not one of ExportFormats.
+func ValidExportFormat(format string) error {
+	_, err := exporterFor(format)
+	return err
+}
+
+// ExportVariables formats KEY=value lines, so that shell (or CI system)
+// can consume them directly. Variables which cannot be expressed in format
+// are left out, and their names are returned as skipped.
+func ExportVariables(format string, items []string) (lines []string, skipped []string, err error) {
+	export, err := exporterFor(format)
+	if err != nil {
+		return nil, nil, err
+	}
+	lines, skipped = []string{}, []string{}
+	for _, item := range items {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			continue
+		}
+		line, ok := export(parts[0], parts[1])
+		if !ok {
+			skipped = append(skipped, parts[0])
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, skipped, nil
+}
@@ -0,0 +1,42 @@
+package operations_test
+
+import (
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/operations"
+)
+
+func TestCanExportVariablesInShellFormats(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	items := []string{"PLAIN=/opt/env/bin", "QUOTED=it's 50% \"done\" $HOME", "MULTI=one\ntwo", "BAD-NAME=x", "broken"}
+
+	wont.Nil(operations.ValidExportFormat("fish"))
+	_, _, err := operations.ExportVariables("fish", items)
+	wont.Nil(err)
+
+	lines, skipped, err := operations.ExportVariables(operations.ExportBash, items)
+	must.Nil(err)
+	must.Equal([]string{"export PLAIN='/opt/env/bin'", `export QUOTED='it'\''s 50% "done" $HOME'`, "export MULTI='one\ntwo'"}, lines)
+	must.Equal([]string{"BAD-NAME"}, skipped)
+
+	lines, skipped, err = operations.ExportVariables(operations.ExportPowershell, items)
+	must.Nil(err)
+	must.Equal(4, len(lines))
+	must.Equal(`${env:QUOTED} = 'it''s 50% "done" $HOME'`, lines[1])
+	must.Equal(0, len(skipped))
+
+	lines, skipped, err = operations.ExportVariables(operations.ExportCmd, items)
+	must.Nil(err)
+	must.Equal([]string{`set "PLAIN=/opt/env/bin"`, `set "QUOTED=it's 50%% "done" $HOME"`, `set "BAD-NAME=x"`}, lines)
+	must.Equal([]string{"MULTI"}, skipped)
+
+	lines, _, err = operations.ExportVariables(operations.ExportDotenv, items)
+	must.Nil(err)
+	must.Equal([]string{"PLAIN=/opt/env/bin", `QUOTED="it's 50% \"done\" \$HOME"`, `MULTI="one\ntwo"`}, lines)
+
+	lines, _, err = operations.ExportVariables(operations.ExportGithubActions, []string{"PLAIN=x", "MULTI=RCC_EOF\ntwo"})
+	must.Nil(err)
+	must.Equal([]string{"PLAIN=x", "MULTI<<RCC_EOF_1\nRCC_EOF\ntwo\nRCC_EOF_1"}, lines)
+}
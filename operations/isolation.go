@@ -0,0 +1,73 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	IsolationInherit = `inherit`
+	IsolationStrict  = `strict`
+	IsolationCustom  = `custom`
+)
+
+// CheckIsolation tells if isolation level and allowlist patterns make sense
+// together. Empty level means inherit.
+func CheckIsolation(level string, allowed []string) error {
+	switch level {
+	case "", IsolationInherit, IsolationStrict:
+		if len(allowed) > 0 {
+			return fmt.Errorf("Allowed variables need isolation level %q, not %q.", IsolationCustom, level)
+		}
+	case IsolationCustom:
+		for _, pattern := range allowed {
+			_, err := filepath.Match(isolationName(pattern), "")
+			if err != nil {
+				return fmt.Errorf("Bad variable pattern %q, reason: %v", pattern, err)
+			}
+		}
+	default:
+		return fmt.Errorf("Unknown isolation level %q, use %s, %s, or %s.", level, IsolationInherit, IsolationStrict, IsolationCustom)
+	}
+	return nil
+}
+
+func isolationAllows(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		match, err := filepath.Match(isolationName(pattern), isolationName(name))
+		if err == nil && match {
+			return true
+		}
+	}
+	return false
+}
+
+// ParentEnvironment selects variables from rcc's own environment, that task
+// process gets before variables rcc generates for it. With "inherit" level,
+// everything is passed, with "strict" level, only variables processes need
+// to work (like HOME or SystemRoot), and with "custom" level, those and
+// variables matching allowed patterns (like "AWS_*").
+func ParentEnvironment(level string, allowed []string) ([]string, error) {
+	err := CheckIsolation(level, allowed)
+	if err != nil {
+		return nil, err
+	}
+	environment := os.Environ()
+	if level == "" || level == IsolationInherit {
+		return environment, nil
+	}
+	patterns := append([]string{}, isolationBaseline...)
+	if level == IsolationCustom {
+		patterns = append(patterns, allowed...)
+	}
+	result := make([]string, 0, len(environment))
+	for _, entry := range environment {
+		name := strings.SplitN(entry, "=", 2)[0]
+		if len(name) > 0 && isolationAllows(patterns, name) {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
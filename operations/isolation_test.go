@@ -0,0 +1,53 @@
+package operations_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/operations"
+)
+
+func hasVariable(environment []string, name string) bool {
+	for _, entry := range environment {
+		if strings.HasPrefix(entry, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+func TestIsolationLevelsSelectParentVariables(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	os.Setenv("RCC_ISOLATION_SECRET", "hidden")
+	os.Setenv("RCC_ISOLATION_ALLOWED", "shown")
+	defer os.Unsetenv("RCC_ISOLATION_SECRET")
+	defer os.Unsetenv("RCC_ISOLATION_ALLOWED")
+
+	inherited, err := operations.ParentEnvironment("", nil)
+	must.Nil(err)
+	must.True(hasVariable(inherited, "RCC_ISOLATION_SECRET"))
+
+	strict, err := operations.ParentEnvironment(operations.IsolationStrict, nil)
+	must.Nil(err)
+	wont.True(hasVariable(strict, "RCC_ISOLATION_SECRET"))
+	wont.True(hasVariable(strict, "RCC_ISOLATION_ALLOWED"))
+	must.True(len(strict) < len(inherited))
+
+	custom, err := operations.ParentEnvironment(operations.IsolationCustom, []string{"RCC_ISOLATION_ALL*"})
+	must.Nil(err)
+	wont.True(hasVariable(custom, "RCC_ISOLATION_SECRET"))
+	must.True(hasVariable(custom, "RCC_ISOLATION_ALLOWED"))
+}
+
+func TestIsolationFlagsAreChecked(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	must.Nil(operations.CheckIsolation(operations.IsolationInherit, nil))
+	must.Nil(operations.CheckIsolation(operations.IsolationCustom, []string{"AWS_*"}))
+	wont.Nil(operations.CheckIsolation("paranoid", nil))
+	wont.Nil(operations.CheckIsolation(operations.IsolationStrict, []string{"AWS_*"}))
+	wont.Nil(operations.CheckIsolation(operations.IsolationCustom, []string{"[AWS"}))
+}
@@ -0,0 +1,12 @@
+//go:build darwin || linux || !windows
+// +build darwin linux !windows
+
+package operations
+
+var (
+	isolationBaseline = []string{"HOME", "USER", "LOGNAME", "SHELL", "TERM", "LANG", "LANGUAGE", "LC_*", "TZ", "TMPDIR", "DISPLAY"}
+)
+
+func isolationName(name string) string {
+	return name
+}
@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package operations
+
+import (
+	"strings"
+)
+
+var (
+	isolationBaseline = []string{"SYSTEMROOT", "SYSTEMDRIVE", "WINDIR", "COMSPEC", "PATHEXT", "OS", "COMPUTERNAME", "USERNAME", "USERDOMAIN", "USERPROFILE", "HOMEDRIVE", "HOMEPATH", "APPDATA", "LOCALAPPDATA", "PROGRAMDATA", "PROGRAMFILES", "PROGRAMFILES(X86)", "PROGRAMW6432", "COMMONPROGRAMFILES", "COMMONPROGRAMFILES(X86)", "PUBLIC", "NUMBER_OF_PROCESSORS", "PROCESSOR_*"}
+)
+
+// isolationName folds variable names, since they are case insensitive on
+// Windows.
+func isolationName(name string) string {
+	return strings.ToUpper(name)
+}
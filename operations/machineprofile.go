@@ -0,0 +1,495 @@
+package operations
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/settings"
+)
+
+// Machine profile archive is zip file with "profile.json" manifest, its
+// signature "profile.sig" (same format as catalog signatures), and files
+// listed in manifest with their sha256 digests.
+const (
+	machineManifest     = `profile.json`
+	machineSignature    = `profile.sig`
+	machineSettings     = `settings.yaml`
+	machineCertificates = `certificates`
+	machineMicromamba   = `micromamba`
+)
+
+var (
+	certificateVariables = []string{"SSL_CERT_FILE", "REQUESTS_CA_BUNDLE", "CURL_CA_BUNDLE", "NODE_EXTRA_CA_CERTS", "PIP_CERT", "GIT_SSL_CAINFO", "CONDA_SSL_VERIFY"}
+	certificateSuffixes  = []string{".pem", ".crt", ".cer", ".cert"}
+	machineSecrets       = []string{"signing-key", "encryption-key", "client-key"}
+)
+
+// MachineFile is one file in machine profile archive. Origin is location of
+// certificate bundle on exporting machine, so that references to it can be
+// updated on import.
+type MachineFile struct {
+	Name   string `json:"name"`
+	Origin string `json:"origin,omitempty"`
+	Digest string `json:"sha256"`
+}
+
+// MachineProfile is manifest of machine profile archive.
+type MachineProfile struct {
+	Version    string         `json:"rcc"`
+	Platform   string         `json:"platform"`
+	Created    string         `json:"created"`
+	Micromamba string         `json:"micromamba,omitempty"`
+	Digest     string         `json:"micromamba-sha256,omitempty"`
+	Files      []*MachineFile `json:"files"`
+	Stripped   []string       `json:"stripped,omitempty"`
+	Signer     string         `json:"-"`
+}
+
+// MachineImport tells what importing machine profile changed.
+type MachineImport struct {
+	Signer       string   `json:"signer,omitempty"`
+	Settings     string   `json:"settings,omitempty"`
+	Backup       string   `json:"backup,omitempty"`
+	Certificates []string `json:"certificates"`
+	Micromamba   string   `json:"micromamba,omitempty"`
+	Binary       string   `json:"binary,omitempty"`
+}
+
+func isCertificateBundle(variable, value string) bool {
+	if !filepath.IsAbs(value) || !pathlib.IsFile(value) {
+		return false
+	}
+	for _, name := range certificateVariables {
+		if strings.EqualFold(name, variable) {
+			return true
+		}
+	}
+	for _, suffix := range certificateSuffixes {
+		if strings.HasSuffix(strings.ToLower(value), suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// certificateBundles finds certificate bundle files environment profiles of
+// settings refer to.
+func certificateBundles(config *settings.Settings) []string {
+	found := make(map[string]bool)
+	if config.Profiles != nil {
+		for _, profile := range config.Profiles.Profiles {
+			for variable, value := range profile {
+				if isCertificateBundle(variable, value) {
+					found[filepath.Clean(value)] = true
+				}
+			}
+		}
+	}
+	result := make([]string, 0, len(found))
+	for filename := range found {
+		result = append(result, filename)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// stripSecrets removes private key settings (and their continuation lines)
+// from settings.yaml, keeping everything else, including comments, as is.
+func stripSecrets(raw []byte) ([]byte, []string, error) {
+	kept := []string{}
+	stripped := []string{}
+	skipping := -1
+	for _, line := range strings.Split(string(raw), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+		if skipping >= 0 && (len(strings.TrimSpace(line)) == 0 || indent > skipping) {
+			continue
+		}
+		skipping = -1
+		for _, name := range machineSecrets {
+			if strings.HasPrefix(trimmed, name+":") {
+				stripped = append(stripped, name)
+				skipping = indent
+				break
+			}
+		}
+		if skipping < 0 {
+			kept = append(kept, line)
+		}
+	}
+	content := []byte(strings.Join(kept, "\n"))
+	config, err := settings.FromBytes(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	leftover := (config.Holotree != nil && (len(config.Holotree.SigningKey) > 0 || len(config.Holotree.ClientKey) > 0)) || (config.Hololib != nil && len(config.Hololib.EncryptionKey) > 0)
+	if leftover {
+		return nil, nil, fmt.Errorf("private keys are still there after stripping them")
+	}
+	return content, stripped, nil
+}
+
+// machineSecret is private key setting (with its continuation lines, which
+// are indented relative to setting itself) in toplevel section of settings.
+type machineSecret struct {
+	section string
+	name    string
+	lines   []string
+}
+
+// localSecrets finds private key settings from settings.yaml.
+func localSecrets(raw []byte) []*machineSecret {
+	result := []*machineSecret{}
+	section := ""
+	var current *machineSecret
+	skipping := -1
+	for _, line := range strings.Split(string(raw), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+		if skipping >= 0 && (len(strings.TrimSpace(line)) == 0 || indent > skipping) {
+			current.lines = append(current.lines, strings.TrimPrefix(line, strings.Repeat(" ", skipping)))
+			continue
+		}
+		skipping = -1
+		if indent == 0 && len(trimmed) > 0 && !strings.HasPrefix(trimmed, "#") {
+			section = strings.SplitN(trimmed, ":", 2)[0]
+			continue
+		}
+		for _, name := range machineSecrets {
+			if indent > 0 && strings.HasPrefix(trimmed, name+":") {
+				current = &machineSecret{section: section, name: name, lines: []string{trimmed}}
+				result = append(result, current)
+				skipping = indent
+				break
+			}
+		}
+	}
+	for _, secret := range result {
+		for len(secret.lines) > 1 && len(strings.TrimSpace(secret.lines[len(secret.lines)-1])) == 0 {
+			secret.lines = secret.lines[:len(secret.lines)-1]
+		}
+	}
+	return result
+}
+
+// mergeSecrets puts private key settings of this machine back into imported
+// settings.yaml (which normally has them stripped), unless imported settings
+// have their own values for them.
+func mergeSecrets(content string, secrets []*machineSecret) string {
+	existing := make(map[string]bool)
+	for _, secret := range localSecrets([]byte(content)) {
+		existing[secret.section+"/"+secret.name] = true
+	}
+	// inserted in reverse, since each goes right after its section header
+	for at := len(secrets) - 1; at >= 0; at-- {
+		secret := secrets[at]
+		if existing[secret.section+"/"+secret.name] {
+			continue
+		}
+		lines := strings.Split(content, "\n")
+		header := -1
+		for at, line := range lines {
+			if strings.HasPrefix(line, secret.section+":") {
+				header = at
+				break
+			}
+		}
+		if header < 0 {
+			if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+				lines = lines[:len(lines)-1]
+			}
+			lines = append(lines, secret.section+":")
+			header = len(lines) - 1
+			lines = append(lines, "")
+		}
+		indent := "  "
+		for _, line := range lines[header+1:] {
+			trimmed := strings.TrimLeft(line, " ")
+			if len(trimmed) == 0 || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			if len(line) > len(trimmed) {
+				indent = line[:len(line)-len(trimmed)]
+			}
+			break
+		}
+		added := make([]string, 0, len(secret.lines))
+		for _, line := range secret.lines {
+			if len(strings.TrimSpace(line)) == 0 {
+				added = append(added, "")
+			} else {
+				added = append(added, indent+line)
+			}
+		}
+		merged := append([]string{}, lines[:header+1]...)
+		merged = append(merged, added...)
+		merged = append(merged, lines[header+1:]...)
+		content = strings.Join(merged, "\n")
+	}
+	return content
+}
+
+func addMachineFile(writer *zip.Writer, profile *MachineProfile, name, origin string, content []byte) error {
+	sink, err := writer.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = sink.Write(content)
+	if err != nil {
+		return err
+	}
+	profile.Files = append(profile.Files, &MachineFile{
+		Name:   name,
+		Origin: origin,
+		Digest: fmt.Sprintf("%x", sha256.Sum256(content)),
+	})
+	return nil
+}
+
+// ExportMachineProfile writes settings.yaml, certificate bundles referred by
+// its environment profiles, and pinned micromamba (version and binary) into
+// archive, signed with configured signing key unless unsigned is requested.
+// Private keys (signing, encryption, and client keys) are left out of
+// settings.yaml, unless secrets are explicitly requested.
+func ExportMachineProfile(archive string, unsigned, secrets bool) (profile *MachineProfile, err error) {
+	defer fail.Around(&err)
+
+	profile = &MachineProfile{
+		Version:  common.Version,
+		Platform: common.Platform(),
+		Created:  time.Now().UTC().Format(time.RFC3339),
+		Files:    []*MachineFile{},
+	}
+	partial := fmt.Sprintf("%s.part%s", archive, <-common.Identities)
+	defer os.Remove(partial)
+	handle, err := os.Create(partial)
+	fail.On(err != nil, "Could not create %q -> %v", partial, err)
+	defer handle.Close()
+	writer := zip.NewWriter(handle)
+
+	if settings.HasCustomSettings() {
+		raw, err := os.ReadFile(settings.SettingsFileLocation())
+		fail.On(err != nil, "Could not read settings -> %v", err)
+		config, err := settings.FromBytes(raw)
+		fail.On(err != nil, "Could not parse settings -> %v", err)
+		if !secrets {
+			raw, profile.Stripped, err = stripSecrets(raw)
+			fail.On(err != nil, "Could not leave private keys out of settings -> %v", err)
+		}
+		err = addMachineFile(writer, profile, machineSettings, "", raw)
+		fail.On(err != nil, "Could not add settings -> %v", err)
+		for at, bundle := range certificateBundles(config) {
+			content, err := os.ReadFile(bundle)
+			fail.On(err != nil, "Could not read certificate bundle %q -> %v", bundle, err)
+			name := path.Join(machineCertificates, fmt.Sprintf("%02d_%s", at, filepath.Base(bundle)))
+			err = addMachineFile(writer, profile, name, bundle, content)
+			fail.On(err != nil, "Could not add certificate bundle %q -> %v", bundle, err)
+		}
+	}
+
+	profile.Micromamba = conda.MicromambaPinned()
+	if len(profile.Micromamba) > 0 {
+		profile.Digest = conda.MicromambaDigestOf(profile.Micromamba)
+	}
+	if len(profile.Micromamba) > 0 && !conda.UsingSystemMicromamba() && pathlib.IsFile(conda.BinMicromamba()) && !conda.MicromambaOutdated() {
+		content, err := os.ReadFile(conda.BinMicromamba())
+		fail.On(err != nil, "Could not read micromamba -> %v", err)
+		actual := fmt.Sprintf("%x", sha256.Sum256(content))
+		fail.On(len(profile.Digest) > 0 && actual != profile.Digest, "Installed micromamba does not match its pinned sha256 digest %s.", profile.Digest)
+		name := path.Join(machineMicromamba, filepath.Base(conda.BinMicromamba()))
+		err = addMachineFile(writer, profile, name, "", content)
+		fail.On(err != nil, "Could not add micromamba -> %v", err)
+	}
+	fail.On(len(profile.Files) == 0 && len(profile.Micromamba) == 0, "Nothing to export, there is no custom settings.yaml nor pinned micromamba.")
+
+	manifest, err := json.MarshalIndent(profile, "", "  ")
+	fail.On(err != nil, "Could not create manifest -> %v", err)
+	sink, err := writer.Create(machineManifest)
+	fail.On(err != nil, "Could not add manifest -> %v", err)
+	_, err = sink.Write(manifest)
+	fail.On(err != nil, "Could not add manifest -> %v", err)
+	if !unsigned {
+		signature, public, err := htfs.SignContent(manifest)
+		fail.On(err != nil, "Could not sign machine profile (use --unsigned to skip signing) -> %v", err)
+		sink, err := writer.Create(machineSignature)
+		fail.On(err != nil, "Could not add signature -> %v", err)
+		_, err = sink.Write([]byte(signature))
+		fail.On(err != nil, "Could not add signature -> %v", err)
+		profile.Signer = public
+	}
+	err = writer.Close()
+	fail.On(err != nil, "Could not finish %q -> %v", partial, err)
+	err = handle.Close()
+	fail.On(err != nil, "Could not close %q -> %v", partial, err)
+	err = os.Rename(partial, archive)
+	fail.On(err != nil, "Could not save %q -> %v", archive, err)
+	return profile, nil
+}
+
+func readZipEntry(entries map[string]*zip.File, name string) ([]byte, bool, error) {
+	entry, ok := entries[name]
+	if !ok {
+		return nil, false, nil
+	}
+	source, err := entry.Open()
+	if err != nil {
+		return nil, true, err
+	}
+	defer source.Close()
+	content, err := io.ReadAll(source)
+	return content, true, err
+}
+
+// verifyMachineProfile checks signature of manifest against trusted keys
+// (from settings and given ones), and returns signer.
+func verifyMachineProfile(manifest, signature []byte, signed bool, trusted []string, insecure bool) (string, error) {
+	keys := append(htfs.TrustedKeys(), trusted...)
+	switch {
+	case signed && len(keys) > 0:
+		return htfs.VerifySignature(manifest, string(signature), keys)
+	case insecure:
+		common.Log("Warning: importing machine profile without verifying its signature.")
+		return "", nil
+	case signed:
+		return "", fmt.Errorf("Cannot verify machine profile signature, no trusted keys. Give signer public key with --trust.")
+	default:
+		return "", fmt.Errorf("Machine profile is not signed. Use --insecure to import it anyway.")
+	}
+}
+
+// rewriteReferences points settings to imported certificate bundles, also
+// when paths are written with escaped backslashes in YAML.
+func rewriteReferences(content string, origin, target string) string {
+	content = strings.ReplaceAll(content, origin, target)
+	escaped := strings.ReplaceAll(origin, `\`, `\\`)
+	if escaped != origin {
+		content = strings.ReplaceAll(content, escaped, strings.ReplaceAll(target, `\`, `\\`))
+	}
+	return content
+}
+
+// ImportMachineProfile verifies archive made by ExportMachineProfile and
+// takes its settings, certificate bundles, and micromamba into use. Existing
+// settings.yaml is kept as backup, and its private keys are kept. Micromamba binary is only installed when
+// it matches digest pinned on this machine, or archive signature is trusted.
+func ImportMachineProfile(archive string, trusted []string, insecure bool) (result *MachineImport, err error) {
+	defer fail.Around(&err)
+
+	reader, err := zip.OpenReader(archive)
+	fail.On(err != nil, "Could not open %q -> %v", archive, err)
+	defer reader.Close()
+	entries := make(map[string]*zip.File)
+	for _, entry := range reader.File {
+		entries[entry.Name] = entry
+	}
+	manifest, ok, err := readZipEntry(entries, machineManifest)
+	fail.On(err != nil || !ok, "File %q is not machine profile archive (no %s) -> %v", archive, machineManifest, err)
+	signature, signed, err := readZipEntry(entries, machineSignature)
+	fail.On(err != nil, "Could not read signature -> %v", err)
+	signer, err := verifyMachineProfile(manifest, signature, signed, trusted, insecure)
+	fail.On(err != nil, "%w", common.WithCode(common.CodeHoloSignature, fmt.Errorf("Machine profile failed signature verification -> %v", err)))
+	profile := &MachineProfile{}
+	err = json.Unmarshal(manifest, profile)
+	fail.On(err != nil, "Could not parse manifest -> %v", err)
+
+	contents := make(map[string][]byte)
+	for _, file := range profile.Files {
+		content, ok, err := readZipEntry(entries, file.Name)
+		fail.On(err != nil || !ok, "Could not read %q from archive -> %v", file.Name, err)
+		fail.On(fmt.Sprintf("%x", sha256.Sum256(content)) != file.Digest, "File %q does not match its digest in manifest.", file.Name)
+		contents[file.Name] = content
+	}
+
+	binary, digest := "", ""
+	if len(profile.Micromamba) > 0 {
+		digest = conda.MicromambaDigestOf(profile.Micromamba)
+		if len(digest) == 0 && len(signer) > 0 {
+			digest = profile.Digest
+		}
+	}
+	for _, file := range profile.Files {
+		if !strings.HasPrefix(file.Name, machineMicromamba+"/") {
+			continue
+		}
+		switch {
+		case profile.Platform != common.Platform() || conda.UsingSystemMicromamba():
+			common.Log("Not installing micromamba binary for %s, it will be downloaded when needed.", profile.Platform)
+		case len(digest) > 0:
+			fail.On(fmt.Sprintf("%x", sha256.Sum256(contents[file.Name])) != digest, "Micromamba in archive does not match pinned sha256 digest %s.", digest)
+			binary = file.Name
+		case len(signer) > 0:
+			binary = file.Name
+		default:
+			pretty.Warning("Not installing unverified micromamba binary from unsigned archive, it will be downloaded (and verified) when needed.")
+		}
+	}
+
+	result = &MachineImport{Signer: signer, Certificates: []string{}}
+	replacements := make(map[string]string)
+	for _, file := range profile.Files {
+		if !strings.HasPrefix(file.Name, machineCertificates+"/") {
+			continue
+		}
+		target := filepath.Join(common.WritableHome(), machineCertificates, path.Base(file.Name))
+		_, err = pathlib.EnsureParentDirectory(target)
+		fail.On(err != nil, "Could not create directory for %q -> %v", target, err)
+		err = os.WriteFile(target, contents[file.Name], 0o644)
+		fail.On(err != nil, "Could not write %q -> %v", target, err)
+		replacements[file.Origin] = target
+		result.Certificates = append(result.Certificates, target)
+	}
+
+	if raw, ok := contents[machineSettings]; ok {
+		content := string(raw)
+		for origin, target := range replacements {
+			content = rewriteReferences(content, origin, target)
+		}
+		location := settings.WritableSettingsLocation()
+		if pathlib.IsFile(location) {
+			local, err := os.ReadFile(location)
+			fail.On(err != nil, "Could not read %q -> %v", location, err)
+			content = mergeSecrets(content, localSecrets(local))
+		}
+		_, err = settings.FromBytes([]byte(content))
+		fail.On(err != nil, "Imported settings.yaml is not valid -> %v", err)
+		if pathlib.IsFile(location) {
+			result.Backup = location + ".before-import"
+			err = pathlib.CopyFile(location, result.Backup, true)
+			fail.On(err != nil, "Could not backup %q -> %v", location, err)
+		}
+		_, err = pathlib.EnsureParentDirectory(location)
+		fail.On(err != nil, "Could not create directory for %q -> %v", location, err)
+		err = os.WriteFile(location, []byte(content), 0o644)
+		fail.On(err != nil, "Could not write %q -> %v", location, err)
+		result.Settings = location
+	}
+
+	if len(profile.Micromamba) > 0 {
+		conda.PinMicromamba(profile.Micromamba, digest)
+		result.Micromamba = profile.Micromamba
+	}
+	if len(binary) > 0 {
+		target := conda.BinMicromamba()
+		_, err = pathlib.EnsureParentDirectory(target)
+		fail.On(err != nil, "Could not create directory for %q -> %v", target, err)
+		err = os.WriteFile(target, contents[binary], 0o755)
+		fail.On(err != nil, "Could not write %q -> %v", target, err)
+		result.Binary = target
+	}
+	return result, nil
+}
@@ -0,0 +1,69 @@
+package operations_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/operations"
+	"github.com/robocorp/rcc/settings"
+)
+
+func TestMachineProfileRoundtrip(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	original := os.Getenv(common.ROBOCORP_HOME_VARIABLE)
+	defer os.Setenv(common.ROBOCORP_HOME_VARIABLE, original)
+	defer os.Unsetenv(htfs.RCC_SIGNING_KEY)
+
+	workarea := t.TempDir()
+	source := filepath.Join(workarea, "source")
+	target := filepath.Join(workarea, "target")
+	must.Nil(os.MkdirAll(source, 0o755))
+	must.Nil(os.MkdirAll(target, 0o755))
+	bundle := filepath.Join(workarea, "corporate.pem")
+	must.Nil(os.WriteFile(bundle, []byte("-----BEGIN CERTIFICATE-----\n"), 0o644))
+	keyfile := filepath.Join(workarea, "signing.key")
+	public, err := htfs.GenerateSigningKey(keyfile)
+	must.Nil(err)
+	os.Setenv(htfs.RCC_SIGNING_KEY, keyfile)
+
+	os.Setenv(common.ROBOCORP_HOME_VARIABLE, source)
+	config := "# office machines\nenvironment-profiles:\n  active: [office]\n  profiles:\n    office:\n      SSL_CERT_FILE: " + bundle + "\nhololib:\n  encryption-key: |\n    very-secret\n  compression: zstd\n"
+	must.Nil(os.WriteFile(settings.SettingsFileLocation(), []byte(config), 0o644))
+	archive := filepath.Join(workarea, "profile.zip")
+	profile, err := operations.ExportMachineProfile(archive, false, false)
+	must.Nil(err)
+	must.Equal(public, profile.Signer)
+	must.Equal(2, len(profile.Files))
+	must.Equal([]string{"encryption-key"}, profile.Stripped)
+
+	os.Setenv(common.ROBOCORP_HOME_VARIABLE, target)
+	_, err = operations.ImportMachineProfile(archive, []string{}, false)
+	wont.Nil(err)
+	wont.True(strings.Contains(err.Error(), "digest"))
+
+	local := "hololib:\n    encryption-key: |\n      local-secret\n\n    compression: gzip\nholotree:\n  signing-key: /home/me/signing.key\n  client-key: /home/me/client.key\n"
+	must.Nil(os.WriteFile(settings.WritableSettingsLocation(), []byte(local), 0o644))
+	result, err := operations.ImportMachineProfile(archive, []string{public}, false)
+	must.Nil(err)
+	must.Equal(public, result.Signer)
+	must.Equal(1, len(result.Certificates))
+	content, err := os.ReadFile(settings.SettingsFileLocation())
+	must.Nil(err)
+	must.True(strings.Contains(string(content), result.Certificates[0]))
+	must.True(strings.Contains(string(content), "# office machines\n"))
+	must.True(strings.Contains(string(content), "  compression: zstd\n"))
+	wont.True(strings.Contains(string(content), bundle))
+	wont.True(strings.Contains(string(content), "very-secret"))
+	merged, err := settings.FromBytes(content)
+	must.Nil(err)
+	must.Equal("local-secret\n", merged.Hololib.EncryptionKey)
+	must.Equal("zstd", merged.Hololib.Compression)
+	must.Equal("/home/me/signing.key", merged.Holotree.SigningKey)
+	must.Equal("/home/me/client.key", merged.Holotree.ClientKey)
+}
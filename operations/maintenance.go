@@ -0,0 +1,63 @@
+package operations
+
+import (
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/journal"
+	"github.com/robocorp/rcc/settings"
+)
+
+// MaintenanceCycle does one round of hygiene: cleanup of old environments
+// and temporary files, pruning of stale catalogs when retention is set in
+// settings, and optionally hololib integrity verification (which also
+// removes blobs that no catalog refers to). Results go to journal.
+func MaintenanceCycle(days int, verify bool) error {
+	stopwatch := common.Stopwatch("Maintenance cycle took")
+	common.Log("Maintenance cycle started (retention %d days, verify=%v).", days, verify)
+	_, err := conda.Cleanup(days, 0, false, false, false, false, htfs.SpaceCleaner)
+	if err != nil {
+		journal.Post("maintenance", "cleanup-failed", "cleanup failed: %v", err)
+		return err
+	}
+	retention, keep := settings.Global.CatalogRetention()
+	if retention > 0 {
+		report, err := htfs.PruneCatalogs(retention, keep, false)
+		if err != nil {
+			journal.Post("maintenance", "prune-failed", "catalog pruning failed: %v", err)
+			return err
+		}
+		journal.Post("maintenance", "prune-done", "pruned %d catalogs and %d blobs, kept %d catalogs", len(report.Removed), len(report.Garbage.Removed), len(report.Kept))
+	}
+	damaged, purged := 0, 0
+	if verify {
+		report, err := htfs.CheckIntegrity()
+		if err != nil {
+			journal.Post("maintenance", "verify-failed", "integrity check failed: %v", err)
+			return err
+		}
+		damaged, purged = len(report.Damaged), len(report.Purged)
+	}
+	elapsed := stopwatch.Log()
+	journal.Post("maintenance", "cycle-done", "retention %d days, %d damaged blobs, %d purged catalogs, took %s", days, damaged, purged, elapsed)
+	return nil
+}
+
+// MaintenanceLoop runs maintenance cycles, with given pause between, until
+// rcc is cancelled.
+func MaintenanceLoop(interval time.Duration, days int, verify bool) {
+	for {
+		err := MaintenanceCycle(days, verify)
+		if err != nil {
+			common.Log("Maintenance cycle failed, reason: %v", err)
+		}
+		common.Log("Next maintenance cycle in %v.", interval)
+		select {
+		case <-common.Context().Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
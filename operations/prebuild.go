@@ -0,0 +1,278 @@
+package operations
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robocorp/rcc/anywork"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/robot"
+	"github.com/robocorp/rcc/shell"
+)
+
+const (
+	prebuildCached    = `cached`
+	prebuildBuilt     = `built`
+	prebuildDuplicate = `duplicate`
+	prebuildSkipped   = `skipped`
+	prebuildFailed    = `failed`
+)
+
+// PrebuildTarget is one discovered robot.yaml or conda.yaml, and outcome of
+// building its environment.
+type PrebuildTarget struct {
+	Source    string  `json:"source"`
+	Kind      string  `json:"kind"`
+	Blueprint string  `json:"blueprint,omitempty"`
+	Status    string  `json:"status"`
+	Seconds   float64 `json:"seconds"`
+	Problem   string  `json:"problem,omitempty"`
+	condafile string
+	settings  *robot.Settings
+	blueprint []byte
+}
+
+// PrebuildReport is summary of prebuilding environments under one directory.
+type PrebuildReport struct {
+	Root    string            `json:"root"`
+	Workers uint64            `json:"workers"`
+	Targets []*PrebuildTarget `json:"targets"`
+	Seconds float64           `json:"seconds"`
+}
+
+// Failures counts targets that failed to build.
+func (it *PrebuildReport) Failures() int {
+	count := 0
+	for _, target := range it.Targets {
+		if target.Status == prebuildFailed {
+			count++
+		}
+	}
+	return count
+}
+
+func (it *PrebuildTarget) skip(status, form string, details ...interface{}) {
+	it.Status = status
+	it.Problem = fmt.Sprintf(form, details...)
+}
+
+// DiscoverConfigurations finds robot.yaml files, and conda.yaml files that
+// are not part of any found robot, under root. Hidden directories are not
+// searched.
+func DiscoverConfigurations(root string) (robots []string, condas []string, err error) {
+	robots, condas = []string{}, []string{}
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != root && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch entry.Name() {
+		case "robot.yaml":
+			robots = append(robots, path)
+		case "conda.yaml":
+			condas = append(condas, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	owned := make(map[string]bool)
+	for _, filename := range robots {
+		owned[filepath.Dir(filename)] = true
+		config, err := robot.LoadRobotYaml(filename, false)
+		if err == nil && config.UsesConda() {
+			owned[filepath.Clean(config.CondaConfigFile())] = true
+		}
+	}
+	standalone := []string{}
+	for _, filename := range condas {
+		if !owned[filename] && !owned[filepath.Dir(filename)] {
+			standalone = append(standalone, filename)
+		}
+	}
+	sort.Strings(robots)
+	sort.Strings(standalone)
+	return robots, standalone, nil
+}
+
+// compose finds blueprint of target, and conda file and robot settings to
+// build it with. Settings of robot.yaml stay with that one target.
+func (it *PrebuildTarget) compose() error {
+	userFiles, packfile := []string{it.Source}, ""
+	it.condafile, it.settings = it.Source, &robot.Settings{}
+	if it.Kind == "robot" {
+		userFiles, packfile = []string{}, it.Source
+		config, err := robot.LoadRobotYaml(it.Source, false)
+		if err != nil {
+			return err
+		}
+		if !config.UsesConda() {
+			it.skip(prebuildSkipped, "robot does not use conda")
+			return nil
+		}
+		it.condafile, it.settings = config.CondaConfigFile(), robot.SettingsOf(config)
+	}
+	_, blueprint, err := htfs.ComposeFinalBlueprint(userFiles, packfile)
+	if err != nil {
+		return err
+	}
+	it.blueprint = blueprint
+	it.Blueprint = htfs.BlueprintHash(blueprint)
+	return nil
+}
+
+func newPrebuildTarget(filename string) *PrebuildTarget {
+	if filepath.Base(filename) == "robot.yaml" {
+		return &PrebuildTarget{Source: filename, Kind: "robot"}
+	}
+	return &PrebuildTarget{Source: filename, Kind: "conda"}
+}
+
+func prebuildTargets(robots, condas []string) []*PrebuildTarget {
+	targets := make([]*PrebuildTarget, 0, len(robots)+len(condas))
+	for _, filename := range append(robots, condas...) {
+		targets = append(targets, newPrebuildTarget(filename))
+	}
+	return targets
+}
+
+// plan composes blueprints of all targets, and returns unique targets that
+// are not yet in hololib.
+func prebuildPlan(tree htfs.Library, targets []*PrebuildTarget) []*PrebuildTarget {
+	todo := []*PrebuildTarget{}
+	seen := make(map[string]string)
+	for _, target := range targets {
+		err := target.compose()
+		if err != nil {
+			target.skip(prebuildFailed, "%v", err)
+			continue
+		}
+		if len(target.Status) > 0 {
+			continue
+		}
+		if first, ok := seen[target.Blueprint]; ok {
+			target.skip(prebuildDuplicate, "same environment as %s", first)
+			continue
+		}
+		seen[target.Blueprint] = target.Source
+		if tree.HasBlueprint(target.blueprint) {
+			target.Status = prebuildCached
+			continue
+		}
+		todo = append(todo, target)
+	}
+	return todo
+}
+
+// PrebuildSlot builds environment of single robot.yaml or conda.yaml into
+// hololib. Prebuild runs it in separate rcc process for each target, with
+// its own stage slot, so that environments are built concurrently.
+func PrebuildSlot(filename string, force bool) *PrebuildTarget {
+	target := newPrebuildTarget(filename)
+	stopwatch := time.Now()
+	err := target.compose()
+	if err == nil && len(target.Status) == 0 {
+		_, _, err = htfs.NewEnvironment(target.condafile, "", false, force, target.settings)
+		if err == nil {
+			target.Status = prebuildBuilt
+		}
+	}
+	if err != nil {
+		target.skip(prebuildFailed, "%v", err)
+	}
+	target.Seconds = time.Since(stopwatch).Seconds()
+	return target
+}
+
+func prebuildCommand(target *PrebuildTarget, slot int, force bool) []string {
+	command := []string{common.BinRcc(), "internal", "prebuild", "--silent", "--slot", fmt.Sprintf("%d", slot)}
+	if force {
+		command = append(command, "--force")
+	}
+	if common.OfflineFlag {
+		command = append(command, "--offline")
+	}
+	if common.RetryFailed {
+		command = append(command, "--retry-failed")
+	}
+	return append(command, target.Source)
+}
+
+// prebuildSlots builds targets concurrently, as many at a time as there are
+// workers, each in separate rcc process and stage slot. Processes do not
+// share state, and hololib is locked only while recording into it.
+func prebuildSlots(todo []*PrebuildTarget, force bool) {
+	slots := make(chan int, anywork.Scale())
+	for slot := 1; slot <= cap(slots); slot++ {
+		slots <- slot
+	}
+	var guard sync.Mutex
+	for _, target := range todo {
+		target := target
+		anywork.Backlog(func() {
+			slot := <-slots
+			defer func() {
+				slots <- slot
+			}()
+			common.Log("Building environment %s from %s in stage slot %d ...", target.Blueprint, target.Source, slot)
+			command := prebuildCommand(target, slot, force)
+			output, code, err := shell.New(os.Environ(), ".", command...).CaptureOutput()
+			outcome := &PrebuildTarget{}
+			if jsonErr := json.Unmarshal([]byte(output), outcome); jsonErr != nil {
+				outcome.Status = prebuildFailed
+				outcome.Problem = fmt.Sprintf("build process failed [%d]: %v", code, err)
+			}
+			guard.Lock()
+			defer guard.Unlock()
+			target.Status, target.Problem, target.Seconds = outcome.Status, outcome.Problem, outcome.Seconds
+		})
+	}
+	anywork.Sync()
+}
+
+// Prebuild builds environments of all robots and standalone conda.yaml files
+// under root into hololib, without restoring them into any space. Failures
+// do not stop other builds, they are in report.
+func Prebuild(root string, force bool) (*PrebuildReport, error) {
+	started := time.Now()
+	robots, condas, err := DiscoverConfigurations(root)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := htfs.New()
+	if err != nil {
+		return nil, err
+	}
+	report := &PrebuildReport{
+		Root:    root,
+		Workers: anywork.Scale(),
+		Targets: prebuildTargets(robots, condas),
+	}
+	todo := prebuildPlan(tree, report.Targets)
+	if len(todo) > 0 {
+		// installed once here, so that build processes do not race on it
+		if !conda.MustMicromamba() {
+			pretty.Warning("Micromamba is not available, builds that need it will fail.")
+		}
+		common.Log("Building %d environment(s) with %d workers.", len(todo), anywork.Scale())
+		prebuildSlots(todo, force)
+	}
+	report.Seconds = time.Since(started).Seconds()
+	return report, nil
+}
@@ -0,0 +1,69 @@
+package operations_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/operations"
+)
+
+func TestDiscoveringPrebuildConfigurations(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	root := t.TempDir()
+	files := map[string]string{
+		"alpha/robot.yaml":     "tasks:\n  run:\n    shell: python task.py\ncondaConfigFile: conda.yaml\nartifactsDir: output\n",
+		"alpha/conda.yaml":     "dependencies:\n- python=3.10\n",
+		"beta/robot.yaml":      "tasks:\n  run:\n    shell: python task.py\ncondaConfigFile: env/conda.yaml\nartifactsDir: output\n",
+		"beta/env/conda.yaml":  "dependencies:\n- python=3.10\n",
+		"gamma/conda.yaml":     "dependencies:\n- python=3.9\n",
+		".hidden/conda.yaml":   "dependencies:\n- python=3.9\n",
+		"delta/deep/notes.txt": "nothing here",
+	}
+	for name, content := range files {
+		fullpath := filepath.Join(root, filepath.FromSlash(name))
+		must.Nil(os.MkdirAll(filepath.Dir(fullpath), 0o755))
+		must.Nil(os.WriteFile(fullpath, []byte(content), 0o644))
+	}
+
+	robots, condas, err := operations.DiscoverConfigurations(root)
+	must.Nil(err)
+	must.Equal([]string{filepath.Join(root, "alpha", "robot.yaml"), filepath.Join(root, "beta", "robot.yaml")}, robots)
+	must.Equal([]string{filepath.Join(root, "gamma", "conda.yaml")}, condas)
+
+	_, _, err = operations.DiscoverConfigurations(filepath.Join(root, "missing"))
+	wont.Nil(err)
+}
+
+func TestPrebuildReportCountsFailures(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	report := &operations.PrebuildReport{
+		Targets: []*operations.PrebuildTarget{
+			{Status: "built"},
+			{Status: "failed"},
+			{Status: "cached"},
+			{Status: "failed"},
+		},
+	}
+	must.Equal(2, report.Failures())
+}
+
+func TestPrebuildSlotReportsOutcomeOfSingleTarget(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	root := t.TempDir()
+	robotfile := filepath.Join(root, "robot.yaml")
+	must.Nil(os.WriteFile(robotfile, []byte("tasks:\n  run:\n    shell: echo\nartifactsDir: output\n"), 0o644))
+	target := operations.PrebuildSlot(robotfile, false)
+	must.Equal("robot", target.Kind)
+	must.Equal("skipped", target.Status)
+
+	condafile := filepath.Join(root, "conda.yaml")
+	must.Nil(os.WriteFile(condafile, []byte("dependencies: [\n"), 0o644))
+	target = operations.PrebuildSlot(condafile, false)
+	must.Equal("conda", target.Kind)
+	must.Equal("failed", target.Status)
+}
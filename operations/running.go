@@ -1,14 +1,18 @@
 package operations
 
 import (
+	"bytes"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/robocorp/rcc/common"
 	"github.com/robocorp/rcc/conda"
 	"github.com/robocorp/rcc/htfs"
 	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/plugins"
 	"github.com/robocorp/rcc/pretty"
 	"github.com/robocorp/rcc/robot"
 	"github.com/robocorp/rcc/shell"
@@ -27,6 +31,23 @@ type RunFlags struct {
 	RobotYaml       string
 	Assistant       bool
 	NoPipFreeze     bool
+	TaskName        string
+	RunReport       bool
+	ShowReport      bool
+	Warmup          bool
+	Isolation       string
+	Allowed         []string
+}
+
+// parentEnvironment is part of rcc's own environment that task gets, based
+// on isolation level of run.
+func (it *RunFlags) parentEnvironment() []string {
+	environment, err := ParentEnvironment(it.Isolation, it.Allowed)
+	if err != nil {
+		pretty.Exit(5, "Error: %v", err)
+	}
+	common.Debug("Task inherits %d of %d environment variables (isolation %q).", len(environment), len(os.Environ()), it.Isolation)
+	return environment
 }
 
 func FreezeEnvironmentListing(label string, config robot.Robot) {
@@ -48,6 +69,86 @@ func FreezeEnvironmentListing(label string, config robot.Robot) {
 	}
 }
 
+func runHookContext(flags *RunFlags, config robot.Robot, label, outputDir string) plugins.Context {
+	return plugins.Context{
+		"robot":     flags.RobotYaml,
+		"task":      flags.TaskName,
+		"label":     label,
+		"directory": config.WorkingDirectory(),
+		"artifacts": outputDir,
+	}
+}
+
+// robotRunHooks prepares run hooks of robot.yaml for given stage. Hooks
+// inside environment get same environment and search path as task itself.
+func robotRunHooks(config robot.Robot, stage string, searchPath pathlib.PathParts, environment []string) ([]*plugins.RobotHook, error) {
+	hooks := config.LifecycleHooks(stage)
+	result := make([]*plugins.RobotHook, 0, len(hooks))
+	for _, hook := range hooks {
+		var inside []string
+		if hook.Inside() {
+			inside = environment
+		}
+		runnable, err := plugins.NewRobotHook(hook.Command, config.WorkingDirectory(), inside)
+		if err != nil {
+			return nil, err
+		}
+		if found, ok := searchPath.Which(runnable.Task[0], conda.FileExtensions); ok && hook.Inside() {
+			runnable.Task[0] = found
+		}
+		result = append(result, runnable)
+	}
+	return result, nil
+}
+
+func runStageHooks(stage string, context plugins.Context, config robot.Robot, searchPath pathlib.PathParts, environment []string) error {
+	err := plugins.RunHooks(stage, context)
+	if err != nil {
+		return err
+	}
+	hooks, err := robotRunHooks(config, stage, searchPath, environment)
+	if err != nil {
+		return err
+	}
+	return plugins.RunRobotHooks(stage, hooks, context)
+}
+
+func preRunHooks(context plugins.Context, config robot.Robot, searchPath pathlib.PathParts, environment []string) {
+	err := runStageHooks(plugins.PreRun, context, config, searchPath, environment)
+	if err != nil {
+		pretty.Exit(common.CodeRunHook.Exit, "Error: [%s] robot run blocked by hook: %v", common.CodeRunHook.Code, err)
+	}
+}
+
+func postRunHooks(context plugins.Context, config robot.Robot, searchPath pathlib.PathParts, environment []string, code int, report *RunReport) {
+	context["exit-code"] = code
+	err := runStageHooks(plugins.PostRun, context, config, searchPath, environment)
+	if err != nil {
+		pretty.Warning("%v", err)
+		report.Warning(err.Error())
+	}
+}
+
+func snapshotToolCaches(config robot.Robot, label string) {
+	caches := config.ToolCaches()
+	if len(caches) == 0 || len(label) == 0 {
+		return
+	}
+	directories := make([]string, 0, len(caches))
+	for _, directory := range caches {
+		directories = append(directories, directory)
+	}
+	sort.Strings(directories)
+	added, err := htfs.SnapshotToolCaches(label, directories)
+	if err != nil {
+		pretty.Warning("Could not snapshot tool caches, reason: %v", err)
+		return
+	}
+	if len(added) > 0 {
+		common.Log("Tool caches %v are now part of holotree environment.", added)
+	}
+}
+
 func ExecutionEnvironmentListing(wantedfile, label string, searchPath pathlib.PathParts, directory, outputDir string, environment []string) bool {
 	common.Timeline("execution environment listing")
 	defer common.Log("--")
@@ -114,9 +215,9 @@ func LoadTaskWithEnvironment(packfile, theTask string, force bool) (bool, robot.
 		return true, config, todo, ""
 	}
 
-	label, _, err := htfs.NewEnvironment(config.CondaConfigFile(), config.Holozip(), true, force)
+	label, _, err := htfs.NewEnvironment(config.CondaConfigFile(), config.Holozip(), true, force, robot.SettingsOf(config))
 	if err != nil {
-		pretty.Exit(4, "Error: %v", err)
+		pretty.Exit(common.ExitCodeOf(err, 4), "Error: %v", err)
 	}
 	return false, config, todo, label
 }
@@ -156,7 +257,9 @@ func ExecuteSimpleTask(flags *RunFlags, template []string, config robot.Robot, t
 	}
 	task[0] = fullpath
 	directory := config.WorkingDirectory()
-	environment := robot.PlainEnvironment([]string{searchPath.AsEnvironmental("PATH")}, true)
+	environment := robot.PlainEnvironment(append(flags.parentEnvironment(), searchPath.AsEnvironmental("PATH")), false)
+	environment = append(environment, conda.UTF8Environment()...)
+	environment = append(environment, config.ProfileEnvironment()...)
 	if len(data) > 0 {
 		endpoint := data["endpoint"]
 		for _, key := range rcHosts {
@@ -174,12 +277,24 @@ func ExecuteSimpleTask(flags *RunFlags, template []string, config robot.Robot, t
 		}
 	}
 	outputDir := config.ArtifactDirectory()
+	report := NewRunReport(flags, config, task, "")
+	hooks := runHookContext(flags, config, "", outputDir)
+	preRunHooks(hooks, config, searchPath, environment)
 	common.Debug("about to run command - %v", task)
+	code := 0
+	restore := conda.EnforceUTF8Console()
 	if common.NoOutputCapture {
-		_, err = shell.New(environment, directory, task...).Execute(interactive)
+		code, err = shell.New(environment, directory, task...).Execute(interactive)
 	} else {
-		_, err = shell.New(environment, directory, task...).Tee(outputDir, interactive)
+		code, err = shell.New(environment, directory, task...).Tee(outputDir, interactive)
 	}
+	restore()
+	report.Phase("task")
+	postRunHooks(hooks, config, searchPath, environment, code, report)
+	if err != nil {
+		report.Warning(err.Error())
+	}
+	report.Finish(code, outputDir, flags.ShowReport)
 	if err != nil {
 		pretty.Exit(9, "Error: %v", err)
 	}
@@ -213,7 +328,8 @@ func ExecuteTask(flags *RunFlags, template []string, config robot.Robot, todo ro
 	}
 	task[0] = fullpath
 	directory := config.WorkingDirectory()
-	environment := config.ExecutionEnvironment(label, developmentEnvironment.AsEnvironment(), true)
+	inject := append(flags.parentEnvironment(), developmentEnvironment.AsEnvironment()...)
+	environment := config.ExecutionEnvironment(label, inject, false)
 	if len(data) > 0 {
 		endpoint := data["endpoint"]
 		for _, key := range rcHosts {
@@ -233,20 +349,40 @@ func ExecuteTask(flags *RunFlags, template []string, config robot.Robot, todo ro
 	before := make(map[string]string)
 	beforeHash, beforeErr := conda.DigestFor(label, before)
 	outputDir := config.ArtifactDirectory()
+	report := NewRunReport(flags, config, task, label)
 	if !flags.NoPipFreeze && !flags.Assistant && !common.Silent && !interactive {
 		wantedfile, _ := config.DependenciesFile()
 		ExecutionEnvironmentListing(wantedfile, label, searchPath, directory, outputDir, environment)
 	}
 	FreezeEnvironmentListing(label, config)
+	report.Phase("listing")
+	hooks := runHookContext(flags, config, label, outputDir)
+	preRunHooks(hooks, config, searchPath, environment)
 	common.Debug("about to run command - %v", task)
+	code := 0
+	restore := conda.EnforceUTF8Console()
 	if common.NoOutputCapture {
-		_, err = shell.New(environment, directory, task...).Execute(interactive)
+		code, err = shell.New(environment, directory, task...).Execute(interactive)
 	} else {
-		_, err = shell.New(environment, directory, task...).Tee(outputDir, interactive)
+		code, err = shell.New(environment, directory, task...).Tee(outputDir, interactive)
+	}
+	restore()
+	report.Phase("task")
+	postRunHooks(hooks, config, searchPath, environment, code, report)
+	if err != nil {
+		report.Warning(err.Error())
 	}
 	after := make(map[string]string)
 	afterHash, afterErr := conda.DigestFor(label, after)
 	conda.DiagnoseDirty(label, label, beforeHash, afterHash, beforeErr, afterErr, before, after, true)
+	if beforeErr == nil && afterErr == nil && !bytes.Equal(beforeHash, afterHash) {
+		report.Warning("environment was modified during the run")
+	}
+	report.Phase("diagnose")
+	if flags.Warmup && err == nil && code == 0 {
+		snapshotToolCaches(config, label)
+	}
+	report.Finish(code, outputDir, flags.ShowReport)
 	if err != nil {
 		pretty.Exit(9, "Error: %v", err)
 	}
@@ -0,0 +1,120 @@
+package operations
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/robot"
+)
+
+const (
+	runReportFile = `run-report.json`
+)
+
+type RunPhase struct {
+	Name    string  `json:"name"`
+	Seconds float64 `json:"seconds"`
+}
+
+type RunReport struct {
+	Rcc         string      `json:"rcc"`
+	Task        string      `json:"task"`
+	Command     []string    `json:"command"`
+	Robot       string      `json:"robot"`
+	Controller  string      `json:"controller"`
+	Space       string      `json:"space"`
+	Platform    string      `json:"platform"`
+	Blueprint   string      `json:"blueprint"`
+	Environment string      `json:"environment"`
+	Started     string      `json:"started"`
+	ExitCode    int         `json:"exitcode"`
+	Phases      []*RunPhase `json:"phases"`
+	Artifacts   []string    `json:"artifacts"`
+	Warnings    []string    `json:"warnings"`
+	marker      time.Time
+}
+
+func NewRunReport(flags *RunFlags, config robot.Robot, command []string, label string) *RunReport {
+	if !flags.RunReport {
+		return nil
+	}
+	taskname := flags.TaskName
+	available := config.AvailableTasks()
+	if len(taskname) == 0 && len(available) == 1 {
+		taskname = available[0]
+	}
+	started := time.Now().Add(-time.Duration(common.Clock.Elapsed()))
+	report := &RunReport{
+		Rcc:         common.Version,
+		Task:        taskname,
+		Command:     command,
+		Robot:       flags.RobotYaml,
+		Controller:  common.ControllerIdentity(),
+		Space:       common.HolotreeSpace,
+		Platform:    common.Platform(),
+		Blueprint:   common.EnvironmentHash,
+		Environment: label,
+		Started:     started.Format(time.RFC3339),
+		Phases:      []*RunPhase{},
+		Artifacts:   []string{},
+		Warnings:    []string{},
+		marker:      started,
+	}
+	report.Phase("setup")
+	return report
+}
+
+func (it *RunReport) Phase(name string) {
+	if it == nil {
+		return
+	}
+	now := time.Now()
+	elapsed := now.Sub(it.marker).Round(time.Millisecond).Seconds()
+	it.Phases = append(it.Phases, &RunPhase{name, elapsed})
+	it.marker = now
+}
+
+func (it *RunReport) Warning(message string) {
+	if it == nil {
+		return
+	}
+	it.Warnings = append(it.Warnings, message)
+}
+
+func (it *RunReport) collectArtifacts(directory string) {
+	pathlib.Walk(directory, pathlib.IgnoreNothing, func(fullpath, relativepath string, details os.FileInfo) {
+		if relativepath == runReportFile {
+			return
+		}
+		it.Artifacts = append(it.Artifacts, filepath.ToSlash(relativepath))
+	})
+	sort.Strings(it.Artifacts)
+}
+
+func (it *RunReport) Finish(code int, directory string, show bool) {
+	if it == nil {
+		return
+	}
+	it.ExitCode = code
+	it.collectArtifacts(directory)
+	body, err := json.MarshalIndent(it, "", "  ")
+	if err != nil {
+		common.Log("Could not create run report, reason: %v", err)
+		return
+	}
+	filename := filepath.Join(directory, runReportFile)
+	err = ioutil.WriteFile(filename, body, 0o644)
+	if err != nil {
+		common.Log("Could not save run report %q, reason: %v", filename, err)
+	}
+	common.Timeline("run report written")
+	if show {
+		common.Stdout("%s\n", body)
+	}
+}
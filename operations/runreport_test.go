@@ -0,0 +1,48 @@
+package operations_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/operations"
+)
+
+func TestMissingRunReportIsSafeToUse(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	var sut *operations.RunReport
+	sut.Phase("task")
+	sut.Warning("nothing happens")
+	sut.Finish(0, "tmp", false)
+	must.Nil(sut)
+}
+
+func TestCanWriteRunReportIntoArtifacts(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	directory, err := ioutil.TempDir("", "runreport")
+	must.Nil(err)
+	defer os.RemoveAll(directory)
+	must.Nil(ioutil.WriteFile(filepath.Join(directory, "output.xml"), []byte("<xml/>"), 0o644))
+
+	sut := &operations.RunReport{Task: "demo", Phases: []*operations.RunPhase{}, Artifacts: []string{}, Warnings: []string{}}
+	sut.Phase("task")
+	sut.Warning("just testing")
+	sut.Finish(3, directory, false)
+
+	body, err := ioutil.ReadFile(filepath.Join(directory, "run-report.json"))
+	must.Nil(err)
+	loaded := operations.RunReport{}
+	must.Nil(json.Unmarshal(body, &loaded))
+	must.Equal("demo", loaded.Task)
+	must.Equal(3, loaded.ExitCode)
+	must.Equal(1, len(loaded.Phases))
+	must.Equal("task", loaded.Phases[0].Name)
+	must.Equal([]string{"output.xml"}, loaded.Artifacts)
+	must.Equal([]string{"just testing"}, loaded.Warnings)
+	wont.Nil(loaded.Phases[0])
+}
@@ -0,0 +1,128 @@
+package operations
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/robot"
+	"github.com/robocorp/rcc/shell"
+)
+
+const (
+	variantPass = `pass`
+	variantFail = `fail`
+)
+
+type VariantResult struct {
+	Variant  string  `json:"variant"`
+	Config   string  `json:"config"`
+	Space    string  `json:"space"`
+	Status   string  `json:"status"`
+	ExitCode int     `json:"exit-code"`
+	Seconds  float64 `json:"seconds"`
+	Error    string  `json:"error,omitempty"`
+}
+
+type VariantMatrix struct {
+	Robot   string           `json:"robot"`
+	Task    string           `json:"task"`
+	Results []*VariantResult `json:"results"`
+}
+
+func (it *VariantMatrix) Failed() int {
+	count := 0
+	for _, result := range it.Results {
+		if result.Status != variantPass {
+			count += 1
+		}
+	}
+	return count
+}
+
+func (it *VariantMatrix) Print() {
+	tabbed := tabwriter.NewWriter(os.Stderr, 2, 4, 2, ' ', 0)
+	tabbed.Write([]byte("Variant\tResult\tExit code\tSeconds\tSpace\n"))
+	tabbed.Write([]byte("-------\t------\t---------\t-------\t-----\n"))
+	for _, result := range it.Results {
+		data := fmt.Sprintf("%s\t%s\t%d\t%.1f\t%s\n", result.Variant, result.Status, result.ExitCode, result.Seconds, result.Space)
+		tabbed.Write([]byte(data))
+	}
+	tabbed.Flush()
+}
+
+func (it *VariantMatrix) SaveAs(filename string) error {
+	body, err := json.MarshalIndent(it, "", "  ")
+	if err != nil {
+		return err
+	}
+	pathlib.EnsureDirectoryExists(filepath.Dir(filename))
+	return ioutil.WriteFile(filename, body, 0o644)
+}
+
+func variantCommand(executable, variant, space string, flags *RunFlags, force bool, args []string) []string {
+	command := common.NewCommander(executable, "run", "--robot", flags.RobotYaml, "--variant", variant, "--space", space, "--controller", common.ControllerType)
+	command.Option("--task", flags.TaskName)
+	command.Option("--environment", flags.EnvironmentFile)
+	command.Option("--workspace", flags.WorkspaceId)
+	command.Option("--account", flags.AccountName)
+	command.ConditionalFlag(force, "--force")
+	command.ConditionalFlag(common.NoOutputCapture, "--no-outputs")
+	result := command.CLI()
+	if len(args) > 0 {
+		result = append(result, "--")
+		result = append(result, args...)
+	}
+	return result
+}
+
+// RunAllVariants builds environment for each variant of robot, runs task in
+// it (as separate rcc process, each in its own space), and collects results
+// into pass/fail matrix.
+func RunAllVariants(flags *RunFlags, space string, force bool, args []string) (*VariantMatrix, robot.Robot, error) {
+	config, err := robot.LoadRobotYaml(flags.RobotYaml, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	variants := config.Variants()
+	if len(variants) == 0 {
+		return nil, config, fmt.Errorf("Robot %q has no environment configuration variants.", flags.RobotYaml)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, config, err
+	}
+	matrix := &VariantMatrix{
+		Robot:   flags.RobotYaml,
+		Task:    flags.TaskName,
+		Results: make([]*VariantResult, 0, len(variants)),
+	}
+	for at, variant := range variants {
+		name := robot.VariantName(variant)
+		result := &VariantResult{
+			Variant: name,
+			Config:  variant,
+			Space:   fmt.Sprintf("%s-%s", space, name),
+			Status:  variantPass,
+		}
+		common.Log("####  Variant %d/%d: %s (space %q)", at+1, len(variants), name, result.Space)
+		started := time.Now()
+		code, err := shell.New(nil, ".", variantCommand(executable, variant, result.Space, flags, force, args)...).Transparent()
+		result.Seconds = time.Since(started).Round(100 * time.Millisecond).Seconds()
+		result.ExitCode = code
+		if err != nil && code < 0 {
+			result.Error = err.Error()
+		}
+		if err != nil || code != 0 {
+			result.Status = variantFail
+		}
+		matrix.Results = append(matrix.Results, result)
+	}
+	return matrix, config, nil
+}
@@ -100,6 +100,12 @@ func (it *unzipper) Explode(workers int, directory string) error {
 	// This is PoC code, for parallel extraction
 	common.Debug("Exploding:")
 
+	for _, entry := range it.reader.File {
+		if !pathlib.IsWithin(directory, filepath.Join(directory, entry.Name)) {
+			return fmt.Errorf("Refused to extract %q, since it is outside of %q.", entry.Name, directory)
+		}
+	}
+
 	todo := make(CommandChannel)
 	done := make(CompletedChannel)
 
@@ -157,6 +163,9 @@ func (it *unzipper) Extract(directory string) error {
 			continue
 		}
 		target := filepath.Join(directory, entry.Name)
+		if !pathlib.IsWithin(directory, target) {
+			return fmt.Errorf("Refused to extract %q, since it is outside of %q.", entry.Name, directory)
+		}
 		todo := WriteTarget{
 			Source: entry,
 			Target: target,
@@ -0,0 +1,19 @@
+//go:build darwin || linux || !windows
+// +build darwin linux !windows
+
+package pathlib
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// FreeSpace tells how many bytes are available for unprivileged user on
+// volume of given path.
+func FreeSpace(pathname string) (uint64, error) {
+	var stat unix.Statfs_t
+	err := unix.Statfs(pathname, &stat)
+	if err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
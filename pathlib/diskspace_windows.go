@@ -0,0 +1,23 @@
+//go:build windows
+// +build windows
+
+package pathlib
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// FreeSpace tells how many bytes are available for current user on volume
+// of given path.
+func FreeSpace(pathname string) (uint64, error) {
+	var available, total, free uint64
+	name, err := windows.UTF16PtrFromString(pathname)
+	if err != nil {
+		return 0, err
+	}
+	err = windows.GetDiskFreeSpaceEx(name, &available, &total, &free)
+	if err != nil {
+		return 0, err
+	}
+	return available, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -20,6 +21,16 @@ func Abs(path string) (string, error) {
 	return filepath.Clean(fullpath), nil
 }
 
+// IsWithin tells if pathname (after cleaning) stays inside directory, so
+// that "../" parts or absolute names cannot escape it.
+func IsWithin(directory, pathname string) bool {
+	relative, err := filepath.Rel(filepath.Clean(directory), filepath.Clean(pathname))
+	if err != nil {
+		return false
+	}
+	return relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator)) && !filepath.IsAbs(relative)
+}
+
 func IsDir(pathname string) bool {
 	stat, err := os.Stat(pathname)
 	return err == nil && stat.IsDir()
@@ -22,6 +22,18 @@ func TestCanTestIfSomethingIsFile(t *testing.T) {
 	wont.True(pathlib.IsFile("testdata"))
 }
 
+func TestCanTestIfPathStaysWithinDirectory(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	must.True(pathlib.IsWithin("/tmp/job", "/tmp/job"))
+	must.True(pathlib.IsWithin("/tmp/job", "/tmp/job/output/../..job/log.html"))
+	must.True(pathlib.IsWithin("job", "job/output"))
+	wont.True(pathlib.IsWithin("/tmp/job", "/tmp/job/../etc/passwd"))
+	wont.True(pathlib.IsWithin("/tmp/job", "/tmp/jobs"))
+	wont.True(pathlib.IsWithin("/tmp/job", "/etc"))
+	wont.True(pathlib.IsWithin("job", "/tmp/job"))
+}
+
 func TestCanTestIfSomethingIsDirectory(t *testing.T) {
 	must, wont := hamlet.Specifications(t)
 
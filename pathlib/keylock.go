@@ -0,0 +1,27 @@
+package pathlib
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+)
+
+const (
+	keyStripes = 256
+)
+
+// KeyLockFile is lock file used for given key (like blob digest or catalog
+// name) in directory. Keys are spread over fixed number of lock files, so
+// that lock files do not pile up, and unrelated keys rarely wait each other.
+func KeyLockFile(directory, key string) string {
+	hasher := fnv.New32a()
+	hasher.Write([]byte(key))
+	return filepath.Join(directory, fmt.Sprintf("%02x.lck", hasher.Sum32()%keyStripes))
+}
+
+// KeyLocker serializes work on one key between processes (and goroutines,
+// since each call opens lock file separately). Locks on keys must not be
+// nested, since two keys can share same lock file.
+func KeyLocker(directory, key string, trycount int) (Releaser, error) {
+	return Locker(KeyLockFile(directory, key), trycount)
+}
@@ -0,0 +1,55 @@
+package pathlib_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+func TestCanLockByKey(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	folder, err := ioutil.TempDir("", "keylock")
+	must.Nil(err)
+	defer os.RemoveAll(folder)
+
+	digest := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	must.Equal(pathlib.KeyLockFile(folder, digest), pathlib.KeyLockFile(folder, digest))
+	must.Equal(folder, filepath.Dir(pathlib.KeyLockFile(folder, digest)))
+	wont.Equal(pathlib.KeyLockFile(folder, "first"), pathlib.KeyLockFile(folder, "second"))
+
+	active, peak := 0, 0
+	var guard sync.Mutex
+	var group sync.WaitGroup
+	for round := 0; round < 8; round++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			locker, err := pathlib.KeyLocker(folder, digest, 30000)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer locker.Release()
+			guard.Lock()
+			active += 1
+			if active > peak {
+				peak = active
+			}
+			guard.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			guard.Lock()
+			active -= 1
+			guard.Unlock()
+		}()
+	}
+	group.Wait()
+	must.Equal(1, peak)
+	must.True(pathlib.IsFile(pathlib.KeyLockFile(folder, digest)))
+}
@@ -39,3 +39,19 @@ func (it Locked) Release() error {
 	common.Trace("LOCKER: release %v with err: %v", it.Name(), err)
 	return err
 }
+
+// LockHeld tells if some other process is now holding lock on filename,
+// without waiting for it.
+func LockHeld(filename string) bool {
+	file, err := os.OpenFile(filename, os.O_WRONLY, 0o600)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	err = syscall.Flock(int(file.Fd()), int(syscall.LOCK_EX|syscall.LOCK_NB))
+	if err != nil {
+		return err == syscall.EWOULDBLOCK
+	}
+	syscall.Flock(int(file.Fd()), int(syscall.LOCK_UN))
+	return false
+}
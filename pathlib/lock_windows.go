@@ -70,6 +70,7 @@ func Locker(filename string, trycount int) (Releaser, error) {
 }
 
 func (it Locked) Release() error {
+	defer it.Close()
 	success, err := trylock(unlockFile, it)
 	common.Trace("LOCKER: release %v success: %v with err: %v", it.Name(), success, err)
 	return err
@@ -91,3 +92,18 @@ func trylock(tool uintptr, identity filehandle) (bool, error) {
 	}
 	return true, nil
 }
+
+// LockHeld tells if some other process is now holding lock on filename,
+// without waiting for it.
+func LockHeld(filename string) bool {
+	file, err := os.OpenFile(filename, os.O_WRONLY, 0o600)
+	if err != nil {
+		return !os.IsNotExist(err)
+	}
+	defer file.Close()
+	success, _ := trylock(lockFile, file)
+	if success {
+		trylock(unlockFile, file)
+	}
+	return !success
+}
@@ -0,0 +1,28 @@
+package pathlib
+
+// Watched remembers content digests of files, so that changes in them can
+// be noticed by polling. Missing file has empty digest.
+type Watched map[string]string
+
+// Watch starts watching given files from their current content.
+func Watch(filenames ...string) Watched {
+	result := make(Watched)
+	for _, filename := range filenames {
+		result[filename], _ = Sha256(filename)
+	}
+	return result
+}
+
+// Changed returns files whose content has changed (or which have appeared
+// or disappeared) since last call, and remembers their new content.
+func (it Watched) Changed() []string {
+	result := []string{}
+	for filename, previous := range it {
+		current, _ := Sha256(filename)
+		if current != previous {
+			it[filename] = current
+			result = append(result, filename)
+		}
+	}
+	return result
+}
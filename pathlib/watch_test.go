@@ -0,0 +1,36 @@
+package pathlib_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+func TestWatchedFilesTellWhenContentChanges(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	folder := t.TempDir()
+	condafile := filepath.Join(folder, "conda.yaml")
+	robotfile := filepath.Join(folder, "robot.yaml")
+	must_be.Nil(ioutil.WriteFile(condafile, []byte("channels: []"), 0o644))
+
+	watched := pathlib.Watch(condafile, robotfile)
+	must_be.Equal(0, len(watched.Changed()))
+
+	must_be.Nil(ioutil.WriteFile(condafile, []byte("channels: []"), 0o644))
+	must_be.Equal(0, len(watched.Changed()))
+
+	must_be.Nil(ioutil.WriteFile(condafile, []byte("channels: [conda-forge]"), 0o644))
+	must_be.Equal([]string{condafile}, watched.Changed())
+	must_be.Equal(0, len(watched.Changed()))
+
+	must_be.Nil(ioutil.WriteFile(robotfile, []byte("tasks: {}"), 0o644))
+	must_be.Equal([]string{robotfile}, watched.Changed())
+
+	must_be.Nil(os.Remove(robotfile))
+	must_be.Equal([]string{robotfile}, watched.Changed())
+}
@@ -0,0 +1,153 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/shlex"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+	"github.com/robocorp/rcc/shell"
+)
+
+const (
+	PreBuild  = `pre-build`
+	PostBuild = `post-build`
+	PreRun    = `pre-run`
+	PostRun   = `post-run`
+)
+
+// Stages are known lifecycle stages, in order they happen.
+var Stages = []string{PreBuild, PostBuild, PreRun, PostRun}
+
+type Context map[string]interface{}
+
+// RobotHook is lifecycle hook from robot.yaml, ready to run. Environment nil
+// means same environment that rcc itself has.
+type RobotHook struct {
+	Task        []string
+	Directory   string
+	Environment []string
+}
+
+// IsStage tells if stage is one of known lifecycle stages.
+func IsStage(stage string) bool {
+	for _, known := range Stages {
+		if stage == known {
+			return true
+		}
+	}
+	return false
+}
+
+// NewRobotHook splits command of robot.yaml hook into task to run.
+func NewRobotHook(command, directory string, environment []string) (*RobotHook, error) {
+	task, err := shlex.Split(command)
+	if err != nil || len(task) == 0 {
+		return nil, fmt.Errorf("Bad hook %q, reason: %v", command, err)
+	}
+	return &RobotHook{Task: task, Directory: directory, Environment: environment}, nil
+}
+
+type hookPayload struct {
+	Hook       string  `json:"hook"`
+	Version    string  `json:"version"`
+	Controller string  `json:"controller"`
+	Space      string  `json:"space"`
+	Context    Context `json:"context"`
+}
+
+func hookEnvironment(base []string, stage string, context Context) []string {
+	result := append(base, fmt.Sprintf("RCC_HOOK=%s", stage))
+	keys := make([]string, 0, len(context))
+	for key, _ := range context {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+		result = append(result, fmt.Sprintf("RCC_HOOK_%s=%v", name, context[key]))
+	}
+	return result
+}
+
+// RunHooks executes all hooks configured in settings for given lifecycle
+// stage. Context is given both as JSON in stdin and as RCC_HOOK_* variables.
+// First failing hook stops processing, and its failure is returned.
+func RunHooks(stage string, context Context) error {
+	hooks := settings.Global.Hooks(stage)
+	if len(hooks) == 0 {
+		return nil
+	}
+	common.Timeline("%s hooks start", stage)
+	defer common.Timeline("%s hooks done", stage)
+	payload, err := hookPayloadFor(stage, context)
+	if err != nil {
+		return err
+	}
+	environment := hookEnvironment(environment(), stage, context)
+	directory, _ := os.Getwd()
+	for _, hook := range hooks {
+		task, err := shlex.Split(hook)
+		if err != nil || len(task) == 0 {
+			return fmt.Errorf("Bad %s hook %q, reason: %v", stage, hook, err)
+		}
+		err = runHook(stage, hook, task, directory, environment, payload)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunRobotHooks executes hooks from robot.yaml for given lifecycle stage,
+// same way as RunHooks does. Hooks with environment get RCC_* and RCC_HOOK_*
+// variables added on top of it.
+func RunRobotHooks(stage string, hooks []*RobotHook, context Context) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+	common.Timeline("%s robot hooks start", stage)
+	defer common.Timeline("%s robot hooks done", stage)
+	payload, err := hookPayloadFor(stage, context)
+	if err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		base := environment()
+		if hook.Environment != nil {
+			base = append(append([]string{}, hook.Environment...), rccVariables()...)
+		}
+		command := strings.Join(hook.Task, " ")
+		err = runHook(stage, command, hook.Task, hook.Directory, hookEnvironment(base, stage, context), payload)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hookPayloadFor(stage string, context Context) ([]byte, error) {
+	return json.Marshal(&hookPayload{
+		Hook:       stage,
+		Version:    common.Version,
+		Controller: common.ControllerIdentity(),
+		Space:      common.HolotreeSpace,
+		Context:    context,
+	})
+}
+
+func runHook(stage, hook string, task []string, directory string, environment []string, payload []byte) error {
+	common.Debug("Running %s hook %q.", stage, hook)
+	code, err := shell.New(environment, directory, task...).StderrOnly().Fed(payload)
+	if code != 0 {
+		return fmt.Errorf("The %s hook %q failed with exit code %d.", stage, hook, code)
+	}
+	if err != nil {
+		return fmt.Errorf("The %s hook %q failed, reason: %v", stage, hook, err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package plugins
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/shell"
+)
+
+const (
+	Prefix = `rcc-`
+)
+
+// Find locates external "rcc-<name>" plugin executable from PATH.
+func Find(name string) (string, bool) {
+	if len(name) == 0 || strings.HasPrefix(name, "-") || strings.ContainsAny(name, `/\`) {
+		return "", false
+	}
+	return pathlib.TargetPath().Which(Prefix+name, conda.FileExtensions)
+}
+
+// Discover lists names (without prefix) of all plugins visible in PATH.
+func Discover() []string {
+	seen := make(map[string]bool)
+	for _, directory := range pathlib.TargetPath() {
+		found, err := filepath.Glob(filepath.Join(directory, Prefix+"*"))
+		if err != nil {
+			continue
+		}
+		for _, fullpath := range found {
+			if !pathlib.IsFile(fullpath) {
+				continue
+			}
+			name := strings.TrimPrefix(filepath.Base(fullpath), Prefix)
+			for _, extension := range conda.FileExtensions {
+				name = strings.TrimSuffix(name, extension)
+			}
+			if len(name) > 0 {
+				seen[name] = true
+			}
+		}
+	}
+	result := make([]string, 0, len(seen))
+	for name, _ := range seen {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func environment() []string {
+	return append(os.Environ(), rccVariables()...)
+}
+
+func rccVariables() []string {
+	executable, err := os.Executable()
+	if err != nil {
+		executable = os.Args[0]
+	}
+	return []string{
+		fmt.Sprintf("RCC_EXE=%s", executable),
+		fmt.Sprintf("RCC_VERSION=%s", common.Version),
+		fmt.Sprintf("ROBOCORP_HOME=%s", common.RobocorpHome()),
+	}
+}
+
+// Run executes plugin with given arguments, connected to terminal, and
+// returns its exit code.
+func Run(fullpath string, arguments []string) (int, error) {
+	common.Debug("Running plugin %q with arguments %q.", fullpath, arguments)
+	task := append([]string{fullpath}, arguments...)
+	return shell.New(environment(), ".", task...).Transparent()
+}
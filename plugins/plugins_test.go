@@ -0,0 +1,66 @@
+package plugins_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/plugins"
+)
+
+func TestCanFindPluginsFromPath(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	folder, err := ioutil.TempDir("", "plugins")
+	must.Nil(err)
+	defer os.RemoveAll(folder)
+	must.Nil(ioutil.WriteFile(filepath.Join(folder, "rcc-policy"), []byte("#!/bin/sh\nexit 0\n"), 0o755))
+	must.Nil(ioutil.WriteFile(filepath.Join(folder, "other-tool"), []byte("#!/bin/sh\nexit 0\n"), 0o755))
+
+	original := os.Getenv("PATH")
+	defer os.Setenv("PATH", original)
+	os.Setenv("PATH", folder)
+
+	fullpath, ok := plugins.Find("policy")
+	must.True(ok)
+	must.Equal(filepath.Join(folder, "rcc-policy"), fullpath)
+
+	_, ok = plugins.Find("missing")
+	wont.True(ok)
+	_, ok = plugins.Find("--policy")
+	wont.True(ok)
+
+	must.Equal([]string{"policy"}, plugins.Discover())
+}
+
+func TestRobotHooksGetContextAndEnvironment(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	folder := t.TempDir()
+	script := "#!/bin/sh\necho \"$RCC_HOOK $RCC_HOOK_TASK $MARKER\" > \"$1\"\ncat >> \"$1\"\n"
+	must.Nil(ioutil.WriteFile(filepath.Join(folder, "hook.sh"), []byte(script), 0o755))
+
+	inside, err := plugins.NewRobotHook("./hook.sh inside.txt", folder, []string{"MARKER=inside"})
+	must.Nil(err)
+	outside, err := plugins.NewRobotHook("./hook.sh outside.txt", folder, nil)
+	must.Nil(err)
+	_, err = plugins.NewRobotHook("", folder, nil)
+	wont.Nil(err)
+
+	context := plugins.Context{"task": "Test"}
+	must.Nil(plugins.RunRobotHooks(plugins.PreRun, []*plugins.RobotHook{inside, outside}, context))
+
+	content, err := ioutil.ReadFile(filepath.Join(folder, "inside.txt"))
+	must.Nil(err)
+	must.True(strings.HasPrefix(string(content), "pre-run Test inside\n{\"hook\":\"pre-run\""))
+	content, err = ioutil.ReadFile(filepath.Join(folder, "outside.txt"))
+	must.Nil(err)
+	must.True(strings.HasPrefix(string(content), "pre-run Test \n"))
+
+	failing, err := plugins.NewRobotHook("false", folder, nil)
+	must.Nil(err)
+	wont.Nil(plugins.RunRobotHooks(plugins.PostRun, []*plugins.RobotHook{failing}, context))
+}
@@ -2,6 +2,8 @@ package pretty
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/robocorp/rcc/common"
 )
@@ -32,3 +34,18 @@ func Guard(truth bool, code int, format string, rest ...interface{}) {
 		Exit(code, format, rest...)
 	}
 }
+
+// Progress shows progress bar line of long running operation.
+func Progress(operation string, percent float64, remaining int64, eta time.Duration, finished bool) {
+	width := 30
+	filled := int(percent * float64(width) / 100.0)
+	if filled > width {
+		filled = width
+	}
+	bar := strings.Repeat("#", filled) + strings.Repeat(".", width-filled)
+	if finished {
+		common.Log("%sProgress: %-7s [%s] %5.1f%% done.%s", Cyan, operation, bar, percent, Reset)
+		return
+	}
+	common.Log("%sProgress: %-7s [%s] %5.1f%% %d files remaining, ETA %s%s", Cyan, operation, bar, percent, remaining, eta.Round(time.Second), Reset)
+}
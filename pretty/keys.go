@@ -0,0 +1,65 @@
+package pretty
+
+import (
+	"bufio"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// Keys which are not printable, as returned by ReadKey. Other keys are
+// returned as runes, like '\t' for tab and '\r' for enter.
+const (
+	KeyUp rune = 0xe000 + iota
+	KeyDown
+	KeyRight
+	KeyLeft
+	KeyEscape
+
+	KeyInterrupt rune = 3
+)
+
+var arrowKeys = map[rune]rune{
+	'A': KeyUp,
+	'B': KeyDown,
+	'C': KeyRight,
+	'D': KeyLeft,
+}
+
+// RawTerminal puts terminal into raw mode, where keys are read one at time
+// and not echoed. Returned function restores terminal back.
+func RawTerminal() (func(), error) {
+	handle := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(handle)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		term.Restore(handle, state)
+	}, nil
+}
+
+// ReadKey reads one key press from raw terminal, converting arrow key
+// escape sequences into KeyUp, KeyDown, KeyRight, and KeyLeft.
+func ReadKey(reader *bufio.Reader) (rune, error) {
+	key, _, err := reader.ReadRune()
+	if err != nil || key != 0x1b {
+		return key, err
+	}
+	if reader.Buffered() == 0 {
+		return KeyEscape, nil
+	}
+	kind, _, err := reader.ReadRune()
+	if err != nil || (kind != '[' && kind != 'O') {
+		return KeyEscape, err
+	}
+	final, _, err := reader.ReadRune()
+	if err != nil {
+		return KeyEscape, err
+	}
+	arrow, ok := arrowKeys[final]
+	if !ok {
+		return KeyEscape, nil
+	}
+	return arrow, nil
+}
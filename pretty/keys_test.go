@@ -0,0 +1,23 @@
+package pretty_test
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/pretty"
+)
+
+func TestReadingKeysFromTerminal(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	reader := bufio.NewReader(strings.NewReader("\x1b[Aj\x1b[B\x1bOC\x1b[Z\t\x1b"))
+	for _, expected := range []rune{pretty.KeyUp, 'j', pretty.KeyDown, pretty.KeyRight, pretty.KeyEscape, '\t', pretty.KeyEscape} {
+		actual, err := pretty.ReadKey(reader)
+		must_be.Nil(err)
+		must_be.Equal(expected, actual)
+	}
+	_, err := pretty.ReadKey(reader)
+	wont_be.Nil(err)
+}
@@ -0,0 +1,66 @@
+package pretty
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+)
+
+const (
+	PhaseSolve  = `solve`
+	PhaseFetch  = `fetch`
+	PhaseLink   = `link`
+	PhasePip    = `pip`
+	PhaseRecord = `record`
+)
+
+// Phase is named phase of environment creation. Its start and end are shown
+// in log, its duration goes into timeline, and with --json-progress both
+// are also streamed as JSON events.
+type Phase struct {
+	sync.Mutex
+	name    string
+	started time.Time
+	elapsed time.Duration
+	ended   bool
+}
+
+// StartPhase begins named phase; returned phase must be ended with Done.
+func StartPhase(name, form string, details ...interface{}) *Phase {
+	message := fmt.Sprintf(form, details...)
+	common.Log("%s####  Phase: %-6s started  %s%s", Cyan, name, message, Reset)
+	common.Timeline("phase %s started", name)
+	common.EmitProgress(&common.ProgressEvent{
+		Event:   common.EventPhaseStart,
+		Phase:   name,
+		Message: message,
+	})
+	return &Phase{name: name, started: time.Now()}
+}
+
+func (it *Phase) Name() string {
+	return it.name
+}
+
+// Done ends phase, and it is safe to call multiple times; only first call
+// counts. Elapsed time of phase is returned.
+func (it *Phase) Done() time.Duration {
+	it.Lock()
+	defer it.Unlock()
+	if it.ended {
+		return it.elapsed
+	}
+	it.ended = true
+	it.elapsed = time.Since(it.started).Round(time.Millisecond)
+	seconds := it.elapsed.Seconds()
+	common.Log("%s####  Phase: %-6s done in %.3fs%s", Cyan, it.name, seconds, Reset)
+	common.Timeline("phase %s done in %.3fs", it.name, seconds)
+	common.EmitProgress(&common.ProgressEvent{
+		Event:   common.EventPhaseDone,
+		Phase:   it.name,
+		Elapsed: seconds,
+	})
+	return it.elapsed
+}
@@ -0,0 +1,120 @@
+package remote
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/operations"
+	"github.com/robocorp/rcc/robot"
+	"github.com/robocorp/rcc/settings"
+)
+
+type client struct {
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+func newClient(address string) *client {
+	endpoint := strings.TrimRight(strings.TrimSpace(address), "/")
+	if !strings.Contains(endpoint, "://") {
+		endpoint = fmt.Sprintf("http://%s", endpoint)
+	}
+	return &client{
+		endpoint: endpoint,
+		token:    Token(),
+		client:   &http.Client{Transport: settings.Global.ConfiguredHttpTransport()},
+	}
+}
+
+func (it *client) request(method, path string, body io.Reader) (*http.Response, error) {
+	request, err := http.NewRequest(method, it.endpoint+path, body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("User-Agent", common.UserAgent())
+	if len(it.token) > 0 {
+		request.Header.Set("Authorization", "Bearer "+it.token)
+	}
+	response, err := it.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		reason, _ := ioutil.ReadAll(response.Body)
+		return nil, fmt.Errorf("remote %s responded %d: %s", it.endpoint, response.StatusCode, strings.TrimSpace(string(reason)))
+	}
+	return response, nil
+}
+
+// Run ships robot to the remote rcc daemon, streams its output to stdout,
+// brings artifacts back into local artifact directory and returns remote
+// exit code of the task.
+func Run(address, robotfile, task, space string, force bool, ignores []string) (int, error) {
+	config, err := robot.LoadRobotYaml(robotfile, false)
+	if err != nil {
+		return -1, err
+	}
+	zipfile := filepath.Join(os.TempDir(), fmt.Sprintf("remoterun%x.zip", common.When))
+	defer os.Remove(zipfile)
+	err = operations.Zip(filepath.Dir(robotfile), zipfile, ignores)
+	if err != nil {
+		return -1, err
+	}
+	source, err := os.Open(zipfile)
+	if err != nil {
+		return -1, err
+	}
+	defer source.Close()
+
+	query := url.Values{}
+	query.Set("task", task)
+	query.Set("space", space)
+	query.Set("force", strconv.FormatBool(force))
+	remote := newClient(address)
+	common.Log("Running task remotely at %s ...", remote.endpoint)
+	response, err := remote.request(http.MethodPost, runPath+"?"+query.Encode(), source)
+	if err != nil {
+		return -1, err
+	}
+	defer response.Body.Close()
+	_, err = io.Copy(os.Stdout, response.Body)
+	if err != nil {
+		return -1, err
+	}
+	code, err := strconv.Atoi(response.Trailer.Get(exitTrailer))
+	if err != nil {
+		return -1, fmt.Errorf("remote run ended without exit code, reason: %v", err)
+	}
+	job := response.Header.Get(jobHeader)
+	err = remote.fetchArtifacts(job, config.ArtifactDirectory())
+	if err != nil {
+		return code, err
+	}
+	return code, nil
+}
+
+func (it *client) fetchArtifacts(job, directory string) error {
+	response, err := it.request(http.MethodGet, artifactsPath+job, nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	zipfile := filepath.Join(os.TempDir(), fmt.Sprintf("remoteartifacts%x.zip", common.When))
+	defer os.Remove(zipfile)
+	err = receiveFile(response.Body, filepath.Dir(zipfile), zipfile)
+	if err != nil {
+		return err
+	}
+	common.Log("Remote artifacts into %q.", directory)
+	return operations.Unzip(directory, zipfile, true, true)
+}
@@ -0,0 +1,343 @@
+package remote
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/journal"
+	"github.com/robocorp/rcc/operations"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/robot"
+	"github.com/robocorp/rcc/shell"
+)
+
+const (
+	REMOTE_TOKEN_VARIABLE = `RCC_REMOTE_TOKEN`
+
+	runPath       = `/run`
+	artifactsPath = `/artifacts/`
+	jobHeader     = `X-Rcc-Job`
+	exitTrailer   = `X-Rcc-Exit-Code`
+	artifactsZip  = `artifacts.zip`
+
+	// jobExpiry is how long finished job waits for its artifacts to be
+	// fetched, before its directory is removed anyway.
+	jobExpiry = 1 * time.Hour
+)
+
+var (
+	// jobSecrets are daemon's own secrets, which are never passed to jobs.
+	jobSecrets = []string{REMOTE_TOKEN_VARIABLE, htfs.HOLOTREE_TOKEN_VARIABLE}
+)
+
+type daemon struct {
+	sync.Mutex
+	token      string
+	workarea   string
+	executable string
+	active     map[string]bool
+}
+
+type flushingWriter struct {
+	sync.Mutex
+	sink    io.Writer
+	flusher http.Flusher
+}
+
+func (it *flushingWriter) Write(blob []byte) (int, error) {
+	it.Lock()
+	defer it.Unlock()
+	size, err := it.sink.Write(blob)
+	if it.flusher != nil {
+		it.flusher.Flush()
+	}
+	return size, err
+}
+
+func Token() string {
+	return strings.TrimSpace(os.Getenv(REMOTE_TOKEN_VARIABLE))
+}
+
+// jobEnvironment is daemon environment without its secrets.
+func jobEnvironment() []string {
+	environment := os.Environ()
+	result := make([]string, 0, len(environment))
+	for _, entry := range environment {
+		name := strings.SplitN(entry, "=", 2)[0]
+		secret := false
+		for _, variable := range jobSecrets {
+			secret = secret || strings.EqualFold(name, variable)
+		}
+		if !secret {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+func newDaemon(token, workarea, executable string) *daemon {
+	return &daemon{
+		token:      token,
+		workarea:   workarea,
+		executable: executable,
+		active:     make(map[string]bool),
+	}
+}
+
+func (it *daemon) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(runPath, it.run)
+	mux.HandleFunc(artifactsPath, it.artifacts)
+	return mux
+}
+
+// NewDaemon gives remote execution handler, which runs robots using given
+// rcc executable, in job directories under workarea.
+func NewDaemon(token, workarea, executable string) http.Handler {
+	return newDaemon(token, workarea, executable).handler()
+}
+
+// Serve runs remote execution daemon. Without token, it refuses to start,
+// unless insecure is explicitly requested.
+func Serve(address string, insecure bool) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	it := newDaemon(Token(), filepath.Join(common.RobocorpTempRoot(), "remote"), executable)
+	if len(it.token) == 0 && !insecure {
+		return fmt.Errorf("Refusing to serve remote execution without %s (use --insecure to allow anybody reaching %q to run robots here).", REMOTE_TOKEN_VARIABLE, address)
+	}
+	if len(it.token) == 0 {
+		common.Log("WARNING: no %s set, so anybody reaching %q can run robots here!", REMOTE_TOKEN_VARIABLE, address)
+	}
+	err = pathlib.EnsureDirectoryExists(it.workarea)
+	if err != nil {
+		return err
+	}
+	it.expire()
+	go func() {
+		for range time.Tick(jobExpiry / 4) {
+			it.expire()
+		}
+	}()
+	journal.Post("daemon", "started", "remote execution daemon listening at %q", address)
+	common.Log("Remote execution daemon listening at %q.", address)
+	server := &http.Server{Addr: address, Handler: it.handler()}
+	go func() {
+		<-common.Context().Done()
+		common.Log("Remote execution daemon stopping, waiting running jobs to finish.")
+		server.Shutdown(context.Background())
+	}()
+	err = server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		journal.Post("daemon", "stopped", "remote execution daemon at %q stopped", address)
+		return nil
+	}
+	return err
+}
+
+func (it *daemon) started(job string) {
+	it.Lock()
+	defer it.Unlock()
+	it.active[job] = true
+}
+
+func (it *daemon) finished(job string) {
+	it.Lock()
+	defer it.Unlock()
+	delete(it.active, job)
+}
+
+// expire removes directories of jobs, which are not running and whose
+// artifacts were not fetched within jobExpiry.
+func (it *daemon) expire() {
+	it.Lock()
+	defer it.Unlock()
+	entries, err := ioutil.ReadDir(it.workarea)
+	if err != nil {
+		return
+	}
+	deadline := time.Now().Add(-jobExpiry)
+	for _, entry := range entries {
+		if it.active[entry.Name()] || entry.ModTime().After(deadline) {
+			continue
+		}
+		common.Debug("Remote job %s expired, removing it.", entry.Name())
+		os.RemoveAll(filepath.Join(it.workarea, entry.Name()))
+	}
+}
+
+func (it *daemon) authorized(response http.ResponseWriter, request *http.Request) bool {
+	if len(it.token) == 0 {
+		return true
+	}
+	expected := []byte("Bearer " + it.token)
+	if subtle.ConstantTimeCompare([]byte(request.Header.Get("Authorization")), expected) == 1 {
+		return true
+	}
+	http.Error(response, "unauthorized", http.StatusUnauthorized)
+	return false
+}
+
+func (it *daemon) run(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(response, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if !it.authorized(response, request) {
+		return
+	}
+	it.expire()
+	job := fmt.Sprintf("%x", time.Now().UnixNano())
+	it.started(job)
+	defer it.finished(job)
+	jobdir := filepath.Join(it.workarea, job)
+	robotdir := filepath.Join(jobdir, "robot")
+	robotzip := filepath.Join(jobdir, "robot.zip")
+	err := receiveFile(request.Body, jobdir, robotzip)
+	if err == nil {
+		err = operations.Unzip(robotdir, robotzip, false, true)
+	}
+	if err != nil {
+		os.RemoveAll(jobdir)
+		http.Error(response, err.Error(), http.StatusBadRequest)
+		return
+	}
+	robotfile := robot.DetectConfigurationName(robotdir)
+	config, err := robot.LoadRobotYaml(robotfile, false)
+	if err != nil {
+		os.RemoveAll(jobdir)
+		http.Error(response, err.Error(), http.StatusBadRequest)
+		return
+	}
+	workspace, err := pathlib.Abs(robotdir)
+	if err == nil && !pathlib.IsWithin(workspace, config.ArtifactDirectory()) {
+		err = fmt.Errorf("artifacts directory %q is outside of job workspace", config.ArtifactDirectory())
+	}
+	if err != nil {
+		os.RemoveAll(jobdir)
+		http.Error(response, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query := request.URL.Query()
+	space := query.Get("space")
+	if len(space) == 0 {
+		space = "remote"
+	}
+	command := []string{it.executable, "run", "--robot", robotfile, "--space", space, "--controller", "rcc.remote"}
+	if task := query.Get("task"); len(task) > 0 {
+		command = append(command, "--task", task)
+	}
+	if query.Get("force") == "true" {
+		command = append(command, "--force")
+	}
+	common.Log("Remote job %s: running %q from %s.", job, query.Get("task"), request.RemoteAddr)
+	journal.Post("daemon", "remote-run", "job %s task %q from %s", job, query.Get("task"), request.RemoteAddr)
+
+	response.Header().Set(jobHeader, job)
+	response.Header().Set("Trailer", exitTrailer)
+	response.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	response.WriteHeader(http.StatusOK)
+	flusher, _ := response.(http.Flusher)
+	stream := &flushingWriter{sink: response, flusher: flusher}
+	code, err := shell.New(jobEnvironment(), robotdir, command...).Tracked(stream, false)
+	if err != nil && code < 0 {
+		fmt.Fprintf(stream, "rcc daemon: job %s failed: %v\n", job, err)
+	}
+	err = zipDirectory(config.ArtifactDirectory(), filepath.Join(jobdir, artifactsZip))
+	if err != nil {
+		fmt.Fprintf(stream, "rcc daemon: could not pack artifacts: %v\n", err)
+	}
+	os.RemoveAll(robotdir)
+	os.Remove(robotzip)
+	os.Chtimes(jobdir, time.Now(), time.Now())
+	common.Log("Remote job %s: finished with exit code %d.", job, code)
+	response.Header().Set(exitTrailer, strconv.Itoa(code))
+}
+
+func (it *daemon) artifacts(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(response, "only GET is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if !it.authorized(response, request) {
+		return
+	}
+	job := strings.TrimPrefix(request.URL.Path, artifactsPath)
+	if len(job) == 0 || strings.ContainsAny(job, `/\.`) {
+		http.Error(response, "invalid job", http.StatusBadRequest)
+		return
+	}
+	jobdir := filepath.Join(it.workarea, job)
+	filename := filepath.Join(jobdir, artifactsZip)
+	if !pathlib.IsFile(filename) {
+		http.NotFound(response, request)
+		return
+	}
+	defer os.RemoveAll(jobdir)
+	response.Header().Set("Content-Type", "application/zip")
+	http.ServeFile(response, request, filename)
+}
+
+func receiveFile(source io.Reader, directory, filename string) error {
+	err := pathlib.EnsureDirectoryExists(directory)
+	if err != nil {
+		return err
+	}
+	sink, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer sink.Close()
+	_, err = io.Copy(sink, source)
+	return err
+}
+
+func zipDirectory(directory, filename string) error {
+	sink, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer sink.Close()
+	archive := zip.NewWriter(sink)
+	defer archive.Close()
+	if !pathlib.IsDir(directory) {
+		return nil
+	}
+	var failure error
+	pathlib.Walk(directory, pathlib.IgnoreNothing, func(fullpath, relativepath string, details os.FileInfo) {
+		if failure != nil {
+			return
+		}
+		failure = addToZip(archive, fullpath, filepath.ToSlash(relativepath))
+	})
+	return failure
+}
+
+func addToZip(archive *zip.Writer, fullpath, name string) error {
+	source, err := os.Open(fullpath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	sink, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(sink, source)
+	return err
+}
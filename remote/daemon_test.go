@@ -0,0 +1,140 @@
+package remote_test
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/remote"
+)
+
+const (
+	robotYaml = "tasks:\n  Remote:\n    shell: echo remote\nartifactsDir: output\n"
+	fakeRcc   = "#!/bin/sh\nmkdir -p output\necho \"$@\" \"token=$RCC_REMOTE_TOKEN\" > output/result.txt\necho ran remotely\nexit 3\n"
+)
+
+func TestDaemonRunsJobsOnlyForAuthorizedClients(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake rcc executable is shell script")
+	}
+	must, wont := hamlet.Specifications(t)
+
+	folder := t.TempDir()
+	original := os.Getenv(remote.REMOTE_TOKEN_VARIABLE)
+	defer os.Setenv(remote.REMOTE_TOKEN_VARIABLE, original)
+
+	executable := filepath.Join(folder, "rcc")
+	must.Nil(ioutil.WriteFile(executable, []byte(fakeRcc), 0o755))
+	workarea := filepath.Join(folder, "workarea")
+	stale := filepath.Join(workarea, "stale")
+	must.Nil(os.MkdirAll(stale, 0o755))
+	old := time.Now().Add(-2 * time.Hour)
+	must.Nil(os.Chtimes(stale, old, old))
+	robotdir := filepath.Join(folder, "robot")
+	must.Nil(os.MkdirAll(robotdir, 0o755))
+	robotfile := filepath.Join(robotdir, "robot.yaml")
+	must.Nil(ioutil.WriteFile(robotfile, []byte(robotYaml), 0o644))
+
+	server := httptest.NewServer(remote.NewDaemon("secret", workarea, executable))
+	defer server.Close()
+
+	response, err := http.Post(server.URL+"/run", "application/zip", strings.NewReader("robot"))
+	must.Nil(err)
+	response.Body.Close()
+	must.Equal(http.StatusUnauthorized, response.StatusCode)
+
+	request, err := http.NewRequest(http.MethodGet, server.URL+"/artifacts/job", nil)
+	must.Nil(err)
+	request.Header.Set("Authorization", "Bearer secreT")
+	response, err = http.DefaultClient.Do(request)
+	must.Nil(err)
+	response.Body.Close()
+	must.Equal(http.StatusUnauthorized, response.StatusCode)
+
+	os.Setenv(remote.REMOTE_TOKEN_VARIABLE, "wrong")
+	_, err = remote.Run(server.URL, robotfile, "Remote", "", false, nil)
+	wont.Nil(err)
+	_, err = os.Stat(filepath.Join(robotdir, "output", "result.txt"))
+	wont.Nil(err)
+
+	os.Setenv(remote.REMOTE_TOKEN_VARIABLE, "secret")
+	code, err := remote.Run(server.URL, robotfile, "Remote", "", false, nil)
+	must.Nil(err)
+	must.Equal(3, code)
+	result, err := ioutil.ReadFile(filepath.Join(robotdir, "output", "result.txt"))
+	must.Nil(err)
+	must.True(strings.HasPrefix(string(result), "run --robot "))
+	must.True(strings.Contains(string(result), "--task Remote"))
+	must.True(strings.HasSuffix(strings.TrimSpace(string(result)), "token="))
+
+	leftovers, err := ioutil.ReadDir(workarea)
+	must.Nil(err)
+	must.Equal(0, len(leftovers))
+}
+
+func TestDaemonRefusesArtifactsOutsideOfJobWorkspace(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake rcc executable is shell script")
+	}
+	must, wont := hamlet.Specifications(t)
+
+	folder := t.TempDir()
+	original := os.Getenv(remote.REMOTE_TOKEN_VARIABLE)
+	defer os.Setenv(remote.REMOTE_TOKEN_VARIABLE, original)
+	os.Setenv(remote.REMOTE_TOKEN_VARIABLE, "secret")
+
+	executable := filepath.Join(folder, "rcc")
+	must.Nil(ioutil.WriteFile(executable, []byte(fakeRcc), 0o755))
+	workarea := filepath.Join(folder, "workarea")
+	robotdir := filepath.Join(folder, "robot")
+	must.Nil(os.MkdirAll(robotdir, 0o755))
+	robotfile := filepath.Join(robotdir, "robot.yaml")
+	escaping := strings.Replace(robotYaml, "artifactsDir: output", "artifactsDir: ../../..", 1)
+	must.Nil(ioutil.WriteFile(robotfile, []byte(escaping), 0o644))
+
+	server := httptest.NewServer(remote.NewDaemon("secret", workarea, executable))
+	defer server.Close()
+
+	_, err := remote.Run(server.URL, robotfile, "Remote", "", false, nil)
+	wont.Nil(err)
+	must.True(strings.Contains(err.Error(), "outside of job workspace"))
+}
+
+func TestClientRefusesArtifactsEscapingArtifactDirectory(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	folder := t.TempDir()
+	robotdir := filepath.Join(folder, "robot")
+	must.Nil(os.MkdirAll(robotdir, 0o755))
+	robotfile := filepath.Join(robotdir, "robot.yaml")
+	must.Nil(ioutil.WriteFile(robotfile, []byte(robotYaml), 0o644))
+
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/run" {
+			response.Header().Set("X-Rcc-Job", "evil")
+			response.Header().Set("Trailer", "X-Rcc-Exit-Code")
+			response.WriteHeader(http.StatusOK)
+			response.Header().Set("X-Rcc-Exit-Code", "0")
+			return
+		}
+		archive := zip.NewWriter(response)
+		sink, _ := archive.Create("../../escaped.txt")
+		sink.Write([]byte("gotcha"))
+		archive.Close()
+	}))
+	defer server.Close()
+
+	_, err := remote.Run(server.URL, robotfile, "Remote", "", false, nil)
+	wont.Nil(err)
+	wont.True(pathlib.Exists(filepath.Join(folder, "escaped.txt")))
+	wont.True(pathlib.Exists(filepath.Join(robotdir, "escaped.txt")))
+}
@@ -0,0 +1,118 @@
+package robot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/robocorp/rcc/yamledit"
+)
+
+var (
+	editableLists = map[string]bool{
+		"PATH":               true,
+		"PYTHONPATH":         true,
+		"ignoreFiles":        true,
+		"environmentConfigs": true,
+	}
+	editableScalars = map[string]bool{
+		"artifactsDir":    true,
+		"condaConfigFile": true,
+	}
+)
+
+// Edits are "key=value" changes into toplevel keys of robot.yaml. Add and
+// Remove operate on lists (like PATH), and Set on scalars (like artifactsDir).
+type Edits struct {
+	Dryrun bool
+	Set    []string
+	Add    []string
+	Remove []string
+}
+
+func editableKeys(keys map[string]bool) string {
+	result := make([]string, 0, len(keys))
+	for key := range keys {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return strings.Join(result, ", ")
+}
+
+func splitEdit(edit string, keys map[string]bool) (string, string, error) {
+	parts := strings.SplitN(edit, "=", 2)
+	if len(parts) != 2 || !keys[parts[0]] {
+		return "", "", fmt.Errorf("Edit %q should be in form key=value, where key is one of: %s.", edit, editableKeys(keys))
+	}
+	return parts[0], parts[1], nil
+}
+
+// EditRobotYaml applies edits into robot.yaml in place, keeping comments,
+// quoting and formatting of untouched parts, and returns resulting content.
+func EditRobotYaml(filename string, edits *Edits) (string, error) {
+	document, err := yamledit.Load(filename)
+	if err != nil {
+		return "", err
+	}
+	for _, edit := range edits.Set {
+		key, value, err := splitEdit(edit, editableScalars)
+		if err != nil {
+			return "", err
+		}
+		err = document.SetScalar(key, value)
+		if err != nil {
+			return "", err
+		}
+	}
+	for _, edit := range edits.Remove {
+		key, value, err := splitEdit(edit, editableLists)
+		if err != nil {
+			return "", err
+		}
+		items, err := document.Sequence(key)
+		if err != nil {
+			return "", err
+		}
+		for at := len(items) - 1; at >= 0; at-- {
+			if items[at].Unquoted() == value {
+				document.Remove(items[at])
+			}
+		}
+	}
+	for _, edit := range edits.Add {
+		key, value, err := splitEdit(edit, editableLists)
+		if err != nil {
+			return "", err
+		}
+		items, err := document.Sequence(key)
+		if err != nil {
+			return "", err
+		}
+		found := false
+		for _, item := range items {
+			found = found || item.Unquoted() == value
+		}
+		if !found {
+			err = document.Append(value, key)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	content := document.Bytes()
+	config, err := robotFrom(content)
+	if err != nil {
+		return "", fmt.Errorf("Edited %q would not be valid YAML, so it was left untouched. Reason: %v", filename, err)
+	}
+	_, err = config.Validate()
+	if err != nil {
+		return "", fmt.Errorf("Edited %q would not be valid robot.yaml, so it was left untouched. Reason: %v", filename, err)
+	}
+	if !edits.Dryrun {
+		err = document.SaveAs(filename)
+		if err != nil {
+			return "", err
+		}
+	}
+	return string(content), nil
+}
@@ -0,0 +1,88 @@
+package robot_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/robot"
+)
+
+const (
+	editableRobot = `# Reporting robot.
+tasks:
+  Run all:
+    shell: python -m robot --report NONE tasks.robot # main entry
+
+condaConfigFile: conda.yaml
+artifactsDir: "output"
+
+PATH: [., bin]
+PYTHONPATH:
+  - .
+  # shared keywords
+  - 'libraries'
+ignoreFiles: [.gitignore]
+`
+)
+
+func TestCanEditRobotYamlInPlace(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	filename := filepath.Join(t.TempDir(), "robot.yaml")
+	must.Nil(ioutil.WriteFile(filename, []byte(editableRobot), 0o644))
+
+	edits := &robot.Edits{
+		Set:    []string{"artifactsDir=output/reports"},
+		Add:    []string{"PATH=scripts", "PATH=bin", "PYTHONPATH=resources", "ignoreFiles=*.log, *.tmp"},
+		Remove: []string{"PYTHONPATH=libraries", "PATH=."},
+	}
+	output, err := robot.EditRobotYaml(filename, edits)
+	must.Nil(err)
+
+	expected := `# Reporting robot.
+tasks:
+  Run all:
+    shell: python -m robot --report NONE tasks.robot # main entry
+
+condaConfigFile: conda.yaml
+artifactsDir: "output/reports"
+
+PATH: [bin, scripts]
+PYTHONPATH:
+  - .
+  - resources
+ignoreFiles: [.gitignore, "*.log, *.tmp"]
+`
+	must.Equal(expected, output)
+	content, err := ioutil.ReadFile(filename)
+	must.Nil(err)
+	must.Equal(expected, string(content))
+
+	sut, err := robot.LoadRobotYaml(filename, false)
+	must.Nil(err)
+	must.Equal(2, len(sut.IgnoreFiles()))
+}
+
+func TestRobotYamlEditsCanBeRefused(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	filename := filepath.Join(t.TempDir(), "robot.yaml")
+	must.Nil(ioutil.WriteFile(filename, []byte(editableRobot), 0o644))
+
+	output, err := robot.EditRobotYaml(filename, &robot.Edits{Dryrun: true, Add: []string{"PATH=scripts"}})
+	must.Nil(err)
+	wont.Equal(editableRobot, output)
+
+	_, err = robot.EditRobotYaml(filename, &robot.Edits{Set: []string{"artifactsDir="}})
+	wont.Nil(err)
+	_, err = robot.EditRobotYaml(filename, &robot.Edits{Add: []string{"tasks=Run all"}})
+	wont.Nil(err)
+	_, err = robot.EditRobotYaml(filename, &robot.Edits{Set: []string{"PATH"}})
+	wont.Nil(err)
+
+	content, err := ioutil.ReadFile(filename)
+	must.Nil(err)
+	must.Equal(editableRobot, string(content))
+}
@@ -14,12 +14,18 @@ import (
 	"github.com/robocorp/rcc/common"
 	"github.com/robocorp/rcc/conda"
 	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/plugins"
 	"github.com/robocorp/rcc/xviper"
 
 	"github.com/google/shlex"
 	"gopkg.in/yaml.v2"
 )
 
+const (
+	HookInside  = `inside`
+	HookOutside = `outside`
+)
+
 var (
 	GoosPattern   = regexp.MustCompile("(?i:(windows|darwin|linux))")
 	GoarchPattern = regexp.MustCompile("(?i:(amd64|arm64))")
@@ -38,6 +44,14 @@ type Robot interface {
 	Validate() (bool, error)
 	Diagnostics(*common.DiagnosticStatus, bool)
 	DependenciesFile() (string, bool)
+	EnvironmentBudget() (float64, bool, bool)
+	ToolCaches() map[string]string
+	ProfileEnvironment() []string
+	Variants() []string
+	Solver() string
+	ActivationHooks() *conda.ActivationHooks
+	LifecycleHooks(stage string) []*Hook
+	BuildHooks() map[string][]string
 
 	WorkingDirectory() string
 	ArtifactDirectory() string
@@ -53,16 +67,40 @@ type Task interface {
 }
 
 type robot struct {
-	Tasks        map[string]*task `yaml:"tasks"`
-	Conda        string           `yaml:"condaConfigFile,omitempty"`
-	Environments []string         `yaml:"environmentConfigs,omitempty"`
-	Ignored      []string         `yaml:"ignoreFiles"`
-	Artifacts    string           `yaml:"artifactsDir"`
-	Path         []string         `yaml:"PATH"`
-	Pythonpath   []string         `yaml:"PYTHONPATH"`
+	Tasks        map[string]*task             `yaml:"tasks"`
+	Conda        string                       `yaml:"condaConfigFile,omitempty"`
+	Environments []string                     `yaml:"environmentConfigs,omitempty"`
+	Ignored      []string                     `yaml:"ignoreFiles"`
+	Artifacts    string                       `yaml:"artifactsDir"`
+	Path         []string                     `yaml:"PATH"`
+	Pythonpath   []string                     `yaml:"PYTHONPATH"`
+	Budget       *budget                      `yaml:"environmentBudget,omitempty"`
+	Caches       map[string]string            `yaml:"toolCaches,omitempty"`
+	Profiles     map[string]map[string]string `yaml:"environmentProfiles,omitempty"`
+	Active       []string                     `yaml:"activeProfiles,omitempty"`
+	SolverName   string                       `yaml:"solver,omitempty"`
+	PreActivate  []string                     `yaml:"preActivate,omitempty"`
+	PostActivate []string                     `yaml:"postActivate,omitempty"`
+	Lifecycle    map[string][]*Hook           `yaml:"hooks,omitempty"`
 	Root         string
 }
 
+// Hook is lifecycle hook command from robot.yaml. Hooks run outside of robot
+// environment unless environment is "inside".
+type Hook struct {
+	Command     string `yaml:"command"`
+	Environment string `yaml:"environment,omitempty"`
+}
+
+func (it *Hook) Inside() bool {
+	return it.Environment == HookInside
+}
+
+type budget struct {
+	Size    float64 `yaml:"sizeGB"`
+	Enforce bool    `yaml:"enforce"`
+}
+
 type task struct {
 	Task    string   `yaml:"robotTaskName,omitempty"`
 	Shell   string   `yaml:"shell,omitempty"`
@@ -185,6 +223,71 @@ func (it *robot) Diagnostics(target *common.DiagnosticStatus, production bool) {
 		}
 	}
 	target.Details["robot-dependencies-yaml"] = dependencies
+	target.Details["robot-active-profiles"] = strings.Join(conda.ProfileNames(it.Active...), ", ")
+	it.diagnoseProfiles(diagnose)
+	it.diagnoseSolver(diagnose)
+	it.diagnoseHooks(diagnose)
+}
+
+func (it *robot) diagnoseProfiles(diagnose common.Diagnoser) {
+	ok := true
+	for _, name := range conda.ProfileNames(it.Active...) {
+		if _, found := conda.FindProfile(it.Profiles, name); !found {
+			diagnose.Warning("", "Environment profile %q is active, but not defined in robot.yaml or settings.", name)
+			ok = false
+		}
+	}
+	if ok {
+		diagnose.Ok("Environment profiles are ok.")
+	}
+}
+
+func (it *robot) diagnoseSolver(diagnose common.Diagnoser) {
+	if !conda.IsKnownSolver(it.SolverName) {
+		diagnose.Fail("", "In robot.yaml, 'solver:' %q is not one of: %s.", it.SolverName, strings.Join(conda.KnownSolvers(), ", "))
+		return
+	}
+	diagnose.Ok("Solver selection is ok.")
+}
+
+func (it *robot) diagnoseHooks(diagnose common.Diagnoser) {
+	if len(it.Lifecycle) == 0 {
+		return
+	}
+	err := it.validateHooks()
+	if err != nil {
+		diagnose.Fail("", "%v", err)
+		return
+	}
+	diagnose.Ok("Lifecycle hooks are ok.")
+}
+
+// validateHooks checks that hooks are given for known stages, and that build
+// hooks run outside, since there is no robot environment during build.
+func (it *robot) validateHooks() error {
+	for stage, hooks := range it.Lifecycle {
+		if !plugins.IsStage(stage) {
+			return fmt.Errorf("In robot.yaml, 'hooks:' stage %q is not one of: %s.", stage, strings.Join(plugins.Stages, ", "))
+		}
+		for _, hook := range hooks {
+			if hook == nil || len(strings.TrimSpace(hook.Command)) == 0 {
+				return fmt.Errorf("In robot.yaml, %s hook needs 'command:'.", stage)
+			}
+			if _, err := shlex.Split(hook.Command); err != nil {
+				return fmt.Errorf("In robot.yaml, %s hook %q is not valid command: %v", stage, hook.Command, err)
+			}
+			switch hook.Environment {
+			case "", HookOutside:
+			case HookInside:
+				if stage == plugins.PreBuild || stage == plugins.PostBuild {
+					return fmt.Errorf("In robot.yaml, %s hook %q cannot run inside environment, since it is not there yet.", stage, hook.Command)
+				}
+			default:
+				return fmt.Errorf("In robot.yaml, %s hook %q has 'environment:' %q, which is not %q or %q.", stage, hook.Command, hook.Environment, HookInside, HookOutside)
+			}
+		}
+	}
+	return nil
 }
 
 func (it *robot) Validate() (bool, error) {
@@ -197,6 +300,17 @@ func (it *robot) Validate() (bool, error) {
 	if it.Artifacts == "" {
 		return false, errors.New("In robot.yaml, 'artifactsDir:' is required!")
 	}
+	for variable, directory := range it.Caches {
+		if !validCacheDirectory(directory) {
+			return false, fmt.Errorf("In robot.yaml, 'toolCaches:' directory for %q must be relative path inside environment, not %q!", variable, directory)
+		}
+	}
+	if !conda.IsKnownSolver(it.SolverName) {
+		return false, fmt.Errorf("In robot.yaml, 'solver:' must be one of %s, not %q!", strings.Join(conda.KnownSolvers(), ", "), it.SolverName)
+	}
+	if err := it.validateHooks(); err != nil {
+		return false, err
+	}
 	for name, task := range it.Tasks {
 		count := 0
 		if len(task.Task) > 0 {
@@ -247,6 +361,37 @@ func (it *robot) RootDirectory() string {
 	return it.Root
 }
 
+// EnvironmentBudget returns size limit (in GB) for environment, and if it
+// should be enforced, and if robot.yaml defines budget at all.
+func (it *robot) EnvironmentBudget() (float64, bool, bool) {
+	if it.Budget == nil || it.Budget.Size <= 0 {
+		return 0, false, false
+	}
+	return it.Budget.Size, it.Budget.Enforce, true
+}
+
+func validCacheDirectory(directory string) bool {
+	cleaned := filepath.ToSlash(filepath.Clean(directory))
+	return len(directory) > 0 && !filepath.IsAbs(directory) && cleaned != "." && cleaned != ".." && !strings.HasPrefix(cleaned, "../") && !strings.HasPrefix(cleaned, "/")
+}
+
+// ToolCaches maps environment variables to directories (relative to
+// environment), which are filled by post-build hooks, or snapshotted into
+// holotree after successful warm-up run.
+func (it *robot) ToolCaches() map[string]string {
+	if it.Caches == nil {
+		return map[string]string{}
+	}
+	return it.Caches
+}
+
+// ProfileEnvironment returns variables of environment profiles active in
+// settings and in robot.yaml (activeProfiles), where robot.yaml profiles
+// (environmentProfiles) are preferred over settings ones with same name.
+func (it *robot) ProfileEnvironment() []string {
+	return conda.ProfileEnvironment(it.Profiles, it.Active...)
+}
+
 func (it *robot) HasHolozip() bool {
 	return len(it.Holozip()) > 0
 }
@@ -314,6 +459,9 @@ func (it *robot) UsesConda() bool {
 }
 
 func (it *robot) CondaConfigFile() string {
+	if len(common.EnvironmentVariant) > 0 {
+		return it.variantFile(common.EnvironmentVariant)
+	}
 	available := it.availableEnvironmentConfigurations(common.Platform())
 	if len(available) > 0 {
 		return available[0]
@@ -368,9 +516,75 @@ func (it *robot) FreezeFilename() string {
 }
 
 func (it *robot) ArtifactDirectory() string {
+	if len(common.EnvironmentVariant) > 0 {
+		return filepath.Join(it.Root, it.Artifacts, "variants", VariantName(common.EnvironmentVariant))
+	}
 	return filepath.Join(it.Root, it.Artifacts)
 }
 
+// VariantName is short name of environment variant, used in space names and
+// artifact directories.
+func VariantName(filename string) string {
+	basename := filepath.Base(filename)
+	return strings.TrimSuffix(basename, filepath.Ext(basename))
+}
+
+func (it *robot) variantFile(variant string) string {
+	if filepath.IsAbs(variant) {
+		return variant
+	}
+	return filepath.Join(it.Root, variant)
+}
+
+// Solver is environment solver selected in robot.yaml, empty when settings
+// decide it.
+func (it *robot) Solver() string {
+	return strings.TrimSpace(it.SolverName)
+}
+
+// ActivationHooks are script snippets, which are added into activation
+// script of environment, after ones from conda.yaml.
+func (it *robot) ActivationHooks() *conda.ActivationHooks {
+	return &conda.ActivationHooks{Pre: it.PreActivate, Post: it.PostActivate}
+}
+
+// LifecycleHooks are hooks of robot.yaml for given lifecycle stage, in
+// order they were defined. They run after hooks from settings.
+func (it *robot) LifecycleHooks(stage string) []*Hook {
+	return it.Lifecycle[stage]
+}
+
+// BuildHooks are commands of pre-build and post-build hooks of robot.yaml.
+func (it *robot) BuildHooks() map[string][]string {
+	result := make(map[string][]string)
+	for _, stage := range []string{plugins.PreBuild, plugins.PostBuild} {
+		for _, hook := range it.Lifecycle[stage] {
+			result[stage] = append(result[stage], hook.Command)
+		}
+	}
+	return result
+}
+
+// Variants are all environment configurations (excluding freeze files) that
+// are available for this platform, including condaConfigFile.
+func (it *robot) Variants() []string {
+	seen := make(map[string]bool)
+	result := make([]string, 0, len(it.Environments)+1)
+	candidates := it.availableEnvironmentConfigurations(common.Platform())
+	if len(it.Conda) > 0 {
+		candidates = append(candidates, filepath.Join(it.Root, it.Conda))
+	}
+	for _, candidate := range candidates {
+		freezed := strings.Contains(strings.ToLower(filepath.Base(candidate)), "freeze")
+		if freezed || seen[candidate] || !pathlib.IsFile(candidate) {
+			continue
+		}
+		seen[candidate] = true
+		result = append(result, candidate)
+	}
+	return result
+}
+
 func pathBuilder(root string, tails []string) pathlib.PathParts {
 	result := make([]string, 0, len(tails))
 	for _, part := range tails {
@@ -441,7 +655,12 @@ func (it *robot) ExecutionEnvironment(location string, inject []string, full boo
 		fmt.Sprintf("ROBOT_ROOT=%s", it.WorkingDirectory()),
 		fmt.Sprintf("ROBOT_ARTIFACTS=%s", it.ArtifactDirectory()),
 	)
+	for variable, directory := range it.ToolCaches() {
+		environment = append(environment, fmt.Sprintf("%s=%s", variable, filepath.Join(location, filepath.FromSlash(directory))))
+	}
 	environment = append(environment, conda.LoadActivationEnvironment(location)...)
+	environment = append(environment, conda.UTF8Environment()...)
+	environment = append(environment, it.ProfileEnvironment()...)
 	return environment
 }
 
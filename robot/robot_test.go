@@ -1,11 +1,16 @@
 package robot_test
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/robocorp/rcc/common"
 	"github.com/robocorp/rcc/hamlet"
 	"github.com/robocorp/rcc/robot"
+	"github.com/robocorp/rcc/settings"
 )
 
 func TestCannotReadMissingRobotYaml(t *testing.T) {
@@ -78,3 +83,129 @@ func TestCanGetTaskFormCommand(t *testing.T) {
 	wont.Nil(command)
 	must.Equal(12, len(command))
 }
+
+func TestRobotSettingsComeFromRobotYaml(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	empty := robot.SettingsOf(nil)
+	wont.Nil(empty)
+	must.Equal(0.0, empty.Budget)
+	wont.True(empty.EnforceBudget)
+	must.Equal("", empty.Solver)
+
+	filename := filepath.Join(t.TempDir(), "robot.yaml")
+	content := "tasks:\n  Run:\n    shell: echo\nenvironmentBudget:\n  sizeGB: 2.5\n  enforce: true\nsolver: pixi\npreActivate:\n  - echo pre\ntoolCaches:\n  TOOL_CACHE: cache\nhooks:\n  pre-build:\n    - command: ./warm.sh\n"
+	must.Nil(os.WriteFile(filename, []byte(content), 0o644))
+	config, err := robot.LoadRobotYaml(filename, false)
+	must.Nil(err)
+	settings := robot.SettingsOf(config)
+	must.Equal(2.5, settings.Budget)
+	must.True(settings.EnforceBudget)
+	must.Equal("pixi", settings.Solver)
+	must.Equal([]string{"echo pre"}, settings.PreActivate)
+	must.Equal(0, len(settings.PostActivate))
+	must.Equal([]string{"./warm.sh"}, settings.BuildHooks["pre-build"])
+	must.Equal("cache", settings.ToolCaches["TOOL_CACHE"])
+	must.Equal(filepath.Dir(filename), settings.Directory)
+}
+
+func TestCanListEnvironmentVariants(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	folder, err := ioutil.TempDir("", "variants")
+	must.Nil(err)
+	defer os.RemoveAll(folder)
+	content := "tasks:\n  Test:\n    shell: python -m pytest\nartifactsDir: output\ncondaConfigFile: conda.yaml\nenvironmentConfigs:\n  - environment_linux_amd64_freeze.yaml\n  - python38.yaml\n  - python310.yaml\n  - missing.yaml\n  - conda.yaml\n"
+	for _, name := range []string{"robot.yaml", "conda.yaml", "python38.yaml", "python310.yaml", "environment_linux_amd64_freeze.yaml"} {
+		body := "channels: []\n"
+		if name == "robot.yaml" {
+			body = content
+		}
+		must.Nil(ioutil.WriteFile(filepath.Join(folder, name), []byte(body), 0o644))
+	}
+
+	sut, err := robot.LoadRobotYaml(filepath.Join(folder, "robot.yaml"), false)
+	must.Nil(err)
+	wont.Nil(sut)
+	variants := sut.Variants()
+	must.Equal(3, len(variants))
+	must.Equal("python38", robot.VariantName(variants[0]))
+	must.Equal("python310", robot.VariantName(variants[1]))
+	must.Equal("conda", robot.VariantName(variants[2]))
+
+	common.EnvironmentVariant = "python310.yaml"
+	defer func() { common.EnvironmentVariant = "" }()
+	must.Equal(filepath.Join(folder, "python310.yaml"), sut.CondaConfigFile())
+	must.Equal(filepath.Join(folder, "output", "variants", "python310"), sut.ArtifactDirectory())
+}
+
+func TestProfilesAreMergedIntoExecutionEnvironment(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	folder, err := ioutil.TempDir("", "profiles")
+	must.Nil(err)
+	defer os.RemoveAll(folder)
+	content := "tasks:\n  Test:\n    shell: python -m pytest\nartifactsDir: output\nenvironmentProfiles:\n  proxy:\n    HTTPS_PROXY: http://robot-proxy:3128\nactiveProfiles:\n  - proxy\n  - missing\n"
+	must.Nil(ioutil.WriteFile(filepath.Join(folder, "robot.yaml"), []byte(content), 0o644))
+
+	config := settings.Global.EnvironmentProfiles()
+	active, profiles := config.Active, config.Profiles
+	defer func() {
+		config.Active, config.Profiles = active, profiles
+	}()
+	config.Active = []string{"corporate"}
+	config.Profiles = map[string]settings.StringMap{
+		"corporate": {"SSL_CERT_FILE": "/etc/ssl/corporate.pem", "HTTPS_PROXY": "http://corporate:8080"},
+		"proxy":     {"HTTPS_PROXY": "http://ignored:8080"},
+	}
+
+	sut, err := robot.LoadRobotYaml(filepath.Join(folder, "robot.yaml"), false)
+	must.Nil(err)
+	wont.Nil(sut)
+	must.Equal([]string{"HTTPS_PROXY=http://corporate:8080", "SSL_CERT_FILE=/etc/ssl/corporate.pem", "HTTPS_PROXY=http://robot-proxy:3128"}, sut.ProfileEnvironment())
+
+	environment := sut.ExecutionEnvironment(folder, []string{}, false)
+	must.Equal("HTTPS_PROXY=http://robot-proxy:3128", environment[len(environment)-1])
+}
+
+func TestCanReadLifecycleHooks(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	folder := t.TempDir()
+	content := "tasks:\n  Test:\n    shell: python -m pytest\nartifactsDir: output\nhooks:\n  pre-build:\n    - command: ./warm-cache.sh\n  post-run:\n    - command: python register.py --quiet\n      environment: inside\n    - command: curl -s https://example.com\n"
+	must.Nil(ioutil.WriteFile(filepath.Join(folder, "robot.yaml"), []byte(content), 0o644))
+
+	sut, err := robot.LoadRobotYaml(filepath.Join(folder, "robot.yaml"), false)
+	must.Nil(err)
+	wont.Nil(sut)
+	valid, err := sut.Validate()
+	must.True(valid)
+	must.Nil(err)
+	must.Equal(0, len(sut.LifecycleHooks("pre-run")))
+	hooks := sut.LifecycleHooks("post-run")
+	must.Equal(2, len(hooks))
+	must.True(hooks[0].Inside())
+	wont.True(hooks[1].Inside())
+	must.Equal(map[string][]string{"pre-build": {"./warm-cache.sh"}}, sut.BuildHooks())
+}
+
+func TestInvalidLifecycleHooksAreRejected(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	folder := t.TempDir()
+	invalid := []string{
+		"hooks:\n  before-run:\n    - command: echo\n",
+		"hooks:\n  pre-run:\n    - environment: inside\n",
+		"hooks:\n  pre-run:\n    - command: echo\n      environment: somewhere\n",
+		"hooks:\n  post-build:\n    - command: echo\n      environment: inside\n",
+	}
+	for _, hooks := range invalid {
+		content := "tasks:\n  Test:\n    shell: python -m pytest\nartifactsDir: output\n" + hooks
+		must.Nil(ioutil.WriteFile(filepath.Join(folder, "robot.yaml"), []byte(content), 0o644))
+		sut, err := robot.LoadRobotYaml(filepath.Join(folder, "robot.yaml"), false)
+		must.Nil(err)
+		valid, err := sut.Validate()
+		wont.True(valid)
+		wont.Nil(err)
+	}
+}
@@ -0,0 +1,32 @@
+package robot
+
+// Settings are environment settings from robot.yaml, which override ones
+// from settings.yaml for environment of that robot. They are passed along
+// explicitly, so that they never leak into environments of other robots.
+// Zero value means that there are no robot specific settings.
+type Settings struct {
+	Budget        float64
+	EnforceBudget bool
+	Solver        string
+	PreActivate   []string
+	PostActivate  []string
+	BuildHooks    map[string][]string
+	ToolCaches    map[string]string
+	Directory     string
+}
+
+// SettingsOf returns environment settings of given robot, or empty settings
+// when there is no robot.
+func SettingsOf(config Robot) *Settings {
+	result := &Settings{}
+	if config == nil {
+		return result
+	}
+	result.Budget, result.EnforceBudget, _ = config.EnvironmentBudget()
+	result.Solver = config.Solver()
+	hooks := config.ActivationHooks()
+	result.PreActivate, result.PostActivate = hooks.Pre, hooks.Post
+	result.BuildHooks, result.Directory = config.BuildHooks(), config.WorkingDirectory()
+	result.ToolCaches = config.ToolCaches()
+	return result
+}
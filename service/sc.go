@@ -0,0 +1,48 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	restartDelay = 10 * time.Second
+	resetPeriod  = 24 * 60 * 60
+)
+
+func scQuote(value string) string {
+	if strings.ContainsAny(value, " \t") {
+		return `\"` + value + `\"`
+	}
+	return value
+}
+
+// ScCommands generates Windows commands (sc.exe and reg.exe) that register
+// daemon same way as install does. Secrets file is only referred to, since
+// it must be written with restricted access.
+func ScCommands(config *Config) string {
+	command := []string{scQuote(config.Executable)}
+	for _, argument := range config.Arguments {
+		command = append(command, scQuote(argument))
+	}
+	create := fmt.Sprintf("sc.exe create \"%s\" binPath= \"%s\" start= auto DisplayName= \"%s\"", config.Name, strings.Join(command, " "), config.Name)
+	if len(config.User) > 0 {
+		create = fmt.Sprintf("%s obj= \"%s\"", create, config.User)
+	}
+	delay := restartDelay.Milliseconds()
+	environment := append([]string{}, config.Environment...)
+	if config.HasSecrets() {
+		environment = append(environment, fmt.Sprintf("%s=%s", SecretsVariable, config.SecretsFile))
+	}
+	lines := []string{
+		create,
+		fmt.Sprintf("sc.exe description \"%s\" \"%s\"", config.Name, config.Description),
+		fmt.Sprintf("sc.exe failure \"%s\" reset= %d actions= restart/%d/restart/%d/restart/%d", config.Name, resetPeriod, delay, delay, delay),
+	}
+	if len(environment) > 0 {
+		lines = append(lines, fmt.Sprintf("reg.exe add \"HKLM\\SYSTEM\\CurrentControlSet\\Services\\%s\" /v Environment /t REG_MULTI_SZ /d \"%s\" /f", config.Name, strings.Join(environment, `\0`)))
+	}
+	lines = append(lines, "")
+	return strings.Join(lines, "\r\n")
+}
@@ -0,0 +1,31 @@
+package service_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/service"
+)
+
+func TestCanGenerateScCommands(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	config := &service.Config{
+		Name:        "rcc-test",
+		Description: "test daemon",
+		Executable:  `C:\Program Files\rcc\rcc.exe`,
+		Arguments:   []string{"daemon", "--maintenance=true"},
+		Environment: []string{`ROBOCORP_HOME=C:\robocorp`},
+		Secrets:     []string{"RCC_REMOTE_TOKEN=very-secret"},
+		SecretsFile: `C:\ProgramData\robocorp\rcc\rcc-test.env`,
+		User:        `NT SERVICE\rcc-test`,
+	}
+	commands := service.ScCommands(config)
+	must.True(strings.Contains(commands, `sc.exe create "rcc-test" binPath= "\"C:\Program Files\rcc\rcc.exe\" daemon --maintenance=true" start= auto`))
+	must.True(strings.Contains(commands, ` obj= "NT SERVICE\rcc-test"`+"\r\n"))
+	must.True(strings.Contains(commands, `sc.exe failure "rcc-test" reset= 86400 actions= restart/10000/restart/10000/restart/10000`))
+	must.True(strings.Contains(commands, `/d "ROBOCORP_HOME=C:\robocorp\0RCC_SERVICE_SECRETS=C:\ProgramData\robocorp\rcc\rcc-test.env" /f`))
+	wont.True(strings.Contains(commands, "very-secret"))
+	must.Equal(1, len(config.Environment))
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+)
+
+const (
+	DefaultName = `rcc-daemon`
+	// SecretsVariable tells service where its secrets file is, on platforms
+	// where service manager cannot read it by itself.
+	SecretsVariable = `RCC_SERVICE_SECRETS`
+	// PasswordVariable gives password of service account on Windows, when
+	// account needs one. It is only used while installing, never stored.
+	PasswordVariable = `RCC_SERVICE_PASSWORD`
+)
+
+var (
+	passedVariables = []string{"ROBOCORP_WRITABLE_HOME"}
+	secretVariables = []string{"RCC_REMOTE_TOKEN", "RCC_HOLOTREE_TOKEN"}
+)
+
+// Config describes how daemon is registered as operating system service.
+// Secrets (tokens) never go into service definition itself, but into
+// SecretsFile, which only administrators (and service account) can read.
+// When User is empty, service runs as root (Linux) or LocalSystem (Windows).
+type Config struct {
+	Name        string
+	Description string
+	Executable  string
+	Arguments   []string
+	Environment []string
+	Secrets     []string
+	SecretsFile string
+	LogFile     string
+	User        string
+	Group       string
+}
+
+// NewConfig creates service config for currently running rcc executable,
+// passing current ROBOCORP_HOME and tokens from current environment (since
+// service usually runs as different user).
+func NewConfig(name, logfile string, arguments []string) (*Config, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		return nil, err
+	}
+	environment := []string{fmt.Sprintf("ROBOCORP_HOME=%s", common.RobocorpHome())}
+	for _, key := range passedVariables {
+		value, ok := os.LookupEnv(key)
+		if ok && len(value) > 0 {
+			environment = append(environment, fmt.Sprintf("%s=%s", key, value))
+		}
+	}
+	secrets := []string{}
+	for _, key := range secretVariables {
+		value, ok := os.LookupEnv(key)
+		if ok && len(value) > 0 {
+			secrets = append(secrets, fmt.Sprintf("%s=%s", key, value))
+		}
+	}
+	if len(logfile) == 0 {
+		logfile = defaultLogfile()
+	}
+	if len(logfile) > 0 {
+		logfile, err = filepath.Abs(logfile)
+		if err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, "--logfile", logfile)
+	}
+	return &Config{
+		Name:        name,
+		Description: "Robocorp rcc daemon, serving environments and remote execution.",
+		Executable:  executable,
+		Arguments:   arguments,
+		Environment: environment,
+		Secrets:     secrets,
+		SecretsFile: secretsFilename(name),
+		LogFile:     logfile,
+	}, nil
+}
+
+// HasSecrets tells if service needs secrets file.
+func (it *Config) HasSecrets() bool {
+	return len(it.Secrets) > 0 && len(it.SecretsFile) > 0
+}
+
+func secretsContent(secrets []string) []byte {
+	return []byte(strings.Join(secrets, "\n") + "\n")
+}
+
+// LoadSecrets adds KEY=value lines of secrets file into process environment.
+func LoadSecrets(filename string) error {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			continue
+		}
+		err = os.Setenv(parts[0], parts[1])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RedirectLogs routes all rcc output (stdout and stderr) into appended log
+// file, since services do not have console.
+func RedirectLogs(logfile string) (*os.File, error) {
+	err := os.MkdirAll(filepath.Dir(logfile), 0o755)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := os.OpenFile(logfile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	os.Stdout = sink
+	os.Stderr = sink
+	return sink, nil
+}
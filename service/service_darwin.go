@@ -0,0 +1,22 @@
+package service
+
+import (
+	"fmt"
+)
+
+func secretsFilename(name string) string {
+	return ""
+}
+
+// Definition is systemd unit, since there is no launchd support.
+func Definition(config *Config) string {
+	return UnitFile(config)
+}
+
+func Install(config *Config) (string, error) {
+	return "", fmt.Errorf("Installing %q as service is not supported on macOS, use launchd directly.", config.Name)
+}
+
+func Remove(name string) (string, error) {
+	return "", fmt.Errorf("Removing %q service is not supported on macOS, use launchd directly.", name)
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+var (
+	UnitDirectory    = `/etc/systemd/system`
+	SecretsDirectory = `/etc/rcc`
+)
+
+func unitFilename(name string) string {
+	return filepath.Join(UnitDirectory, fmt.Sprintf("%s.service", name))
+}
+
+func secretsFilename(name string) string {
+	return filepath.Join(SecretsDirectory, fmt.Sprintf("%s.env", name))
+}
+
+// writeSecrets writes secrets readable only by root (systemd reads
+// EnvironmentFile as root before dropping privileges).
+func writeSecrets(config *Config) error {
+	err := os.MkdirAll(filepath.Dir(config.SecretsFile), 0o700)
+	if err != nil {
+		return err
+	}
+	sink, err := os.OpenFile(config.SecretsFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer sink.Close()
+	err = sink.Chmod(0o600)
+	if err != nil {
+		return err
+	}
+	_, err = sink.Write(secretsContent(config.Secrets))
+	if err != nil {
+		return err
+	}
+	return sink.Close()
+}
+
+// Definition is what install would register, in form of systemd unit.
+func Definition(config *Config) string {
+	return UnitFile(config)
+}
+
+func Install(config *Config) (string, error) {
+	filename := unitFilename(config.Name)
+	err := os.MkdirAll(UnitDirectory, 0o755)
+	if err != nil {
+		return "", err
+	}
+	if config.HasSecrets() {
+		err = writeSecrets(config)
+		if err != nil {
+			return "", err
+		}
+	}
+	err = ioutil.WriteFile(filename, []byte(UnitFile(config)), 0o644)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Wrote systemd unit %q. Activate it with: systemctl daemon-reload && systemctl enable --now %s", filename, config.Name), nil
+}
+
+func Remove(name string) (string, error) {
+	filename := unitFilename(name)
+	systemctl, err := exec.LookPath("systemctl")
+	if err == nil {
+		exec.Command(systemctl, "disable", "--now", name).Run()
+	}
+	err = os.Remove(filename)
+	if err != nil {
+		return "", err
+	}
+	err = os.Remove(secretsFilename(name))
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	return fmt.Sprintf("Removed systemd unit %q. Finish with: systemctl daemon-reload", filename), nil
+}
@@ -0,0 +1,49 @@
+package service_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/service"
+)
+
+func TestInstalledSecretsAreOnlyReadableByOwner(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	folder := t.TempDir()
+	units, secrets := service.UnitDirectory, service.SecretsDirectory
+	defer func() {
+		service.UnitDirectory, service.SecretsDirectory = units, secrets
+	}()
+	service.UnitDirectory = filepath.Join(folder, "units")
+	service.SecretsDirectory = filepath.Join(folder, "secrets")
+
+	original := os.Getenv("RCC_HOLOTREE_TOKEN")
+	defer os.Setenv("RCC_HOLOTREE_TOKEN", original)
+	os.Setenv("RCC_HOLOTREE_TOKEN", "very-secret")
+
+	config, err := service.NewConfig("rcc-test", "", []string{"daemon"})
+	must.Nil(err)
+	_, err = service.Install(config)
+	must.Nil(err)
+
+	unit, err := os.ReadFile(filepath.Join(folder, "units", "rcc-test.service"))
+	must.Nil(err)
+	wont.True(strings.Contains(string(unit), "very-secret"))
+	must.True(strings.Contains(string(unit), "EnvironmentFile="+filepath.Join(folder, "secrets", "rcc-test.env")))
+
+	info, err := os.Stat(filepath.Join(folder, "secrets", "rcc-test.env"))
+	must.Nil(err)
+	must.Equal(os.FileMode(0o600), info.Mode().Perm())
+	content, err := os.ReadFile(filepath.Join(folder, "secrets", "rcc-test.env"))
+	must.Nil(err)
+	must.Equal("RCC_HOLOTREE_TOKEN=very-secret\n", string(content))
+
+	_, err = service.Remove("rcc-test")
+	must.Nil(err)
+	_, err = os.Stat(filepath.Join(folder, "secrets", "rcc-test.env"))
+	must.True(os.IsNotExist(err))
+}
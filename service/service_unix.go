@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package service
+
+// defaultLogfile is empty, since service manager captures output.
+func defaultLogfile() string {
+	return ""
+}
+
+func Run(name, logfile string, work func() error) error {
+	if len(logfile) > 0 {
+		sink, err := RedirectLogs(logfile)
+		if err != nil {
+			return err
+		}
+		defer sink.Close()
+	}
+	return work()
+}
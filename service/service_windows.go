@@ -0,0 +1,224 @@
+//go:build windows
+// +build windows
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const (
+	acceptedCommands = svc.AcceptStop | svc.AcceptShutdown
+	// only SYSTEM and Administrators, nothing inherited
+	secretsSddl = `D:P(A;;FA;;;SY)(A;;FA;;;BA)`
+	// read access for service account, when it is not SYSTEM
+	accountAce = `(A;;FR;;;%s)`
+)
+
+// Definition is what install would register, in form of sc.exe commands.
+func Definition(config *Config) string {
+	return ScCommands(config)
+}
+
+func secretsFilename(name string) string {
+	return filepath.Join(os.Getenv("ProgramData"), "robocorp", "rcc", fmt.Sprintf("%s.env", name))
+}
+
+// writeSecrets writes secrets into file that only SYSTEM, Administrators,
+// and service account (if one is given) can read. Access is restricted
+// before any secret is written into file.
+func writeSecrets(config *Config) error {
+	err := os.MkdirAll(filepath.Dir(config.SecretsFile), 0o700)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(config.SecretsFile, []byte{}, 0o600)
+	if err != nil {
+		return err
+	}
+	sddl := secretsSddl
+	if len(config.User) > 0 {
+		sid, _, _, err := windows.LookupSID("", config.User)
+		if err != nil {
+			return err
+		}
+		sddl += fmt.Sprintf(accountAce, sid.String())
+	}
+	descriptor, err := windows.SecurityDescriptorFromString(sddl)
+	if err != nil {
+		return err
+	}
+	dacl, _, err := descriptor.DACL()
+	if err != nil {
+		return err
+	}
+	information := windows.SECURITY_INFORMATION(windows.DACL_SECURITY_INFORMATION | windows.PROTECTED_DACL_SECURITY_INFORMATION)
+	err = windows.SetNamedSecurityInfo(config.SecretsFile, windows.SE_FILE_OBJECT, information, nil, nil, dacl, nil)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(config.SecretsFile, secretsContent(config.Secrets), 0o600)
+}
+
+// defaultLogfile is needed, since Windows services have no console.
+func defaultLogfile() string {
+	return filepath.Join(common.WritableHome(), "logs", "rcc-daemon.log")
+}
+
+func Install(config *Config) (string, error) {
+	manager, err := mgr.Connect()
+	if err != nil {
+		return "", err
+	}
+	defer manager.Disconnect()
+	existing, err := manager.OpenService(config.Name)
+	if err == nil {
+		existing.Close()
+		return "", fmt.Errorf("Service %q already exists.", config.Name)
+	}
+	settings := mgr.Config{
+		DisplayName: config.Name,
+		Description: config.Description,
+		StartType:   mgr.StartAutomatic,
+	}
+	if len(config.User) > 0 {
+		settings.ServiceStartName = config.User
+		settings.Password = os.Getenv(PasswordVariable)
+	}
+	service, err := manager.CreateService(config.Name, config.Executable, settings, config.Arguments...)
+	if err != nil {
+		return "", err
+	}
+	defer service.Close()
+	recovery := []mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: restartDelay},
+		{Type: mgr.ServiceRestart, Delay: restartDelay},
+		{Type: mgr.ServiceRestart, Delay: restartDelay},
+	}
+	err = service.SetRecoveryActions(recovery, resetPeriod)
+	if err != nil {
+		service.Delete()
+		return "", err
+	}
+	environment := append([]string{}, config.Environment...)
+	if config.HasSecrets() {
+		err = writeSecrets(config)
+		if err != nil {
+			service.Delete()
+			return "", err
+		}
+		environment = append(environment, fmt.Sprintf("%s=%s", SecretsVariable, config.SecretsFile))
+	}
+	if len(environment) > 0 {
+		key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\`+config.Name, registry.SET_VALUE)
+		if err != nil {
+			service.Delete()
+			return "", err
+		}
+		err = key.SetStringsValue("Environment", environment)
+		key.Close()
+		if err != nil {
+			service.Delete()
+			return "", err
+		}
+	}
+	eventlog.InstallAsEventCreate(config.Name, eventlog.Error|eventlog.Warning|eventlog.Info)
+	return fmt.Sprintf("Installed Windows service %q (automatic start, restart on failure), logging into %q. Start it with: sc start %s", config.Name, config.LogFile, config.Name), nil
+}
+
+func Remove(name string) (string, error) {
+	manager, err := mgr.Connect()
+	if err != nil {
+		return "", err
+	}
+	defer manager.Disconnect()
+	service, err := manager.OpenService(name)
+	if err != nil {
+		return "", fmt.Errorf("Service %q is not installed.", name)
+	}
+	defer service.Close()
+	service.Control(svc.Stop)
+	err = service.Delete()
+	if err != nil {
+		return "", err
+	}
+	eventlog.Remove(name)
+	err = os.Remove(secretsFilename(name))
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	return fmt.Sprintf("Removed Windows service %q.", name), nil
+}
+
+type handler struct {
+	name string
+	work func() error
+}
+
+func (it *handler) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+	done := make(chan error, 1)
+	go func() {
+		done <- it.work()
+	}()
+	changes <- svc.Status{State: svc.Running, Accepts: acceptedCommands}
+	for {
+		select {
+		case err := <-done:
+			if elog, failure := eventlog.Open(it.name); failure == nil {
+				elog.Error(1, fmt.Sprintf("%s stopped unexpectedly, reason: %v", it.name, err))
+				elog.Close()
+			}
+			return false, 1
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				changes <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending, WaitHint: uint32(common.ExitGrace / time.Millisecond)}
+				common.Cancel(common.CodeInterrupted, "Stopped by service control manager")
+				select {
+				case <-done:
+				case <-time.After(common.ExitGrace):
+					common.Log("%s did not stop within %s, stopping anyway.", it.name, common.ExitGrace)
+				}
+				return false, 0
+			}
+		}
+	}
+}
+
+// Run executes work either directly, or when started by service control
+// manager, under service protocol (so that stop requests are honored and
+// failures lead to automatic restart).
+func Run(name, logfile string, work func() error) error {
+	if len(logfile) > 0 {
+		sink, err := RedirectLogs(logfile)
+		if err != nil {
+			return err
+		}
+		defer sink.Close()
+	}
+	if secrets := os.Getenv(SecretsVariable); len(secrets) > 0 {
+		err := LoadSecrets(secrets)
+		if err != nil {
+			return err
+		}
+	}
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return work()
+	}
+	return svc.Run(name, &handler{name: name, work: work})
+}
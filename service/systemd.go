@@ -0,0 +1,54 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func unitQuote(value string) string {
+	if strings.ContainsAny(value, " \t\"'\\") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// UnitFile generates systemd unit for daemon, with automatic restart and
+// output routed into journal (or into log file, if one is configured).
+// Secrets are only referred to as EnvironmentFile, since units are readable
+// by everyone.
+func UnitFile(config *Config) string {
+	command := []string{unitQuote(config.Executable)}
+	for _, argument := range config.Arguments {
+		command = append(command, unitQuote(argument))
+	}
+	lines := []string{
+		"[Unit]",
+		fmt.Sprintf("Description=%s", config.Description),
+		"After=network-online.target",
+		"Wants=network-online.target",
+		"",
+		"[Service]",
+		"Type=simple",
+		fmt.Sprintf("ExecStart=%s", strings.Join(command, " ")),
+		"Restart=always",
+		"RestartSec=10",
+		"StandardOutput=journal",
+		"StandardError=journal",
+		fmt.Sprintf("SyslogIdentifier=%s", config.Name),
+	}
+	if len(config.User) > 0 {
+		lines = append(lines, fmt.Sprintf("User=%s", unitQuote(config.User)))
+	}
+	if len(config.Group) > 0 {
+		lines = append(lines, fmt.Sprintf("Group=%s", unitQuote(config.Group)))
+	}
+	for _, variable := range config.Environment {
+		lines = append(lines, fmt.Sprintf("Environment=%s", unitQuote(variable)))
+	}
+	if config.HasSecrets() {
+		lines = append(lines, fmt.Sprintf("EnvironmentFile=%s", unitQuote(config.SecretsFile)))
+	}
+	lines = append(lines, "", "[Install]", "WantedBy=multi-user.target", "")
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,71 @@
+package service_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/service"
+)
+
+func TestCanGenerateSystemdUnit(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	config := &service.Config{
+		Name:        "rcc-test",
+		Description: "test daemon",
+		Executable:  "/opt/rcc/rcc",
+		Arguments:   []string{"daemon", "--maintenance=true", "--logfile", "/var/log/rcc daemon.log"},
+		Environment: []string{"ROBOCORP_HOME=/opt/robocorp"},
+	}
+	unit := service.UnitFile(config)
+	must.True(strings.Contains(unit, "ExecStart=/opt/rcc/rcc daemon --maintenance=true --logfile \"/var/log/rcc daemon.log\"\n"))
+	must.True(strings.Contains(unit, "Restart=always\n"))
+	must.True(strings.Contains(unit, "Environment=ROBOCORP_HOME=/opt/robocorp\n"))
+	must.True(strings.Contains(unit, "SyslogIdentifier=rcc-test\n"))
+	wont.True(strings.Contains(unit, "\n\n\n"))
+	wont.True(strings.Contains(unit, "User="))
+}
+
+func TestSystemdUnitCanRunAsDedicatedAccount(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	config := &service.Config{
+		Name:        "rcc-test",
+		Description: "test daemon",
+		Executable:  "/opt/rcc/rcc",
+		User:        "robot",
+		Group:       "robots",
+	}
+	unit := service.UnitFile(config)
+	must.True(strings.Contains(unit, "User=robot\nGroup=robots\n"))
+}
+
+func TestSecretsAreNotPartOfSystemdUnit(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	config := &service.Config{
+		Name:        "rcc-test",
+		Description: "test daemon",
+		Executable:  "/opt/rcc/rcc",
+		Environment: []string{"ROBOCORP_HOME=/opt/robocorp"},
+		Secrets:     []string{"RCC_REMOTE_TOKEN=very-secret"},
+		SecretsFile: "/etc/rcc/rcc-test.env",
+	}
+	unit := service.UnitFile(config)
+	must.True(strings.Contains(unit, "EnvironmentFile=/etc/rcc/rcc-test.env\n"))
+	wont.True(strings.Contains(unit, "very-secret"))
+	wont.True(strings.Contains(unit, "RCC_REMOTE_TOKEN"))
+}
+
+func TestCanLoadSecretsIntoEnvironment(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	filename := filepath.Join(t.TempDir(), "secrets.env")
+	must.Nil(os.WriteFile(filename, []byte("RCC_TEST_SECRET=a=b\n\nbroken\n"), 0o600))
+	defer os.Unsetenv("RCC_TEST_SECRET")
+	must.Nil(service.LoadSecrets(filename))
+	must.Equal("a=b", os.Getenv("RCC_TEST_SECRET"))
+}
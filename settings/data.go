@@ -21,7 +21,12 @@ type Settings struct {
 	Branding     StringMap     `yaml:"branding" json:"branding"`
 	Certificates *Certificates `yaml:"certificates" json:"certificates"`
 	Endpoints    *Endpoints    `yaml:"endpoints" json:"endpoints"`
+	Environment  *Environment  `yaml:"environment" json:"environment"`
 	Hosts        []string      `yaml:"diagnostics-hosts" json:"diagnostics-hosts"`
+	Hololib      *Hololib      `yaml:"hololib" json:"hololib"`
+	Holotree     *Holotree     `yaml:"holotree" json:"holotree"`
+	Hooks        Hooks         `yaml:"hooks" json:"hooks"`
+	Profiles     *Profiles     `yaml:"environment-profiles" json:"environment-profiles"`
 	Meta         *Meta         `yaml:"meta" json:"meta"`
 }
 
@@ -61,6 +66,14 @@ func (it *Settings) Hostnames() []string {
 			collector[name] = true
 		}
 	}
+	if it.Holotree != nil {
+		for _, channel := range it.Holotree.CondaChannels {
+			hostFromUrl(channel.Channel, collector)
+		}
+		for _, channel := range it.Holotree.MirrorChannels {
+			hostFromUrl(channel.Channel, collector)
+		}
+	}
 	result := make([]string, 0, len(collector))
 	for key, _ := range collector {
 		result = append(result, key)
@@ -205,6 +218,101 @@ func (it *Endpoints) Hostnames() []string {
 	return result
 }
 
+type Holotree struct {
+	FailureCooldown    int                 `yaml:"failure-cooldown" json:"failure-cooldown"`
+	SharedServer       string              `yaml:"shared-server" json:"shared-server"`
+	SharedPush         bool                `yaml:"shared-push" json:"shared-push"`
+	ClientCertificate  string              `yaml:"client-certificate" json:"client-certificate"`
+	ClientKey          string              `yaml:"client-key" json:"client-key"`
+	SystemLibrary      string              `yaml:"system-library" json:"system-library"`
+	Solver             string              `yaml:"solver" json:"solver"`
+	SolverExecutable   string              `yaml:"solver-executable" json:"solver-executable"`
+	VerifyBlobs        bool                `yaml:"verify-blobs" json:"verify-blobs"`
+	CatalogRetention   int                 `yaml:"catalog-retention" json:"catalog-retention"`
+	CatalogKeepLast    int                 `yaml:"catalog-keep-last" json:"catalog-keep-last"`
+	PreserveAttributes bool                `yaml:"preserve-attributes" json:"preserve-attributes"`
+	Digest             string              `yaml:"digest" json:"digest"`
+	ExcludePatterns    []string            `yaml:"exclude-patterns" json:"exclude-patterns"`
+	BlobStore          string              `yaml:"blob-store" json:"blob-store"`
+	SigningKey         string              `yaml:"signing-key" json:"signing-key"`
+	TrustedKeys        []string            `yaml:"trusted-keys" json:"trusted-keys"`
+	SparseSpaces       map[string][]string `yaml:"sparse-spaces" json:"sparse-spaces"`
+	CaseCollisions     string              `yaml:"case-collisions" json:"case-collisions"`
+	IoLimit            int                 `yaml:"io-limit" json:"io-limit"`
+	ChunkThreshold     int                 `yaml:"chunk-threshold" json:"chunk-threshold"`
+	Relocations        []*Relocation       `yaml:"relocations" json:"relocations"`
+	MicromambaVersion  string              `yaml:"micromamba-version" json:"micromamba-version"`
+	MicromambaChannel  string              `yaml:"micromamba-channel" json:"micromamba-channel"`
+	MicromambaUpdate   bool                `yaml:"micromamba-update" json:"micromamba-update"`
+	SystemMicromamba   bool                `yaml:"system-micromamba" json:"system-micromamba"`
+	MicromambaDigests  map[string]string   `yaml:"micromamba-sha256" json:"micromamba-sha256"`
+	MicromambaVerify   bool                `yaml:"micromamba-verify" json:"micromamba-verify"`
+	CondaChannels      []*CondaChannel     `yaml:"conda-channels" json:"conda-channels"`
+	MirrorChannels     []*CondaChannel     `yaml:"mirror-channels" json:"mirror-channels"`
+	PipRequireHashes   bool                `yaml:"pip-require-hashes" json:"pip-require-hashes"`
+	MicromambaTimeout  int                 `yaml:"micromamba-timeout" json:"micromamba-timeout"`
+	MicromambaRetries  int                 `yaml:"micromamba-retries" json:"micromamba-retries"`
+	MicromambaBackoff  int                 `yaml:"micromamba-backoff" json:"micromamba-backoff"`
+	PipIndexURL        *PipIndex           `yaml:"pip-index-url" json:"pip-index-url"`
+	PipExtraIndexURLs  []*PipIndex         `yaml:"pip-extra-index-urls" json:"pip-extra-index-urls"`
+	PackagePolicy      string              `yaml:"package-policy" json:"package-policy"`
+	SharedPackages     string              `yaml:"shared-pkgs" json:"shared-pkgs"`
+	NativeProbe        string              `yaml:"native-probe" json:"native-probe"`
+}
+
+// CondaChannel is channel (name or URL) used instead of channels listed in
+// conda.yaml. Token for private channel comes from environment variable or
+// from keyring of operating system, and username makes it basic auth.
+type CondaChannel struct {
+	Channel  string `yaml:"channel" json:"channel"`
+	Username string `yaml:"username,omitempty" json:"username,omitempty"`
+	TokenEnv string `yaml:"token-env,omitempty" json:"token-env,omitempty"`
+	Keyring  string `yaml:"keyring,omitempty" json:"keyring,omitempty"`
+}
+
+// PipIndex is PyPI compatible package index used by pip. Token comes from
+// environment variable or from keyring of operating system, and is given to
+// index as basic auth password.
+type PipIndex struct {
+	URL      string `yaml:"url" json:"url"`
+	Username string `yaml:"username,omitempty" json:"username,omitempty"`
+	TokenEnv string `yaml:"token-env,omitempty" json:"token-env,omitempty"`
+	Keyring  string `yaml:"keyring,omitempty" json:"keyring,omitempty"`
+}
+
+// Relocation is extra search/replace pair for relocating environments,
+// where replacement shorter than search is padded (with "/" by default).
+type Relocation struct {
+	Search  string `yaml:"search" json:"search"`
+	Replace string `yaml:"replace" json:"replace"`
+	Padding string `yaml:"padding,omitempty" json:"padding,omitempty"`
+}
+
+// Environment is about building and running robot environments.
+type Environment struct {
+	SizeBudget       float64 `yaml:"size-budget" json:"size-budget"`
+	EnforceBudget    bool    `yaml:"enforce-budget" json:"enforce-budget"`
+	ResolverFallback string  `yaml:"resolver-fallback" json:"resolver-fallback"`
+	EnforceUTF8      bool    `yaml:"enforce-utf8" json:"enforce-utf8"`
+}
+
+// Hololib is about how blobs are stored in hololib and restored from it.
+type Hololib struct {
+	Compression       string `yaml:"compression" json:"compression"`
+	RestoreMode       string `yaml:"restore-mode" json:"restore-mode"`
+	EncryptionKey     string `yaml:"encryption-key" json:"encryption-key"`
+	EncryptionKeyring string `yaml:"encryption-keyring" json:"encryption-keyring"`
+}
+
+type Hooks map[string][]string
+
+// Profiles are named sets of environment variables, where active ones are
+// added into every environment.
+type Profiles struct {
+	Active   []string             `yaml:"active" json:"active"`
+	Profiles map[string]StringMap `yaml:"profiles" json:"profiles"`
+}
+
 type Meta struct {
 	Source  string `yaml:"source" json:"source"`
 	Version string `yaml:"version" json:"version"`
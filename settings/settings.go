@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/robocorp/rcc/blobs"
 	"github.com/robocorp/rcc/common"
@@ -34,10 +35,22 @@ func cacheSettings(result *Settings) (*Settings, error) {
 	return result, nil
 }
 
+// SettingsFileLocation is settings file in use: one in writable home, or
+// when home is split and that does not exist, one pre-seeded into
+// ROBOCORP_HOME.
 func SettingsFileLocation() string {
+	location := WritableSettingsLocation()
+	if pathlib.IsFile(location) || !common.SplitHome() {
+		return location
+	}
 	return filepath.Join(common.RobocorpHome(), "settings.yaml")
 }
 
+// WritableSettingsLocation is where settings are written to.
+func WritableSettingsLocation() string {
+	return filepath.Join(common.WritableHome(), "settings.yaml")
+}
+
 func HasCustomSettings() bool {
 	return pathlib.IsFile(SettingsFileLocation())
 }
@@ -187,6 +200,233 @@ func (it gateway) Hostnames() []string {
 	return config.Hostnames()
 }
 
+func (it gateway) FailureCooldown() time.Duration {
+	config, err := SummonSettings()
+	pretty.Guard(err == nil, 111, "Could not get settings, reason: %v", err)
+	if config.Holotree == nil || config.Holotree.FailureCooldown < 0 {
+		return 0
+	}
+	return time.Duration(config.Holotree.FailureCooldown) * time.Minute
+}
+
+func (it gateway) Holotree() *Holotree {
+	config, err := SummonSettings()
+	pretty.Guard(err == nil, 111, "Could not get settings, reason: %v", err)
+	if config.Holotree == nil {
+		return &Holotree{}
+	}
+	return config.Holotree
+}
+
+func (it gateway) EnvironmentSettings() *Environment {
+	config, err := SummonSettings()
+	pretty.Guard(err == nil, 111, "Could not get settings, reason: %v", err)
+	if config.Environment == nil {
+		config.Environment = &Environment{}
+	}
+	return config.Environment
+}
+
+func (it gateway) HololibSettings() *Hololib {
+	config, err := SummonSettings()
+	pretty.Guard(err == nil, 111, "Could not get settings, reason: %v", err)
+	if config.Hololib == nil {
+		config.Hololib = &Hololib{}
+	}
+	return config.Hololib
+}
+
+func (it gateway) SystemHololib() string {
+	return it.Holotree().SystemLibrary
+}
+
+func (it gateway) ResolverFallback() string {
+	return it.EnvironmentSettings().ResolverFallback
+}
+
+func (it gateway) SharedPackages() string {
+	return it.Holotree().SharedPackages
+}
+
+func (it gateway) Solver() string {
+	return it.Holotree().Solver
+}
+
+func (it gateway) SolverExecutable() string {
+	return it.Holotree().SolverExecutable
+}
+
+func (it gateway) MicromambaVersion() string {
+	return it.Holotree().MicromambaVersion
+}
+
+func (it gateway) MicromambaChannel() string {
+	return it.Holotree().MicromambaChannel
+}
+
+func (it gateway) MicromambaUpdate() bool {
+	return it.Holotree().MicromambaUpdate
+}
+
+func (it gateway) SystemMicromamba() bool {
+	return it.Holotree().SystemMicromamba
+}
+
+func (it gateway) MicromambaDigests() map[string]string {
+	return it.Holotree().MicromambaDigests
+}
+
+func (it gateway) MicromambaVerify() bool {
+	return it.Holotree().MicromambaVerify
+}
+
+func (it gateway) Compression() string {
+	return it.HololibSettings().Compression
+}
+
+func (it gateway) RestoreMode() string {
+	return it.HololibSettings().RestoreMode
+}
+
+func (it gateway) EncryptionKey() string {
+	return it.HololibSettings().EncryptionKey
+}
+
+func (it gateway) EncryptionKeyring() string {
+	return it.HololibSettings().EncryptionKeyring
+}
+
+func (it gateway) VerifyBlobs() bool {
+	return it.Holotree().VerifyBlobs
+}
+
+func (it gateway) CatalogRetention() (int, int) {
+	holotree := it.Holotree()
+	return holotree.CatalogRetention, holotree.CatalogKeepLast
+}
+
+func (it gateway) PreserveAttributes() bool {
+	return it.Holotree().PreserveAttributes
+}
+
+func (it gateway) DigestAlgorithm() string {
+	return it.Holotree().Digest
+}
+
+func (it gateway) ExcludePatterns() []string {
+	return it.Holotree().ExcludePatterns
+}
+
+func (it gateway) BlobStore() string {
+	return it.Holotree().BlobStore
+}
+
+func (it gateway) SigningKey() string {
+	return it.Holotree().SigningKey
+}
+
+func (it gateway) TrustedKeys() []string {
+	return it.Holotree().TrustedKeys
+}
+
+func (it gateway) SparseSpaces() map[string][]string {
+	return it.Holotree().SparseSpaces
+}
+
+func (it gateway) CaseCollisions() string {
+	return it.Holotree().CaseCollisions
+}
+
+func (it gateway) MicromambaTimeout() time.Duration {
+	minutes := it.Holotree().MicromambaTimeout
+	if minutes < 0 {
+		return 0
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func (it gateway) MicromambaRetries() int {
+	return it.Holotree().MicromambaRetries
+}
+
+func (it gateway) MicromambaBackoff() time.Duration {
+	seconds := it.Holotree().MicromambaBackoff
+	if seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func (it gateway) IoLimit() int {
+	return it.Holotree().IoLimit
+}
+
+func (it gateway) ChunkThreshold() int {
+	return it.Holotree().ChunkThreshold
+}
+
+func (it gateway) Relocations() []*Relocation {
+	return it.Holotree().Relocations
+}
+
+func (it gateway) CondaChannels() []*CondaChannel {
+	return it.Holotree().CondaChannels
+}
+
+func (it gateway) MirrorChannels() []*CondaChannel {
+	return it.Holotree().MirrorChannels
+}
+
+func (it gateway) PackagePolicy() string {
+	return it.Holotree().PackagePolicy
+}
+
+func (it gateway) PipRequireHashes() bool {
+	return it.Holotree().PipRequireHashes
+}
+
+func (it gateway) NativeProbe() string {
+	return it.Holotree().NativeProbe
+}
+
+func (it gateway) PipIndexURL() *PipIndex {
+	return it.Holotree().PipIndexURL
+}
+
+func (it gateway) PipExtraIndexURLs() []*PipIndex {
+	return it.Holotree().PipExtraIndexURLs
+}
+
+func (it gateway) EnforceUTF8() bool {
+	return it.EnvironmentSettings().EnforceUTF8
+}
+
+func (it gateway) EnvironmentBudget() (float64, bool) {
+	config := it.EnvironmentSettings()
+	if config.SizeBudget <= 0 {
+		return 0, false
+	}
+	return config.SizeBudget, config.EnforceBudget
+}
+
+func (it gateway) Hooks(stage string) []string {
+	config, err := SummonSettings()
+	pretty.Guard(err == nil, 111, "Could not get settings, reason: %v", err)
+	if config.Hooks == nil {
+		return []string{}
+	}
+	return config.Hooks[stage]
+}
+
+func (it gateway) EnvironmentProfiles() *Profiles {
+	config, err := SummonSettings()
+	pretty.Guard(err == nil, 111, "Could not get settings, reason: %v", err)
+	if config.Profiles == nil {
+		return &Profiles{}
+	}
+	return config.Profiles
+}
+
 func (it gateway) ConfiguredHttpTransport() *http.Transport {
 	return httpTransport
 }
@@ -2,10 +2,14 @@ package shell
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sync/atomic"
+	"time"
 
 	"github.com/robocorp/rcc/common"
 )
@@ -16,6 +20,25 @@ type Task struct {
 	executable  string
 	args        []string
 	stderronly  bool
+	timeout     time.Duration
+}
+
+const (
+	// TimeoutExit is exit code given, when task was killed because of timeout.
+	TimeoutExit = -700
+	// CancelExit is exit code given, when task was not started because rcc
+	// was already cancelled (by signal or --timeout).
+	CancelExit = -701
+)
+
+var (
+	foreground int32
+)
+
+// Foreground tells if interactive child process (one reading rcc stdin) is
+// running. Such process gets Ctrl-C from terminal, and handles it itself.
+func Foreground() bool {
+	return atomic.LoadInt32(&foreground) > 0
 }
 
 func New(environment []string, directory string, task ...string) *Task {
@@ -34,6 +57,13 @@ func (it *Task) StderrOnly() *Task {
 	return it
 }
 
+// Timeout kills task, if it has not finished within limit. Zero limit means
+// no timeout.
+func (it *Task) Timeout(limit time.Duration) *Task {
+	it.timeout = limit
+	return it
+}
+
 func (it *Task) stdout() io.Writer {
 	if it.stderronly {
 		return os.Stderr
@@ -43,7 +73,21 @@ func (it *Task) stdout() io.Writer {
 
 func (it *Task) execute(stdin io.Reader, stdout, stderr io.Writer) (int, error) {
 	common.Trace("Execute %q with arguments %q", it.executable, it.args)
-	command := exec.Command(it.executable, it.args...)
+	if common.Context().Err() != nil {
+		_, reason := common.Cancellation()
+		return CancelExit, fmt.Errorf("%q was not started, because %s", it.executable, reason)
+	}
+	ctx := context.Background()
+	if it.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, it.timeout)
+		defer cancel()
+	}
+	if stdin == os.Stdin {
+		atomic.AddInt32(&foreground, 1)
+		defer atomic.AddInt32(&foreground, -1)
+	}
+	command := exec.CommandContext(ctx, it.executable, it.args...)
 	command.Env = it.environment
 	command.Dir = it.directory
 	command.Stdin = stdin
@@ -58,7 +102,14 @@ func (it *Task) execute(stdin io.Reader, stdout, stderr io.Writer) (int, error)
 	defer func() {
 		common.Debug("PID #%d finished: %v.", command.Process.Pid, command.ProcessState)
 	}()
+	finished := make(chan bool)
+	defer close(finished)
+	go stopOnCancel(command.Process, finished)
 	err = command.Wait()
+	if ctx.Err() == context.DeadlineExceeded {
+		common.Timeline("exec %q timed out", it.executable)
+		return TimeoutExit, fmt.Errorf("%q did not finish within %s and was killed", it.executable, it.timeout)
+	}
 	exit, ok := err.(*exec.ExitError)
 	if ok {
 		return exit.ExitCode(), err
@@ -69,6 +120,26 @@ func (it *Task) execute(stdin io.Reader, stdout, stderr io.Writer) (int, error)
 	return 0, nil
 }
 
+// stopOnCancel gives process time to finish on its own after rcc has been
+// cancelled (for example Ctrl-C also reaches children through terminal),
+// then terminates it, and finally kills it.
+func stopOnCancel(process *os.Process, finished chan bool) {
+	select {
+	case <-finished:
+		return
+	case <-common.Context().Done():
+	}
+	for _, stop := range []func(*os.Process) error{terminate, (*os.Process).Kill} {
+		select {
+		case <-finished:
+			return
+		case <-time.After(common.ChildGrace):
+		}
+		common.Debug("Stopping PID #%d, since rcc was cancelled.", process.Pid)
+		stop(process)
+	}
+}
+
 func (it *Task) Transparent() (int, error) {
 	return it.execute(os.Stdin, it.stdout(), os.Stderr)
 }
@@ -123,6 +194,10 @@ func (it *Task) Tracked(sink io.Writer, interactive bool) (int, error) {
 	return it.execute(stdin, sink, sink)
 }
 
+func (it *Task) Fed(stdin []byte) (int, error) {
+	return it.execute(bytes.NewReader(stdin), it.stdout(), os.Stderr)
+}
+
 func (it *Task) CaptureOutput() (string, int, error) {
 	stdin := bytes.NewReader([]byte{})
 	stdout := bytes.NewBuffer(nil)
@@ -0,0 +1,14 @@
+//go:build !windows
+// +build !windows
+
+package shell
+
+import (
+	"os"
+	"syscall"
+)
+
+// terminate asks process to stop, so that it can clean up after itself.
+func terminate(process *os.Process) error {
+	return process.Signal(syscall.SIGTERM)
+}
@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package shell
+
+import (
+	"os"
+)
+
+// terminate stops process. Windows has no signal for asking process to
+// stop, so it is killed.
+func terminate(process *os.Process) error {
+	return process.Kill()
+}
@@ -2,6 +2,7 @@ package shell_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/robocorp/rcc/conda"
 	"github.com/robocorp/rcc/hamlet"
@@ -27,3 +28,19 @@ func TestCanExecuteSimpleEcho(t *testing.T) {
 	wont_be.Nil(err)
 	wont_be.Equal(0, code)
 }
+
+func TestTimeoutKillsHungTask(t *testing.T) {
+	if conda.IsWindows() {
+		t.Skip("Not a windows test.")
+	}
+
+	must_be, wont_be := hamlet.Specifications(t)
+
+	code, err := shell.New(nil, ".", "sleep", "5").Timeout(100 * time.Millisecond).Transparent()
+	wont_be.Nil(err)
+	must_be.Equal(shell.TimeoutExit, code)
+
+	code, err = shell.New(nil, ".", "echo", "fast").Timeout(5 * time.Second).Transparent()
+	must_be.Nil(err)
+	must_be.Equal(0, code)
+}
@@ -1,6 +1,8 @@
 package wizard
 
 import (
+	"strings"
+
 	"github.com/robocorp/rcc/common"
 	"github.com/robocorp/rcc/pretty"
 )
@@ -19,3 +21,16 @@ func firstOf(arguments []string, missing string) string {
 	}
 	return missing
 }
+
+// Confirm asks yes/no question from user, empty reply meaning defaults.
+func Confirm(question string, defaults bool) (bool, error) {
+	initial := "n"
+	if defaults {
+		initial = "y"
+	}
+	reply, err := ask(question, initial, yesnoPattern, "Answer y(es) or n(o).")
+	if err != nil {
+		return false, err
+	}
+	return strings.HasPrefix(strings.ToLower(reply), "y"), nil
+}
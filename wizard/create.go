@@ -23,6 +23,7 @@ const (
 var (
 	namePattern  = regexp.MustCompile("^[\\w-]*$")
 	digitPattern = regexp.MustCompile("^\\d+$")
+	yesnoPattern = regexp.MustCompile("(?i)^(?:y|yes|n|no)?$")
 )
 
 func ask(question, defaults string, validator *regexp.Regexp, erratic string) (string, error) {
@@ -0,0 +1,32 @@
+package xviper
+
+import (
+	"github.com/robocorp/rcc/common"
+)
+
+const (
+	auditEnabledKey = `audit.enabled`
+	auditPathKey    = `audit.path`
+)
+
+func EnableAuditLog(state bool) {
+	Set(auditEnabledKey, state)
+}
+
+func AuditLogEnabled() bool {
+	return GetBool(auditEnabledKey)
+}
+
+func SetAuditLogPath(filename string) {
+	Set(auditPathKey, filename)
+}
+
+// AuditLogPath is configured audit log location, or ROBOCORP_HOME/audit.log
+// when none is configured.
+func AuditLogPath() string {
+	filename := GetString(auditPathKey)
+	if len(filename) == 0 {
+		return common.AuditLog()
+	}
+	return filename
+}
@@ -0,0 +1,541 @@
+package yamledit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Document is line based YAML editor, which only understands block style
+// mappings and sequences, and single line flow sequences of toplevel keys,
+// but keeps everything else (comments, empty lines, ordering, quoting and
+// formatting) exactly as user wrote them.
+type Document struct {
+	lines []string
+	crlf  bool
+	final bool
+}
+
+type Item struct {
+	Line    int
+	Indent  int
+	Value   string
+	Comment string
+	flow    int
+}
+
+func (it *Item) IsMapping() bool {
+	return strings.HasSuffix(it.Value, ":")
+}
+
+func (it *Item) Unquoted() string {
+	return Unquote(it.Value)
+}
+
+type block struct {
+	start  int
+	end    int
+	parent int
+	flow   bool
+}
+
+// flowSequence is single line "key: [a, 'b', "c"]  # comment" split into
+// parts, so that it can be put back together with same spacing.
+type flowSequence struct {
+	head    string
+	open    string
+	values  []string
+	sep     string
+	close   string
+	comment string
+}
+
+func Unquote(value string) string {
+	trimmed := strings.TrimSpace(value)
+	if len(trimmed) < 2 {
+		return trimmed
+	}
+	first, last := trimmed[0], trimmed[len(trimmed)-1]
+	if first == '\'' && last == '\'' {
+		return strings.ReplaceAll(trimmed[1:len(trimmed)-1], "''", "'")
+	}
+	if first == '"' && last == '"' {
+		unquoted, err := strconv.Unquote(trimmed)
+		if err == nil {
+			return unquoted
+		}
+		return trimmed[1 : len(trimmed)-1]
+	}
+	return trimmed
+}
+
+func needsQuotes(value string, flow bool) bool {
+	if len(value) == 0 || value != strings.TrimSpace(value) {
+		return true
+	}
+	if strings.ContainsAny(value[:1], ",[]{}#&*!|>'\"%@`") {
+		return true
+	}
+	for _, indicator := range []string{"-", "?", ":"} {
+		if value == indicator || strings.HasPrefix(value, indicator+" ") {
+			return true
+		}
+	}
+	if strings.HasSuffix(value, ":") || strings.Contains(value, ": ") || strings.Contains(value, " #") {
+		return true
+	}
+	return flow && strings.ContainsAny(value, ",[]{}")
+}
+
+// requote formats value same way as original was quoted, and when original
+// was plain, quotes value only if it would not be valid plain scalar.
+func requote(original, value string, flow bool) string {
+	style := ""
+	if len(original) > 1 && original[0] == original[len(original)-1] {
+		style = original[:1]
+	}
+	switch {
+	case style == "'":
+		return fmt.Sprintf("'%s'", strings.ReplaceAll(value, "'", "''"))
+	case style == `"` || needsQuotes(value, flow):
+		return strconv.Quote(value)
+	default:
+		return value
+	}
+}
+
+func Parse(content []byte) *Document {
+	text := string(content)
+	crlf := strings.Contains(text, "\r\n")
+	final := strings.HasSuffix(text, "\n")
+	text = strings.TrimSuffix(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	lines := []string{}
+	if len(text) > 0 || !final {
+		lines = strings.Split(text, "\n")
+	}
+	return &Document{
+		lines: lines,
+		crlf:  crlf,
+		final: final || len(lines) == 0,
+	}
+}
+
+func Load(filename string) (*Document, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(content), nil
+}
+
+func (it *Document) Bytes() []byte {
+	newline := "\n"
+	if it.crlf {
+		newline = "\r\n"
+	}
+	text := strings.Join(it.lines, newline)
+	if it.final {
+		text += newline
+	}
+	return []byte(text)
+}
+
+func (it *Document) SaveAs(filename string) error {
+	mode := os.FileMode(0o640)
+	stat, err := os.Stat(filename)
+	if err == nil {
+		mode = stat.Mode()
+	}
+	return ioutil.WriteFile(filename, it.Bytes(), mode)
+}
+
+// HasComments tells if there is anything that full rewrite would lose.
+func (it *Document) HasComments() bool {
+	for at, line := range it.lines {
+		if isComment(line) || len(it.item(at).Comment) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func isNoise(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return len(trimmed) == 0 || strings.HasPrefix(trimmed, "#")
+}
+
+func isComment(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "#")
+}
+
+func isItem(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "-" || strings.HasPrefix(trimmed, "- ")
+}
+
+// splitComment separates value and trailing comment (with its leading
+// whitespace), ignoring hash characters inside quotes.
+func splitComment(text string) (string, string) {
+	quote := rune(0)
+	for at, char := range text {
+		switch {
+		case quote != 0 && char == quote:
+			quote = 0
+		case quote == 0 && (char == '"' || char == '\''):
+			quote = char
+		case quote == 0 && char == '#' && (at == 0 || text[at-1] == ' ' || text[at-1] == '\t'):
+			value := strings.TrimRight(text[:at], " \t")
+			return value, text[len(value):]
+		}
+	}
+	return strings.TrimRight(text, " \t"), ""
+}
+
+func (it *Document) item(at int) *Item {
+	line := it.lines[at]
+	indent := indentOf(line)
+	body := strings.TrimPrefix(strings.TrimSpace(line), "-")
+	value, comment := splitComment(strings.TrimLeft(body, " "))
+	return &Item{
+		Line:    at,
+		Indent:  indent,
+		Value:   value,
+		Comment: comment,
+	}
+}
+
+func (it *Document) topLevel(key string) (*block, error) {
+	for at, line := range it.lines {
+		if isNoise(line) || indentOf(line) > 0 {
+			continue
+		}
+		value, _ := splitComment(line)
+		if value == key+":" {
+			end := at + 1
+			for ; end < len(it.lines); end++ {
+				candidate := it.lines[end]
+				if !isNoise(candidate) && indentOf(candidate) == 0 && !isItem(candidate) {
+					break
+				}
+			}
+			return &block{at + 1, end, -1, false}, nil
+		}
+		if strings.HasPrefix(value, key+":") {
+			if parseFlow(line) == nil {
+				return nil, fmt.Errorf("Key %q is not in block style or single line flow sequence, cannot edit it.", key)
+			}
+			return &block{at, at + 1, -1, true}, nil
+		}
+	}
+	return nil, nil
+}
+
+func parseFlow(line string) *flowSequence {
+	value, comment := splitComment(line)
+	colon := strings.Index(value, ":")
+	if colon < 0 {
+		return nil
+	}
+	body := value[colon+1:]
+	inner := strings.TrimSpace(body)
+	if !strings.HasPrefix(inner, "[") || !strings.HasSuffix(inner, "]") {
+		return nil
+	}
+	inner = inner[1 : len(inner)-1]
+	values := []string{}
+	quote, start, escaped := rune(0), 0, false
+	for at, char := range inner {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && char == '\\':
+			escaped = true
+		case quote != 0 && char == quote:
+			quote = 0
+		case quote != 0:
+		case char == '"' || char == '\'':
+			quote = char
+		case strings.ContainsRune("[]{}", char):
+			return nil
+		case char == ',':
+			values = append(values, strings.TrimSpace(inner[start:at]))
+			start = at + 1
+		}
+	}
+	if quote != 0 {
+		return nil
+	}
+	last := strings.TrimSpace(inner[start:])
+	if len(last) > 0 {
+		values = append(values, last)
+	}
+	result := &flowSequence{
+		head:    value[:colon+1] + body[:len(body)-len(strings.TrimLeft(body, " "))],
+		values:  values,
+		sep:     ", ",
+		comment: comment,
+	}
+	if len(values) > 0 {
+		result.open = "[" + inner[:len(inner)-len(strings.TrimLeft(inner, " "))]
+		result.close = inner[len(strings.TrimRight(inner, " ")):] + "]"
+	} else {
+		result.open, result.close = "[", "]"
+	}
+	if comma := strings.Index(inner, ","); comma > -1 && len(values) > 1 {
+		rest := inner[comma+1:]
+		result.sep = "," + rest[:len(rest)-len(strings.TrimLeft(rest, " "))]
+	}
+	return result
+}
+
+func (it *flowSequence) String() string {
+	return it.head + it.open + strings.Join(it.values, it.sep) + it.close + it.comment
+}
+
+func (it *Document) editFlow(at int, edit func(*flowSequence)) {
+	sequence := parseFlow(it.lines[at])
+	if sequence != nil {
+		edit(sequence)
+		it.lines[at] = sequence.String()
+	}
+}
+
+func (it *Document) children(item *Item, limit int) *block {
+	end := item.Line + 1
+	for ; end < limit; end++ {
+		candidate := it.lines[end]
+		if !isNoise(candidate) && indentOf(candidate) <= item.Indent {
+			break
+		}
+	}
+	return &block{item.Line + 1, end, item.Indent, false}
+}
+
+func (it *Document) items(scope *block) []*Item {
+	result := []*Item{}
+	if scope.flow {
+		sequence := parseFlow(it.lines[scope.start])
+		for at, value := range sequence.values {
+			result = append(result, &Item{Line: scope.start, Value: value, flow: at + 1})
+		}
+		return result
+	}
+	indent := -1
+	for at := scope.start; at < scope.end; at++ {
+		line := it.lines[at]
+		if isNoise(line) || !isItem(line) {
+			continue
+		}
+		current := indentOf(line)
+		if current <= scope.parent {
+			continue
+		}
+		if indent < 0 {
+			indent = current
+		}
+		if current == indent {
+			result = append(result, it.item(at))
+		}
+	}
+	return result
+}
+
+func (it *Document) locate(path []string) (*block, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("Empty path.")
+	}
+	scope, err := it.topLevel(path[0])
+	if err != nil || scope == nil {
+		return nil, err
+	}
+	for _, step := range path[1:] {
+		if scope.flow {
+			return nil, nil
+		}
+		var found *Item
+		for _, item := range it.items(scope) {
+			if item.Value == step+":" {
+				found = item
+				break
+			}
+		}
+		if found == nil {
+			return nil, nil
+		}
+		scope = it.children(found, scope.end)
+	}
+	return scope, nil
+}
+
+// Sequence returns items of block sequence found from path. First path
+// element is toplevel key, following ones are "key:" items inside sequences.
+func (it *Document) Sequence(path ...string) ([]*Item, error) {
+	scope, err := it.locate(path)
+	if err != nil || scope == nil {
+		return []*Item{}, err
+	}
+	return it.items(scope), nil
+}
+
+// Replace changes value of item, keeping its quoting style and comment.
+func (it *Document) Replace(item *Item, value string) {
+	if item.flow > 0 {
+		it.editFlow(item.Line, func(sequence *flowSequence) {
+			sequence.values[item.flow-1] = requote(item.Value, value, true)
+		})
+		return
+	}
+	prefix := strings.Repeat(" ", item.Indent)
+	it.lines[item.Line] = fmt.Sprintf("%s- %s%s", prefix, requote(item.Value, value, false), item.Comment)
+}
+
+// Remove deletes item with its children, and comment lines directly above
+// it (without empty line between), since those describe removed item.
+func (it *Document) Remove(item *Item) {
+	if item.flow > 0 {
+		it.editFlow(item.Line, func(sequence *flowSequence) {
+			sequence.values = append(sequence.values[:item.flow-1], sequence.values[item.flow:]...)
+		})
+		return
+	}
+	end := item.Line + 1
+	if item.IsMapping() {
+		end = it.children(item, len(it.lines)).end
+	}
+	start := item.Line
+	for start > 0 && isComment(it.lines[start-1]) && indentOf(it.lines[start-1]) >= item.Indent {
+		start--
+	}
+	it.lines = append(it.lines[:start], it.lines[end:]...)
+}
+
+func (it *Document) insert(at int, lines ...string) {
+	result := make([]string, 0, len(it.lines)+len(lines))
+	result = append(result, it.lines[:at]...)
+	result = append(result, lines...)
+	it.lines = append(result, it.lines[at:]...)
+}
+
+func (it *Document) lastContent(scope *block) int {
+	last := scope.start - 1
+	for at := scope.start; at < scope.end; at++ {
+		if !isNoise(it.lines[at]) {
+			last = at
+		}
+	}
+	return last
+}
+
+// Append adds new scalar item into sequence in path, after last existing
+// scalar item (so that nested "key:" items stay last), creating missing
+// sequences on the way.
+func (it *Document) Append(value string, path ...string) error {
+	return it.append(requote("", value, false), path)
+}
+
+func (it *Document) append(value string, path []string) error {
+	scope, err := it.locate(path)
+	if err != nil {
+		return err
+	}
+	if scope == nil {
+		return it.create(value, path)
+	}
+	if scope.flow {
+		it.editFlow(scope.start, func(sequence *flowSequence) {
+			sequence.values = append(sequence.values, requote("", Unquote(value), true))
+		})
+		return nil
+	}
+	items := it.items(scope)
+	indent := scope.parent + 2
+	if scope.parent < 0 {
+		indent = 2
+	}
+	at := it.lastContent(scope) + 1
+	for _, item := range items {
+		indent = item.Indent
+		if item.IsMapping() {
+			at = item.Line
+			break
+		}
+		at = item.Line + 1
+	}
+	it.insert(at, fmt.Sprintf("%s- %s", strings.Repeat(" ", indent), value))
+	return nil
+}
+
+func (it *Document) create(value string, path []string) error {
+	if len(path) == 1 {
+		if len(it.lines) > 0 && !isNoise(it.lines[len(it.lines)-1]) {
+			it.lines = append(it.lines, "")
+		}
+		it.lines = append(it.lines, fmt.Sprintf("%s:", path[0]), fmt.Sprintf("  - %s", value))
+		return nil
+	}
+	parent := path[:len(path)-1]
+	key := path[len(path)-1]
+	scope, err := it.locate(parent)
+	if err != nil {
+		return err
+	}
+	if scope == nil {
+		err = it.create(key+":", parent)
+	} else if scope.flow {
+		err = fmt.Errorf("Key %q is flow sequence, cannot add %q into it.", path[0], key)
+	} else {
+		err = it.appendMapping(key, scope)
+	}
+	if err != nil {
+		return err
+	}
+	return it.append(value, path)
+}
+
+func (it *Document) appendMapping(key string, scope *block) error {
+	indent := 2
+	items := it.items(scope)
+	if len(items) > 0 {
+		indent = items[0].Indent
+	}
+	at := it.lastContent(scope) + 1
+	it.insert(at, fmt.Sprintf("%s- %s:", strings.Repeat(" ", indent), key))
+	return nil
+}
+
+// SetScalar sets toplevel "key: value", keeping possible comment and quoting
+// style.
+func (it *Document) SetScalar(key, value string) error {
+	for at, line := range it.lines {
+		if isNoise(line) || indentOf(line) > 0 {
+			continue
+		}
+		current, comment := splitComment(line)
+		if current == key+":" {
+			return fmt.Errorf("Key %q is not a scalar, cannot set it.", key)
+		}
+		if strings.HasPrefix(current, key+":") {
+			original := strings.TrimSpace(current[len(key)+1:])
+			it.lines[at] = fmt.Sprintf("%s: %s%s", key, requote(original, value, false), comment)
+			return nil
+		}
+	}
+	it.insert(it.header(), fmt.Sprintf("%s: %s", key, requote("", value, false)))
+	return nil
+}
+
+// header returns first line after leading comments and empty lines, so that
+// new toplevel keys go below header comment of file.
+func (it *Document) header() int {
+	at := 0
+	for at < len(it.lines) && isNoise(it.lines[at]) {
+		at++
+	}
+	return at
+}
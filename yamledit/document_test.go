@@ -0,0 +1,228 @@
+package yamledit_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/yamledit"
+)
+
+const (
+	condaSample = `channels:
+  # Define conda channels here.
+  - conda-forge
+
+dependencies:
+  # conda packages
+  - python=3.7.5 # interpreter
+
+  - pip=20.1
+  - pip:
+    # pip packages
+    - rpaframework==11.1.3 # https://rpaframework.org/releasenotes.html
+    - "requests#2"
+`
+
+	robotSample = `# Robot for reports.
+tasks:
+  Run all:
+    shell: python -m robot --report NONE tasks.robot
+
+condaConfigFile: conda.yaml
+artifactsDir: 'output'  # keep in sync with CI
+
+PATH: [., bin]
+PYTHONPATH: [ ., "libraries, extra" ] # comma inside quotes
+ignoreFiles:
+  - .gitignore
+  - 'temp files'
+`
+)
+
+func TestCanReadNestedSequences(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	sut := yamledit.Parse([]byte(condaSample))
+	must.Equal(condaSample, string(sut.Bytes()))
+
+	channels, err := sut.Sequence("channels")
+	must.Nil(err)
+	must.Equal(1, len(channels))
+	must.Equal("conda-forge", channels[0].Value)
+
+	conda, err := sut.Sequence("dependencies")
+	must.Nil(err)
+	must.Equal(3, len(conda))
+	must.Equal("python=3.7.5", conda[0].Value)
+	must.Equal(" # interpreter", conda[0].Comment)
+	must.True(conda[2].IsMapping())
+
+	pip, err := sut.Sequence("dependencies", "pip")
+	must.Nil(err)
+	must.Equal(2, len(pip))
+	must.Equal("rpaframework==11.1.3", pip[0].Value)
+	must.Equal("requests#2", pip[1].Unquoted())
+
+	missing, err := sut.Sequence("rccPostInstall")
+	must.Nil(err)
+	must.Equal(0, len(missing))
+	wont.Nil(missing)
+}
+
+func TestEditsKeepCommentsAndFormatting(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	sut := yamledit.Parse([]byte(condaSample))
+	conda, _ := sut.Sequence("dependencies")
+	sut.Replace(conda[0], "python=3.9.7")
+	must.Nil(sut.Append("nodejs=16.6.1", "dependencies"))
+	pip, _ := sut.Sequence("dependencies", "pip")
+	sut.Remove(pip[1])
+	must.Nil(sut.Append("robotframework==4.1.1", "dependencies", "pip"))
+	must.Nil(sut.Append("echo done", "rccPostInstall"))
+	must.Nil(sut.SetScalar("name", "example"))
+
+	expected := `name: example
+channels:
+  # Define conda channels here.
+  - conda-forge
+
+dependencies:
+  # conda packages
+  - python=3.9.7 # interpreter
+
+  - pip=20.1
+  - nodejs=16.6.1
+  - pip:
+    # pip packages
+    - rpaframework==11.1.3 # https://rpaframework.org/releasenotes.html
+    - robotframework==4.1.1
+
+rccPostInstall:
+  - echo done
+`
+	must.Equal(expected, string(sut.Bytes()))
+}
+
+func TestCanCreateMissingNestedSequences(t *testing.T) {
+	must, wont := hamlet.Specifications(t)
+
+	sut := yamledit.Parse([]byte("channels:\r\n- conda-forge\r\n"))
+	must.Nil(sut.Append("python=3.7.5", "dependencies"))
+	must.Nil(sut.Append("rpaframework", "dependencies", "pip"))
+	must.Equal("channels:\r\n- conda-forge\r\n\r\ndependencies:\r\n  - python=3.7.5\r\n  - pip:\r\n    - rpaframework\r\n", string(sut.Bytes()))
+
+	flow := yamledit.Parse([]byte("channels: [conda-forge]\n"))
+	channels, err := flow.Sequence("channels")
+	must.Nil(err)
+	must.Equal(1, len(channels))
+	wont.Nil(flow.Append("python=3.7.5", "channels", "pip"))
+
+	for _, unsupported := range []string{"channels: {a: b}\n", "channels: [\n  conda-forge]\n", "channels: [[a]]\n"} {
+		_, err = yamledit.Parse([]byte(unsupported)).Sequence("channels")
+		wont.Nil(err)
+	}
+}
+
+func TestCanReadFlowSequences(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	sut := yamledit.Parse([]byte(robotSample))
+	must.Equal(robotSample, string(sut.Bytes()))
+
+	path, err := sut.Sequence("PATH")
+	must.Nil(err)
+	must.Equal(2, len(path))
+	must.Equal(".", path[0].Value)
+	must.Equal("bin", path[1].Value)
+
+	pythonpath, err := sut.Sequence("PYTHONPATH")
+	must.Nil(err)
+	must.Equal(2, len(pythonpath))
+	must.Equal(`"libraries, extra"`, pythonpath[1].Value)
+	must.Equal("libraries, extra", pythonpath[1].Unquoted())
+
+	ignored, err := sut.Sequence("ignoreFiles")
+	must.Nil(err)
+	must.Equal("temp files", ignored[1].Unquoted())
+}
+
+func TestRobotEditsKeepCommentsQuotingAndFlowStyle(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	sut := yamledit.Parse([]byte(robotSample))
+	must.Nil(sut.SetScalar("artifactsDir", "it's output"))
+	path, _ := sut.Sequence("PATH")
+	sut.Replace(path[1], "scripts, tools")
+	must.Nil(sut.Append("vendor", "PATH"))
+	pythonpath, _ := sut.Sequence("PYTHONPATH")
+	sut.Remove(pythonpath[0])
+	must.Nil(sut.Append("libs", "PYTHONPATH"))
+	ignored, _ := sut.Sequence("ignoreFiles")
+	sut.Replace(ignored[1], "temporary files")
+	must.Nil(sut.Append("# not a comment", "ignoreFiles"))
+
+	expected := `# Robot for reports.
+tasks:
+  Run all:
+    shell: python -m robot --report NONE tasks.robot
+
+condaConfigFile: conda.yaml
+artifactsDir: 'it''s output'  # keep in sync with CI
+
+PATH: [., "scripts, tools", vendor]
+PYTHONPATH: [ "libraries, extra", libs ] # comma inside quotes
+ignoreFiles:
+  - .gitignore
+  - 'temporary files'
+  - "# not a comment"
+`
+	must.Equal(expected, string(sut.Bytes()))
+
+	path, _ = sut.Sequence("PATH")
+	must.Equal("scripts, tools", path[1].Unquoted())
+	for at := len(path) - 1; at >= 0; at-- {
+		sut.Remove(path[at])
+	}
+	must.Nil(sut.Append("bin", "PATH"))
+	must.True(strings.Contains(string(sut.Bytes()), "\nPATH: [bin]\n"))
+}
+
+func TestRemoveTakesCommentsOfRemovedItem(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	sut := yamledit.Parse([]byte(condaSample))
+	pip, _ := sut.Sequence("dependencies", "pip")
+	sut.Remove(pip[0])
+	conda, _ := sut.Sequence("dependencies")
+	sut.Remove(conda[0])
+
+	expected := `channels:
+  # Define conda channels here.
+  - conda-forge
+
+dependencies:
+
+  - pip=20.1
+  - pip:
+    - "requests#2"
+`
+	must.Equal(expected, string(sut.Bytes()))
+
+	conda, _ = sut.Sequence("dependencies")
+	sut.Remove(conda[1])
+	must.True(strings.HasSuffix(string(sut.Bytes()), "\n  - pip=20.1\n"))
+}
+
+func TestNewScalarsGoBelowHeaderComment(t *testing.T) {
+	must, _ := hamlet.Specifications(t)
+
+	sut := yamledit.Parse([]byte("# Environment for reports.\n# Owned by reporting team.\n\nchannels:\n  - conda-forge\n"))
+	must.Nil(sut.SetScalar("name", "reports"))
+	must.Equal("# Environment for reports.\n# Owned by reporting team.\n\nname: reports\nchannels:\n  - conda-forge\n", string(sut.Bytes()))
+
+	empty := yamledit.Parse([]byte("# nothing yet\n"))
+	must.Nil(empty.SetScalar("name", "reports"))
+	must.Equal("# nothing yet\nname: reports\n", string(empty.Bytes()))
+}